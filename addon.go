@@ -5,6 +5,7 @@ package nvr
 import (
 	"context"
 	stdLog "log"
+	"nvr/pkg/addon"
 	"nvr/pkg/monitor"
 	"nvr/pkg/storage"
 	"nvr/pkg/web"
@@ -14,18 +15,20 @@ import (
 type appRunHook func(context.Context, *App) error
 
 type hookList struct {
-	newAuthenticator    auth.NewAuthenticatorFunc
-	onAppRun            []appRunHook
-	template            []web.TemplateHook
-	templateSub         []web.TemplateHook
-	templateData        []web.TemplateDataFunc
-	monitorStart        []monitor.StartHook
-	monitorInputProcess []monitor.StartInputHook
-	monitorEvent        []monitor.EventHook
-	monitorRecSave      []monitor.RecSaveHook
-	monitorRecSaved     []monitor.RecSavedHook
-	migrationMonitor    []monitor.MigationHook
-	logSource           []string
+	newAuthenticator     auth.NewAuthenticatorFunc
+	onAppRun             []appRunHook
+	template             []web.TemplateHook
+	templateSub          []web.TemplateHook
+	templateData         []web.TemplateDataFunc
+	monitorStart         []monitor.StartHook
+	monitorInputProcess  []monitor.StartInputHook
+	monitorEvent         []monitor.EventHook
+	monitorRecSave       []monitor.RecSaveHook
+	monitorRecSaved      []monitor.RecSavedHook
+	monitorEventClip     []monitor.EventClipHook
+	monitorEventSnapshot []monitor.EventSnapshotHook
+	migrationMonitor     []monitor.MigationHook
+	logSource            []string
 }
 
 var hooks = &hookList{}
@@ -85,6 +88,18 @@ func RegisterMonitorRecSavedHook(h monitor.RecSavedHook) {
 	hooks.monitorRecSaved = append(hooks.monitorRecSaved, h)
 }
 
+// RegisterMonitorEventClipHook registers hook that's
+// called after the clip of an event has been saved.
+func RegisterMonitorEventClipHook(h monitor.EventClipHook) {
+	hooks.monitorEventClip = append(hooks.monitorEventClip, h)
+}
+
+// RegisterMonitorEventSnapshotHook registers hook that's
+// called after the snapshot of an event has been saved.
+func RegisterMonitorEventSnapshotHook(h monitor.EventSnapshotHook) {
+	hooks.monitorEventSnapshot = append(hooks.monitorEventSnapshot, h)
+}
+
 // RegisterMigrationMonitorHook is called when each monitor config is loaded.
 func RegisterMigrationMonitorHook(h monitor.MigationHook) {
 	hooks.migrationMonitor = append(hooks.migrationMonitor, h)
@@ -104,9 +119,9 @@ func (h *hookList) appRun(ctx context.Context, app *App) error {
 	return nil
 }
 
-func (h *hookList) tplHooks() web.TemplateHooks {
+func (h *hookList) tplHooks(addonTpls *addon.Templates) web.TemplateHooks {
 	tplHook := func(pageFiles map[string]string) error {
-		for _, hook := range h.template {
+		for _, hook := range append(h.template, addonTpls.PageHooks()...) {
 			if err := hook(pageFiles); err != nil {
 				return err
 			}
@@ -114,7 +129,7 @@ func (h *hookList) tplHooks() web.TemplateHooks {
 		return nil
 	}
 	tplSubHook := func(pageFiles map[string]string) error {
-		for _, hook := range h.templateSub {
+		for _, hook := range append(h.templateSub, addonTpls.SubHooks()...) {
 			if err := hook(pageFiles); err != nil {
 				return err
 			}
@@ -128,7 +143,7 @@ func (h *hookList) tplHooks() web.TemplateHooks {
 	}
 }
 
-func (h *hookList) monitor() *monitor.Hooks {
+func (h *hookList) monitor(addons *addon.Manager) *monitor.Hooks {
 	startHook := func(ctx context.Context, m *monitor.Monitor) {
 		for _, hook := range h.monitorStart {
 			hook(ctx, m)
@@ -143,6 +158,7 @@ func (h *hookList) monitor() *monitor.Hooks {
 		for _, hook := range h.monitorEvent {
 			hook(r, event)
 		}
+		addons.OnEvent(r, event)
 	}
 	recSaveHook := func(r *monitor.Recorder, args *string) {
 		for _, hook := range h.monitorRecSave {
@@ -153,6 +169,17 @@ func (h *hookList) monitor() *monitor.Hooks {
 		for _, hook := range h.monitorRecSaved {
 			hook(r, recPath, recData)
 		}
+		addons.OnRecordingFinalized(r, recPath, recData)
+	}
+	eventClipHook := func(r *monitor.Recorder, event storage.Event, clipID string) {
+		for _, hook := range h.monitorEventClip {
+			hook(r, event, clipID)
+		}
+	}
+	eventSnapshotHook := func(r *monitor.Recorder, event storage.Event, snapshotID string) {
+		for _, hook := range h.monitorEventSnapshot {
+			hook(r, event, snapshotID)
+		}
 	}
 	migrateHook := func(conf monitor.RawConfig) error {
 		for _, hook := range h.migrationMonitor {
@@ -163,13 +190,20 @@ func (h *hookList) monitor() *monitor.Hooks {
 		}
 		return nil
 	}
+	var frameHook monitor.FrameHook
+	if addons.HasFrameHooks() {
+		frameHook = addons.OnFrame
+	}
 
 	return &monitor.Hooks{
-		Start:      startHook,
-		StartInput: startInputHook,
-		Event:      eventHook,
-		RecSave:    recSaveHook,
-		RecSaved:   recSavedHook,
-		Migrate:    migrateHook,
+		Start:         startHook,
+		StartInput:    startInputHook,
+		Event:         eventHook,
+		RecSave:       recSaveHook,
+		RecSaved:      recSavedHook,
+		EventClip:     eventClipHook,
+		EventSnapshot: eventSnapshotHook,
+		Migrate:       migrateHook,
+		Frame:         frameHook,
 	}
 }
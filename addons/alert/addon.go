@@ -3,21 +3,32 @@
 package alert
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"nvr"
+	"nvr/pkg/feed"
 	"nvr/pkg/log"
 	"nvr/pkg/monitor"
 	"nvr/pkg/storage"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 )
 
-// Hook Alert hook.
+// Hook Alert hook. The bytes are the MP4 clip of the
+// event if clips are enabled for the alert, otherwise nil.
 type Hook func(*monitor.Recorder, *storage.Event, []byte)
 
-var addon struct {
-	hooks []Hook
+var addon = struct {
+	hooks   []Hook
+	senders map[string]SendFunc
+}{
+	senders: make(map[string]SendFunc),
 }
 
 // RegisterAlertHook registers hook that's called on alerts.
@@ -31,18 +42,66 @@ func init() {
 
 	nvr.RegisterLogSource([]string{"alert"})
 	nvr.RegisterMonitorEventHook(a.onEvent)
+	nvr.RegisterMonitorEventClipHook(a.onEventClip)
+
+	nvr.RegisterAppRunHook(func(ctx context.Context, app *nvr.App) error {
+		logf := func(level log.Level, format string, v ...interface{}) {
+			app.Logger.Log(log.Entry{
+				Level: level,
+				Src:   "alert",
+				Msg:   fmt.Sprintf(format, v...),
+			})
+		}
+		if app.Env.AlertWebhook != "" {
+			RegisterAlertSender("webhook", newWebhookSender(app.Env.AlertWebhook, http.DefaultClient))
+		}
+		readEventClip := func(clipID string) ([]byte, error) {
+			return readClip(app.Env.EventClipsDir(), clipID)
+		}
+		q, err := newDeliveryQueue(
+			app.DB,
+			filepath.Join(app.Env.StorageDir, "alert-queue.json"),
+			addon.senders,
+			readEventClip,
+			logf,
+		)
+		if err != nil {
+			return err
+		}
+		a.queue = q
+		go q.run(ctx)
+		go a.runDiskAlerts(ctx, app.Storage.DiskAlerts(), logf)
+
+		auth := app.Auth
+		app.Router.Handle("/api/alert/queue", auth.Admin(handleQueue(q)))
+		app.Router.Handle("/api/alert/queue/requeue", auth.Admin(auth.CSRF(
+			handleQueueAction(http.MethodPost, q.Requeue))))
+		app.Router.Handle("/api/alert/queue/drop", auth.Admin(auth.CSRF(
+			handleQueueAction(http.MethodDelete, q.Drop))))
+		return nil
+	})
 }
 
 func newAlerter(alertHooks []Hook) *alerter {
 	return &alerter{
-		alertHooks: alertHooks,
-		prevAlerts: map[string]time.Time{},
+		alertHooks:   alertHooks,
+		prevAlerts:   map[string]time.Time{},
+		pendingClips: map[string]chan eventClip{},
+		clipTimeout:  2 * time.Minute,
 	}
 }
 
 type alerter struct {
 	alertHooks []Hook
 	prevAlerts map[string]time.Time // map[monitorID]prevAlert.
+
+	pendingClips map[string]chan eventClip // map[eventID]clip.
+	clipTimeout  time.Duration
+
+	// Set on app start, before monitors start.
+	queue *deliveryQueue
+
+	mu sync.Mutex
 }
 
 func (a *alerter) onEvent(r *monitor.Recorder, event *storage.Event) {
@@ -105,18 +164,157 @@ func (a *alerter) processEvent(
 
 	a.prevAlerts[id] = time.Now()
 
+	var clip eventClip
+	if config.Clip == "true" && r.Config.EventClipEnabled() {
+		clip, err = a.waitForClip(monitor.EventID(id, event.Time))
+		if err != nil {
+			// Send the alert without the clip.
+			r.Logger.Log(log.Entry{
+				Level:     log.LevelError,
+				Src:       "alert",
+				MonitorID: id,
+				Msg:       err.Error(),
+			})
+		}
+	}
+
 	for _, hook := range a.alertHooks {
-		hook(r, event, nil)
+		hook(r, event, clip.data)
+	}
+
+	if a.queue != nil && a.queue.hasSenders() {
+		alert := Alert{
+			MonitorID:   id,
+			MonitorName: r.Config.Name(),
+			Time:        event.Time,
+			Detection:   d,
+		}
+		if clip.data != nil {
+			alert.ClipID = clip.id
+		}
+		a.queue.send(alert, clip.data, time.Now())
 	}
 
 	return nil
 }
 
+// diskAlertLevels are the storage alert levels that are sent as alerts.
+var diskAlertLevels = map[string]bool{
+	storage.DiskAlertFailover:     true,
+	storage.DiskAlertRestored:     true,
+	storage.DiskAlertRetentionLow: true,
+	storage.DiskAlertRetentionOK:  true,
+}
+
+// runDiskAlerts sends the new storage alerts until ctx is canceled.
+func (a *alerter) runDiskAlerts(
+	ctx context.Context,
+	alerts *feed.Buffer[storage.DiskAlert],
+	logf log.Func,
+) {
+	cursor := alerts.Cursor()
+	for {
+		items := alerts.Wait(ctx, cursor)
+		if items == nil {
+			return
+		}
+		for _, item := range items {
+			cursor = item.Cursor
+			a.onDiskAlert(item.Value, logf, time.Now())
+		}
+	}
+}
+
+func (a *alerter) onDiskAlert(alert storage.DiskAlert, logf log.Func, now time.Time) {
+	if !diskAlertLevels[alert.Level] {
+		return
+	}
+	logf(log.LevelInfo, "storage: %v", alert.Level)
+	if a.queue != nil && a.queue.hasSenders() {
+		a.queue.send(Alert{Time: alert.Time, DiskAlert: &alert}, nil, now)
+	}
+}
+
+// ErrClipTimeout the event clip wasn't saved in time.
+var ErrClipTimeout = errors.New("timeout waiting for event clip")
+
+// eventClip is the clip of an event. The ID is the ID of the first event
+// if the event was merged into the clip of an earlier event.
+type eventClip struct {
+	id   string
+	data []byte
+}
+
+// waitForClip blocks until the clip of the event has been saved.
+func (a *alerter) waitForClip(eventID string) (eventClip, error) {
+	res := make(chan eventClip, 1)
+	a.mu.Lock()
+	a.pendingClips[eventID] = res
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		delete(a.pendingClips, eventID)
+		a.mu.Unlock()
+	}()
+
+	select {
+	case clip := <-res:
+		if clip.data == nil {
+			return eventClip{}, fmt.Errorf("read event clip: %v", eventID) //nolint:goerr113
+		}
+		return clip, nil
+	case <-time.After(a.clipTimeout):
+		return eventClip{}, fmt.Errorf("%w: %v", ErrClipTimeout, eventID)
+	}
+}
+
+func (a *alerter) onEventClip(r *monitor.Recorder, event storage.Event, clipID string) {
+	eventID := monitor.EventID(r.Config.ID(), event.Time)
+	a.mu.Lock()
+	res, exist := a.pendingClips[eventID]
+	a.mu.Unlock()
+	if !exist {
+		return
+	}
+
+	clip, err := readClip(r.Env.EventClipsDir(), clipID)
+	if err != nil {
+		r.Logger.Log(log.Entry{
+			Level:     log.LevelError,
+			Src:       "alert",
+			MonitorID: r.Config.ID(),
+			Msg:       fmt.Sprintf("read event clip: %v", err),
+		})
+	}
+	select {
+	case res <- eventClip{id: clipID, data: clip}:
+	default:
+	}
+}
+
+// readClip returns the event clip as MP4.
+func readClip(clipsDir string, clipID string) ([]byte, error) {
+	path, err := monitor.EventClipPath(clipsDir, clipID)
+	if err != nil {
+		return nil, err
+	}
+	video, err := storage.NewVideoReader(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer video.Close()
+	return io.ReadAll(video)
+}
+
 // Config is a monitor alert config.
 type Config struct {
 	Enable    string `json:"enable"`
 	Threshold string `json:"threshold"`
 	Cooldown  string `json:"cooldown"`
+
+	// Wait for the event clip and attach it to the alert.
+	Clip string `json:"clip"`
 }
 
 func (c *Config) fillMissing() {
@@ -129,6 +327,9 @@ func (c *Config) fillMissing() {
 	if c.Cooldown == "" {
 		c.Cooldown = "30"
 	}
+	if c.Clip == "" {
+		c.Clip = "false"
+	}
 }
 
 func bestDetection(e storage.Event) storage.Detection {
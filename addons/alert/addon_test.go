@@ -4,9 +4,11 @@ package alert
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"nvr/pkg/log"
 	"nvr/pkg/monitor"
 	"nvr/pkg/storage"
 
@@ -162,3 +164,68 @@ func TestProcessEvent(t *testing.T) {
 		require.Equal(t, outEvent, event2)
 	})
 }
+
+func TestWaitForClip(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		a := newAlerter(nil)
+		a.clipTimeout = time.Millisecond
+
+		_, err := a.waitForClip("x")
+		require.ErrorIs(t, err, ErrClipTimeout)
+		require.Empty(t, a.pendingClips)
+	})
+	t.Run("readErr", func(t *testing.T) {
+		a := newAlerter(nil)
+		r := &monitor.Recorder{
+			Config: monitor.NewConfig(monitor.RawConfig{"id": "m1"}),
+			Env:    storage.ConfigEnv{StorageDir: t.TempDir()},
+			Logger: log.NewDummyLogger(),
+		}
+		eventTime := time.Now()
+		eventID := monitor.EventID("m1", eventTime)
+
+		done := make(chan error)
+		go func() {
+			_, err := a.waitForClip(eventID)
+			done <- err
+		}()
+		require.Eventually(t, func() bool {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			return a.pendingClips[eventID] != nil
+		}, time.Second, time.Millisecond)
+
+		a.onEventClip(r, storage.Event{Time: eventTime}, eventID)
+		err := <-done
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrClipTimeout)
+	})
+}
+
+func TestOnDiskAlert(t *testing.T) {
+	var sent []Alert
+	send := func(alert Alert, _ []byte) error {
+		sent = append(sent, alert)
+		return nil
+	}
+	a := newAlerter(nil)
+	a.queue = newTestQueue(t, filepath.Join(t.TempDir(), "nvr.db"), send)
+	logf := func(log.Level, string, ...interface{}) {}
+
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.onDiskAlert(storage.DiskAlert{Time: now, Level: storage.DiskAlertHigh}, logf, now)
+	require.Empty(t, sent)
+
+	failover := storage.DiskAlert{Time: now, Level: storage.DiskAlertFailover}
+	a.onDiskAlert(failover, logf, now)
+	require.Equal(t, []Alert{{Time: now, DiskAlert: &failover}}, sent)
+
+	retention := storage.DiskAlert{
+		Time:          now,
+		Level:         storage.DiskAlertRetentionLow,
+		RetentionDays: 3,
+	}
+	a.onDiskAlert(retention, logf, now)
+	require.Len(t, sent, 2)
+	require.Equal(t, Alert{Time: now, DiskAlert: &retention}, sent[1])
+}
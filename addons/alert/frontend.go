@@ -48,6 +48,7 @@ func modifySettingsjs(tpl string) string { //nolint:funlen
 				"30",
 				"30",
 			),
+			clip: fieldTemplate.toggle("Attach clip", "false"),
 		};
 		const form = newForm(fields);
 		const modal = newModal("Alert", form.html());
@@ -0,0 +1,386 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nvr/pkg/kv"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Alert is the part of an alert that is persisted
+// so that it can be delivered after a restart.
+type Alert struct {
+	MonitorID   string            `json:"monitorId,omitempty"`
+	MonitorName string            `json:"monitorName,omitempty"`
+	Time        time.Time         `json:"time"`
+	Detection   storage.Detection `json:"detection"`
+
+	// ID of the attached event clip, the clip is read again on
+	// retries and is left out if it has been pruned by then.
+	ClipID string `json:"clipId,omitempty"`
+
+	// Set if the alert is a storage alert instead of
+	// a monitor event, the other fields are empty.
+	DiskAlert *storage.DiskAlert `json:"diskAlert,omitempty"`
+}
+
+// SendFunc delivers an alert to a notification provider. The
+// bytes are the MP4 clip of the event or nil. Returned errors
+// are retried, see RegisterAlertSender.
+type SendFunc func(alert Alert, clip []byte) error
+
+// RegisterAlertSender registers a notification provider. Failed
+// deliveries are persisted and retried with backoff. Deliveries
+// that keep failing are moved to the dead-letter queue where
+// admins can requeue or drop them.
+func RegisterAlertSender(name string, send SendFunc) {
+	addon.senders[name] = send
+}
+
+// Delivery is an alert that failed to be delivered by a sender.
+type Delivery struct {
+	ID        string    `json:"id"`
+	Sender    string    `json:"sender"`
+	Alert     Alert     `json:"alert"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	NextRetry time.Time `json:"nextRetry"`
+
+	// Dead deliveries are not retried until they are requeued.
+	Dead bool `json:"dead"`
+}
+
+const (
+	maxDeliveryAttempts = 10
+	minRetryDelay       = 30 * time.Second
+	maxRetryDelay       = time.Hour
+	retryCheckInterval  = 10 * time.Second
+)
+
+// retryDelay doubles for each attempt, about 4 hours
+// pass between the first and the last attempt.
+func retryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Delivery queue errors.
+var (
+	ErrDeliveryNotExist = errors.New("delivery does not exist")
+	ErrSenderNotExist   = errors.New("sender is not registered")
+)
+
+type readClipFunc func(clipID string) ([]byte, error)
+
+// Bucket of the failed deliveries, keyed by ID.
+const deliveryBucket = "alert.deliveries"
+
+// deliveryQueue sends alerts and persists the failed deliveries.
+type deliveryQueue struct {
+	db         *kv.DB
+	senders    map[string]SendFunc
+	readClip   readClipFunc
+	deliveries map[string]*Delivery
+	inFlight   map[string]bool
+	logf       log.Func
+
+	nextID uint64
+	wake   chan struct{}
+	mu     sync.Mutex
+}
+
+// newDeliveryQueue loads the persisted deliveries from the database.
+// Deliveries in the legacy JSON file are moved into the database.
+func newDeliveryQueue(
+	db *kv.DB,
+	legacyPath string,
+	senders map[string]SendFunc,
+	readClip readClipFunc,
+	logf log.Func,
+) (*deliveryQueue, error) {
+	q := &deliveryQueue{
+		db:         db,
+		senders:    senders,
+		readClip:   readClip,
+		deliveries: make(map[string]*Delivery),
+		inFlight:   make(map[string]bool),
+		logf:       logf,
+		wake:       make(chan struct{}, 1),
+	}
+
+	err := db.View(func(tx *kv.Tx) error {
+		return tx.Bucket(deliveryBucket).ForEach(func(id string, raw []byte) error {
+			var d Delivery
+			if err := json.Unmarshal(raw, &d); err != nil {
+				return fmt.Errorf("unmarshal delivery %v: %w", id, err)
+			}
+			q.deliveries[id] = &d
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load delivery queue: %w", err)
+	}
+
+	if err := q.migrate(legacyPath); err != nil {
+		return nil, fmt.Errorf("migrate delivery queue: %w", err)
+	}
+	return q, nil
+}
+
+// migrate moves the deliveries from the legacy JSON file into the database.
+func (q *deliveryQueue) migrate(legacyPath string) error {
+	raw, err := os.ReadFile(legacyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var deliveries []*Delivery
+	if err := json.Unmarshal(raw, &deliveries); err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		q.deliveries[d.ID] = d
+	}
+	if err := q.unsafeSave(); err != nil {
+		return err
+	}
+	return os.Remove(legacyPath)
+}
+
+func (q *deliveryQueue) hasSenders() bool {
+	return len(q.senders) != 0
+}
+
+// send delivers the alert to every sender and queues the failed deliveries.
+func (q *deliveryQueue) send(alert Alert, clip []byte, now time.Time) {
+	for name, send := range q.senders {
+		err := send(alert, clip)
+		if err == nil {
+			continue
+		}
+		q.mu.Lock()
+		q.nextID++
+		d := &Delivery{
+			ID:     strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(q.nextID, 10),
+			Sender: name,
+			Alert:  alert,
+		}
+		q.deliveries[d.ID] = d
+		q.unsafeFailed(d, err, now)
+		q.mu.Unlock()
+	}
+}
+
+// unsafeFailed schedules a retry or moves the delivery to the
+// dead-letter queue. The lock must be held.
+func (q *deliveryQueue) unsafeFailed(d *Delivery, err error, now time.Time) {
+	d.Attempts++
+	d.LastError = err.Error()
+	if d.Attempts >= maxDeliveryAttempts {
+		d.Dead = true
+		d.NextRetry = time.Time{}
+		q.logf(log.LevelError, "%v: delivery %v failed %v times, moved to dead-letter queue: %v",
+			d.Sender, d.ID, d.Attempts, err)
+	} else {
+		d.NextRetry = now.Add(retryDelay(d.Attempts))
+		q.logf(log.LevelWarning, "%v: delivery %v failed, retrying at %v: %v",
+			d.Sender, d.ID, d.NextRetry.Format(time.RFC3339), err)
+	}
+	if err := q.unsafeSave(); err != nil {
+		q.logf(log.LevelError, "save delivery queue: %v", err)
+	}
+}
+
+func (q *deliveryQueue) unsafeSave() error {
+	return q.db.Update(func(tx *kv.Tx) error {
+		if err := tx.DeleteBucket(deliveryBucket); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(deliveryBucket)
+		for id, d := range q.deliveries {
+			if err := bucket.PutJSON(id, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// retryDue retries the deliveries that are due.
+func (q *deliveryQueue) retryDue(now time.Time) {
+	q.mu.Lock()
+	var due []Delivery
+	for _, d := range q.deliveries {
+		if !d.Dead && !q.inFlight[d.ID] && !now.Before(d.NextRetry) {
+			q.inFlight[d.ID] = true
+			due = append(due, *d)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, d := range due {
+		err := q.retry(d)
+
+		q.mu.Lock()
+		delete(q.inFlight, d.ID)
+		current, exist := q.deliveries[d.ID]
+		switch {
+		case !exist:
+			// Dropped while sending.
+		case err == nil:
+			delete(q.deliveries, d.ID)
+			q.logf(log.LevelInfo, "%v: delivery %v succeeded after %v attempts",
+				d.Sender, d.ID, d.Attempts+1)
+			if err := q.unsafeSave(); err != nil {
+				q.logf(log.LevelError, "save delivery queue: %v", err)
+			}
+		default:
+			q.unsafeFailed(current, err, now)
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *deliveryQueue) retry(d Delivery) error {
+	send, exist := q.senders[d.Sender]
+	if !exist {
+		return fmt.Errorf("%w: %v", ErrSenderNotExist, d.Sender)
+	}
+	var clip []byte
+	if d.Alert.ClipID != "" {
+		clip, _ = q.readClip(d.Alert.ClipID)
+	}
+	return send(d.Alert, clip)
+}
+
+// run retries the due deliveries until the context is canceled.
+func (q *deliveryQueue) run(ctx context.Context) {
+	ticker := time.NewTicker(retryCheckInterval)
+	defer ticker.Stop()
+	for {
+		q.retryDue(time.Now())
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// List returns the queued and dead deliveries ordered by alert time.
+func (q *deliveryQueue) List() []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.unsafeList()
+}
+
+func (q *deliveryQueue) unsafeList() []Delivery {
+	list := make([]Delivery, 0, len(q.deliveries))
+	for _, d := range q.deliveries {
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Alert.Time.Equal(list[j].Alert.Time) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].Alert.Time.Before(list[j].Alert.Time)
+	})
+	return list
+}
+
+// Requeue resets the attempts of the delivery and retries it immediately.
+func (q *deliveryQueue) Requeue(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	d, exist := q.deliveries[id]
+	if !exist {
+		return fmt.Errorf("%w: %v", ErrDeliveryNotExist, id)
+	}
+	d.Attempts = 0
+	d.Dead = false
+	d.NextRetry = time.Time{}
+	if err := q.unsafeSave(); err != nil {
+		return err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Drop deletes the delivery without sending it.
+func (q *deliveryQueue) Drop(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exist := q.deliveries[id]; !exist {
+		return fmt.Errorf("%w: %v", ErrDeliveryNotExist, id)
+	}
+	delete(q.deliveries, id)
+	return q.unsafeSave()
+}
+
+func handleQueue(q *deliveryQueue) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(q.List()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func handleQueueAction(method string, action func(id string) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		err := action(id)
+		switch {
+		case errors.Is(err, ErrDeliveryNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/kv"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, 30*time.Second, retryDelay(1))
+	require.Equal(t, time.Minute, retryDelay(2))
+	require.Equal(t, 32*time.Minute, retryDelay(7))
+	require.Equal(t, time.Hour, retryDelay(8))
+	require.Equal(t, time.Hour, retryDelay(100))
+}
+
+var errSend = errors.New("send")
+
+// newTestQueue opens the database at path, the legacy
+// queue file is "alert-queue.json" in the same directory.
+func newTestQueue(t *testing.T, path string, send SendFunc) *deliveryQueue {
+	t.Helper()
+	db, err := kv.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	readClip := func(string) ([]byte, error) { return []byte("clip"), nil }
+	logf := func(log.Level, string, ...interface{}) {}
+	legacyPath := filepath.Join(filepath.Dir(path), "alert-queue.json")
+	q, err := newDeliveryQueue(db, legacyPath, map[string]SendFunc{"a": send}, readClip, logf)
+	require.NoError(t, err)
+	return q
+}
+
+func TestDeliveryQueue(t *testing.T) {
+	alert := Alert{
+		MonitorID: "m1",
+		Time:      time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Detection: storage.Detection{Label: "person", Score: 90},
+		ClipID:    "x",
+	}
+	now := time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC)
+
+	t.Run("retry", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nvr.db")
+		var sendErr error = errSend
+		var clips []string
+		send := func(_ Alert, clip []byte) error {
+			clips = append(clips, string(clip))
+			return sendErr
+		}
+		q := newTestQueue(t, path, send)
+
+		q.send(alert, nil, now)
+		list := q.List()
+		require.Len(t, list, 1)
+		require.Equal(t, 1, list[0].Attempts)
+		require.Equal(t, "send", list[0].LastError)
+		require.Equal(t, now.Add(30*time.Second), list[0].NextRetry)
+
+		// Not due yet.
+		q.retryDue(now)
+		require.Len(t, clips, 1)
+
+		// Persisted.
+		require.NoError(t, q.db.Close())
+		q = newTestQueue(t, path, send)
+		require.Equal(t, list, q.List())
+
+		// The clip is read from disk on retries.
+		sendErr = nil
+		q.retryDue(now.Add(30 * time.Second))
+		require.Equal(t, []string{"", "clip"}, clips)
+		require.Empty(t, q.List())
+
+		require.NoError(t, q.db.Close())
+		q = newTestQueue(t, path, send)
+		require.Empty(t, q.List())
+	})
+	t.Run("deadLetter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nvr.db")
+		attempts := 0
+		send := func(Alert, []byte) error {
+			attempts++
+			return errSend
+		}
+		q := newTestQueue(t, path, send)
+
+		q.send(alert, nil, now)
+		for i := 0; i < 20; i++ {
+			q.retryDue(now.Add(time.Duration(i) * 2 * time.Hour))
+		}
+		require.Equal(t, maxDeliveryAttempts, attempts)
+		list := q.List()
+		require.Len(t, list, 1)
+		require.True(t, list[0].Dead)
+
+		// Requeue.
+		require.NoError(t, q.Requeue(list[0].ID))
+		q.retryDue(now)
+		require.Equal(t, maxDeliveryAttempts+1, attempts)
+		require.False(t, q.List()[0].Dead)
+
+		// Drop.
+		require.NoError(t, q.Drop(list[0].ID))
+		require.Empty(t, q.List())
+		require.ErrorIs(t, q.Drop(list[0].ID), ErrDeliveryNotExist)
+		require.ErrorIs(t, q.Requeue(list[0].ID), ErrDeliveryNotExist)
+	})
+	t.Run("migrate", func(t *testing.T) {
+		dir := t.TempDir()
+		legacyPath := filepath.Join(dir, "alert-queue.json")
+		legacy := `[{"id":"x","sender":"a","attempts":2,"lastError":"send"}]`
+		require.NoError(t, os.WriteFile(legacyPath, []byte(legacy), 0o600))
+
+		path := filepath.Join(dir, "nvr.db")
+		q := newTestQueue(t, path, nil)
+		expected := []Delivery{{ID: "x", Sender: "a", Attempts: 2, LastError: "send"}}
+		require.Equal(t, expected, q.List())
+		require.NoFileExists(t, legacyPath)
+
+		require.NoError(t, q.db.Close())
+		q = newTestQueue(t, path, nil)
+		require.Equal(t, expected, q.List())
+	})
+	t.Run("unknownSender", func(t *testing.T) {
+		q := newTestQueue(t, filepath.Join(t.TempDir(), "nvr.db"), nil)
+		q.deliveries["x"] = &Delivery{ID: "x", Sender: "b", Alert: alert}
+
+		q.retryDue(now)
+		d := q.List()[0]
+		require.Equal(t, 1, d.Attempts)
+		require.Contains(t, d.LastError, ErrSenderNotExist.Error())
+	})
+}
+
+func TestHandleQueueAction(t *testing.T) {
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "nvr.db"), nil)
+	q.deliveries["x"] = &Delivery{ID: "x", Sender: "a"}
+	h := handleQueueAction(http.MethodDelete, q.Drop)
+
+	cases := []struct {
+		method string
+		target string
+		code   int
+	}{
+		{http.MethodPost, "/api/alert/queue/drop?id=x", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/alert/queue/drop", http.StatusBadRequest},
+		{http.MethodDelete, "/api/alert/queue/drop?id=x", http.StatusOK},
+		{http.MethodDelete, "/api/alert/queue/drop?id=x", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+		require.Equal(t, tc.code, w.Code, tc.target)
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// ErrWebhookStatus the webhook responded with a non 2xx status.
+var ErrWebhookStatus = errors.New("webhook responded with error status")
+
+// newWebhookSender returns a sender that posts the alert as JSON to the
+// URL. The clip isn't included, it can be downloaded by the clip ID.
+func newWebhookSender(url string, client *http.Client) SendFunc {
+	return func(alert Alert, _ []byte) error {
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(res.Body, 4096)) //nolint:errcheck
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("%w: %v", ErrWebhookStatus, res.Status)
+		}
+		return nil
+	}
+}
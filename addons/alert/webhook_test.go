@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSender(t *testing.T) {
+	var got Alert
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	send := newWebhookSender(server.URL, server.Client())
+	alert := Alert{
+		MonitorID: "m1",
+		Time:      time.Unix(1, 0).UTC(),
+		Detection: storage.Detection{Label: "person", Score: 90},
+	}
+	require.NoError(t, send(alert, []byte("clip")))
+	require.Equal(t, alert, got)
+
+	status = http.StatusBadGateway
+	require.ErrorIs(t, send(alert, nil), ErrWebhookStatus)
+
+	server.Close()
+	require.Error(t, send(alert, nil))
+}
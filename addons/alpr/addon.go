@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"context"
+	"fmt"
+	"nvr"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	nvr.RegisterMonitorEventSnapshotHook(onEventSnapshot)
+	nvr.RegisterLogSource([]string{"alpr"})
+	nvr.RegisterTplHook(modifyTemplates)
+}
+
+// Detections of plates have this label, the plate number is the text.
+const plateLabel = "plate"
+
+// Maximum time to wait for the backend.
+const recognizeTimeout = 10 * time.Second
+
+func onEventSnapshot(r *monitor.Recorder, event storage.Event, snapshotID string) {
+	id := r.Config.ID()
+	logf := func(level log.Level, format string, a ...interface{}) {
+		r.Logger.Log(log.Entry{
+			Level:     level,
+			Src:       "alpr",
+			MonitorID: id,
+			Msg:       fmt.Sprintf(format, a...),
+		})
+	}
+
+	config, enable, err := parseConfig(r.Config)
+	if err != nil {
+		logf(log.LevelError, "could not parse config: %v", err)
+		return
+	}
+	if !enable {
+		return
+	}
+
+	backend, err := newBackend(config.backend, r.Config)
+	if err != nil {
+		logf(log.LevelError, "could not create backend: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recognizeTimeout)
+	defer cancel()
+
+	p := &pipeline{
+		backend:      backend,
+		config:       *config,
+		snapshotsDir: r.Env.EventSnapshotsDir(),
+		logf:         logf,
+	}
+	if err := p.process(ctx, event, snapshotID); err != nil {
+		logf(log.LevelError, "%v", err)
+	}
+}
+
+// pipeline sends the snapshot of a event to the backend
+// and adds the recognized plates to the saved event.
+type pipeline struct {
+	backend      Backend
+	config       config
+	snapshotsDir string
+	logf         log.Func
+}
+
+func (p *pipeline) process(ctx context.Context, event storage.Event, snapshotID string) error {
+	if !p.config.acceptLabels(eventLabels(event)) {
+		return nil
+	}
+
+	path, err := monitor.EventSnapshotPath(p.snapshotsDir, snapshotID)
+	if err != nil {
+		return err
+	}
+	img, err := os.ReadFile(path + ".jpeg")
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	plates, err := p.backend.Recognize(ctx, img)
+	if err != nil {
+		return fmt.Errorf("recognize: %w", err)
+	}
+
+	detections := filterPlates(plates, p.config.minConfidence)
+	if len(detections) == 0 {
+		return nil
+	}
+	for _, d := range detections {
+		p.logf(log.LevelInfo, "plate: %v score:%.1f", d.Text, d.Score)
+	}
+
+	err = monitor.AddEventSnapshotDetections(p.snapshotsDir, snapshotID, detections)
+	if err != nil {
+		return fmt.Errorf("save plates: %w", err)
+	}
+	return nil
+}
+
+func eventLabels(event storage.Event) []string {
+	labels := make([]string, 0, len(event.Detections))
+	for _, d := range event.Detections {
+		labels = append(labels, d.Label)
+	}
+	return labels
+}
+
+// filterPlates returns the plates above the minimum
+// confidence as detections with the region in percent.
+func filterPlates(plates []Plate, minConfidence float64) []storage.Detection {
+	var detections []storage.Detection
+	for _, p := range plates {
+		text := strings.TrimSpace(p.Text)
+		if text == "" || p.Score < minConfidence {
+			continue
+		}
+		detections = append(detections, storage.Detection{
+			Label: plateLabel,
+			Score: p.Score,
+			Text:  text,
+			Region: &storage.Region{
+				Rect: &ffmpeg.Rect{
+					int(p.Top * 100),
+					int(p.Left * 100),
+					int(p.Bottom * 100),
+					int(p.Right * 100),
+				},
+			},
+		})
+	}
+	return detections
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubBackend struct {
+	plates []Plate
+	err    error
+	img    []byte
+}
+
+func (b *stubBackend) Recognize(_ context.Context, img []byte) ([]Plate, error) {
+	b.img = img
+	return b.plates, b.err
+}
+
+func TestPipeline(t *testing.T) {
+	newTestPipeline := func(t *testing.T, b Backend, labels []string) (*pipeline, string) {
+		t.Helper()
+		dir := t.TempDir()
+		event := storage.Event{
+			Time:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local),
+			Detections: []storage.Detection{{Label: "car", Score: 90}},
+		}
+		id := monitor.EventID("m1", event.Time)
+		rawEvent, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), rawEvent, 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id+".jpeg"), []byte("jpeg"), 0o600))
+
+		return &pipeline{
+			backend:      b,
+			config:       config{labels: labels, minConfidence: 70},
+			snapshotsDir: dir,
+			logf:         func(log.Level, string, ...interface{}) {},
+		}, id
+	}
+	event := storage.Event{Detections: []storage.Detection{{Label: "car"}}}
+
+	t.Run("ok", func(t *testing.T) {
+		b := &stubBackend{plates: []Plate{
+			{Text: "ABC123", Score: 90, Top: 0.1, Left: 0.2, Bottom: 0.3, Right: 0.4},
+			{Text: "XYZ", Score: 50},
+		}}
+		p, id := newTestPipeline(t, b, []string{"car"})
+		require.NoError(t, p.process(context.Background(), event, id))
+		require.Equal(t, []byte("jpeg"), b.img)
+
+		snapshots, err := monitor.QueryEventSnapshots(p.snapshotsDir, monitor.EventSnapshotQuery{
+			Limit: 1, Plate: "abc-123",
+		})
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		expected := []storage.Detection{
+			{Label: "car", Score: 90},
+			{
+				Label: "plate", Score: 90, Text: "ABC123",
+				Region: &storage.Region{Rect: &ffmpeg.Rect{10, 20, 30, 40}},
+			},
+		}
+		require.Equal(t, expected, snapshots[0].Detections)
+	})
+	t.Run("label", func(t *testing.T) {
+		b := &stubBackend{}
+		p, id := newTestPipeline(t, b, []string{"truck"})
+		require.NoError(t, p.process(context.Background(), event, id))
+		require.Nil(t, b.img)
+	})
+	t.Run("backendErr", func(t *testing.T) {
+		errMock := errors.New("mock")
+		p, id := newTestPipeline(t, &stubBackend{err: errMock}, nil)
+		require.ErrorIs(t, p.process(context.Background(), event, id), errMock)
+	})
+	t.Run("missingSnapshot", func(t *testing.T) {
+		p, _ := newTestPipeline(t, &stubBackend{}, nil)
+		id := monitor.EventID("m2", time.Now())
+		require.ErrorIs(t, p.process(context.Background(), event, id), os.ErrNotExist)
+	})
+}
+
+func TestFilterPlates(t *testing.T) {
+	plates := []Plate{
+		{Text: " AB1 ", Score: 70},
+		{Text: "AB2", Score: 69.9},
+		{Text: "", Score: 100},
+	}
+	detections := filterPlates(plates, 70)
+	require.Len(t, detections, 1)
+	require.Equal(t, "AB1", detections[0].Text)
+	require.Equal(t, plateLabel, detections[0].Label)
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/monitor"
+	"sort"
+	"sync"
+)
+
+// Backend recognizes license plates in images.
+type Backend interface {
+	// Recognize returns the plates found in the JPEG image.
+	Recognize(ctx context.Context, img []byte) ([]Plate, error)
+}
+
+// Plate recognized in a image. The coordinates are
+// fractions of the image size, between 0 and 1.
+type Plate struct {
+	Text   string
+	Score  float64 // Confidence in percent.
+	Top    float64
+	Left   float64
+	Bottom float64
+	Right  float64
+}
+
+// NewBackendFunc creates a backend from the monitor config.
+type NewBackendFunc func(monitor.Config) (Backend, error)
+
+var (
+	backends   = make(map[string]NewBackendFunc)
+	backendsMu sync.Mutex
+)
+
+// RegisterBackend makes a recognition backend available by
+// name. Addons can provide other ALPR services by calling
+// this from their init function.
+func RegisterBackend(name string, newBackend NewBackendFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, exists := backends[name]; exists {
+		panic("alpr: backend registered twice: " + name)
+	}
+	backends[name] = newBackend
+}
+
+// ErrUnknownBackend unknown backend.
+var ErrUnknownBackend = errors.New("unknown backend")
+
+func newBackend(name string, c monitor.Config) (Backend, error) {
+	backendsMu.Lock()
+	newBackend, exists := backends[name]
+	backendsMu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, name)
+	}
+	return newBackend(c)
+}
+
+func backendNames() []string {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"nvr/pkg/monitor"
+	"strings"
+)
+
+func init() {
+	RegisterBackend("codeproject", newCodeProject)
+}
+
+// codeProject is the license plate module of CodeProject.AI Server.
+//
+// POST /v1/vision/alpr with a multipart "upload" field.
+type codeProject struct {
+	client *http.Client
+	url    string
+}
+
+// ErrURLMissing ALPR URL missing.
+var ErrURLMissing = errors.New("alpr url missing")
+
+func newCodeProject(c monitor.Config) (Backend, error) {
+	base, err := parseURL(c.Get("alprUrl"))
+	if err != nil {
+		return nil, err
+	}
+	return &codeProject{
+		client: &http.Client{},
+		url:    base + "/v1/vision/alpr",
+	}, nil
+}
+
+// parseURL returns the base URL without a trailing slash.
+func parseURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", ErrURLMissing
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+type codeProjectResponse struct {
+	Success     bool   `json:"success"`
+	Error       string `json:"error"`
+	Predictions []struct {
+		Plate      string  `json:"plate"`
+		Confidence float64 `json:"confidence"`
+		XMin       int     `json:"x_min"`
+		YMin       int     `json:"y_min"`
+		XMax       int     `json:"x_max"`
+		YMax       int     `json:"y_max"`
+	} `json:"predictions"`
+}
+
+// ErrRecognitionFailed server returned an error.
+var ErrRecognitionFailed = errors.New("recognition failed")
+
+func (b *codeProject) Recognize(ctx context.Context, img []byte) ([]Plate, error) {
+	imgConfig, err := jpeg.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("upload", "snapshot.jpeg")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(img); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %v", ErrRecognitionFailed, res.Status)
+	}
+
+	var response codeProjectResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %v", ErrRecognitionFailed, response.Error)
+	}
+
+	width := float64(imgConfig.Width)
+	height := float64(imgConfig.Height)
+
+	plates := make([]Plate, 0, len(response.Predictions))
+	for _, p := range response.Predictions {
+		plates = append(plates, Plate{
+			Text:   p.Plate,
+			Score:  p.Confidence * 100,
+			Top:    float64(p.YMin) / height,
+			Left:   float64(p.XMin) / width,
+			Bottom: float64(p.YMax) / height,
+			Right:  float64(p.XMax) / width,
+		})
+	}
+	return plates, nil
+}
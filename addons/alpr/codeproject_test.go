@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nvr/pkg/monitor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testJPEG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	err := jpeg.Encode(&b, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+	require.NoError(t, err)
+	return b.Bytes()
+}
+
+func TestCodeProject(t *testing.T) {
+	newTestBackend := func(t *testing.T, handler http.HandlerFunc) Backend {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		b, err := newCodeProject(monitor.NewConfig(monitor.RawConfig{
+			"alprUrl": server.URL + "/",
+		}))
+		require.NoError(t, err)
+		return b
+	}
+	img := testJPEG(t, 200, 100)
+
+	t.Run("ok", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/vision/alpr", r.URL.Path)
+
+			file, _, err := r.FormFile("upload")
+			require.NoError(t, err)
+			upload, err := io.ReadAll(file)
+			require.NoError(t, err)
+			require.Equal(t, img, upload)
+
+			w.Write([]byte(`{"success":true,"predictions":[{"label":"Plate: ABC123",` +
+				`"plate":"ABC123","confidence":0.9,"x_min":20,"y_min":10,"x_max":100,"y_max":50}]}`))
+		})
+
+		plates, err := b.Recognize(context.Background(), img)
+		require.NoError(t, err)
+
+		expected := []Plate{{
+			Text:   "ABC123",
+			Score:  90,
+			Top:    0.1,
+			Left:   0.1,
+			Bottom: 0.5,
+			Right:  0.5,
+		}}
+		require.Equal(t, expected, plates)
+	})
+	t.Run("unsuccessful", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"success":false,"error":"x"}`))
+		})
+		_, err := b.Recognize(context.Background(), img)
+		require.ErrorIs(t, err, ErrRecognitionFailed)
+	})
+	t.Run("status", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		_, err := b.Recognize(context.Background(), img)
+		require.ErrorIs(t, err, ErrRecognitionFailed)
+	})
+	t.Run("invalidImage", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+		_, err := b.Recognize(context.Background(), []byte("x"))
+		require.Error(t, err)
+	})
+	t.Run("urlMissing", func(t *testing.T) {
+		_, err := newCodeProject(monitor.NewConfig(monitor.RawConfig{}))
+		require.ErrorIs(t, err, ErrURLMissing)
+	})
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"errors"
+	"fmt"
+	"nvr/pkg/monitor"
+	"strconv"
+	"strings"
+)
+
+// Plates below this confidence in percent are ignored by default.
+const defaultMinConfidence = 70
+
+type config struct {
+	backend string
+
+	// Only events with one of these labels are sent
+	// to the backend, all events are sent if empty.
+	labels []string
+
+	minConfidence float64
+}
+
+// ErrInvalidConfidence invalid minimum confidence.
+var ErrInvalidConfidence = errors.New("invalid minimum confidence")
+
+func parseConfig(c monitor.Config) (*config, bool, error) {
+	if c.Get("alprEnable") != "true" {
+		return nil, false, nil
+	}
+
+	backend := c.Get("alprBackend")
+	if backend == "" {
+		backend = "codeproject"
+	}
+
+	var labels []string
+	for _, label := range strings.Split(c.Get("alprLabels"), ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+
+	minConfidence := float64(defaultMinConfidence)
+	if raw := strings.TrimSpace(c.Get("alprMinConfidence")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || v > 100 {
+			return nil, false, fmt.Errorf("%w: %q", ErrInvalidConfidence, raw)
+		}
+		minConfidence = v
+	}
+
+	return &config{
+		backend:       backend,
+		labels:        labels,
+		minConfidence: minConfidence,
+	}, true, nil
+}
+
+// acceptLabels returns true if the event has a
+// detection with one of the configured labels.
+func (c config) acceptLabels(labels []string) bool {
+	if len(c.labels) == 0 {
+		return true
+	}
+	for _, label := range labels {
+		for _, l := range c.labels {
+			if label == l {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"testing"
+
+	"nvr/pkg/monitor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		_, enable, err := parseConfig(monitor.NewConfig(monitor.RawConfig{}))
+		require.NoError(t, err)
+		require.False(t, enable)
+	})
+	t.Run("defaults", func(t *testing.T) {
+		c, enable, err := parseConfig(monitor.NewConfig(monitor.RawConfig{
+			"alprEnable": "true",
+		}))
+		require.NoError(t, err)
+		require.True(t, enable)
+		require.Equal(t, config{backend: "codeproject", minConfidence: 70}, *c)
+	})
+	t.Run("maximal", func(t *testing.T) {
+		c, _, err := parseConfig(monitor.NewConfig(monitor.RawConfig{
+			"alprEnable":        "true",
+			"alprBackend":       "openalpr",
+			"alprLabels":        " car, truck,",
+			"alprMinConfidence": "85.5",
+		}))
+		require.NoError(t, err)
+		expected := config{
+			backend:       "openalpr",
+			labels:        []string{"car", "truck"},
+			minConfidence: 85.5,
+		}
+		require.Equal(t, expected, *c)
+		require.True(t, c.acceptLabels([]string{"person", "truck"}))
+		require.False(t, c.acceptLabels([]string{"person"}))
+	})
+	t.Run("confidenceErr", func(t *testing.T) {
+		_, _, err := parseConfig(monitor.NewConfig(monitor.RawConfig{
+			"alprEnable":        "true",
+			"alprMinConfidence": "101",
+		}))
+		require.ErrorIs(t, err, ErrInvalidConfidence)
+	})
+}
+
+func TestModifySettingsjs(t *testing.T) {
+	tpl := `a: 1,
+		logLevel: fieldTemplate.select(`
+	actual, err := modifySettingsjs(tpl, []string{"codeproject", "openalpr"})
+	require.NoError(t, err)
+	require.Contains(t, actual, `alprBackend: fieldTemplate.select("ALPR backend", ["codeproject","openalpr"], "codeproject"),`)
+	require.Contains(t, actual, "logLevel: fieldTemplate.select(")
+}
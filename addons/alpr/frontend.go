@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func modifyTemplates(pageFiles map[string]string) error {
+	js, exists := pageFiles["settings.js"]
+	if !exists {
+		return fmt.Errorf("alpr: settings.js %w", os.ErrNotExist)
+	}
+
+	tpl, err := modifySettingsjs(js, backendNames())
+	if err != nil {
+		return fmt.Errorf("alpr: %w", err)
+	}
+	pageFiles["settings.js"] = tpl
+	return nil
+}
+
+func modifySettingsjs(tpl string, backends []string) (string, error) {
+	rawBackends, err := json.Marshal(backends)
+	if err != nil {
+		return "", err
+	}
+
+	fields := `alprEnable: fieldTemplate.toggle("License plate recognition", "false"),
+		alprBackend: fieldTemplate.select("ALPR backend", ` + string(rawBackends) + `, "codeproject"),
+		alprUrl: fieldTemplate.text("ALPR server URL", "http://127.0.0.1:32168"),
+		alprSecretKey: fieldTemplate.text("ALPR secret key", ""),
+		alprCountry: fieldTemplate.text("ALPR country", "us", "us"),
+		alprLabels: fieldTemplate.text("ALPR labels", "car,truck", ""),
+		alprMinConfidence: fieldTemplate.text("ALPR minimum confidence", "70", "70"),
+		`
+
+	const target = "logLevel: fieldTemplate.select("
+	return strings.ReplaceAll(tpl, target, fields+target), nil
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nvr/pkg/monitor"
+	"strings"
+)
+
+func init() {
+	RegisterBackend("openalpr", newOpenALPR)
+}
+
+// openALPR is the OpenALPR cloud API, or a server that implements it.
+//
+// POST /v3/recognize_bytes with the base64 encoded image as body.
+type openALPR struct {
+	client *http.Client
+	url    string
+}
+
+// ErrSecretKeyMissing OpenALPR secret key missing.
+var ErrSecretKeyMissing = errors.New("openalpr secret key missing")
+
+func newOpenALPR(c monitor.Config) (Backend, error) {
+	rawURL := c.Get("alprUrl")
+	if strings.TrimSpace(rawURL) == "" {
+		rawURL = "https://api.openalpr.com"
+	}
+	base, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	secretKey := strings.TrimSpace(c.Get("alprSecretKey"))
+	if secretKey == "" {
+		return nil, ErrSecretKeyMissing
+	}
+	country := strings.TrimSpace(c.Get("alprCountry"))
+	if country == "" {
+		country = "us"
+	}
+
+	query := url.Values{}
+	query.Set("secret_key", secretKey)
+	query.Set("country", country)
+	return &openALPR{
+		client: &http.Client{},
+		url:    base + "/v3/recognize_bytes?" + query.Encode(),
+	}, nil
+}
+
+type openALPRResponse struct {
+	ImgWidth  int    `json:"img_width"`
+	ImgHeight int    `json:"img_height"`
+	Error     string `json:"error"`
+	Results   []struct {
+		Plate       string  `json:"plate"`
+		Confidence  float64 `json:"confidence"`
+		Coordinates []struct {
+			X int `json:"x"`
+			Y int `json:"y"`
+		} `json:"coordinates"`
+	} `json:"results"`
+}
+
+func (b *openALPR) Recognize(ctx context.Context, img []byte) ([]Plate, error) {
+	body := base64.StdEncoding.EncodeToString(img)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		// The error contains the URL with the secret key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Errors are also JSON with a error message.
+	var response openALPRResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%w: %v", ErrRecognitionFailed, res.Status)
+		}
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK || response.Error != "" {
+		return nil, fmt.Errorf("%w: %v %v", ErrRecognitionFailed, res.Status, response.Error)
+	}
+	if response.ImgWidth == 0 || response.ImgHeight == 0 {
+		return nil, fmt.Errorf("%w: image size missing", ErrRecognitionFailed)
+	}
+
+	width := float64(response.ImgWidth)
+	height := float64(response.ImgHeight)
+
+	plates := make([]Plate, 0, len(response.Results))
+	for _, r := range response.Results {
+		p := Plate{Text: r.Plate, Score: r.Confidence}
+		// The coordinates are the four corners of the plate.
+		if len(r.Coordinates) != 0 {
+			top, left := r.Coordinates[0].Y, r.Coordinates[0].X
+			bottom, right := top, left
+			for _, c := range r.Coordinates[1:] {
+				top, bottom = min(top, c.Y), max(bottom, c.Y)
+				left, right = min(left, c.X), max(right, c.X)
+			}
+			p.Top = float64(top) / height
+			p.Left = float64(left) / width
+			p.Bottom = float64(bottom) / height
+			p.Right = float64(right) / width
+		}
+		plates = append(plates, p)
+	}
+	return plates, nil
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package alpr
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nvr/pkg/monitor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenALPR(t *testing.T) {
+	newTestBackend := func(t *testing.T, handler http.HandlerFunc) Backend {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		b, err := newOpenALPR(monitor.NewConfig(monitor.RawConfig{
+			"alprUrl":       server.URL,
+			"alprSecretKey": "key",
+		}))
+		require.NoError(t, err)
+		return b
+	}
+	img := []byte("jpeg")
+
+	t.Run("ok", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v3/recognize_bytes", r.URL.Path)
+			require.Equal(t, "key", r.URL.Query().Get("secret_key"))
+			require.Equal(t, "us", r.URL.Query().Get("country"))
+
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, base64.StdEncoding.EncodeToString(img), string(body))
+
+			w.Write([]byte(`{"img_width":200,"img_height":100,"results":[{"plate":"ABC123",` +
+				`"confidence":90.5,"coordinates":[{"x":20,"y":12},{"x":100,"y":10},` +
+				`{"x":98,"y":50},{"x":22,"y":48}]}]}`))
+		})
+
+		plates, err := b.Recognize(context.Background(), img)
+		require.NoError(t, err)
+
+		expected := []Plate{{
+			Text:   "ABC123",
+			Score:  90.5,
+			Top:    0.1,
+			Left:   0.1,
+			Bottom: 0.5,
+			Right:  0.5,
+		}}
+		require.Equal(t, expected, plates)
+	})
+	t.Run("error", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid secret key"}`))
+		})
+		_, err := b.Recognize(context.Background(), img)
+		require.ErrorIs(t, err, ErrRecognitionFailed)
+		require.Contains(t, err.Error(), "invalid secret key")
+	})
+	t.Run("status", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+		_, err := b.Recognize(context.Background(), img)
+		require.ErrorIs(t, err, ErrRecognitionFailed)
+	})
+	t.Run("redactKey", func(t *testing.T) {
+		b, err := newOpenALPR(monitor.NewConfig(monitor.RawConfig{
+			"alprUrl":       "http://127.0.0.1:0",
+			"alprSecretKey": "secret",
+		}))
+		require.NoError(t, err)
+		_, err = b.Recognize(context.Background(), img)
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), "secret")
+	})
+	t.Run("secretKeyMissing", func(t *testing.T) {
+		_, err := newOpenALPR(monitor.NewConfig(monitor.RawConfig{}))
+		require.ErrorIs(t, err, ErrSecretKeyMissing)
+	})
+}
@@ -17,6 +17,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -31,7 +32,13 @@ type Authenticator struct {
 	accounts  map[string]auth.Account
 	authCache map[string]auth.ValidateResponse
 
-	hashCost int
+	// Active impersonations by admin ID.
+	impersonations map[string]impersonation
+
+	hashCost       int
+	limiter        *auth.Limiter
+	passwordPolicy storage.PasswordPolicy
+	proxyAuth      storage.ProxyAuth
 
 	logger *log.Logger
 
@@ -49,8 +56,13 @@ func NewBasicAuthenticator(env storage.ConfigEnv, logger *log.Logger) (auth.Auth
 		accounts:  make(map[string]auth.Account),
 		authCache: make(map[string]auth.ValidateResponse),
 
-		hashCost: auth.DefaultBcryptHashCost,
-		logger:   logger,
+		impersonations: make(map[string]impersonation),
+
+		hashCost:       auth.DefaultBcryptHashCost,
+		limiter:        auth.NewLimiter(env.AuthRateLimit, logger),
+		passwordPolicy: env.PasswordPolicy,
+		proxyAuth:      env.ProxyAuth,
+		logger:         logger,
 	}
 
 	file, err := os.ReadFile(path)
@@ -73,9 +85,24 @@ func NewBasicAuthenticator(env storage.ConfigEnv, logger *log.Logger) (auth.Auth
 	return &a, nil
 }
 
-// ValidateRequest Should always take the same amount of
-// time to run, even when username or password is invalid.
+// ValidateRequest validates the request and applies impersonation.
 func (a *Authenticator) ValidateRequest(r *http.Request) auth.ValidateResponse {
+	res := a.validateRequest(r)
+	if !res.IsValid {
+		return res
+	}
+	return a.impersonate(res)
+}
+
+// validateRequest Should always take the same amount of
+// time to run, even when username or password is invalid.
+func (a *Authenticator) validateRequest(r *http.Request) auth.ValidateResponse {
+	// Requests without proxy headers, for example direct
+	// requests from the local network, fall back to basic auth.
+	if name, groups, ok := auth.ProxyUser(a.proxyAuth, r); ok {
+		return a.validateProxyUser(name, groups)
+	}
+
 	req := r.Header.Get("Authorization")
 
 	a.mu.Lock()
@@ -91,21 +118,105 @@ func (a *Authenticator) ValidateRequest(r *http.Request) auth.ValidateResponse {
 	user, found := a.userByNameUnsafe(name)
 	a.mu.Unlock()
 
+	ip := a.limiter.IP(r)
+	lockedUntil := a.limiter.IPLockedUntil(ip, time.Now())
+	if !lockedUntil.IsZero() {
+		return auth.ValidateResponse{LockedUntil: lockedUntil}
+	}
+
+	// Requests without credentials are not failed attempts.
+	if name != "" {
+		time.Sleep(a.limiter.Delay(ip, time.Now()))
+	}
+	onFail := func() auth.ValidateResponse {
+		if name == "" {
+			return auth.ValidateResponse{}
+		}
+		a.limiter.Fail(ip, name, time.Now())
+		// The correct password is accepted while the account is locked.
+		return auth.ValidateResponse{
+			LockedUntil: a.limiter.AccountLockedUntil(name, time.Now()),
+		}
+	}
+
 	a.hashLock.Lock()
 	defer a.hashLock.Unlock()
 	if !found || name != user.Username {
 		// Generate fake hash to prevent timing based attacks.
 		bcrypt.GenerateFromPassword([]byte(name), a.hashCost) //nolint:errcheck
-		return auth.ValidateResponse{}
+		return onFail()
 	}
 	if passwordsMatch(user.Password, pass) {
+		a.limiter.Success(name)
 		a.mu.Lock()
 		res := auth.ValidateResponse{IsValid: true, User: user}
 		a.authCache[req] = res // Only cache valid requests.
 		a.mu.Unlock()
 		return res
 	}
-	return auth.ValidateResponse{}
+	return onFail()
+}
+
+// validateProxyUser validates a user that was authenticated by the proxy.
+// Unknown users are created if auto provisioning is enabled and the admin
+// role is synced with the groups if there are admin groups.
+func (a *Authenticator) validateProxyUser(name string, groups []string) auth.ValidateResponse {
+	allowed, isAdmin := auth.ProxyRole(a.proxyAuth, groups)
+	if !allowed {
+		return auth.ValidateResponse{}
+	}
+	name = strings.ToLower(name)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, found := a.userByNameUnsafe(name)
+	if !found && !a.proxyAuth.AutoProvision {
+		return auth.ValidateResponse{}
+	}
+	changed := !found
+	if !found {
+		// The account doesn't have a password, basic auth is impossible.
+		user = auth.Account{
+			ID:       auth.GenToken()[:16],
+			Username: name,
+			Token:    auth.GenToken(),
+		}
+	}
+	if len(a.proxyAuth.AdminGroups) != 0 && user.IsAdmin != isAdmin {
+		user.IsAdmin = isAdmin
+		changed = true
+	}
+
+	if changed {
+		prev, exist := a.accounts[user.ID]
+		a.accounts[user.ID] = user
+		if err := a.saveToFile(); err != nil {
+			if exist {
+				a.accounts[user.ID] = prev
+			} else {
+				delete(a.accounts, user.ID)
+			}
+			a.logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "auth",
+				Msg:   fmt.Sprintf("proxy auth: save user %q: %v", name, err),
+			})
+			return auth.ValidateResponse{}
+		}
+		// The cached basic auth responses may have the old role.
+		a.authCache = make(map[string]auth.ValidateResponse)
+
+		msg := fmt.Sprintf("proxy auth: user %q is now admin: %v", name, user.IsAdmin)
+		if !found {
+			msg = fmt.Sprintf("proxy auth: created user %q, admin: %v", name, user.IsAdmin)
+		}
+		a.logger.Log(log.Entry{Level: log.LevelInfo, Src: "auth", Msg: msg})
+	}
+
+	// The password is managed by the proxy.
+	user.MustChangePassword = false
+	return auth.ValidateResponse{IsValid: true, User: user}
 }
 
 func passwordsMatch(hash []byte, plaintext string) bool {
@@ -125,6 +236,78 @@ func (a *Authenticator) userByNameUnsafe(name string) (auth.Account, bool) {
 	return auth.Account{}, false
 }
 
+type impersonation struct {
+	userID string
+	until  time.Time
+}
+
+// impersonate returns the impersonated user if the admin is impersonating.
+func (a *Authenticator) impersonate(res auth.ValidateResponse) auth.ValidateResponse {
+	a.mu.Lock()
+	imp, exists := a.impersonations[res.User.ID]
+	if !exists {
+		a.mu.Unlock()
+		return res
+	}
+	user, userExists := a.accounts[imp.userID]
+	if !userExists || time.Now().After(imp.until) {
+		delete(a.impersonations, res.User.ID)
+		a.mu.Unlock()
+		auth.LogImpersonation(a.logger, fmt.Sprintf(
+			"admin %q stopped impersonating user id %q: expired", res.User.Username, imp.userID))
+		return res
+	}
+	a.mu.Unlock()
+
+	admin := res.User
+	return auth.ValidateResponse{
+		IsValid:      true,
+		User:         user,
+		Impersonator: &admin,
+	}
+}
+
+// Impersonate validates the requests of the admin as the
+// user with the given id until the duration has passed.
+func (a *Authenticator) Impersonate(adminID string, userID string, duration time.Duration) error {
+	if adminID == userID {
+		return auth.ErrImpersonateSelf
+	}
+
+	a.mu.Lock()
+	admin, adminExists := a.accounts[adminID]
+	user, userExists := a.accounts[userID]
+	if !adminExists || !userExists {
+		a.mu.Unlock()
+		return ErrUserNotExist
+	}
+	until := time.Now().Add(duration)
+	a.impersonations[adminID] = impersonation{userID: userID, until: until}
+	a.mu.Unlock()
+
+	auth.LogImpersonation(a.logger, fmt.Sprintf("admin %q started impersonating user %q until %v",
+		admin.Username, user.Username, until.Format(time.RFC3339)))
+	return nil
+}
+
+// StopImpersonation stops the impersonation of the admin.
+func (a *Authenticator) StopImpersonation(adminID string) error {
+	a.mu.Lock()
+	imp, exists := a.impersonations[adminID]
+	if !exists {
+		a.mu.Unlock()
+		return auth.ErrNotImpersonating
+	}
+	delete(a.impersonations, adminID)
+	admin := a.accounts[adminID]
+	user := a.accounts[imp.userID]
+	a.mu.Unlock()
+
+	auth.LogImpersonation(a.logger, fmt.Sprintf(
+		"admin %q stopped impersonating user %q", admin.Username, user.Username))
+	return nil
+}
+
 // Modified from net/http. Link:
 // https://cs.opensource.google/go/go/+/refs/tags/go1.17.8:src/net/http/request.go;l=949
 func parseBasicAuth(str string) (string, string) {
@@ -154,6 +337,16 @@ func (a *Authenticator) resetTokens() {
 	a.mu.Unlock()
 }
 
+// Lockouts returns the active lockouts.
+func (a *Authenticator) Lockouts() []auth.Lockout {
+	return a.limiter.Lockouts(time.Now())
+}
+
+// ClearLockout unlocks a IP or account.
+func (a *Authenticator) ClearLockout(typ string, key string) error {
+	return a.limiter.Clear(typ, key)
+}
+
 // AuthDisabled False.
 func (a *Authenticator) AuthDisabled() bool {
 	return false
@@ -167,9 +360,11 @@ func (a *Authenticator) UsersList() map[string]auth.AccountObfuscated {
 	list := make(map[string]auth.AccountObfuscated)
 	for id, user := range a.accounts {
 		list[id] = auth.AccountObfuscated{
-			ID:       user.ID,
-			Username: user.Username,
-			IsAdmin:  user.IsAdmin,
+			ID:                 user.ID,
+			Username:           user.Username,
+			IsAdmin:            user.IsAdmin,
+			MustChangePassword: user.MustChangePassword,
+			Talkback:           user.Talkback,
 		}
 	}
 	return list
@@ -185,6 +380,12 @@ var (
 
 // UserSet set user details.
 func (a *Authenticator) UserSet(req auth.SetUserRequest) error {
+	if req.PlainPassword != "" {
+		if err := auth.CheckPassword(a.passwordPolicy, req.PlainPassword); err != nil {
+			return err
+		}
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -207,9 +408,12 @@ func (a *Authenticator) UserSet(req auth.SetUserRequest) error {
 	user.ID = req.ID
 	user.Username = req.Username
 	user.IsAdmin = req.IsAdmin
+	user.MustChangePassword = req.MustChangePassword
+	user.Talkback = req.Talkback
 	if req.PlainPassword != "" {
 		hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(req.PlainPassword), a.hashCost)
 		if err != nil {
+			a.mu.Lock()
 			return fmt.Errorf("hash password: %w", err)
 		}
 		user.Password = hashedNewPassword
@@ -248,6 +452,85 @@ func (a *Authenticator) UserDelete(id string) error {
 	return nil
 }
 
+// UserReset sets a temporary password and requires
+// the user to change it before using the app.
+func (a *Authenticator) UserReset(req auth.ResetPasswordRequest) error {
+	if err := auth.CheckPassword(a.passwordPolicy, req.PlainPassword); err != nil {
+		return err
+	}
+	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(req.PlainPassword), a.hashCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, exists := a.accounts[req.ID]
+	if !exists {
+		return ErrUserNotExist
+	}
+	user.Password = hashedNewPassword
+	user.MustChangePassword = true
+	user.Token = auth.GenToken()
+	a.accounts[user.ID] = user
+
+	// Reset cache.
+	a.authCache = make(map[string]auth.ValidateResponse)
+
+	if err := a.saveToFile(); err != nil {
+		return fmt.Errorf("save users to file: %w", err)
+	}
+	return nil
+}
+
+// ChangePassword changes the password of the user if the current
+// password is correct and clears the must change password flag.
+func (a *Authenticator) ChangePassword(id string, req auth.ChangePasswordRequest) error {
+	a.mu.Lock()
+	user, exists := a.accounts[id]
+	a.mu.Unlock()
+	if !exists {
+		return ErrUserNotExist
+	}
+
+	a.hashLock.Lock()
+	match := passwordsMatch(user.Password, req.CurrentPassword)
+	a.hashLock.Unlock()
+	if !match {
+		return auth.ErrWrongPassword
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return auth.ErrPasswordUnchanged
+	}
+	if err := auth.CheckPassword(a.passwordPolicy, req.NewPassword); err != nil {
+		return err
+	}
+	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), a.hashCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, exists = a.accounts[id]
+	if !exists {
+		return ErrUserNotExist
+	}
+	user.Password = hashedNewPassword
+	user.MustChangePassword = false
+	a.accounts[id] = user
+
+	// Reset cache.
+	a.authCache = make(map[string]auth.ValidateResponse)
+
+	if err := a.saveToFile(); err != nil {
+		return fmt.Errorf("save users to file: %w", err)
+	}
+	return nil
+}
+
 func (a *Authenticator) saveToFile() error {
 	users, err := json.MarshalIndent(a.accounts, "", "  ")
 	if err != nil {
@@ -266,15 +549,27 @@ func (a *Authenticator) saveToFile() error {
 func (a *Authenticator) User(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		res := a.ValidateRequest(r)
+		if !res.LockedUntil.IsZero() {
+			auth.RespondLocked(w, res.LockedUntil)
+			return
+		}
 		if !res.IsValid {
 			if r.Header.Get("Authorization") != "" {
 				username, _ := parseBasicAuth(r.Header.Get("Authorization"))
 				auth.LogFailedLogin(a.logger, r, username)
 			}
-			w.Header().Set("WWW-Authenticate", `Basic realm=""`)
+			a.promptLogin(w, `Basic realm=""`)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
+		if res.User.MustChangePassword && res.Impersonator == nil &&
+			!auth.PasswordChangeAllowed(r.URL.Path) {
+			auth.RespondPasswordChange(w, r)
+			return
+		}
+		if res.Impersonator != nil {
+			w.Header().Set(auth.ImpersonationHeader, res.User.Username)
+		}
 
 		next.ServeHTTP(w, r)
 	})
@@ -284,6 +579,10 @@ func (a *Authenticator) User(next http.Handler) http.Handler {
 func (a *Authenticator) Admin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		res := a.ValidateRequest(r)
+		if !res.LockedUntil.IsZero() {
+			auth.RespondLocked(w, res.LockedUntil)
+			return
+		}
 
 		if !res.IsValid || !res.User.IsAdmin {
 			if r.Header.Get("Authorization") != "" {
@@ -291,15 +590,32 @@ func (a *Authenticator) Admin(next http.Handler) http.Handler {
 				auth.LogFailedLogin(a.logger, r, username)
 			}
 
-			w.Header().Set("WWW-Authenticate", `Basic realm="NVR"`)
+			a.promptLogin(w, `Basic realm="NVR"`)
 			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
 			return
 		}
+		if res.User.MustChangePassword && res.Impersonator == nil &&
+			!auth.PasswordChangeAllowed(r.URL.Path) {
+			auth.RespondPasswordChange(w, r)
+			return
+		}
+		if res.Impersonator != nil {
+			w.Header().Set(auth.ImpersonationHeader, res.User.Username)
+		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// promptLogin makes the browser prompt for basic auth credentials. The
+// proxy handles the login if proxy auth is enabled, a prompt would only
+// confuse users of SSO deployments.
+func (a *Authenticator) promptLogin(w http.ResponseWriter, challenge string) {
+	if !a.proxyAuth.Enable {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+}
+
 // CSRF blocks invalid Cross-site request forgery tokens.
 // Each user has a unique token. The request needs to
 // have a matching token in the "X-CSRF-TOKEN" header.
@@ -335,8 +651,13 @@ func (a *Authenticator) MyToken() http.Handler {
 }
 
 // Logout prompts for login and redirects. Old login should be overwritten.
+// Redirects to the logout URL of the proxy if proxy auth is used.
 func (a *Authenticator) Logout() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.proxyAuth.Enable && a.proxyAuth.LogoutURL != "" {
+			http.Redirect(w, r, a.proxyAuth.LogoutURL, http.StatusSeeOther)
+			return
+		}
 		switch r.Header.Get("Authorization") {
 		case "Basic Og==":
 		case "":
@@ -3,12 +3,15 @@
 package basic
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"io/fs"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"nvr/pkg/log"
 	"nvr/pkg/storage"
@@ -53,13 +56,28 @@ func newTestAuth(t *testing.T) (string, *Authenticator, func()) {
 	err = os.WriteFile(usersPath, data, 0o600)
 	require.NoError(t, err)
 
+	// Logs are discarded.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	logger := &log.Logger{Ctx: ctx}
+	limiter := auth.NewLimiter(storage.AuthRateLimit{
+		MaxAttemptsIP:      10,
+		MaxAttemptsAccount: 3,
+		Window:             600,
+		Lockout:            900,
+	}, logger)
+
 	auth := Authenticator{
 		path:      usersPath,
 		accounts:  users,
 		authCache: make(map[string]auth.ValidateResponse),
 
+		impersonations: make(map[string]impersonation),
+
 		hashCost: bcrypt.MinCost,
-		logger:   &log.Logger{},
+		limiter:  limiter,
+		logger:   logger,
 	}
 	return tempDir, &auth, cancelFunc
 }
@@ -338,4 +356,332 @@ func TestBasicAuthenticator(t *testing.T) {
 		response2 := a.ValidateRequest(req)
 		require.True(t, response2.IsValid)
 	})
+
+	t.Run("impersonate", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		basicAuth := base64.StdEncoding.EncodeToString([]byte("admin:pass1"))
+		req := authHeader("Basic " + basicAuth)
+
+		require.ErrorIs(t, a.Impersonate("1", "1", time.Hour), auth.ErrImpersonateSelf)
+		require.ErrorIs(t, a.Impersonate("1", "x", time.Hour), ErrUserNotExist)
+		require.ErrorIs(t, a.StopImpersonation("1"), auth.ErrNotImpersonating)
+
+		require.NoError(t, a.Impersonate("1", "2", time.Hour))
+		res := a.ValidateRequest(req)
+		require.True(t, res.IsValid)
+		require.Equal(t, "user", res.User.Username)
+		require.Equal(t, "admin", res.Impersonator.Username)
+
+		require.NoError(t, a.StopImpersonation("1"))
+		res = a.ValidateRequest(req)
+		require.Equal(t, "admin", res.User.Username)
+		require.Nil(t, res.Impersonator)
+	})
+	t.Run("impersonationExpired", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		basicAuth := base64.StdEncoding.EncodeToString([]byte("admin:pass1"))
+		req := authHeader("Basic " + basicAuth)
+
+		require.NoError(t, a.Impersonate("1", "2", -time.Second))
+		res := a.ValidateRequest(req)
+		require.Equal(t, "admin", res.User.Username)
+		require.Nil(t, res.Impersonator)
+		require.Empty(t, a.impersonations)
+	})
+	t.Run("lockout", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		wrongPass := base64.StdEncoding.EncodeToString([]byte("user:wrongPass"))
+		for i := 0; i < 2; i++ {
+			res := a.ValidateRequest(authHeader("Basic " + wrongPass))
+			require.False(t, res.IsValid)
+			require.True(t, res.LockedUntil.IsZero())
+		}
+
+		// Wrong passwords are rejected as locked from the third failure.
+		res := a.ValidateRequest(authHeader("Basic " + wrongPass))
+		require.False(t, res.IsValid)
+		require.False(t, res.LockedUntil.IsZero())
+
+		lockouts := a.Lockouts()
+		require.Len(t, lockouts, 1)
+		require.Equal(t, auth.LockoutAccount, lockouts[0].Type)
+		require.Equal(t, "user", lockouts[0].Key)
+
+		// The correct password is accepted while locked,
+		// anyone could lock out the account otherwise.
+		okPass := base64.StdEncoding.EncodeToString([]byte("user:pass2"))
+		require.True(t, a.ValidateRequest(authHeader("Basic "+okPass)).IsValid)
+		require.Empty(t, a.Lockouts())
+	})
+	t.Run("ipLockout", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+		a.limiter = auth.NewLimiter(storage.AuthRateLimit{
+			MaxAttemptsIP:      2,
+			MaxAttemptsAccount: -1,
+			Window:             600,
+			Lockout:            900,
+		}, a.logger)
+
+		wrongPass := base64.StdEncoding.EncodeToString([]byte("user:wrongPass"))
+		for i := 0; i < 2; i++ {
+			a.ValidateRequest(authHeader("Basic " + wrongPass))
+		}
+
+		// The IP is locked for every account.
+		adminPass := base64.StdEncoding.EncodeToString([]byte("admin:pass1"))
+		res := a.ValidateRequest(authHeader("Basic " + adminPass))
+		require.False(t, res.IsValid)
+		require.False(t, res.LockedUntil.IsZero())
+
+		// The test requests don't have a remote address.
+		require.NoError(t, a.ClearLockout(auth.LockoutIP, ""))
+		require.True(t, a.ValidateRequest(authHeader("Basic "+adminPass)).IsValid)
+	})
+	t.Run("lockedResponse", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		wrongPass := base64.StdEncoding.EncodeToString([]byte("user:wrongPass"))
+		for i := 0; i < 3; i++ {
+			a.ValidateRequest(authHeader("Basic " + wrongPass))
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Basic "+wrongPass)
+		w := httptest.NewRecorder()
+		a.User(http.NotFoundHandler()).ServeHTTP(w, r)
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+	t.Run("passwordPolicy", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		a.passwordPolicy = storage.PasswordPolicy{MinLength: 8, RequireDigit: true}
+
+		err := a.UserSet(auth.SetUserRequest{ID: "10", Username: "a", PlainPassword: "short1"})
+		require.ErrorIs(t, err, auth.ErrPasswordPolicy)
+
+		err = a.UserSet(auth.SetUserRequest{ID: "10", Username: "a", PlainPassword: "password1"})
+		require.NoError(t, err)
+
+		// Other fields can be changed without a password.
+		err = a.UserSet(auth.SetUserRequest{ID: "2", Username: "user"})
+		require.NoError(t, err)
+	})
+	t.Run("userReset", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		err := a.UserReset(auth.ResetPasswordRequest{ID: "10", PlainPassword: "temp"})
+		require.ErrorIs(t, err, ErrUserNotExist)
+
+		err = a.UserReset(auth.ResetPasswordRequest{ID: "2", PlainPassword: "temp"})
+		require.NoError(t, err)
+		require.True(t, a.UsersList()["2"].MustChangePassword)
+
+		oldPass := base64.StdEncoding.EncodeToString([]byte("user:pass2"))
+		require.False(t, a.ValidateRequest(authHeader("Basic "+oldPass)).IsValid)
+
+		tempPass := base64.StdEncoding.EncodeToString([]byte("user:temp"))
+		res := a.ValidateRequest(authHeader("Basic " + tempPass))
+		require.True(t, res.IsValid)
+		require.True(t, res.User.MustChangePassword)
+	})
+	t.Run("changePassword", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		a.passwordPolicy = storage.PasswordPolicy{MinLength: 6}
+		require.NoError(t, a.UserReset(auth.ResetPasswordRequest{ID: "2", PlainPassword: "temporary"}))
+
+		err := a.ChangePassword("2", auth.ChangePasswordRequest{
+			CurrentPassword: "wrong", NewPassword: "newPassword",
+		})
+		require.ErrorIs(t, err, auth.ErrWrongPassword)
+
+		err = a.ChangePassword("2", auth.ChangePasswordRequest{
+			CurrentPassword: "temporary", NewPassword: "temporary",
+		})
+		require.ErrorIs(t, err, auth.ErrPasswordUnchanged)
+
+		err = a.ChangePassword("2", auth.ChangePasswordRequest{
+			CurrentPassword: "temporary", NewPassword: "new",
+		})
+		require.ErrorIs(t, err, auth.ErrPasswordPolicy)
+
+		err = a.ChangePassword("2", auth.ChangePasswordRequest{
+			CurrentPassword: "temporary", NewPassword: "newPassword",
+		})
+		require.NoError(t, err)
+		require.False(t, a.UsersList()["2"].MustChangePassword)
+
+		newPass := base64.StdEncoding.EncodeToString([]byte("user:newPassword"))
+		require.True(t, a.ValidateRequest(authHeader("Basic "+newPass)).IsValid)
+	})
+	t.Run("mustChangePassword", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		require.NoError(t, a.UserReset(auth.ResetPasswordRequest{ID: "1", PlainPassword: "temp"}))
+		tempPass := "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:temp"))
+
+		cases := map[string]struct {
+			path     string
+			accept   string
+			code     int
+			location string
+		}{
+			"page":     {"/live", "text/html", http.StatusSeeOther, "password"},
+			"api":      {"/api/monitor/list", "text/html", http.StatusForbidden, ""},
+			"password": {"/password", "text/html", http.StatusOK, ""},
+			"change":   {"/api/user/password", "", http.StatusOK, ""},
+			"static":   {"/static/style/style.css", "", http.StatusOK, ""},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+				for _, wrap := range []func(http.Handler) http.Handler{a.User, a.Admin} {
+					r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+					r.Header.Set("Authorization", tempPass)
+					r.Header.Set("Accept", tc.accept)
+					w := httptest.NewRecorder()
+					wrap(ok).ServeHTTP(w, r)
+					require.Equal(t, tc.code, w.Code)
+					require.Equal(t, tc.location, w.Header().Get("Location"))
+				}
+			})
+		}
+	})
+	t.Run("noCredentials", func(t *testing.T) {
+		_, a, cancel := newTestAuth(t)
+		defer cancel()
+
+		for i := 0; i < 20; i++ {
+			a.ValidateRequest(authHeader(""))
+		}
+		require.Empty(t, a.Lockouts())
+	})
+	t.Run("proxyAuth", func(t *testing.T) {
+		newProxyAuth := func(t *testing.T, autoProvision bool) (*Authenticator, func()) {
+			_, a, cancel := newTestAuth(t)
+			a.proxyAuth = storage.ProxyAuth{
+				Enable:        true,
+				UserHeader:    "Remote-User",
+				GroupsHeader:  "Remote-Groups",
+				AdminGroups:   []string{"admins"},
+				UserGroups:    []string{"nvr"},
+				AutoProvision: autoProvision,
+				LogoutURL:     "https://auth.example.com/logout",
+			}
+			return a, cancel
+		}
+		proxyRequest := func(user string, groups string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Remote-User", user)
+			r.Header.Set("Remote-Groups", groups)
+			return auth.WithTrustedProxy(r)
+		}
+		t.Run("existing", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+
+			res := a.ValidateRequest(proxyRequest("User", "nvr"))
+			require.True(t, res.IsValid)
+			require.Equal(t, "2", res.User.ID)
+			require.False(t, res.User.IsAdmin)
+		})
+		t.Run("syncAdmin", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+
+			res := a.ValidateRequest(proxyRequest("admin", "nvr"))
+			require.True(t, res.IsValid)
+			require.False(t, res.User.IsAdmin)
+			require.False(t, a.UsersList()["1"].IsAdmin)
+
+			res = a.ValidateRequest(proxyRequest("user", "admins"))
+			require.True(t, res.IsValid)
+			require.True(t, res.User.IsAdmin)
+		})
+		t.Run("notAllowed", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+			require.False(t, a.ValidateRequest(proxyRequest("user", "x")).IsValid)
+		})
+		t.Run("unknown", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+			require.False(t, a.ValidateRequest(proxyRequest("x", "nvr")).IsValid)
+		})
+		t.Run("autoProvision", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, true)
+			defer cancel()
+
+			res := a.ValidateRequest(proxyRequest("New", "admins"))
+			require.True(t, res.IsValid)
+			require.Equal(t, "new", res.User.Username)
+			require.True(t, res.User.IsAdmin)
+			require.NotEmpty(t, res.User.Token)
+			require.Len(t, a.accounts, 3)
+
+			// Saved and reused.
+			res2 := a.ValidateRequest(proxyRequest("new", "admins"))
+			require.Equal(t, res.User.ID, res2.User.ID)
+			require.Len(t, a.accounts, 3)
+
+			file, err := os.ReadFile(a.path)
+			require.NoError(t, err)
+			require.Contains(t, string(file), `"username": "new"`)
+
+			// Basic auth isn't possible without a password.
+			basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("new:"))
+			require.False(t, a.ValidateRequest(authHeader(basic)).IsValid)
+		})
+		t.Run("untrusted", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, true)
+			defer cancel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Remote-User", "admin")
+			r.Header.Set("Remote-Groups", "admins")
+			require.False(t, a.ValidateRequest(r).IsValid)
+			require.Len(t, a.accounts, 2)
+		})
+		t.Run("basicFallback", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+
+			basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:pass1"))
+			require.True(t, a.ValidateRequest(authHeader(basic)).IsValid)
+		})
+		t.Run("noPrompt", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+
+			ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+			for _, wrap := range []func(http.Handler) http.Handler{a.User, a.Admin} {
+				w := httptest.NewRecorder()
+				wrap(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				require.Equal(t, http.StatusUnauthorized, w.Code)
+				require.Empty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+		t.Run("logout", func(t *testing.T) {
+			a, cancel := newProxyAuth(t, false)
+			defer cancel()
+
+			w := httptest.NewRecorder()
+			a.Logout().ServeHTTP(w, proxyRequest("user", "nvr"))
+			require.Equal(t, http.StatusSeeOther, w.Code)
+			require.Equal(t, "https://auth.example.com/logout", w.Header().Get("Location"))
+		})
+	})
 }
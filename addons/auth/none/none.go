@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -38,6 +39,8 @@ type Authenticator struct {
 	accounts map[string]auth.Account
 	hashCost int
 
+	passwordPolicy storage.PasswordPolicy
+
 	token string
 	mu    sync.Mutex
 }
@@ -51,6 +54,8 @@ func NewAuthenticator(env storage.ConfigEnv, _ *log.Logger) (auth.Authenticator,
 		accounts: make(map[string]auth.Account),
 		hashCost: auth.DefaultBcryptHashCost,
 
+		passwordPolicy: env.PasswordPolicy,
+
 		token: auth.GenToken(),
 	}
 
@@ -84,6 +89,26 @@ func (a *Authenticator) ValidateRequest(_ *http.Request) auth.ValidateResponse {
 	}
 }
 
+// Impersonate is not supported, all requests are admin requests.
+func (a *Authenticator) Impersonate(string, string, time.Duration) error {
+	return auth.ErrImpersonationUnsupported
+}
+
+// StopImpersonation is not supported.
+func (a *Authenticator) StopImpersonation(string) error {
+	return auth.ErrImpersonationUnsupported
+}
+
+// Lockouts returns nothing, logins cannot fail.
+func (a *Authenticator) Lockouts() []auth.Lockout {
+	return []auth.Lockout{}
+}
+
+// ClearLockout does nothing.
+func (a *Authenticator) ClearLockout(string, string) error {
+	return nil
+}
+
 // AuthDisabled True.
 func (a *Authenticator) AuthDisabled() bool {
 	return true
@@ -97,9 +122,11 @@ func (a *Authenticator) UsersList() map[string]auth.AccountObfuscated {
 	list := make(map[string]auth.AccountObfuscated)
 	for id, user := range a.accounts {
 		list[id] = auth.AccountObfuscated{
-			ID:       user.ID,
-			Username: user.Username,
-			IsAdmin:  user.IsAdmin,
+			ID:                 user.ID,
+			Username:           user.Username,
+			IsAdmin:            user.IsAdmin,
+			MustChangePassword: user.MustChangePassword,
+			Talkback:           user.Talkback,
 		}
 	}
 	return list
@@ -115,6 +142,12 @@ var (
 
 // UserSet set user details.
 func (a *Authenticator) UserSet(req auth.SetUserRequest) error {
+	if req.PlainPassword != "" {
+		if err := auth.CheckPassword(a.passwordPolicy, req.PlainPassword); err != nil {
+			return err
+		}
+	}
+
 	defer a.mu.Unlock()
 	a.mu.Lock()
 
@@ -137,6 +170,8 @@ func (a *Authenticator) UserSet(req auth.SetUserRequest) error {
 	user.ID = req.ID
 	user.Username = req.Username
 	user.IsAdmin = req.IsAdmin
+	user.MustChangePassword = req.MustChangePassword
+	user.Talkback = req.Talkback
 	if req.PlainPassword != "" {
 		hashedNewPassword, _ := bcrypt.GenerateFromPassword([]byte(req.PlainPassword), a.hashCost)
 		user.Password = hashedNewPassword
@@ -168,6 +203,60 @@ func (a *Authenticator) UserDelete(id string) error {
 	return nil
 }
 
+// UserReset sets a temporary password and the must change password
+// flag. The flag is only enforced when basic auth is enabled.
+func (a *Authenticator) UserReset(req auth.ResetPasswordRequest) error {
+	if err := auth.CheckPassword(a.passwordPolicy, req.PlainPassword); err != nil {
+		return err
+	}
+	hashedNewPassword, _ := bcrypt.GenerateFromPassword([]byte(req.PlainPassword), a.hashCost)
+
+	defer a.mu.Unlock()
+	a.mu.Lock()
+
+	user, exists := a.accounts[req.ID]
+	if !exists {
+		return ErrUserNotExist
+	}
+	user.Password = hashedNewPassword
+	user.MustChangePassword = true
+	a.accounts[user.ID] = user
+
+	if err := a.SaveUsersToFile(); err != nil {
+		return fmt.Errorf("could not save users to file: %w", err)
+	}
+	return nil
+}
+
+// ChangePassword changes the password of the user if the current
+// password is correct and clears the must change password flag.
+func (a *Authenticator) ChangePassword(id string, req auth.ChangePasswordRequest) error {
+	defer a.mu.Unlock()
+	a.mu.Lock()
+
+	user, exists := a.accounts[id]
+	if !exists {
+		return ErrUserNotExist
+	}
+	if bcrypt.CompareHashAndPassword(user.Password, []byte(req.CurrentPassword)) != nil {
+		return auth.ErrWrongPassword
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return auth.ErrPasswordUnchanged
+	}
+	if err := auth.CheckPassword(a.passwordPolicy, req.NewPassword); err != nil {
+		return err
+	}
+	user.Password, _ = bcrypt.GenerateFromPassword([]byte(req.NewPassword), a.hashCost)
+	user.MustChangePassword = false
+	a.accounts[id] = user
+
+	if err := a.SaveUsersToFile(); err != nil {
+		return fmt.Errorf("could not save users to file: %w", err)
+	}
+	return nil
+}
+
 // User allows all requests.
 func (a *Authenticator) User(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
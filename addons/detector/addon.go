@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"nvr"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/video/gortsplib/pkg/h264"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func init() {
+	nvr.RegisterMonitorInputProcessHook(onInputProcessStart)
+	nvr.RegisterLogSource([]string{"detector"})
+	nvr.RegisterTplHook(modifyTemplates)
+}
+
+func onInputProcessStart(ctx context.Context, i *monitor.InputProcess, _ *[]string) {
+	if i.Config.SubInputEnabled() != i.IsSubInput() {
+		return
+	}
+
+	id := i.Config.ID()
+	logf := func(level log.Level, format string, a ...interface{}) {
+		i.Logger.Log(log.Entry{
+			Level:     level,
+			Src:       "detector",
+			MonitorID: id,
+			Msg:       fmt.Sprintf(format, a...),
+		})
+	}
+
+	config, enable, err := parseConfig(i.Config)
+	if err != nil {
+		logf(log.LevelError, "could not parse config: %v", err)
+		return
+	}
+	if !enable {
+		return
+	}
+
+	backend, err := newBackend(config.backend, i.Config)
+	if err != nil {
+		logf(log.LevelError, "could not create backend: %v", err)
+		return
+	}
+
+	i.WG.Add(1)
+	go start(ctx, i, *config, backend, logf)
+}
+
+func start(
+	ctx context.Context,
+	i *monitor.InputProcess,
+	config config,
+	backend Backend,
+	logf log.Func,
+) {
+	defer i.WG.Done()
+
+	// Wait for the monitor to start.
+	select {
+	case <-time.After(10 * time.Second):
+	case <-ctx.Done():
+		return
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		ctx2, cancel := context.WithCancel(ctx)
+
+		if err := run(ctx2, cancel, i, config, backend, logf); err != nil {
+			logf(log.LevelError, "%v", err)
+		}
+		cancel()
+
+		select {
+		case <-time.After(3 * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func run(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	i *monitor.InputProcess,
+	config config,
+	backend Backend,
+	logf log.Func,
+) error {
+	videoTrack, err := i.VideoTrack(ctx)
+	if err != nil {
+		return fmt.Errorf("get video track: %w", err)
+	}
+
+	var spsp h264.SPS
+	err = spsp.Unmarshal(videoTrack.SPS)
+	if err != nil {
+		return fmt.Errorf("unmarshal spsp: %w", err)
+	}
+
+	width, height := frameSize(spsp.Width(), spsp.Height())
+
+	d := &detector{
+		backend:   backend,
+		sendEvent: i.SendEvent,
+		logf:      logf,
+		config:    config,
+		width:     width,
+		height:    height,
+	}
+
+	args := generateFFmpegArgs(config, i.RTSPprotocol(), i.RTSPaddress(), width, height)
+	cmd := exec.Command(i.Env.FFmpegBin, args...)
+
+	processLogFunc := func(msg string) {
+		logf(log.FFmpegLevel(config.logLevel), fmt.Sprintf("process: %v", msg))
+	}
+
+	process := ffmpeg.NewProcess(cmd).
+		StderrLogger(processLogFunc)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stdout: %w", err)
+	}
+
+	logf(log.LevelInfo, "starting process: %v", cmd)
+
+	i.WG.Add(1)
+	go d.startFrameReader(ctx, cancel, i.WG, stdout)
+
+	err = process.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("process crashed: %w", err)
+	}
+	return nil
+}
+
+// Frames are downscaled to this width before detection.
+const maxFrameWidth = 640
+
+// frameSize returns the output frame size. The aspect ratio
+// is kept and the dimensions are rounded down to even numbers.
+func frameSize(inputWidth int, inputHeight int) (int, int) {
+	if inputWidth <= maxFrameWidth {
+		return inputWidth &^ 1, inputHeight &^ 1
+	}
+	height := inputHeight * maxFrameWidth / inputWidth
+	return maxFrameWidth, height &^ 1
+}
+
+func generateFFmpegArgs(
+	c config,
+	rtspProtocol string,
+	rtspAddress string,
+	width int,
+	height int,
+) []string {
+	// Output.
+	//	ffmpeg -loglevel info -hwaccel x -y -rtsp_transport tcp -i rtsp://ip
+	//    -vf "fps=fps=1,scale=640:360" -f rawvideo -pix_fmt rgba -
+
+	var args []string
+
+	args = append(args, "-y", "-threads", "1", "-loglevel", c.logLevel)
+
+	if c.hwaccel != "" {
+		args = append(args, ffmpeg.ParseArgs("-hwaccel "+c.hwaccel)...)
+	}
+
+	args = append(args, "-rtsp_transport", rtspProtocol, "-i", rtspAddress)
+
+	scale := strconv.Itoa(width) + ":" + strconv.Itoa(height)
+	args = append(args, "-vf", "fps=fps="+c.feedRate+",scale="+scale)
+	args = append(args, "-f", "rawvideo", "-pix_fmt", "rgba", "-")
+
+	return args
+}
+
+type detector struct {
+	backend   Backend
+	sendEvent monitor.SendEventFunc
+	logf      log.Func
+	config    config
+
+	width  int
+	height int
+}
+
+func (d *detector) startFrameReader(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	wg *sync.WaitGroup,
+	stdout io.Reader,
+) {
+	defer wg.Done()
+	err := d.runFrameReader(ctx, stdout)
+	if !errors.Is(err, io.EOF) && !errors.Is(err, context.Canceled) {
+		d.logf(log.LevelError, "frame reader: %v", err)
+	}
+	cancel()
+}
+
+// Maximum time to wait for the backend.
+const detectTimeout = 10 * time.Second
+
+func (d *detector) runFrameReader(ctx context.Context, stdout io.Reader) error {
+	img := image.NewRGBA(image.Rect(0, 0, d.width, d.height))
+
+	for {
+		if _, err := io.ReadFull(stdout, img.Pix); err != nil {
+			return fmt.Errorf("read frame: %w", err)
+		}
+		t := time.Now().Add(-d.config.timestampOffset)
+
+		ctx2, cancel := context.WithTimeout(ctx, detectTimeout)
+		objects, err := d.backend.Detect(ctx2, img)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("detect: %w", err)
+		}
+
+		detections := filterObjects(objects, d.config.thresholds, d.config.zones)
+		if len(detections) == 0 {
+			continue
+		}
+
+		d.logf(log.LevelDebug, "trigger: label:%v score:%.1f",
+			detections[0].Label, detections[0].Score)
+
+		err = d.sendEvent(storage.Event{
+			Time:        t,
+			Trigger:     storage.TriggerObject,
+			Detections:  detections,
+			Duration:    d.config.duration,
+			RecDuration: d.config.recDuration,
+		})
+		if err != nil {
+			return fmt.Errorf("send event: %w", err)
+		}
+	}
+}
+
+// filterObjects returns the objects above their label threshold
+// whose center is inside one of the zones. Zones are in percent
+// and the whole frame is used if there are no zones.
+func filterObjects(
+	objects []Object,
+	thresholds thresholds,
+	zones []ffmpeg.Polygon,
+) []storage.Detection {
+	detections := []storage.Detection{}
+	for _, o := range objects {
+		if !thresholds.accept(o.Label, o.Score) {
+			continue
+		}
+
+		top := int(o.Top * 100)
+		left := int(o.Left * 100)
+		bottom := int(o.Bottom * 100)
+		right := int(o.Right * 100)
+
+		if !insideZones(left+(right-left)/2, top+(bottom-top)/2, zones) {
+			continue
+		}
+
+		detections = append(detections, storage.Detection{
+			Label: o.Label,
+			Score: o.Score,
+			Region: &storage.Region{
+				Rect: &ffmpeg.Rect{top, left, bottom, right},
+			},
+		})
+	}
+	return detections
+}
+
+func insideZones(x int, y int, zones []ffmpeg.Polygon) bool {
+	if len(zones) == 0 {
+		return true
+	}
+	for _, zone := range zones {
+		if ffmpeg.VertexInsidePoly(x, y, zone) {
+			return true
+		}
+	}
+	return false
+}
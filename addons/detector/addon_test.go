@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"testing"
+
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameSize(t *testing.T) {
+	cases := []struct {
+		inputWidth     int
+		inputHeight    int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{1920, 1080, 640, 360},
+		{2560, 1440, 640, 360},
+		{1280, 1024, 640, 512},
+		{641, 481, 640, 480},
+		{352, 241, 352, 240},
+	}
+	for _, tc := range cases {
+		width, height := frameSize(tc.inputWidth, tc.inputHeight)
+		require.Equal(t, tc.expectedWidth, width)
+		require.Equal(t, tc.expectedHeight, height)
+	}
+}
+
+func TestGenerateFFmpegArgs(t *testing.T) {
+	t.Run("minimal", func(t *testing.T) {
+		c := config{
+			logLevel: "1",
+			feedRate: "2",
+		}
+		actual := generateFFmpegArgs(c, "3", "4", 640, 360)
+
+		expected := []string{
+			"-y", "-threads", "1", "-loglevel", "1",
+			"-rtsp_transport", "3", "-i", "4",
+			"-vf", "fps=fps=2,scale=640:360",
+			"-f", "rawvideo", "-pix_fmt", "rgba", "-",
+		}
+		require.Equal(t, expected, actual)
+	})
+	t.Run("maximal", func(t *testing.T) {
+		c := config{
+			logLevel: "1",
+			hwaccel:  "2",
+			feedRate: "3",
+		}
+		actual := generateFFmpegArgs(c, "4", "5", 640, 360)
+
+		expected := []string{
+			"-y", "-threads", "1", "-loglevel", "1", "-hwaccel", "2",
+			"-rtsp_transport", "4", "-i", "5",
+			"-vf", "fps=fps=3,scale=640:360",
+			"-f", "rawvideo", "-pix_fmt", "rgba", "-",
+		}
+		require.Equal(t, expected, actual)
+	})
+}
+
+func TestFilterObjects(t *testing.T) {
+	objects := []Object{
+		{Label: "person", Score: 80, Top: 0.1, Left: 0.1, Bottom: 0.3, Right: 0.3},
+		{Label: "person", Score: 40, Top: 0.1, Left: 0.1, Bottom: 0.3, Right: 0.3},
+		{Label: "car", Score: 90, Top: 0.1, Left: 0.1, Bottom: 0.3, Right: 0.3},
+		{Label: "person", Score: 90, Top: 0.1, Left: 0.7, Bottom: 0.3, Right: 0.9},
+	}
+	t.Run("noZones", func(t *testing.T) {
+		actual := filterObjects(objects, thresholds{"person": 50}, nil)
+		expected := []storage.Detection{
+			{
+				Label:  "person",
+				Score:  80,
+				Region: &storage.Region{Rect: &ffmpeg.Rect{10, 10, 30, 30}},
+			},
+			{
+				Label:  "person",
+				Score:  90,
+				Region: &storage.Region{Rect: &ffmpeg.Rect{10, 70, 30, 90}},
+			},
+		}
+		require.Equal(t, expected, actual)
+	})
+	t.Run("zones", func(t *testing.T) {
+		// Right half of the frame.
+		zones := []ffmpeg.Polygon{{{50, 0}, {100, 0}, {100, 100}, {50, 100}}}
+		actual := filterObjects(objects, thresholds{"person": 50}, zones)
+		expected := []storage.Detection{
+			{
+				Label:  "person",
+				Score:  90,
+				Region: &storage.Region{Rect: &ffmpeg.Rect{10, 70, 30, 90}},
+			},
+		}
+		require.Equal(t, expected, actual)
+	})
+	t.Run("outsideZones", func(t *testing.T) {
+		// Bottom half of the frame, no objects.
+		zones := []ffmpeg.Polygon{{{0, 50}, {100, 50}, {100, 100}, {0, 100}}}
+		actual := filterObjects(objects, thresholds{}, zones)
+		require.Empty(t, actual)
+	})
+}
+
+func TestModifySettingsjs(t *testing.T) {
+	tpl := `logLevel: fieldTemplate.select(`
+	actual, err := modifySettingsjs(tpl, []string{"a", "b"})
+	require.NoError(t, err)
+	require.Contains(t, actual, `fieldTemplate.select("Detection backend", ["a","b"], "deepstack")`)
+	require.Contains(t, actual, "detectorEnable: ")
+	require.Contains(t, actual, tpl)
+}
+
+func TestNewBackend(t *testing.T) {
+	_, err := newBackend("nil", monitor.NewConfig(monitor.RawConfig{}))
+	require.ErrorIs(t, err, ErrUnknownBackend)
+
+	require.Contains(t, backendNames(), "deepstack")
+}
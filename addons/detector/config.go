@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/monitor"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimum score in percent for labels without a threshold.
+const defaultThreshold = 50
+
+type config struct {
+	monitorID       string
+	logLevel        string
+	hwaccel         string
+	timestampOffset time.Duration
+	backend         string
+	thresholds      thresholds
+	zones           []ffmpeg.Polygon
+	feedRate        string
+	duration        time.Duration
+	recDuration     time.Duration
+}
+
+// Minimum score for each label, all labels are accepted if empty.
+type thresholds map[string]float64
+
+func (t thresholds) accept(label string, score float64) bool {
+	if len(t) == 0 {
+		return score >= defaultThreshold
+	}
+	threshold, exists := t[label]
+	return exists && score >= threshold
+}
+
+// Config errors.
+var (
+	ErrInvalidThreshold = errors.New("invalid threshold")
+	ErrInvalidFeedRate  = errors.New("invalid feed rate")
+)
+
+func parseConfig(c monitor.Config) (*config, bool, error) {
+	if c.Get("detectorEnable") != "true" {
+		return nil, false, nil
+	}
+
+	timestampOffset, err := ffmpeg.ParseTimestampOffset(c.TimestampOffset())
+	if err != nil {
+		return nil, false, err
+	}
+
+	thresholds, err := parseThresholds(c.Get("detectorLabels"))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var zones []ffmpeg.Polygon
+	if rawZones := c.Get("detectorZones"); rawZones != "" {
+		if err := json.Unmarshal([]byte(rawZones), &zones); err != nil {
+			return nil, false, fmt.Errorf("unmarshal zones: %w", err)
+		}
+	}
+
+	feedRate := c.Get("detectorFeedRate")
+	if feedRate == "" {
+		feedRate = "1"
+	}
+	feedRateFloat, err := strconv.ParseFloat(feedRate, 64)
+	if err != nil || feedRateFloat <= 0 {
+		return nil, false, fmt.Errorf("%w: %q", ErrInvalidFeedRate, feedRate)
+	}
+
+	recDuration := 120 * time.Second
+	if rawDuration := c.Get("detectorDuration"); rawDuration != "" {
+		durationInt, err := strconv.Atoi(rawDuration)
+		if err != nil {
+			return nil, false, fmt.Errorf("parse duration: %w", err)
+		}
+		recDuration = time.Duration(durationInt) * time.Second
+	}
+
+	backend := c.Get("detectorBackend")
+	if backend == "" {
+		backend = "deepstack"
+	}
+
+	return &config{
+		monitorID:       c.ID(),
+		logLevel:        c.LogLevel(),
+		hwaccel:         c.Hwaccel(),
+		timestampOffset: timestampOffset,
+		backend:         backend,
+		thresholds:      thresholds,
+		zones:           zones,
+		feedRate:        feedRate,
+		duration:        ffmpeg.FeedRateToDuration(feedRateFloat),
+		recDuration:     recDuration,
+	}, true, nil
+}
+
+// parseThresholds parses a comma separated list of
+// labels with optional thresholds. "person:60,car"
+func parseThresholds(raw string) (thresholds, error) {
+	t := make(thresholds)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		label, rawThreshold, found := strings.Cut(item, ":")
+		label = strings.TrimSpace(label)
+		if !found {
+			t[label] = defaultThreshold
+			continue
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(rawThreshold), 64)
+		if err != nil || threshold < 0 || threshold > 100 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidThreshold, item)
+		}
+		t[label] = threshold
+	}
+	return t, nil
+}
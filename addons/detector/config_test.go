@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"testing"
+	"time"
+
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/monitor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		_, enable, err := parseConfig(monitor.NewConfig(monitor.RawConfig{}))
+		require.NoError(t, err)
+		require.False(t, enable)
+	})
+	t.Run("minimal", func(t *testing.T) {
+		c := monitor.NewConfig(monitor.RawConfig{
+			"id":             "1",
+			"detectorEnable": "true",
+		})
+		actual, enable, err := parseConfig(c)
+		require.NoError(t, err)
+		require.True(t, enable)
+
+		expected := &config{
+			monitorID:   "1",
+			backend:     "deepstack",
+			thresholds:  thresholds{},
+			feedRate:    "1",
+			duration:    time.Second,
+			recDuration: 120 * time.Second,
+		}
+		require.Equal(t, expected, actual)
+	})
+	t.Run("maximal", func(t *testing.T) {
+		c := monitor.NewConfig(monitor.RawConfig{
+			"id":               "1",
+			"logLevel":         "2",
+			"hwaccel":          "3",
+			"timestampOffset":  "500",
+			"detectorEnable":   "true",
+			"detectorBackend":  "4",
+			"detectorLabels":   "person:60, car",
+			"detectorZones":    "[[[0,0],[50,0],[50,50]]]",
+			"detectorFeedRate": "2",
+			"detectorDuration": "30",
+		})
+		actual, enable, err := parseConfig(c)
+		require.NoError(t, err)
+		require.True(t, enable)
+
+		expected := &config{
+			monitorID:       "1",
+			logLevel:        "2",
+			hwaccel:         "3",
+			timestampOffset: 500 * time.Millisecond,
+			backend:         "4",
+			thresholds:      thresholds{"person": 60, "car": 50},
+			zones:           []ffmpeg.Polygon{{{0, 0}, {50, 0}, {50, 50}}},
+			feedRate:        "2",
+			duration:        500 * time.Millisecond,
+			recDuration:     30 * time.Second,
+		}
+		require.Equal(t, expected, actual)
+	})
+	t.Run("invalidFeedRate", func(t *testing.T) {
+		c := monitor.NewConfig(monitor.RawConfig{
+			"detectorEnable":   "true",
+			"detectorFeedRate": "0",
+		})
+		_, _, err := parseConfig(c)
+		require.ErrorIs(t, err, ErrInvalidFeedRate)
+	})
+	t.Run("invalidZones", func(t *testing.T) {
+		c := monitor.NewConfig(monitor.RawConfig{
+			"detectorEnable": "true",
+			"detectorZones":  "nil",
+		})
+		_, _, err := parseConfig(c)
+		require.Error(t, err)
+	})
+}
+
+func TestParseThresholds(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected thresholds
+		err      error
+	}{
+		{"", thresholds{}, nil},
+		{"person", thresholds{"person": 50}, nil},
+		{"person:70,car:30", thresholds{"person": 70, "car": 30}, nil},
+		{" person : 70 , ", thresholds{"person": 70}, nil},
+		{"person:x", nil, ErrInvalidThreshold},
+		{"person:101", nil, ErrInvalidThreshold},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := parseThresholds(tc.input)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestThresholdsAccept(t *testing.T) {
+	require.True(t, thresholds{}.accept("x", 50))
+	require.False(t, thresholds{}.accept("x", 49))
+	require.True(t, thresholds{"person": 70}.accept("person", 70))
+	require.False(t, thresholds{"person": 70}.accept("person", 69))
+	require.False(t, thresholds{"person": 70}.accept("car", 99))
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"nvr/pkg/monitor"
+	"strings"
+)
+
+func init() {
+	RegisterBackend("deepstack", newDeepstack)
+}
+
+// deepstack is a remote inference server that implements the DeepStack
+// detection API, this includes CodeProject.AI Server.
+//
+// POST /v1/vision/detection with a multipart "image" field.
+type deepstack struct {
+	client *http.Client
+	url    string
+}
+
+// ErrURLMissing detector URL missing.
+var ErrURLMissing = errors.New("detector url missing")
+
+func newDeepstack(c monitor.Config) (Backend, error) {
+	rawURL := strings.TrimSpace(c.Get("detectorUrl"))
+	if rawURL == "" {
+		return nil, ErrURLMissing
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	return &deepstack{
+		client: &http.Client{},
+		url:    strings.TrimSuffix(u.String(), "/") + "/v1/vision/detection",
+	}, nil
+}
+
+type deepstackResponse struct {
+	Success     bool   `json:"success"`
+	Error       string `json:"error"`
+	Predictions []struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+		XMin       int     `json:"x_min"`
+		YMin       int     `json:"y_min"`
+		XMax       int     `json:"x_max"`
+		YMax       int     `json:"y_max"`
+	} `json:"predictions"`
+}
+
+// ErrDetectionFailed server returned an error.
+var ErrDetectionFailed = errors.New("detection failed")
+
+func (d *deepstack) Detect(ctx context.Context, img image.Image) ([]Object, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("image", "frame.jpg")
+	if err != nil {
+		return nil, err
+	}
+	if err := jpeg.Encode(fw, img, nil); err != nil {
+		return nil, fmt.Errorf("encode frame: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %v", ErrDetectionFailed, res.Status)
+	}
+
+	var response deepstackResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %v", ErrDetectionFailed, response.Error)
+	}
+
+	width := float64(img.Bounds().Dx())
+	height := float64(img.Bounds().Dy())
+
+	objects := make([]Object, 0, len(response.Predictions))
+	for _, p := range response.Predictions {
+		objects = append(objects, Object{
+			Label:  p.Label,
+			Score:  p.Confidence * 100,
+			Top:    float64(p.YMin) / height,
+			Left:   float64(p.XMin) / width,
+			Bottom: float64(p.YMax) / height,
+			Right:  float64(p.XMax) / width,
+		})
+	}
+	return objects, nil
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"context"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nvr/pkg/monitor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepstack(t *testing.T) {
+	newTestBackend := func(t *testing.T, handler http.HandlerFunc) Backend {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		b, err := newDeepstack(monitor.NewConfig(monitor.RawConfig{
+			"detectorUrl": server.URL + "/",
+		}))
+		require.NoError(t, err)
+		return b
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+
+	t.Run("ok", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/vision/detection", r.URL.Path)
+
+			file, _, err := r.FormFile("image")
+			require.NoError(t, err)
+			frame, err := jpeg.Decode(file)
+			require.NoError(t, err)
+			require.Equal(t, img.Bounds(), frame.Bounds())
+
+			w.Write([]byte(`{"success":true,"predictions":[{"label":"person",` +
+				`"confidence":0.5,"x_min":20,"y_min":10,"x_max":100,"y_max":50}]}`))
+		})
+
+		objects, err := b.Detect(context.Background(), img)
+		require.NoError(t, err)
+
+		expected := []Object{{
+			Label:  "person",
+			Score:  50,
+			Top:    0.1,
+			Left:   0.1,
+			Bottom: 0.5,
+			Right:  0.5,
+		}}
+		require.Equal(t, expected, objects)
+	})
+	t.Run("unsuccessful", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"success":false,"error":"x"}`))
+		})
+		_, err := b.Detect(context.Background(), img)
+		require.ErrorIs(t, err, ErrDetectionFailed)
+	})
+	t.Run("status", func(t *testing.T) {
+		b := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		_, err := b.Detect(context.Background(), img)
+		require.ErrorIs(t, err, ErrDetectionFailed)
+	})
+	t.Run("urlMissing", func(t *testing.T) {
+		_, err := newDeepstack(monitor.NewConfig(monitor.RawConfig{}))
+		require.ErrorIs(t, err, ErrURLMissing)
+	})
+}
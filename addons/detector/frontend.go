@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func modifyTemplates(pageFiles map[string]string) error {
+	js, exists := pageFiles["settings.js"]
+	if !exists {
+		return fmt.Errorf("detector: settings.js %w", os.ErrNotExist)
+	}
+
+	tpl, err := modifySettingsjs(js, backendNames())
+	if err != nil {
+		return fmt.Errorf("detector: %w", err)
+	}
+	pageFiles["settings.js"] = tpl
+	return nil
+}
+
+func modifySettingsjs(tpl string, backends []string) (string, error) {
+	rawBackends, err := json.Marshal(backends)
+	if err != nil {
+		return "", err
+	}
+
+	fields := `detectorEnable: fieldTemplate.toggle("Object detection", "false"),
+		detectorBackend: fieldTemplate.select("Detection backend", ` + string(rawBackends) + `, "deepstack"),
+		detectorUrl: fieldTemplate.text("Detection server URL", "http://127.0.0.1:32168"),
+		detectorLabels: fieldTemplate.text("Detection labels", "person:60,car:70", "person"),
+		detectorZones: fieldTemplate.text("Detection zones", "[[[0,0],[100,0],[100,100],[0,100]]]"),
+		detectorFeedRate: fieldTemplate.text("Detection feed rate (fps)", "1", "1"),
+		detectorDuration: fieldTemplate.integer("Detection trigger duration (sec)", "120", "120"),
+		`
+
+	const target = "logLevel: fieldTemplate.select("
+	return strings.ReplaceAll(tpl, target, fields+target), nil
+}
@@ -444,6 +444,7 @@ func (i *instance) runReader(ctx context.Context, stdout io.Reader) error {
 
 		err = i.sendEvent(storage.Event{
 			Time:        t,
+			Trigger:     storage.TriggerObject,
 			Detections:  parsed,
 			Duration:    eventDuration,
 			RecDuration: i.c.recDuration,
@@ -367,6 +367,7 @@ func TestRunInstance(t *testing.T) {
 		actual := event
 
 		expected := storage.Event{
+			Trigger: storage.TriggerObject,
 			Detections: []storage.Detection{{
 				Label:  "1",
 				Region: &storage.Region{Rect: &ffmpeg.Rect{}},
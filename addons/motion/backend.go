@@ -242,8 +242,9 @@ func (d detector) runFrameReader(stdout io.Reader) error {
 		d.logf(log.LevelDebug, "detection: zone:%v score:%.2f", zone, score)
 		t := time.Now().Add(-d.config.timestampOffset)
 		d.sendEvent(storage.Event{ //nolint:errcheck
+			Trigger: storage.TriggerMotion,
 			Detections: []storage.Detection{
-				{Score: score},
+				{Score: score, Zone: strconv.Itoa(zone)},
 			},
 			Time:        t,
 			Duration:    d.config.duration,
@@ -30,7 +30,7 @@ func init() {
 	nvr.RegisterAppRunHook(func(_ context.Context, app *nvr.App) error {
 		app.Router.Handle(
 			"/api/recording/timeline/",
-			app.Auth.User(handleTimeline(app.Env.RecordingsDir())),
+			app.Auth.User(handleTimeline(app.Env.RecordingsDirs())),
 		)
 		app.Router.Handle(
 			"/timeline",
@@ -44,7 +44,7 @@ func init() {
 	})
 }
 
-func handleTimeline(recordingsDir string) http.Handler {
+func handleTimeline(recordingsDirs []string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
@@ -58,6 +58,7 @@ func handleTimeline(recordingsDir string) http.Handler {
 			return
 		}
 
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, timelinePath)
 		path := filepath.Join(recordingsDir, timelinePath+".timeline")
 
 		// ServeFile will sanitize ".."
@@ -4,19 +4,37 @@ package nvr
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"nvr/pkg/addon"
+	"nvr/pkg/crypt"
+	"nvr/pkg/export"
+	"nvr/pkg/feed"
+	"nvr/pkg/ffmpeg"
 	"nvr/pkg/group"
+	"nvr/pkg/health"
+	"nvr/pkg/ingest"
+	"nvr/pkg/kv"
 	"nvr/pkg/log"
 	"nvr/pkg/monitor"
+	"nvr/pkg/rpc"
+	"nvr/pkg/secret"
+	"nvr/pkg/share"
+	"nvr/pkg/speedtest"
 	"nvr/pkg/storage"
 	"nvr/pkg/system"
+	"nvr/pkg/transcode"
+	"nvr/pkg/update"
 	"nvr/pkg/video"
 	"nvr/pkg/web"
 	"nvr/pkg/web/auth"
+	"nvr/pkg/zones"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -24,6 +42,8 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 // Run .
@@ -42,16 +62,17 @@ func Run() error {
 	}
 
 	wg := &sync.WaitGroup{}
-	app, err := newApp(envPath, wg, hooks)
+	app, err := NewApp(envPath, wg)
 	if err != nil {
 		return err
 	}
+	defer app.DB.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	fatal := make(chan error, 1)
-	go func() { fatal <- app.run(ctx) }()
+	go func() { fatal <- app.Start(ctx) }()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
@@ -62,10 +83,12 @@ func Run() error {
 	case signal := <-stop:
 		fmt.Println("") // New line.
 		app.logf(log.LevelInfo, "received %v, stopping", signal)
+	case <-app.updater.Restart():
+		// The start script applies or rolls back the release.
+		app.logf(log.LevelInfo, "restarting to apply update")
 	}
 
-	app.monitorManager.StopMonitors()
-	app.logf(log.LevelInfo, "Monitors stopped.")
+	app.StopMonitors()
 
 	cancel()
 	wg.Wait()
@@ -76,24 +99,92 @@ func Run() error {
 	if err != nil {
 		return err
 	}
-	return app.server.Shutdown(ctx2)
+	return app.Shutdown(ctx2)
+}
+
+// NewApp creates the app with the registered addons, the
+// wait group is done when the app has stopped.
+// Used by Run and the integration test harness.
+func NewApp(envPath string, wg *sync.WaitGroup) (*App, error) {
+	return newApp(envPath, wg, hooks)
+}
+
+// Start starts the app and blocks until the main server is
+// stopped. Returns http.ErrServerClosed after Shutdown.
+func (app *App) Start(ctx context.Context) error {
+	return app.run(ctx)
+}
+
+// StopMonitors stops all monitors and waits for them to exit.
+// The recordings in progress are finished and saved.
+func (app *App) StopMonitors() {
+	app.monitorManager.StopMonitors()
+	app.logf(log.LevelInfo, "Monitors stopped.")
+}
+
+// Shutdown gracefully shuts down the servers.
+func (app *App) Shutdown(ctx context.Context) error {
+	app.serversMu.Lock()
+	if app.redirectServer != nil {
+		app.redirectServer.Shutdown(ctx) //nolint:errcheck
+	}
+	if app.grpcServer != nil {
+		// Streams never finish, don't wait for them.
+		app.grpcServer.Stop()
+	}
+	app.serversMu.Unlock()
+	return app.server.Shutdown(ctx)
 }
 
 // App is the main application.
 type App struct {
 	WG             *sync.WaitGroup
 	Logger         *log.Logger
+	DB             *kv.DB
+	Zones          *zones.Store
 	logStore       *log.Store
+	logPromoter    *log.Promoter
+	logForwarders  []*log.Forwarder
+	logHistory     *feed.Buffer[log.Entry]
+	eventsFeed     *feed.Buffer[web.FeedMessage]
 	Env            storage.ConfigEnv
+	addons         *addon.Manager
 	monitorManager *monitor.Manager
+	health         *health.Checker
 	Auth           auth.Authenticator
 	Storage        *storage.Manager
+	verifier       *storage.Verifier
+	scrubber       *storage.Scrubber
+	exports        *export.Manager
+	updater        *update.Updater
+	rpcService     *rpc.Server
 	videoServer    *video.Server
 	Templater      *web.Templater
 	Router         *http.ServeMux
 	server         *http.Server
+	redirectServer *http.Server
+	grpcServer     *grpc.Server
+
+	// Guards the servers that are started by run.
+	serversMu sync.Mutex
 }
 
+// Components that must be started before the app is ready.
+const (
+	healthStorage     = "storage"
+	healthVideoServer = "video server"
+	healthMonitors    = "monitors"
+)
+
+// Interval of the heartbeats of the subsystems.
+const healthInterval = 10 * time.Second
+
+// Number of recent log entries kept for clients that resume the log feed.
+const logHistorySize = 1000
+
+// Number of recent messages kept for clients that resume the events feed.
+const eventsFeedSize = 512
+
 func newApp(envPath string, wg *sync.WaitGroup, hooks *hookList) (*App, error) { //nolint:funlen
 	// Environment config.
 	envYAML, err := os.ReadFile(envPath)
@@ -111,29 +202,106 @@ func newApp(envPath string, wg *sync.WaitGroup, hooks *hookList) (*App, error) {
 		return nil, fmt.Errorf("could not get general config: %w", err)
 	}
 
+	// Shared database of the subsystems.
+	db, err := kv.Open(filepath.Join(env.StorageDir, "nvr.db"))
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+
 	// Logs.
 	logDir := filepath.Join(env.StorageDir, "logs")
-	logger := log.NewLogger(wg, hooks.logSource)
+	logSources := append([]string(nil), hooks.logSource...)
+	logSources = append(logSources, addon.Names()...)
+	logger := log.NewLogger(wg, logSources)
+	if err := logger.LoadLevels(db, filepath.Join(env.ConfigDir, "log-levels.json")); err != nil {
+		return nil, fmt.Errorf("could not load log levels: %w", err)
+	}
 	logStore, err := log.NewStore(logDir, wg, general.DiskSpace)
 	if err != nil {
 		return nil, fmt.Errorf("could not create log store: %w", err)
 	}
 
+	logPromoter, err := log.NewPromoter(env.LogEventRules)
+	if err != nil {
+		return nil, err
+	}
+	var logForwarders []*log.Forwarder
+	for _, config := range env.LogForward {
+		forwarder, err := log.NewForwarder(config)
+		if err != nil {
+			return nil, err
+		}
+		logForwarders = append(logForwarders, forwarder)
+	}
+	logHistory := feed.NewBuffer[log.Entry](logHistorySize)
+	eventsFeed := feed.NewBuffer[web.FeedMessage](eventsFeedSize)
+
+	// Addons.
+	addons, err := addon.NewManager(env.ConfigDir, *env, logger)
+	if err != nil {
+		return nil, fmt.Errorf("could not create addon manager: %w", err)
+	}
+
 	// Video server.
 	videoServer := video.NewServer(logger, wg, *env)
 
 	// Monitors.
+	secrets, err := secret.NewCipher(env.SecretStore, env.SecretKeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("could not load secret key: %w", err)
+	}
+	recordingKeys, err := crypt.LoadKeyring(
+		env.RecordingEncryption.KeyFile, env.RecordingEncryption.KeyCommand)
+	if err != nil {
+		return nil, fmt.Errorf("could not load recording encryption keys: %w", err)
+	}
+	crypt.SetKeyring(recordingKeys)
+	transcoders := monitor.ProbeTranscoders(*env)
 	monitorConfigDir := filepath.Join(env.ConfigDir, "monitors")
 	monitorManager, err := monitor.NewManager(
 		monitorConfigDir,
 		*env,
+		general,
 		logger,
 		videoServer,
-		hooks.monitor(),
+		transcoders,
+		secrets,
+		hooks.monitor(addons),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not create monitor manager: %w", err)
 	}
+	monitorTemplates, err := monitor.NewTemplates(
+		filepath.Join(env.ConfigDir, "monitor-templates"), secrets)
+	if err != nil {
+		return nil, fmt.Errorf("could not load monitor templates: %w", err)
+	}
+	monitorCredentials, err := monitor.NewCredentials(
+		filepath.Join(env.ConfigDir, "credentials"), secrets)
+	if err != nil {
+		return nil, fmt.Errorf("could not load camera credentials: %w", err)
+	}
+	monitorManager.SetCredentials(monitorCredentials)
+	privacyMasks, err := monitor.NewPrivacyMasks(filepath.Join(env.ConfigDir, "privacy-masks"))
+	if err != nil {
+		return nil, fmt.Errorf("could not load privacy masks: %w", err)
+	}
+	monitorManager.SetPrivacyMasks(privacyMasks)
+
+	// Detection zones of the monitors, shared by the detectors and addons.
+	zoneStore := zones.NewStore(db)
+
+	shares, err := share.NewManager(db)
+	if err != nil {
+		return nil, fmt.Errorf("could not create share manager: %w", err)
+	}
+
+	// Recordings uploaded by edge devices.
+	ingests, err := ingest.NewManager(
+		db, monitorManager.RecordingsDir, env.FFmpegBin, env.RecordingEncryption.PlainThumbnails)
+	if err != nil {
+		return nil, fmt.Errorf("could not create ingest manager: %w", err)
+	}
 
 	// Monitor groups.
 	groupConfigDir := filepath.Join(env.ConfigDir, "groups")
@@ -153,9 +321,29 @@ func newApp(envPath string, wg *sync.WaitGroup, hooks *hookList) (*App, error) {
 		return nil, fmt.Errorf("could not create authenticator: %w", err)
 	}
 
+	// User preferences.
+	preferences, err := web.NewPreferences(db, filepath.Join(env.ConfigDir, "preferences"))
+	if err != nil {
+		return nil, fmt.Errorf("could not create preferences: %w", err)
+	}
+
 	// Storage.
-	storageManager := storage.NewManager(env.StorageDir, general, logger)
-	crawler := storage.NewCrawler(os.DirFS(storageManager.RecordingsDir()))
+	storageManager := storage.NewManager(env.StorageDir, env.StorageVolumes, general, logger)
+	crawler := storage.NewCrawler(storageManager.RecordingsFS())
+	verifier := storage.NewVerifier(env.RecordingsDirs(), env.FFmpegBin, logger)
+	scrubber := storage.NewScrubber(env.RecordingsDirs(), logger)
+	monitorManager.Volumes().SetAlerts(storageManager.DiskAlerts())
+
+	// Transcode profiles.
+	transcodeConfigDir := filepath.Join(env.ConfigDir, "transcode-profiles")
+	transcodeProfiles, err := transcode.NewManager(transcodeConfigDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not create transcode profile manager: %w", err)
+	}
+
+	// Exports.
+	exports := export.NewManager(
+		env.RecordingsDirs(), env.TempDir, env.FFmpegBin, transcodeProfiles, logger)
 
 	// Time zone.
 	timeZone, err := system.TimeZone()
@@ -164,7 +352,8 @@ func newApp(envPath string, wg *sync.WaitGroup, hooks *hookList) (*App, error) {
 	}
 
 	// Templates.
-	t, err := web.NewTemplater(a, hooks.tplHooks())
+	addonTemplates := addons.Templates()
+	t, err := web.NewTemplater(a, hooks.tplHooks(addonTemplates))
 	if err != nil {
 		return nil, err
 	}
@@ -189,74 +378,240 @@ func newApp(envPath string, wg *sync.WaitGroup, hooks *hookList) (*App, error) {
 		},
 	)
 	t.RegisterTemplateDataFuncs(hooks.templateData...)
+	t.RegisterTemplateDataFuncs(addonTemplates.DataFuncs()...)
+
+	updater := update.NewUpdater(
+		env.HomeDir,
+		update.Config{URL: env.Update.URL, PublicKey: env.Update.Key()},
+		http.DefaultClient,
+		logger,
+	)
+
+	liveSessions := web.NewLiveSessions(env.LiveSessions)
+
+	// Health checks.
+	healthChecker := health.NewChecker(healthStorage, healthVideoServer, healthMonitors)
+	healthChecker.AddCheck(healthStorage, monitorManager.Volumes().Writable)
 
 	// Routes.
 	router := http.NewServeMux()
 
+	router.Handle("/healthz", web.HealthCheck(healthChecker.Live))
+	router.Handle("/readyz", web.HealthCheck(healthChecker.Ready))
+
 	router.Handle("/live", a.User(t.Render("live.tpl")))
 	router.Handle("/recordings", a.User(t.Render("recordings.tpl")))
 	router.Handle("/settings", a.User(t.Render("settings.tpl")))
 	router.Handle("/settings.js", a.User(t.Render("settings.js")))
 	router.Handle("/logs", a.Admin(t.Render("logs.tpl")))
 	router.Handle("/debug", a.Admin(t.Render("debug.tpl")))
+	router.Handle("/password", a.User(t.Render("password.tpl")))
 
 	router.Handle("/static/", a.User(web.Static()))
-	router.Handle("/hls/", a.User(videoServer.HandleHLS()))
-
-	router.Handle("/api/system/time-zone", a.User(web.TimeZone(timeZone)))
-
-	router.Handle("/api/general", a.Admin(web.General(general)))
-	router.Handle("/api/general/set", a.Admin(a.CSRF(web.GeneralSet(general))))
-
-	router.Handle("/api/users", a.Admin(web.Users(a)))
-	router.Handle("/api/user/set", a.Admin(a.CSRF(web.UserSet(a))))
-	router.Handle("/api/user/delete", a.Admin(a.CSRF(web.UserDelete(a))))
-	router.Handle("/api/user/my-token", a.Admin(a.MyToken()))
+	router.Handle("/hls/", a.User(liveSessions.HLS(a, videoServer.HandleHLS())))
+
+	api := web.NewAPI(router, a)
+	api.Handle("/api/spec", web.APISpec())
+	api.Handle("/api/system/time-zone", web.TimeZone(timeZone))
+	api.Handle("/api/system/transcoders", web.Transcoders(transcoders))
+	api.Handle("/api/system/backup", web.SystemBackup(logger, env.ConfigDir))
+	api.Handle("/api/system/restore", web.SystemRestore(logger, env.ConfigDir))
+	api.Handle("/api/system/version", web.SystemVersion(updater.Info))
+	api.Handle("/api/system/update", web.SystemUpdate(logger, updater.Update))
+	api.Handle("/api/system/status", web.PublicStatus(*env, monitorManager, storageManager))
+	api.Handle("/api/storage/age-report", web.StorageAgeReport(storageManager.AgeReport))
+	api.Handle("/api/storage/forecast", web.StorageForecast(storageManager.Forecast))
+	api.Handle("/api/storage/verify", web.StorageVerify(verifier.Trigger))
+	api.Handle("/api/storage/verify/status", web.StorageVerifyStatus(verifier.Report))
+	api.Handle("/api/storage/maintenance", web.StorageMaintenance(scrubber.Trigger))
+	api.Handle("/api/storage/maintenance/status", web.StorageMaintenanceStatus(scrubber.Report))
+
+	api.Handle("/api/general", web.General(general))
+	api.Handle("/api/general/set", web.GeneralSet(general))
+
+	api.Handle("/api/users", web.Users(a))
+	api.Handle("/api/user/set", web.UserSet(a))
+	api.Handle("/api/user/delete", web.UserDelete(a, preferences))
+	api.Handle("/api/user/reset", web.UserReset(a))
+	api.Handle("/api/user/password", web.UserPassword(a))
+	api.Handle("/api/user/preferences", web.UserPreferencesGet(a, preferences))
+	api.Handle("/api/user/preferences/set", web.UserPreferencesSet(a, preferences))
+	api.Handle("/api/user/impersonate", web.UserImpersonate(a))
+	api.Handle("/api/user/impersonate/stop", web.UserImpersonateStop(a))
+	api.Handle("/api/user/lockouts", web.UserLockouts(a))
+	api.Handle("/api/user/lockouts/clear", web.UserLockoutClear(a))
+	api.Handle("/api/user/my-token", a.MyToken())
+	api.Handle("/api/user/live-sessions", web.LiveSessionList(liveSessions))
+	api.Handle("/api/streams", web.Streams(liveSessions, videoServer))
+	api.Handle("/api/streams/kick", web.StreamKick(liveSessions, videoServer))
 	router.Handle("/logout", a.Logout())
 
-	router.Handle("/api/monitor/configs", a.Admin(web.MonitorConfigs(monitorManager)))
-	router.Handle("/api/monitor/delete", a.Admin(a.CSRF(web.MonitorDelete(monitorManager))))
-	router.Handle("/api/monitor/list", a.User(web.MonitorList(monitorManager.MonitorsInfo)))
-	router.Handle("/api/monitor/restart", a.Admin(a.CSRF(web.MonitorRestart(monitorManager))))
-	router.Handle("/api/monitor/set", a.Admin(a.CSRF(web.MonitorSet(monitorManager))))
-
-	router.Handle("/api/group/configs", a.User(web.GroupConfigs(groupManager)))
-	router.Handle("/api/group/set", a.Admin(a.CSRF(web.GroupSet(groupManager))))
-	router.Handle("/api/group/delete", a.Admin(a.CSRF(web.GroupDelete(groupManager))))
-
-	router.Handle("/api/recording/delete/", a.Admin(a.CSRF(web.RecordingDelete(env.RecordingsDir()))))
-	router.Handle("/api/recording/thumbnail/", a.User(web.RecordingThumbnail(env.RecordingsDir())))
-	router.Handle("/api/recording/video/", a.User(web.RecordingVideo(logger, env.RecordingsDir())))
-	router.Handle("/api/recording/query", a.User(web.RecordingQuery(crawler, logger)))
+	api.Handle("/api/monitor/arm", web.MonitorArm(monitorManager))
+	api.Handle("/api/monitor/arm-state", web.MonitorArmState(monitorManager))
+	api.Handle("/api/monitor/camera-motion", web.MonitorCameraMotion(monitorManager))
+	api.Handle("/api/monitor/camera-motion/set", web.MonitorCameraMotionSet(monitorManager))
+	api.Handle("/api/monitor/configs", web.MonitorConfigs(monitorManager))
+	api.Handle("/api/monitor/delete", web.MonitorDelete(monitorManager))
+	api.Handle("/api/monitor/snapshot", web.MonitorSnapshot(monitorManager))
+	api.Handle("/api/monitor/talkback", web.MonitorTalkback(a, monitorManager))
+	api.Handle("/api/monitor/events", web.MonitorEvents(monitorManager, a))
+	api.Handle("/api/monitor/events/poll", web.MonitorEventsPoll(monitorManager, a))
+	api.Handle("/api/monitor/health", web.MonitorHealth(monitorManager))
+	api.Handle("/api/monitor/renditions", web.MonitorRenditions(videoServer))
+	api.Handle("/api/monitor/stats", web.MonitorStats(monitorManager))
+	api.Handle("/api/monitor/maintenance", web.MonitorMaintenance(monitorManager))
+	api.Handle("/api/monitor/maintenance-state", web.MonitorMaintenanceState(monitorManager))
+	api.Handle("/api/monitor/list", web.MonitorList(monitorManager.MonitorsInfo))
+	api.Handle("/api/monitor/restart", web.MonitorRestart(monitorManager))
+	api.Handle("/api/monitor/enable", web.MonitorEnable(monitorManager))
+	api.Handle("/api/monitor/set", web.MonitorSet(monitorManager))
+	api.Handle("/api/monitor/clone", web.MonitorClone(monitorManager))
+	api.Handle("/api/monitor/templates", web.MonitorTemplates(monitorTemplates))
+	api.Handle("/api/monitor/template/set", web.MonitorTemplateSet(monitorTemplates))
+	api.Handle("/api/monitor/template/delete", web.MonitorTemplateDelete(monitorTemplates))
+	api.Handle("/api/monitor/template/apply", web.MonitorTemplateApply(monitorManager, monitorTemplates))
+	api.Handle("/api/monitor/credentials", web.MonitorCredentials(monitorCredentials))
+	api.Handle("/api/monitor/credential/set", web.MonitorCredentialSet(monitorCredentials))
+	api.Handle("/api/monitor/credential/delete", web.MonitorCredentialDelete(monitorManager, monitorCredentials))
+	api.Handle("/api/monitor/test", web.MonitorTest(
+		monitor.NewStreamTester(env.FFmpegBin, monitorCredentials)))
+	api.Handle("/api/monitor/privacy-mask", web.MonitorPrivacyMask(privacyMasks))
+	api.Handle("/api/monitor/privacy-mask/set", web.MonitorPrivacyMaskSet(monitorManager, privacyMasks, a))
+	api.Handle("/api/monitor/zones", web.MonitorZones(zoneStore))
+	api.Handle("/api/monitor/zone/set", web.MonitorZoneSet(monitorManager, zoneStore))
+	api.Handle("/api/monitor/zone/delete", web.MonitorZoneDelete(zoneStore))
+
+	api.Handle("/api/video/paths", web.VideoPaths(videoServer))
+	api.Handle("/api/video/hls-memory", web.VideoHLSMemory(videoServer))
+	streamRecommender := web.NewStreamRecommender(
+		speedtest.NewStore(), a, monitorManager, videoServer)
+	api.Handle("/api/live/", web.LiveMSE(videoServer, streamRecommender, liveSessions, a))
+	api.Handle("/api/speedtest", web.SpeedTest(streamRecommender))
+	api.Handle("/api/speedtest/recommend", web.SpeedTestRecommend(streamRecommender))
+
+	api.Handle("/api/group/configs", web.GroupConfigs(groupManager))
+	api.Handle("/api/group/set", web.GroupSet(groupManager))
+	api.Handle("/api/group/delete", web.GroupDelete(groupManager))
+	api.Handle("/api/group/action", web.GroupAction(groupManager, monitorManager))
+	api.Handle("/api/group/", web.GroupRollup(groupManager, crawler, logger))
+
+	api.Handle("/api/recording", web.RecordingDelete(env.RecordingsDirs()))
+	api.Handle("/api/recording/delete/", web.RecordingDelete(env.RecordingsDirs()))
+	api.Handle("/api/recording/protect", web.RecordingProtect(env.RecordingsDirs()))
+	api.Handle("/api/recording/thumbnail/", web.RecordingThumbnail(env.RecordingsDirs()))
+	videoCache := storage.NewVideoCache()
+	recordingVideo := web.RecordingVideo(logger, env.RecordingsDirs(), videoCache)
+	api.Handle("/api/recording/video/", recordingVideo)
+	api.Handle("/api/recording/playback/", web.RecordingPlayback(
+		logger, env.RecordingsDirs(), videoCache, ffmpeg.New(env.FFmpegBin)))
+	api.Handle("/api/recording/index/", web.RecordingIndex(logger, env.RecordingsDirs(), videoCache))
+	api.Handle("/api/recording/vod/", web.RecordingVOD(logger, env.RecordingsDirs(), videoCache))
+	api.Handle("/api/recording/download", web.RecordingDownload(logger, env.RecordingsDirs(), videoCache))
+	api.Handle("/api/share/create", web.ShareCreate(shares, monitorManager, env.RecordingsDirs(), a))
+	api.Handle("/api/share/links", web.ShareLinks(shares))
+	api.Handle("/api/share/revoke", web.ShareRevoke(shares, a, logger))
+	router.Handle("/share/", web.Share(shares, logger, recordingVideo, videoServer.HandleHLS()))
+	api.Handle("/api/ingest/presign", web.IngestPresign(ingests, monitorManager, a, logger))
+	api.Handle("/api/ingest/tokens", web.IngestTokens(ingests))
+	api.Handle("/api/ingest/revoke", web.IngestRevoke(ingests, a, logger))
+	router.Handle("/ingest/", web.Ingest(ingests, logger))
+
+	api.Handle("/api/events/feed", web.EventsFeed(eventsFeed, a))
+	api.Handle("/api/events/feed/poll", web.EventsFeedPoll(eventsFeed, a))
+	api.Handle("/api/events/", web.EventMedia(
+		logger, env.EventClipsDir(), env.EventSnapshotsDir(), videoCache))
+	api.Handle("/api/events/snapshots", web.EventSnapshots(env.EventSnapshotsDir()))
+	api.Handle("/api/recording/query", web.RecordingQuery(crawler, logger))
+	api.Handle("/api/recording/activity", web.RecordingActivity(logger, env.RecordingsDirs()))
+	api.Handle("/api/transcode/profiles", web.TranscodeProfiles(transcodeProfiles))
+	api.Handle("/api/transcode/profile/set", web.TranscodeProfileSet(transcodeProfiles))
+	api.Handle("/api/transcode/profile/delete", web.TranscodeProfileDelete(transcodeProfiles))
+
+	api.Handle("/api/recording/export", web.RecordingExport(exports))
+	api.Handle("/api/recording/summary", web.RecordingSummary(exports))
+	api.Handle("/api/recording/export/status", web.RecordingExportStatus(exports))
+	api.Handle("/api/recording/export/file", web.RecordingExportFile(exports))
+
+	api.Handle("/api/log/feed", web.LogFeed(logHistory, a))
+	api.Handle("/api/log/feed/poll", web.LogFeedPoll(logHistory, a))
+	api.Handle("/api/log/query", web.LogQuery(logStore))
+	api.Handle("/api/log/search", web.LogSearch(logStore))
+	api.Handle("/api/log/sources", web.LogSources(logger))
+	api.Handle("/api/log/level", web.LogLevel(logger))
+
+	api.Handle("/api/addons", addons.HandleList())
+	api.Handle("/api/addons/set", addons.HandleSet())
+	addons.RegisterRoutes(router, a)
+
+	// gRPC API.
+	rpcService := rpc.NewServer(
+		monitorManager.MonitorsInfo,
+		groupManager,
+		crawler,
+		monitorManager.EventHistory(),
+		logHistory,
+		logStore,
+		logger,
+	)
 
-	router.Handle("/api/log/feed", a.Admin(web.LogFeed(logger, a)))
-	router.Handle("/api/log/query", a.Admin(web.LogQuery(logStore)))
-	router.Handle("/api/log/sources", a.Admin(web.LogSources(logger)))
+	// Main server.
+	handler := web.CORS(env.CORS, router)
+	handler = web.AccessLog(env.AccessLog, a, logger, handler)
+	handler = web.BasePath(env.BasePath, handler)
+	handler = web.ProxyHeaders(env.TrustedProxies, handler)
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(env.Port),
+		Handler: handler,
+	}
 
 	return &App{
 		WG:             wg,
 		Logger:         logger,
+		DB:             db,
+		Zones:          zoneStore,
 		logStore:       logStore,
+		logPromoter:    logPromoter,
+		logForwarders:  logForwarders,
+		logHistory:     logHistory,
+		eventsFeed:     eventsFeed,
 		Env:            *env,
+		addons:         addons,
 		monitorManager: monitorManager,
+		health:         healthChecker,
 		Auth:           a,
 		Storage:        storageManager,
+		verifier:       verifier,
+		scrubber:       scrubber,
+		exports:        exports,
+		updater:        updater,
+		rpcService:     rpcService,
 		videoServer:    videoServer,
 		Templater:      t,
 		Router:         router,
+		server:         server,
 	}, nil
 }
 
 func (app *App) run(ctx context.Context) error {
-	// Main server.
-	address := ":" + strconv.Itoa(app.Env.Port)
-	app.server = &http.Server{Addr: address, Handler: app.Router}
-
 	if err := app.Logger.Start(ctx); err != nil {
 		return fmt.Errorf("could not start logger: %w", err)
 	}
 
-	app.Logger.LogToWriter(ctx, os.Stdout)
+	if app.Env.LogFormat == log.FormatJSON {
+		app.Logger.LogJSONToWriter(ctx, os.Stdout)
+	} else {
+		app.Logger.LogToWriter(ctx, os.Stdout)
+	}
+	for _, forwarder := range app.logForwarders {
+		go forwarder.Run(ctx, app.Logger)
+	}
+	app.Logger.LogToBuffer(ctx, app.logHistory)
+	web.ForwardEventsFeed(ctx, app.eventsFeed, web.EventsFeedSources{
+		States:  app.monitorManager.StateHistory(),
+		Events:  app.monitorManager.EventHistory(),
+		Storage: app.Storage.DiskAlerts(),
+		Logs:    app.logHistory,
+	})
 	app.logStore.SaveLogs(ctx, app.Logger)
 	app.logStore.PurgeLoop(ctx, app.Logger)
 	time.Sleep(10 * time.Millisecond)
@@ -264,25 +619,136 @@ func (app *App) run(ctx context.Context) error {
 	if err := hooks.appRun(ctx, app); err != nil {
 		return err
 	}
+	if err := app.addons.Start(ctx); err != nil {
+		return err
+	}
 
 	app.logf(log.LevelInfo, "Starting..")
 
 	if err := app.Env.PrepareEnvironment(); err != nil {
 		return fmt.Errorf("could not prepare environment: %w", err)
 	}
+	app.health.SetStarted(healthStorage)
 
 	if err := app.videoServer.Start(ctx); err != nil {
 		return fmt.Errorf("could not start video server: %w", err)
 	}
+	app.health.SetStarted(healthVideoServer)
 
-	app.monitorManager.StartMonitors()
+	// Recordings without a data file were interrupted by a crash.
+	storage.RecoverRecordings(ctx, app.Env.RecordingsDirs(), app.Logger)
+
+	go func() {
+		app.monitorManager.StartMonitors(ctx)
+		app.health.SetStarted(healthMonitors)
+	}()
+	app.watchHealth(ctx)
+	go app.logPromoter.Run(ctx, app.Logger, app.monitorManager.PublishLogEvent)
+	go app.monitorManager.RunStreamStats(ctx, time.Minute)
 
 	go app.Storage.PurgeLoop(ctx, 10*time.Minute)
+	go app.Storage.ForecastLoop(ctx, time.Hour)
+	go app.verifier.Run(ctx, time.Hour)
+	go app.scrubber.Run(ctx, 24*time.Hour)
+	go app.monitorManager.Volumes().RunFailover(ctx, time.Minute, app.monitorManager.StorageVolume)
+	go app.exports.Run(ctx)
+	go app.updater.ConfirmTrial(ctx, time.Minute, app.healthCheck)
+
+	if app.Env.TLS.Enabled() {
+		return app.serveTLS()
+	}
+
+	if err := app.serveGRPC(nil); err != nil {
+		return err
+	}
 
-	app.logf(log.LevelInfo, "Serving app on port %v", app.Env.Port)
+	app.logf(log.LevelInfo, "Serving app on port %v%v", app.Env.Port, app.Env.BasePath)
 	return app.server.ListenAndServe()
 }
 
+// watchHealth registers the heartbeats of the subsystems. The probes
+// go through the locks and loops that a deadlock would block.
+func (app *App) watchHealth(ctx context.Context) {
+	go app.health.Watch(ctx, "logger", healthInterval, func() {
+		_, cancel := app.Logger.Subscribe()
+		cancel()
+	})
+	go app.health.Watch(ctx, "monitors", healthInterval, func() {
+		app.monitorManager.MonitorsInfo()
+	})
+	go app.health.Watch(ctx, "video server", healthInterval, func() {
+		app.videoServer.PathStats()
+	})
+	go app.health.Watch(ctx, "storage", healthInterval, func() {
+		app.Storage.DiskUsageCached()
+	})
+}
+
+// healthCheck returns nil if the app is serving.
+func (app *App) healthCheck() error {
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(app.Env.Port), 5*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (app *App) serveTLS() error {
+	tlsConfig, redirect, err := web.NewTLS(app.Env.TLS, app.Env.Port, app.Logger)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	app.server.TLSConfig = tlsConfig
+
+	if err := app.serveGRPC(tlsConfig); err != nil {
+		return err
+	}
+
+	if port := app.Env.TLS.RedirectPort; port != 0 {
+		redirectServer := &http.Server{
+			Addr:              ":" + strconv.Itoa(port),
+			Handler:           redirect,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		app.serversMu.Lock()
+		app.redirectServer = redirectServer
+		app.serversMu.Unlock()
+		go func() {
+			err := redirectServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.logf(log.LevelError, "redirect server: %v", err)
+			}
+		}()
+		app.logf(log.LevelInfo, "Redirecting HTTP on port %v to HTTPS", port)
+	}
+
+	app.logf(log.LevelInfo, "Serving app over HTTPS on port %v%v", app.Env.Port, app.Env.BasePath)
+	return app.server.ListenAndServeTLS("", "")
+}
+
+// serveGRPC starts the gRPC server if it's enabled.
+func (app *App) serveGRPC(tlsConfig *tls.Config) error {
+	port := app.Env.GRPC.Port
+	if port == 0 {
+		return nil
+	}
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return fmt.Errorf("grpc: %w", err)
+	}
+	server := rpc.NewGRPCServer(app.rpcService, app.Auth, app.Env.GRPC.Tokens, tlsConfig)
+	app.serversMu.Lock()
+	app.grpcServer = server
+	app.serversMu.Unlock()
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			app.logf(log.LevelError, "grpc server: %v", err)
+		}
+	}()
+	app.logf(log.LevelInfo, "Serving gRPC API on port %v", port)
+	return nil
+}
+
 func (app *App) logf(level log.Level, format string, a ...interface{}) {
 	app.Logger.Log(log.Entry{
 		Level: level,
@@ -0,0 +1,510 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package addon is the typed addon interface. Addons call Register
+// from init() and implement the hook interfaces they need. Hooks are
+// only called for monitors where the addon is enabled, this can be
+// changed at runtime through /api/addons.
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdLog "log"
+	"net/http"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/video"
+	"nvr/pkg/web"
+	"nvr/pkg/web/auth"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const jsonContentType = "application/json"
+
+// Addon is implemented by all addons.
+type Addon interface {
+	// Name must be unique. It's used as the log source,
+	// data directory name and route prefix.
+	Name() string
+}
+
+// Starter is called when the app starts, ctx is canceled on shutdown.
+type Starter interface {
+	Start(ctx context.Context, env Env) error
+}
+
+// FrameHook is called for every H264 frame of enabled monitors.
+// It's called from the video muxer and must not block.
+type FrameHook interface {
+	OnFrame(*monitor.InputProcess, video.H264Frame)
+}
+
+// EventHook is called on every event of enabled monitors.
+type EventHook interface {
+	OnEvent(*monitor.Recorder, *storage.Event)
+}
+
+// RecordingFinalizedHook is called after a recording of a enabled monitor have been saved.
+type RecordingFinalizedHook interface {
+	OnRecordingFinalized(r *monitor.Recorder, recPath string, data storage.RecordingData)
+}
+
+// RouteHook is called once before the app starts to register routes.
+type RouteHook interface {
+	RegisterRoutes(*Router)
+}
+
+// TemplateHook is called once before the app starts to modify templates.
+type TemplateHook interface {
+	RegisterTemplates(*Templates)
+}
+
+// Env is passed to Starter.
+type Env struct {
+	Env storage.ConfigEnv
+
+	// Logger with the addon name as source.
+	Logger *Logger
+
+	// DataDir private storage directory of the addon.
+	DataDir string
+}
+
+// Logger with the addon name as source.
+type Logger struct {
+	src    string
+	logger log.ILogger
+}
+
+// Logf logs a message.
+func (l *Logger) Logf(level log.Level, format string, a ...interface{}) {
+	l.MonitorLogf(level, "", format, a...)
+}
+
+// MonitorLogf logs a message with a monitor ID.
+func (l *Logger) MonitorLogf(level log.Level, monitorID string, format string, a ...interface{}) {
+	l.logger.Log(log.Entry{
+		Level:     level,
+		Src:       l.src,
+		MonitorID: monitorID,
+		Msg:       fmt.Sprintf(format, a...),
+	})
+}
+
+// Router registers routes under "/api/addon/<name>/".
+type Router struct {
+	Auth auth.Authenticator
+
+	prefix string
+	mux    *http.ServeMux
+}
+
+// Handle registers the handler for the pattern relative to the
+// addon prefix. Only authenticated users are allowed.
+func (r *Router) Handle(pattern string, handler http.Handler) {
+	r.handle(pattern, r.Auth.User(handler))
+}
+
+// HandleAdmin registers a handler that only allows admins.
+func (r *Router) HandleAdmin(pattern string, handler http.Handler) {
+	r.handle(pattern, r.Auth.Admin(handler))
+}
+
+// HandlePublic registers a handler without authentication.
+func (r *Router) HandlePublic(pattern string, handler http.Handler) {
+	r.handle(pattern, handler)
+}
+
+func (r *Router) handle(pattern string, handler http.Handler) {
+	r.mux.Handle(r.prefix+strings.TrimPrefix(pattern, "/"), handler)
+}
+
+// Templates is used to register template hooks.
+type Templates struct {
+	tpl  []web.TemplateHook
+	sub  []web.TemplateHook
+	data []web.TemplateDataFunc
+}
+
+// Page registers function used to modify page templates.
+func (t *Templates) Page(h web.TemplateHook) {
+	t.tpl = append(t.tpl, h)
+}
+
+// Sub registers function used to modify sub templates.
+func (t *Templates) Sub(h web.TemplateHook) {
+	t.sub = append(t.sub, h)
+}
+
+// Data registers function that's called on page render.
+func (t *Templates) Data(f web.TemplateDataFunc) {
+	t.data = append(t.data, f)
+}
+
+// PageHooks returns the registered page template hooks.
+func (t *Templates) PageHooks() []web.TemplateHook {
+	return t.tpl
+}
+
+// SubHooks returns the registered sub template hooks.
+func (t *Templates) SubHooks() []web.TemplateHook {
+	return t.sub
+}
+
+// DataFuncs returns the registered template data functions.
+func (t *Templates) DataFuncs() []web.TemplateDataFunc {
+	return t.data
+}
+
+var registered []Addon
+
+// Register addon, should be called from init().
+func Register(a Addon) {
+	for _, addon := range registered {
+		if addon.Name() == a.Name() {
+			stdLog.Fatalf("\n\nERROR: duplicate addon name: %v\n\n", a.Name())
+		}
+	}
+	registered = append(registered, a)
+}
+
+// Names returns the names of the registered addons.
+func Names() []string {
+	names := make([]string, len(registered))
+	for i, a := range registered {
+		names[i] = a.Name()
+	}
+	return names
+}
+
+// Manager manages the registered addons and which monitors they're enabled for.
+type Manager struct {
+	addons []Addon
+
+	// Addon name and monitor IDs.
+	disabled map[string]map[string]bool
+
+	env    storage.ConfigEnv
+	logger log.ILogger
+	path   string
+	mu     sync.Mutex
+}
+
+// NewManager creates a manager for the registered addons. The
+// per monitor state is stored in "<configDir>/addons.json".
+func NewManager(configDir string, env storage.ConfigEnv, logger log.ILogger) (*Manager, error) {
+	return newManager(registered, configDir, env, logger)
+}
+
+func newManager(
+	addons []Addon,
+	configDir string,
+	env storage.ConfigEnv,
+	logger log.ILogger,
+) (*Manager, error) {
+	m := &Manager{
+		addons:   addons,
+		disabled: make(map[string]map[string]bool),
+		env:      env,
+		logger:   logger,
+		path:     filepath.Join(configDir, "addons.json"),
+	}
+
+	file, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read addon config: %w", err)
+	}
+
+	var config map[string][]string
+	if err := json.Unmarshal(file, &config); err != nil {
+		return nil, fmt.Errorf("unmarshal addon config: %w", err)
+	}
+	for name, monitorIDs := range config {
+		m.disabled[name] = make(map[string]bool)
+		for _, id := range monitorIDs {
+			m.disabled[name][id] = true
+		}
+	}
+	return m, nil
+}
+
+// Start calls Start on all addons that implement Starter.
+func (m *Manager) Start(ctx context.Context) error {
+	for _, a := range m.addons {
+		starter, ok := a.(Starter)
+		if !ok {
+			continue
+		}
+
+		dataDir := filepath.Join(m.env.StorageDir, "addons", a.Name())
+		if err := os.MkdirAll(dataDir, 0o700); err != nil {
+			return fmt.Errorf("create data directory: %v: %w", a.Name(), err)
+		}
+
+		env := Env{
+			Env:     m.env,
+			Logger:  &Logger{src: a.Name(), logger: m.logger},
+			DataDir: dataDir,
+		}
+		if err := starter.Start(ctx, env); err != nil {
+			return fmt.Errorf("start addon: %v: %w", a.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Enabled returns true if the addon is enabled for the monitor.
+func (m *Manager) Enabled(name string, monitorID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.disabled[name][monitorID]
+}
+
+// ErrAddonNotExist addon does not exist.
+var ErrAddonNotExist = errors.New("addon does not exist")
+
+// SetEnabled enables or disables the addon for the monitor.
+func (m *Manager) SetEnabled(name string, monitorID string, enable bool) error {
+	if m.addon(name) == nil {
+		return fmt.Errorf("%w: %v", ErrAddonNotExist, name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.disabled[name] == nil {
+		m.disabled[name] = make(map[string]bool)
+	}
+	if enable {
+		delete(m.disabled[name], monitorID)
+	} else {
+		m.disabled[name][monitorID] = true
+	}
+
+	return m.saveToFile()
+}
+
+func (m *Manager) saveToFile() error {
+	config := make(map[string][]string)
+	for name, monitorIDs := range m.disabled {
+		for id := range monitorIDs {
+			config[name] = append(config[name], id)
+		}
+		sort.Strings(config[name])
+	}
+
+	file, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, file, 0o600)
+}
+
+func (m *Manager) addon(name string) Addon {
+	for _, a := range m.addons {
+		if a.Name() == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// OnFrame calls the frame hook of enabled addons.
+func (m *Manager) OnFrame(i *monitor.InputProcess, frame video.H264Frame) {
+	for _, a := range m.addons {
+		if h, ok := a.(FrameHook); ok && m.Enabled(a.Name(), i.Config.ID()) {
+			h.OnFrame(i, frame)
+		}
+	}
+}
+
+// OnEvent calls the event hook of enabled addons.
+func (m *Manager) OnEvent(r *monitor.Recorder, event *storage.Event) {
+	for _, a := range m.addons {
+		if h, ok := a.(EventHook); ok && m.Enabled(a.Name(), r.Config.ID()) {
+			h.OnEvent(r, event)
+		}
+	}
+}
+
+// OnRecordingFinalized calls the recording finalized hook of enabled addons.
+func (m *Manager) OnRecordingFinalized(
+	r *monitor.Recorder,
+	recPath string,
+	data storage.RecordingData,
+) {
+	for _, a := range m.addons {
+		h, ok := a.(RecordingFinalizedHook)
+		if ok && m.Enabled(a.Name(), r.Config.ID()) {
+			h.OnRecordingFinalized(r, recPath, data)
+		}
+	}
+}
+
+// HasFrameHooks returns true if any addon implements FrameHook.
+func (m *Manager) HasFrameHooks() bool {
+	for _, a := range m.addons {
+		if _, ok := a.(FrameHook); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterRoutes calls the route hook of all addons.
+func (m *Manager) RegisterRoutes(mux *http.ServeMux, a auth.Authenticator) {
+	for _, addon := range m.addons {
+		if h, ok := addon.(RouteHook); ok {
+			h.RegisterRoutes(&Router{
+				Auth:   a,
+				prefix: "/api/addon/" + addon.Name() + "/",
+				mux:    mux,
+			})
+		}
+	}
+}
+
+// Templates calls the template hook of all addons.
+func (m *Manager) Templates() *Templates {
+	t := &Templates{}
+	for _, a := range m.addons {
+		if h, ok := a.(TemplateHook); ok {
+			h.RegisterTemplates(t)
+		}
+	}
+	return t
+}
+
+// Info addon information.
+type Info struct {
+	Name             string   `json:"name"`
+	Hooks            []string `json:"hooks"`
+	DisabledMonitors []string `json:"disabledMonitors"`
+}
+
+// List returns information about all addons.
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Info, len(m.addons))
+	for i, a := range m.addons {
+		disabled := []string{}
+		for id := range m.disabled[a.Name()] {
+			disabled = append(disabled, id)
+		}
+		sort.Strings(disabled)
+
+		list[i] = Info{
+			Name:             a.Name(),
+			Hooks:            hookNames(a),
+			DisabledMonitors: disabled,
+		}
+	}
+	return list
+}
+
+func hookNames(a Addon) []string {
+	names := []string{}
+	if _, ok := a.(Starter); ok {
+		names = append(names, "Start")
+	}
+	if _, ok := a.(FrameHook); ok {
+		names = append(names, "OnFrame")
+	}
+	if _, ok := a.(EventHook); ok {
+		names = append(names, "OnEvent")
+	}
+	if _, ok := a.(RecordingFinalizedHook); ok {
+		names = append(names, "OnRecordingFinalized")
+	}
+	if _, ok := a.(RouteHook); ok {
+		names = append(names, "RegisterRoutes")
+	}
+	if _, ok := a.(TemplateHook); ok {
+		names = append(names, "RegisterTemplates")
+	}
+	return names
+}
+
+// HandleList handler returns information about all addons.
+func (m *Manager) HandleList() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(m.List()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// SetRequest request body for HandleSet.
+type SetRequest struct {
+	Name      string `json:"name"`
+	MonitorID string `json:"monitorID"`
+	Enable    bool   `json:"enable"`
+}
+
+func init() {
+	web.RegisterRoute("/api/addons", web.Route{
+		Auth: web.AuthAdmin,
+		Operations: []web.Operation{{
+			ID: "addons", Method: http.MethodGet,
+			Summary:  "Addons, their hooks and the monitors where they are disabled.",
+			Response: []Info{},
+		}},
+	})
+	web.RegisterRoute("/api/addons/set", web.Route{
+		Auth: web.AuthAdmin,
+		CSRF: true,
+		Operations: []web.Operation{{
+			ID: "addonSet", Method: http.MethodPut,
+			Summary: "Enable or disable a addon for a monitor.",
+			Request: SetRequest{},
+		}},
+	})
+}
+
+// HandleSet handler to enable or disable a addon for a monitor.
+func (m *Manager) HandleSet() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req SetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.MonitorID == "" {
+			http.Error(w, "monitorID missing", http.StatusBadRequest)
+			return
+		}
+
+		err := m.SetEnabled(req.Name, req.MonitorID, req.Enable)
+		if errors.Is(err, ErrAddonNotExist) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package addon
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubAddon struct {
+	events  int
+	started bool
+	dataDir string
+}
+
+func (a *stubAddon) Name() string { return "stub" }
+
+func (a *stubAddon) Start(_ context.Context, env Env) error {
+	a.started = true
+	a.dataDir = env.DataDir
+	return nil
+}
+
+func (a *stubAddon) OnEvent(*monitor.Recorder, *storage.Event) { a.events++ }
+
+type minimalAddon struct{}
+
+func (minimalAddon) Name() string { return "minimal" }
+
+func newTestManager(t *testing.T, addons ...Addon) (*Manager, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	env := storage.ConfigEnv{StorageDir: filepath.Join(tempDir, "storage")}
+	m, err := newManager(addons, tempDir, env, log.NewDummyLogger())
+	require.NoError(t, err)
+	return m, tempDir
+}
+
+func newTestRecorder(monitorID string) *monitor.Recorder {
+	return &monitor.Recorder{
+		Config: monitor.NewConfig(monitor.RawConfig{"id": monitorID}),
+	}
+}
+
+func TestManager(t *testing.T) {
+	t.Run("start", func(t *testing.T) {
+		a := &stubAddon{}
+		m, tempDir := newTestManager(t, a, minimalAddon{})
+
+		require.NoError(t, m.Start(context.Background()))
+		require.True(t, a.started)
+		require.Equal(t, filepath.Join(tempDir, "storage", "addons", "stub"), a.dataDir)
+		require.DirExists(t, a.dataDir)
+	})
+	t.Run("enabled", func(t *testing.T) {
+		a := &stubAddon{}
+		m, configDir := newTestManager(t, a)
+
+		m.OnEvent(newTestRecorder("x"), &storage.Event{})
+		require.Equal(t, 1, a.events)
+
+		require.NoError(t, m.SetEnabled("stub", "x", false))
+		require.False(t, m.Enabled("stub", "x"))
+		require.True(t, m.Enabled("stub", "y"))
+
+		m.OnEvent(newTestRecorder("x"), &storage.Event{})
+		m.OnEvent(newTestRecorder("y"), &storage.Event{})
+		require.Equal(t, 2, a.events)
+
+		// Reload from disk.
+		m2, err := newManager([]Addon{a}, configDir, m.env, m.logger)
+		require.NoError(t, err)
+		require.False(t, m2.Enabled("stub", "x"))
+
+		require.NoError(t, m.SetEnabled("stub", "x", true))
+		require.True(t, m.Enabled("stub", "x"))
+	})
+	t.Run("notExist", func(t *testing.T) {
+		m, _ := newTestManager(t)
+		err := m.SetEnabled("nil", "x", false)
+		require.ErrorIs(t, err, ErrAddonNotExist)
+	})
+	t.Run("unmarshalErr", func(t *testing.T) {
+		tempDir := t.TempDir()
+		err := os.WriteFile(filepath.Join(tempDir, "addons.json"), []byte("{"), 0o600)
+		require.NoError(t, err)
+
+		_, err = newManager(nil, tempDir, storage.ConfigEnv{}, nil)
+		require.Error(t, err)
+	})
+	t.Run("list", func(t *testing.T) {
+		m, _ := newTestManager(t, &stubAddon{}, minimalAddon{})
+		require.NoError(t, m.SetEnabled("stub", "x", false))
+
+		expected := []Info{
+			{
+				Name:             "stub",
+				Hooks:            []string{"Start", "OnEvent"},
+				DisabledMonitors: []string{"x"},
+			},
+			{
+				Name:             "minimal",
+				Hooks:            []string{},
+				DisabledMonitors: []string{},
+			},
+		}
+		require.Equal(t, expected, m.List())
+		require.False(t, m.HasFrameHooks())
+	})
+}
+
+func TestHandleSet(t *testing.T) {
+	m, _ := newTestManager(t, &stubAddon{})
+
+	cases := map[string]struct {
+		body     string
+		expected int
+	}{
+		"ok":         {`{"name":"stub","monitorID":"x","enable":false}`, http.StatusOK},
+		"notExist":   {`{"name":"nil","monitorID":"x"}`, http.StatusNotFound},
+		"noMonitor":  {`{"name":"stub"}`, http.StatusBadRequest},
+		"invalidErr": {`{`, http.StatusBadRequest},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(tc.body)))
+			m.HandleSet().ServeHTTP(w, r)
+			require.Equal(t, tc.expected, w.Code)
+		})
+	}
+	require.False(t, m.Enabled("stub", "x"))
+}
+
+// stubAuth blocks requests without the "user" or "admin" header.
+type stubAuth struct {
+	auth.Authenticator
+}
+
+func (stubAuth) User(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("user") == "" && r.Header.Get("admin") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (stubAuth) Admin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("admin") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestRouter(t *testing.T) {
+	mux := http.NewServeMux()
+	router := &Router{Auth: stubAuth{}, prefix: "/api/addon/x/", mux: mux}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Handle("/user", ok)
+	router.HandleAdmin("/admin", ok)
+	router.HandlePublic("/public", ok)
+
+	status := func(path string, header string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/addon/x"+path, nil)
+		if header != "" {
+			r.Header.Set(header, "1")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	require.Equal(t, http.StatusUnauthorized, status("/user", ""))
+	require.Equal(t, http.StatusOK, status("/user", "user"))
+	require.Equal(t, http.StatusUnauthorized, status("/admin", "user"))
+	require.Equal(t, http.StatusOK, status("/admin", "admin"))
+	require.Equal(t, http.StatusOK, status("/public", ""))
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package backup creates and restores backups of the config directory.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup format:
+//
+// nvr-backup.tar.gz
+// ├── manifest.json
+// └── configs
+//     ├── general.json
+//     ├── users.json
+//     ├── addons.json
+//     ├── monitors
+//     │   └── <id>.json
+//     └── groups
+//         └── <id>.json
+//
+// Every JSON file in the config directory is included, addon configs
+// included. env.yaml is excluded since it's specific to the machine.
+
+const (
+	manifestName = "manifest.json"
+	configsDir   = "configs"
+	version      = 1
+
+	maxFileSize  = 8 * 1024 * 1024
+	maxFileCount = 10000
+)
+
+type manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+// Backup writes a tar.gz of the config directory to w.
+func Backup(configDir string, w io.Writer) error {
+	files, err := listConfigFiles(configDir)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	rawManifest, err := json.MarshalIndent(manifest{
+		Version: version,
+		Created: time.Now().UTC(),
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(tw, manifestName, rawManifest); err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		file, err := os.ReadFile(filepath.Join(configDir, name))
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+		err = writeFile(tw, path.Join(configsDir, filepath.ToSlash(name)), file)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
+
+// listConfigFiles returns the relative paths of all JSON files in the config directory.
+func listConfigFiles(configDir string) ([]string, error) {
+	var files []string
+	walkFunc := func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(name, ".json") {
+			files = append(files, name)
+		}
+		return nil
+	}
+	if err := fs.WalkDir(os.DirFS(configDir), ".", walkFunc); err != nil {
+		return nil, fmt.Errorf("list configs: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Errors.
+var (
+	ErrInvalidBackup      = errors.New("invalid backup")
+	ErrNoManifest         = errors.New("backup manifest missing")
+	ErrUnsupportedVersion = errors.New("unsupported backup version")
+	ErrInvalidFile        = errors.New("invalid file")
+	ErrTooLarge           = errors.New("backup too large")
+)
+
+// Restore validates the backup and writes the configs to the config
+// directory. All backup files are validated before anything is written.
+// If merge is false, configs that aren't in the backup are deleted,
+// otherwise they are kept. The app must be restarted afterwards.
+func Restore(configDir string, r io.Reader, merge bool) error {
+	files, err := readBackup(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+
+	if !merge {
+		existing, err := listConfigFiles(configDir)
+		if err != nil {
+			return err
+		}
+		for _, name := range existing {
+			if _, exist := files[filepath.ToSlash(name)]; exist {
+				continue
+			}
+			if err := os.Remove(filepath.Join(configDir, name)); err != nil {
+				return fmt.Errorf("remove config: %w", err)
+			}
+		}
+	}
+
+	for name, data := range files {
+		if err := writeConfig(filepath.Join(configDir, filepath.FromSlash(name)), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes to a temporary file first to not leave a partial config.
+func writeConfig(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename config: %w", err)
+	}
+	return nil
+}
+
+// readBackup reads and validates the backup, returns
+// the config files by their relative path.
+func readBackup(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	var m *manifest
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: not a regular file: %v", ErrInvalidFile, header.Name)
+		}
+		if header.Size > maxFileSize || len(files) >= maxFileCount {
+			return nil, ErrTooLarge
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+		if err != nil {
+			return nil, fmt.Errorf("read %v: %w", header.Name, err)
+		}
+
+		if header.Name == manifestName {
+			m = &manifest{}
+			if err := json.Unmarshal(data, m); err != nil {
+				return nil, fmt.Errorf("%w: %v: %v", ErrInvalidFile, header.Name, err)
+			}
+			continue
+		}
+
+		name, err := configName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("%w: invalid json: %v", ErrInvalidFile, header.Name)
+		}
+		files[name] = data
+	}
+
+	if m == nil {
+		return nil, ErrNoManifest
+	}
+	if m.Version != version {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedVersion, m.Version)
+	}
+	return files, nil
+}
+
+// configName validates the path of a file in the backup and
+// returns it relative to the config directory.
+func configName(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if cleaned != name || !strings.HasPrefix(name, configsDir+"/") {
+		return "", fmt.Errorf("%w: %v", ErrInvalidFile, name)
+	}
+	relPath := strings.TrimPrefix(name, configsDir+"/")
+	if !fs.ValidPath(relPath) || !strings.HasSuffix(relPath, ".json") {
+		return "", fmt.Errorf("%w: %v", ErrInvalidFile, name)
+	}
+	return relPath, nil
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func readTestFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	names, err := listConfigFiles(dir)
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, name := range names {
+		file, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		files[filepath.ToSlash(name)] = string(file)
+	}
+	return files
+}
+
+func newTestBackup(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFiles(t, dir, files)
+	var buf bytes.Buffer
+	require.NoError(t, Backup(dir, &buf))
+	return buf.Bytes()
+}
+
+func newRawBackup(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, writeFile(tw, name, []byte(content)))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestBackupRestore(t *testing.T) {
+	source := map[string]string{
+		"general.json":    `{"diskSpace":"5"}`,
+		"users.json":      `{"a":{"password":"hash"}}`,
+		"monitors/1.json": `{"id":"1"}`,
+		"groups/2.json":   `{"id":"2"}`,
+	}
+	archive := newTestBackup(t, source)
+
+	t.Run("envExcluded", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFiles(t, dir, map[string]string{
+			"env.yaml":     "port: 2020",
+			"general.json": "{}",
+		})
+		var buf bytes.Buffer
+		require.NoError(t, Backup(dir, &buf))
+		require.NotContains(t, buf.String(), "port")
+	})
+	t.Run("replace", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFiles(t, dir, map[string]string{
+			"env.yaml":        "x",
+			"general.json":    "{}",
+			"monitors/3.json": `{"id":"3"}`,
+		})
+		require.NoError(t, Restore(dir, bytes.NewReader(archive), false))
+		require.Equal(t, source, readTestFiles(t, dir))
+
+		env, err := os.ReadFile(filepath.Join(dir, "env.yaml"))
+		require.NoError(t, err)
+		require.Equal(t, "x", string(env))
+	})
+	t.Run("merge", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFiles(t, dir, map[string]string{
+			"general.json":    "{}",
+			"monitors/3.json": `{"id":"3"}`,
+		})
+		require.NoError(t, Restore(dir, bytes.NewReader(archive), true))
+
+		expected := map[string]string{"monitors/3.json": `{"id":"3"}`}
+		for name, content := range source {
+			expected[name] = content
+		}
+		require.Equal(t, expected, readTestFiles(t, dir))
+	})
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	validManifest := `{"version":1}`
+	cases := map[string]struct {
+		files       map[string]string
+		expectedErr error
+	}{
+		"noManifest": {
+			map[string]string{"configs/general.json": "{}"},
+			ErrNoManifest,
+		},
+		"version": {
+			map[string]string{manifestName: `{"version":2}`},
+			ErrUnsupportedVersion,
+		},
+		"traversal": {
+			map[string]string{manifestName: validManifest, "configs/../x.json": "{}"},
+			ErrInvalidFile,
+		},
+		"outside": {
+			map[string]string{manifestName: validManifest, "general.json": "{}"},
+			ErrInvalidFile,
+		},
+		"notJSON": {
+			map[string]string{manifestName: validManifest, "configs/env.yaml": "{}"},
+			ErrInvalidFile,
+		},
+		"invalidJSON": {
+			map[string]string{manifestName: validManifest, "configs/general.json": "{"},
+			ErrInvalidFile,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, map[string]string{"general.json": "{}"})
+
+			archive := newRawBackup(t, tc.files)
+			err := Restore(dir, bytes.NewReader(archive), false)
+			require.True(t, errors.Is(err, ErrInvalidBackup), "got: %v", err)
+			require.True(t, errors.Is(err, tc.expectedErr), "got: %v", err)
+
+			// Nothing should be changed.
+			require.Equal(t, map[string]string{"general.json": "{}"}, readTestFiles(t, dir))
+		})
+	}
+	t.Run("notGzip", func(t *testing.T) {
+		err := Restore(t.TempDir(), bytes.NewReader([]byte("x")), false)
+		require.True(t, errors.Is(err, ErrInvalidBackup), "got: %v", err)
+	})
+}
@@ -0,0 +1,470 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package crypt encrypts recording files at rest with AES-256-GCM. Files
+// are encrypted in chunks so they can be read at any offset and appended
+// to while recording. Files that aren't encrypted are read unchanged.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted file format.
+//
+//	magic     [8]byte
+//	keyIDSize uint8
+//	keyID     []byte
+//	fileID    [16]byte
+//	chunks    []chunk
+//
+//	chunk {
+//	  nonce      [12]byte
+//	  ciphertext []byte // Up to chunkSize bytes of plaintext and the tag.
+//	}
+//
+// The key of each file is derived from the keyring key and the random
+// file ID. The chunk index is authenticated, chunks can't be reordered.
+// Every chunk except the last is full. The last chunk is sealed again
+// with a new nonce when it grows, see Writer.Flush.
+const (
+	chunkSize    = 64 * 1024
+	nonceSize    = 12
+	tagSize      = 16
+	slotSize     = nonceSize + chunkSize + tagSize
+	fileIDSize   = 16
+	maxKeyIDSize = 255
+)
+
+var magic = []byte("NVRENC\x00\x01")
+
+// Errors.
+var (
+	ErrInvalidFile = errors.New("invalid encrypted file")
+	ErrDecrypt     = errors.New("decrypt: wrong key or corrupt file")
+)
+
+func newAEAD(key []byte, fileID []byte) (cipher.AEAD, error) {
+	fileKey := make([]byte, keySize)
+	kdf := hkdf.New(sha256.New, key, fileID, []byte("nvr:recording"))
+	if _, err := io.ReadFull(kdf, fileKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkAD(index int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(index))
+}
+
+// WriteFile is a file that's written by the recorder.
+type WriteFile interface {
+	io.Writer
+
+	// Flush writes the buffered data.
+	Flush() error
+
+	Sync() error
+	Close() error
+}
+
+// Create creates a file that's encrypted with the key of the monitor,
+// the file isn't encrypted if the monitor has no key.
+func Create(path string, monitorID string) (WriteFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	keyID, key := getKeyring().monitorKey(monitorID)
+	if key == nil {
+		return plainWriter{file}, nil
+	}
+	w, err := newWriter(file, keyID, key)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+type plainWriter struct {
+	*os.File
+}
+
+func (plainWriter) Flush() error { return nil }
+
+// Writer encrypts a file.
+type Writer struct {
+	file       *os.File
+	aead       cipher.AEAD
+	headerSize int64
+
+	// Index and plaintext of the last chunk.
+	chunk int64
+	buf   []byte
+	dirty bool
+}
+
+func newWriter(file *os.File, keyID string, key []byte) (*Writer, error) {
+	fileID := make([]byte, fileIDSize)
+	if _, err := rand.Read(fileID); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, magic...)
+	header = append(header, uint8(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, fileID...)
+	if _, err := file.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		file:       file,
+		aead:       aead,
+		headerSize: int64(len(header)),
+		buf:        make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Write buffers the data, full chunks are written immediately.
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), chunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		w.dirty = true
+		p = p[n:]
+		written += n
+
+		if len(w.buf) == chunkSize {
+			if err := w.writeChunk(); err != nil {
+				return written, err
+			}
+			w.chunk++
+			w.buf = w.buf[:0]
+		}
+	}
+	return written, nil
+}
+
+// Flush writes the partial last chunk. The chunk is
+// overwritten when it grows, with a new nonce.
+func (w *Writer) Flush() error {
+	if !w.dirty || len(w.buf) == 0 {
+		return nil
+	}
+	return w.writeChunk()
+}
+
+func (w *Writer) writeChunk() error {
+	sealed := make([]byte, nonceSize, slotSize)
+	if _, err := rand.Read(sealed); err != nil {
+		return err
+	}
+	sealed = w.aead.Seal(sealed, sealed[:nonceSize], w.buf, chunkAD(w.chunk))
+	if _, err := w.file.WriteAt(sealed, w.headerSize+w.chunk*slotSize); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// Sync flushes and syncs the file.
+func (w *Writer) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Close flushes and closes the file.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// File is a plain or encrypted file opened for reading.
+type File interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+
+	// Size of the plaintext.
+	Size() int64
+
+	Encrypted() bool
+}
+
+// Open opens a plain or encrypted file.
+func Open(path string) (File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFile(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return f, nil
+}
+
+func newFile(file *os.File) (File, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := info.Size()
+
+	head := make([]byte, len(magic)+1)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n < len(head) || !bytes.Equal(head[:len(magic)], magic) {
+		return &plainFile{File: file, size: fileSize}, nil
+	}
+
+	keyIDSize := int64(head[len(magic)])
+	headerSize := int64(len(head)) + keyIDSize + fileIDSize
+	if fileSize < headerSize {
+		return nil, ErrInvalidFile
+	}
+	rest := make([]byte, keyIDSize+fileIDSize)
+	if _, err := file.ReadAt(rest, int64(len(head))); err != nil {
+		return nil, err
+	}
+	key, err := getKeyring().key(string(rest[:keyIDSize]))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key, rest[keyIDSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &encryptedFile{
+		file:       file,
+		aead:       aead,
+		headerSize: headerSize,
+		cached:     -1,
+	}
+	if err := f.initSize(fileSize - headerSize); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type plainFile struct {
+	*os.File
+	size int64
+}
+
+func (f *plainFile) Size() int64     { return f.size }
+func (f *plainFile) Encrypted() bool { return false }
+
+type encryptedFile struct {
+	file       *os.File
+	aead       cipher.AEAD
+	headerSize int64
+	size       int64
+	chunks     int64
+
+	pos int64 // Read position.
+
+	// Last decrypted chunk.
+	mu          sync.Mutex
+	cached      int64
+	cachedChunk []byte
+}
+
+// initSize calculates the plaintext size. A last chunk that can't be
+// decrypted was torn by a crash while it was overwritten and is ignored.
+func (f *encryptedFile) initSize(n int64) error {
+	full := n / slotSize
+	rem := n % slotSize
+	f.chunks = full
+	f.size = full * chunkSize
+	if rem > nonceSize+tagSize {
+		f.chunks++
+		f.size += rem - nonceSize - tagSize
+	}
+	if f.chunks == 0 {
+		return nil
+	}
+	if _, err := f.readChunk(f.chunks - 1); err != nil {
+		if !errors.Is(err, ErrDecrypt) {
+			return err
+		}
+		f.chunks--
+		f.size = f.chunks * chunkSize
+	}
+	return nil
+}
+
+func (f *encryptedFile) readChunk(i int64) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cached == i {
+		return f.cachedChunk, nil
+	}
+
+	size := int64(slotSize)
+	if i == f.chunks-1 {
+		size = nonceSize + tagSize + f.size - i*chunkSize
+	}
+	sealed := make([]byte, size)
+	if _, err := f.file.ReadAt(sealed, f.headerSize+i*slotSize); err != nil {
+		return nil, err
+	}
+	plain, err := f.aead.Open(
+		sealed[nonceSize:nonceSize], sealed[:nonceSize], sealed[nonceSize:], chunkAD(i))
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %v", ErrDecrypt, i)
+	}
+	f.cached = i
+	f.cachedChunk = plain
+	return plain, nil
+}
+
+func (f *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidFile
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.size {
+			return n, io.EOF
+		}
+		chunk, err := f.readChunk(pos / chunkSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], chunk[pos%chunkSize:])
+	}
+	return n, nil
+}
+
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.pos + offset
+	case io.SeekEnd:
+		abs = f.size + offset
+	default:
+		return 0, ErrInvalidFile
+	}
+	if abs < 0 {
+		return 0, ErrInvalidFile
+	}
+	f.pos = abs
+	return abs, nil
+}
+
+func (f *encryptedFile) Close() error    { return f.file.Close() }
+func (f *encryptedFile) Size() int64     { return f.size }
+func (f *encryptedFile) Encrypted() bool { return true }
+
+// ReadFile reads a plain or encrypted file.
+func ReadFile(path string) ([]byte, error) {
+	f, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, f.Size())
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// IsEncrypted returns true if the file is encrypted, the key isn't needed.
+func IsEncrypted(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	head := make([]byte, len(magic))
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return n == len(magic) && bytes.Equal(head, magic), nil
+}
+
+// EncryptFile encrypts a existing file with the key of the monitor. Nothing
+// is changed if the monitor has no key or the file is already encrypted.
+func EncryptFile(path string, monitorID string) error {
+	if _, key := getKeyring().monitorKey(monitorID); key == nil {
+		return nil
+	}
+	f, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if f.Encrypted() {
+		return nil
+	}
+
+	tmpPath := path + ".tmp"
+	w, err := Create(tmpPath, monitorID)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := w.Sync(); err != nil {
+		w.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
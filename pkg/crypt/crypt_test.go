@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keySize))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, keySize))
+	k, err := ParseKeyring([]byte(
+		"keys:\n  a: " + key1 + "\n  b: " + key2 + "\n" +
+			"default: a\nmonitors:\n  m2: b\n"))
+	require.NoError(t, err)
+	return k
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+func TestParseKeyring(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keySize))
+	short := base64.StdEncoding.EncodeToString([]byte("x"))
+
+	t.Run("ok", func(t *testing.T) {
+		k := newTestKeyring(t)
+		id, key := k.monitorKey("m1")
+		require.Equal(t, "a", id)
+		require.Len(t, key, keySize)
+
+		id, _ = k.monitorKey("m2")
+		require.Equal(t, "b", id)
+	})
+	t.Run("noDefault", func(t *testing.T) {
+		k, err := ParseKeyring([]byte("keys:\n  a: " + key + "\n"))
+		require.NoError(t, err)
+		_, key := k.monitorKey("m1")
+		require.Nil(t, key)
+	})
+	cases := map[string]string{
+		"noKeys":         "default: a\n",
+		"keySize":        "keys:\n  a: " + short + "\n",
+		"unknownDefault": "keys:\n  a: " + key + "\ndefault: b\n",
+		"unknownMonitor": "keys:\n  a: " + key + "\nmonitors:\n  m1: b\n",
+		"yaml":           "keys: [",
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseKeyring([]byte(raw))
+			require.ErrorIs(t, err, ErrInvalidKeyring)
+		})
+	}
+}
+
+func TestCrypt(t *testing.T) {
+	SetKeyring(newTestKeyring(t))
+	defer SetKeyring(nil)
+
+	t.Run("roundtrip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x")
+		data := randomBytes(t, 3*chunkSize+100)
+
+		w, err := Create(path, "m1")
+		require.NoError(t, err)
+
+		// Flush partial chunks that are later overwritten.
+		_, err = w.Write(data[:10])
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+		_, err = w.Write(data[10 : chunkSize+5])
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+		_, err = w.Write(data[chunkSize+5:])
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(raw, data[:32]))
+
+		f, err := Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		require.True(t, f.Encrypted())
+		require.Equal(t, int64(len(data)), f.Size())
+
+		got, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+
+		// Across chunks.
+		buf := make([]byte, 200)
+		_, err = f.ReadAt(buf, chunkSize-100)
+		require.NoError(t, err)
+		require.Equal(t, data[chunkSize-100:chunkSize+100], buf)
+
+		n, err := f.ReadAt(buf, int64(len(data))-50)
+		require.ErrorIs(t, err, io.EOF)
+		require.Equal(t, 50, n)
+
+		_, err = f.Seek(-100, io.SeekEnd)
+		require.NoError(t, err)
+		got, err = io.ReadAll(f)
+		require.NoError(t, err)
+		require.Equal(t, data[len(data)-100:], got)
+	})
+	t.Run("flushedOnly", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x")
+		data := randomBytes(t, chunkSize+10)
+
+		w, err := Create(path, "m2")
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+
+		// The recorder reads the file while it's being written.
+		f, err := Open(path)
+		require.NoError(t, err)
+		require.Equal(t, int64(chunkSize), f.Size())
+		f.Close()
+
+		require.NoError(t, w.Flush())
+		got, err := ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+		require.NoError(t, w.Close())
+	})
+	t.Run("tornLastChunk", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x")
+		data := randomBytes(t, chunkSize+1000)
+
+		w, err := Create(path, "m1")
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(path, info.Size()-10))
+
+		got, err := ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, data[:chunkSize], got)
+	})
+	t.Run("plain", func(t *testing.T) {
+		SetKeyring(nil)
+		defer SetKeyring(newTestKeyring(t))
+
+		path := filepath.Join(t.TempDir(), "x")
+		w, err := Create(path, "m1")
+		require.NoError(t, err)
+		_, err = w.Write([]byte("abc"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, []byte("abc"), raw)
+
+		f, err := Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		require.False(t, f.Encrypted())
+		require.Equal(t, int64(3), f.Size())
+	})
+	t.Run("noKeyring", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x")
+		w, err := Create(path, "m1")
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		SetKeyring(nil)
+		defer SetKeyring(newTestKeyring(t))
+		_, err = Open(path)
+		require.ErrorIs(t, err, ErrNoKeyring)
+	})
+	t.Run("corruptChunk", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x")
+		w, err := Create(path, "m1")
+		require.NoError(t, err)
+		_, err = w.Write([]byte("abc"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		raw[len(raw)-1] ^= 1
+		require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+		// The only chunk is considered torn.
+		got, err := ReadFile(path)
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+	t.Run("encryptFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x.jpeg")
+		require.NoError(t, os.WriteFile(path, []byte("abc"), 0o600))
+
+		encrypted, err := IsEncrypted(path)
+		require.NoError(t, err)
+		require.False(t, encrypted)
+
+		require.NoError(t, EncryptFile(path, "m1"))
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(raw, magic))
+		encrypted, err = IsEncrypted(path)
+		require.NoError(t, err)
+		require.True(t, encrypted)
+
+		// Already encrypted.
+		require.NoError(t, EncryptFile(path, "m1"))
+		got, err := ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, []byte("abc"), got)
+	})
+}
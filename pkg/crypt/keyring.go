@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package crypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const keySize = 32
+
+// Errors.
+var (
+	ErrInvalidKeyring = errors.New("invalid keyring")
+	ErrUnknownKey     = errors.New("unknown key")
+	ErrNoKeyring      = errors.New("file is encrypted but there is no keyring")
+)
+
+// Keyring holds the keys that recordings are encrypted with.
+type Keyring struct {
+	keys       map[string][]byte
+	defaultKey string
+	monitors   map[string]string
+}
+
+// keyFile is the format of the key file and the output of the key command.
+//
+//	keys:
+//	  2024: <base64 key>
+//	  2025: <base64 key>
+//	default: 2025
+//	monitors:
+//	  door: 2024
+type keyFile struct {
+	// Keys by ID. Old keys are kept to read old recordings.
+	Keys map[string]string `yaml:"keys"`
+
+	// ID of the key of monitors that aren't listed in monitors,
+	// recordings of other monitors aren't encrypted if empty.
+	Default string `yaml:"default"`
+
+	// Key IDs by monitor ID.
+	Monitors map[string]string `yaml:"monitors"`
+}
+
+// ParseKeyring parses the key file format.
+func ParseKeyring(raw []byte) (*Keyring, error) {
+	var f keyFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKeyring, err)
+	}
+	if len(f.Keys) == 0 {
+		return nil, fmt.Errorf("%w: no keys", ErrInvalidKeyring)
+	}
+
+	k := &Keyring{
+		keys:       make(map[string][]byte, len(f.Keys)),
+		defaultKey: f.Default,
+		monitors:   f.Monitors,
+	}
+	for id, encoded := range f.Keys {
+		if id == "" || len(id) > maxKeyIDSize {
+			return nil, fmt.Errorf("%w: key ID: %q", ErrInvalidKeyring, id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("%w: key %v must be %v bytes in base64",
+				ErrInvalidKeyring, id, keySize)
+		}
+		k.keys[id] = key
+	}
+	if _, exist := k.keys[f.Default]; f.Default != "" && !exist {
+		return nil, fmt.Errorf("%w: default: %w: %v", ErrInvalidKeyring, ErrUnknownKey, f.Default)
+	}
+	for monitorID, id := range f.Monitors {
+		if _, exist := k.keys[id]; !exist {
+			return nil, fmt.Errorf("%w: monitor %v: %w: %v",
+				ErrInvalidKeyring, monitorID, ErrUnknownKey, id)
+		}
+	}
+	return k, nil
+}
+
+// LoadKeyring reads the key file, or the output of the key command
+// which can fetch the keys from a KMS. Returns nil if both are empty.
+func LoadKeyring(keyFilePath string, keyCommand []string) (*Keyring, error) {
+	switch {
+	case keyFilePath != "":
+		raw, err := os.ReadFile(keyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		return ParseKeyring(raw)
+	case len(keyCommand) != 0:
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(keyCommand[0], keyCommand[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("key command: %w: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		return ParseKeyring(stdout.Bytes())
+	}
+	return nil, nil //nolint:nilnil
+}
+
+// monitorKey returns the ID and key that new recordings
+// of the monitor are encrypted with, nil if none.
+func (k *Keyring) monitorKey(monitorID string) (string, []byte) {
+	if k == nil {
+		return "", nil
+	}
+	id, exist := k.monitors[monitorID]
+	if !exist {
+		id = k.defaultKey
+	}
+	return id, k.keys[id]
+}
+
+func (k *Keyring) key(id string) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	key, exist := k.keys[id]
+	if !exist {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// The recordings are read by many packages and addons, the
+// keyring is set once for the process instead of passed to each.
+var (
+	keyring   *Keyring
+	keyringMu sync.RWMutex
+)
+
+// SetKeyring sets the keyring of the process, called by the app
+// on startup. Nil disables the encryption of new files.
+func SetKeyring(k *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = k
+}
+
+func getKeyring() *Keyring {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	return keyring
+}
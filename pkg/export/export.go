@@ -0,0 +1,404 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package export composes the recordings of one or more monitors
+// within a time range into a single video clip. The monitors are
+// arranged in a grid or as picture-in-picture.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts.
+const (
+	LayoutGrid = "grid"
+
+	// The first monitor fills the frame and the
+	// others are small overlays in the corners.
+	LayoutPiP = "pip"
+)
+
+// Limits.
+const (
+	maxMonitors    = 9
+	maxPiPMonitors = 5
+	maxClips       = 64
+	maxDuration    = time.Hour
+)
+
+// Request export request.
+type Request struct {
+	Monitors []string  `json:"monitors"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Layout   string    `json:"layout"`
+
+	// Transcode profile name, empty uses the default profile.
+	Profile string `json:"profile,omitempty"`
+}
+
+// Request errors.
+var (
+	ErrNoMonitors      = errors.New("no monitors")
+	ErrTooManyMonitors = errors.New("too many monitors")
+	ErrInvalidRange    = errors.New("invalid time range")
+	ErrInvalidLayout   = errors.New("invalid layout")
+	ErrInvalidMonitor  = errors.New("invalid monitor id")
+	ErrNoRecordings    = errors.New("no recordings in time range")
+	ErrTooManyClips    = errors.New("too many recordings in time range")
+)
+
+// Validate returns an error if the request is invalid.
+func (r Request) Validate() error {
+	switch r.Layout {
+	case LayoutGrid:
+		if len(r.Monitors) > maxMonitors {
+			return fmt.Errorf("%w: max %v", ErrTooManyMonitors, maxMonitors)
+		}
+	case LayoutPiP:
+		if len(r.Monitors) > maxPiPMonitors {
+			return fmt.Errorf("%w: max %v", ErrTooManyMonitors, maxPiPMonitors)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidLayout, r.Layout)
+	}
+	if len(r.Monitors) == 0 {
+		return ErrNoMonitors
+	}
+	for _, id := range r.Monitors {
+		if err := validateMonitorID(id); err != nil {
+			return err
+		}
+	}
+	if !r.End.After(r.Start) {
+		return fmt.Errorf("%w: end must be after start", ErrInvalidRange)
+	}
+	if r.End.Sub(r.Start) > maxDuration {
+		return fmt.Errorf("%w: max duration %v", ErrInvalidRange, maxDuration)
+	}
+	return nil
+}
+
+func validateMonitorID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidMonitor, id)
+	}
+	return nil
+}
+
+func (r Request) duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// clip is a recording that overlaps the export range.
+type clip struct {
+	monitor int // Index in Request.Monitors.
+	source  storage.PlaybackSource
+	start   time.Time
+	end     time.Time
+}
+
+// trim returns the offset from the start of the clip
+// and the duration of the part that's within the range.
+func (c clip) trim(start time.Time, end time.Time) (time.Duration, time.Duration) {
+	inpoint := max(start.Sub(c.start), 0)
+	if c.end.Before(end) {
+		end = c.end
+	}
+	return inpoint, end.Sub(c.start) - inpoint
+}
+
+// findClips returns the recordings of the monitors that overlap the
+// time range. The days are read from the recording data files.
+func findClips(recordingsDirs []string, r Request) ([]clip, error) {
+	var clips []clip
+	for i, monitorID := range r.Monitors {
+		monitorClips, err := findMonitorClips(recordingsDirs, monitorID, r.Start, r.End)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range monitorClips {
+			c.monitor = i
+			clips = append(clips, c)
+		}
+	}
+	if len(clips) == 0 {
+		return nil, ErrNoRecordings
+	}
+	if len(clips) > maxClips {
+		return nil, fmt.Errorf("%w: %v, max %v", ErrTooManyClips, len(clips), maxClips)
+	}
+	return clips, nil
+}
+
+func findMonitorClips(
+	recordingsDirs []string,
+	monitorID string,
+	start time.Time,
+	end time.Time,
+) ([]clip, error) {
+	// Recordings that start the day before may overlap the range.
+	firstDay := start.UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+
+	var clips []clip
+	for day := firstDay; !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, recordingsDir := range recordingsDirs {
+			dir := filepath.Join(recordingsDir, day.Format("2006/01/02"), monitorID)
+			dayClips, err := readDirClips(dir, start, end)
+			if err != nil {
+				return nil, err
+			}
+			clips = append(clips, dayClips...)
+		}
+	}
+	sort.Slice(clips, func(i, j int) bool {
+		return clips[i].start.Before(clips[j].start)
+	})
+	return clips, nil
+}
+
+func readDirClips(dir string, start time.Time, end time.Time) ([]clip, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+
+	var clips []clip
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, strings.TrimSuffix(entry.Name(), ".json"))
+
+		rawData, err := os.ReadFile(path + ".json")
+		if err != nil {
+			return nil, fmt.Errorf("read data: %w", err)
+		}
+		var data storage.RecordingData
+		if err := json.Unmarshal(rawData, &data); err != nil {
+			continue
+		}
+		if !data.End.After(start) || !data.Start.Before(end) {
+			continue
+		}
+
+		source, err := storage.FindPlaybackSource(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		clips = append(clips, clip{
+			source: source,
+			start:  data.Start,
+			end:    data.End,
+		})
+	}
+	return clips, nil
+}
+
+// materialize writes recordings in the meta format and encrypted
+// recordings to MP4 files in the directory since FFmpeg can't
+// read them. Returns the input file paths.
+func materialize(clips []clip, dir string) ([]string, error) {
+	inputs := make([]string, len(clips))
+	for i, c := range clips {
+		if !needsMaterialize(c.source) {
+			inputs[i] = c.source.Path
+			continue
+		}
+
+		path := filepath.Join(dir, "input"+strconv.Itoa(i)+".mp4")
+		if err := writeMP4(c.source, path); err != nil {
+			return nil, fmt.Errorf("write mp4: %w", err)
+		}
+		inputs[i] = path
+	}
+	return inputs, nil
+}
+
+func needsMaterialize(source storage.PlaybackSource) bool {
+	return source.Format == storage.PlaybackMeta || source.Encrypted
+}
+
+func writeMP4(source storage.PlaybackSource, path string) error {
+	video, err := source.Open(nil)
+	if err != nil {
+		return err
+	}
+	defer video.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, video); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Frame sizes.
+const (
+	tileWidth  = 640
+	tileHeight = 360
+
+	pipWidth       = 1280
+	pipHeight      = 720
+	pipInsetWidth  = 320
+	pipInsetHeight = 180
+	pipMargin      = 16
+)
+
+type size struct {
+	width  int
+	height int
+}
+
+// tileSize returns the size of the monitor in the output.
+func tileSize(layout string, monitor int) size {
+	if layout == LayoutPiP {
+		if monitor == 0 {
+			return size{pipWidth, pipHeight}
+		}
+		return size{pipInsetWidth, pipInsetHeight}
+	}
+	return size{tileWidth, tileHeight}
+}
+
+// gridPositions returns the xstack layout of n tiles.
+func gridPositions(n int) string {
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	positions := make([]string, n)
+	for i := range positions {
+		x := (i % cols) * tileWidth
+		y := (i / cols) * tileHeight
+		positions[i] = strconv.Itoa(x) + "_" + strconv.Itoa(y)
+	}
+	return strings.Join(positions, "|")
+}
+
+// pipPositions of the insets, bottom right first.
+var pipPositions = []string{
+	"W-w-" + strconv.Itoa(pipMargin) + ":H-h-" + strconv.Itoa(pipMargin),
+	strconv.Itoa(pipMargin) + ":H-h-" + strconv.Itoa(pipMargin),
+	"W-w-" + strconv.Itoa(pipMargin) + ":" + strconv.Itoa(pipMargin),
+	strconv.Itoa(pipMargin) + ":" + strconv.Itoa(pipMargin),
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// generateArgs returns the FFmpeg arguments. Every monitor is drawn on a
+// black background for the whole duration and each clip is overlaid at
+// its offset from the start, this keeps the monitors in sync across gaps.
+func generateArgs(
+	r Request,
+	profile transcode.Profile,
+	clips []clip,
+	inputs []string,
+	output string,
+) []string {
+	// ffmpeg -ss 5 -t 60 -i input0.mp4 -filter_complex
+	//   "color=c=black:s=640x360:r=25:d=60[b0_0];
+	//    [0:v]scale=640:360:...,setpts=PTS-STARTPTS+0/TB[c0];
+	//    [b0_0][c0]overlay=eof_action=pass[b0_1];
+	//    [b0_1][b1_1]xstack=inputs=2:layout=0_0|640_0:fill=black[out]"
+	//   -map [out] -c:v libx264 -t 60 output.mp4
+
+	args := []string{"-y", "-loglevel", "error"}
+	args = append(args, profile.InputArgs()...)
+
+	var filters []string
+	last := make([]string, len(r.Monitors))
+	for i := range r.Monitors {
+		s := tileSize(r.Layout, i)
+		last[i] = fmt.Sprintf("b%d_0", i)
+		filters = append(filters, fmt.Sprintf("color=c=black:s=%dx%d:r=25:d=%v[%v]",
+			s.width, s.height, seconds(r.duration()), last[i]))
+	}
+
+	for k, c := range clips {
+		offset := max(c.start.Sub(r.Start), 0)
+		inpoint, duration := c.trim(r.Start, r.End)
+
+		if c.source.Format == storage.PlaybackH264 {
+			args = append(args, "-f", "h264")
+		}
+		args = append(args, "-ss", seconds(inpoint), "-t", seconds(duration), "-i", inputs[k])
+
+		s := tileSize(r.Layout, c.monitor)
+		filters = append(filters, fmt.Sprintf(
+			"[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,"+
+				"pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setpts=PTS-STARTPTS+%v/TB[c%d]",
+			k, s.width, s.height, s.width, s.height, seconds(offset), k))
+
+		next := fmt.Sprintf("b%d_%d", c.monitor, k+1)
+		filters = append(filters, fmt.Sprintf(
+			"[%v][c%d]overlay=eof_action=pass[%v]", last[c.monitor], k, next))
+		last[c.monitor] = next
+	}
+
+	filters = append(filters, compose(r, last))
+	out := "[out]"
+	if filter := profile.Filter(); filter != "" {
+		filters = append(filters, "[out]"+filter+"[encode]")
+		out = "[encode]"
+	}
+
+	args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", out)
+	args = append(args, profile.OutputArgs()...)
+	args = append(args,
+		"-movflags", "+faststart",
+		"-t", seconds(r.duration()),
+		output,
+	)
+	return args
+}
+
+// compose arranges the monitor labels into the "out" label.
+func compose(r Request, labels []string) string {
+	if len(labels) == 1 {
+		return "[" + labels[0] + "]null[out]"
+	}
+
+	if r.Layout == LayoutPiP {
+		var filters []string
+		prev := labels[0]
+		for i, label := range labels[1:] {
+			next := "p" + strconv.Itoa(i+1)
+			if i == len(labels)-2 {
+				next = "out"
+			}
+			filters = append(filters, fmt.Sprintf(
+				"[%v][%v]overlay=%v[%v]", prev, label, pipPositions[i], next))
+			prev = next
+		}
+		return strings.Join(filters, ";")
+	}
+
+	var inputs string
+	for _, label := range labels {
+		inputs += "[" + label + "]"
+	}
+	return fmt.Sprintf("%vxstack=inputs=%d:layout=%v:fill=black[out]",
+		inputs, len(labels), gridPositions(len(labels)))
+}
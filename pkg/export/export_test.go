@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)
+	valid := Request{
+		Monitors: []string{"a", "b"},
+		Start:    start,
+		End:      start.Add(time.Minute),
+		Layout:   LayoutGrid,
+	}
+	require.NoError(t, valid.Validate())
+
+	cases := map[string]struct {
+		modify func(*Request)
+		err    error
+	}{
+		"noMonitors": {func(r *Request) { r.Monitors = nil }, ErrNoMonitors},
+		"tooManyGrid": {
+			func(r *Request) { r.Monitors = strings.Split("abcdefghij", "") }, ErrTooManyMonitors,
+		},
+		"tooManyPiP": {
+			func(r *Request) {
+				r.Layout = LayoutPiP
+				r.Monitors = strings.Split("abcdef", "")
+			}, ErrTooManyMonitors,
+		},
+		"layout": {func(r *Request) { r.Layout = "x" }, ErrInvalidLayout},
+		"monitorID": {
+			func(r *Request) { r.Monitors = []string{"a", "../b"} }, ErrInvalidMonitor,
+		},
+		"endStart": {func(r *Request) { r.End = r.Start }, ErrInvalidRange},
+		"tooLong": {
+			func(r *Request) { r.End = r.Start.Add(2 * time.Hour) }, ErrInvalidRange,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := valid
+			tc.modify(&r)
+			require.ErrorIs(t, r.Validate(), tc.err)
+		})
+	}
+}
+
+func writeRecording(t *testing.T, recordingsDir string, monitorID string, start time.Time, d time.Duration) string {
+	t.Helper()
+	id := start.Format("2006-01-02_15-04-05_") + monitorID
+	dir := filepath.Join(recordingsDir, start.Format("2006/01/02"), monitorID)
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+
+	data, err := json.Marshal(storage.RecordingData{Start: start, End: start.Add(d)})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), data, 0o600))
+
+	h264Path := filepath.Join(dir, id+".h264")
+	require.NoError(t, os.WriteFile(h264Path, nil, 0o600))
+	return h264Path
+}
+
+func TestFindClips(t *testing.T) {
+	recordingsDir := t.TempDir()
+	start := time.Date(2000, 1, 2, 0, 0, 30, 0, time.UTC)
+
+	// Starts the day before and overlaps the range.
+	a1 := writeRecording(t, recordingsDir, "a", start.Add(-time.Minute), 2*time.Minute)
+	a2 := writeRecording(t, recordingsDir, "a", start.Add(2*time.Minute), time.Minute)
+	// After the range.
+	writeRecording(t, recordingsDir, "a", start.Add(time.Hour), time.Minute)
+	b1 := writeRecording(t, recordingsDir, "b", start.Add(time.Minute), time.Minute)
+	// Not selected.
+	writeRecording(t, recordingsDir, "c", start, time.Minute)
+
+	r := Request{
+		Monitors: []string{"a", "b"},
+		Start:    start,
+		End:      start.Add(5 * time.Minute),
+		Layout:   LayoutGrid,
+	}
+	clips, err := findClips([]string{recordingsDir}, r)
+	require.NoError(t, err)
+
+	var actual []string
+	for _, c := range clips {
+		actual = append(actual, c.source.Path+" "+r.Monitors[c.monitor])
+	}
+	expected := []string{a1 + " a", a2 + " a", b1 + " b"}
+	require.Equal(t, expected, actual)
+
+	t.Run("noRecordings", func(t *testing.T) {
+		r := r
+		r.Monitors = []string{"x"}
+		_, err := findClips([]string{recordingsDir}, r)
+		require.ErrorIs(t, err, ErrNoRecordings)
+	})
+}
+
+func TestGenerateArgs(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	clips := []clip{
+		{
+			monitor: 0,
+			source:  storage.PlaybackSource{Path: "a.mp4", Format: storage.PlaybackMP4},
+			start:   start.Add(-10 * time.Second),
+			end:     start.Add(20 * time.Second),
+		},
+		{
+			monitor: 1,
+			source:  storage.PlaybackSource{Path: "b.h264", Format: storage.PlaybackH264},
+			start:   start.Add(5 * time.Second),
+			end:     start.Add(time.Minute),
+		},
+	}
+	inputs := []string{"a.mp4", "b.h264"}
+
+	t.Run("grid", func(t *testing.T) {
+		r := Request{
+			Monitors: []string{"a", "b"},
+			Start:    start,
+			End:      start.Add(30 * time.Second),
+			Layout:   LayoutGrid,
+		}
+		args := generateArgs(r, transcode.DefaultProfile, clips, inputs, "out.mp4")
+		expected := "-y -loglevel error" +
+			" -ss 10.000 -t 20.000 -i a.mp4" +
+			" -f h264 -ss 0.000 -t 25.000 -i b.h264" +
+			" -filter_complex" +
+			" color=c=black:s=640x360:r=25:d=30.000[b0_0];" +
+			"color=c=black:s=640x360:r=25:d=30.000[b1_0];" +
+			"[0:v]scale=640:360:force_original_aspect_ratio=decrease," +
+			"pad=640:360:(ow-iw)/2:(oh-ih)/2,setpts=PTS-STARTPTS+0.000/TB[c0];" +
+			"[b0_0][c0]overlay=eof_action=pass[b0_1];" +
+			"[1:v]scale=640:360:force_original_aspect_ratio=decrease," +
+			"pad=640:360:(ow-iw)/2:(oh-ih)/2,setpts=PTS-STARTPTS+5.000/TB[c1];" +
+			"[b1_0][c1]overlay=eof_action=pass[b1_2];" +
+			"[b0_1][b1_2]xstack=inputs=2:layout=0_0|640_0:fill=black[out]" +
+			" -map [out] -c:v libx264 -preset veryfast -crf 23" +
+			" -pix_fmt yuv420p -movflags +faststart -t 30.000 out.mp4"
+		require.Equal(t, expected, strings.Join(args, " "))
+	})
+	t.Run("pip", func(t *testing.T) {
+		r := Request{
+			Monitors: []string{"a", "b"},
+			Start:    start,
+			End:      start.Add(30 * time.Second),
+			Layout:   LayoutPiP,
+		}
+		args := generateArgs(r, transcode.DefaultProfile, clips, inputs, "out.mp4")
+		filter := args[18]
+		require.Equal(t, "-filter_complex", args[17])
+		require.Contains(t, filter, "color=c=black:s=1280x720:r=25:d=30.000[b0_0]")
+		require.Contains(t, filter, "color=c=black:s=320x180:r=25:d=30.000[b1_0]")
+		require.True(t, strings.HasSuffix(filter, "[b0_1][b1_2]overlay=W-w-16:H-h-16[out]"), filter)
+	})
+	t.Run("profile", func(t *testing.T) {
+		r := Request{
+			Monitors: []string{"a", "b"},
+			Start:    start,
+			End:      start.Add(30 * time.Second),
+			Layout:   LayoutGrid,
+		}
+		p := transcode.Profile{
+			Name:    "p",
+			Codec:   transcode.CodecH265,
+			Height:  360,
+			Bitrate: 1000,
+			HWAccel: transcode.HWAccelVAAPI,
+		}
+		args := generateArgs(r, p, clips, inputs, "out.mp4")
+		require.Equal(t, []string{"-vaapi_device", "/dev/dri/renderD128"}, args[3:5])
+
+		filter := args[20]
+		require.Equal(t, "-filter_complex", args[19])
+		require.True(t, strings.HasSuffix(filter,
+			"[out]scale=-2:360,format=nv12,hwupload[encode]"), filter)
+
+		expected := "-map [encode] -c:v hevc_vaapi -b:v 1000k -maxrate 1000k" +
+			" -bufsize 2000k -tag:v hvc1 -movflags +faststart -t 30.000 out.mp4"
+		require.Equal(t, expected, strings.Join(args[21:], " "))
+	})
+}
+
+func TestCompose(t *testing.T) {
+	require.Equal(t, "[a]null[out]", compose(Request{Layout: LayoutGrid}, []string{"a"}))
+	require.Equal(t,
+		"[a][b][c]xstack=inputs=3:layout=0_0|640_0|0_360:fill=black[out]",
+		compose(Request{Layout: LayoutGrid}, []string{"a", "b", "c"}),
+	)
+	require.Equal(t,
+		"[a][b]overlay=W-w-16:H-h-16[p1];[p1][c]overlay=16:H-h-16[out]",
+		compose(Request{Layout: LayoutPiP}, []string{"a", "b", "c"}),
+	)
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Job export or summary job.
+type Job struct {
+	ID      string          `json:"id"`
+	Request *Request        `json:"request,omitempty"`
+	Summary *SummaryRequest `json:"summary,omitempty"`
+	Status  string          `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Created time.Time       `json:"created"`
+
+	// When the job was done or failed.
+	finished time.Time
+}
+
+// Limits.
+const (
+	maxRunningJobs = 2
+
+	// Finished jobs and their files are removed after this duration.
+	jobRetention = time.Hour
+)
+
+// Manager errors.
+var (
+	ErrTooManyJobs = errors.New("too many running exports")
+	ErrJobNotExist = errors.New("export job does not exist")
+	ErrJobNotDone  = errors.New("export job is not done")
+)
+
+type runFunc func(ctx context.Context, args []string) error
+
+// Manager runs export jobs. The output files are kept in the temporary
+// directory until the job expires.
+type Manager struct {
+	recordingsDirs []string
+	tempDir        string
+	profiles       *transcode.Manager
+	logger         log.ILogger
+	run            runFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	jobs map[string]*Job
+	mu   sync.Mutex
+}
+
+// NewManager creates a new export manager. The
+// default transcode profile is used if profiles is nil.
+func NewManager(
+	recordingsDirs []string,
+	tempDir string,
+	ffmpegBin string,
+	profiles *transcode.Manager,
+	logger log.ILogger,
+) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		recordingsDirs: recordingsDirs,
+		tempDir:        filepath.Join(tempDir, "export"),
+		profiles:       profiles,
+		logger:         logger,
+		run:            newFFmpegRunner(ffmpegBin),
+		ctx:            ctx,
+		cancel:         cancel,
+		jobs:           make(map[string]*Job),
+	}
+}
+
+func newFFmpegRunner(bin string) runFunc {
+	return func(ctx context.Context, args []string) error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, bin, args...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
+
+// Run removes expired jobs until the context is
+// canceled, then cancels the running jobs.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			m.cancel()
+			m.wg.Wait()
+			return
+		case <-time.After(10 * time.Minute):
+			m.removeExpired(time.Now())
+		}
+	}
+}
+
+func (m *Manager) removeExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		if job.Status == StatusRunning || now.Sub(job.finished) < jobRetention {
+			continue
+		}
+		delete(m.jobs, id)
+		os.RemoveAll(m.jobDir(id))
+	}
+}
+
+func (m *Manager) jobDir(id string) string {
+	return filepath.Join(m.tempDir, id)
+}
+
+func (m *Manager) outputPath(id string) string {
+	return filepath.Join(m.jobDir(id), "export.mp4")
+}
+
+// Start validates the request and starts the export in the background.
+func (m *Manager) Start(r Request) (Job, error) {
+	if err := r.Validate(); err != nil {
+		return Job{}, err
+	}
+
+	profile := transcode.DefaultProfile
+	if m.profiles != nil {
+		var err error
+		profile, err = m.profiles.Profile(r.Profile)
+		if err != nil {
+			return Job{}, err
+		}
+	}
+
+	clips, err := findClips(m.recordingsDirs, r)
+	if err != nil {
+		return Job{}, err
+	}
+
+	// The recordings can't be pruned until the export is finished.
+	ids := make([]string, 0, len(clips))
+	for _, c := range clips {
+		ids = append(ids, c.source.RecordingID())
+	}
+	unlock := storage.LockRecordings(ids...)
+
+	job, err := m.startJob(Job{Request: &r}, func(id string) error {
+		defer unlock()
+		return m.export(r, profile, clips, id)
+	})
+	if err != nil {
+		unlock()
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// startJob runs the work in the background. The
+// job fails if the work returns a error.
+func (m *Manager) startJob(job Job, work func(id string) error) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	running := 0
+	for _, j := range m.jobs {
+		if j.Status == StatusRunning {
+			running++
+		}
+	}
+	if running >= maxRunningJobs {
+		return Job{}, ErrTooManyJobs
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	job.ID = id
+	job.Status = StatusRunning
+	job.Created = time.Now()
+	m.jobs[id] = &job
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		err := work(id)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		job.finished = time.Now()
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			os.RemoveAll(m.jobDir(id))
+			m.logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("export %v: %v", id, err),
+			})
+			return
+		}
+		job.Status = StatusDone
+	}()
+
+	return job, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (m *Manager) export(r Request, profile transcode.Profile, clips []clip, id string) error {
+	dir := m.jobDir(id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	inputs, err := materialize(clips, dir)
+	if err != nil {
+		return err
+	}
+
+	args := generateArgs(r, profile, clips, inputs, m.outputPath(id))
+	if err := m.run(m.ctx, args); err != nil {
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	// The inputs are no longer needed.
+	for _, input := range inputs {
+		if filepath.Dir(input) == dir {
+			os.Remove(input)
+		}
+	}
+	return nil
+}
+
+// Job returns the job by ID.
+func (m *Manager) Job(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, exist := m.jobs[id]
+	if !exist {
+		return Job{}, ErrJobNotExist
+	}
+	return *job, nil
+}
+
+// File returns the path of the exported video.
+func (m *Manager) File(id string) (string, error) {
+	job, err := m.Job(id)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != StatusDone {
+		return "", fmt.Errorf("%w: %v", ErrJobNotDone, job.Status)
+	}
+	return m.outputPath(id), nil
+}
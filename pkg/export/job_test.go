@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package export
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) (*Manager, Request) {
+	t.Helper()
+	recordingsDir := t.TempDir()
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	writeRecording(t, recordingsDir, "a", start, time.Minute)
+
+	m := NewManager([]string{recordingsDir}, t.TempDir(), "", nil, log.NewDummyLogger())
+	r := Request{
+		Monitors: []string{"a"},
+		Start:    start,
+		End:      start.Add(time.Minute),
+		Layout:   LayoutGrid,
+	}
+	return m, r
+}
+
+func waitForJob(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		job, err := m.Job(id)
+		require.NoError(t, err)
+		if job.Status != StatusRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+	return Job{}
+}
+
+func TestManager(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		m, r := newTestManager(t)
+		m.run = func(_ context.Context, args []string) error {
+			return os.WriteFile(args[len(args)-1], []byte("video"), 0o600)
+		}
+
+		job, err := m.Start(r)
+		require.NoError(t, err)
+		require.Equal(t, StatusRunning, job.Status)
+
+		job = waitForJob(t, m, job.ID)
+		require.Equal(t, StatusDone, job.Status)
+
+		path, err := m.File(job.ID)
+		require.NoError(t, err)
+		video, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "video", string(video))
+
+		// The retention starts when the job is finished.
+		finished := m.jobs[job.ID].finished
+		require.False(t, finished.Before(job.Created))
+		m.removeExpired(finished.Add(jobRetention - time.Second))
+		_, err = m.Job(job.ID)
+		require.NoError(t, err)
+
+		m.removeExpired(finished.Add(jobRetention))
+		_, err = m.Job(job.ID)
+		require.ErrorIs(t, err, ErrJobNotExist)
+		_, err = os.Stat(filepath.Dir(path))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("locked", func(t *testing.T) {
+		m, r := newTestManager(t)
+		recID := "2000-01-02_00-00-00_a"
+		var lockedDuringExport bool
+		m.run = func(_ context.Context, args []string) error {
+			lockedDuringExport = storage.RecordingLocked(recID)
+			return os.WriteFile(args[len(args)-1], []byte("video"), 0o600)
+		}
+
+		job, err := m.Start(r)
+		require.NoError(t, err)
+		job = waitForJob(t, m, job.ID)
+		require.Equal(t, StatusDone, job.Status)
+		require.True(t, lockedDuringExport)
+		require.False(t, storage.RecordingLocked(recID))
+	})
+	t.Run("failed", func(t *testing.T) {
+		m, r := newTestManager(t)
+		m.run = func(context.Context, []string) error {
+			return errors.New("mock") //nolint:goerr113
+		}
+
+		job, err := m.Start(r)
+		require.NoError(t, err)
+
+		job = waitForJob(t, m, job.ID)
+		require.Equal(t, StatusFailed, job.Status)
+		require.Equal(t, "ffmpeg: mock", job.Error)
+
+		_, err = m.File(job.ID)
+		require.ErrorIs(t, err, ErrJobNotDone)
+	})
+	t.Run("tooManyJobs", func(t *testing.T) {
+		m, r := newTestManager(t)
+		block := make(chan struct{})
+		m.run = func(context.Context, []string) error {
+			<-block
+			return nil
+		}
+		defer close(block)
+
+		for i := 0; i < maxRunningJobs; i++ {
+			_, err := m.Start(r)
+			require.NoError(t, err)
+		}
+		_, err := m.Start(r)
+		require.ErrorIs(t, err, ErrTooManyJobs)
+	})
+	t.Run("invalidRequest", func(t *testing.T) {
+		m, r := newTestManager(t)
+		r.Layout = ""
+		_, err := m.Start(r)
+		require.ErrorIs(t, err, ErrInvalidLayout)
+	})
+	t.Run("notExist", func(t *testing.T) {
+		m, _ := newTestManager(t)
+		_, err := m.File("x")
+		require.ErrorIs(t, err, ErrJobNotExist)
+	})
+}
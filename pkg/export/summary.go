@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package export
+
+import (
+	"errors"
+	"fmt"
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Summary modes.
+const (
+	// The recordings are sped up to fit the duration.
+	SummaryTimelapse = "timelapse"
+
+	// Time-lapse with the motion between frames overlaid as a
+	// heatmap that fades out over two seconds of the summary.
+	SummaryHeatmap = "heatmap"
+)
+
+// DefaultSummaryDuration is the duration of the summary in seconds.
+const DefaultSummaryDuration = 60
+
+// Summary limits.
+const (
+	minSummaryDuration = 10
+	maxSummaryDuration = 600
+	maxSummaryRange    = 24 * time.Hour
+
+	// One recording per minute.
+	maxSummaryClips = 24 * 60
+)
+
+// Summary frame.
+const (
+	summaryWidth  = 1280
+	summaryHeight = 720
+	summaryFPS    = 25
+)
+
+// SummaryRequest summary video request.
+type SummaryRequest struct {
+	Monitor string    `json:"monitor"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Mode    string    `json:"mode"`
+
+	// Duration of the summary in seconds, zero is the default.
+	Duration int `json:"duration,omitempty"`
+
+	// Transcode profile name, empty uses the default profile.
+	Profile string `json:"profile,omitempty"`
+}
+
+// Summary errors.
+var (
+	ErrInvalidMode     = errors.New("invalid summary mode")
+	ErrInvalidDuration = errors.New("invalid summary duration")
+)
+
+// Validate returns an error if the request is invalid.
+func (r SummaryRequest) Validate() error {
+	switch r.Mode {
+	case SummaryTimelapse, SummaryHeatmap:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidMode, r.Mode)
+	}
+	if err := validateMonitorID(r.Monitor); err != nil {
+		return err
+	}
+	if !r.End.After(r.Start) {
+		return fmt.Errorf("%w: end must be after start", ErrInvalidRange)
+	}
+	if r.End.Sub(r.Start) > maxSummaryRange {
+		return fmt.Errorf("%w: max duration %v", ErrInvalidRange, maxSummaryRange)
+	}
+	if r.Duration < minSummaryDuration || r.Duration > maxSummaryDuration {
+		return fmt.Errorf("%w: %v, must be between %v and %v seconds",
+			ErrInvalidDuration, r.Duration, minSummaryDuration, maxSummaryDuration)
+	}
+	return nil
+}
+
+// StartSummary validates the request and starts generating a
+// summary video of the recordings in the background. The job
+// is shared with the exports and the video is served the same way.
+func (m *Manager) StartSummary(r SummaryRequest) (Job, error) {
+	if r.Duration == 0 {
+		r.Duration = DefaultSummaryDuration
+	}
+	if err := r.Validate(); err != nil {
+		return Job{}, err
+	}
+
+	profile := transcode.DefaultProfile
+	if m.profiles != nil {
+		var err error
+		profile, err = m.profiles.Profile(r.Profile)
+		if err != nil {
+			return Job{}, err
+		}
+	}
+
+	clips, err := findMonitorClips(m.recordingsDirs, r.Monitor, r.Start, r.End)
+	if err != nil {
+		return Job{}, err
+	}
+	if len(clips) == 0 {
+		return Job{}, ErrNoRecordings
+	}
+	if len(clips) > maxSummaryClips {
+		return Job{}, fmt.Errorf("%w: %v, max %v", ErrTooManyClips, len(clips), maxSummaryClips)
+	}
+
+	// The recordings can't be pruned until the summary is finished.
+	ids := make([]string, 0, len(clips))
+	for _, c := range clips {
+		ids = append(ids, c.source.RecordingID())
+	}
+	unlock := storage.LockRecordings(ids...)
+
+	job, err := m.startJob(Job{Summary: &r}, func(id string) error {
+		defer unlock()
+		return m.summary(r, profile, clips, id)
+	})
+	if err != nil {
+		unlock()
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// summary speeds up each recording into a part, one at a time
+// so only one recording is materialized, and then joins the parts.
+func (m *Manager) summary(r SummaryRequest, profile transcode.Profile, clips []clip, id string) error {
+	dir := m.jobDir(id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	speed := summarySpeed(r, clips)
+	var list strings.Builder
+	var parts []string
+	for i, c := range clips {
+		inpoint, duration := c.trim(r.Start, r.End)
+		// Less than one frame.
+		if duration.Seconds()/speed < 1.0/summaryFPS {
+			continue
+		}
+
+		input := c.source.Path
+		if needsMaterialize(c.source) {
+			input = filepath.Join(dir, "input.mp4")
+			if err := writeMP4(c.source, input); err != nil {
+				return fmt.Errorf("write mp4: %w", err)
+			}
+		}
+
+		name := "part" + strconv.Itoa(i) + ".mp4"
+		args := summaryPartArgs(c, input, inpoint, duration, speed, filepath.Join(dir, name))
+		if err := m.run(m.ctx, args); err != nil {
+			return fmt.Errorf("ffmpeg: %v: %w", filepath.Base(c.source.Path), err)
+		}
+		if input != c.source.Path {
+			os.Remove(input)
+		}
+		list.WriteString("file '" + name + "'\n")
+		parts = append(parts, name)
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("%w: recordings are too short", ErrNoRecordings)
+	}
+
+	listPath := filepath.Join(dir, "parts.txt")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0o600); err != nil {
+		return err
+	}
+	args := summaryArgs(r, profile, listPath, m.outputPath(id))
+	if err := m.run(m.ctx, args); err != nil {
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	// The parts are no longer needed.
+	os.Remove(listPath)
+	for _, part := range parts {
+		os.Remove(filepath.Join(dir, part))
+	}
+	return nil
+}
+
+// summarySpeed returns how much the recordings are sped
+// up to fit the duration. Gaps between them are skipped.
+func summarySpeed(r SummaryRequest, clips []clip) float64 {
+	var recorded time.Duration
+	for _, c := range clips {
+		_, duration := c.trim(r.Start, r.End)
+		recorded += duration
+	}
+	return max(recorded.Seconds()/float64(r.Duration), 1)
+}
+
+// summaryPartArgs returns the FFmpeg arguments that speed up a recording.
+// Only key frames are decoded, there are more than enough of them. The
+// parts have the same size and frame rate so they can be concatenated.
+func summaryPartArgs(
+	c clip,
+	input string,
+	inpoint time.Duration,
+	duration time.Duration,
+	speed float64,
+	output string,
+) []string {
+	// ffmpeg -skip_frame nokey -ss 5 -t 60 -i input.mp4
+	//   -vf "setpts=(PTS-STARTPTS)/1440,fps=25,scale=...,pad=...,setsar=1"
+	//   -an -c:v libx264 -preset veryfast -crf 18 part0.mp4
+
+	args := []string{"-y", "-loglevel", "error", "-skip_frame", "nokey"}
+	if c.source.Format == storage.PlaybackH264 {
+		args = append(args, "-f", "h264")
+	}
+	args = append(args, "-ss", seconds(inpoint), "-t", seconds(duration), "-i", input)
+
+	filter := fmt.Sprintf(
+		"setpts=(PTS-STARTPTS)/%v,fps=%d,"+
+			"scale=%d:%d:force_original_aspect_ratio=decrease,"+
+			"pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1",
+		strconv.FormatFloat(speed, 'f', 3, 64), summaryFPS,
+		summaryWidth, summaryHeight, summaryWidth, summaryHeight)
+
+	return append(args,
+		"-vf", filter,
+		"-an",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "18", "-pix_fmt", "yuv420p",
+		output,
+	)
+}
+
+// heatmapFilter blends the difference between frames, averaged over
+// two seconds and colored, over the video.
+const heatmapFilter = "split[v0][m];" +
+	"[m]tblend=all_mode=difference,hue=s=0,tmix=frames=50,lutyuv=y=val*8," +
+	"pseudocolor=p=magma[h];" +
+	"[v0][h]blend=all_mode=screen"
+
+// summaryArgs returns the FFmpeg arguments that
+// join the parts and encode them with the profile.
+func summaryArgs(
+	r SummaryRequest,
+	profile transcode.Profile,
+	listPath string,
+	output string,
+) []string {
+	args := []string{"-y", "-loglevel", "error"}
+	args = append(args, profile.InputArgs()...)
+	args = append(args, "-f", "concat", "-safe", "0", "-i", listPath)
+
+	filter := "[0:v]null[out]"
+	if r.Mode == SummaryHeatmap {
+		filter = "[0:v]" + heatmapFilter + "[out]"
+	}
+	out := "[out]"
+	if f := profile.Filter(); f != "" {
+		filter += ";[out]" + f + "[encode]"
+		out = "[encode]"
+	}
+
+	args = append(args, "-filter_complex", filter, "-map", out)
+	args = append(args, profile.OutputArgs()...)
+	return append(args, "-movflags", "+faststart", output)
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryValidate(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := SummaryRequest{
+		Monitor:  "a",
+		Start:    start,
+		End:      start.Add(24 * time.Hour),
+		Mode:     SummaryTimelapse,
+		Duration: 60,
+	}
+	require.NoError(t, valid.Validate())
+
+	cases := map[string]struct {
+		modify func(*SummaryRequest)
+		err    error
+	}{
+		"mode":      {func(r *SummaryRequest) { r.Mode = "x" }, ErrInvalidMode},
+		"monitorID": {func(r *SummaryRequest) { r.Monitor = ".." }, ErrInvalidMonitor},
+		"endStart":  {func(r *SummaryRequest) { r.End = r.Start }, ErrInvalidRange},
+		"tooLong": {
+			func(r *SummaryRequest) { r.End = r.Start.Add(25 * time.Hour) }, ErrInvalidRange,
+		},
+		"tooShort": {func(r *SummaryRequest) { r.Duration = 1 }, ErrInvalidDuration},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := valid
+			tc.modify(&r)
+			require.ErrorIs(t, r.Validate(), tc.err)
+		})
+	}
+}
+
+func TestSummaryArgs(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clip{
+		source: storage.PlaybackSource{Path: "a.h264", Format: storage.PlaybackH264},
+		start:  start.Add(-time.Minute),
+		end:    start.Add(time.Hour),
+	}
+	r := SummaryRequest{Start: start, End: start.Add(time.Hour), Duration: 60}
+
+	speed := summarySpeed(r, []clip{c})
+	require.Equal(t, float64(60), speed)
+
+	inpoint, duration := c.trim(r.Start, r.End)
+	args := strings.Join(summaryPartArgs(c, "a.h264", inpoint, duration, speed, "part0.mp4"), " ")
+	expected := "-y -loglevel error -skip_frame nokey -f h264 -ss 60.000 -t 3600.000 -i a.h264" +
+		" -vf setpts=(PTS-STARTPTS)/60.000,fps=25," +
+		"scale=1280:720:force_original_aspect_ratio=decrease," +
+		"pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1" +
+		" -an -c:v libx264 -preset veryfast -crf 18 -pix_fmt yuv420p part0.mp4"
+	require.Equal(t, expected, args)
+
+	t.Run("timelapse", func(t *testing.T) {
+		r := r
+		r.Mode = SummaryTimelapse
+		args := strings.Join(summaryArgs(r, transcode.DefaultProfile, "parts.txt", "out.mp4"), " ")
+		expected := "-y -loglevel error -f concat -safe 0 -i parts.txt" +
+			" -filter_complex [0:v]null[out] -map [out]" +
+			" -c:v libx264 -preset veryfast -crf 23 -pix_fmt yuv420p" +
+			" -movflags +faststart out.mp4"
+		require.Equal(t, expected, args)
+	})
+	t.Run("heatmap", func(t *testing.T) {
+		r := r
+		r.Mode = SummaryHeatmap
+		profile := transcode.Profile{Codec: transcode.CodecH264, HWAccel: transcode.HWAccelVAAPI}
+		args := strings.Join(summaryArgs(r, profile, "parts.txt", "out.mp4"), " ")
+		expected := "-y -loglevel error -vaapi_device /dev/dri/renderD128" +
+			" -f concat -safe 0 -i parts.txt -filter_complex [0:v]" + heatmapFilter + "[out];" +
+			"[out]format=nv12,hwupload[encode] -map [encode] -c:v h264_vaapi" +
+			" -movflags +faststart out.mp4"
+		require.Equal(t, expected, args)
+	})
+}
+
+func TestStartSummary(t *testing.T) {
+	recordingsDir := t.TempDir()
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	writeRecording(t, recordingsDir, "a", start, time.Hour)
+	// Shorter than one frame of the summary.
+	writeRecording(t, recordingsDir, "a", start.Add(2*time.Hour), time.Second)
+
+	m := NewManager([]string{recordingsDir}, t.TempDir(), "", nil, log.NewDummyLogger())
+	recID := "2000-01-02_00-00-00_a"
+	var calls [][]string
+	var lockedDuringSummary bool
+	m.run = func(_ context.Context, args []string) error {
+		calls = append(calls, args)
+		lockedDuringSummary = storage.RecordingLocked(recID)
+		return os.WriteFile(args[len(args)-1], []byte("video"), 0o600)
+	}
+	r := SummaryRequest{
+		Monitor: "a",
+		Start:   start,
+		End:     start.Add(24 * time.Hour),
+		Mode:    SummaryHeatmap,
+	}
+
+	job, err := m.StartSummary(r)
+	require.NoError(t, err)
+	require.Equal(t, DefaultSummaryDuration, job.Summary.Duration)
+	require.Nil(t, job.Request)
+
+	job = waitForJob(t, m, job.ID)
+	require.Equal(t, StatusDone, job.Status)
+	require.Len(t, calls, 2)
+	require.True(t, lockedDuringSummary)
+	require.False(t, storage.RecordingLocked(recID))
+
+	path, err := m.File(job.ID)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	t.Run("noRecordings", func(t *testing.T) {
+		r := r
+		r.Monitor = "b"
+		_, err := m.StartSummary(r)
+		require.ErrorIs(t, err, ErrNoRecordings)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		r := r
+		r.Duration = maxSummaryDuration + 1
+		_, err := m.StartSummary(r)
+		require.ErrorIs(t, err, ErrInvalidDuration)
+	})
+}
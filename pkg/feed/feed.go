@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package feed buffers live feeds so clients can resume them.
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Item is a value with its cursor.
+type Item[T any] struct {
+	Cursor uint64
+	Value  T
+}
+
+// Buffer keeps the most recent items of a feed. The cursor of each
+// item is one higher than the previous, starting at 1. Clients resume
+// the feed by requesting the items after the last cursor they received.
+type Buffer[T any] struct {
+	items  []Item[T] // Ring buffer.
+	next   uint64    // Cursor of the next item.
+	notify chan struct{}
+	mu     sync.Mutex
+
+	// Identifies the buffer in resume tokens.
+	epoch string
+}
+
+// NewBuffer returns a buffer that keeps the last size items.
+func NewBuffer[T any](size int) *Buffer[T] {
+	return &Buffer[T]{
+		items:  make([]Item[T], size),
+		next:   1,
+		notify: make(chan struct{}),
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// Push adds a value to the feed and wakes up waiting clients.
+func (b *Buffer[T]) Push(value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.items[b.next%uint64(len(b.items))] = Item[T]{
+		Cursor: b.next,
+		Value:  value,
+	}
+	b.next++
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Cursor returns the cursor of the latest item, 0 if the feed is empty.
+func (b *Buffer[T]) Cursor() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.next - 1
+}
+
+// Since returns the buffered items after cursor and a channel that's
+// closed when the next item is pushed. Items that no longer fit in the
+// buffer are skipped, clients can detect this by a gap in the cursors.
+func (b *Buffer[T]) Since(cursor uint64) ([]Item[T], <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := uint64(len(b.items))
+	start := cursor + 1
+	if b.next > size && start < b.next-size {
+		start = b.next - size
+	}
+
+	var items []Item[T]
+	for c := start; c < b.next; c++ {
+		items = append(items, b.items[c%size])
+	}
+	return items, b.notify
+}
+
+// Wait returns the items after cursor. It blocks until there are
+// items or ctx is canceled. A cursor from the future, for example
+// from before a restart, is treated as the latest cursor.
+func (b *Buffer[T]) Wait(ctx context.Context, cursor uint64) []Item[T] {
+	cursor = min(cursor, b.Cursor())
+	for {
+		items, notify := b.Since(cursor)
+		if len(items) != 0 {
+			return items
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Forward pushes the new items of src to dst until ctx is canceled.
+// Items are skipped if convert returns false. Items that are
+// overwritten in src before they're forwarded are lost.
+func Forward[T, U any](ctx context.Context, src *Buffer[T], dst *Buffer[U], convert func(T) (U, bool)) {
+	cursor := src.Cursor()
+	for {
+		items := src.Wait(ctx, cursor)
+		if items == nil {
+			return
+		}
+		for _, item := range items {
+			cursor = item.Cursor
+			if value, ok := convert(item.Value); ok {
+				dst.Push(value)
+			}
+		}
+	}
+}
+
+// ErrInvalidToken invalid resume token.
+var ErrInvalidToken = errors.New("invalid resume token")
+
+// Token returns the resume token of the cursor, "<epoch>-<cursor>".
+// Unlike cursors, tokens from another buffer, for example from before
+// a restart, are detected by ParseToken.
+func (b *Buffer[T]) Token(cursor uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(cursor, 10)
+}
+
+// ParseToken returns the cursor of the resume token. Returns 0 if the
+// token is from another buffer, the client missed all buffered items.
+func (b *Buffer[T]) ParseToken(token string) (uint64, error) {
+	epoch, rawCursor, found := strings.Cut(token, "-")
+	if !found {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidToken, token)
+	}
+	cursor, err := strconv.ParseUint(rawCursor, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidToken, token)
+	}
+	if epoch != b.epoch {
+		return 0, nil
+	}
+	return min(cursor, b.Cursor()), nil
+}
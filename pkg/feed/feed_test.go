@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package feed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Run("since", func(t *testing.T) {
+		b := NewBuffer[string](3)
+		require.Equal(t, uint64(0), b.Cursor())
+
+		items, _ := b.Since(0)
+		require.Empty(t, items)
+
+		b.Push("a")
+		b.Push("b")
+		require.Equal(t, uint64(2), b.Cursor())
+
+		items, _ = b.Since(0)
+		require.Equal(t, []Item[string]{{1, "a"}, {2, "b"}}, items)
+
+		items, _ = b.Since(1)
+		require.Equal(t, []Item[string]{{2, "b"}}, items)
+
+		items, _ = b.Since(2)
+		require.Empty(t, items)
+	})
+	t.Run("overflow", func(t *testing.T) {
+		b := NewBuffer[string](3)
+		for _, v := range []string{"a", "b", "c", "d", "e"} {
+			b.Push(v)
+		}
+		items, _ := b.Since(0)
+		require.Equal(t, []Item[string]{{3, "c"}, {4, "d"}, {5, "e"}}, items)
+
+		items, _ = b.Since(3)
+		require.Equal(t, []Item[string]{{4, "d"}, {5, "e"}}, items)
+	})
+	t.Run("notify", func(t *testing.T) {
+		b := NewBuffer[string](3)
+		_, notify := b.Since(0)
+		b.Push("a")
+		select {
+		case <-notify:
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	})
+	t.Run("wait", func(t *testing.T) {
+		b := NewBuffer[string](3)
+		b.Push("a")
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			b.Push("b")
+		}()
+		items := b.Wait(context.Background(), 1)
+		require.Equal(t, []Item[string]{{2, "b"}}, items)
+	})
+	t.Run("waitCanceled", func(t *testing.T) {
+		b := NewBuffer[string](3)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.Nil(t, b.Wait(ctx, 0))
+	})
+	t.Run("waitFutureCursor", func(t *testing.T) {
+		b := NewBuffer[string](3)
+		b.Push("a")
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			b.Push("b")
+		}()
+		items := b.Wait(context.Background(), 100)
+		require.Equal(t, []Item[string]{{2, "b"}}, items)
+	})
+}
+
+func TestForward(t *testing.T) {
+	src := NewBuffer[string](3)
+	dst := NewBuffer[int](3)
+	src.Push("a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Forward(ctx, src, dst, func(v string) (int, bool) {
+			return len(v), v != "skip"
+		})
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Items pushed before the forward started are not forwarded.
+	src.Push("bb")
+	src.Push("skip")
+	src.Push("ccc")
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second)
+	defer cancel2()
+	var items []Item[int]
+	for len(items) < 2 {
+		items = append(items, dst.Wait(ctx2, uint64(len(items)))...)
+		require.NoError(t, ctx2.Err())
+	}
+	require.Equal(t, []Item[int]{{1, 2}, {2, 3}}, items)
+
+	cancel()
+	<-done
+}
+
+func TestToken(t *testing.T) {
+	b := NewBuffer[string](3)
+	b.Push("a")
+	b.Push("b")
+
+	cursor, err := b.ParseToken(b.Token(1))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), cursor)
+
+	// Cursors from the future are treated as the latest cursor.
+	cursor, err = b.ParseToken(b.Token(100))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), cursor)
+
+	// Tokens from another buffer resume from the start.
+	b2 := NewBuffer[string](3)
+	b2.epoch = "x"
+	cursor, err = b.ParseToken(b2.Token(1))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), cursor)
+
+	for _, token := range []string{"", "x", "x-", "x-a", b.epoch + "--1"} {
+		_, err := b.ParseToken(token)
+		require.ErrorIs(t, err, ErrInvalidToken, token)
+	}
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Capabilities of a FFmpeg binary.
+type Capabilities struct {
+	Version  string   `json:"version"`
+	Hwaccels []string `json:"hwaccels"`
+	Encoders []string `json:"encoders"`
+}
+
+// ProbeCapabilities runs the binary to detect its version,
+// hardware acceleration methods and encoders.
+func ProbeCapabilities(bin string) (*Capabilities, error) {
+	run := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+		cmd := exec.Command(bin, args...)
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%v %v: %w", bin, strings.Join(args, " "), err)
+		}
+		return stdout.String(), nil
+	}
+
+	version, err := run("-hide_banner", "-version")
+	if err != nil {
+		return nil, err
+	}
+	hwaccels, err := run("-hide_banner", "-hwaccels")
+	if err != nil {
+		return nil, err
+	}
+	encoders, err := run("-hide_banner", "-encoders")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Capabilities{
+		Version:  parseVersion(version),
+		Hwaccels: parseHwaccels(hwaccels),
+		Encoders: parseEncoders(encoders),
+	}, nil
+}
+
+// HasEncoder returns true if the encoder is available. Only
+// the first word is checked, "libx264 -preset fast" is valid.
+func (c *Capabilities) HasEncoder(encoder string) bool {
+	if c == nil {
+		return false
+	}
+	fields := strings.Fields(encoder)
+	if len(fields) == 0 {
+		return false
+	}
+	for _, e := range c.Encoders {
+		if e == fields[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// Input "ffmpeg version 4.4.2 Copyright..."
+// Output "4.4.2"
+func parseVersion(input string) string {
+	line := strings.SplitN(strings.TrimSpace(input), "\n", 2)[0]
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[1] != "version" {
+		return ""
+	}
+	return fields[2]
+}
+
+// Input
+//
+//	Hardware acceleration methods:
+//	vdpau
+//	vaapi
+//
+// Output ["vdpau", "vaapi"]
+func parseHwaccels(input string) []string {
+	hwaccels := []string{}
+	lines := strings.Split(strings.TrimSpace(input), "\n")
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			hwaccels = append(hwaccels, line)
+		}
+	}
+	return hwaccels
+}
+
+// Input
+//
+//	Encoders:
+//	 V..... = Video
+//	 ...
+//	 ------
+//	 V....D libx264    libx264 H.264 / AVC
+//	 A....D aac        AAC (Advanced Audio Coding)
+//
+// Output ["libx264", "aac"]
+func parseEncoders(input string) []string {
+	encoders := []string{}
+	var started bool
+	for _, line := range strings.Split(input, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if !started {
+			started = strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 {
+			encoders = append(encoders, fields[1])
+		}
+	}
+	return encoders
+}
@@ -34,6 +34,7 @@ type mockProcess struct {
 func (m mockProcess) Timeout(time.Duration) ffmpeg.Process       { return m }
 func (m mockProcess) StdoutLogger(ffmpeg.LogFunc) ffmpeg.Process { return m }
 func (m mockProcess) StderrLogger(ffmpeg.LogFunc) ffmpeg.Process { return m }
+func (m mockProcess) OnStart(func(int)) ffmpeg.Process           { return m }
 
 func (m mockProcess) Start(ctx context.Context) error {
 	if m.c.Sleep != 0 {
@@ -31,6 +31,9 @@ type Process interface {
 	// Set function called on stderr line.
 	StderrLogger(LogFunc) Process
 
+	// Set function called with the process ID after the process has started.
+	OnStart(func(pid int)) Process
+
 	// Start process with context.
 	Start(ctx context.Context) error
 
@@ -45,6 +48,7 @@ type process struct {
 
 	stdoutLogger LogFunc
 	stderrLogger LogFunc
+	onStart      func(int)
 
 	done chan struct{}
 }
@@ -75,6 +79,11 @@ func (p process) StderrLogger(l LogFunc) Process {
 	return p
 }
 
+func (p process) OnStart(f func(int)) Process {
+	p.onStart = f
+	return p
+}
+
 func (p process) Start(ctx context.Context) error {
 	if p.stdoutLogger != nil {
 		pipe, err := p.cmd.StdoutPipe()
@@ -94,6 +103,9 @@ func (p process) Start(ctx context.Context) error {
 	if err := p.cmd.Start(); err != nil {
 		return err
 	}
+	if p.onStart != nil {
+		p.onStart(p.cmd.Process.Pid)
+	}
 
 	p.done = make(chan struct{})
 
@@ -141,40 +153,41 @@ func (p process) Stop() {
 
 // FFMPEG stores ffmpeg binary location.
 type FFMPEG struct {
-	command func(...string) *exec.Cmd
+	command func(context.Context, ...string) *exec.Cmd
 }
 
 // New returns FFMPEG.
 func New(bin string) *FFMPEG {
-	command := func(args ...string) *exec.Cmd {
-		return exec.Command(bin, args...)
+	command := func(ctx context.Context, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, bin, args...)
 	}
 	return &FFMPEG{command: command}
 }
 
-/*
-func HWaccels(bin string) ([]string, error) {
-	cmd := exec.Command(bin, "-hwaccels")
-
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-
+// Remux copies the streams of the input file to w as fragmented MP4
+// without transcoding. Fragmented MP4 can be played by browsers
+// while it's being written. The input format is probed if empty.
+func (f *FFMPEG) Remux(ctx context.Context, w io.Writer, input string, inputFormat string) error {
+	args := []string{"-loglevel", "error"}
+	if inputFormat != "" {
+		args = append(args, "-f", inputFormat)
+	}
+	args = append(args,
+		"-i", input,
+		"-c", "copy",
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4", "pipe:1",
+	)
+
+	var stderr strings.Builder
+	cmd := f.command(ctx, args...)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return []string{}, fmt.Errorf("%v", err)
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
 	}
-
-	// Input
-	//   accels Hardware acceleration methods:
-	//   vdpau
-	//   vaapi
-
-	// Output ["vdpau", "vaapi"]
-	input := strings.TrimSpace(stdout.String())
-	lines := strings.Split(input, "\n")
-
-	return lines[1:], nil
+	return nil
 }
-*/
 
 // Rect top, left, bottom, right.
 type Rect [4]int
@@ -3,6 +3,7 @@
 package ffmpeg
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"os"
@@ -131,6 +132,32 @@ func imageToText(img image.Image) string {
 	return text
 }
 
+func TestRemux(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		f := &FFMPEG{command: func(ctx context.Context, args ...string) *exec.Cmd {
+			return exec.CommandContext(ctx, "echo", args...)
+		}}
+
+		var out strings.Builder
+		err := f.Remux(context.Background(), &out, "a.h264", "h264")
+		require.NoError(t, err)
+
+		expected := "-loglevel error -f h264 -i a.h264 -c copy -movflags" +
+			" frag_keyframe+empty_moov+default_base_moof -f mp4 pipe:1\n"
+		require.Equal(t, expected, out.String())
+	})
+	t.Run("err", func(t *testing.T) {
+		f := &FFMPEG{command: func(ctx context.Context, args ...string) *exec.Cmd {
+			return exec.CommandContext(ctx, "sh", "-c", "echo msg >&2; exit 1")
+		}}
+
+		var out strings.Builder
+		err := f.Remux(context.Background(), &out, "a.mp4", "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "msg")
+	})
+}
+
 func TestPolygonToAbs(t *testing.T) {
 	polygon := Polygon{
 		Point{5, 10},
@@ -387,3 +414,35 @@ func TestParseTimestampOffset(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestParseCapabilities(t *testing.T) {
+	t.Run("version", func(t *testing.T) {
+		input := "ffmpeg version 4.4.2-0ubuntu0.22.04.1 Copyright (c) 2000-2021\nbuilt with gcc"
+		require.Equal(t, "4.4.2-0ubuntu0.22.04.1", parseVersion(input))
+		require.Equal(t, "", parseVersion("x"))
+	})
+	t.Run("hwaccels", func(t *testing.T) {
+		input := "Hardware acceleration methods:\nvdpau\nvaapi\n\n"
+		require.Equal(t, []string{"vdpau", "vaapi"}, parseHwaccels(input))
+		require.Equal(t, []string{}, parseHwaccels(""))
+	})
+	t.Run("encoders", func(t *testing.T) {
+		input := `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+		require.Equal(t, []string{"libx264", "aac"}, parseEncoders(input))
+	})
+	t.Run("hasEncoder", func(t *testing.T) {
+		c := &Capabilities{Encoders: []string{"libx264"}}
+		require.True(t, c.HasEncoder("libx264 -preset veryfast"))
+		require.False(t, c.HasEncoder("copy"))
+		require.False(t, c.HasEncoder(""))
+
+		var nilCapabilities *Capabilities
+		require.False(t, nilCapabilities.HasEncoder("libx264"))
+	})
+}
@@ -28,6 +28,9 @@ type Manager struct {
 	Groups groups
 	path   string
 	mu     sync.Mutex
+
+	// Incremented when a config is set or deleted.
+	version uint64
 }
 
 // NewManager return new group manager.
@@ -91,6 +94,7 @@ func (m *Manager) GroupSet(id string, c Config) error {
 		group = m.newGroup(c)
 		m.Groups[id] = group
 	}
+	m.version++
 
 	// Update file.
 	group.mu.Lock()
@@ -108,6 +112,49 @@ func (m *Manager) GroupSet(id string, c Config) error {
 // ErrGroupNotExist group does not exist.
 var ErrGroupNotExist = errors.New("group does not exist")
 
+// MonitorIDs returns the IDs of the monitors in the group.
+func (m *Manager) MonitorIDs(id string) ([]string, error) {
+	m.mu.Lock()
+	group, exists := m.Groups[id]
+	m.mu.Unlock()
+	if !exists {
+		return nil, ErrGroupNotExist
+	}
+
+	group.mu.Lock()
+	config := group.Config
+	group.mu.Unlock()
+
+	return config.monitors()
+}
+
+func (c Config) monitors() ([]string, error) {
+	monitors := []string{}
+	if c["monitors"] == "" {
+		return monitors, nil
+	}
+	if err := json.Unmarshal([]byte(c["monitors"]), &monitors); err != nil {
+		return nil, fmt.Errorf("unmarshal monitors: %w", err)
+	}
+	return monitors, nil
+}
+
+// Layout returns the live layout of the group.
+func (m *Manager) Layout(id string) (*Layout, error) {
+	m.mu.Lock()
+	group, exists := m.Groups[id]
+	m.mu.Unlock()
+	if !exists {
+		return nil, ErrGroupNotExist
+	}
+
+	group.mu.Lock()
+	config := group.Config
+	group.mu.Unlock()
+
+	return config.Layout()
+}
+
 // GroupDelete deletes group by id.
 func (m *Manager) GroupDelete(id string) error {
 	defer m.mu.Unlock()
@@ -120,6 +167,7 @@ func (m *Manager) GroupDelete(id string) error {
 	}
 
 	delete(m.Groups, id)
+	m.version++
 
 	if err := os.Remove(m.configPath(id)); err != nil {
 		return err
@@ -132,6 +180,14 @@ func (m *Manager) configPath(id string) string {
 	return m.path + "/" + id + ".json"
 }
 
+// Version returns a number that changes every time a group
+// config is set or deleted. Used to cache encoded configs.
+func (m *Manager) Version() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.version
+}
+
 // Configs returns configurations for all groups.
 func (m *Manager) Configs() map[string]Config {
 	configs := make(map[string]Config)
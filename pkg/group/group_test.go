@@ -108,8 +108,10 @@ func TestGroupSet(t *testing.T) {
 		config := manager.Groups["1"].Config
 		config["name"] = "new"
 
+		version := manager.Version()
 		err := manager.GroupSet("new", config)
 		require.NoError(t, err)
+		require.Equal(t, version+1, manager.Version())
 
 		newName := manager.Groups["new"].Config["name"]
 		require.Equal(t, newName, "new")
@@ -158,8 +160,10 @@ func TestGroupDelete(t *testing.T) {
 
 		require.NotNil(t, manager.Groups["1"])
 
+		version := manager.Version()
 		err := manager.GroupDelete("1")
 		require.NoError(t, err)
+		require.Equal(t, version+1, manager.Version())
 
 		require.Nil(t, manager.Groups["1"])
 	})
@@ -189,3 +193,40 @@ func TestGroupConfigs(t *testing.T) {
 	expected := "map[1:map[id:1 monitors:[\"1\"] name:one] 2:map[id:2 monitors:[\"2\"] name:two]]"
 	require.Equal(t, actual, expected)
 }
+
+func TestMonitorIDs(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		_, manager, cancel := newTestManager(t)
+		defer cancel()
+
+		monitors, err := manager.MonitorIDs("1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"1"}, monitors)
+	})
+	t.Run("empty", func(t *testing.T) {
+		_, manager, cancel := newTestManager(t)
+		defer cancel()
+
+		manager.Groups["1"].Config = Config{"id": "1"}
+
+		monitors, err := manager.MonitorIDs("1")
+		require.NoError(t, err)
+		require.Equal(t, []string{}, monitors)
+	})
+	t.Run("existErr", func(t *testing.T) {
+		_, manager, cancel := newTestManager(t)
+		defer cancel()
+
+		_, err := manager.MonitorIDs("nil")
+		require.ErrorIs(t, err, ErrGroupNotExist)
+	})
+	t.Run("unmarshalErr", func(t *testing.T) {
+		_, manager, cancel := newTestManager(t)
+		defer cancel()
+
+		manager.Groups["1"].Config["monitors"] = "nil"
+
+		_, err := manager.MonitorIDs("1")
+		require.Error(t, err)
+	})
+}
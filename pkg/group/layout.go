@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package group
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Layout is what a display shows when it views the group.
+// The settings are stored as flat keys in the group config.
+type Layout struct {
+	// Rows and Columns of the grid. Zero if
+	// unset, the viewer picks the grid size.
+	Rows    int `json:"rows"`
+	Columns int `json:"columns"`
+
+	// Monitor IDs in tile order. Monitors that aren't
+	// in the tile order are appended in group order.
+	Order []string `json:"order"`
+
+	// Seconds between pages if the monitors don't fit
+	// in the grid. Zero disables rotation.
+	RotationInterval int `json:"rotationInterval"`
+
+	// StreamQuality "main", "sub" or "auto". Empty if unset.
+	StreamQuality string `json:"streamQuality"`
+}
+
+// Layout config keys.
+const (
+	KeyGridRows         = "gridRows"
+	KeyGridColumns      = "gridColumns"
+	KeyTileOrder        = "tileOrder"
+	KeyRotationInterval = "rotationInterval"
+	KeyStreamQuality    = "streamQuality"
+)
+
+// Layout limits.
+const (
+	MaxGridSize         = 8
+	MinRotationInterval = 5
+	MaxRotationInterval = 3600
+)
+
+// Layout errors.
+var (
+	ErrInvalidGridSize         = errors.New("invalid grid size")
+	ErrInvalidTileOrder        = errors.New("invalid tile order")
+	ErrInvalidRotationInterval = errors.New("invalid rotation interval")
+	ErrInvalidStreamQuality    = errors.New("invalid stream quality")
+)
+
+// Validate returns a error if the layout settings are invalid.
+func (c Config) Validate() error {
+	_, err := c.Layout()
+	return err
+}
+
+// Layout parses and validates the layout settings.
+func (c Config) Layout() (*Layout, error) {
+	monitors, err := c.monitors()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := parseGridSize(c[KeyGridRows])
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", KeyGridRows, err)
+	}
+	columns, err := parseGridSize(c[KeyGridColumns])
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", KeyGridColumns, err)
+	}
+	if (rows == 0) != (columns == 0) {
+		return nil, fmt.Errorf("%w: rows and columns must both be set", ErrInvalidGridSize)
+	}
+
+	order, err := parseTileOrder(c[KeyTileOrder], monitors)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", KeyTileOrder, err)
+	}
+
+	interval, err := parseRotationInterval(c[KeyRotationInterval])
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", KeyRotationInterval, err)
+	}
+
+	quality := c[KeyStreamQuality]
+	switch quality {
+	case "", "main", "sub", "auto":
+	default:
+		return nil, fmt.Errorf("%v: %w: %q", KeyStreamQuality, ErrInvalidStreamQuality, quality)
+	}
+
+	return &Layout{
+		Rows:             rows,
+		Columns:          columns,
+		Order:            order,
+		RotationInterval: interval,
+		StreamQuality:    quality,
+	}, nil
+}
+
+func parseGridSize(raw string) (int, error) {
+	if raw == "" || raw == "0" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < 1 || size > MaxGridSize {
+		return 0, fmt.Errorf("%w: %q, must be between 1 and %v", ErrInvalidGridSize, raw, MaxGridSize)
+	}
+	return size, nil
+}
+
+// parseTileOrder returns the monitors in tile order. Monitors that
+// were removed from the group after the order was saved are dropped.
+func parseTileOrder(raw string, monitors []string) ([]string, error) {
+	var tiles []string
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &tiles); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTileOrder, err)
+		}
+	}
+
+	inGroup := make(map[string]bool, len(monitors))
+	for _, id := range monitors {
+		inGroup[id] = true
+	}
+
+	order := make([]string, 0, len(monitors))
+	added := make(map[string]bool, len(monitors))
+	for _, id := range tiles {
+		if !inGroup[id] {
+			continue
+		}
+		if added[id] {
+			return nil, fmt.Errorf("%w: duplicate monitor: %q", ErrInvalidTileOrder, id)
+		}
+		order = append(order, id)
+		added[id] = true
+	}
+	for _, id := range monitors {
+		if !added[id] {
+			order = append(order, id)
+			added[id] = true
+		}
+	}
+	return order, nil
+}
+
+func parseRotationInterval(raw string) (int, error) {
+	if raw == "" || raw == "0" {
+		return 0, nil
+	}
+	interval, err := strconv.Atoi(raw)
+	if err != nil || interval < MinRotationInterval || interval > MaxRotationInterval {
+		return 0, fmt.Errorf("%w: %q, must be 0 or between %v and %v",
+			ErrInvalidRotationInterval, raw, MinRotationInterval, MaxRotationInterval)
+	}
+	return interval, nil
+}
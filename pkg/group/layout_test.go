@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayout(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config := Config{"id": "1", "monitors": `["a","b"]`}
+		layout, err := config.Layout()
+		require.NoError(t, err)
+		require.Equal(t, &Layout{Order: []string{"a", "b"}}, layout)
+	})
+	t.Run("ok", func(t *testing.T) {
+		config := Config{
+			"monitors":         `["a","b","c"]`,
+			"gridRows":         "2",
+			"gridColumns":      "3",
+			"tileOrder":        `["c","a"]`,
+			"rotationInterval": "30",
+			"streamQuality":    "sub",
+		}
+		layout, err := config.Layout()
+		require.NoError(t, err)
+		want := &Layout{
+			Rows:             2,
+			Columns:          3,
+			Order:            []string{"c", "a", "b"},
+			RotationInterval: 30,
+			StreamQuality:    "sub",
+		}
+		require.Equal(t, want, layout)
+	})
+	t.Run("staleOrder", func(t *testing.T) {
+		config := Config{"monitors": `["a","c"]`, "tileOrder": `["c","b","a"]`}
+		layout, err := config.Layout()
+		require.NoError(t, err)
+		require.Equal(t, []string{"c", "a"}, layout.Order)
+	})
+	cases := map[string]struct {
+		config Config
+		err    error
+	}{
+		"rowsTooLarge":     {Config{"gridRows": "9", "gridColumns": "1"}, ErrInvalidGridSize},
+		"rowsNotNumber":    {Config{"gridRows": "a", "gridColumns": "1"}, ErrInvalidGridSize},
+		"columnsNegative":  {Config{"gridRows": "1", "gridColumns": "-1"}, ErrInvalidGridSize},
+		"onlyRows":         {Config{"gridRows": "2"}, ErrInvalidGridSize},
+		"orderDuplicate":   {Config{"monitors": `["a"]`, "tileOrder": `["a","a"]`}, ErrInvalidTileOrder},
+		"orderUnmarshal":   {Config{"tileOrder": "nil"}, ErrInvalidTileOrder},
+		"intervalTooShort": {Config{"rotationInterval": "4"}, ErrInvalidRotationInterval},
+		"intervalTooLong":  {Config{"rotationInterval": "3601"}, ErrInvalidRotationInterval},
+		"quality":          {Config{"streamQuality": "high"}, ErrInvalidStreamQuality},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, tc.config.Validate(), tc.err)
+		})
+	}
+}
+
+func TestManagerLayout(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		_, manager, cancel := newTestManager(t)
+		defer cancel()
+
+		layout, err := manager.Layout("1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"1"}, layout.Order)
+	})
+	t.Run("existErr", func(t *testing.T) {
+		_, manager, cancel := newTestManager(t)
+		defer cancel()
+
+		_, err := manager.Layout("nil")
+		require.ErrorIs(t, err, ErrGroupNotExist)
+	})
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package gstreamer detects the capabilities of a GStreamer installation.
+// Some boards only expose their hardware codecs through GStreamer plugins.
+package gstreamer
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Capabilities of a GStreamer installation.
+type Capabilities struct {
+	Version  string   `json:"version"`
+	Elements []string `json:"elements"`
+}
+
+// ProbeCapabilities runs gst-launch and gst-inspect to detect the
+// version and available elements. gst-inspect-1.0 is expected to
+// be in the same directory as the gst-launch-1.0 binary.
+func ProbeCapabilities(launchBin string) (*Capabilities, error) {
+	run := func(bin string, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		cmd := exec.Command(bin, args...)
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%v: %w", bin, err)
+		}
+		return stdout.String(), nil
+	}
+
+	version, err := run(launchBin, "--version")
+	if err != nil {
+		return nil, err
+	}
+
+	inspectBin := filepath.Join(filepath.Dir(launchBin), "gst-inspect-1.0")
+	elements, err := run(inspectBin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Capabilities{
+		Version:  parseVersion(version),
+		Elements: parseElements(elements),
+	}, nil
+}
+
+// HasElement returns true if the element is available. Only
+// the first word is checked, "x264enc speed-preset=1" is valid.
+func (c *Capabilities) HasElement(element string) bool {
+	if c == nil {
+		return false
+	}
+	fields := strings.Fields(element)
+	if len(fields) == 0 {
+		return false
+	}
+	i := sort.SearchStrings(c.Elements, fields[0])
+	return i < len(c.Elements) && c.Elements[i] == fields[0]
+}
+
+// Input
+//
+//	gst-launch-1.0 version 1.20.3
+//	GStreamer 1.20.3
+//
+// Output "1.20.3"
+func parseVersion(input string) string {
+	for _, line := range strings.Split(input, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "GStreamer" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// Input
+//
+//	coreelements:  fakesink: Fake Sink
+//	x264:  x264enc: x264 H.264 Encoder
+//
+//	Total count: 2 plugins, 2 features
+//
+// Output ["fakesink", "x264enc"]
+func parseElements(input string) []string {
+	elements := []string{}
+	for _, line := range strings.Split(input, "\n") {
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		element := strings.TrimSpace(parts[1])
+		if element == "" || strings.Contains(element, " ") {
+			continue
+		}
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	return elements
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package gstreamer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	input := "gst-launch-1.0 version 1.20.3\nGStreamer 1.20.3\nhttps://launchpad.net\n"
+	require.Equal(t, "1.20.3", parseVersion(input))
+	require.Equal(t, "", parseVersion(""))
+}
+
+func TestParseElements(t *testing.T) {
+	input := `x264:  x264enc: x264 H.264 Encoder
+coreelements:  fakesink: Fake Sink
+staticelements:  bin: Generic bin
+
+Total count: 3 plugins, 3 features
+`
+	expected := []string{"bin", "fakesink", "x264enc"}
+	require.Equal(t, expected, parseElements(input))
+}
+
+func TestHasElement(t *testing.T) {
+	c := &Capabilities{Elements: []string{"fakesink", "x264enc"}}
+	require.True(t, c.HasElement("x264enc speed-preset=1"))
+	require.False(t, c.HasElement("v4l2h264enc"))
+	require.False(t, c.HasElement(""))
+
+	var nilCapabilities *Capabilities
+	require.False(t, nilCapabilities.HasElement("x264enc"))
+}
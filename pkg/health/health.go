@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package health reports the readiness and liveness of the
+// app to container orchestrators like Docker and Kubernetes.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health check errors.
+var (
+	ErrNotReady = errors.New("not ready")
+	ErrNotLive  = errors.New("not live")
+)
+
+// A subsystem is stalled if it misses this many heartbeats in a row.
+const missedHeartbeats = 3
+
+// Checker tracks the startup of the components that are required for
+// readiness and the heartbeats of the subsystems for liveness.
+type Checker struct {
+	mu         sync.Mutex
+	components []string // Required for readiness, in startup order.
+	started    map[string]bool
+	checks     map[string]func() error
+	heartbeats map[string]*heartbeat
+
+	now func() time.Time
+}
+
+type heartbeat struct {
+	last    time.Time
+	timeout time.Duration
+}
+
+// NewChecker returns a checker that isn't ready
+// until every component has been started.
+func NewChecker(components ...string) *Checker {
+	return &Checker{
+		components: components,
+		started:    make(map[string]bool),
+		checks:     make(map[string]func() error),
+		heartbeats: make(map[string]*heartbeat),
+		now:        time.Now,
+	}
+}
+
+// SetStarted marks the component as started.
+func (c *Checker) SetStarted(component string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started[component] = true
+}
+
+// AddCheck adds a check that's called on every readiness check after
+// the component has been started, the app isn't ready if it fails.
+func (c *Checker) AddCheck(component string, check func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[component] = check
+}
+
+// Ready returns an error if a component hasn't been started or its check fails.
+func (c *Checker) Ready() error {
+	c.mu.Lock()
+	components := make([]string, 0, len(c.components))
+	checks := make(map[string]func() error)
+	for _, component := range c.components {
+		if !c.started[component] {
+			components = append(components, component)
+		} else if check, exist := c.checks[component]; exist {
+			checks[component] = check
+		}
+	}
+	c.mu.Unlock()
+
+	var reasons []string
+	if len(components) != 0 {
+		reasons = append(reasons, "not started: "+strings.Join(components, ", "))
+	}
+	// The checks may block, they're called without the lock.
+	for _, component := range c.components {
+		check, exist := checks[component]
+		if !exist {
+			continue
+		}
+		if err := check(); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%v: %v", component, err))
+		}
+	}
+	if len(reasons) != 0 {
+		return fmt.Errorf("%w: %v", ErrNotReady, strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// Watch registers a heartbeat for the subsystem and calls the probe every
+// interval until the context is canceled. The probe should go through the
+// locks or channels of the subsystem, so that the heartbeat stops if the
+// subsystem is deadlocked. Blocks until the context is canceled.
+func (c *Checker) Watch(ctx context.Context, name string, interval time.Duration, probe func()) {
+	c.mu.Lock()
+	c.heartbeats[name] = &heartbeat{
+		last:    c.now(),
+		timeout: missedHeartbeats * interval,
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.heartbeats, name)
+		c.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			probe()
+			c.beat(name)
+		}
+	}
+}
+
+func (c *Checker) beat(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hb, exist := c.heartbeats[name]; exist {
+		hb.last = c.now()
+	}
+}
+
+// Live returns an error if a subsystem has stopped sending heartbeats.
+func (c *Checker) Live() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var stalled []string
+	for name, hb := range c.heartbeats {
+		if since := now.Sub(hb.last); since > hb.timeout {
+			stalled = append(stalled, fmt.Sprintf("%v (last heartbeat %v ago)",
+				name, since.Truncate(time.Second)))
+		}
+	}
+	if len(stalled) != 0 {
+		sort.Strings(stalled)
+		return fmt.Errorf("%w: stalled: %v", ErrNotLive, strings.Join(stalled, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	c := NewChecker("a", "b")
+	err := c.Ready()
+	require.ErrorIs(t, err, ErrNotReady)
+	require.Equal(t, "not ready: not started: a, b", err.Error())
+
+	checkErr := errors.New("mock") //nolint:goerr113
+	c.AddCheck("b", func() error { return checkErr })
+	c.SetStarted("a")
+	require.Equal(t, "not ready: not started: b", c.Ready().Error())
+
+	// The check is only called after the component has been started.
+	c.SetStarted("b")
+	require.Equal(t, "not ready: b: mock", c.Ready().Error())
+
+	checkErr = nil
+	require.NoError(t, c.Ready())
+}
+
+func TestLive(t *testing.T) {
+	t.Run("stalled", func(t *testing.T) {
+		c := NewChecker()
+		now := time.Unix(1000, 0)
+		c.now = func() time.Time { return now }
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		block := make(chan struct{})
+		defer close(block)
+		go c.Watch(ctx, "x", time.Millisecond, func() { <-block })
+
+		require.Eventually(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			_, exist := c.heartbeats["x"]
+			return exist
+		}, time.Second, time.Millisecond)
+		require.NoError(t, c.Live())
+
+		now = now.Add(time.Second)
+		err := c.Live()
+		require.ErrorIs(t, err, ErrNotLive)
+		require.Equal(t, "not live: stalled: x (last heartbeat 1s ago)", err.Error())
+	})
+	t.Run("beat", func(t *testing.T) {
+		c := NewChecker()
+		ctx, cancel := context.WithCancel(context.Background())
+		probed := make(chan struct{}, 1)
+		done := make(chan struct{})
+		go func() {
+			c.Watch(ctx, "x", time.Millisecond, func() {
+				select {
+				case probed <- struct{}{}:
+				default:
+				}
+			})
+			close(done)
+		}()
+		<-probed
+		require.NoError(t, c.Live())
+
+		// The heartbeat is removed when the watch stops.
+		cancel()
+		<-done
+		require.Empty(t, c.heartbeats)
+	})
+}
@@ -0,0 +1,595 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package ingest accepts finished recordings from edge devices, for example
+// battery or LTE cameras that record locally and upload their clips later.
+//
+// Admins issue upload tokens for a monitor so the device can upload without
+// a account. A token is the token ID and a HMAC signature, the tokens are
+// also stored so that admins can list and revoke them and so the total
+// size of the uploads can be limited. The clips are saved as MP4
+// recordings of the monitor next to the recordings of the recorder.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"nvr/pkg/crypt"
+	"nvr/pkg/kv"
+	"nvr/pkg/storage"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token limits.
+const (
+	defaultDuration = time.Hour
+	maxDuration     = 30 * 24 * time.Hour
+
+	// Megabytes.
+	defaultMaxSize      = 512
+	maxMaxSize          = 8192
+	defaultMaxTotalSize = 4096
+	maxMaxTotalSize     = 1000 * 1000
+
+	// Tokens are kept for the audit this long after they expired.
+	auditRetention = 90 * 24 * time.Hour
+)
+
+// Recording limits.
+const (
+	maxRecordingLength = 24 * time.Hour
+	maxEvents          = 1000
+
+	// Allowed clock difference between the device and the server.
+	maxClockSkew = 5 * time.Minute
+)
+
+// Database buckets.
+const (
+	keyBucket   = "ingest"
+	tokenBucket = "ingest-tokens"
+)
+
+// Temporary video file, "YYYY-MM-DD_hh-mm-ss_monitor.mp4.upload".
+const uploadExt = ".mp4.upload"
+
+// Errors.
+var (
+	ErrInvalidRequest  = errors.New("invalid presign request")
+	ErrInvalidToken    = errors.New("invalid upload token")
+	ErrTokenNotExist   = errors.New("upload token does not exist")
+	ErrExpired         = errors.New("upload token expired")
+	ErrRevoked         = errors.New("upload token revoked")
+	ErrTotalSize       = errors.New("upload token total size reached")
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	ErrInvalidVideo    = errors.New("invalid video")
+	ErrRecordingExist  = errors.New("recording already exists")
+)
+
+// PresignRequest is a request to issue a upload token.
+type PresignRequest struct {
+	MonitorID string `json:"monitorId"`
+
+	// Seconds until the token expires, defaults to a hour.
+	Duration int `json:"duration,omitempty"`
+
+	// Maximum size of a clip in megabytes, defaults to 512.
+	MaxSize int `json:"maxSize,omitempty"`
+
+	// Maximum size of all clips in megabytes, defaults to 4096.
+	MaxTotalSize int `json:"maxTotalSize,omitempty"`
+}
+
+// UploadToken is a issued upload token.
+type UploadToken struct {
+	ID        string    `json:"id"`
+	MonitorID string    `json:"monitorId"`
+	CreatedBy string    `json:"createdBy"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+
+	// Bytes.
+	MaxSize      int64 `json:"maxSize"`
+	MaxTotalSize int64 `json:"maxTotalSize"`
+
+	// Set if a admin revoked the token.
+	RevokedBy string    `json:"revokedBy,omitempty"`
+	Revoked   time.Time `json:"revoked,omitempty"`
+
+	// Number of uploaded clips and their total size in bytes.
+	Uses      int       `json:"uses"`
+	BytesUsed int64     `json:"bytesUsed"`
+	LastUsed  time.Time `json:"lastUsed,omitempty"`
+}
+
+// Metadata of a uploaded clip.
+type Metadata struct {
+	Start  time.Time       `json:"start"`
+	End    time.Time       `json:"end"`
+	Events []storage.Event `json:"events,omitempty"`
+}
+
+// RecordingsDirFunc returns the recordings directory for a new recording.
+type RecordingsDirFunc func(monitorID string) (string, error)
+
+// Manager issues upload tokens and saves the uploaded clips.
+type Manager struct {
+	db            *kv.DB
+	key           []byte
+	recordingsDir RecordingsDirFunc
+	ffmpegBin     string
+
+	// Thumbnails aren't encrypted, see storage.RecordingEncryption.
+	plainThumbnails bool
+
+	// Bytes reserved by uploads in progress, by token ID.
+	reserved map[string]int64
+	mu       sync.Mutex
+
+	now func() time.Time
+}
+
+// NewManager loads or creates the signing key.
+func NewManager(
+	db *kv.DB,
+	recordingsDir RecordingsDirFunc,
+	ffmpegBin string,
+	plainThumbnails bool,
+) (*Manager, error) {
+	var key []byte
+	err := db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(keyBucket)
+		if k := bucket.Get("key"); k != nil {
+			key = append([]byte(nil), k...)
+			return nil
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		return bucket.Put("key", key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load ingest key: %w", err)
+	}
+	return &Manager{
+		db:              db,
+		key:             key,
+		recordingsDir:   recordingsDir,
+		ffmpegBin:       ffmpegBin,
+		plainThumbnails: plainThumbnails,
+		reserved:        make(map[string]int64),
+		now:             time.Now,
+	}, nil
+}
+
+func (m *Manager) sign(t UploadToken) string {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(strings.Join([]string{
+		t.ID,
+		t.MonitorID,
+		strconv.FormatInt(t.Expires.Unix(), 10),
+	}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// megabytes validates a size in megabytes and returns it in bytes.
+func megabytes(name string, size int, defaultSize int, maxSize int) (int64, error) {
+	switch {
+	case size < 0 || size > maxSize:
+		return 0, fmt.Errorf("%w: %v must be between 0 and %v",
+			ErrInvalidRequest, name, maxSize)
+	case size == 0:
+		size = defaultSize
+	}
+	return int64(size) * 1000 * 1000, nil
+}
+
+// Presign issues a upload token and returns it with the token string,
+// "<id>.<signature>". Tokens can be used for more than one clip until
+// they expire, are revoked or the total size is reached.
+func (m *Manager) Presign(req PresignRequest, username string) (*UploadToken, string, error) {
+	id := req.MonitorID
+	if id == "" || id == ".." || strings.ContainsAny(id, "\n/\\") {
+		return nil, "", fmt.Errorf("%w: monitor id %q", ErrInvalidRequest, req.MonitorID)
+	}
+
+	duration := time.Duration(req.Duration) * time.Second
+	switch {
+	case req.Duration < 0 || duration > maxDuration:
+		return nil, "", fmt.Errorf("%w: duration must be between 0 and %v",
+			ErrInvalidRequest, maxDuration)
+	case req.Duration == 0:
+		duration = defaultDuration
+	}
+	maxSize, err := megabytes("max size", req.MaxSize, defaultMaxSize, maxMaxSize)
+	if err != nil {
+		return nil, "", err
+	}
+	maxTotalSize, err := megabytes(
+		"max total size", req.MaxTotalSize, defaultMaxTotalSize, maxMaxTotalSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tokenID := make([]byte, 16)
+	if _, err := rand.Read(tokenID); err != nil {
+		return nil, "", err
+	}
+	now := m.now()
+	t := UploadToken{
+		ID:           hex.EncodeToString(tokenID),
+		MonitorID:    req.MonitorID,
+		CreatedBy:    username,
+		Created:      now,
+		Expires:      now.Add(duration).Truncate(time.Second),
+		MaxSize:      maxSize,
+		MaxTotalSize: maxTotalSize,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err = m.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(tokenBucket)
+		if err := pruneTokens(bucket, now.Add(-auditRetention)); err != nil {
+			return err
+		}
+		return bucket.PutJSON(t.ID, t)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("save upload token: %w", err)
+	}
+	return &t, t.ID + "." + m.sign(t), nil
+}
+
+// pruneTokens deletes the tokens that expired before t.
+func pruneTokens(bucket *kv.Bucket, t time.Time) error {
+	var expired []string
+	err := bucket.ForEach(func(id string, _ []byte) error {
+		var token UploadToken
+		if _, err := bucket.GetJSON(id, &token); err != nil {
+			return err
+		}
+		if token.Expires.Before(t) {
+			expired = append(expired, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range expired {
+		if err := bucket.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) get(id string) (UploadToken, error) {
+	var t UploadToken
+	var exist bool
+	err := m.db.View(func(tx *kv.Tx) error {
+		var err error
+		exist, err = tx.Bucket(tokenBucket).GetJSON(id, &t)
+		return err
+	})
+	if err != nil {
+		return UploadToken{}, err
+	}
+	if !exist {
+		return UploadToken{}, fmt.Errorf("%w: %v", ErrTokenNotExist, id)
+	}
+	return t, nil
+}
+
+// Validate returns the upload token. Returns a error if the signature
+// doesn't match or the token is expired, revoked or used up.
+func (m *Manager) Validate(token string) (*UploadToken, error) {
+	id, signature, found := strings.Cut(token, ".")
+	if !found || id == "" {
+		return nil, ErrInvalidToken
+	}
+
+	t, err := m.get(id)
+	if errors.Is(err, ErrTokenNotExist) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(signature), []byte(m.sign(t))) {
+		return nil, ErrInvalidToken
+	}
+
+	switch {
+	case !t.Revoked.IsZero():
+		return nil, ErrRevoked
+	case !m.now().Before(t.Expires):
+		return nil, ErrExpired
+	case t.BytesUsed >= t.MaxTotalSize:
+		return nil, ErrTotalSize
+	}
+	return &t, nil
+}
+
+// Upload is a upload in progress. It reserves its size limit until it's
+// released, concurrent uploads can't exceed the total size of the token.
+type Upload struct {
+	Token UploadToken
+
+	// Size limit of the clip in bytes, the max size
+	// of the token or the remaining total size.
+	MaxSize int64
+
+	m        *Manager
+	released bool
+}
+
+// Begin validates the token and starts a upload, Release must be called.
+func (m *Manager) Begin(token string) (*Upload, error) {
+	t, err := m.Validate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	remaining := t.MaxTotalSize - t.BytesUsed - m.reserved[t.ID]
+	if remaining <= 0 {
+		return nil, ErrTotalSize
+	}
+	maxSize := t.MaxSize
+	if remaining < maxSize {
+		maxSize = remaining
+	}
+	m.reserved[t.ID] += maxSize
+	return &Upload{Token: *t, MaxSize: maxSize, m: m}, nil
+}
+
+// Release releases the reserved size. It's safe to call more than once.
+func (u *Upload) Release() {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	if u.released {
+		return
+	}
+	u.released = true
+	u.m.reserved[u.Token.ID] -= u.MaxSize
+	if u.m.reserved[u.Token.ID] <= 0 {
+		delete(u.m.reserved, u.Token.ID)
+	}
+}
+
+// Save saves the clip, see Manager.Save, and adds its size to the token.
+func (u *Upload) Save(ctx context.Context, data Metadata, video io.Reader) (string, error) {
+	counter := &countingReader{r: video}
+	recID, err := u.m.Save(ctx, u.Token.MonitorID, data, counter)
+	if err != nil {
+		return "", err
+	}
+
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	err = u.m.update(u.Token.ID, func(t *UploadToken) {
+		t.Uses++
+		t.BytesUsed += counter.n
+		t.LastUsed = u.m.now()
+	})
+	if err != nil {
+		return "", fmt.Errorf("update upload token: %w", err)
+	}
+	return recID, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Revoke revokes the token, it stays in the list for the audit.
+func (m *Manager) Revoke(id string, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update(id, func(t *UploadToken) {
+		if t.Revoked.IsZero() {
+			t.Revoked = m.now()
+			t.RevokedBy = username
+		}
+	})
+}
+
+func (m *Manager) update(id string, fn func(*UploadToken)) error {
+	return m.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(tokenBucket)
+		var t UploadToken
+		exist, err := bucket.GetJSON(id, &t)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return fmt.Errorf("%w: %v", ErrTokenNotExist, id)
+		}
+		fn(&t)
+		return bucket.PutJSON(id, t)
+	})
+}
+
+// List returns the issued tokens, newest first. Expired
+// and revoked tokens are included for the audit.
+func (m *Manager) List() ([]UploadToken, error) {
+	tokens := []UploadToken{}
+	err := m.db.View(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(tokenBucket)
+		return bucket.ForEach(func(id string, _ []byte) error {
+			var t UploadToken
+			if _, err := bucket.GetJSON(id, &t); err != nil {
+				return err
+			}
+			tokens = append(tokens, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list upload tokens: %w", err)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Created.After(tokens[j].Created)
+	})
+	return tokens, nil
+}
+
+// Validate returns a error if the metadata is invalid.
+func (d Metadata) Validate(now time.Time) error {
+	switch {
+	case d.Start.IsZero():
+		return fmt.Errorf("%w: start missing", ErrInvalidMetadata)
+	case !d.End.After(d.Start):
+		return fmt.Errorf("%w: end must be after start", ErrInvalidMetadata)
+	case d.End.Sub(d.Start) > maxRecordingLength:
+		return fmt.Errorf("%w: max length %v", ErrInvalidMetadata, maxRecordingLength)
+	case d.End.After(now.Add(maxClockSkew)):
+		return fmt.Errorf("%w: end is in the future", ErrInvalidMetadata)
+	case len(d.Events) > maxEvents:
+		return fmt.Errorf("%w: max %v events", ErrInvalidMetadata, maxEvents)
+	}
+	for _, e := range d.Events {
+		if e.Time.IsZero() {
+			return fmt.Errorf("%w: event time missing", ErrInvalidMetadata)
+		}
+	}
+	return nil
+}
+
+// Save saves the clip as a recording of the monitor and returns the
+// recording ID. The video is read until EOF, the caller limits the size.
+func (m *Manager) Save(
+	ctx context.Context,
+	monitorID string,
+	data Metadata,
+	video io.Reader,
+) (string, error) {
+	if err := data.Validate(m.now()); err != nil {
+		return "", err
+	}
+
+	recordingsDir, err := m.recordingsDir(monitorID)
+	if err != nil {
+		return "", err
+	}
+	start := data.Start.Local()
+	recID := start.Format("2006-01-02_15-04-05_") + monitorID
+	recPath, err := storage.RecordingIDToPath(recID)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(recordingsDir, recPath)
+
+	for _, ext := range []string{".json", ".mp4", ".meta", uploadExt} {
+		if _, err := os.Stat(path + ext); err == nil {
+			return "", fmt.Errorf("%w: %v", ErrRecordingExist, recID)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("make directory for video: %w", err)
+	}
+
+	uploadPath := path + uploadExt
+	if err := writeVideo(uploadPath, video); err != nil {
+		os.Remove(uploadPath)
+		return "", err
+	}
+
+	// The thumbnail is optional.
+	thumbPath := path + ".jpeg"
+	err = m.generateThumbnail(ctx, uploadPath, thumbPath)
+	if err == nil && !m.plainThumbnails {
+		if err := crypt.EncryptFile(thumbPath, monitorID); err != nil {
+			os.Remove(thumbPath)
+		}
+	}
+
+	// Encrypted with the key of the monitor like the recordings.
+	if err := crypt.EncryptFile(uploadPath, monitorID); err != nil {
+		os.Remove(uploadPath)
+		os.Remove(thumbPath)
+		return "", fmt.Errorf("encrypt video: %w", err)
+	}
+	if err := os.Rename(uploadPath, path+".mp4"); err != nil {
+		os.Remove(uploadPath)
+		return "", err
+	}
+
+	recData := storage.RecordingData{
+		Start:    data.Start,
+		End:      data.End,
+		Events:   data.Events,
+		Activity: storage.NewActivityIndex(data.Start, data.End, data.Events),
+	}
+	if recData.Events == nil {
+		recData.Events = []storage.Event{}
+	}
+	recData.Summarize()
+	if err := storage.WriteRecordingData(path, recData); err != nil {
+		return "", fmt.Errorf("write recording data: %w", err)
+	}
+	return recID, nil
+}
+
+// writeVideo writes the video and checks that it starts with a "ftyp" box.
+func writeVideo(path string, video io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(video, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidVideo, err)
+	}
+	if !bytes.Equal(header[4:], []byte("ftyp")) {
+		return fmt.Errorf("%w: not a mp4 file", ErrInvalidVideo)
+	}
+	if _, err := file.Write(header); err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, video); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (m *Manager) generateThumbnail(ctx context.Context, videoPath string, output string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, m.ffmpegBin,
+		"-y", "-loglevel", "error", "-f", "mp4", "-i", videoPath,
+		"-frames:v", "1", "-f", "image2", output)
+	if err := cmd.Run(); err != nil {
+		os.Remove(output)
+		return err
+	}
+	return nil
+}
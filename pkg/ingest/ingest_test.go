@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/crypt"
+	"nvr/pkg/kv"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T, recordingsDir string) *Manager {
+	t.Helper()
+	db, err := kv.Open(filepath.Join(t.TempDir(), "nvr.db"))
+	require.NoError(t, err)
+	m, err := NewManager(db, func(string) (string, error) {
+		return recordingsDir, nil
+	}, "false", false)
+	require.NoError(t, err)
+	m.now = func() time.Time { return time.Unix(1000, 0) }
+	return m
+}
+
+func TestPresign(t *testing.T) {
+	m := newTestManager(t, "")
+
+	cases := map[string]PresignRequest{
+		"monitor":      {},
+		"path":         {MonitorID: "../m1"},
+		"duration":     {MonitorID: "m1", Duration: 31 * 24 * 60 * 60},
+		"negDuration":  {MonitorID: "m1", Duration: -1},
+		"maxSize":      {MonitorID: "m1", MaxSize: maxMaxSize + 1},
+		"maxTotalSize": {MonitorID: "m1", MaxTotalSize: -1},
+	}
+	for name, req := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := m.Presign(req, "admin")
+			require.ErrorIs(t, err, ErrInvalidRequest)
+		})
+	}
+
+	uploadToken, token, err := m.Presign(PresignRequest{MonitorID: "m1"}, "admin")
+	require.NoError(t, err)
+	expected := UploadToken{
+		ID:           uploadToken.ID,
+		MonitorID:    "m1",
+		CreatedBy:    "admin",
+		Created:      time.Unix(1000, 0),
+		Expires:      time.Unix(1000, 0).Add(defaultDuration),
+		MaxSize:      defaultMaxSize * 1000 * 1000,
+		MaxTotalSize: defaultMaxTotalSize * 1000 * 1000,
+	}
+	require.Equal(t, expected, *uploadToken)
+	require.True(t, strings.HasPrefix(token, uploadToken.ID+"."))
+
+	t.Run("validate", func(t *testing.T) {
+		got, err := m.Validate(token)
+		require.NoError(t, err)
+		require.Equal(t, expected.ID, got.ID)
+		require.Equal(t, expected.MaxSize, got.MaxSize)
+	})
+	t.Run("tampered", func(t *testing.T) {
+		_, other, err := m.Presign(PresignRequest{MonitorID: "m2"}, "admin")
+		require.NoError(t, err)
+		id, _, _ := strings.Cut(other, ".")
+		_, signature, _ := strings.Cut(token, ".")
+
+		_, err = m.Validate(id + "." + signature)
+		require.ErrorIs(t, err, ErrInvalidToken)
+		_, err = m.Validate("x")
+		require.ErrorIs(t, err, ErrInvalidToken)
+		_, err = m.Validate("x.y")
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+	t.Run("expired", func(t *testing.T) {
+		m.now = func() time.Time { return expected.Expires }
+		defer func() { m.now = func() time.Time { return time.Unix(1000, 0) } }()
+		_, err := m.Validate(token)
+		require.ErrorIs(t, err, ErrExpired)
+	})
+	t.Run("persisted", func(t *testing.T) {
+		m2, err := NewManager(m.db, m.recordingsDir, m.ffmpegBin, false)
+		require.NoError(t, err)
+		m2.now = m.now
+		_, err = m2.Validate(token)
+		require.NoError(t, err)
+	})
+	t.Run("revoke", func(t *testing.T) {
+		uploadToken, token, err := m.Presign(PresignRequest{MonitorID: "m3"}, "admin")
+		require.NoError(t, err)
+		require.NoError(t, m.Revoke(uploadToken.ID, "admin2"))
+		_, err = m.Validate(token)
+		require.ErrorIs(t, err, ErrRevoked)
+		require.ErrorIs(t, m.Revoke("nil", "admin2"), ErrTokenNotExist)
+
+		tokens, err := m.List()
+		require.NoError(t, err)
+		var revoked *UploadToken
+		for i := range tokens {
+			if tokens[i].ID == uploadToken.ID {
+				revoked = &tokens[i]
+			}
+		}
+		require.NotNil(t, revoked)
+		require.Equal(t, "admin2", revoked.RevokedBy)
+	})
+}
+
+func TestUpload(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+	mp4 := []byte("\x00\x00\x00\x08ftypmdat")
+
+	uploadToken, token, err := m.Presign(PresignRequest{MonitorID: "m1"}, "admin")
+	require.NoError(t, err)
+	// Room for one clip and a part of another.
+	require.NoError(t, m.update(uploadToken.ID, func(t *UploadToken) {
+		t.MaxSize = 20
+		t.MaxTotalSize = 30
+	}))
+
+	u1, err := m.Begin(token)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), u1.MaxSize)
+
+	// The first upload reserves its max size.
+	u2, err := m.Begin(token)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), u2.MaxSize)
+	_, err = m.Begin(token)
+	require.ErrorIs(t, err, ErrTotalSize)
+	u2.Release()
+	u2.Release()
+
+	start := time.Unix(500, 0)
+	data := Metadata{Start: start, End: start.Add(10 * time.Second)}
+	_, err = u1.Save(context.Background(), data, bytes.NewReader(mp4))
+	require.NoError(t, err)
+	u1.Release()
+
+	got, err := m.Validate(token)
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Uses)
+	require.Equal(t, int64(len(mp4)), got.BytesUsed)
+	require.True(t, time.Unix(1000, 0).Equal(got.LastUsed))
+
+	u3, err := m.Begin(token)
+	require.NoError(t, err)
+	require.Equal(t, int64(30-len(mp4)), u3.MaxSize)
+	u3.Release()
+
+	require.NoError(t, m.update(uploadToken.ID, func(t *UploadToken) {
+		t.BytesUsed = t.MaxTotalSize
+	}))
+	_, err = m.Validate(token)
+	require.ErrorIs(t, err, ErrTotalSize)
+}
+
+func TestSave(t *testing.T) {
+	recordingsDir := t.TempDir()
+	m := newTestManager(t, recordingsDir)
+
+	start := time.Unix(500, 0)
+	data := Metadata{
+		Start: start,
+		End:   start.Add(10 * time.Second),
+		Events: []storage.Event{{
+			Time:       start.Add(2 * time.Second),
+			Detections: []storage.Detection{{Label: "person"}},
+		}},
+	}
+	mp4 := []byte("\x00\x00\x00\x08ftypmdat")
+
+	recID, err := m.Save(context.Background(), "m1", data, bytes.NewReader(mp4))
+	require.NoError(t, err)
+	require.Equal(t, start.Format("2006-01-02_15-04-05_")+"m1", recID)
+
+	recPath, err := storage.RecordingIDToPath(recID)
+	require.NoError(t, err)
+	path := filepath.Join(recordingsDir, recPath)
+
+	video, err := os.ReadFile(path + ".mp4")
+	require.NoError(t, err)
+	require.Equal(t, mp4, video)
+	_, err = os.Stat(path + uploadExt)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	rawData, err := os.ReadFile(path + ".json")
+	require.NoError(t, err)
+	var recData storage.RecordingData
+	require.NoError(t, json.Unmarshal(rawData, &recData))
+	require.True(t, data.End.Equal(recData.End))
+	require.Equal(t, []string{"person"}, recData.Labels)
+
+	t.Run("encrypted", func(t *testing.T) {
+		key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+		keyring, err := crypt.ParseKeyring([]byte("keys:\n  a: " + key + "\ndefault: a\n"))
+		require.NoError(t, err)
+		crypt.SetKeyring(keyring)
+		defer crypt.SetKeyring(nil)
+
+		// Writes the last argument, the thumbnail.
+		ffmpegBin := filepath.Join(t.TempDir(), "ffmpeg")
+		script := "#!/bin/sh\nfor a; do out=$a; done\nprintf jpeg > \"$out\"\n"
+		require.NoError(t, os.WriteFile(ffmpegBin, []byte(script), 0o700))
+
+		recordingsDir := t.TempDir()
+		m := newTestManager(t, recordingsDir)
+		m.ffmpegBin = ffmpegBin
+
+		recID, err := m.Save(context.Background(), "m1", data, bytes.NewReader(mp4))
+		require.NoError(t, err)
+		recPath, err := storage.RecordingIDToPath(recID)
+		require.NoError(t, err)
+		path := filepath.Join(recordingsDir, recPath)
+
+		raw, err := os.ReadFile(path + ".mp4")
+		require.NoError(t, err)
+		require.NotContains(t, string(raw), "ftyp")
+
+		file, err := crypt.Open(path + ".mp4")
+		require.NoError(t, err)
+		defer file.Close()
+		require.True(t, file.Encrypted())
+		video, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, mp4, video)
+
+		encrypted, err := crypt.IsEncrypted(path + ".jpeg")
+		require.NoError(t, err)
+		require.True(t, encrypted)
+		thumb, err := crypt.ReadFile(path + ".jpeg")
+		require.NoError(t, err)
+		require.Equal(t, "jpeg", string(thumb))
+	})
+	t.Run("exist", func(t *testing.T) {
+		_, err := m.Save(context.Background(), "m1", data, bytes.NewReader(mp4))
+		require.ErrorIs(t, err, ErrRecordingExist)
+	})
+	t.Run("invalidVideo", func(t *testing.T) {
+		data := data
+		data.Start = start.Add(time.Minute)
+		data.End = start.Add(2 * time.Minute)
+		_, err := m.Save(context.Background(), "m1", data, bytes.NewReader([]byte("abcdefghij")))
+		require.ErrorIs(t, err, ErrInvalidVideo)
+
+		matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*"+uploadExt))
+		require.NoError(t, err)
+		require.Empty(t, matches)
+	})
+
+	metadataCases := map[string]Metadata{
+		"start":   {End: start},
+		"end":     {Start: start, End: start},
+		"length":  {Start: start, End: start.Add(25 * time.Hour)},
+		"future":  {Start: start, End: time.Unix(1000, 0).Add(time.Hour)},
+		"eventNo": {Start: start, End: start.Add(time.Second), Events: []storage.Event{{}}},
+	}
+	for name, data := range metadataCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := m.Save(context.Background(), "m1", data, bytes.NewReader(mp4))
+			require.ErrorIs(t, err, ErrInvalidMetadata)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package kv is a small embedded key-value store with namespaced
+// buckets and transactions. New subsystems should use it instead
+// of their own JSON files, see docs/3_Development.md.
+//
+// It's a thin layer over bbolt, every bucket is a bbolt bucket
+// and the values are stored as is. Committed transactions are
+// synced to disk before Update returns.
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// How long Open waits for another process to close the database.
+const openTimeout = time.Second
+
+// Errors.
+var (
+	ErrReadOnly        = errors.New("transaction is read-only")
+	ErrEmptyBucketName = errors.New("bucket name can not be empty")
+)
+
+// DB is a key-value database stored in a single file.
+// Only one write transaction can run at a time.
+type DB struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database at path. The file is locked
+// until Close is called, only one process can have it open.
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create database directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database, it waits for the running transactions.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// View runs a read-only transaction.
+func (db *DB) View(fn func(*Tx) error) error {
+	return db.db.View(func(tx *bolt.Tx) error {
+		return fn(&Tx{tx: tx})
+	})
+}
+
+// Update runs a read-write transaction. The changes are written
+// to disk if fn returns nil and discarded if it returns a error.
+func (db *DB) Update(fn func(*Tx) error) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return fn(&Tx{tx: tx})
+	})
+}
+
+// Tx is a transaction, it's only valid until the function returns.
+type Tx struct {
+	tx *bolt.Tx
+}
+
+// Bucket returns the bucket with the name. Buckets are
+// created when the first key is written to them.
+func (tx *Tx) Bucket(name string) *Bucket {
+	return &Bucket{tx: tx, name: name}
+}
+
+// DeleteBucket deletes the bucket and all its keys.
+func (tx *Tx) DeleteBucket(name string) error {
+	if !tx.tx.Writable() {
+		return ErrReadOnly
+	}
+	err := tx.tx.DeleteBucket([]byte(name))
+	if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return fmt.Errorf("delete bucket %v: %w", name, err)
+	}
+	return nil
+}
+
+// Bucket is a namespace of keys, usually one per subsystem.
+type Bucket struct {
+	tx   *Tx
+	name string
+}
+
+// readable returns nil if the bucket doesn't exist.
+func (b *Bucket) readable() *bolt.Bucket {
+	return b.tx.tx.Bucket([]byte(b.name))
+}
+
+func (b *Bucket) writable() (*bolt.Bucket, error) {
+	if !b.tx.tx.Writable() {
+		return nil, ErrReadOnly
+	}
+	if b.name == "" {
+		return nil, ErrEmptyBucketName
+	}
+	bucket, err := b.tx.tx.CreateBucketIfNotExists([]byte(b.name))
+	if err != nil {
+		return nil, fmt.Errorf("create bucket %v: %w", b.name, err)
+	}
+	return bucket, nil
+}
+
+// Get returns the value of the key or nil if it doesn't exist. The
+// value must not be modified and is only valid in the transaction.
+func (b *Bucket) Get(key string) []byte {
+	bucket := b.readable()
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Get([]byte(key))
+}
+
+// Put sets the value of the key.
+func (b *Bucket) Put(key string, value []byte) error {
+	bucket, err := b.writable()
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put([]byte(key), value); err != nil {
+		return fmt.Errorf("put %v/%v: %w", b.name, key, err)
+	}
+	return nil
+}
+
+// Delete deletes the key. It's not a error if the key doesn't exist.
+func (b *Bucket) Delete(key string) error {
+	bucket, err := b.writable()
+	if err != nil {
+		return err
+	}
+	if err := bucket.Delete([]byte(key)); err != nil {
+		return fmt.Errorf("delete %v/%v: %w", b.name, key, err)
+	}
+	return nil
+}
+
+// ForEach calls fn for each key in the bucket in
+// sorted order, stops if fn returns a error.
+func (b *Bucket) ForEach(fn func(key string, value []byte) error) error {
+	bucket := b.readable()
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(key, value []byte) error {
+		return fn(string(key), value)
+	})
+}
+
+// GetJSON unmarshals the value of the key into v.
+// Returns false if the key doesn't exist.
+func (b *Bucket) GetJSON(key string, v interface{}) (bool, error) {
+	raw := b.Get(key)
+	if raw == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("unmarshal %v/%v: %w", b.name, key, err)
+	}
+	return true, nil
+}
+
+// PutJSON sets the value of the key to v marshaled as JSON.
+func (b *Bucket) PutJSON(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal %v/%v: %w", b.name, key, err)
+	}
+	return b.Put(key, raw)
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package kv
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "test.db")
+	db, err := Open(path)
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *Tx) error {
+		a := tx.Bucket("a")
+		require.NoError(t, a.Put("1", []byte("x")))
+		require.NoError(t, a.PutJSON("2", map[string]int{"y": 2}))
+		return tx.Bucket("b").Put("1", []byte("z"))
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Persisted.
+	db, err = Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	err = db.View(func(tx *Tx) error {
+		a := tx.Bucket("a")
+		require.Equal(t, []byte("x"), a.Get("1"))
+
+		var v map[string]int
+		exist, err := a.GetJSON("2", &v)
+		require.NoError(t, err)
+		require.True(t, exist)
+		require.Equal(t, map[string]int{"y": 2}, v)
+
+		exist, err = a.GetJSON("3", &v)
+		require.NoError(t, err)
+		require.False(t, exist)
+
+		require.Equal(t, []byte("z"), tx.Bucket("b").Get("1"))
+		require.Nil(t, tx.Bucket("c").Get("1"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("forEach", func(t *testing.T) {
+		var keys []string
+		err := db.View(func(tx *Tx) error {
+			return tx.Bucket("a").ForEach(func(key string, _ []byte) error {
+				keys = append(keys, key)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2"}, keys)
+	})
+	t.Run("rollback", func(t *testing.T) {
+		errTest := errors.New("test")
+		err := db.Update(func(tx *Tx) error {
+			require.NoError(t, tx.Bucket("a").Put("1", []byte("new")))
+			require.NoError(t, tx.Bucket("a").Delete("2"))
+
+			// Changes are visible inside the transaction.
+			require.Equal(t, []byte("new"), tx.Bucket("a").Get("1"))
+			require.Nil(t, tx.Bucket("a").Get("2"))
+			return errTest
+		})
+		require.ErrorIs(t, err, errTest)
+
+		err = db.View(func(tx *Tx) error {
+			require.Equal(t, []byte("x"), tx.Bucket("a").Get("1"))
+			require.NotNil(t, tx.Bucket("a").Get("2"))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+	t.Run("deleteBucket", func(t *testing.T) {
+		err := db.Update(func(tx *Tx) error {
+			require.NoError(t, tx.DeleteBucket("b"))
+			require.Nil(t, tx.Bucket("b").Get("1"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = db.View(func(tx *Tx) error {
+			require.Nil(t, tx.Bucket("b").Get("1"))
+			require.Equal(t, []byte("x"), tx.Bucket("a").Get("1"))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+	t.Run("readOnly", func(t *testing.T) {
+		err := db.View(func(tx *Tx) error {
+			return tx.Bucket("a").Put("1", nil)
+		})
+		require.ErrorIs(t, err, ErrReadOnly)
+	})
+	t.Run("emptyBucketName", func(t *testing.T) {
+		err := db.Update(func(tx *Tx) error {
+			return tx.Bucket("").Put("1", nil)
+		})
+		require.ErrorIs(t, err, ErrEmptyBucketName)
+	})
+	t.Run("locked", func(t *testing.T) {
+		// Only one process can have the database open.
+		_, err := Open(path)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// syslogSeverity returns the RFC 5424 severity of the level.
+func syslogSeverity(level Level) int {
+	switch level {
+	case LevelError:
+		return 3
+	case LevelWarning:
+		return 4
+	case LevelInfo:
+		return 6
+	}
+	return 7
+}
+
+// Syslog facility "daemon".
+const syslogFacility = 3
+
+// syslog returns the entry as a RFC 5424 message. The source
+// is the message ID. The message has the same format as text
+// logs, but without the level, which is part of the priority.
+func (e Entry) syslog(hostname string, pid int) []byte {
+	msg := e.Msg
+	if e.MonitorID != "" {
+		msg = e.MonitorID + ": " + msg
+	}
+	return []byte(fmt.Sprintf("<%d>1 %s %s nvr %d %s - %s",
+		syslogFacility*8+syslogSeverity(e.Level),
+		e.GetTime().UTC().Format("2006-01-02T15:04:05.000000Z"),
+		hostname, pid, e.Src, msg))
+}
+
+// ForwardConfig forwards log entries to syslog or a remote collector.
+// Empty sources and levels match everything.
+type ForwardConfig struct {
+	// Network is "udp", "tcp", "unix" or "unixgram".
+	Network string `yaml:"network"`
+
+	// Address is "host:port", or the socket path like "/dev/log".
+	Address string `yaml:"address"`
+
+	// Format is "syslog" or "json", defaults to syslog.
+	Format string `yaml:"format"`
+
+	Sources []string `yaml:"sources"`
+	Levels  []string `yaml:"levels"`
+}
+
+// Forward errors.
+var (
+	ErrInvalidNetwork = errors.New("invalid network")
+	ErrInvalidFormat  = errors.New("invalid format")
+	ErrAddressMissing = errors.New("address missing")
+)
+
+// Size of the queue of each forwarder, entries are
+// dropped if the collector can't keep up.
+const forwardQueueSize = 1000
+
+// Reconnect backoff of forwarders.
+const (
+	forwardMinBackoff = time.Second
+	forwardMaxBackoff = time.Minute
+	forwardTimeout    = 5 * time.Second
+)
+
+// Forwarder sends log entries to a syslog daemon or a remote collector.
+// The connection is reopened with a backoff if it fails, entries that
+// are logged while the connection is down are queued.
+type Forwarder struct {
+	network string
+	address string
+	format  string
+	sources []string
+	levels  []Level
+
+	hostname string
+	pid      int
+	queue    chan Entry
+	dropped  atomic.Uint64
+
+	dial func(network, address string) (net.Conn, error)
+}
+
+// NewForwarder validates the config and returns a forwarder.
+func NewForwarder(config ForwardConfig) (*Forwarder, error) {
+	switch config.Network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("log forward: %w: %q", ErrInvalidNetwork, config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("log forward: %w", ErrAddressMissing)
+	}
+
+	format := config.Format
+	switch format {
+	case "":
+		format = FormatSyslog
+	case FormatSyslog, FormatJSON:
+	default:
+		return nil, fmt.Errorf("log forward: %w: %q", ErrInvalidFormat, config.Format)
+	}
+
+	var levels []Level
+	for _, rawLevel := range config.Levels {
+		level, err := parseLevel(rawLevel)
+		if err != nil {
+			return nil, fmt.Errorf("log forward: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	return &Forwarder{
+		network:  config.Network,
+		address:  config.Address,
+		format:   format,
+		sources:  config.Sources,
+		levels:   levels,
+		hostname: hostname,
+		pid:      os.Getpid(),
+		queue:    make(chan Entry, forwardQueueSize),
+		dial: func(network, address string) (net.Conn, error) {
+			return net.DialTimeout(network, address, forwardTimeout)
+		},
+	}, nil
+}
+
+// Run forwards the logs until ctx is canceled.
+func (f *Forwarder) Run(ctx context.Context, logger *Logger) {
+	go f.send(ctx, logger)
+
+	feed, cancel := logger.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case entry := <-feed:
+			f.enqueue(entry)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueue adds the entry to the queue if it matches. The
+// logger waits for every subscriber, it must not block.
+func (f *Forwarder) enqueue(entry Entry) {
+	if !LevelInLevels(entry.Level, f.levels) || !StringInStrings(entry.Src, f.sources) {
+		return
+	}
+	select {
+	case f.queue <- entry:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of entries that
+// were dropped because the queue was full.
+func (f *Forwarder) Dropped() uint64 {
+	return f.dropped.Load()
+}
+
+func (f *Forwarder) send(ctx context.Context, logger ILogger) {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := forwardMinBackoff
+	failed := false
+	for {
+		var entry Entry
+		select {
+		case <-ctx.Done():
+			return
+		case entry = <-f.queue:
+		}
+
+		// Retry the entry until it's sent.
+		for {
+			if conn == nil {
+				var err error
+				conn, err = f.dial(f.network, f.address)
+				if err != nil {
+					conn = nil
+				}
+			}
+			if conn != nil {
+				err := f.write(conn, entry)
+				if err == nil {
+					break
+				}
+				conn.Close()
+				conn = nil
+			}
+
+			if !failed {
+				failed = true
+				// The entry is queued and sent when the connection is restored.
+				go logger.Log(Entry{
+					Level: LevelWarning,
+					Src:   "app",
+					Msg:   fmt.Sprintf("log forward: %v %v: connection failed, retrying", f.network, f.address),
+				})
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, forwardMaxBackoff)
+		}
+
+		if failed {
+			failed = false
+			go logger.Log(Entry{
+				Level: LevelInfo,
+				Src:   "app",
+				Msg:   fmt.Sprintf("log forward: %v %v: connection restored", f.network, f.address),
+			})
+		}
+		backoff = forwardMinBackoff
+	}
+}
+
+// write writes a single entry. Stream connections are framed,
+// syslog uses octet counting and JSON uses newlines.
+func (f *Forwarder) write(conn net.Conn, entry Entry) error {
+	var msg []byte
+	if f.format == FormatJSON {
+		msg = entry.JSON()
+	} else {
+		msg = entry.syslog(f.hostname, f.pid)
+	}
+
+	if f.network == "tcp" || f.network == "unix" {
+		if f.format == FormatJSON {
+			msg = append(msg, '\n')
+		} else {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+	}
+
+	if err := conn.SetWriteDeadline(time.Now().Add(forwardTimeout)); err != nil {
+		return err
+	}
+	_, err := conn.Write(msg)
+	return err
+}
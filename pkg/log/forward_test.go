@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryFormats(t *testing.T) {
+	entry := Entry{
+		Level:     LevelWarning,
+		Src:       "monitor",
+		MonitorID: "m1",
+		Msg:       `a "b"`,
+		Time:      UnixMicro(time.Date(2001, 2, 3, 4, 5, 6, 7000, time.UTC).UnixMicro()),
+	}
+
+	expected := `{"time":"2001-02-03T04:05:06.000007Z","level":"warning",` +
+		`"src":"monitor","monitorID":"m1","msg":"a \"b\""}`
+	require.Equal(t, expected, string(entry.JSON()))
+
+	expected = `<28>1 2001-02-03T04:05:06.000007Z host nvr 9 monitor - m1: a "b"`
+	require.Equal(t, expected, string(entry.syslog("host", 9)))
+}
+
+func TestNewForwarder(t *testing.T) {
+	cases := map[string]struct {
+		config   ForwardConfig
+		expected error
+	}{
+		"network": {ForwardConfig{Network: "x", Address: "a"}, ErrInvalidNetwork},
+		"address": {ForwardConfig{Network: "udp"}, ErrAddressMissing},
+		"format":  {ForwardConfig{Network: "udp", Address: "a", Format: "x"}, ErrInvalidFormat},
+		"level":   {ForwardConfig{Network: "udp", Address: "a", Levels: []string{"x"}}, ErrInvalidLevel},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewForwarder(tc.config)
+			require.ErrorIs(t, err, tc.expected)
+		})
+	}
+
+	f, err := NewForwarder(ForwardConfig{Network: "udp", Address: "a"})
+	require.NoError(t, err)
+	require.Equal(t, FormatSyslog, f.format)
+}
+
+func TestForwarder(t *testing.T) {
+	newTestForwarder := func(t *testing.T, config ForwardConfig) *Forwarder {
+		t.Helper()
+		f, err := NewForwarder(config)
+		require.NoError(t, err)
+		f.hostname = "host"
+		f.pid = 9
+		return f
+	}
+	entry := Entry{
+		Level: LevelError,
+		Src:   "app",
+		Msg:   "msg",
+		Time:  UnixMicro(time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).UnixMicro()),
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		f := newTestForwarder(t, ForwardConfig{
+			Network: "udp",
+			Address: "a",
+			Sources: []string{"app"},
+			Levels:  []string{"error"},
+		})
+		f.enqueue(Entry{Level: LevelInfo, Src: "app"})
+		f.enqueue(Entry{Level: LevelError, Src: "monitor"})
+		f.enqueue(entry)
+		require.Len(t, f.queue, 1)
+	})
+	t.Run("dropped", func(t *testing.T) {
+		f := newTestForwarder(t, ForwardConfig{Network: "udp", Address: "a"})
+		for i := 0; i < forwardQueueSize+2; i++ {
+			f.enqueue(entry)
+		}
+		require.Equal(t, uint64(2), f.Dropped())
+	})
+	t.Run("framing", func(t *testing.T) {
+		cases := map[string]struct {
+			network  string
+			format   string
+			expected string
+		}{
+			"tcpSyslog": {"tcp", FormatSyslog, "54 <27>1 2001-02-03T04:05:06.000000Z host nvr 9 app - msg"},
+			"tcpJSON": {"tcp", FormatJSON,
+				`{"time":"2001-02-03T04:05:06Z","level":"error","src":"app","msg":"msg"}` + "\n"},
+			"udpSyslog": {"udp", FormatSyslog, "<27>1 2001-02-03T04:05:06.000000Z host nvr 9 app - msg"},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				f := newTestForwarder(t, ForwardConfig{
+					Network: tc.network, Address: "a", Format: tc.format,
+				})
+				client, server := net.Pipe()
+				defer server.Close()
+				go func() {
+					require.NoError(t, f.write(client, entry))
+					client.Close()
+				}()
+				buf := make([]byte, 1000)
+				n, err := server.Read(buf)
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, string(buf[:n]))
+			})
+		}
+	})
+	t.Run("reconnect", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestForwarder(t, ForwardConfig{
+			Network: "tcp", Address: "a", Format: FormatJSON,
+		})
+		conns := make(chan net.Conn)
+		dials := 0
+		f.dial = func(string, string) (net.Conn, error) {
+			dials++
+			if dials == 1 {
+				return nil, errors.New("refused") //nolint:goerr113
+			}
+			client, server := net.Pipe()
+			conns <- server
+			return client, nil
+		}
+
+		logger, logs := NewMockLogger()
+		go f.send(ctx, logger)
+		f.enqueue(entry)
+
+		require.Contains(t, <-logs, "connection failed, retrying")
+		server := <-conns
+		line, err := bufio.NewReader(server).ReadString('\n')
+		require.NoError(t, err)
+		require.Contains(t, line, `"msg":"msg"`)
+		require.Contains(t, <-logs, "connection restored")
+	})
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// file.index {
+//     version  uint8
+//     nEntries uint32
+//     []key
+// }
+//
+// key {
+//     keySize      uint8
+//     key          [keySize]byte
+//     postingsSize uint32
+//     postings     [postingsSize]byte
+// }
+//
+// The postings are the ascending indexes of the entries that
+// have the key, each stored as a uvarint delta from the previous.
+// The keys are the level, source, monitor ID and the trigrams
+// of the lowercase message. The index covers the first nEntries
+// entries of the chunk, the entries after them are scanned.
+
+const indexAPIVersion = 0
+
+// Index errors.
+var (
+	ErrUnknownIndexVersion = errors.New("unknown index api version")
+	ErrCorruptIndex        = errors.New("corrupt index")
+)
+
+func chunkIDToIndexPath(logDir, chunkID string) string {
+	return filepath.Join(logDir, chunkID+".index")
+}
+
+func levelKey(level Level) string {
+	return "l:" + strconv.Itoa(int(level))
+}
+
+func srcKey(src string) string {
+	return "s:" + src
+}
+
+func monitorKey(monitorID string) string {
+	return "m:" + monitorID
+}
+
+func trigramKey(trigram string) string {
+	return "t:" + trigram
+}
+
+// trigrams returns every three byte substring of s.
+func trigrams(s string) []string {
+	if len(s) < 3 {
+		return nil
+	}
+	trigrams := make([]string, 0, len(s)-2)
+	for i := 0; i+3 <= len(s); i++ {
+		trigrams = append(trigrams, s[i:i+3])
+	}
+	return trigrams
+}
+
+func entryKeys(entry Entry) []string {
+	keys := []string{
+		levelKey(entry.Level),
+		srcKey(entry.Src),
+		monitorKey(entry.MonitorID),
+	}
+	for _, trigram := range trigrams(strings.ToLower(entry.Msg)) {
+		keys = append(keys, trigramKey(trigram))
+	}
+	return keys
+}
+
+type chunkIndex struct {
+	nEntries int
+	postings map[string][]byte
+}
+
+// readIndex returns nil if the chunk doesn't have an index.
+func readIndex(logDir, chunkID string) (*chunkIndex, error) {
+	raw, err := os.ReadFile(chunkIDToIndexPath(logDir, chunkID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < 5 {
+		return nil, ErrCorruptIndex
+	}
+	if raw[0] != indexAPIVersion {
+		return nil, ErrUnknownIndexVersion
+	}
+	index := &chunkIndex{
+		nEntries: int(binary.BigEndian.Uint32(raw[1:5])),
+		postings: make(map[string][]byte),
+	}
+	raw = raw[5:]
+	for len(raw) != 0 {
+		keySize := int(raw[0])
+		if len(raw) < 1+keySize+4 {
+			return nil, ErrCorruptIndex
+		}
+		key := string(raw[1 : 1+keySize])
+		raw = raw[1+keySize:]
+
+		postingsSize := int(binary.BigEndian.Uint32(raw[:4]))
+		if len(raw) < 4+postingsSize {
+			return nil, ErrCorruptIndex
+		}
+		index.postings[key] = raw[4 : 4+postingsSize]
+		raw = raw[4+postingsSize:]
+	}
+	return index, nil
+}
+
+// get returns the ascending indexes of the entries with the key.
+func (i *chunkIndex) get(key string) []int {
+	raw := i.postings[key]
+	var indexes []int
+	prev := 0
+	for len(raw) != 0 {
+		delta, n := binary.Uvarint(raw)
+		if n <= 0 {
+			return indexes
+		}
+		prev += int(delta)
+		indexes = append(indexes, prev)
+		raw = raw[n:]
+	}
+	return indexes
+}
+
+// candidates returns the ascending indexes of the entries that may
+// match the query. Returns false if the index can't narrow the query.
+func (i *chunkIndex) candidates(q Query) ([]int, bool) {
+	var sets [][]int
+	anyOf := func(keys []string) {
+		var set []int
+		for _, key := range keys {
+			set = union(set, i.get(key))
+		}
+		sets = append(sets, set)
+	}
+
+	if len(q.Levels) != 0 {
+		keys := make([]string, 0, len(q.Levels))
+		for _, level := range q.Levels {
+			keys = append(keys, levelKey(level))
+		}
+		anyOf(keys)
+	}
+	if len(q.Sources) != 0 {
+		keys := make([]string, 0, len(q.Sources))
+		for _, src := range q.Sources {
+			keys = append(keys, srcKey(src))
+		}
+		anyOf(keys)
+	}
+	if len(q.Monitors) != 0 {
+		keys := make([]string, 0, len(q.Monitors))
+		for _, monitorID := range q.Monitors {
+			keys = append(keys, monitorKey(monitorID))
+		}
+		anyOf(keys)
+	}
+
+	// A match contains the substring and the literal prefix of the
+	// regex, so the lowercase message contains their trigrams.
+	literals := []string{q.Substring}
+	if q.Regex != nil {
+		prefix, _ := q.Regex.LiteralPrefix()
+		literals = append(literals, prefix)
+	}
+	for _, literal := range literals {
+		for _, trigram := range trigrams(strings.ToLower(literal)) {
+			sets = append(sets, i.get(trigramKey(trigram)))
+		}
+	}
+
+	if len(sets) == 0 {
+		return nil, false
+	}
+	// Smallest first.
+	slices.SortFunc(sets, func(a, b []int) int { return len(a) - len(b) })
+	result := sets[0]
+	for _, set := range sets[1:] {
+		result = intersect(result, set)
+	}
+	return result, true
+}
+
+// union of two ascending lists.
+func union(a, b []int) []int {
+	result := make([]int, 0, len(a)+len(b))
+	for len(a) != 0 && len(b) != 0 {
+		switch {
+		case a[0] < b[0]:
+			result = append(result, a[0])
+			a = a[1:]
+		case a[0] > b[0]:
+			result = append(result, b[0])
+			b = b[1:]
+		default:
+			result = append(result, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	result = append(result, a...)
+	return append(result, b...)
+}
+
+// intersect of two ascending lists.
+func intersect(a, b []int) []int {
+	var result []int
+	for len(a) != 0 && len(b) != 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			result = append(result, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	return result
+}
+
+// indexChunks indexes the chunks with entries that aren't indexed.
+func (s *Store) indexChunks() error {
+	chunkIDs, err := s.listChunks()
+	if err != nil {
+		return fmt.Errorf("list chunks: %w", err)
+	}
+	for _, chunkID := range chunkIDs {
+		if err := s.indexChunk(chunkID); err != nil {
+			return fmt.Errorf("index chunk %q: %w", chunkID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) indexChunk(chunkID string) error {
+	decoder, err := newChunkDecoder(s.logDir, chunkID)
+	if err != nil {
+		return fmt.Errorf("new chunk decoder: %w", err)
+	}
+	defer decoder.close()
+
+	index, err := readIndex(s.logDir, chunkID)
+	if err == nil && index != nil && index.nEntries == decoder.nEntries {
+		return nil
+	}
+
+	postings := make(map[string][]int)
+	for i := 0; i < decoder.nEntries; i++ {
+		entry, _, err := decoder.decode(i)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		for _, key := range entryKeys(*entry) {
+			indexes := postings[key]
+			if len(indexes) == 0 || indexes[len(indexes)-1] != i {
+				postings[key] = append(indexes, i)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(postings))
+	for key := range postings {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	buf := []byte{indexAPIVersion}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(decoder.nEntries))
+	for _, key := range keys {
+		var raw []byte
+		prev := 0
+		for _, i := range postings[key] {
+			raw = binary.AppendUvarint(raw, uint64(i-prev))
+			prev = i
+		}
+		buf = append(buf, byte(len(key)))
+		buf = append(buf, key...)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(raw)))
+		buf = append(buf, raw...)
+	}
+
+	// The index is replaced atomically, queries may read it concurrently.
+	path := chunkIDToIndexPath(s.logDir, chunkID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
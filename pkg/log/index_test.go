@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	newIndexedStore := func(t *testing.T) *Store {
+		t.Helper()
+		store := newTestStore(t, "")
+		require.NoError(t, store.saveLog(Entry{Time: 1, Src: "app", Msg: "Disk Full"}))
+		require.NoError(t, store.saveLog(Entry{Time: 2, Src: "monitor", MonitorID: "a", Msg: "started"}))
+		require.NoError(t, store.saveLog(Entry{Time: 3, Src: "app", Msg: "disk ok"}))
+		require.NoError(t, store.indexChunks())
+		return store
+	}
+	msgs := func(t *testing.T, store *Store, q Query) []string {
+		t.Helper()
+		entries, err := store.Query(q)
+		require.NoError(t, err)
+		var msgs []string
+		for _, entry := range entries {
+			msgs = append(msgs, entry.Msg)
+		}
+		return msgs
+	}
+
+	t.Run("candidates", func(t *testing.T) {
+		store := newIndexedStore(t)
+		index, err := readIndex(store.logDir, "00000")
+		require.NoError(t, err)
+		require.Equal(t, 3, index.nEntries)
+
+		_, ok := index.candidates(Query{Substring: "di"})
+		require.False(t, ok)
+
+		candidates, ok := index.candidates(Query{Substring: "DISK"})
+		require.True(t, ok)
+		require.Equal(t, []int{0, 2}, candidates)
+
+		candidates, ok = index.candidates(Query{Sources: []string{"monitor", "x"}})
+		require.True(t, ok)
+		require.Equal(t, []int{1}, candidates)
+
+		candidates, ok = index.candidates(Query{
+			Sources: []string{"app"},
+			Regex:   regexp.MustCompile("Disk F.*"),
+		})
+		require.True(t, ok)
+		require.Equal(t, []int{0}, candidates)
+	})
+	t.Run("partial", func(t *testing.T) {
+		store := newIndexedStore(t)
+		require.NoError(t, store.saveLog(Entry{Time: 4, Src: "app", Msg: "disk full"}))
+
+		require.Equal(t,
+			[]string{"disk full", "disk ok", "Disk Full"},
+			msgs(t, store, Query{Substring: "disk"}),
+		)
+
+		require.NoError(t, store.indexChunks())
+		index, err := readIndex(store.logDir, "00000")
+		require.NoError(t, err)
+		require.Equal(t, 4, index.nEntries)
+	})
+	t.Run("time", func(t *testing.T) {
+		store := newIndexedStore(t)
+		require.Equal(t,
+			[]string{"Disk Full"},
+			msgs(t, store, Query{Substring: "disk", Time: 3}),
+		)
+	})
+	t.Run("corrupt", func(t *testing.T) {
+		store := newIndexedStore(t)
+		path := chunkIDToIndexPath(store.logDir, "00000")
+		require.NoError(t, os.WriteFile(path, []byte{indexAPIVersion, 0, 0, 0, 3, 9}, 0o600))
+
+		_, err := readIndex(store.logDir, "00000")
+		require.ErrorIs(t, err, ErrCorruptIndex)
+
+		// The entries are scanned instead.
+		require.Equal(t,
+			[]string{"disk ok", "Disk Full"},
+			msgs(t, store, Query{Substring: "disk"}),
+		)
+	})
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nvr/pkg/kv"
+	"os"
+	"slices"
+)
+
+// SourceLevel is the most verbose level that is logged for a source.
+type SourceLevel struct {
+	Source string `json:"source"`
+	Level  string `json:"level"`
+}
+
+// ErrUnknownSource unknown log source.
+var ErrUnknownSource = errors.New("unknown log source")
+
+// Bucket of the source levels, keyed by source.
+const levelBucket = "log.levels"
+
+// LoadLevels reads the source levels from the database, changes are
+// saved to it. Levels in the legacy JSON file are moved into the
+// database. Sources without a level log everything, sources that no
+// longer exist, for example of removed addons, are ignored.
+func (l *Logger) LoadLevels(db *kv.DB, legacyPath string) error {
+	l.levelsMu.Lock()
+	defer l.levelsMu.Unlock()
+
+	l.levelsDB = db
+	if err := migrateLevels(db, legacyPath); err != nil {
+		return fmt.Errorf("migrate log levels: %w", err)
+	}
+
+	return db.View(func(tx *kv.Tx) error {
+		return tx.Bucket(levelBucket).ForEach(func(source string, name []byte) error {
+			level, err := parseLevel(string(name))
+			if err != nil {
+				return fmt.Errorf("log level of %v: %w", source, err)
+			}
+			if slices.Contains(l.sources, source) {
+				l.setLevel(source, level)
+			}
+			return nil
+		})
+	})
+}
+
+// migrateLevels moves the levels from the legacy JSON file into the database.
+func migrateLevels(db *kv.DB, legacyPath string) error {
+	raw, err := os.ReadFile(legacyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var names map[string]string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		return err
+	}
+	err = db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(levelBucket)
+		for source, name := range names {
+			if err := bucket.Put(source, []byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.Remove(legacyPath)
+}
+
+// SetLevel sets the most verbose level that is logged for the source at
+// runtime, the entries of the source with a more verbose level are dropped.
+func (l *Logger) SetLevel(source string, name string) error {
+	if !slices.Contains(l.sources, source) {
+		return fmt.Errorf("%w: %q", ErrUnknownSource, source)
+	}
+	level, err := parseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	l.levelsMu.Lock()
+	defer l.levelsMu.Unlock()
+
+	if err := l.saveLevel(source, level); err != nil {
+		return fmt.Errorf("save log level: %w", err)
+	}
+	l.setLevel(source, level)
+	return nil
+}
+
+func (l *Logger) setLevel(source string, level Level) {
+	// Debug is the default.
+	if level == LevelDebug {
+		delete(l.levels, source)
+		return
+	}
+	if l.levels == nil {
+		l.levels = make(map[string]Level)
+	}
+	l.levels[source] = level
+}
+
+// saveLevel writes the level to the database. Not saved if the levels weren't loaded.
+func (l *Logger) saveLevel(source string, level Level) error {
+	if l.levelsDB == nil {
+		return nil
+	}
+	return l.levelsDB.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(levelBucket)
+		if level == LevelDebug {
+			return bucket.Delete(source)
+		}
+		return bucket.Put(source, []byte(levelName(level)))
+	})
+}
+
+// Levels returns the level of every source.
+func (l *Logger) Levels() []SourceLevel {
+	l.levelsMu.RLock()
+	defer l.levelsMu.RUnlock()
+
+	levels := make([]SourceLevel, 0, len(l.sources))
+	for _, source := range l.sources {
+		level, exist := l.levels[source]
+		if !exist {
+			level = LevelDebug
+		}
+		levels = append(levels, SourceLevel{Source: source, Level: levelName(level)})
+	}
+	return levels
+}
+
+// levelEnabled returns true if entries of the source with the level are logged.
+func (l *Logger) levelEnabled(source string, level Level) bool {
+	l.levelsMu.RLock()
+	defer l.levelsMu.RUnlock()
+	threshold, exist := l.levels[source]
+	return !exist || level <= threshold
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"context"
+	"nvr/pkg/kv"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevels(t *testing.T) {
+	t.Run("filter", func(t *testing.T) {
+		logger := NewLogger(&sync.WaitGroup{}, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, logger.Start(ctx))
+
+		feed, cancel2 := logger.Subscribe()
+		defer cancel2()
+
+		require.NoError(t, logger.SetLevel("monitor", "warning"))
+		go func() {
+			logger.Log(Entry{Level: LevelInfo, Src: "monitor", Msg: "1"})
+			logger.Log(Entry{Level: LevelDebug, Src: "app", Msg: "2"})
+			logger.Log(Entry{Level: LevelError, Src: "monitor", Msg: "3"})
+		}()
+		require.Equal(t, "2", (<-feed).Msg)
+		require.Equal(t, "3", (<-feed).Msg)
+	})
+	t.Run("persist", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "nvr.db")
+		db, err := kv.Open(dbPath)
+		require.NoError(t, err)
+		logger := NewLogger(&sync.WaitGroup{}, []string{"x"})
+		require.NoError(t, logger.LoadLevels(db, ""))
+		require.NoError(t, logger.SetLevel("x", "error"))
+		require.NoError(t, logger.SetLevel("app", "info"))
+		require.NoError(t, logger.SetLevel("app", "debug"))
+		require.NoError(t, db.Close())
+
+		db, err = kv.Open(dbPath)
+		require.NoError(t, err)
+		defer db.Close()
+		logger2 := NewLogger(&sync.WaitGroup{}, []string{"x"})
+		require.NoError(t, logger2.LoadLevels(db, ""))
+		require.Equal(t,
+			[]SourceLevel{
+				{Source: "access", Level: "debug"},
+				{Source: "app", Level: "debug"},
+				{Source: "auth", Level: "debug"},
+				{Source: "monitor", Level: "debug"},
+				{Source: "recorder", Level: "debug"},
+				{Source: "x", Level: "error"},
+			},
+			logger2.Levels(),
+		)
+		require.False(t, logger2.levelEnabled("x", LevelWarning))
+		require.True(t, logger2.levelEnabled("x", LevelError))
+
+		// Sources of removed addons are ignored.
+		logger3 := NewLogger(&sync.WaitGroup{}, nil)
+		require.NoError(t, logger3.LoadLevels(db, ""))
+		require.Empty(t, logger3.levels)
+	})
+	t.Run("migrate", func(t *testing.T) {
+		tempDir := t.TempDir()
+		legacyPath := filepath.Join(tempDir, "log-levels.json")
+		require.NoError(t, os.WriteFile(legacyPath, []byte(`{"app":"warning"}`), 0o600))
+		db, err := kv.Open(filepath.Join(tempDir, "nvr.db"))
+		require.NoError(t, err)
+		defer db.Close()
+
+		logger := NewLogger(&sync.WaitGroup{}, nil)
+		require.NoError(t, logger.LoadLevels(db, legacyPath))
+		require.False(t, logger.levelEnabled("app", LevelInfo))
+		require.NoFileExists(t, legacyPath)
+
+		logger2 := NewLogger(&sync.WaitGroup{}, nil)
+		require.NoError(t, logger2.LoadLevels(db, legacyPath))
+		require.False(t, logger2.levelEnabled("app", LevelInfo))
+	})
+	t.Run("unknownSource", func(t *testing.T) {
+		logger := NewLogger(&sync.WaitGroup{}, nil)
+		require.ErrorIs(t, logger.SetLevel("x", "info"), ErrUnknownSource)
+	})
+	t.Run("invalidLevel", func(t *testing.T) {
+		logger := NewLogger(&sync.WaitGroup{}, nil)
+		require.ErrorIs(t, logger.SetLevel("app", "x"), ErrInvalidLevel)
+	})
+	t.Run("invalidLevelStored", func(t *testing.T) {
+		db, err := kv.Open(filepath.Join(t.TempDir(), "nvr.db"))
+		require.NoError(t, err)
+		defer db.Close()
+		err = db.Update(func(tx *kv.Tx) error {
+			return tx.Bucket(levelBucket).Put("app", []byte("x"))
+		})
+		require.NoError(t, err)
+		logger := NewLogger(&sync.WaitGroup{}, nil)
+		require.ErrorIs(t, logger.LoadLevels(db, ""), ErrInvalidLevel)
+	})
+	t.Run("saveErr", func(t *testing.T) {
+		db, err := kv.Open(filepath.Join(t.TempDir(), "nvr.db"))
+		require.NoError(t, err)
+		logger := NewLogger(&sync.WaitGroup{}, nil)
+		require.NoError(t, logger.LoadLevels(db, ""))
+
+		require.NoError(t, db.Close())
+		require.Error(t, logger.SetLevel("app", "error"))
+		require.True(t, logger.levelEnabled("app", LevelDebug))
+	})
+}
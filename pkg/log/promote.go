@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// PromotionRule promotes matching log entries to events. Empty
+// fields match everything. If Count is greater than one, the rule
+// only matches after Count entries within Window seconds, for
+// example repeated crashes of the same monitor.
+type PromotionRule struct {
+	Name    string   `yaml:"name"`
+	Sources []string `yaml:"sources"`
+	Levels  []string `yaml:"levels"`
+	Pattern string   `yaml:"pattern"`
+	Count   int      `yaml:"count"`
+	Window  int      `yaml:"window"`
+}
+
+// PromotedEvent is emitted when a log entry matches a rule.
+type PromotedEvent struct {
+	Rule  string
+	Entry Entry
+}
+
+// Promotion rule errors.
+var (
+	ErrRuleNameMissing = errors.New("name missing")
+	ErrInvalidLevel    = errors.New("invalid level")
+	ErrInvalidWindow   = errors.New("window is required if count is greater than one")
+)
+
+type compiledRule struct {
+	name    string
+	sources []string
+	levels  []Level
+	regex   *regexp.Regexp
+	count   int
+	window  time.Duration
+}
+
+// Promoter matches log entries against promotion rules.
+type Promoter struct {
+	rules []compiledRule
+
+	// Recent match times by rule index and monitor ID.
+	matches map[promoterKey][]time.Time
+}
+
+type promoterKey struct {
+	rule      int
+	monitorID string
+}
+
+// NewPromoter validates the rules and returns a promoter.
+func NewPromoter(rules []PromotionRule) (*Promoter, error) {
+	p := &Promoter{matches: make(map[promoterKey][]time.Time)}
+	for _, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("log promotion rule %q: %w", rule.Name, err)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+func compileRule(rule PromotionRule) (compiledRule, error) {
+	if rule.Name == "" {
+		return compiledRule{}, ErrRuleNameMissing
+	}
+
+	var levels []Level
+	for _, rawLevel := range rule.Levels {
+		level, err := parseLevel(rawLevel)
+		if err != nil {
+			return compiledRule{}, err
+		}
+		levels = append(levels, level)
+	}
+
+	var regex *regexp.Regexp
+	if rule.Pattern != "" {
+		var err error
+		regex, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("pattern: %w", err)
+		}
+	}
+
+	count := max(rule.Count, 1)
+	if count > 1 && rule.Window <= 0 {
+		return compiledRule{}, ErrInvalidWindow
+	}
+
+	return compiledRule{
+		name:    rule.Name,
+		sources: rule.Sources,
+		levels:  levels,
+		regex:   regex,
+		count:   count,
+		window:  time.Duration(rule.Window) * time.Second,
+	}, nil
+}
+
+func parseLevel(level string) (Level, error) {
+	switch level {
+	case "error":
+		return LevelError, nil
+	case "warning":
+		return LevelWarning, nil
+	case "info":
+		return LevelInfo, nil
+	case "debug":
+		return LevelDebug, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, level)
+}
+
+// Run promotes the logs until ctx is canceled. onEvent must
+// not block or log, the logger waits for every subscriber.
+func (p *Promoter) Run(ctx context.Context, logger *Logger, onEvent func(PromotedEvent)) {
+	if len(p.rules) == 0 {
+		return
+	}
+
+	feed, cancel := logger.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case entry := <-feed:
+			for _, event := range p.promote(entry) {
+				onEvent(event)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// promote returns an event for every rule that the entry triggers.
+func (p *Promoter) promote(entry Entry) []PromotedEvent {
+	var events []PromotedEvent
+	for i, rule := range p.rules {
+		if !LevelInLevels(entry.Level, rule.levels) ||
+			!StringInStrings(entry.Src, rule.sources) ||
+			(rule.regex != nil && !rule.regex.MatchString(entry.Msg)) {
+			continue
+		}
+
+		if rule.count > 1 && !p.countMatch(i, rule, entry) {
+			continue
+		}
+		events = append(events, PromotedEvent{Rule: rule.name, Entry: entry})
+	}
+	return events
+}
+
+// countMatch records the match and returns true if the rule has
+// matched count times within the window. The count is reset after.
+func (p *Promoter) countMatch(ruleIndex int, rule compiledRule, entry Entry) bool {
+	key := promoterKey{rule: ruleIndex, monitorID: entry.MonitorID}
+	now := entry.GetTime()
+
+	var recent []time.Time
+	for _, t := range p.matches[key] {
+		if now.Sub(t) < rule.window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+
+	if len(recent) < rule.count {
+		p.matches[key] = recent
+		return false
+	}
+	delete(p.matches, key)
+	return true
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPromoter(t *testing.T) {
+	cases := map[string]struct {
+		rule        PromotionRule
+		expectedErr error
+	}{
+		"ok":     {PromotionRule{Name: "a", Levels: []string{"error"}}, nil},
+		"name":   {PromotionRule{}, ErrRuleNameMissing},
+		"level":  {PromotionRule{Name: "a", Levels: []string{"x"}}, ErrInvalidLevel},
+		"window": {PromotionRule{Name: "a", Count: 2}, ErrInvalidWindow},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPromoter([]PromotionRule{tc.rule})
+			require.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+	t.Run("pattern", func(t *testing.T) {
+		_, err := NewPromoter([]PromotionRule{{Name: "a", Pattern: "("}})
+		require.Error(t, err)
+	})
+}
+
+func TestPromote(t *testing.T) {
+	t.Run("match", func(t *testing.T) {
+		p, err := NewPromoter([]PromotionRule{{
+			Name:    "a",
+			Sources: []string{"monitor"},
+			Levels:  []string{"error"},
+			Pattern: "crashed",
+		}})
+		require.NoError(t, err)
+
+		entry := Entry{Level: LevelError, Src: "monitor", Msg: "main process: crashed"}
+		require.Equal(t, []PromotedEvent{{Rule: "a", Entry: entry}}, p.promote(entry))
+
+		entry.Level = LevelWarning
+		require.Empty(t, p.promote(entry))
+
+		entry.Level = LevelError
+		entry.Src = "app"
+		require.Empty(t, p.promote(entry))
+
+		entry.Src = "monitor"
+		entry.Msg = "started"
+		require.Empty(t, p.promote(entry))
+	})
+	t.Run("count", func(t *testing.T) {
+		p, err := NewPromoter([]PromotionRule{{
+			Name:   "a",
+			Count:  3,
+			Window: 10,
+		}})
+		require.NoError(t, err)
+
+		second := UnixMicro(1000000)
+		entry := func(monitorID string, time UnixMicro) Entry {
+			return Entry{Level: LevelError, Src: "monitor", MonitorID: monitorID, Time: time}
+		}
+
+		require.Empty(t, p.promote(entry("1", 0)))
+		require.Empty(t, p.promote(entry("1", 5*second)))
+		// Other monitors are counted separately.
+		require.Empty(t, p.promote(entry("2", 6*second)))
+		// The first match is outside the window.
+		require.Empty(t, p.promote(entry("1", 11*second)))
+		require.Len(t, p.promote(entry("1", 12*second)), 1)
+		// Count is reset.
+		require.Empty(t, p.promote(entry("1", 13*second)))
+	})
+}
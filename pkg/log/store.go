@@ -11,6 +11,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +22,7 @@ import (
 // chunk {
 //     file.data
 //     file.msg
+//     file.index // See index.go
 // }
 //
 // file.data {
@@ -130,7 +133,7 @@ func (s *Store) SaveLogs(ctx context.Context, logger *Logger) {
 	}()
 }
 
-// PurgeLoop purges logs every hour.
+// PurgeLoop purges logs and indexes the new entries every hour.
 func (s *Store) PurgeLoop(ctx context.Context, logger *Logger) {
 	s.wg.Add(1)
 	go func() {
@@ -147,6 +150,13 @@ func (s *Store) PurgeLoop(ctx context.Context, logger *Logger) {
 						Msg:   fmt.Sprintf("could not purge logs: %v", err),
 					})
 				}
+				if err := s.indexChunks(); err != nil {
+					logger.Log(Entry{
+						Level: LevelError,
+						Src:   "app",
+						Msg:   fmt.Sprintf("could not index logs: %v", err),
+					})
+				}
 			}
 		}
 	}()
@@ -189,33 +199,82 @@ type Query struct {
 	Sources  []string
 	Monitors []string
 	Limit    int
+
+	// Optional message filters. Substring is case-insensitive.
+	Substring string
+	Regex     *regexp.Regexp
+}
+
+func (q Query) matchMessage(msg string) bool {
+	if q.Substring != "" &&
+		!strings.Contains(strings.ToLower(msg), strings.ToLower(q.Substring)) {
+		return false
+	}
+	if q.Regex != nil && !q.Regex.MatchString(msg) {
+		return false
+	}
+	return true
 }
 
 // Query logs in database.
 func (s *Store) Query(q Query) ([]Entry, error) {
+	var entries []Entry
+	err := s.query(q, func(entry Entry) bool {
+		entries = append(entries, entry)
+		return q.Limit == 0 || len(entries) < q.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Export calls fn with every matching entry, newest first. The limit is
+// ignored and the entries are not buffered, the export is as fast as fn.
+// Returns the first error from fn or the context.
+func (s *Store) Export(ctx context.Context, q Query, fn func(Entry) error) error {
+	q.Limit = 0
+	var fnErr error
+	err := s.query(q, func(entry Entry) bool {
+		if fnErr = ctx.Err(); fnErr != nil {
+			return false
+		}
+		fnErr = fn(entry)
+		return fnErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// query calls fn with the matching entries until it returns false.
+func (s *Store) query(q Query, fn func(Entry) bool) error {
 	chunkIDs, err := s.listChunksBefore(q.Time)
 	if err != nil {
-		return nil, fmt.Errorf("list chunks before: %w", err)
+		return fmt.Errorf("list chunks before: %w", err)
 	}
 
-	var entries []Entry
 	for i := len(chunkIDs) - 1; i >= 0; i-- {
 		chunkID := chunkIDs[i]
-		err := s.queryChunk(q, &entries, chunkID)
+		more, err := s.queryChunk(q, chunkID, fn)
 		if err != nil {
 			s.logf("query chunk %q: %v", chunkID, err)
 		}
+		if !more {
+			return nil
+		}
 		// Time is only relevant for the first iteration.
 		q.Time = 0
 	}
-
-	return entries, nil
+	return nil
 }
 
-func (s *Store) queryChunk(q Query, entries *[]Entry, chunkID string) error {
+// queryChunk returns false if fn returned false.
+func (s *Store) queryChunk(q Query, chunkID string, fn func(Entry) bool) (bool, error) {
 	decoder, err := newChunkDecoder(s.logDir, chunkID)
 	if err != nil {
-		return fmt.Errorf("create decoder: %w", err)
+		return true, fmt.Errorf("create decoder: %w", err)
 	}
 	defer decoder.close()
 
@@ -223,35 +282,57 @@ func (s *Store) queryChunk(q Query, entries *[]Entry, chunkID string) error {
 	if q.Time != 0 {
 		index, err = decoder.search(q.Time)
 		if err != nil {
-			return fmt.Errorf("seek: %w", err)
+			return true, fmt.Errorf("seek: %w", err)
 		}
 		index--
 	}
 
-	for index >= 0 && (q.Limit == 0 || len(*entries) < q.Limit) {
+	// Only the candidates of the indexed entries are decoded.
+	var candidates []int
+	nIndexed := 0
+	chunkIndex, err := readIndex(s.logDir, chunkID)
+	if err != nil {
+		s.logf("read index %q: %v", chunkID, err)
+	}
+	if chunkIndex != nil && chunkIndex.nEntries <= decoder.nEntries {
+		var ok bool
+		candidates, ok = chunkIndex.candidates(q)
+		if ok {
+			nIndexed = chunkIndex.nEntries
+		}
+	}
+
+	for index >= 0 {
+		if index < nIndexed {
+			n, _ := slices.BinarySearch(candidates, index+1)
+			if n == 0 {
+				return true, nil
+			}
+			index = candidates[n-1]
+		}
+
 		entry, _, err := decoder.decode(index)
 		if err != nil {
-			return err
+			return true, err
 		}
 		if entry == nil {
 			// Last entry.
-			return nil
+			return true, nil
 		}
 		index--
 
 		if !LevelInLevels(entry.Level, q.Levels) ||
 			!StringInStrings(entry.Src, q.Sources) ||
-			!StringInStrings(entry.MonitorID, q.Monitors) {
-			entryChunkID, err := timeToID(entry.Time)
-			if err != nil || chunkID != entryChunkID {
-				continue
-			}
+			!StringInStrings(entry.MonitorID, q.Monitors) ||
+			!q.matchMessage(entry.Msg) {
 			continue
 		}
-		*entries = append(*entries, *entry)
+		if !fn(*entry) {
+			return false, nil
+		}
 	}
 
-	return nil
+	return true, nil
 }
 
 func (s *Store) listChunksBefore(time UnixMicro) ([]string, error) {
@@ -330,6 +411,7 @@ func (s *Store) purge() error {
 	if err != nil {
 		return fmt.Errorf("remove %q %w", msgPath, err)
 	}
+	os.Remove(chunkIDToIndexPath(s.logDir, chunkToRemove))
 
 	return nil
 }
@@ -4,10 +4,12 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"sync"
 	"testing"
@@ -154,6 +156,21 @@ func TestQuery(t *testing.T) {
 			},
 			expected: []Entry{msg2, msg3},
 		},
+		"substring": {
+			input:    Query{Substring: "MSG2"},
+			expected: []Entry{msg2},
+		},
+		"regex": {
+			input:    Query{Regex: regexp.MustCompile("msg[13]")},
+			expected: []Entry{msg1, msg3},
+		},
+		"substringAndRegex": {
+			input: Query{
+				Substring: "msg",
+				Regex:     regexp.MustCompile("3$"),
+			},
+			expected: []Entry{msg3},
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -164,6 +181,17 @@ func TestQuery(t *testing.T) {
 		})
 	}
 
+	require.NoError(t, store.indexChunks())
+	require.FileExists(t, filepath.Join(store.logDir, "00000.index"))
+	for name, tc := range cases {
+		t.Run("indexed/"+name, func(t *testing.T) {
+			logs, err := store.Query(tc.input)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, logs)
+		})
+	}
+
 	t.Run("noEntries", func(t *testing.T) {
 		store := newTestStore(t, "")
 		entries, err := store.Query(Query{})
@@ -303,6 +331,55 @@ func TestQuery(t *testing.T) {
 	})
 }
 
+func TestExport(t *testing.T) {
+	store := newTestStore(t, "")
+	msg1 := Entry{Src: "s1", Msg: "msg1", Time: 1}
+	msg2 := Entry{Src: "s2", Msg: "msg2", Time: chunkDuration}
+	msg3 := Entry{Src: "s1", Msg: "msg3", Time: chunkDuration * 2}
+	require.NoError(t, store.saveLog(msg1))
+	require.NoError(t, store.saveLog(msg2))
+	require.NoError(t, store.saveLog(msg3))
+
+	t.Run("ok", func(t *testing.T) {
+		var entries []Entry
+		err := store.Export(context.Background(), Query{Limit: 1}, func(e Entry) error {
+			entries = append(entries, e)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Entry{msg3, msg2, msg1}, entries)
+	})
+	t.Run("filter", func(t *testing.T) {
+		var entries []Entry
+		q := Query{Sources: []string{"s1"}, Time: chunkDuration * 2}
+		err := store.Export(context.Background(), q, func(e Entry) error {
+			entries = append(entries, e)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Entry{msg1}, entries)
+	})
+	t.Run("fnErr", func(t *testing.T) {
+		errMock := errors.New("mock")
+		n := 0
+		err := store.Export(context.Background(), Query{}, func(Entry) error {
+			n++
+			return errMock
+		})
+		require.ErrorIs(t, err, errMock)
+		require.Equal(t, 1, n)
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := store.Export(ctx, Query{}, func(Entry) error {
+			t.Fatal("unexpected call")
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
 func TestNewStore(t *testing.T) {
 	t.Run("mkdir", func(t *testing.T) {
 		tempDir := t.TempDir()
@@ -508,9 +585,13 @@ func TestPurge(t *testing.T) {
 
 		writeTestChunk(t, logDir, "00000")
 		writeTestChunk(t, logDir, "11111")
+		indexPath := chunkIDToIndexPath(logDir, "00000")
+		require.NoError(t, os.WriteFile(indexPath, nil, 0o600))
 
 		files := listFiles(t, logDir)
-		expected := []string{"00000.data", "00000.msg", "11111.data", "11111.msg"}
+		expected := []string{
+			"00000.data", "00000.index", "00000.msg", "11111.data", "11111.msg",
+		}
 		require.Equal(t, expected, files)
 
 		require.NoError(t, s.purge())
@@ -0,0 +1,478 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"nvr/pkg/video/gortsplib/pkg/base"
+	"nvr/pkg/video/gortsplib/pkg/conn"
+	"nvr/pkg/video/gortsplib/pkg/headers"
+	"nvr/pkg/video/gortsplib/pkg/sdp"
+	"nvr/pkg/video/gortsplib/pkg/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	psdp "github.com/pion/sdp/v3"
+)
+
+// The ONVIF backchannel is requested with this Require header.
+const backchannelRequire = "www.onvif.org/ver20/backchannel"
+
+// Backchannel errors.
+var (
+	ErrBackchannelNotSupported = errors.New("camera doesn't have a audio backchannel")
+	ErrBackchannelCodec        = errors.New("unsupported backchannel codec")
+	ErrBackchannelAuth         = errors.New("camera rejected the credentials")
+	ErrBackchannelResponse     = errors.New("unexpected backchannel response")
+)
+
+// Backchannel audio format, G.711 at 8kHz. Input samples are
+// 16-bit mono PCM and are sent in packets of 20 milliseconds.
+const (
+	BackchannelSampleRate = 8000
+	backchannelPacketSize = BackchannelSampleRate / 50
+	backchannelTimeout    = 10 * time.Second
+	backchannelKeepalive  = 30 * time.Second
+)
+
+// Backchannel is a RTSP session that sends audio to the speaker of
+// a camera through the ONVIF audio backchannel. The session is
+// opened with DialBackchannel and must be closed.
+type Backchannel struct {
+	nconn   net.Conn
+	conn    *conn.Conn
+	baseURL *url.URL
+	auth    *rtspAuth
+	session string
+
+	mu          sync.Mutex
+	cseq        int
+	channel     int
+	payloadType uint8
+	encode      func(int16) byte
+	seq         uint16
+	timestamp   uint32
+	ssrc        uint32
+	pending     []int16
+	buf         []byte
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// DialBackchannel opens the backchannel of the RTSP URL with the dialer.
+// Only "rtsp://" URLs are supported. The credentials in the URL
+// are used if the camera requires authentication.
+func DialBackchannel(ctx context.Context, rawURL string, dialer *net.Dialer) (*Backchannel, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "rtsp" {
+		return nil, fmt.Errorf("%w: invalid url", ErrBackchannelNotSupported)
+	}
+	host := u.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "554")
+	}
+
+	nconn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	b := &Backchannel{
+		nconn:   nconn,
+		conn:    conn.NewConn(nconn),
+		baseURL: u.CloneWithoutCredentials(),
+		ssrc:    rand.Uint32(), //nolint:gosec
+		buf:     make([]byte, 2048),
+		done:    make(chan struct{}),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		b.auth = &rtspAuth{username: u.User.Username(), password: password}
+	}
+
+	deadline := time.Now().Add(backchannelTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := nconn.SetDeadline(deadline); err != nil {
+		nconn.Close()
+		return nil, err
+	}
+	if err := b.setup(); err != nil {
+		nconn.Close()
+		return nil, err
+	}
+	if err := nconn.SetDeadline(time.Time{}); err != nil {
+		nconn.Close()
+		return nil, err
+	}
+
+	var keepaliveCtx context.Context
+	keepaliveCtx, b.cancel = context.WithCancel(context.Background())
+	go b.readLoop()
+	go b.keepalive(keepaliveCtx)
+
+	return b, nil
+}
+
+// setup sends DESCRIBE, SETUP and PLAY.
+func (b *Backchannel) setup() error {
+	res, err := b.do(base.Describe, b.baseURL, base.Header{
+		"Accept": base.HeaderValue{"application/sdp"},
+	})
+	if err != nil {
+		return err
+	}
+	if contentBase, ok := res.Header["Content-Base"]; ok && len(contentBase) == 1 {
+		if u, err := url.Parse(contentBase[0]); err == nil {
+			b.baseURL = u.CloneWithoutCredentials()
+		}
+	}
+
+	var desc sdp.SessionDescription
+	if err := desc.Unmarshal(res.Body); err != nil {
+		return fmt.Errorf("%w: sdp: %v", ErrBackchannelResponse, err)
+	}
+	media, err := parseBackchannelMedia(desc)
+	if err != nil {
+		return err
+	}
+	b.channel = media.index * 2
+	b.payloadType = media.payloadType
+	b.encode = media.encode
+
+	setupURL, err := backchannelControlURL(b.baseURL, media.control)
+	if err != nil {
+		return err
+	}
+	transport := headers.Transport{InterleavedIDs: &[2]int{b.channel, b.channel + 1}}
+	res, err = b.do(base.Setup, setupURL, base.Header{
+		"Transport": transport.Marshal(),
+	})
+	if err != nil {
+		return err
+	}
+	var session headers.Session
+	if err := session.Unmarshal(res.Header["Session"]); err != nil {
+		return fmt.Errorf("%w: session: %v", ErrBackchannelResponse, err)
+	}
+	b.session = session.Session
+
+	_, err = b.do(base.Play, b.baseURL, base.Header{})
+	return err
+}
+
+// do sends the request and reads the response. The request is
+// repeated with credentials if the camera responds with 401.
+func (b *Backchannel) do(method base.Method, u *url.URL, header base.Header) (*base.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := b.writeRequest(method, u, header); err != nil {
+			return nil, err
+		}
+		res, err := b.conn.ReadResponseIgnoreFrames()
+		if err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+
+		if res.StatusCode == base.StatusUnauthorized && attempt == 0 && b.auth != nil {
+			if err := b.auth.parseChallenge(res.Header["WWW-Authenticate"]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		switch {
+		case res.StatusCode == base.StatusUnauthorized || res.StatusCode == base.StatusForbidden:
+			return nil, fmt.Errorf("%w: %v", ErrBackchannelAuth, res.StatusCode)
+		case res.StatusCode == base.StatusOptionNotSupported:
+			return nil, ErrBackchannelNotSupported
+		case res.StatusCode != base.StatusOK:
+			return nil, fmt.Errorf("%w: %v %v: %v %v",
+				ErrBackchannelResponse, method, u, res.StatusCode, res.StatusMessage)
+		}
+		return res, nil
+	}
+}
+
+func (b *Backchannel) writeRequest(method base.Method, u *url.URL, header base.Header) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.cseq++
+	h := base.Header{
+		"CSeq":       base.HeaderValue{strconv.Itoa(b.cseq)},
+		"Require":    base.HeaderValue{backchannelRequire},
+		"User-Agent": base.HeaderValue{"OS-NVR"},
+	}
+	for k, v := range header {
+		h[k] = v
+	}
+	if b.session != "" {
+		h["Session"] = base.HeaderValue{b.session}
+	}
+	if b.auth != nil && b.auth.realm != "" {
+		h["Authorization"] = base.HeaderValue{b.auth.authorization(method, u)}
+	}
+	return b.conn.WriteRequest(&base.Request{Method: method, URL: u, Header: h})
+}
+
+// readLoop discards the packets and responses sent
+// by the camera after PLAY, until the connection closes.
+func (b *Backchannel) readLoop() {
+	defer close(b.done)
+	for {
+		if _, err := b.conn.ReadInterleavedFrameOrResponse(); err != nil {
+			return
+		}
+	}
+}
+
+// keepalive prevents the session from timing out, cameras
+// don't always count the backchannel packets as activity.
+func (b *Backchannel) keepalive(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backchannelKeepalive):
+		}
+		if err := b.writeRequest(base.GetParameter, b.baseURL, base.Header{}); err != nil {
+			return
+		}
+	}
+}
+
+// Write encodes and sends 16-bit PCM samples at 8kHz. Samples
+// are buffered until there is enough for a full packet.
+func (b *Backchannel) Write(samples []int16) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, samples...)
+	for len(b.pending) >= backchannelPacketSize {
+		if err := b.writePacket(b.pending[:backchannelPacketSize]); err != nil {
+			return err
+		}
+		b.pending = b.pending[backchannelPacketSize:]
+	}
+	return nil
+}
+
+func (b *Backchannel) writePacket(samples []int16) error {
+	payload := make([]byte, len(samples))
+	for i, s := range samples {
+		payload[i] = b.encode(s)
+	}
+	packet := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         b.seq == 0,
+			PayloadType:    b.payloadType,
+			SequenceNumber: b.seq,
+			Timestamp:      b.timestamp,
+			SSRC:           b.ssrc,
+		},
+		Payload: payload,
+	}
+	b.seq++
+	b.timestamp += uint32(len(samples))
+
+	raw, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := b.nconn.SetWriteDeadline(time.Now().Add(backchannelTimeout)); err != nil {
+		return err
+	}
+	frame := base.InterleavedFrame{Channel: b.channel, Payload: raw}
+	return b.conn.WriteInterleavedFrame(&frame, b.buf)
+}
+
+// Close tears down the session and closes the connection.
+func (b *Backchannel) Close() error {
+	b.cancel()
+	b.nconn.SetWriteDeadline(time.Now().Add(time.Second))   //nolint:errcheck
+	b.writeRequest(base.Teardown, b.baseURL, base.Header{}) //nolint:errcheck
+	err := b.nconn.Close()
+	<-b.done
+	return err
+}
+
+type backchannelMedia struct {
+	index       int
+	control     string
+	payloadType uint8
+	encode      func(int16) byte
+}
+
+// parseBackchannelMedia returns the first sendonly audio media.
+// The direction is from the camera's point of view.
+func parseBackchannelMedia(desc sdp.SessionDescription) (*backchannelMedia, error) {
+	for i, md := range desc.MediaDescriptions {
+		if md.MediaName.Media != "audio" {
+			continue
+		}
+		if _, sendonly := md.Attribute("sendonly"); !sendonly {
+			continue
+		}
+		control, _ := md.Attribute("control")
+		for _, format := range md.MediaName.Formats {
+			pt, err := strconv.ParseUint(format, 10, 7)
+			if err != nil {
+				continue
+			}
+			codec := strings.ToUpper(rtpmapCodec(md.Attributes, format))
+			switch {
+			case pt == 0 || codec == "PCMU/8000":
+				return &backchannelMedia{i, control, uint8(pt), encodeMulaw}, nil
+			case pt == 8 || codec == "PCMA/8000":
+				return &backchannelMedia{i, control, uint8(pt), encodeAlaw}, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %v", ErrBackchannelCodec, md.MediaName.Formats)
+	}
+	return nil, ErrBackchannelNotSupported
+}
+
+// rtpmapCodec returns the encoding of the payload type, "PCMU/8000".
+func rtpmapCodec(attributes []psdp.Attribute, format string) string {
+	for _, a := range attributes {
+		if a.Key != "rtpmap" {
+			continue
+		}
+		fields := strings.Fields(a.Value)
+		if len(fields) == 2 && fields[0] == format {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+func backchannelControlURL(baseURL *url.URL, control string) (*url.URL, error) {
+	switch {
+	case control == "" || control == "*":
+		return baseURL, nil
+	case strings.HasPrefix(control, "rtsp://"):
+		return url.Parse(control)
+	}
+	s := baseURL.String()
+	if !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+	return url.Parse(s + control)
+}
+
+// rtspAuth is RTSP basic or digest authentication.
+type rtspAuth struct {
+	username string
+	password string
+
+	digest bool
+	realm  string
+	nonce  string
+}
+
+var authParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseChallenge reads the WWW-Authenticate headers,
+// digest is preferred over basic authentication.
+func (a *rtspAuth) parseChallenge(values base.HeaderValue) error {
+	for _, digest := range []bool{true, false} {
+		for _, v := range values {
+			isDigest := strings.HasPrefix(v, "Digest ")
+			if isDigest != digest || (!isDigest && !strings.HasPrefix(v, "Basic ")) {
+				continue
+			}
+			a.digest = digest
+			for _, m := range authParamRegex.FindAllStringSubmatch(v, -1) {
+				switch m[1] {
+				case "realm":
+					a.realm = m[2]
+				case "nonce":
+					a.nonce = m[2]
+				}
+			}
+			if a.realm != "" {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: unsupported authentication %v", ErrBackchannelAuth, values)
+}
+
+func (a *rtspAuth) authorization(method base.Method, u *url.URL) string {
+	if !a.digest {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.username+":"+a.password))
+	}
+	md5Hex := func(s string) string {
+		h := md5.Sum([]byte(s)) //nolint:gosec
+		return hex.EncodeToString(h[:])
+	}
+	ha1 := md5Hex(a.username + ":" + a.realm + ":" + a.password)
+	ha2 := md5Hex(string(method) + ":" + u.String())
+	response := md5Hex(ha1 + ":" + a.nonce + ":" + ha2)
+	return fmt.Sprintf(`Digest username="%v", realm="%v", nonce="%v", uri="%v", response="%v"`,
+		a.username, a.realm, a.nonce, u.String(), response)
+}
+
+// encodeMulaw encodes a sample with G.711 mu-law.
+func encodeMulaw(sample int16) byte {
+	const bias = 0x84 >> 2
+	const clip = 8159
+
+	s := int(sample) >> 2
+	mask := 0xff
+	if s < 0 {
+		s = -s
+		mask = 0x7f
+	}
+	if s > clip {
+		s = clip
+	}
+	s += bias
+
+	segment := 0
+	for v := s >> 6; v > 0; v >>= 1 {
+		segment++
+	}
+	if segment >= 8 {
+		return byte(0x7f ^ mask)
+	}
+	return byte((segment<<4 | (s>>(segment+1))&0x0f) ^ mask)
+}
+
+// encodeAlaw encodes a sample with G.711 A-law.
+func encodeAlaw(sample int16) byte {
+	s := int(sample) >> 3
+	sign := 0x80
+	if s < 0 {
+		s = -s - 1
+		sign = 0
+	}
+	if s > 0xfff {
+		s = 0xfff
+	}
+
+	var b int
+	if s < 32 {
+		b = s >> 1
+	} else {
+		exponent := 1
+		for v := s >> 5; v > 1; v >>= 1 {
+			exponent++
+		}
+		b = exponent<<4 | (s>>exponent)&0x0f
+	}
+	return byte((sign | b) ^ 0x55)
+}
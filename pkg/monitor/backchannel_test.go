@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"net"
+	"nvr/pkg/video/gortsplib/pkg/base"
+	"nvr/pkg/video/gortsplib/pkg/conn"
+	"nvr/pkg/video/gortsplib/pkg/sdp"
+	"strings"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+const testBackchannelSDP = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=Media Presentation\r\n" +
+	"t=0 0\r\n" +
+	"m=video 0 RTP/AVP 96\r\n" +
+	"a=control:track1\r\n" +
+	"a=recvonly\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"m=audio 0 RTP/AVP 0\r\n" +
+	"a=control:track3\r\n" +
+	"a=sendonly\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n"
+
+// serveTestBackchannel is a camera that requires digest authentication.
+func serveTestBackchannel(t *testing.T, l net.Listener, packets chan<- *rtp.Packet) {
+	t.Helper()
+	nconn, err := l.Accept()
+	require.NoError(t, err)
+	defer nconn.Close()
+	c := conn.NewConn(nconn)
+
+	respond := func(req *base.Request, res base.Response) {
+		if res.Header == nil {
+			res.Header = base.Header{}
+		}
+		res.Header["CSeq"] = req.Header["CSeq"]
+		require.NoError(t, c.WriteResponse(&res))
+	}
+
+	authorized := false
+	for {
+		msg, err := c.ReadInterleavedFrameOrRequest()
+		if err != nil {
+			close(packets)
+			return
+		}
+		if frame, ok := msg.(*base.InterleavedFrame); ok {
+			require.Equal(t, 2, frame.Channel)
+			var packet rtp.Packet
+			require.NoError(t, packet.Unmarshal(frame.Payload))
+			packets <- &packet
+			continue
+		}
+
+		req := msg.(*base.Request)
+		require.Equal(t, base.HeaderValue{backchannelRequire}, req.Header["Require"])
+		if !authorized {
+			auth := req.Header["Authorization"]
+			if len(auth) == 0 {
+				respond(req, base.Response{
+					StatusCode: base.StatusUnauthorized,
+					Header: base.Header{
+						"WWW-Authenticate": base.HeaderValue{`Digest realm="cam", nonce="abc"`},
+					},
+				})
+				continue
+			}
+			require.Contains(t, auth[0], `username="u"`)
+			authorized = true
+		}
+
+		switch req.Method {
+		case base.Describe:
+			respond(req, base.Response{
+				StatusCode: base.StatusOK,
+				Header: base.Header{
+					"Content-Base": base.HeaderValue{"rtsp://" + l.Addr().String() + "/stream/"},
+				},
+				Body: []byte(testBackchannelSDP),
+			})
+		case base.Setup:
+			require.Equal(t, "rtsp://"+l.Addr().String()+"/stream/track3", req.URL.String())
+			require.Equal(t, base.HeaderValue{"RTP/AVP/TCP;interleaved=2-3"}, req.Header["Transport"])
+			respond(req, base.Response{
+				StatusCode: base.StatusOK,
+				Header:     base.Header{"Session": base.HeaderValue{"123;timeout=60"}},
+			})
+		case base.Play:
+			require.Equal(t, base.HeaderValue{"123"}, req.Header["Session"])
+			respond(req, base.Response{StatusCode: base.StatusOK})
+		case base.Teardown:
+			respond(req, base.Response{StatusCode: base.StatusOK})
+		}
+	}
+}
+
+func TestBackchannel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	packets := make(chan *rtp.Packet, 10)
+	go serveTestBackchannel(t, l, packets)
+
+	b, err := DialBackchannel(context.Background(), "rtsp://u:p@"+l.Addr().String()+"/stream", &net.Dialer{})
+	require.NoError(t, err)
+
+	// 1.5 packets, the remaining samples are buffered.
+	require.NoError(t, b.Write(make([]int16, backchannelPacketSize*3/2)))
+	require.NoError(t, b.Write(make([]int16, backchannelPacketSize/2)))
+	require.NoError(t, b.Close())
+
+	var received []*rtp.Packet
+	for p := range packets {
+		received = append(received, p)
+	}
+	require.Len(t, received, 2)
+	require.Equal(t, uint8(0), received[0].PayloadType)
+	require.Equal(t, received[0].SequenceNumber+1, received[1].SequenceNumber)
+	require.Equal(t, received[0].Timestamp+backchannelPacketSize, received[1].Timestamp)
+	require.Equal(t, strings.Repeat("\xff", backchannelPacketSize), string(received[1].Payload))
+}
+
+func TestParseBackchannelMedia(t *testing.T) {
+	parse := func(raw string) (*backchannelMedia, error) {
+		var desc sdp.SessionDescription
+		require.NoError(t, desc.Unmarshal([]byte(raw)))
+		return parseBackchannelMedia(desc)
+	}
+
+	media, err := parse(testBackchannelSDP)
+	require.NoError(t, err)
+	require.Equal(t, 1, media.index)
+	require.Equal(t, "track3", media.control)
+
+	_, err = parse(strings.ReplaceAll(testBackchannelSDP, "a=sendonly", "a=recvonly"))
+	require.ErrorIs(t, err, ErrBackchannelNotSupported)
+
+	_, err = parse(strings.ReplaceAll(testBackchannelSDP,
+		"m=audio 0 RTP/AVP 0\r\n", "m=audio 0 RTP/AVP 97\r\n"))
+	require.ErrorIs(t, err, ErrBackchannelCodec)
+}
+
+func TestG711(t *testing.T) {
+	cases := []struct {
+		sample int16
+		mulaw  byte
+		alaw   byte
+	}{
+		{0, 0xff, 0xd5},
+		{-1, 0x7e, 0x55},
+		{1000, 0xce, 0xfa},
+		{-1000, 0x4e, 0x7a},
+		{32767, 0x80, 0xaa},
+		{-32768, 0x00, 0x2a},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.mulaw, encodeMulaw(tc.sample), tc.sample)
+		require.Equal(t, tc.alaw, encodeAlaw(tc.sample), tc.sample)
+	}
+}
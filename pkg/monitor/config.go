@@ -2,7 +2,11 @@
 
 package monitor
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 // RawConfigs map of RawConfig.
 type RawConfigs map[string]RawConfig
@@ -47,16 +51,36 @@ func (c Config) InputOpts() string {
 	return c.v["inputOptions"]
 }
 
+// Audio encoders. Custom values are passed to FFmpeg.
+const (
+	AudioEncoderNone = "none"
+	AudioEncoderCopy = "copy"
+	AudioEncoderAAC  = "aac"
+
+	// Copy if the input audio is AAC, otherwise transcode to AAC.
+	AudioEncoderAuto = "auto"
+)
+
 func (c Config) audioEnabled() bool {
 	switch c.v["audioEncoder"] {
 	case "":
 		return false
-	case "none":
+	case AudioEncoderNone:
 		return false
 	}
 	return true
 }
 
+// AudioStream returns the index of the input audio
+// stream to use. Returns -1 if unset or invalid.
+func (c Config) AudioStream() int {
+	index, err := strconv.Atoi(c.v["audioStream"])
+	if err != nil || index < 0 {
+		return -1
+	}
+	return index
+}
+
 // AudioEncoder returns the monitor audio encoder.
 func (c Config) AudioEncoder() string {
 	return c.v["audioEncoder"]
@@ -78,8 +102,9 @@ func (c Config) SubInput() string {
 }
 
 // SubInputEnabled if sub input is available.
+// Virtual monitors don't have a sub input.
 func (c Config) SubInputEnabled() bool {
-	return c.SubInput() != ""
+	return (c.SubInput() != "" || c.AutoSubStream()) && !c.IsVirtual()
 }
 
 // video length is seconds.
@@ -91,11 +116,69 @@ func (c Config) alwaysRecord() bool {
 	return c.v["alwaysRecord"] == "true"
 }
 
+// EventClipEnabled returns true if a clip should be saved for every event.
+func (c Config) EventClipEnabled() bool {
+	return c.v["eventClip"] == "true"
+}
+
+// eventClipPre returns the duration before the event that's included in the clip.
+func (c Config) eventClipPre() time.Duration {
+	return parseSeconds(c.v["eventClipPre"], defaultEventClipPre, maxEventClipPre)
+}
+
+// eventClipPost returns the duration after the event that's included in the clip.
+func (c Config) eventClipPost() time.Duration {
+	return parseSeconds(c.v["eventClipPost"], defaultEventClipPost, maxEventClipPost)
+}
+
+// parseSeconds returns the value in seconds, or the default
+// if the value is unset or invalid. The value is capped at limit.
+func parseSeconds(value string, def time.Duration, limit time.Duration) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return def
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	if d > limit {
+		return limit
+	}
+	return d
+}
+
 // TimestampOffset returns the timestamp offset.
 func (c Config) TimestampOffset() string {
 	return c.v["timestampOffset"]
 }
 
+// stallTimeout returns the time without new segments before
+// the input process is restarted. Value is in seconds.
+func (c Config) stallTimeout() time.Duration {
+	seconds, err := strconv.Atoi(c.v["stallTimeout"])
+	if err != nil || seconds <= 0 {
+		return defaultStallTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Transcoder returns the transcoder backend, "auto" if unset.
+func (c Config) Transcoder() string {
+	if c.v["transcoder"] == "" {
+		return TranscoderAuto
+	}
+	return c.v["transcoder"]
+}
+
+// GStreamerSourceOpts returns the rtspsrc properties of the GStreamer transcoder.
+func (c Config) GStreamerSourceOpts() string {
+	return c.v["gstreamerSourceOptions"]
+}
+
+// StorageVolume returns the storage directory
+// the monitor is pinned to, empty if not pinned.
+func (c Config) StorageVolume() string {
+	return c.v["storageVolume"]
+}
+
 // LogLevel returns the ffmpeg log level.
 func (c Config) LogLevel() string {
 	return c.v["logLevel"]
@@ -106,6 +189,21 @@ func (c Config) Hwaccel() string {
 	return c.v["hwaccel"]
 }
 
+// ONVIFAddress returns the address of the camera ONVIF device service.
+func (c Config) ONVIFAddress() string {
+	return c.v["onvifAddress"]
+}
+
+// ONVIFUsername returns the username of the camera ONVIF user.
+func (c Config) ONVIFUsername() string {
+	return c.v["onvifUsername"]
+}
+
+// ONVIFPassword returns the password of the camera ONVIF user.
+func (c Config) ONVIFPassword() string {
+	return c.v["onvifPassword"]
+}
+
 // CensorLog replaces sensitive monitor config values.
 func (c Config) CensorLog(msg string) string {
 	if c.MainInput() != "" {
@@ -114,5 +212,8 @@ func (c Config) CensorLog(msg string) string {
 	if c.SubInput() != "" {
 		msg = strings.ReplaceAll(msg, c.SubInput(), "$SubInput")
 	}
+	if c.BackupInput() != "" {
+		msg = strings.ReplaceAll(msg, c.BackupInput(), "$BackupInput")
+	}
 	return msg
 }
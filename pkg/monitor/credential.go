@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"nvr/pkg/secret"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Credential is a camera username and password. Monitors reference it
+// by ID with the "credential" config key, the input URLs and ONVIF
+// settings then don't have to contain the password.
+type Credential struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+
+	// Password is never returned by the API.
+	Password string `json:"password,omitempty"`
+}
+
+// Credential errors.
+var (
+	ErrCredentialNotExist  = errors.New("credential does not exist")
+	ErrInvalidCredentialID = errors.New("invalid credential id")
+)
+
+var credentialIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Credentials stores the camera credentials, one JSON file per
+// credential. The passwords are encrypted like monitor configs.
+type Credentials struct {
+	credentials map[string]Credential
+	path        string
+	secrets     *secret.Cipher
+	mu          sync.Mutex
+}
+
+// NewCredentials loads the credentials from the directory.
+func NewCredentials(configPath string, secrets *secret.Cipher) (*Credentials, error) {
+	if err := os.MkdirAll(configPath, 0o700); err != nil {
+		return nil, fmt.Errorf("create credentials directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read credentials directory: %w", err)
+	}
+
+	credentials := make(map[string]Credential)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(configPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read credential: %w", err)
+		}
+		var c Credential
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, fmt.Errorf("unmarshal credential: %w: %v", err, entry.Name())
+		}
+		// The password is kept encrypted if it can't be decrypted,
+		// monitors that use it will fail to authenticate.
+		if password, err := secrets.Decrypt(credentialScope(c.ID), "password", c.Password); err == nil {
+			c.Password = password
+		}
+		credentials[c.ID] = c
+	}
+
+	return &Credentials{
+		credentials: credentials,
+		path:        configPath,
+		secrets:     secrets,
+	}, nil
+}
+
+// Passwords of credentials are bound to the
+// credential ID to not collide with monitor IDs.
+func credentialScope(id string) string {
+	return "credential:" + id
+}
+
+func (c *Credentials) credentialPath(id string) string {
+	return filepath.Join(c.path, id+".json")
+}
+
+// List returns all credentials sorted by ID without the passwords.
+func (c *Credentials) List() []Credential {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	credentials := []Credential{}
+	for _, cred := range c.credentials {
+		cred.Password = ""
+		credentials = append(credentials, cred)
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].ID < credentials[j].ID
+	})
+	return credentials
+}
+
+// Get returns a credential by ID.
+func (c *Credentials) Get(id string) (Credential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cred, exist := c.credentials[id]
+	if !exist {
+		return Credential{}, fmt.Errorf("%w: %v", ErrCredentialNotExist, id)
+	}
+	return cred, nil
+}
+
+// Set creates or updates a credential. The
+// password is kept if the new password is empty.
+func (c *Credentials) Set(cred Credential) error {
+	if !credentialIDRegex.MatchString(cred.ID) {
+		return fmt.Errorf("%w: %q", ErrInvalidCredentialID, cred.ID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, exist := c.credentials[cred.ID]; exist && cred.Password == "" {
+		cred.Password = old.Password
+	}
+
+	encrypted := cred
+	password, err := c.secrets.Encrypt(credentialScope(cred.ID), "password", cred.Password)
+	if err != nil {
+		return fmt.Errorf("encrypt password: %w", err)
+	}
+	encrypted.Password = password
+
+	raw, err := json.MarshalIndent(encrypted, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshal credential: %w", err)
+	}
+	if err := os.WriteFile(c.credentialPath(cred.ID), raw, 0o600); err != nil {
+		return fmt.Errorf("write credential: %w", err)
+	}
+	c.credentials[cred.ID] = cred
+	return nil
+}
+
+// Delete deletes a credential by ID.
+func (c *Credentials) Delete(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exist := c.credentials[id]; !exist {
+		return fmt.Errorf("%w: %v", ErrCredentialNotExist, id)
+	}
+	if err := os.Remove(c.credentialPath(id)); err != nil {
+		return err
+	}
+	delete(c.credentials, id)
+	return nil
+}
+
+// applyCredential returns a copy of the config with the credential added
+// to the input URLs and ONVIF settings that don't have their own.
+func applyCredential(rawConf RawConfig, cred Credential) RawConfig {
+	c := make(RawConfig, len(rawConf))
+	for key, value := range rawConf {
+		c[key] = value
+	}
+	for _, key := range []string{"mainInput", "subInput", "backupInput"} {
+		u, err := url.Parse(c[key])
+		if c[key] == "" || err != nil || u.User != nil {
+			continue
+		}
+		u.User = url.UserPassword(cred.Username, cred.Password)
+		c[key] = u.String()
+	}
+	if c["onvifUsername"] == "" && c["onvifPassword"] == "" {
+		c["onvifUsername"] = cred.Username
+		c["onvifPassword"] = cred.Password
+	}
+	return c
+}
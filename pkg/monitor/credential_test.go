@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nvr/pkg/log"
+	"nvr/pkg/secret"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentials(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "secret.key")
+	secrets, err := secret.NewCipher(storage.SecretStoreFile, keyPath)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	credentials, err := NewCredentials(dir, secrets)
+	require.NoError(t, err)
+	require.Empty(t, credentials.List())
+
+	err = credentials.Set(Credential{ID: "a b"})
+	require.ErrorIs(t, err, ErrInvalidCredentialID)
+
+	require.NoError(t, credentials.Set(Credential{ID: "b", Username: "admin", Password: "hunter2"}))
+	require.NoError(t, credentials.Set(Credential{ID: "a", Username: "user"}))
+
+	// The password is encrypted on disk and not listed.
+	raw, err := os.ReadFile(filepath.Join(dir, "b.json"))
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(raw), "hunter2"))
+
+	expected := []Credential{{ID: "a", Username: "user"}, {ID: "b", Username: "admin"}}
+	require.Equal(t, expected, credentials.List())
+
+	// An empty password keeps the current password.
+	require.NoError(t, credentials.Set(Credential{ID: "b", Username: "admin2"}))
+
+	// Reloaded.
+	credentials, err = NewCredentials(dir, secrets)
+	require.NoError(t, err)
+	cred, err := credentials.Get("b")
+	require.NoError(t, err)
+	require.Equal(t, Credential{ID: "b", Username: "admin2", Password: "hunter2"}, cred)
+
+	require.NoError(t, credentials.Delete("b"))
+	_, err = credentials.Get("b")
+	require.ErrorIs(t, err, ErrCredentialNotExist)
+	require.ErrorIs(t, credentials.Delete("b"), ErrCredentialNotExist)
+}
+
+func TestApplyCredential(t *testing.T) {
+	cred := Credential{Username: "admin", Password: "p@ss"}
+
+	c := RawConfig{
+		"mainInput": "rtsp://x:554/main",
+		"subInput":  "rtsp://other:pass@x:554/sub",
+	}
+	expected := RawConfig{
+		"mainInput":     "rtsp://admin:p%40ss@x:554/main",
+		"subInput":      "rtsp://other:pass@x:554/sub",
+		"onvifUsername": "admin",
+		"onvifPassword": "p@ss",
+	}
+	require.Equal(t, expected, applyCredential(c, cred))
+	require.Equal(t, "rtsp://x:554/main", c["mainInput"])
+
+	// Own ONVIF credentials are kept.
+	c = RawConfig{"onvifUsername": "onvif", "onvifPassword": "pass"}
+	require.Equal(t, c, applyCredential(c, cred))
+}
+
+func TestResolvedConfig(t *testing.T) {
+	credentials, err := NewCredentials(t.TempDir(), nil)
+	require.NoError(t, err)
+	require.NoError(t, credentials.Set(Credential{ID: "c", Username: "admin", Password: "pass"}))
+
+	manager, err := NewManager(
+		prepareDir(t),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&Hooks{Migrate: func(RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	manager.SetCredentials(credentials)
+
+	require.NoError(t, manager.MonitorSet("x", RawConfig{
+		"id": "x", "mainInput": "rtsp://x/main", "credential": "c",
+	}))
+	require.NoError(t, manager.MonitorSet("y", RawConfig{"id": "y", "credential": "nil"}))
+
+	c, err := manager.ResolvedConfig("x")
+	require.NoError(t, err)
+	require.Equal(t, "rtsp://admin:pass@x/main", c["mainInput"])
+
+	// The stored config is unchanged.
+	require.Equal(t, "rtsp://x/main", manager.MonitorConfigs()["x"]["mainInput"])
+
+	_, err = manager.ResolvedConfig("y")
+	require.ErrorIs(t, err, ErrCredentialNotExist)
+
+	_, err = manager.ResolvedConfig("nil")
+	require.ErrorIs(t, err, ErrMonitorNotExist)
+}
@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"nvr/pkg/video/hls"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event clips are short videos around an event. The segments cached by the
+// HLS muxer of the main input act as the pre-buffer, the muxer keeps the
+// last 3 segments of about 0.9 seconds each. The clip is saved in the same
+// format as recordings and can be served by storage.VideoReader.
+const (
+	defaultEventClipPre  = 2 * time.Second
+	maxEventClipPre      = 3 * time.Second
+	defaultEventClipPost = 5 * time.Second
+	maxEventClipPost     = time.Minute
+
+	// Clips older than this are deleted when a new clip is saved.
+	eventClipMaxAge = 7 * 24 * time.Hour
+
+	// Events that overlap the clip of an earlier event are merged into
+	// it, the clip is extended by the post time up to this length.
+	maxEventClipDuration = 5 * time.Minute
+
+	// Max number of clips that are generated at the same time by all
+	// monitors. Clips are skipped if there are more, waiting would
+	// lose the pre-buffer.
+	maxConcurrentEventClips = 4
+)
+
+// EventClipHook is called after the clip of an event has been saved.
+type EventClipHook func(r *Recorder, event storage.Event, clipID string)
+
+const eventIDLayout = "2006-01-02_15-04-05.000"
+
+// EventID returns the ID of an event, the event time
+// in milliseconds followed by the monitor ID. Clips start
+// at the keyframe before the pre time, not at the exact frame.
+// Example: "2006-01-02_15-04-05.000_id"
+func EventID(monitorID string, eventTime time.Time) string {
+	return eventTime.Format(eventIDLayout) + "_" + monitorID
+}
+
+var eventIDRegex = regexp.MustCompile(
+	`^\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2}\.\d{3}_[A-Za-z0-9_.-]+$`)
+
+// ErrInvalidEventID invalid event ID.
+var ErrInvalidEventID = errors.New("invalid event ID")
+
+// EventClipPath returns the path of the event clip, without extension.
+func EventClipPath(clipsDir string, id string) (string, error) {
+	if !eventIDRegex.MatchString(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEventID, id)
+	}
+	return filepath.Join(clipsDir, id), nil
+}
+
+// eventClip is a clip that's being generated.
+type eventClip struct {
+	id     string
+	start  time.Time
+	end    time.Time
+	events []storage.Event
+}
+
+// eventClips is the clip of a monitor that's being generated.
+type eventClips struct {
+	current *eventClip
+	mu      sync.Mutex
+}
+
+// add merges the event into the current clip if they overlap and
+// returns nil. Otherwise a new clip is returned and becomes current.
+func (c *eventClips) add(id string, event storage.Event, pre, post time.Duration) *eventClip {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := event.Time.Add(-pre)
+	end := event.Time.Add(post)
+	cur := c.current
+	if cur != nil && start.Before(cur.end) && !end.After(cur.start.Add(maxEventClipDuration)) {
+		cur.events = append(cur.events, event)
+		if end.After(cur.end) {
+			cur.end = end
+		}
+		return nil
+	}
+	clip := &eventClip{
+		id:     id,
+		start:  start,
+		end:    end,
+		events: []storage.Event{event},
+	}
+	c.current = clip
+	return clip
+}
+
+// ended returns true if the segment after prev is past the end of the
+// clip. Later events are not merged into the clip once it has ended.
+func (c *eventClips) ended(clip *eventClip, prev *hls.Segment) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !prev.StartTime.After(clip.end) {
+		return false
+	}
+	if c.current == clip {
+		c.current = nil
+	}
+	return true
+}
+
+// done returns the events that were merged into the clip.
+func (c *eventClips) done(clip *eventClip) []storage.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == clip {
+		c.current = nil
+	}
+	return clip.events
+}
+
+// startEventClip merges the event into the clip that's being generated
+// if they overlap, otherwise a new clip is generated in the background.
+func (r *Recorder) startEventClip(ctx context.Context, event storage.Event) {
+	id := EventID(r.Config.ID(), event.Time)
+	clip := r.clips.add(id, event, r.Config.eventClipPre(), r.Config.eventClipPost())
+	if clip == nil {
+		r.logf(log.LevelDebug, "event merged into the current event clip: %v", id)
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.saveEventClip(ctx, clip)
+	}()
+}
+
+// saveEventClip saves the clip and calls the event clip hook for each
+// of its events. Merged events are hard linked to the clip so that it
+// can also be found by their event ID.
+func (r *Recorder) saveEventClip(ctx context.Context, clip *eventClip) {
+	select {
+	case r.clipSlots <- struct{}{}:
+		defer func() { <-r.clipSlots }()
+	default:
+		r.clips.done(clip)
+		r.logf(log.LevelWarning, "too many event clips are being generated, skipping: %v", clip.id)
+		return
+	}
+
+	r.logf(log.LevelDebug, "generating event clip: %v", clip.id)
+	err := r.generateEventClip(ctx, clip)
+	events := r.clips.done(clip)
+	if err != nil {
+		r.logf(log.LevelError, "event clip: %v", err)
+		return
+	}
+	r.logf(log.LevelDebug, "event clip saved: %v", clip.id)
+
+	clipsDir := r.Env.EventClipsDir()
+	for _, event := range events {
+		clipID := EventID(r.Config.ID(), event.Time)
+		if err := linkEventClip(clipsDir, clip.id, clipID); err != nil {
+			r.logf(log.LevelError, "link event clip: %v", err)
+			clipID = clip.id
+		}
+		if r.hooks.EventClip != nil && !r.InMaintenance(time.Now()) {
+			r.hooks.EventClip(r, event, clipID)
+		}
+	}
+}
+
+// linkEventClip hard links the files of the clip to the event ID.
+func linkEventClip(clipsDir string, clipID string, eventID string) error {
+	if clipID == eventID {
+		return nil
+	}
+	for _, ext := range []string{".meta", ".mdat"} {
+		oldPath := filepath.Join(clipsDir, clipID+ext)
+		newPath := filepath.Join(clipsDir, eventID+ext)
+		if err := os.Link(oldPath, newPath); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+var errEventClipEnd = errors.New("end of event clip")
+
+func (r *Recorder) generateEventClip(ctx context.Context, clip *eventClip) error {
+	ctx, cancel := context.WithDeadline(ctx, clip.start.Add(maxEventClipDuration+time.Minute))
+	defer cancel()
+
+	muxer, err := r.input.HLSMuxer(ctx)
+	if err != nil {
+		return fmt.Errorf("get muxer: %w", err)
+	}
+
+	firstSegment, err := firstClipSegment(muxer.NextSegment, clip.start)
+	if err != nil {
+		return fmt.Errorf("first segment: %w", err)
+	}
+
+	clipsDir := r.Env.EventClipsDir()
+	if err := os.MkdirAll(clipsDir, 0o755); err != nil {
+		return fmt.Errorf("make directory for clip: %w", err)
+	}
+	path := filepath.Join(clipsDir, clip.id)
+
+	// The end is read for every segment, merged events extend it.
+	nextSegment := func(prev *hls.Segment) (*hls.Segment, error) {
+		if r.clips.ended(clip, prev) {
+			return nil, errEventClipEnd
+		}
+		return muxer.NextSegment(prev)
+	}
+
+	_, _, err = generateVideo(
+		ctx,
+		path,
+		r.Config.ID(),
+		nextSegment,
+		firstSegment,
+		muxer.VideoTrack(),
+		muxer.AudioTrack(),
+		maxEventClipDuration,
+	)
+	if err != nil {
+		os.Remove(path + ".meta")
+		os.Remove(path + ".mdat")
+		return fmt.Errorf("write video: %w", err)
+	}
+
+	if err := pruneEventClips(clipsDir, time.Now().Add(-eventClipMaxAge)); err != nil {
+		r.logf(log.LevelError, "prune event clips: %v", err)
+	}
+	return nil
+}
+
+// firstClipSegment returns the first segment that ends after start.
+// The search begins at the oldest cached segment. Segments start with
+// a keyframe, so the clip may begin up to one segment before start.
+func firstClipSegment(nextSegment nextSegmentFunc, start time.Time) (*hls.Segment, error) {
+	seg, err := nextSegment(nil)
+	if err != nil {
+		return nil, err
+	}
+	for !seg.StartTime.Add(seg.RenderedDuration).After(start) {
+		seg, err = nextSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+// pruneEventClips deletes the clips that were modified before t.
+func pruneEventClips(clipsDir string, t time.Time) error {
+	entries, err := os.ReadDir(clipsDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".meta") && !strings.HasSuffix(name, ".mdat") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(t) {
+			if err := os.Remove(filepath.Join(clipsDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
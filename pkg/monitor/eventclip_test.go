@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/storage"
+	"nvr/pkg/video/hls"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventClipPath(t *testing.T) {
+	eventTime := time.Date(2000, 1, 2, 3, 4, 5, 6e6, time.Local)
+	id := EventID("m1", eventTime)
+	require.Equal(t, "2000-01-02_03-04-05.006_m1", id)
+
+	path, err := EventClipPath("/clips", id)
+	require.NoError(t, err)
+	require.Equal(t, "/clips/2000-01-02_03-04-05.006_m1", path)
+
+	id = EventID("cam.1", eventTime)
+	path, err = EventClipPath("/clips", id)
+	require.NoError(t, err)
+	require.Equal(t, "/clips/2000-01-02_03-04-05.006_cam.1", path)
+
+	for _, id := range []string{
+		"",
+		"2000-01-02_03-04-05_m1",
+		"2000-01-02_03-04-05.006_",
+		"2000-01-02_03-04-05.006_../m1",
+	} {
+		_, err := EventClipPath("/clips", id)
+		require.ErrorIs(t, err, ErrInvalidEventID, id)
+	}
+}
+
+func TestEventClipConfig(t *testing.T) {
+	c := NewConfig(RawConfig{})
+	require.False(t, c.EventClipEnabled())
+	require.Equal(t, defaultEventClipPre, c.eventClipPre())
+	require.Equal(t, defaultEventClipPost, c.eventClipPost())
+
+	c = NewConfig(RawConfig{
+		"eventClip":     "true",
+		"eventClipPre":  "1.5",
+		"eventClipPost": "x",
+	})
+	require.True(t, c.EventClipEnabled())
+	require.Equal(t, 1500*time.Millisecond, c.eventClipPre())
+	require.Equal(t, defaultEventClipPost, c.eventClipPost())
+
+	c = NewConfig(RawConfig{"eventClipPre": "10", "eventClipPost": "3600"})
+	require.Equal(t, maxEventClipPre, c.eventClipPre())
+	require.Equal(t, maxEventClipPost, c.eventClipPost())
+}
+
+func TestFirstClipSegment(t *testing.T) {
+	start := time.Unix(100, 0)
+	segments := []*hls.Segment{
+		{ID: 1, StartTime: start.Add(-2 * time.Second), RenderedDuration: time.Second},
+		{ID: 2, StartTime: start.Add(-time.Second), RenderedDuration: time.Second},
+		{ID: 3, StartTime: start, RenderedDuration: time.Second},
+	}
+	nextSegment := func(prev *hls.Segment) (*hls.Segment, error) {
+		if prev == nil {
+			return segments[0], nil
+		}
+		if int(prev.ID) == len(segments) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		return segments[prev.ID], nil
+	}
+
+	seg, err := firstClipSegment(nextSegment, start.Add(-500*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), seg.ID)
+
+	seg, err = firstClipSegment(nextSegment, start.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), seg.ID)
+
+	seg, err = firstClipSegment(nextSegment, start)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), seg.ID)
+
+	_, err = firstClipSegment(nextSegment, start.Add(time.Minute))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestPruneEventClips(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, modTime := range map[string]time.Time{
+		"old.meta":  now.Add(-2 * time.Hour),
+		"old.mdat":  now.Add(-2 * time.Hour),
+		"new.meta":  now,
+		"new.mdat":  now,
+		"other.txt": now.Add(-2 * time.Hour),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	require.NoError(t, pruneEventClips(dir, now.Add(-time.Hour)))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"new.mdat", "new.meta", "other.txt"}, names)
+}
+
+func TestEventClips(t *testing.T) {
+	start := time.Unix(1000, 0)
+	pre, post := 2*time.Second, 5*time.Second
+	event := func(offset time.Duration) storage.Event {
+		return storage.Event{Time: start.Add(offset)}
+	}
+	c := &eventClips{}
+
+	clip := c.add("a", event(0), pre, post)
+	require.NotNil(t, clip)
+	require.Equal(t, start.Add(-pre), clip.start)
+	require.Equal(t, start.Add(post), clip.end)
+
+	// Overlapping events are merged and extend the clip.
+	require.Nil(t, c.add("b", event(6*time.Second), pre, post))
+	require.Equal(t, start.Add(11*time.Second), clip.end)
+
+	// Not past the end.
+	require.False(t, c.ended(clip, &hls.Segment{StartTime: start.Add(11 * time.Second)}))
+
+	// Events after the end start a new clip.
+	require.True(t, c.ended(clip, &hls.Segment{StartTime: clip.end.Add(time.Second)}))
+	require.Nil(t, c.current)
+	require.Len(t, c.done(clip), 2)
+	require.NotNil(t, c.add("c", event(8*time.Second), pre, post))
+
+	// The clip isn't extended past the max duration.
+	c.current = &eventClip{start: start, end: start.Add(maxEventClipDuration)}
+	require.NotNil(t, c.add("d", event(maxEventClipDuration-time.Second), pre, post))
+}
+
+func TestLinkEventClip(t *testing.T) {
+	dir := t.TempDir()
+	for _, ext := range []string{".meta", ".mdat"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a"+ext), []byte(ext), 0o600))
+	}
+	require.NoError(t, linkEventClip(dir, "a", "a"))
+	require.NoError(t, linkEventClip(dir, "a", "b"))
+	require.NoError(t, linkEventClip(dir, "a", "b"))
+
+	content, err := os.ReadFile(filepath.Join(dir, "b.mdat"))
+	require.NoError(t, err)
+	require.Equal(t, ".mdat", string(content))
+	require.FileExists(t, filepath.Join(dir, "b.meta"))
+}
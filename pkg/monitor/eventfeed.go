@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"nvr/pkg/feed"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"sync"
+	"time"
+)
+
+// DetectionScale is the value that detection coordinates are relative to.
+// Coordinates are percentages of the frame size, with the origin in the
+// top left corner. A rect with the values {25, 50, 75, 100} is drawn
+// from 25% of the frame height and 50% of the frame width to the
+// bottom right corner. Rects are ordered top, left, bottom, right.
+const DetectionScale = 100
+
+// LiveEvent is an event sent to the live event feed.
+type LiveEvent struct {
+	// Event ID, see EventID. Unset for promoted log entries.
+	ID        string `json:"id,omitempty"`
+	MonitorID string `json:"monitorId"`
+
+	// Timestamp of the analyzed frame.
+	Time       time.Time           `json:"time"`
+	Detections []storage.Detection `json:"detections"`
+	Duration   time.Duration       `json:"duration"`
+
+	// Coordinate normalization, see DetectionScale.
+	Scale int `json:"scale"`
+
+	// Rule and log message of promoted log entries.
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func newLiveEvent(monitorID string, event storage.Event) LiveEvent {
+	detections := event.Detections
+	if detections == nil {
+		detections = []storage.Detection{}
+	}
+	return LiveEvent{
+		ID:         EventID(monitorID, event.Time),
+		MonitorID:  monitorID,
+		Time:       event.Time,
+		Detections: detections,
+		Duration:   event.Duration,
+		Scale:      DetectionScale,
+	}
+}
+
+const (
+	eventFeedBufferSize  = 16
+	eventFeedHistorySize = 256
+)
+
+// eventFeed broadcasts events to subscribers. Sending never blocks,
+// subscribers that fall behind are dropped and their channels closed.
+// Recent events are also kept in a buffer for clients that poll.
+type eventFeed struct {
+	subs    map[chan LiveEvent]struct{}
+	history *feed.Buffer[LiveEvent]
+	mu      sync.Mutex
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{
+		subs:    make(map[chan LiveEvent]struct{}),
+		history: feed.NewBuffer[LiveEvent](eventFeedHistorySize),
+	}
+}
+
+func (f *eventFeed) send(event LiveEvent) {
+	f.history.Push(event)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		select {
+		case sub <- event:
+		default:
+			delete(f.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+// subscribe returns a channel that's closed when ctx is canceled.
+func (f *eventFeed) subscribe(ctx context.Context) <-chan LiveEvent {
+	sub := make(chan LiveEvent, eventFeedBufferSize)
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, exist := f.subs[sub]; exist {
+			delete(f.subs, sub)
+			close(sub)
+		}
+	}()
+	return sub
+}
+
+// PublishLogEvent sends a promoted log entry to the event feed.
+// The monitor ID is empty if the entry isn't from a monitor.
+// Entries from monitors in maintenance are dropped.
+//
+// Entries from running monitors are also passed to the event hooks,
+// like the alert addon, as a event with the "log" trigger. The rule
+// is the detection label and the message the detection text. These
+// events don't trigger recordings.
+func (m *Manager) PublishLogEvent(event log.PromotedEvent) {
+	monitorID := event.Entry.MonitorID
+	if monitorID != "" {
+		if _, active := m.maintenance.get(monitorID, time.Now()); active {
+			return
+		}
+	}
+	m.eventFeed.send(LiveEvent{
+		MonitorID:  event.Entry.MonitorID,
+		Time:       event.Entry.GetTime(),
+		Detections: []storage.Detection{},
+		Scale:      DetectionScale,
+		Rule:       event.Rule,
+		Message:    event.Entry.Msg,
+	})
+
+	if monitorID == "" || m.hooks.Event == nil {
+		return
+	}
+	m.mu.Lock()
+	monitor, running := m.runningMonitors[monitorID]
+	m.mu.Unlock()
+	if !running || monitor.recorder == nil {
+		return
+	}
+	m.hooks.Event(monitor.recorder, &storage.Event{
+		Time:    event.Entry.GetTime(),
+		Trigger: storage.TriggerLog,
+		Detections: []storage.Detection{{
+			Label: event.Rule,
+			Score: 100,
+			Text:  event.Entry.Msg,
+		}},
+	})
+}
+
+// SubscribeEvents returns a channel with the events of all monitors,
+// including events that are ignored while disarmed. The channel is
+// closed when ctx is canceled or if the subscriber falls behind.
+func (m *Manager) SubscribeEvents(ctx context.Context) <-chan LiveEvent {
+	return m.eventFeed.subscribe(ctx)
+}
+
+// EventHistory returns the buffer with the recent events of all monitors.
+// Clients use the cursors to resume the feed without missing events.
+func (m *Manager) EventHistory() *feed.Buffer[LiveEvent] {
+	return m.eventFeed.history
+}
+
+// MonitorState is sent to the state history when a monitor starts or
+// stops, and when the health state of one of its inputs changes.
+type MonitorState struct {
+	MonitorID string    `json:"monitorId"`
+	Time      time.Time `json:"time"`
+	State     string    `json:"state"`
+
+	// Input health changes only.
+	Input     string `json:"input,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Monitor states.
+const (
+	MonitorStarted = "started"
+	MonitorStopped = "stopped"
+)
+
+const stateHistorySize = 64
+
+// sendState is a no-op if the monitor doesn't have a state history.
+func (m *Monitor) sendState(state string) {
+	if m.stateHistory == nil {
+		return
+	}
+	m.stateHistory.Push(MonitorState{
+		MonitorID: m.Config.ID(),
+		Time:      time.Now(),
+		State:     state,
+	})
+}
+
+// sendHealth sends the input health state to the state history.
+// The starting and stopped states are covered by the monitor states.
+// Down states are suppressed during maintenance.
+func (m *Monitor) sendHealth(input string, health InputHealth) {
+	if m.stateHistory == nil {
+		return
+	}
+	switch health.State {
+	case HealthOK:
+	case HealthStalled, HealthCrashed:
+		if m.InMaintenance(time.Now()) {
+			return
+		}
+	default:
+		return
+	}
+	state := MonitorState{
+		MonitorID: m.Config.ID(),
+		Time:      time.Now(),
+		State:     string(health.State),
+		Input:     input,
+	}
+	if health.State == HealthCrashed {
+		state.LastError = health.LastError
+	}
+	m.stateHistory.Push(state)
+}
+
+// StateHistory returns the buffer with the recent monitor starts and stops.
+func (m *Manager) StateHistory() *feed.Buffer[MonitorState] {
+	return m.stateHistory
+}
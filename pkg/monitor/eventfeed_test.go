@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nvr/pkg/feed"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFeed(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		feed := newEventFeed()
+		sub := feed.subscribe(ctx)
+
+		detection := storage.Detection{
+			Label: "a",
+			Score: 90,
+			Region: &storage.Region{
+				Rect: &ffmpeg.Rect{10, 20, 30, 40},
+			},
+		}
+		eventTime := time.Unix(1, 0)
+		feed.send(newLiveEvent("x", storage.Event{
+			Time:       eventTime,
+			Detections: []storage.Detection{detection},
+			Duration:   time.Second,
+		}))
+
+		expected := LiveEvent{
+			ID:         EventID("x", eventTime),
+			MonitorID:  "x",
+			Time:       eventTime,
+			Detections: []storage.Detection{detection},
+			Duration:   time.Second,
+			Scale:      DetectionScale,
+		}
+		require.Equal(t, expected, <-sub)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed := newEventFeed()
+		sub := feed.subscribe(ctx)
+		cancel()
+
+		_, ok := <-sub
+		require.False(t, ok)
+		feed.send(LiveEvent{})
+	})
+	t.Run("dropSlow", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		feed := newEventFeed()
+		sub := feed.subscribe(ctx)
+		for i := 0; i <= eventFeedBufferSize; i++ {
+			feed.send(LiveEvent{})
+		}
+
+		for i := 0; i < eventFeedBufferSize; i++ {
+			_, ok := <-sub
+			require.True(t, ok)
+		}
+		_, ok := <-sub
+		require.False(t, ok)
+	})
+}
+
+func TestEventFeedHistory(t *testing.T) {
+	f := newEventFeed()
+	f.send(LiveEvent{MonitorID: "a"})
+	f.send(LiveEvent{MonitorID: "b"})
+
+	items, _ := f.history.Since(1)
+	require.Len(t, items, 1)
+	require.Equal(t, uint64(2), items[0].Cursor)
+	require.Equal(t, "b", items[0].Value.MonitorID)
+}
+
+func TestMonitorStateHistory(t *testing.T) {
+	history := feed.NewBuffer[MonitorState](stateHistorySize)
+	m := &Monitor{
+		Config:       NewConfig(RawConfig{"id": "a"}),
+		stateHistory: history,
+	}
+
+	// Not started.
+	m.stop()
+	require.Equal(t, uint64(0), history.Cursor())
+
+	m.sendState(MonitorStarted)
+	_, m.cancel = context.WithCancel(context.Background())
+	m.stop()
+
+	items, _ := history.Since(0)
+	require.Len(t, items, 2)
+	require.Equal(t, "a", items[0].Value.MonitorID)
+	require.Equal(t, MonitorStarted, items[0].Value.State)
+	require.Equal(t, MonitorStopped, items[1].Value.State)
+}
+
+func TestPublishLogEvent(t *testing.T) {
+	store, err := newMaintenanceStore("", log.NewDummyLogger())
+	require.NoError(t, err)
+
+	var events []storage.Event
+	recorder := &Recorder{}
+	m := &Manager{
+		runningMonitors: monitors{"a": &Monitor{recorder: recorder}},
+		maintenance:     store,
+		eventFeed:       newEventFeed(),
+		hooks: Hooks{Event: func(r *Recorder, e *storage.Event) {
+			require.Equal(t, recorder, r)
+			events = append(events, *e)
+		}},
+	}
+	publish := func(monitorID string) {
+		m.PublishLogEvent(log.PromotedEvent{
+			Rule:  "crashes",
+			Entry: log.Entry{MonitorID: monitorID, Msg: "crashed"},
+		})
+	}
+
+	publish("a")
+	publish("")
+	publish("b")
+	items, _ := m.eventFeed.history.Since(0)
+	require.Len(t, items, 3)
+
+	expected := []storage.Event{{
+		Time:       time.Unix(0, 0),
+		Trigger:    storage.TriggerLog,
+		Detections: []storage.Detection{{Label: "crashes", Score: 100, Text: "crashed"}},
+	}}
+	require.Equal(t, expected, events)
+
+	require.NoError(t, store.set("a", Maintenance{Start: time.Now()}, time.Now()))
+	publish("a")
+	require.Len(t, events, 1)
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"nvr/pkg/video/mp4muxer"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Event snapshots are full resolution JPEG images of detection events.
+// The image is the first frame of the main input segment that contains
+// the event, events in the same segment share the image. The event is
+// saved next to the image, "<event-id>.jpeg" and "<event-id>.json".
+const (
+	// Snapshots older than this are deleted when a new snapshot is saved.
+	eventSnapshotMaxAge = 7 * 24 * time.Hour
+
+	eventSnapshotExt = ".jpeg"
+)
+
+// EventSnapshotHook is called after the snapshot of an event has been saved.
+type EventSnapshotHook func(r *Recorder, event storage.Event, snapshotID string)
+
+// EventSnapshotPath returns the path of the event snapshot, without extension.
+func EventSnapshotPath(snapshotsDir string, id string) (string, error) {
+	if !eventIDRegex.MatchString(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEventID, id)
+	}
+	return filepath.Join(snapshotsDir, id), nil
+}
+
+// saveEventSnapshot saves the snapshot and calls the event snapshot hook.
+func (r *Recorder) saveEventSnapshot(ctx context.Context, event storage.Event) {
+	id := EventID(r.Config.ID(), event.Time)
+	if err := r.generateEventSnapshot(ctx, id, event); err != nil {
+		r.logf(log.LevelError, "event snapshot: %v", err)
+		return
+	}
+	r.logf(log.LevelDebug, "event snapshot saved: %v", id)
+
+	if r.hooks.EventSnapshot != nil {
+		r.hooks.EventSnapshot(r, event, id)
+	}
+}
+
+func (r *Recorder) generateEventSnapshot(ctx context.Context, id string, event storage.Event) error {
+	ctx, cancel := context.WithDeadline(ctx, event.Time.Add(time.Minute))
+	defer cancel()
+
+	muxer, err := r.input.HLSMuxer(ctx)
+	if err != nil {
+		return fmt.Errorf("get muxer: %w", err)
+	}
+
+	seg, err := firstClipSegment(muxer.NextSegment, event.Time)
+	if err != nil {
+		return fmt.Errorf("segment: %w", err)
+	}
+
+	img, err := r.snapshotCache.get(seg, func() ([]byte, error) {
+		var video bytes.Buffer
+		err := mp4muxer.GenerateThumbnailVideo(&video, seg, muxer.VideoTrack())
+		if err != nil {
+			return nil, fmt.Errorf("generate video: %w", err)
+		}
+		return encodeJPEG(ctx, r.Env.FFmpegBin, &video)
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := r.Env.EventSnapshotsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("make directory for snapshot: %w", err)
+	}
+	if err := writeEventSnapshot(filepath.Join(dir, id), img, event); err != nil {
+		return err
+	}
+
+	if err := pruneEventSnapshots(dir, time.Now().Add(-eventSnapshotMaxAge)); err != nil {
+		r.logf(log.LevelError, "prune event snapshots: %v", err)
+	}
+	return nil
+}
+
+// writeEventSnapshot writes the event and then the image. The
+// image is renamed into place, so listed images are complete.
+func writeEventSnapshot(path string, img []byte, event storage.Event) error {
+	rawEvent, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".json", rawEvent, 0o600); err != nil {
+		return err
+	}
+
+	tmpPath := path + eventSnapshotExt + ".tmp"
+	if err := os.WriteFile(tmpPath, img, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path+eventSnapshotExt)
+}
+
+// pruneEventSnapshots deletes the snapshots that were modified before t.
+func pruneEventSnapshots(snapshotsDir string, t time.Time) error {
+	entries, err := os.ReadDir(snapshotsDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(t) {
+			if err := os.Remove(filepath.Join(snapshotsDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EventSnapshot is a item in the event snapshot gallery.
+type EventSnapshot struct {
+	ID         string              `json:"id"`
+	MonitorID  string              `json:"monitorId"`
+	Time       time.Time           `json:"time"`
+	Detections []storage.Detection `json:"detections"`
+}
+
+// EventSnapshotQuery is a query of the event snapshot gallery.
+type EventSnapshotQuery struct {
+	// Start after this event ID, or time "2006-01-02_15-04-05".
+	// The newest snapshots are returned if empty.
+	Time     string
+	Limit    int
+	Reverse  bool // Oldest first.
+	Monitors []string
+
+	// Only snapshots with a detected text that contains this,
+	// for example a license plate. See NormalizePlate.
+	Plate string
+}
+
+// QueryEventSnapshots returns the snapshots before the query
+// time, newest first, or after the time if Reverse is set.
+func QueryEventSnapshots(snapshotsDir string, q EventSnapshotQuery) ([]EventSnapshot, error) {
+	entries, err := os.ReadDir(snapshotsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// The entries are sorted by name, IDs start with the time.
+	var ids []string
+	for _, entry := range entries {
+		id, isSnapshot := strings.CutSuffix(entry.Name(), eventSnapshotExt)
+		if !isSnapshot || !eventIDRegex.MatchString(id) {
+			continue
+		}
+		if len(q.Monitors) != 0 && !containsString(q.Monitors, eventMonitorID(id)) {
+			continue
+		}
+		if q.Time != "" {
+			if q.Reverse && id <= q.Time {
+				continue
+			}
+			if !q.Reverse && id >= q.Time {
+				continue
+			}
+		}
+		ids = append(ids, id)
+	}
+	if !q.Reverse {
+		sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	}
+
+	plate := NormalizePlate(q.Plate)
+	snapshots := []EventSnapshot{}
+	for _, id := range ids {
+		if len(snapshots) >= q.Limit {
+			break
+		}
+		eventTime, _ := time.ParseInLocation(eventIDLayout, id[:len(eventIDLayout)], time.Local)
+		snapshot := EventSnapshot{
+			ID:         id,
+			MonitorID:  eventMonitorID(id),
+			Time:       eventTime,
+			Detections: []storage.Detection{},
+		}
+		event, err := readSnapshotEvent(snapshotsDir, id)
+		if err == nil {
+			snapshot.Time = event.Time
+			if event.Detections != nil {
+				snapshot.Detections = event.Detections
+			}
+		}
+		if plate != "" && !detectionsContainPlate(snapshot.Detections, plate) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+func readSnapshotEvent(snapshotsDir string, id string) (*storage.Event, error) {
+	raw, err := os.ReadFile(filepath.Join(snapshotsDir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var event storage.Event
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// AddEventSnapshotDetections adds detections to the saved event of a
+// snapshot, for example license plates recognized by an addon. The
+// file is replaced atomically so queries never read a partial event.
+func AddEventSnapshotDetections(snapshotsDir string, id string, detections []storage.Detection) error {
+	path, err := EventSnapshotPath(snapshotsDir, id)
+	if err != nil {
+		return err
+	}
+	event, err := readSnapshotEvent(snapshotsDir, id)
+	if err != nil {
+		return fmt.Errorf("read event: %w", err)
+	}
+	event.Detections = append(event.Detections, detections...)
+
+	rawEvent, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".json.tmp"
+	if err := os.WriteFile(tmpPath, rawEvent, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path+".json")
+}
+
+// NormalizePlate returns the plate number in upper case
+// without spaces, dashes and other separators. "ab-12 3" = "AB123".
+func NormalizePlate(plate string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(plate) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// detectionsContainPlate returns true if the
+// normalized text of a detection contains plate.
+func detectionsContainPlate(detections []storage.Detection, plate string) bool {
+	for _, d := range detections {
+		if d.Text != "" && strings.Contains(NormalizePlate(d.Text), plate) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventMonitorID returns the monitor ID of a valid event ID.
+func eventMonitorID(id string) string {
+	return id[len(eventIDLayout)+1:]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
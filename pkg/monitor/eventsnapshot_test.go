@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSnapshotPath(t *testing.T) {
+	path, err := EventSnapshotPath("/snapshots", "2000-01-02_03-04-05.006_m1")
+	require.NoError(t, err)
+	require.Equal(t, "/snapshots/2000-01-02_03-04-05.006_m1", path)
+
+	_, err = EventSnapshotPath("/snapshots", "2000-01-02_03-04-05.006_../m1")
+	require.ErrorIs(t, err, ErrInvalidEventID)
+}
+
+func TestQueryEventSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	write := func(monitorID string, sec int, label string) string {
+		event := storage.Event{
+			Time:       time.Date(2000, 1, 1, 0, 0, sec, 0, time.Local),
+			Detections: []storage.Detection{{Label: label}},
+		}
+		id := EventID(monitorID, event.Time)
+		require.NoError(t, writeEventSnapshot(filepath.Join(dir, id), []byte("jpeg"), event))
+		return id
+	}
+	id1 := write("m1", 1, "a")
+	id2 := write("m2", 2, "b")
+	id3 := write("m1", 3, "c")
+
+	// Incomplete image.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "x.jpeg.tmp"), nil, 0o600))
+
+	ids := func(snapshots []EventSnapshot) []string {
+		var ids []string
+		for _, s := range snapshots {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	t.Run("newest", func(t *testing.T) {
+		snapshots, err := QueryEventSnapshots(dir, EventSnapshotQuery{Limit: 2})
+		require.NoError(t, err)
+		require.Equal(t, []string{id3, id2}, ids(snapshots))
+		require.Equal(t, "m1", snapshots[0].MonitorID)
+		require.True(t, snapshots[0].Time.Equal(time.Date(2000, 1, 1, 0, 0, 3, 0, time.Local)))
+		require.Equal(t, "c", snapshots[0].Detections[0].Label)
+	})
+	t.Run("before", func(t *testing.T) {
+		snapshots, err := QueryEventSnapshots(dir, EventSnapshotQuery{Time: id2, Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, []string{id1}, ids(snapshots))
+	})
+	t.Run("reverse", func(t *testing.T) {
+		snapshots, err := QueryEventSnapshots(dir, EventSnapshotQuery{
+			Time: id1, Limit: 10, Reverse: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{id2, id3}, ids(snapshots))
+	})
+	t.Run("monitors", func(t *testing.T) {
+		snapshots, err := QueryEventSnapshots(dir, EventSnapshotQuery{
+			Limit: 10, Monitors: []string{"m1"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{id3, id1}, ids(snapshots))
+	})
+	t.Run("plate", func(t *testing.T) {
+		err := AddEventSnapshotDetections(dir, id1, []storage.Detection{
+			{Label: "plate", Score: 90, Text: "ABC 123"},
+		})
+		require.NoError(t, err)
+
+		snapshots, err := QueryEventSnapshots(dir, EventSnapshotQuery{
+			Limit: 10, Plate: "c-12",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{id1}, ids(snapshots))
+		require.Equal(t, []storage.Detection{
+			{Label: "a"},
+			{Label: "plate", Score: 90, Text: "ABC 123"},
+		}, snapshots[0].Detections)
+
+		snapshots, err = QueryEventSnapshots(dir, EventSnapshotQuery{
+			Limit: 10, Plate: "XYZ",
+		})
+		require.NoError(t, err)
+		require.Empty(t, snapshots)
+	})
+	t.Run("missingEvent", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(dir, id3+".json")))
+		snapshots, err := QueryEventSnapshots(dir, EventSnapshotQuery{Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, []string{id3}, ids(snapshots))
+		require.True(t, snapshots[0].Time.Equal(time.Date(2000, 1, 1, 0, 0, 3, 0, time.Local)))
+		require.Empty(t, snapshots[0].Detections)
+	})
+	t.Run("missingDir", func(t *testing.T) {
+		snapshots, err := QueryEventSnapshots(filepath.Join(dir, "x"), EventSnapshotQuery{Limit: 1})
+		require.NoError(t, err)
+		require.Empty(t, snapshots)
+	})
+}
+
+func TestAddEventSnapshotDetections(t *testing.T) {
+	dir := t.TempDir()
+	err := AddEventSnapshotDetections(dir, "2000-01-01_00-00-00.000_m1", nil)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = AddEventSnapshotDetections(dir, "../x", nil)
+	require.ErrorIs(t, err, ErrInvalidEventID)
+}
+
+func TestNormalizePlate(t *testing.T) {
+	require.Equal(t, "AB123", NormalizePlate("ab-12 3"))
+	require.Equal(t, "ÖÄ1", NormalizePlate(" öä·1 "))
+	require.Empty(t, NormalizePlate(" - "))
+}
+
+func TestPruneEventSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.jpeg")
+	newPath := filepath.Join(dir, "new.jpeg")
+	require.NoError(t, os.WriteFile(oldPath, nil, 0o600))
+	require.NoError(t, os.WriteFile(newPath, nil, 0o600))
+	old := time.Now().Add(-2 * eventSnapshotMaxAge)
+	require.NoError(t, os.Chtimes(oldPath, old, old))
+
+	require.NoError(t, pruneEventSnapshots(dir, time.Now().Add(-eventSnapshotMaxAge)))
+	require.NoFileExists(t, oldPath)
+	require.FileExists(t, newPath)
+}
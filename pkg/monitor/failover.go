@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/log"
+	"sync"
+	"time"
+)
+
+// The main input process switches to the backup input if the main
+// input has been down for the failover delay. The main input is
+// probed while the backup is used and the process switches back
+// as soon as the main input is available again.
+const (
+	defaultFailoverDelay = 30 * time.Second
+	maxFailoverDelay     = 1 * time.Hour
+
+	// Interval between probes of the main input while the backup is used.
+	failbackProbeInterval = 30 * time.Second
+)
+
+// Monitor states of input failover.
+const (
+	MonitorFailover = "failover"
+	MonitorFailback = "failback"
+)
+
+// BackupInput returns the backup url of the main input.
+func (c Config) BackupInput() string {
+	return c.v["backupInput"]
+}
+
+// failoverDelay returns the time the main input has to be
+// down before the backup input is used. Value is in seconds.
+func (c Config) failoverDelay() time.Duration {
+	return parseSeconds(c.v["failoverDelay"], defaultFailoverDelay, maxFailoverDelay)
+}
+
+// inputFailover failover state of the main input process.
+type inputFailover struct {
+	onBackup bool
+
+	// Time the main input was started or finalized its last segment.
+	mainUp time.Time
+
+	mu sync.Mutex
+}
+
+func (f *inputFailover) isOnBackup() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.onBackup
+}
+
+// mainDownSince returns the time the main input went down.
+// lastSegment is the last segment of the input process.
+func (f *inputFailover) mainDownSince(lastSegment time.Time) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lastSegment.After(f.mainUp) {
+		f.mainUp = lastSegment
+	}
+	return f.mainUp
+}
+
+func (f *inputFailover) setBackup(onBackup bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onBackup = onBackup
+	if !onBackup {
+		f.mainUp = time.Now()
+	}
+}
+
+// canFailover returns true if the input has a backup.
+// Sub inputs and virtual monitors don't have a backup.
+func (i *InputProcess) canFailover() bool {
+	return !i.isSubInput && !i.Config.IsVirtual() && i.Config.BackupInput() != ""
+}
+
+// checkFailover switches to the backup input if the main input has been down
+// for the failover delay. Returns true if the process switched to the backup.
+func (i *InputProcess) checkFailover(ctx context.Context) bool {
+	if !i.canFailover() || i.failover.isOnBackup() {
+		return false
+	}
+	downSince := i.failover.mainDownSince(i.health.get().LastSegment)
+	delay := i.Config.failoverDelay()
+	if time.Since(downSince) < delay {
+		return false
+	}
+
+	i.failover.setBackup(true)
+	i.health.update(func(health *InputHealth) {
+		health.Backup = true
+	})
+	i.logf(log.LevelWarning,
+		"%v process: main input has been down for %v, switching to backup input",
+		i.ProcessName(), delay)
+	if i.sendState != nil {
+		i.sendState(MonitorFailover)
+	}
+
+	i.WG.Add(1)
+	go func() {
+		i.watchForFailback(ctx)
+		i.WG.Done()
+	}()
+	return true
+}
+
+// watchForFailback probes the main input until it's available
+// and restarts the process with the main input. Blocks until
+// ctx is canceled or the process switched back.
+func (i *InputProcess) watchForFailback(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(i.failbackInterval):
+		}
+
+		if !i.probeMainInput(ctx) {
+			continue
+		}
+
+		i.failover.setBackup(false)
+		i.health.update(func(health *InputHealth) {
+			health.Backup = false
+		})
+		i.logf(log.LevelInfo,
+			"%v process: main input is available, switching back from backup input",
+			i.ProcessName())
+		if i.sendState != nil {
+			i.sendState(MonitorFailback)
+		}
+		if i.cancel != nil {
+			i.cancel()
+		}
+		return
+	}
+}
+
+// probeMainInput returns true if the main input has a video stream.
+func (i *InputProcess) probeMainInput(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, streamTestTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-nostdin"}
+	if i.Config.InputOpts() != "" {
+		args = append(args, ffmpeg.ParseArgs(i.Config.InputOpts())...)
+	}
+	// Without a output, FFmpeg exits after printing the streams.
+	args = append(args, "-i", i.Config.MainInput())
+
+	stderr, _ := i.probe(ctx, i.Env.FFmpegBin, args)
+	if ctx.Err() != nil {
+		return false
+	}
+	return parseStreamTest(stderr).Video != nil
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFailoverInput(failoverDelay string) *InputProcess {
+	i := newTestInputProcess()
+	i.Config = NewConfig(RawConfig{
+		"id":            "test",
+		"mainInput":     "rtsp://main",
+		"backupInput":   "rtsp://backup",
+		"failoverDelay": failoverDelay,
+	})
+	i.health = newInputHealth(nil)
+	i.failover.setBackup(false)
+	return i
+}
+
+func TestFailoverDelay(t *testing.T) {
+	require.Equal(t, defaultFailoverDelay, NewConfig(RawConfig{}).failoverDelay())
+	require.Equal(t, 5*time.Second,
+		NewConfig(RawConfig{"failoverDelay": "5"}).failoverDelay())
+	require.Equal(t, maxFailoverDelay,
+		NewConfig(RawConfig{"failoverDelay": "999999"}).failoverDelay())
+}
+
+func TestCheckFailover(t *testing.T) {
+	t.Run("failover", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		states := make(chan string, 2)
+		probes := make(chan []string)
+
+		i := newTestFailoverInput("0")
+		i.failbackInterval = time.Millisecond
+		i.sendState = func(state string) { states <- state }
+		i.probe = func(_ context.Context, _ string, args []string) (string, error) {
+			probes <- args
+			return "Stream #0:0: Video: h264 (Main), yuv420p, 1920x1080, 25 fps", nil
+		}
+		require.Equal(t, "rtsp://main", i.input())
+
+		require.True(t, i.checkFailover(ctx))
+		require.Equal(t, MonitorFailover, <-states)
+		require.Equal(t, "rtsp://backup", i.input())
+		require.True(t, i.health.get().Backup)
+
+		// Already on backup.
+		require.False(t, i.checkFailover(ctx))
+
+		require.Equal(t,
+			[]string{"-hide_banner", "-nostdin", "-i", "rtsp://main"},
+			<-probes)
+		require.Equal(t, MonitorFailback, <-states)
+		require.Equal(t, "rtsp://main", i.input())
+		require.False(t, i.health.get().Backup)
+		i.WG.Wait()
+	})
+	t.Run("mainUnavailable", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		probes := make(chan struct{}, 2)
+		i := newTestFailoverInput("0")
+		i.failbackInterval = time.Millisecond
+		i.probe = func(context.Context, string, []string) (string, error) {
+			select {
+			case probes <- struct{}{}:
+			default:
+			}
+			return "rtsp://main: Connection refused", nil
+		}
+		require.True(t, i.checkFailover(ctx))
+		<-probes
+		<-probes
+
+		cancel()
+		i.WG.Wait()
+		require.Equal(t, "rtsp://backup", i.input())
+	})
+	t.Run("delay", func(t *testing.T) {
+		i := newTestFailoverInput("60")
+		require.False(t, i.checkFailover(context.Background()))
+		require.Equal(t, "rtsp://main", i.input())
+	})
+	t.Run("noBackup", func(t *testing.T) {
+		i := newTestFailoverInput("0")
+		i.Config.v["backupInput"] = ""
+		require.False(t, i.checkFailover(context.Background()))
+	})
+	t.Run("subInput", func(t *testing.T) {
+		i := newTestFailoverInput("0")
+		i.isSubInput = true
+		require.False(t, i.checkFailover(context.Background()))
+	})
+}
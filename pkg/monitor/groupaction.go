@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Group actions.
+const (
+	ActionStart   = "start"
+	ActionStop    = "stop"
+	ActionRestart = "restart"
+	ActionArm     = "arm"
+	ActionDisarm  = "disarm"
+	ActionAuto    = "auto"
+)
+
+// Group action errors.
+var (
+	ErrInvalidAction   = errors.New("invalid action")
+	ErrMonitorDisabled = errors.New("monitor is disabled")
+	ErrNotRunning      = errors.New("monitor is not running")
+)
+
+// GroupActionResult is the result of a group action for a single
+// monitor. Error is empty if the action was applied.
+type GroupActionResult struct {
+	MonitorID string `json:"monitorId"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GroupAction applies the action to every monitor while holding the
+// manager lock, other changes can't interleave with the action. The
+// action isn't applied to any monitor if a monitor doesn't exist.
+// Failures of individual monitors are reported in the results.
+// Start and stop don't change the "enable" setting of the monitors,
+// the monitors are restored when the app restarts. Duration only
+// applies to the arm actions, a zero duration lasts until changed.
+func (m *Manager) GroupAction(
+	ids []string,
+	action string,
+	duration time.Duration,
+) ([]GroupActionResult, error) {
+	var apply func(id string) error
+	switch action {
+	case ActionStart:
+		apply = m.unsafeGroupStart
+	case ActionStop:
+		apply = m.unsafeGroupStop
+	case ActionRestart:
+		apply = m.unsafeGroupRestart
+	case ActionArm, ActionDisarm, ActionAuto:
+		var until time.Time
+		if duration > 0 {
+			until = time.Now().Add(duration)
+		}
+		apply = func(id string) error {
+			m.armOverrides.set(id, action, until)
+			return nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAction, action)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		if _, exist := m.rawConfigs[id]; !exist {
+			return nil, fmt.Errorf("%w: %v", ErrNotExist, id)
+		}
+	}
+
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+
+	results := make([]GroupActionResult, 0, len(sorted))
+	for _, id := range sorted {
+		result := GroupActionResult{MonitorID: id}
+		if err := apply(id); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (m *Manager) unsafeGroupStart(id string) error {
+	if !NewConfig(m.rawConfigs[id]).enabled() {
+		return ErrMonitorDisabled
+	}
+	if _, running := m.runningMonitors[id]; running {
+		return ErrRunning
+	}
+	m.unsafeStartMonitor(id)
+	return nil
+}
+
+func (m *Manager) unsafeGroupStop(id string) error {
+	if _, running := m.runningMonitors[id]; !running {
+		return ErrNotRunning
+	}
+	m.unsafeStopMonitor(id)
+	return nil
+}
+
+func (m *Manager) unsafeGroupRestart(id string) error {
+	if !NewConfig(m.rawConfigs[id]).enabled() {
+		return ErrMonitorDisabled
+	}
+	if _, running := m.runningMonitors[id]; running {
+		m.unsafeStopMonitor(id)
+	}
+	m.unsafeStartMonitor(id)
+	return nil
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupAction(t *testing.T) {
+	t.Run("arm", func(t *testing.T) {
+		_, manager := newTestManager(t)
+		results, err := manager.GroupAction([]string{"2", "1"}, ActionDisarm, time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, []GroupActionResult{{MonitorID: "1"}, {MonitorID: "2"}}, results)
+
+		for _, id := range []string{"1", "2"} {
+			state, until := manager.armOverrides.get(id, time.Now())
+			require.Equal(t, ArmDisarm, state)
+			require.False(t, until.IsZero())
+		}
+	})
+	t.Run("partialFailure", func(t *testing.T) {
+		_, manager := newTestManager(t)
+		manager.rawConfigs["1"]["enable"] = "true"
+		manager.runningMonitors["1"] = &Monitor{}
+
+		results, err := manager.GroupAction([]string{"1", "2"}, ActionStart, 0)
+		require.NoError(t, err)
+		expected := []GroupActionResult{
+			{MonitorID: "1", Error: ErrRunning.Error()},
+			{MonitorID: "2", Error: ErrMonitorDisabled.Error()},
+		}
+		require.Equal(t, expected, results)
+
+		results, err = manager.GroupAction([]string{"1", "2"}, ActionStop, 0)
+		require.NoError(t, err)
+		expected = []GroupActionResult{
+			{MonitorID: "1"},
+			{MonitorID: "2", Error: ErrNotRunning.Error()},
+		}
+		require.Equal(t, expected, results)
+		require.Nil(t, manager.runningMonitors["1"])
+	})
+	t.Run("notExistErr", func(t *testing.T) {
+		_, manager := newTestManager(t)
+		_, err := manager.GroupAction([]string{"1", "x"}, ActionArm, 0)
+		require.ErrorIs(t, err, ErrNotExist)
+
+		// Nothing should be applied.
+		state, _ := manager.armOverrides.get("1", time.Now())
+		require.Equal(t, ArmAuto, state)
+	})
+	t.Run("invalidActionErr", func(t *testing.T) {
+		_, manager := newTestManager(t)
+		_, err := manager.GroupAction([]string{"1"}, "x", 0)
+		require.ErrorIs(t, err, ErrInvalidAction)
+	})
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"sync"
+	"time"
+)
+
+// HealthState state of a input process.
+type HealthState string
+
+// Health states.
+const (
+	HealthStarting HealthState = "starting"
+	HealthOK       HealthState = "healthy"
+	HealthStalled  HealthState = "stalled"
+	HealthCrashed  HealthState = "crashed"
+	HealthStopped  HealthState = "stopped"
+)
+
+// InputHealth health of a single input process.
+type InputHealth struct {
+	State       HealthState `json:"state"`
+	LastSegment time.Time   `json:"lastSegment"`
+	Restarts    int         `json:"restarts"`
+	LastError   string      `json:"lastError,omitempty"`
+	NextRestart *time.Time  `json:"nextRestart,omitempty"`
+
+	// Set if the main input failed over to the backup input.
+	Backup bool `json:"backup,omitempty"`
+
+	// Parsed from the FFmpeg output. The error class is set if the
+	// last crash was caused by a known error, for example "auth".
+	LastErrorClass string  `json:"lastErrorClass,omitempty"`
+	FPS            float64 `json:"fps,omitempty"`
+	CorruptFrames  int     `json:"corruptFrames,omitempty"` // Since the process started.
+}
+
+// Health of a monitor.
+type Health struct {
+	Main InputHealth  `json:"main"`
+	Sub  *InputHealth `json:"sub,omitempty"`
+
+	// Set if the monitor is in maintenance.
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+}
+
+type inputHealth struct {
+	health   InputHealth
+	onChange func(InputHealth)
+	mu       sync.Mutex
+}
+
+func newInputHealth(onChange func(InputHealth)) *inputHealth {
+	return &inputHealth{
+		health:   InputHealth{State: HealthStopped},
+		onChange: onChange,
+	}
+}
+
+func (h *inputHealth) get() InputHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.health
+}
+
+// update modifies the health and calls onChange if the state changed.
+func (h *inputHealth) update(fn func(*InputHealth)) {
+	h.mu.Lock()
+	prevState := h.health.State
+	fn(&h.health)
+	health := h.health
+	h.mu.Unlock()
+
+	if health.State != prevState && h.onChange != nil {
+		h.onChange(health)
+	}
+}
+
+func (h *inputHealth) starting() {
+	h.update(func(health *InputHealth) {
+		health.State = HealthStarting
+		health.NextRestart = nil
+		health.FPS = 0
+		health.CorruptFrames = 0
+	})
+}
+
+func (h *inputHealth) segmentFinalized() {
+	h.update(func(health *InputHealth) {
+		health.State = HealthOK
+		health.LastSegment = time.Now()
+	})
+}
+
+func (h *inputHealth) stalled() {
+	h.update(func(health *InputHealth) {
+		health.State = HealthStalled
+	})
+}
+
+func (h *inputHealth) crashed(err error, nextRestart time.Time) {
+	h.update(func(health *InputHealth) {
+		if health.State != HealthStalled {
+			health.State = HealthCrashed
+		}
+		if err != nil {
+			health.LastError = err.Error()
+			health.LastErrorClass = ""
+			var ffmpegErr ffmpegError
+			if errors.As(err, &ffmpegErr) {
+				health.LastErrorClass = ffmpegErr.class
+			}
+		}
+		health.Restarts++
+		health.NextRestart = &nextRestart
+	})
+}
+
+func (h *inputHealth) stopped() {
+	h.update(func(health *InputHealth) {
+		health.State = HealthStopped
+		health.NextRestart = nil
+	})
+}
+
+// wasHealthySince returns true if a segment was finalized after t.
+func (h *inputHealth) wasHealthySince(t time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.health.LastSegment.After(t)
+}
+
+// Exponential backoff between input process restarts.
+const (
+	minRestartDelay = 1 * time.Second
+	maxRestartDelay = 1 * time.Minute
+)
+
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newBackoff() *backoff {
+	return &backoff{min: minRestartDelay, max: maxRestartDelay}
+}
+
+// next returns the next delay, doubling it each time until max is reached.
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+		return b.current
+	}
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+	return b.current
+}
+
+func (b *backoff) reset() {
+	b.current = 0
+}
+
+// Default time without new segments before the input is considered stalled.
+const defaultStallTimeout = 15 * time.Second
+
+// ErrInputStalled no new segments were finalized within the stall timeout.
+var ErrInputStalled = errors.New("input stalled")
+
+// watchForStall calls onStall if no new HLS segments are
+// finalized within the stall timeout. Blocks until ctx is
+// canceled or a stall is detected.
+func (i *InputProcess) watchForStall(ctx context.Context, onStall func()) {
+	timeout := i.Config.stallTimeout()
+
+	segFinalized := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-time.After(1 * time.Second):
+			case <-ctx.Done():
+				return
+			}
+
+			muxer, err := i.HLSMuxer(ctx)
+			if err != nil {
+				continue
+			}
+
+			muxer.WaitForSegFinalized()
+			select {
+			case <-ctx.Done():
+				return
+			case segFinalized <- struct{}{}:
+			}
+		}
+	}()
+
+	// The first segment takes longer while the input is starting.
+	deadline := time.After(2 * timeout)
+	for {
+		select {
+		case <-segFinalized:
+			i.health.segmentFinalized()
+			deadline = time.After(timeout)
+		case <-deadline:
+			i.health.stalled()
+			msg := fmt.Sprintf("no new segments for %v, restarting", timeout)
+			if i.supervisor != nil {
+				if err := i.supervisor.lastError(); err != nil {
+					msg += ", last error: " + err.Error()
+				}
+			}
+			i.logf(log.LevelError, "%v process: %v", i.ProcessName(), msg)
+			onStall()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// MonitorHealth returns the health of all running monitors.
+func (m *Manager) MonitorHealth() map[string]Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := make(map[string]Health)
+	for id, monitor := range m.runningMonitors {
+		health[id] = monitor.Health()
+	}
+	return health
+}
+
+// Health returns the health of the monitor inputs.
+func (m *Monitor) Health() Health {
+	var health Health
+	if m.mainInput != nil && m.mainInput.health != nil {
+		health.Main = m.mainInput.health.get()
+	}
+	if m.Config.SubInputEnabled() && m.subInput != nil && m.subInput.health != nil {
+		sub := m.subInput.health.get()
+		health.Sub = &sub
+	}
+	if m.maintenance != nil {
+		if maintenance, active := m.maintenance.get(m.Config.ID(), time.Now()); active {
+			health.Maintenance = &maintenance
+		}
+	}
+	return health
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff()
+	require.Equal(t, 1*time.Second, b.next())
+	require.Equal(t, 2*time.Second, b.next())
+	require.Equal(t, 4*time.Second, b.next())
+
+	for i := 0; i < 10; i++ {
+		b.next()
+	}
+	require.Equal(t, maxRestartDelay, b.next())
+
+	b.reset()
+	require.Equal(t, 1*time.Second, b.next())
+}
+
+func TestInputHealth(t *testing.T) {
+	t.Run("stateChanges", func(t *testing.T) {
+		var states []HealthState
+		h := newInputHealth(func(health InputHealth) {
+			states = append(states, health.State)
+		})
+
+		h.starting()
+		h.segmentFinalized()
+		h.segmentFinalized()
+		h.stalled()
+		h.crashed(errors.New("stub"), time.Time{})
+		h.starting()
+		h.stopped()
+
+		expected := []HealthState{
+			HealthStarting,
+			HealthOK,
+			HealthStalled,
+			HealthStarting,
+			HealthStopped,
+		}
+		require.Equal(t, expected, states)
+	})
+	t.Run("crashed", func(t *testing.T) {
+		h := newInputHealth(nil)
+		nextRestart := time.Unix(1, 0)
+
+		h.starting()
+		h.crashed(errors.New("stub"), nextRestart)
+
+		health := h.get()
+		require.Equal(t, HealthCrashed, health.State)
+		require.Equal(t, "stub", health.LastError)
+		require.Equal(t, 1, health.Restarts)
+		require.Equal(t, &nextRestart, health.NextRestart)
+
+		h.starting()
+		require.Nil(t, h.get().NextRestart)
+	})
+	t.Run("wasHealthySince", func(t *testing.T) {
+		h := newInputHealth(nil)
+		start := time.Now()
+		require.False(t, h.wasHealthySince(start))
+
+		h.segmentFinalized()
+		require.True(t, h.wasHealthySince(start))
+	})
+}
+
+func TestStallTimeout(t *testing.T) {
+	c := NewConfig(RawConfig{})
+	require.Equal(t, defaultStallTimeout, c.stallTimeout())
+
+	c = NewConfig(RawConfig{"stallTimeout": "30"})
+	require.Equal(t, 30*time.Second, c.stallTimeout())
+
+	c = NewConfig(RawConfig{"stallTimeout": "x"})
+	require.Equal(t, defaultStallTimeout, c.stallTimeout())
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Maintenance is planned work on a camera. Unlike a disabled monitor,
+// the monitor keeps running, but down alerts are suppressed and the
+// recordings and gaps are marked so the work is visible in the history.
+type Maintenance struct {
+	Reason string    `json:"reason"`
+	Start  time.Time `json:"start"`
+
+	// Zero if the maintenance lasts until it's cleared.
+	Until time.Time `json:"until"`
+}
+
+// MaintenancePeriod is a finished maintenance.
+type MaintenancePeriod struct {
+	MonitorID string    `json:"monitorId"`
+	Reason    string    `json:"reason"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// Number of finished periods that are kept.
+const maxMaintenanceHistory = 1000
+
+// Maximum length of the reason in bytes.
+const maxMaintenanceReason = 256
+
+type maintenanceState struct {
+	Active  map[string]Maintenance `json:"active"`
+	History []MaintenancePeriod    `json:"history"`
+}
+
+// maintenanceStore stores the active maintenance of the monitors and the
+// history of finished periods. The state is saved to a file so it's kept
+// between restarts, an empty path keeps it in memory only.
+type maintenanceStore struct {
+	path   string
+	logger log.ILogger
+	state  maintenanceState
+	mu     sync.Mutex
+}
+
+func newMaintenanceStore(path string, logger log.ILogger) (*maintenanceStore, error) {
+	s := &maintenanceStore{
+		path:   path,
+		logger: logger,
+		state:  maintenanceState{Active: make(map[string]Maintenance)},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read maintenance file: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.state); err != nil {
+		return nil, fmt.Errorf("unmarshal maintenance file: %w", err)
+	}
+	if s.state.Active == nil {
+		s.state.Active = make(map[string]Maintenance)
+	}
+	return s, nil
+}
+
+// set starts or updates the maintenance of a monitor, the
+// start time of an active maintenance is kept.
+func (s *maintenanceStore) set(monitorID string, m Maintenance, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireUnsafe(now)
+	if prev, exist := s.state.Active[monitorID]; exist {
+		m.Start = prev.Start
+	}
+	s.state.Active[monitorID] = m
+	return s.saveUnsafe()
+}
+
+// clear ends the maintenance of a monitor.
+func (s *maintenanceStore) clear(monitorID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireUnsafe(now)
+	m, exist := s.state.Active[monitorID]
+	if !exist {
+		return nil
+	}
+	s.endUnsafe(monitorID, m, now)
+	return s.saveUnsafe()
+}
+
+// get returns the active maintenance of a monitor.
+func (s *maintenanceStore) get(monitorID string, now time.Time) (Maintenance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expireUnsafe(now) {
+		s.saveAndLogUnsafe()
+	}
+	m, exist := s.state.Active[monitorID]
+	return m, exist
+}
+
+// during returns the reason of the first maintenance of
+// the monitor that overlaps the time range.
+func (s *maintenanceStore) during(monitorID string, start time.Time, end time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expireUnsafe(time.Now()) {
+		s.saveAndLogUnsafe()
+	}
+	if m, exist := s.state.Active[monitorID]; exist && m.Start.Before(end) {
+		return m.Reason, true
+	}
+	for _, p := range s.state.History {
+		if p.MonitorID == monitorID && p.Start.Before(end) && p.End.After(start) {
+			return p.Reason, true
+		}
+	}
+	return "", false
+}
+
+// history returns the finished periods of a monitor, newest first.
+func (s *maintenanceStore) history(monitorID string, now time.Time) []MaintenancePeriod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expireUnsafe(now) {
+		s.saveAndLogUnsafe()
+	}
+	periods := []MaintenancePeriod{}
+	for i := len(s.state.History) - 1; i >= 0; i-- {
+		if s.state.History[i].MonitorID == monitorID {
+			periods = append(periods, s.state.History[i])
+		}
+	}
+	return periods
+}
+
+// expireUnsafe ends the maintenance that expired before now.
+func (s *maintenanceStore) expireUnsafe(now time.Time) bool {
+	expired := false
+	for monitorID, m := range s.state.Active {
+		if !m.Until.IsZero() && !now.Before(m.Until) {
+			s.endUnsafe(monitorID, m, m.Until)
+			expired = true
+		}
+	}
+	return expired
+}
+
+func (s *maintenanceStore) endUnsafe(monitorID string, m Maintenance, end time.Time) {
+	delete(s.state.Active, monitorID)
+	s.state.History = append(s.state.History, MaintenancePeriod{
+		MonitorID: monitorID,
+		Reason:    m.Reason,
+		Start:     m.Start,
+		End:       end,
+	})
+	if len(s.state.History) > maxMaintenanceHistory {
+		s.state.History = s.state.History[len(s.state.History)-maxMaintenanceHistory:]
+	}
+}
+
+func (s *maintenanceStore) saveUnsafe() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(s.state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshal maintenance: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create maintenance directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, raw, 0o600); err != nil {
+		return fmt.Errorf("write maintenance file: %w", err)
+	}
+	return nil
+}
+
+// saveAndLogUnsafe saves the state after an expiry, the error
+// is logged since it's not caused by the caller.
+func (s *maintenanceStore) saveAndLogUnsafe() {
+	if err := s.saveUnsafe(); err != nil {
+		s.logger.Log(log.Entry{
+			Level: log.LevelError,
+			Src:   "monitor",
+			Msg:   fmt.Sprintf("save maintenance: %v", err),
+		})
+	}
+}
+
+// InMaintenance returns true if the monitor is in maintenance.
+func (m *Monitor) InMaintenance(now time.Time) bool {
+	if m.maintenance == nil {
+		return false
+	}
+	_, active := m.maintenance.get(m.Config.ID(), now)
+	return active
+}
+
+// InMaintenance returns true if the monitor is in maintenance.
+// Event and alert hooks are not called during maintenance.
+func (r *Recorder) InMaintenance(now time.Time) bool {
+	if r.maintenance == nil {
+		return false
+	}
+	_, active := r.maintenance.get(r.Config.ID(), now)
+	return active
+}
+
+// MaintenanceInfo is the active maintenance and history of a monitor.
+type MaintenanceInfo struct {
+	// Nil if the monitor isn't in maintenance.
+	Active  *Maintenance        `json:"active"`
+	History []MaintenancePeriod `json:"history"`
+}
+
+// Maintenance returns the active maintenance and the history of a monitor.
+func (m *Manager) Maintenance(id string) (MaintenanceInfo, error) {
+	m.mu.Lock()
+	_, exist := m.rawConfigs[id]
+	m.mu.Unlock()
+	if !exist {
+		return MaintenanceInfo{}, ErrNotExist
+	}
+
+	now := time.Now()
+	info := MaintenanceInfo{History: m.maintenance.history(id, now)}
+	if active, exist := m.maintenance.get(id, now); exist {
+		info.Active = &active
+	}
+	return info, nil
+}
+
+// ErrMaintenanceReasonTooLong reason is too long.
+var ErrMaintenanceReasonTooLong = errors.New("maintenance reason is too long")
+
+// SetMaintenance puts the monitor in maintenance. A zero duration
+// lasts until cleared. Setting it again updates the reason and expiry.
+func (m *Manager) SetMaintenance(id string, reason string, duration time.Duration) error {
+	if len(reason) > maxMaintenanceReason {
+		return fmt.Errorf("%w: max %v bytes", ErrMaintenanceReasonTooLong, maxMaintenanceReason)
+	}
+
+	m.mu.Lock()
+	_, exist := m.rawConfigs[id]
+	m.mu.Unlock()
+	if !exist {
+		return ErrNotExist
+	}
+
+	now := time.Now()
+	maintenance := Maintenance{Reason: reason, Start: now}
+	if duration > 0 {
+		maintenance.Until = now.Add(duration)
+	}
+	return m.maintenance.set(id, maintenance, now)
+}
+
+// ClearMaintenance ends the maintenance of the monitor.
+func (m *Manager) ClearMaintenance(id string) error {
+	m.mu.Lock()
+	_, exist := m.rawConfigs[id]
+	m.mu.Unlock()
+	if !exist {
+		return ErrNotExist
+	}
+	return m.maintenance.clear(id, time.Now())
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/feed"
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceStore(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return t0.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	t.Run("setAndClear", func(t *testing.T) {
+		s, err := newMaintenanceStore("", log.NewDummyLogger())
+		require.NoError(t, err)
+
+		require.NoError(t, s.set("m1", Maintenance{Reason: "a", Start: at(0)}, at(0)))
+		m, active := s.get("m1", at(5))
+		require.True(t, active)
+		require.Equal(t, Maintenance{Reason: "a", Start: at(0)}, m)
+
+		// The start time is kept when updated.
+		require.NoError(t, s.set("m1", Maintenance{Reason: "b", Start: at(5)}, at(5)))
+		m, _ = s.get("m1", at(5))
+		require.Equal(t, Maintenance{Reason: "b", Start: at(0)}, m)
+
+		require.NoError(t, s.clear("m1", at(10)))
+		_, active = s.get("m1", at(10))
+		require.False(t, active)
+		require.Equal(t, []MaintenancePeriod{
+			{MonitorID: "m1", Reason: "b", Start: at(0), End: at(10)},
+		}, s.history("m1", at(10)))
+		require.Equal(t, []MaintenancePeriod{}, s.history("m2", at(10)))
+	})
+	t.Run("expiry", func(t *testing.T) {
+		s, err := newMaintenanceStore("", log.NewDummyLogger())
+		require.NoError(t, err)
+
+		m := Maintenance{Reason: "a", Start: at(0), Until: at(10)}
+		require.NoError(t, s.set("m1", m, at(0)))
+
+		_, active := s.get("m1", at(9))
+		require.True(t, active)
+		_, active = s.get("m1", at(10))
+		require.False(t, active)
+		require.Equal(t, []MaintenancePeriod{
+			{MonitorID: "m1", Reason: "a", Start: at(0), End: at(10)},
+		}, s.history("m1", at(20)))
+	})
+	t.Run("during", func(t *testing.T) {
+		s, err := newMaintenanceStore("", log.NewDummyLogger())
+		require.NoError(t, err)
+
+		require.NoError(t, s.set("m1", Maintenance{Reason: "a", Start: at(0)}, at(0)))
+		require.NoError(t, s.clear("m1", at(10)))
+
+		reason, during := s.during("m1", at(5), at(15))
+		require.True(t, during)
+		require.Equal(t, "a", reason)
+
+		_, during = s.during("m1", at(10), at(15))
+		require.False(t, during)
+		_, during = s.during("m2", at(5), at(15))
+		require.False(t, during)
+	})
+	t.Run("historyLimit", func(t *testing.T) {
+		s, err := newMaintenanceStore("", log.NewDummyLogger())
+		require.NoError(t, err)
+
+		for i := 0; i < maxMaintenanceHistory+1; i++ {
+			require.NoError(t, s.set("m1", Maintenance{Start: at(i)}, at(i)))
+			require.NoError(t, s.clear("m1", at(i)))
+		}
+		history := s.history("m1", at(0))
+		require.Len(t, history, maxMaintenanceHistory)
+		require.Equal(t, at(maxMaintenanceHistory), history[0].Start)
+		require.Equal(t, at(1), history[len(history)-1].Start)
+	})
+	t.Run("persistence", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "storage", "maintenance.json")
+		s, err := newMaintenanceStore(path, log.NewDummyLogger())
+		require.NoError(t, err)
+
+		require.NoError(t, s.set("m1", Maintenance{Reason: "a", Start: at(0)}, at(0)))
+		require.NoError(t, s.set("m2", Maintenance{Reason: "b", Start: at(0)}, at(0)))
+		require.NoError(t, s.clear("m2", at(1)))
+
+		s, err = newMaintenanceStore(path, log.NewDummyLogger())
+		require.NoError(t, err)
+
+		m, active := s.get("m1", at(2))
+		require.True(t, active)
+		require.Equal(t, "a", m.Reason)
+		require.Equal(t, []MaintenancePeriod{
+			{MonitorID: "m2", Reason: "b", Start: at(0), End: at(1)},
+		}, s.history("m2", at(2)))
+	})
+}
+
+func TestManagerMaintenance(t *testing.T) {
+	_, m := newTestManager(t)
+
+	require.NoError(t, m.SetMaintenance("1", "lens cleaning", time.Hour))
+
+	info, err := m.Maintenance("1")
+	require.NoError(t, err)
+	require.NotNil(t, info.Active)
+	require.Equal(t, "lens cleaning", info.Active.Reason)
+	require.WithinDuration(t, time.Now().Add(time.Hour), info.Active.Until, time.Minute)
+	require.Empty(t, info.History)
+
+	require.NoError(t, m.ClearMaintenance("1"))
+	info, err = m.Maintenance("1")
+	require.NoError(t, err)
+	require.Nil(t, info.Active)
+	require.Len(t, info.History, 1)
+
+	err = m.SetMaintenance("1", strings.Repeat("x", maxMaintenanceReason+1), 0)
+	require.ErrorIs(t, err, ErrMaintenanceReasonTooLong)
+
+	require.ErrorIs(t, m.SetMaintenance("nil", "", 0), ErrNotExist)
+	require.ErrorIs(t, m.ClearMaintenance("nil"), ErrNotExist)
+	_, err = m.Maintenance("nil")
+	require.ErrorIs(t, err, ErrNotExist)
+}
+
+func TestMaintenanceSuppression(t *testing.T) {
+	store, err := newMaintenanceStore("", log.NewDummyLogger())
+	require.NoError(t, err)
+
+	m := &Monitor{
+		Config:       NewConfig(RawConfig{"id": "m1"}),
+		maintenance:  store,
+		stateHistory: feed.NewBuffer[MonitorState](stateHistorySize),
+	}
+	i := newInputProcess(m, false)
+	states := func() []string {
+		items, _ := m.stateHistory.Since(0)
+		var states []string
+		for _, item := range items {
+			states = append(states, item.Value.State)
+		}
+		return states
+	}
+
+	require.NoError(t, store.set("m1", Maintenance{Start: time.Now()}, time.Now()))
+	i.health.stalled()
+	i.health.segmentFinalized()
+	require.Equal(t, []string{"healthy"}, states())
+	require.NotNil(t, m.Health().Maintenance)
+	r := &Recorder{Config: m.Config, maintenance: store}
+	require.True(t, r.InMaintenance(time.Now()))
+
+	require.NoError(t, store.clear("m1", time.Now()))
+	i.health.stalled()
+	require.Equal(t, []string{"healthy", "stalled"}, states())
+	require.Nil(t, m.Health().Maintenance)
+	require.False(t, r.InMaintenance(time.Now()))
+}
@@ -8,16 +8,21 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
+	"nvr/pkg/feed"
 	"nvr/pkg/ffmpeg"
 	"nvr/pkg/log"
+	"nvr/pkg/secret"
 	"nvr/pkg/storage"
 	"nvr/pkg/video"
 	"nvr/pkg/video/gortsplib"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,17 +41,24 @@ type RecSaveHook func(*Recorder, *string)
 // RecSavedHook is called after recording have been saved successfully.
 type RecSavedHook func(*Recorder, string, storage.RecordingData)
 
+// FrameHook is called for every H264 frame of the input process.
+// It's called from the video muxer and must not block.
+type FrameHook func(*InputProcess, video.H264Frame)
+
 // MigationHook is called when each monitor config is loaded.
 type MigationHook func(RawConfig) error
 
 // Hooks monitor hooks.
 type Hooks struct {
-	Start      StartHook
-	StartInput StartInputHook
-	Event      EventHook
-	RecSave    RecSaveHook
-	RecSaved   RecSavedHook
-	Migrate    MigationHook
+	Start         StartHook
+	StartInput    StartInputHook
+	Event         EventHook
+	RecSave       RecSaveHook
+	RecSaved      RecSavedHook
+	EventClip     EventClipHook
+	EventSnapshot EventSnapshotHook
+	Migrate       MigationHook
+	Frame         FrameHook
 }
 
 // Manager for the monitors.
@@ -54,20 +66,45 @@ type Manager struct {
 	rawConfigs      RawConfigs
 	runningMonitors monitors
 
-	env         storage.ConfigEnv
-	logger      log.ILogger
-	videoServer *video.Server
-	path        string
-	hooks       Hooks
-	mu          sync.Mutex
+	env          storage.ConfigEnv
+	general      *storage.ConfigGeneral
+	logger       log.ILogger
+	videoServer  *video.Server
+	transcoders  *Transcoders
+	armOverrides *armOverrides
+	maintenance  *maintenanceStore
+	volumes      *storage.Volumes
+	eventFeed    *eventFeed
+	stateHistory *feed.Buffer[MonitorState]
+	secrets      *secret.Cipher
+	credentials  *Credentials
+	privacyMasks *PrivacyMasks
+	talkbacks    *talkbacks
+	streamStats  *streamStats
+	startCancel  context.CancelFunc
+	path         string
+	hooks        Hooks
+	mu           sync.Mutex
+
+	// Limits the event clips that are generated at the same time.
+	eventClipSlots chan struct{}
+
+	dialBackchannel func(context.Context, string, *net.Dialer) (*Backchannel, error)
+
+	// Incremented when a config is set or deleted.
+	configVersion uint64
 }
 
-// NewManager return new monitor manager.
+// NewManager return new monitor manager. Secret config
+// values are stored in plain text if secrets is nil.
 func NewManager(
 	configPath string,
 	env storage.ConfigEnv,
+	general *storage.ConfigGeneral,
 	logger log.ILogger,
 	videoServer *video.Server,
+	transcoders *Transcoders,
+	secrets *secret.Cipher,
 	hooks *Hooks,
 ) (*Manager, error) {
 	if err := os.MkdirAll(configPath, 0o700); err != nil {
@@ -89,12 +126,18 @@ func NewManager(
 		if err := hooks.Migrate(rawConf); err != nil {
 			return nil, fmt.Errorf("migration failed: %w", err)
 		}
+		decryptConfig(secrets, rawConf)
 
 		id := rawConf["id"]
 		configPath := monitorConfigPath(configPath, id)
 
-		jsonConf, _ := json.MarshalIndent(rawConf, "", "    ")
-		err := os.WriteFile(configPath, jsonConf, 0o600)
+		// Plain text secrets from older versions are encrypted here.
+		encrypted, err := encryptConfig(secrets, rawConf)
+		if err != nil {
+			return nil, err
+		}
+		jsonConf, _ := json.MarshalIndent(encrypted, "", "    ")
+		err = os.WriteFile(configPath, jsonConf, 0o600)
 		if err != nil {
 			return nil, fmt.Errorf("write migrated config: %w", err)
 		}
@@ -102,15 +145,37 @@ func NewManager(
 		rawConfigs[id] = rawConf
 	}
 
+	var maintenancePath string
+	if env.StorageDir != "" {
+		maintenancePath = filepath.Join(env.StorageDir, "maintenance.json")
+	}
+	maintenance, err := newMaintenanceStore(maintenancePath, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Manager{
 		rawConfigs:      rawConfigs,
 		runningMonitors: make(monitors),
 
-		env:         env,
-		logger:      logger,
-		videoServer: videoServer,
-		path:        configPath,
-		hooks:       *hooks,
+		env:          env,
+		general:      general,
+		logger:       logger,
+		videoServer:  videoServer,
+		transcoders:  transcoders,
+		armOverrides: newArmOverrides(),
+		maintenance:  maintenance,
+		volumes:      storage.NewVolumes(env, logger),
+		eventFeed:    newEventFeed(),
+		stateHistory: feed.NewBuffer[MonitorState](stateHistorySize),
+		secrets:      secrets,
+		talkbacks:    newTalkbacks(),
+		streamStats:  newStreamStats(videoServer.Renditions),
+		path:         configPath,
+		hooks:        *hooks,
+
+		eventClipSlots:  make(chan struct{}, maxConcurrentEventClips),
+		dialBackchannel: DialBackchannel,
 	}, nil
 }
 
@@ -134,9 +199,90 @@ func readConfigs(fileSystem fs.FS) ([][]byte, error) {
 	return files, err
 }
 
-func (m *Manager) unsafeStartMonitor(id string) {
+// SetCredentials sets the credentials that monitors reference
+// with the "credential" key. Must be called before StartMonitors.
+func (m *Manager) SetCredentials(c *Credentials) {
+	m.mu.Lock()
+	m.credentials = c
+	m.mu.Unlock()
+}
+
+// SetPrivacyMasks sets the privacy masks that are applied
+// to the monitors. Must be called before StartMonitors.
+func (m *Manager) SetPrivacyMasks(p *PrivacyMasks) {
+	m.mu.Lock()
+	m.privacyMasks = p
+	m.mu.Unlock()
+}
+
+// Volumes returns the storage volume selector of the recorders.
+func (m *Manager) Volumes() *storage.Volumes {
+	return m.volumes
+}
+
+// RecordingsDir returns the recordings directory for a new recording
+// of the monitor, the storage volume is selected like the recorder does.
+func (m *Manager) RecordingsDir(id string) (string, error) {
+	m.mu.Lock()
+	rawConf, exist := m.rawConfigs[id]
+	m.mu.Unlock()
+	if !exist {
+		return "", fmt.Errorf("%w: %v", ErrMonitorNotExist, id)
+	}
+	return m.volumes.RecordingsDir(NewConfig(rawConf).StorageVolume())
+}
+
+// StorageVolume returns the pinned storage volume of the monitor.
+func (m *Manager) StorageVolume(id string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return NewConfig(m.rawConfigs[id]).StorageVolume()
+}
+
+// ResolvedConfig returns the config of the monitor with the credential applied.
+func (m *Manager) ResolvedConfig(id string) (RawConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exist := m.rawConfigs[id]; !exist {
+		return nil, ErrMonitorNotExist
+	}
+	return m.unsafeResolvedConfig(id)
+}
+
+func (m *Manager) unsafeResolvedConfig(id string) (RawConfig, error) {
 	rawConf := m.rawConfigs[id]
+	credentialID := rawConf["credential"]
+	if credentialID == "" {
+		return rawConf, nil
+	}
+	if m.credentials == nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialNotExist, credentialID)
+	}
+	cred, err := m.credentials.Get(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	return applyCredential(rawConf, cred), nil
+}
+
+func (m *Manager) unsafeStartMonitor(id string) {
+	rawConf, err := m.unsafeResolvedConfig(id)
+	if err != nil {
+		// The monitor is started without the credential, the error
+		// is logged again when the input fails to authenticate.
+		m.logger.Log(log.Entry{
+			Level:     log.LevelError,
+			Src:       "monitor",
+			MonitorID: id,
+			Msg:       fmt.Sprintf("could not apply credential: %v", err),
+		})
+		rawConf = m.rawConfigs[id]
+	}
 	monitor := m.newMonitor(NewConfig(rawConf))
+	if m.privacyMasks != nil {
+		monitor.setPrivacyZones(m.privacyMasks.Current(id))
+	}
 	monitor.start()
 	m.runningMonitors[id] = monitor
 }
@@ -146,18 +292,12 @@ func (m *Manager) unsafeStopMonitor(id string) {
 	delete(m.runningMonitors, id)
 }
 
-// StartMonitors starts all monitors.
-func (m *Manager) StartMonitors() {
-	m.mu.Lock()
-	for id := range m.rawConfigs {
-		m.unsafeStartMonitor(id)
-	}
-	m.mu.Unlock()
-}
-
-// StopMonitors stops all monitors.
+// StopMonitors stops all monitors and cancels the startup.
 func (m *Manager) StopMonitors() {
 	m.mu.Lock()
+	if m.startCancel != nil {
+		m.startCancel()
+	}
 	for id := range m.runningMonitors {
 		m.unsafeStopMonitor(id)
 	}
@@ -189,8 +329,19 @@ func (m *Manager) MonitorSet(id string, rawConf RawConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Write config to file.
-	configJSON, err := json.MarshalIndent(rawConf, "", "    ")
+	// Passwords are redacted in the configs that the API returns.
+	rawConf = unredactConfig(rawConf, m.rawConfigs[id])
+
+	return m.unsafeSaveConfig(id, rawConf)
+}
+
+// unsafeSaveConfig writes the config to file and replaces the current config.
+func (m *Manager) unsafeSaveConfig(id string, rawConf RawConfig) error {
+	encrypted, err := encryptConfig(m.secrets, rawConf)
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.MarshalIndent(encrypted, "", "    ")
 	if err != nil {
 		return fmt.Errorf("marshal config file: %w", err)
 	}
@@ -200,9 +351,44 @@ func (m *Manager) MonitorSet(id string, rawConf RawConfig) error {
 	}
 
 	m.rawConfigs[id] = rawConf
+	m.configVersion++
 	return nil
 }
 
+// SetEnabled enables or disables a monitor and restarts it. The setting
+// is saved in the config. Disabled monitors keep their config and
+// recordings but don't run any processes. Returns false if the monitor
+// already had the state, it's not restarted then.
+func (m *Manager) SetEnabled(id string, enable bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldConf, exist := m.rawConfigs[id]
+	if !exist {
+		return false, ErrMonitorNotExist
+	}
+	if NewConfig(oldConf).enabled() == enable {
+		return false, nil
+	}
+
+	rawConf := make(RawConfig, len(oldConf))
+	for key, value := range oldConf {
+		rawConf[key] = value
+	}
+	rawConf["enable"] = strconv.FormatBool(enable)
+	if err := m.unsafeSaveConfig(id, rawConf); err != nil {
+		return false, err
+	}
+
+	// Disabled monitors are kept in the running
+	// monitors so they can be deleted, see start.
+	if _, running := m.runningMonitors[id]; running {
+		m.unsafeStopMonitor(id)
+	}
+	m.unsafeStartMonitor(id)
+	return true, nil
+}
+
 // ErrNotExist monitor does not exist.
 var ErrNotExist = errors.New("monitor does not exist")
 
@@ -219,12 +405,13 @@ func (m *Manager) MonitorDelete(id string) error {
 	monitor.stop()
 	delete(m.runningMonitors, id)
 	delete(m.rawConfigs, id)
+	m.configVersion++
 
 	if err := os.Remove(m.configPath(id)); err != nil {
 		return err
 	}
 
-	return nil
+	return m.maintenance.clear(id, time.Now())
 }
 
 // MonitorsInfo returns common information about the monitors.
@@ -252,12 +439,19 @@ func (m *Manager) MonitorsInfo() RawConfigs {
 			subInputEnabled = "true"
 		}
 
+		talkbackEnabled := "false"
+		if c.TalkbackEnabled() {
+			talkbackEnabled = "true"
+		}
+
 		configs[c.ID()] = RawConfig{
 			"id":              c.ID(),
 			"name":            c.Name(),
 			"enable":          enable,
 			"audioEnabled":    audioEnabled,
 			"subInputEnabled": subInputEnabled,
+			"talkbackEnabled": talkbackEnabled,
+			"tags":            strings.Join(c.Tags(), ","),
 		}
 	}
 	return configs
@@ -271,6 +465,14 @@ func monitorConfigPath(path string, id string) string {
 	return filepath.Join(path, id+".json")
 }
 
+// ConfigVersion returns a number that changes every time a monitor
+// config is set or deleted. Used to cache encoded configs.
+func (m *Manager) ConfigVersion() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.configVersion
+}
+
 // MonitorConfigs returns configurations for all monitors.
 func (m *Manager) MonitorConfigs() RawConfigs {
 	m.mu.Lock()
@@ -294,10 +496,21 @@ type Monitor struct {
 	Env         storage.ConfigEnv
 	Logger      log.ILogger
 	videoServer *video.Server
+	transcoders *Transcoders
+
+	general      *storage.ConfigGeneral
+	armOverrides *armOverrides
+	maintenance  *maintenanceStore
+	volumes      *storage.Volumes
+	eventFeed    *eventFeed
+	stateHistory *feed.Buffer[MonitorState]
 
-	mainInput *InputProcess
-	subInput  *InputProcess
-	recorder  *Recorder
+	eventClipSlots chan struct{}
+
+	mainInput     *InputProcess
+	subInput      *InputProcess
+	recorder      *Recorder
+	snapshotCache snapshotCache
 	Recorder
 	hooks      Hooks
 	NewProcess ffmpeg.NewProcessFunc
@@ -328,6 +541,16 @@ func (m *Manager) newMonitor(config Config) *Monitor {
 		Env:         m.env,
 		Logger:      m.logger,
 		videoServer: m.videoServer,
+		transcoders: m.transcoders,
+
+		general:      m.general,
+		armOverrides: m.armOverrides,
+		maintenance:  m.maintenance,
+		volumes:      m.volumes,
+		eventFeed:    m.eventFeed,
+		stateHistory: m.stateHistory,
+
+		eventClipSlots: m.eventClipSlots,
 
 		hooks:      m.hooks,
 		NewProcess: ffmpeg.NewProcess,
@@ -349,23 +572,22 @@ func (m *Monitor) start() {
 		return
 	}
 
+	if keys := m.Config.encryptedKeys(); len(keys) != 0 {
+		m.logf(log.LevelError, "could not decrypt %v, the secret key may have"+
+			" changed. Re-enter the values in the monitor settings", keys)
+		return
+	}
+
 	m.logf(log.LevelInfo, "starting")
 
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 
 	if m.Config.alwaysRecord() {
-		infinte := time.Duration(1<<63 - 62135596801)
 		go func() {
 			select {
 			case <-m.ctx.Done():
 			case <-time.After(15 * time.Second):
-				err := m.SendEvent(storage.Event{
-					Time:        time.Now(),
-					RecDuration: infinte,
-				})
-				if err != nil {
-					m.logf(log.LevelError, "could not start continuous recording: %v", err)
-				}
+				m.runRecordSchedule(m.ctx)
 			}
 		}()
 	}
@@ -382,13 +604,23 @@ func (m *Monitor) start() {
 
 	m.WG.Add(1)
 	go m.recorder.start(m.ctx)
+
+	m.sendState(MonitorStarted)
 }
 
 // SendEventFunc send event signature.
 type SendEventFunc func(storage.Event) error
 
-// SendEvent sends event to recorder.
+// SendEvent sends event to the live event feed and the
+// recorder. Events are not recorded while the monitor is disarmed.
 func (m *Monitor) SendEvent(event storage.Event) error {
+	if m.eventFeed != nil {
+		m.eventFeed.send(newLiveEvent(m.Config.ID(), event))
+	}
+	if !m.Armed(time.Now()) {
+		m.logf(log.LevelDebug, "disarmed, ignoring event")
+		return nil
+	}
 	return m.recorder.sendEvent(m.ctx, event)
 }
 
@@ -398,6 +630,11 @@ func (m *Monitor) stop() {
 		m.cancel()
 	}
 	m.WG.Wait()
+
+	// Disabled monitors were never started.
+	if m.cancel != nil {
+		m.sendState(MonitorStopped)
+	}
 }
 
 // InputProcess monitor input process.
@@ -406,7 +643,27 @@ type InputProcess struct {
 	serverPath video.ServerPath
 	isSubInput bool
 
-	cancel func()
+	cancel      func()
+	health      *inputHealth
+	backoff     *backoff
+	usage       processUsage
+	transcoders *Transcoders
+	sourceAddr  string
+
+	// Privacy zones and the path of their mask image.
+	privacyZones []PrivacyZone
+	privacyMask  string
+
+	// Set if the "auto" audio encoder should transcode.
+	audioTranscode atomic.Bool
+
+	// Parses the stderr of the running process.
+	supervisor *supervisor
+
+	failover         inputFailover
+	failbackInterval time.Duration
+	probe            streamProbeFunc
+	sendState        func(state string)
 
 	hooks     Hooks
 	Env       storage.ConfigEnv
@@ -418,6 +675,7 @@ type InputProcess struct {
 	newVideoServerPath newVideoServerPathFunc
 	runInputProcess    runInputProcessFunc
 	newProcess         ffmpeg.NewProcessFunc
+	listInterfaces     listInterfacesFunc
 }
 
 type newVideoServerPathFunc func(context.Context, string, video.PathConf) (*video.ServerPath, error)
@@ -435,11 +693,22 @@ func newInputProcess(m *Monitor, isSubInput bool) *InputProcess {
 		WG:        &m.WG,
 		SendEvent: m.SendEvent,
 
+		transcoders: m.transcoders,
+
+		failbackInterval: failbackProbeInterval,
+		probe:            runStreamProbe,
+		sendState:        m.sendState,
+
 		logf:               m.logf,
 		newVideoServerPath: m.videoServer.NewPath,
 		runInputProcess:    runInputProcess,
 		newProcess:         ffmpeg.NewProcess,
+		listInterfaces:     listInterfaces,
 	}
+	i.health = newInputHealth(func(health InputHealth) {
+		m.sendHealth(i.ProcessName(), health)
+	})
+	i.backoff = newBackoff()
 
 	return i
 }
@@ -500,17 +769,20 @@ func (i *InputProcess) ProcessName() string {
 }
 
 func (i *InputProcess) input() string {
+	if i.isAutoSubStream() {
+		return i.mainRTSPaddress()
+	}
 	if i.IsSubInput() {
 		return i.Config.SubInput()
 	}
+	if i.failover.isOnBackup() {
+		return i.Config.BackupInput()
+	}
 	return i.Config.MainInput()
 }
 
 func (i *InputProcess) rtspPathName() string {
-	if i.isSubInput {
-		return i.Config.ID() + "_sub"
-	}
-	return i.Config.ID()
+	return video.PathName(i.Config.ID(), i.isSubInput)
 }
 
 // Cancel process context.
@@ -519,42 +791,131 @@ func (i *InputProcess) Cancel() {
 }
 
 func (i *InputProcess) start(ctx context.Context) {
+	if i.health == nil {
+		i.health = newInputHealth(nil)
+	}
+	if i.backoff == nil {
+		i.backoff = newBackoff()
+	}
+	i.failover.setBackup(false)
+
 	for {
 		if ctx.Err() != nil {
+			i.health.stopped()
 			i.logf(log.LevelInfo, "%v process: stopped", i.ProcessName())
 			i.WG.Done()
 			return
 		}
 
+		startTime := time.Now()
+		i.health.starting()
+
 		if err := i.runInputProcess(ctx, i); err != nil {
+			// Reset the backoff if the process produced
+			// segments before it crashed.
+			if i.health.wasHealthySince(startTime) {
+				i.backoff.reset()
+			}
+			if i.checkFailover(ctx) {
+				i.backoff.reset()
+			}
+			delay := i.backoff.next()
+			i.health.crashed(err, time.Now().Add(delay))
+
 			i.logf(log.LevelError, "%v process: crashed: %v", i.ProcessName(), err)
 			select {
 			case <-ctx.Done():
-			case <-time.After(1 * time.Second):
+			case <-time.After(delay):
 			}
 			continue
 		}
 	}
 }
 
+// ErrSourceAddrGStreamer source interface is not supported by GStreamer.
+var ErrSourceAddrGStreamer = errors.New("source interface is not supported by GStreamer")
+
 func runInputProcess(ctx context.Context, i *InputProcess) error {
 	processCTX, cancel2 := context.WithCancel(ctx)
 	i.cancel = cancel2
 	defer cancel2()
 
+	sourceAddr, err := resolveSourceAddr(i.Config.SourceInterface(), i.listInterfaces)
+	if err != nil {
+		return fmt.Errorf("source interface: %w", err)
+	}
+	i.sourceAddr = sourceAddr
+
 	pathConf := video.PathConf{MonitorID: i.Config.ID(), IsSub: i.IsSubInput()}
+	if i.hooks.Frame != nil {
+		pathConf.OnH264 = func(frame video.H264Frame) {
+			i.hooks.Frame(i, frame)
+		}
+	}
 	serverPath, err := i.newVideoServerPath(processCTX, i.rtspPathName(), pathConf)
 	if err != nil {
 		return fmt.Errorf("add path to RTSP server: %w", err)
 	}
 	i.serverPath = *serverPath
 
+	i.supervisor = newSupervisor(i)
+	var stalled atomic.Bool
+	go i.watchForStall(processCTX, func() {
+		stalled.Store(true)
+		cancel2()
+	})
+	if i.isPublished() {
+		return i.waitForPublisher(ctx, processCTX)
+	}
+	isAutoAudio := i.Config.AudioEncoder() == AudioEncoderAuto &&
+		!i.Config.IsVirtual() && !i.isAutoSubStream()
+	if isAutoAudio && !i.audioTranscode.Load() {
+		go i.checkAudioCopy(processCTX, cancel2)
+	}
+
 	logLevel := log.FFmpegLevel(i.Config.LogLevel())
-	args := ffmpeg.ParseArgs(i.generateArgs())
 
-	i.hooks.StartInput(processCTX, i, &args)
+	transcoder, err := i.transcoder()
+	if err != nil {
+		return err
+	}
 
-	cmd := exec.Command(i.Env.FFmpegBin, args...)
+	var cmd *exec.Cmd
+	switch {
+	case i.isAutoSubStream():
+		args := ffmpeg.ParseArgs(i.generateAutoSubStreamArgs())
+		i.hooks.StartInput(processCTX, i, &args)
+		bin, args := i.Config.wrapCommand(i.Env.FFmpegBin, args)
+		cmd = exec.Command(bin, args...)
+	case i.Config.IsVirtual():
+		if len(i.privacyZones) != 0 {
+			i.logf(log.LevelWarning, "%v process: privacy masks are not applied to virtual monitors",
+				i.ProcessName())
+		}
+		// Input hooks are skipped, they add decoded outputs for detectors.
+		args := ffmpeg.ParseArgs(i.generateVirtualArgs())
+		bin, args := i.Config.wrapCommand(i.Env.FFmpegBin, args)
+		cmd = exec.Command(bin, args...)
+	case transcoder == TranscoderGStreamer:
+		if i.sourceAddr != "" {
+			return ErrSourceAddrGStreamer
+		}
+		args := ffmpeg.ParseArgs(i.generateGStreamerArgs())
+		i.hooks.StartInput(processCTX, i, &args)
+		bin, args := i.Config.wrapCommand(i.Env.GStreamerBin, args)
+		cmd = exec.Command(bin, args...)
+	default:
+		i.privacyMask = ""
+		if len(i.privacyZones) != 0 {
+			if i.privacyMask, err = i.writePrivacyMask(); err != nil {
+				return fmt.Errorf("write privacy mask: %w", err)
+			}
+		}
+		args := ffmpeg.ParseArgs(i.generateArgs())
+		i.hooks.StartInput(processCTX, i, &args)
+		bin, args := i.Config.wrapCommand(i.Env.FFmpegBin, args)
+		cmd = exec.Command(bin, args...)
+	}
 
 	logFunc := func(msg string) {
 		i.logf(logLevel, "%v process: %v", i.ProcessName(), msg)
@@ -563,18 +924,53 @@ func runInputProcess(ctx context.Context, i *InputProcess) error {
 	process := i.newProcess(cmd).
 		Timeout(10 * time.Second).
 		StdoutLogger(logFunc).
-		StderrLogger(logFunc)
+		StderrLogger(i.supervisor.parse).
+		OnStart(i.usage.started)
 
 	i.logf(log.LevelInfo, "starting %v process: %v", i.ProcessName(), cmd)
 
 	err = process.Start(processCTX) // Blocks until process exits.
+	i.usage.stopped()
+	if stalled.Load() {
+		return ErrInputStalled
+	}
 	if err != nil {
+		if lastErr := i.supervisor.lastError(); lastErr != nil {
+			return fmt.Errorf("crashed: %w: %w", err, lastErr)
+		}
 		return fmt.Errorf("crashed: %w", err)
 	}
 
 	return nil
 }
 
+// audioEncoder resolves the "auto" audio encoder.
+func (i *InputProcess) audioEncoder() string {
+	encoder := i.Config.AudioEncoder()
+	if encoder != AudioEncoderAuto {
+		return encoder
+	}
+	if i.audioTranscode.Load() {
+		return AudioEncoderAAC
+	}
+	return AudioEncoderCopy
+}
+
+// checkAudioCopy restarts the process with AAC transcoding if the
+// copied audio track is missing. AAC is the only supported audio
+// codec, other codecs like G.711 are dropped by the video server.
+func (i *InputProcess) checkAudioCopy(ctx context.Context, restart func()) {
+	track, err := i.AudioTrack(ctx)
+	if err != nil || track != nil {
+		return
+	}
+	i.logf(log.LevelInfo,
+		"%v process: input audio is missing or not AAC, restarting with AAC transcoding",
+		i.ProcessName())
+	i.audioTranscode.Store(true)
+	restart()
+}
+
 func (i *InputProcess) generateArgs() string {
 	// OUTPUT
 	// -threads 1 -loglevel error -hwaccel x -i rtsp://x -c:a aac -c:v libx264
@@ -591,15 +987,37 @@ func (i *InputProcess) generateArgs() string {
 	if c.InputOpts() != "" {
 		args += " " + c.InputOpts()
 	}
+	if i.sourceAddr != "" {
+		args += " -local_addr " + i.sourceAddr
+	}
 	args += " -i " + i.input()
 
+	if i.privacyMask != "" {
+		// The mask image is scaled to the video and overlaid.
+		args += " -loop 1 -i " + i.privacyMask +
+			" -filter_complex [1:v][0:v:0]scale2ref[mask][video];[video][mask]overlay=shortest=1[masked]" +
+			" -map [masked]"
+	}
+
 	if c.audioEnabled() {
-		args += " -c:a " + c.AudioEncoder()
+		stream := c.AudioStream()
+		switch {
+		case i.privacyMask != "" && stream != -1:
+			args += " -map 0:a:" + strconv.Itoa(stream) + "?"
+		case i.privacyMask != "":
+			args += " -map 0:a?"
+		case stream != -1:
+			args += " -map 0:v:0 -map 0:a:" + strconv.Itoa(stream) + "?"
+		}
+		args += " -c:a " + i.audioEncoder()
 	} else {
 		args += " -an" // Skip audio.
 	}
 
-	args += " -c:v " + c.VideoEncoder()
+	if threads := c.Threads(); threads != 0 {
+		args += " -threads " + strconv.Itoa(threads)
+	}
+	args += " -c:v " + i.videoEncoder()
 	args += " -f rtsp -rtsp_transport " + i.RTSPprotocol() + " " + i.RTSPaddress()
 
 	return args
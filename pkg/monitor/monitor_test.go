@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
 	"sync"
@@ -69,8 +70,11 @@ func newTestManager(t *testing.T) (string, *Manager) {
 	manager, err := NewManager(
 		configDir,
 		storage.ConfigEnv{},
+		nil,
 		log.NewDummyLogger(),
 		nil,
+		nil,
+		nil,
 		&Hooks{Migrate: func(RawConfig) error { return nil }},
 	)
 	require.NoError(t, err)
@@ -114,8 +118,11 @@ func TestNewManager(t *testing.T) {
 		manager, err := NewManager(
 			configDir,
 			storage.ConfigEnv{},
+			nil,
 			&log.Logger{},
 			&video.Server{},
+			nil,
+			nil,
 			&Hooks{Migrate: migrate},
 		)
 		require.NoError(t, err)
@@ -133,15 +140,18 @@ func TestNewManager(t *testing.T) {
 		require.Equal(t, expected2, string(actual2))
 	})
 	t.Run("mkDirErr", func(t *testing.T) {
-		_, err := NewManager("/dev/null/nil", storage.ConfigEnv{}, nil, nil, nil)
+		_, err := NewManager("/dev/null/nil", storage.ConfigEnv{}, nil, nil, nil, nil, nil, nil)
 		require.Error(t, err)
 	})
 	t.Run("readFileErr", func(t *testing.T) {
 		_, err := NewManager(
 			"/dev/null/nil.json",
 			storage.ConfigEnv{},
+			nil,
 			&log.Logger{},
 			&video.Server{},
+			nil,
+			nil,
 			&Hooks{Migrate: func(RawConfig) error { return nil }},
 		)
 		require.Error(t, err)
@@ -156,8 +166,11 @@ func TestNewManager(t *testing.T) {
 		_, err = NewManager(
 			configDir,
 			storage.ConfigEnv{},
+			nil,
 			&log.Logger{},
 			&video.Server{},
+			nil,
+			nil,
 			&Hooks{Migrate: func(RawConfig) error { return nil }},
 		)
 		var e *json.SyntaxError
@@ -175,8 +188,11 @@ func TestNewManager(t *testing.T) {
 		_, err = NewManager(
 			configDir,
 			storage.ConfigEnv{},
+			nil,
 			&log.Logger{},
 			&video.Server{},
+			nil,
+			nil,
 			&Hooks{Migrate: func(RawConfig) error { return stubErr }},
 		)
 		require.ErrorIs(t, err, stubErr)
@@ -190,8 +206,10 @@ func TestMonitorSet(t *testing.T) {
 		config := manager.rawConfigs["1"]
 		config["name"] = "new"
 
+		version := manager.ConfigVersion()
 		err := manager.MonitorSet("new", config)
 		require.NoError(t, err)
+		require.Equal(t, version+1, manager.ConfigVersion())
 
 		newName := manager.rawConfigs["new"]["name"]
 		require.Equal(t, newName, "new")
@@ -234,8 +252,10 @@ func TestMonitorDelete(t *testing.T) {
 		configDir, manager := newTestManager(t)
 		manager.runningMonitors["1"] = &Monitor{}
 
+		version := manager.ConfigVersion()
 		err := manager.MonitorDelete("1")
 		require.NoError(t, err)
+		require.Equal(t, version+1, manager.ConfigVersion())
 
 		require.Nil(t, manager.runningMonitors["1"])
 		require.NoFileExists(t, filepath.Join(configDir, "1.json"))
@@ -267,7 +287,9 @@ func TestMonitorList(t *testing.T) {
 				"enable":       "true",
 				"audioEncoder": "x",
 				"subInput":     "x",
+				"talkback":     "true",
 				"secret":       "x",
+				"tags":         "Outdoor, garage,outdoor",
 			},
 		},
 	}
@@ -280,6 +302,8 @@ func TestMonitorList(t *testing.T) {
 			"id":              "1",
 			"name":            "2",
 			"subInputEnabled": "false",
+			"talkbackEnabled": "false",
+			"tags":            "",
 		},
 		"3": {
 			"audioEnabled":    "true",
@@ -287,6 +311,8 @@ func TestMonitorList(t *testing.T) {
 			"id":              "3",
 			"name":            "4",
 			"subInputEnabled": "true",
+			"talkbackEnabled": "true",
+			"tags":            "garage,outdoor",
 		},
 	}
 	require.Equal(t, expected, actual)
@@ -317,7 +343,7 @@ func TestMonitorConfigs(t *testing.T) {
 
 func TestStartAllMonitors(t *testing.T) {
 	_, manager := newTestManager(t)
-	manager.StartMonitors()
+	manager.StartMonitors(context.Background())
 	manager.StopMonitors()
 }
 
@@ -344,6 +370,41 @@ func TestRestartMonitor(t *testing.T) {
 	})
 }
 
+func TestSetEnabled(t *testing.T) {
+	t.Run("disable", func(t *testing.T) {
+		configDir, manager := newTestManager(t)
+		manager.rawConfigs["1"]["enable"] = "true"
+		manager.runningMonitors["1"] = &Monitor{}
+
+		changed, err := manager.SetEnabled("1", false)
+		require.NoError(t, err)
+		require.True(t, changed)
+
+		config := readConfig(t, filepath.Join(configDir, "1.json"))
+		require.Equal(t, "false", config["enable"])
+		require.Equal(t, "one", config["name"])
+		require.Equal(t, "false", manager.MonitorsInfo()["1"]["enable"])
+
+		// The disabled monitor is kept so it can be deleted.
+		require.False(t, manager.runningMonitors["1"].Config.enabled())
+		require.NoError(t, manager.MonitorDelete("1"))
+	})
+	t.Run("unchanged", func(t *testing.T) {
+		_, manager := newTestManager(t)
+		version := manager.ConfigVersion()
+
+		changed, err := manager.SetEnabled("1", false)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Equal(t, version, manager.ConfigVersion())
+	})
+	t.Run("notExistErr", func(t *testing.T) {
+		_, manager := newTestManager(t)
+		_, err := manager.SetEnabled("x", true)
+		require.ErrorIs(t, err, ErrMonitorNotExist)
+	})
+}
+
 func stubNewVideoServerPath(
 	_ context.Context,
 	name string,
@@ -373,9 +434,16 @@ func newTestInputProcess() *InputProcess {
 		newVideoServerPath: stubNewVideoServerPath,
 		runInputProcess:    stubRunInputProcess,
 		newProcess:         ffmock.NewProcess,
+		listInterfaces:     stubListInterfaces,
 	}
 }
 
+func stubListInterfaces() ([]netInterface, error) {
+	return []netInterface{
+		{name: "eth1", ips: []net.IP{net.ParseIP("10.0.20.2")}},
+	}, nil
+}
+
 func stubHooks() Hooks {
 	return Hooks{
 		Start:      func(context.Context, *Monitor) {},
@@ -463,6 +531,19 @@ func TestRunInputProcess(t *testing.T) {
 		err := runInputProcess(context.Background(), i)
 		require.Error(t, err)
 	})
+	t.Run("sourceInterface", func(t *testing.T) {
+		i := newTestInputProcess()
+		i.newProcess = ffmock.NewProcessErr
+		i.Config.v["sourceInterface"] = "eth1"
+		require.Error(t, runInputProcess(context.Background(), i))
+		require.Equal(t, "10.0.20.2", i.sourceAddr)
+	})
+	t.Run("sourceInterfaceErr", func(t *testing.T) {
+		i := newTestInputProcess()
+		i.Config.v["sourceInterface"] = "eth2"
+		err := runInputProcess(context.Background(), i)
+		require.ErrorIs(t, err, ErrInterfaceNotExist)
+	})
 	t.Run("rtspPathErr", func(t *testing.T) {
 		i := newTestInputProcess()
 		i.Config.v["id"] = ""
@@ -510,6 +591,90 @@ func TestGenInputArgs(t *testing.T) {
 		expected := "-threads 1 -loglevel 1 -hwaccel 2 3 -i 4 -c:a 5 -c:v 6 -f rtsp -rtsp_transport 8 9"
 		require.Equal(t, expected, actual)
 	})
+	t.Run("sourceAddr", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"logLevel":     "1",
+				"mainInput":    "2",
+				"videoEncoder": "3",
+			}),
+			sourceAddr: "10.0.0.2",
+			serverPath: video.ServerPath{
+				RtspProtocol: "4",
+				RtspAddress:  "5",
+			},
+		}
+		actual := i.generateArgs()
+		expected := "-threads 1 -loglevel 1 -local_addr 10.0.0.2 -i 2 -an -c:v 3 -f rtsp -rtsp_transport 4 5"
+		require.Equal(t, expected, actual)
+	})
+	t.Run("threads", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"logLevel":     "1",
+				"mainInput":    "2",
+				"videoEncoder": "3",
+				"threads":      "2",
+			}),
+			serverPath: video.ServerPath{
+				RtspProtocol: "4",
+				RtspAddress:  "5",
+			},
+		}
+		actual := i.generateArgs()
+		expected := "-threads 1 -loglevel 1 -i 2 -an -threads 2 -c:v 3 -f rtsp -rtsp_transport 4 5"
+		require.Equal(t, expected, actual)
+	})
+	t.Run("audio", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"logLevel":     "1",
+				"mainInput":    "2",
+				"audioEncoder": "auto",
+				"audioStream":  "1",
+				"videoEncoder": "3",
+			}),
+			serverPath: video.ServerPath{
+				RtspProtocol: "4",
+				RtspAddress:  "5",
+			},
+		}
+		actual := i.generateArgs()
+		expected := "-threads 1 -loglevel 1 -i 2 -map 0:v:0 -map 0:a:1? -c:a copy" +
+			" -c:v 3 -f rtsp -rtsp_transport 4 5"
+		require.Equal(t, expected, actual)
+
+		i.audioTranscode.Store(true)
+		actual = i.generateArgs()
+		expected = "-threads 1 -loglevel 1 -i 2 -map 0:v:0 -map 0:a:1? -c:a aac" +
+			" -c:v 3 -f rtsp -rtsp_transport 4 5"
+		require.Equal(t, expected, actual)
+	})
+}
+
+func TestCheckAudioCopy(t *testing.T) {
+	newTestInput := func(audioTrack *gortsplib.TrackMPEG4Audio) *InputProcess {
+		return &InputProcess{
+			serverPath: video.ServerPath{
+				HLSMuxer: newMockMuxerFunc(&mockMuxer{audioTrack: audioTrack}),
+			},
+			logf: func(log.Level, string, ...interface{}) {},
+		}
+	}
+	t.Run("aac", func(t *testing.T) {
+		i := newTestInput(&gortsplib.TrackMPEG4Audio{})
+		restarted := false
+		i.checkAudioCopy(context.Background(), func() { restarted = true })
+		require.False(t, restarted)
+		require.False(t, i.audioTranscode.Load())
+	})
+	t.Run("notAAC", func(t *testing.T) {
+		i := newTestInput(nil)
+		restarted := false
+		i.checkAudioCopy(context.Background(), func() { restarted = true })
+		require.True(t, restarted)
+		require.True(t, i.audioTranscode.Load())
+	})
 }
 
 func TestInputVideoTrack(t *testing.T) {
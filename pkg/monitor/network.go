@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Errors.
+var (
+	ErrInterfaceNotExist = errors.New("network interface does not exist")
+	ErrInterfaceNoAddr   = errors.New("network interface has no IP address")
+)
+
+// SourceInterface returns the local network interface or
+// IP address used to connect to the camera, optional.
+func (c Config) SourceInterface() string {
+	return c.v["sourceInterface"]
+}
+
+// SourceAddr returns the local IP address used to connect to the
+// camera. Returns a empty string if the source interface is unset.
+func (c Config) SourceAddr() (string, error) {
+	return resolveSourceAddr(c.SourceInterface(), listInterfaces)
+}
+
+// Dialer returns a dialer that binds the connections
+// to the camera, for example ONVIF, to the source interface.
+func (c Config) Dialer() (*net.Dialer, error) {
+	sourceAddr, err := c.SourceAddr()
+	if err != nil {
+		return nil, fmt.Errorf("source interface: %w", err)
+	}
+	return newSourceDialer(sourceAddr), nil
+}
+
+func newSourceDialer(sourceAddr string) *net.Dialer {
+	if sourceAddr == "" {
+		return &net.Dialer{}
+	}
+	return &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(sourceAddr)}}
+}
+
+type netInterface struct {
+	name string
+	ips  []net.IP
+}
+
+type listInterfacesFunc func() ([]netInterface, error)
+
+func listInterfaces() ([]netInterface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var list []netInterface
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", iface.Name, err)
+		}
+		var ips []net.IP
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+		list = append(list, netInterface{name: iface.Name, ips: ips})
+	}
+	return list, nil
+}
+
+// resolveSourceAddr accepts a interface name, for example "eth1" or
+// "eth0.20", or a IP address that's assigned to a local interface.
+// The first IPv4 address is preferred if the interface has multiple.
+func resolveSourceAddr(value string, list listInterfacesFunc) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	interfaces, err := list()
+	if err != nil {
+		return "", fmt.Errorf("list network interfaces: %w", err)
+	}
+
+	if ip := net.ParseIP(value); ip != nil {
+		for _, iface := range interfaces {
+			for _, ifaceIP := range iface.ips {
+				if ifaceIP.Equal(ip) {
+					return ip.String(), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("%w: %v", ErrInterfaceNotExist, value)
+	}
+
+	for _, iface := range interfaces {
+		if iface.name != value {
+			continue
+		}
+		for _, ip := range iface.ips {
+			if ip.To4() != nil {
+				return ip.String(), nil
+			}
+		}
+		if len(iface.ips) != 0 {
+			return iface.ips[0].String(), nil
+		}
+		return "", fmt.Errorf("%w: %v", ErrInterfaceNoAddr, value)
+	}
+	return "", fmt.Errorf("%w: %v", ErrInterfaceNotExist, value)
+}
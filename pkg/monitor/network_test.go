@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSourceAddr(t *testing.T) {
+	stubList := func() ([]netInterface, error) {
+		return []netInterface{
+			{name: "eth0", ips: []net.IP{net.ParseIP("192.168.1.2")}},
+			{name: "eth1", ips: []net.IP{
+				net.ParseIP("fe80::1"),
+				net.ParseIP("10.0.20.2"),
+			}},
+			{name: "eth2", ips: []net.IP{net.ParseIP("fe80::2")}},
+			{name: "eth3"},
+		}, nil
+	}
+
+	cases := map[string]struct {
+		input       string
+		expected    string
+		expectedErr error
+	}{
+		"empty":        {"", "", nil},
+		"name":         {"eth0", "192.168.1.2", nil},
+		"preferIPv4":   {"eth1", "10.0.20.2", nil},
+		"onlyIPv6":     {"eth2", "fe80::2", nil},
+		"noAddr":       {"eth3", "", ErrInterfaceNoAddr},
+		"nameNotExist": {"eth4", "", ErrInterfaceNotExist},
+		"ip":           {"10.0.20.2", "10.0.20.2", nil},
+		"ipNotExist":   {"10.0.20.3", "", ErrInterfaceNotExist},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := resolveSourceAddr(tc.input, stubList)
+			require.ErrorIs(t, err, tc.expectedErr)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+	t.Run("listErr", func(t *testing.T) {
+		stubErr := errors.New("stub")
+		listErr := func() ([]netInterface, error) { return nil, stubErr }
+		_, err := resolveSourceAddr("eth0", listErr)
+		require.ErrorIs(t, err, stubErr)
+	})
+}
+
+func TestNewSourceDialer(t *testing.T) {
+	require.Nil(t, newSourceDialer("").LocalAddr)
+	require.Equal(t,
+		&net.TCPAddr{IP: net.ParseIP("10.0.20.2")},
+		newSourceDialer("10.0.20.2").LocalAddr,
+	)
+}
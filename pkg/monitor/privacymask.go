@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrivacyZone is a polygon that is blacked out in the live streams and
+// recordings. Points are normalized coordinates, [0,0] is the top left
+// corner and [1,1] the bottom right corner of the frame.
+type PrivacyZone struct {
+	Points [][2]float64 `json:"points"`
+}
+
+// PrivacyMask is a version of the privacy zones of a monitor. Every
+// change creates a new version, old versions are kept for auditing.
+type PrivacyMask struct {
+	Version  int           `json:"version"`
+	Time     time.Time     `json:"time"`
+	Username string        `json:"username"`
+	Zones    []PrivacyZone `json:"zones"`
+}
+
+// Privacy mask limits.
+const (
+	maxPrivacyZones  = 16
+	maxPrivacyPoints = 64
+)
+
+// ErrInvalidPrivacyZone invalid privacy zone.
+var ErrInvalidPrivacyZone = errors.New("invalid privacy zone")
+
+// validatePrivacyZones returns a error if a zone has
+// less than three points or a point is out of bounds.
+func validatePrivacyZones(zones []PrivacyZone) error {
+	if len(zones) > maxPrivacyZones {
+		return fmt.Errorf("%w: max %v zones", ErrInvalidPrivacyZone, maxPrivacyZones)
+	}
+	for i, zone := range zones {
+		if len(zone.Points) < 3 || len(zone.Points) > maxPrivacyPoints {
+			return fmt.Errorf("%w: zone %v: must have 3 to %v points",
+				ErrInvalidPrivacyZone, i, maxPrivacyPoints)
+		}
+		for _, p := range zone.Points {
+			if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+				return fmt.Errorf("%w: zone %v: point %v is out of bounds",
+					ErrInvalidPrivacyZone, i, p)
+			}
+		}
+	}
+	return nil
+}
+
+// PrivacyMasks stores the privacy mask history
+// of each monitor, one JSON file per monitor.
+type PrivacyMasks struct {
+	history map[string][]PrivacyMask
+	path    string
+	mu      sync.Mutex
+}
+
+// NewPrivacyMasks loads the privacy masks from the directory.
+func NewPrivacyMasks(configPath string) (*PrivacyMasks, error) {
+	if err := os.MkdirAll(configPath, 0o700); err != nil {
+		return nil, fmt.Errorf("create privacy masks directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read privacy masks directory: %w", err)
+	}
+
+	history := make(map[string][]PrivacyMask)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(configPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read privacy mask: %w", err)
+		}
+		var masks []PrivacyMask
+		if err := json.Unmarshal(raw, &masks); err != nil {
+			return nil, fmt.Errorf("unmarshal privacy mask: %w: %v", err, entry.Name())
+		}
+		history[strings.TrimSuffix(entry.Name(), ".json")] = masks
+	}
+
+	return &PrivacyMasks{
+		history: history,
+		path:    configPath,
+	}, nil
+}
+
+func (p *PrivacyMasks) maskPath(monitorID string) string {
+	return filepath.Join(p.path, monitorID+".json")
+}
+
+// Current returns the current privacy zones of the monitor.
+func (p *PrivacyMasks) Current(monitorID string) []PrivacyZone {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history := p.history[monitorID]
+	if len(history) == 0 {
+		return nil
+	}
+	return history[len(history)-1].Zones
+}
+
+// History returns all versions of the privacy mask, oldest first.
+func (p *PrivacyMasks) History(monitorID string) []PrivacyMask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PrivacyMask{}, p.history[monitorID]...)
+}
+
+// Set saves a new version of the privacy mask. Empty zones remove the mask.
+func (p *PrivacyMasks) Set(monitorID string, username string, zones []PrivacyZone) (PrivacyMask, error) {
+	if monitorID == "" || monitorID == "." || monitorID == ".." ||
+		strings.ContainsAny(monitorID, `/\`) {
+		return PrivacyMask{}, fmt.Errorf("%w: %q", storage.ErrInvalidMonitorID, monitorID)
+	}
+	if err := validatePrivacyZones(zones); err != nil {
+		return PrivacyMask{}, err
+	}
+	if zones == nil {
+		zones = []PrivacyZone{}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history := p.history[monitorID]
+	mask := PrivacyMask{
+		Version:  len(history) + 1,
+		Time:     time.Now().UTC(),
+		Username: username,
+		Zones:    zones,
+	}
+	history = append(append([]PrivacyMask{}, history...), mask)
+
+	raw, err := json.MarshalIndent(history, "", "    ")
+	if err != nil {
+		return PrivacyMask{}, fmt.Errorf("marshal privacy mask: %w", err)
+	}
+	if err := os.WriteFile(p.maskPath(monitorID), raw, 0o600); err != nil {
+		return PrivacyMask{}, fmt.Errorf("write privacy mask: %w", err)
+	}
+	p.history[monitorID] = history
+	return mask, nil
+}
+
+// Size of the mask image, it's scaled to the size of the video.
+const (
+	privacyMaskWidth  = 1280
+	privacyMaskHeight = 720
+)
+
+// createPrivacyMask returns a image that is
+// black inside the zones and transparent outside.
+func createPrivacyMask(zones []PrivacyZone) image.Image {
+	polygons := make([]ffmpeg.Polygon, 0, len(zones))
+	for _, zone := range zones {
+		polygon := make(ffmpeg.Polygon, 0, len(zone.Points))
+		for _, p := range zone.Points {
+			polygon = append(polygon, ffmpeg.Point{
+				int(p[0] * privacyMaskWidth),
+				int(p[1] * privacyMaskHeight),
+			})
+		}
+		polygons = append(polygons, polygon)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, privacyMaskWidth, privacyMaskHeight))
+	for y := 0; y < privacyMaskHeight; y++ {
+		for x := 0; x < privacyMaskWidth; x++ {
+			for _, polygon := range polygons {
+				if ffmpeg.VertexInsidePoly(x, y, polygon) {
+					img.Set(x, y, color.NRGBA{A: 255})
+					break
+				}
+			}
+		}
+	}
+	return img
+}
+
+// writePrivacyMask writes the mask image of the input process
+// to the temporary directory and returns the path.
+func (i *InputProcess) writePrivacyMask() (string, error) {
+	dir := filepath.Join(i.Env.TempDir, "privacy")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, i.rtspPathName()+".png")
+	if err := ffmpeg.SaveImage(path, createPrivacyMask(i.privacyZones)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// setPrivacyZones sets the privacy zones of both inputs.
+func (m *Monitor) setPrivacyZones(zones []PrivacyZone) {
+	m.mainInput.privacyZones = zones
+	m.subInput.privacyZones = zones
+}
+
+// Masked videos can't be copied, they're encoded with this instead.
+const privacyMaskEncoder = "libx264"
+
+// videoEncoder returns the video encoder of the input process.
+func (i *InputProcess) videoEncoder() string {
+	encoder := i.Config.VideoEncoder()
+	if i.privacyMask != "" && (encoder == "" || encoder == "copy") {
+		return privacyMaskEncoder
+	}
+	return encoder
+}
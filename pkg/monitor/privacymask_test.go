@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"image/color"
+	"testing"
+
+	"nvr/pkg/storage"
+	"nvr/pkg/video"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivacyMasks(t *testing.T) {
+	dir := t.TempDir()
+	masks, err := NewPrivacyMasks(dir)
+	require.NoError(t, err)
+	require.Nil(t, masks.Current("a"))
+
+	zone := PrivacyZone{Points: [][2]float64{{0, 0}, {0.5, 0}, {0.5, 0.5}}}
+
+	_, err = masks.Set("../a", "admin", []PrivacyZone{zone})
+	require.ErrorIs(t, err, storage.ErrInvalidMonitorID)
+
+	invalid := [][]PrivacyZone{
+		{{Points: [][2]float64{{0, 0}, {1, 1}}}},
+		{{Points: [][2]float64{{0, 0}, {1, 0}, {1, 1.5}}}},
+		{{Points: [][2]float64{{-0.1, 0}, {1, 0}, {1, 1}}}},
+	}
+	for _, zones := range invalid {
+		_, err = masks.Set("a", "admin", zones)
+		require.ErrorIs(t, err, ErrInvalidPrivacyZone, zones)
+	}
+
+	mask, err := masks.Set("a", "admin", []PrivacyZone{zone})
+	require.NoError(t, err)
+	require.Equal(t, 1, mask.Version)
+
+	mask, err = masks.Set("a", "user", nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, mask.Version)
+	require.Empty(t, masks.Current("a"))
+
+	// Reloaded.
+	masks, err = NewPrivacyMasks(dir)
+	require.NoError(t, err)
+	history := masks.History("a")
+	require.Len(t, history, 2)
+	require.Equal(t, "admin", history[0].Username)
+	require.Equal(t, []PrivacyZone{zone}, history[0].Zones)
+	require.Equal(t, "user", history[1].Username)
+	require.Equal(t, []PrivacyZone{}, history[1].Zones)
+	require.Empty(t, masks.History("b"))
+}
+
+func TestCreatePrivacyMask(t *testing.T) {
+	img := createPrivacyMask([]PrivacyZone{
+		{Points: [][2]float64{{0, 0}, {0.5, 0}, {0.5, 0.5}, {0, 0.5}}},
+	})
+	require.Equal(t, privacyMaskWidth, img.Bounds().Dx())
+	require.Equal(t, privacyMaskHeight, img.Bounds().Dy())
+
+	require.Equal(t, color.NRGBA{A: 255}, img.At(100, 100))
+	require.Equal(t, color.NRGBA{}, img.At(1000, 600))
+}
+
+func TestGenerateArgsPrivacyMask(t *testing.T) {
+	i := &InputProcess{
+		Config: NewConfig(RawConfig{
+			"logLevel":     "1",
+			"mainInput":    "2",
+			"audioEncoder": "aac",
+			"videoEncoder": "copy",
+		}),
+		privacyMask: "mask.png",
+		serverPath: video.ServerPath{
+			RtspProtocol: "4",
+			RtspAddress:  "5",
+		},
+	}
+	actual := i.generateArgs()
+	expected := "-threads 1 -loglevel 1 -i 2 -loop 1 -i mask.png -filter_complex" +
+		" [1:v][0:v:0]scale2ref[mask][video];[video][mask]overlay=shortest=1[masked]" +
+		" -map [masked] -map 0:a? -c:a aac -c:v libx264 -f rtsp -rtsp_transport 4 5"
+	require.Equal(t, expected, actual)
+}
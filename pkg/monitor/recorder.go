@@ -5,19 +5,21 @@ package monitor
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"nvr/pkg/crypt"
 	"nvr/pkg/ffmpeg"
 	"nvr/pkg/log"
 	"nvr/pkg/storage"
 	"nvr/pkg/video/customformat"
 	"nvr/pkg/video/gortsplib"
+	"nvr/pkg/video/gortsplib/pkg/h264"
 	"nvr/pkg/video/hls"
 	"nvr/pkg/video/mp4muxer"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime/debug"
 	"strconv"
 	"sync"
 	"time"
@@ -35,11 +37,16 @@ type Recorder struct {
 	runSession runRecordingFunc
 	NewProcess ffmpeg.NewProcessFunc
 
-	input  *InputProcess
-	Env    storage.ConfigEnv
-	Logger log.ILogger
-	wg     *sync.WaitGroup
-	hooks  Hooks
+	input         *InputProcess
+	snapshotCache *snapshotCache
+	clips         *eventClips
+	clipSlots     chan struct{}
+	Env           storage.ConfigEnv
+	volumes       *storage.Volumes
+	maintenance   *maintenanceStore
+	Logger        log.ILogger
+	wg            *sync.WaitGroup
+	hooks         Hooks
 
 	sleep   time.Duration
 	prevSeg *hls.Segment
@@ -67,11 +74,16 @@ func newRecorder(m *Monitor) *Recorder {
 		runSession: runRecording,
 		NewProcess: ffmpeg.NewProcess,
 
-		input:  m.mainInput,
-		Env:    m.Env,
-		Logger: m.Logger,
-		wg:     &m.WG,
-		hooks:  m.hooks,
+		input:         m.mainInput,
+		snapshotCache: &m.snapshotCache,
+		clips:         &eventClips{},
+		clipSlots:     m.eventClipSlots,
+		Env:           m.Env,
+		volumes:       m.volumes,
+		maintenance:   m.maintenance,
+		Logger:        m.Logger,
+		wg:            &m.WG,
+		hooks:         m.hooks,
 
 		sleep: 3 * time.Second,
 	}
@@ -100,11 +112,26 @@ func (r *Recorder) start(ctx context.Context) {
 			return
 
 		case event := <-r.eventChan: // Incomming events.
-			r.hooks.Event(r, &event)
+			if r.InMaintenance(time.Now()) {
+				r.logf(log.LevelDebug, "in maintenance, skipping event hooks")
+			} else {
+				r.hooks.Event(r, &event)
+			}
 			r.eventsLock.Lock()
 			*r.events = append(*r.events, event)
 			r.eventsLock.Unlock()
 
+			if r.Config.EventClipEnabled() {
+				r.startEventClip(ctx, event)
+			}
+			if len(event.Detections) != 0 {
+				r.wg.Add(1)
+				go func(event storage.Event) {
+					defer r.wg.Done()
+					r.saveEventSnapshot(ctx, event)
+				}(event)
+			}
+
 			end := event.Time.Add(event.RecDuration)
 			if end.After(timerEnd) {
 				timerEnd = end
@@ -140,7 +167,7 @@ func (r *Recorder) start(ctx context.Context) {
 func (r *Recorder) runRecordingSession(ctx context.Context) {
 	defer r.logf(log.LevelDebug, "session stopped")
 	for {
-		err := r.runSession(ctx, r)
+		err := r.runSessionRecover(ctx)
 		if err != nil {
 			if !errors.Is(err, context.Canceled) {
 				r.logf(log.LevelError, "recording crashed: %v", err)
@@ -167,6 +194,39 @@ func (r *Recorder) runRecordingSession(ctx context.Context) {
 
 type runRecordingFunc func(context.Context, *Recorder) error
 
+// ErrRecorderPanic the recording session panicked.
+var ErrRecorderPanic = errors.New("panic")
+
+// runSessionRecover turns a panic into a error, the files are closed
+// by the deferred calls and the session is restarted. The data file of
+// the interrupted recording is written by the startup recovery.
+func (r *Recorder) runSessionRecover(ctx context.Context) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%w: %v\n%s", ErrRecorderPanic, rec, debug.Stack())
+		}
+	}()
+	return r.runSession(ctx, r)
+}
+
+// recordingsDir returns the recordings directory of the storage
+// volume that the next recording should be saved to.
+func (r *Recorder) recordingsDir() (string, error) {
+	if r.volumes == nil {
+		return r.Env.RecordingsDir(), nil
+	}
+
+	pinned := r.Config.StorageVolume()
+	dir, err := r.volumes.RecordingsDir(pinned)
+	if err != nil {
+		return "", fmt.Errorf("select storage volume: %w", err)
+	}
+	if pinned != "" && filepath.Dir(dir) != pinned {
+		r.logf(log.LevelWarning, "storage volume %v unavailable, using %v", pinned, filepath.Dir(dir))
+	}
+	return dir, nil
+}
+
 func runRecording(ctx context.Context, r *Recorder) error {
 	timestampOffsetInt, err := strconv.Atoi(r.Config.TimestampOffset())
 	if err != nil {
@@ -179,6 +239,10 @@ func runRecording(ctx context.Context, r *Recorder) error {
 	}
 
 	firstSegment, err := muxer.NextSegment(r.prevSeg)
+	if errors.Is(err, hls.ErrFellBehind) {
+		r.logf(log.LevelWarning, "recorder fell behind, segments were dropped")
+		firstSegment, err = muxer.NextSegment(nil)
+	}
 	if err != nil {
 		return fmt.Errorf("first segment: %w", err)
 	}
@@ -186,9 +250,14 @@ func runRecording(ctx context.Context, r *Recorder) error {
 	offset := 0 + time.Duration(timestampOffsetInt)*time.Millisecond
 	startTime := firstSegment.StartTime.Add(-offset)
 
+	recordingsDir, err := r.recordingsDir()
+	if err != nil {
+		return err
+	}
+
 	monitorID := r.Config.ID()
 	fileDir := filepath.Join(
-		r.Env.RecordingsDir(),
+		recordingsDir,
 		startTime.Format("2006/01/02/")+monitorID,
 	)
 	filePath := filepath.Join(
@@ -213,17 +282,28 @@ func runRecording(ctx context.Context, r *Recorder) error {
 
 	videoTrack := muxer.VideoTrack()
 	audioTrack := muxer.AudioTrack()
-	go r.generateThumbnail(filePath, firstSegment, videoTrack)
+
+	// The monitor waits for the thumbnail and the data file
+	// when it's stopped, so shutdown doesn't interrupt them.
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.generateThumbnail(filePath, firstSegment, videoTrack)
+	}()
 
 	prevSeg, endTime, err := generateVideo(
-		ctx, filePath, muxer.NextSegment, firstSegment, videoTrack, audioTrack, videoLength)
+		ctx, filePath, r.Config.ID(), muxer.NextSegment, firstSegment, videoTrack, audioTrack, videoLength)
 	if err != nil {
 		return fmt.Errorf("write video: %w", err)
 	}
 	r.prevSeg = prevSeg
 	r.logf(log.LevelInfo, "video generated: %v", basePath)
 
-	go r.saveRecording(filePath, startTime, *endTime)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.saveRecording(filePath, startTime, *endTime, videoTrack)
+	}()
 
 	return nil
 }
@@ -236,6 +316,7 @@ type nextSegmentFunc func(*hls.Segment) (*hls.Segment, error)
 func generateVideo( //nolint:funlen
 	ctx context.Context,
 	filePath string,
+	monitorID string,
 	nextSegment nextSegmentFunc,
 	firstSegment *hls.Segment,
 	videoTrack *gortsplib.TrackH264,
@@ -256,12 +337,20 @@ func generateVideo( //nolint:funlen
 	}
 	defer meta.Close()
 
-	mdat, err := os.OpenFile(mdatPath, os.O_CREATE|os.O_WRONLY, 0o600)
+	// Encrypted if the monitor has a key.
+	mdat, err := crypt.Create(mdatPath, monitorID)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer mdat.Close()
 
+	// Flush the video to disk before the data file is written.
+	// The mdat file first, samples must never reference missing data.
+	defer func() {
+		mdat.Sync() //nolint:errcheck
+		meta.Sync() //nolint:errcheck
+	}()
+
 	var audioConfig []byte
 	if audioTrack != nil {
 		audioConfig, err = audioTrack.Config.Marshal()
@@ -361,12 +450,20 @@ func (r *Recorder) generateThumbnail(
 		return
 	}
 	r.logf(log.LevelDebug, "thumbnail generated: %v", filepath.Base(thumbPath))
+
+	if r.Env.RecordingEncryption.PlainThumbnails {
+		return
+	}
+	if err := crypt.EncryptFile(thumbPath, r.Config.ID()); err != nil {
+		r.logf(log.LevelError, "encrypt thumbnail: %v", err)
+	}
 }
 
 func (r *Recorder) saveRecording(
 	filePath string,
 	startTime time.Time,
 	endTime time.Time,
+	videoTrack *gortsplib.TrackH264,
 ) {
 	r.logf(log.LevelInfo, "saving recording: %v", filepath.Base(filePath))
 
@@ -375,25 +472,33 @@ func (r *Recorder) saveRecording(
 	r.eventsLock.Unlock()
 
 	data := storage.RecordingData{
-		Start:  startTime,
-		End:    endTime,
-		Events: events,
+		Start:    startTime,
+		End:      endTime,
+		Events:   events,
+		Activity: storage.NewActivityIndex(startTime, endTime, events),
 	}
-	json, err := json.MarshalIndent(data, "", "    ")
-	if err != nil {
-		r.logf(log.LevelError, "marshal event data: %w", err)
-		return
+	if r.maintenance != nil {
+		reason, during := r.maintenance.during(r.Config.ID(), startTime, endTime)
+		data.Maintenance = during
+		data.MaintenanceReason = reason
+	}
+	if videoTrack != nil {
+		var sps h264.SPS
+		if err := sps.Unmarshal(videoTrack.SafeSPS()); err == nil {
+			data.Width = sps.Width()
+			data.Height = sps.Height()
+		}
 	}
+	data.Summarize()
 
-	dataPath := filePath + ".json"
-	if err := os.WriteFile(dataPath, json, 0o600); err != nil {
+	if err := storage.WriteRecordingData(filePath, data); err != nil {
 		r.logf(log.LevelError, "write event data: %v", err)
 		return
 	}
 
 	go r.hooks.RecSaved(r, filePath, data)
 
-	r.logf(log.LevelInfo, "recording saved: %v", filepath.Base(dataPath))
+	r.logf(log.LevelInfo, "recording saved: %v", filepath.Base(filePath)+".json")
 }
 
 func (r *Recorder) sendEvent(ctx context.Context, event storage.Event) error {
@@ -99,6 +99,10 @@ func (m *mockMuxer) NextSegment(_ *hls.Segment) (*hls.Segment, error) {
 	return seg, nil
 }
 
+func (m *mockMuxer) LatestSegment() (*hls.Segment, error) {
+	return &hls.Segment{ID: uint64(m.segCount)}, nil
+}
+
 func (m *mockMuxer) WaitForSegFinalized() {}
 
 func TestStartRecorder(t *testing.T) {
@@ -402,6 +406,16 @@ func TestWriteThumbnail(t *testing.T) {
 	})*/
 }
 
+func TestRunSessionRecover(t *testing.T) {
+	r := newTestRecorder(t)
+	r.runSession = func(context.Context, *Recorder) error {
+		panic("x")
+	}
+	err := r.runSessionRecover(context.Background())
+	require.ErrorIs(t, err, ErrRecorderPanic)
+	require.Contains(t, err.Error(), "panic: x")
+}
+
 func TestSaveRecording(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		r := newTestRecorder(t)
@@ -436,7 +450,7 @@ func TestSaveRecording(t *testing.T) {
 		tempdir := r.Env.TempDir
 		filePath := tempdir + "file"
 
-		r.saveRecording(filePath, start, end)
+		r.saveRecording(filePath, start, end, nil)
 
 		b, err := os.ReadFile(filePath + ".json")
 		require.NoError(t, err)
@@ -448,7 +462,8 @@ func TestSaveRecording(t *testing.T) {
 		expected := `{"start":"0001-01-01T00:01:00Z","end":"0001-01-01T00:11:00Z",` +
 			`"events":[{"time":"0001-01-01T00:02:00Z","detections":` +
 			`[{"label":"10","score":9,"region":{"rect":[1,2,3,4],` +
-			`"polygon":[[5,6],[7,8]]}}],"duration":11}]}`
+			`"polygon":[[5,6],[7,8]]}}],"duration":11}],"activity":[[60,61]],` +
+			`"version":1,"labels":["10"],"duration":600000000000}`
 
 		require.Equal(t, actual, expected)
 	})
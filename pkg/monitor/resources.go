@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Resource limit errors.
+var (
+	ErrInvalidThreads     = errors.New("threads must be a positive integer")
+	ErrInvalidNiceness    = errors.New("niceness must be a integer between -20 and 19")
+	ErrInvalidCPUAffinity = errors.New("invalid cpu affinity")
+)
+
+// Threads returns the FFmpeg encoder thread cap, 0 if unset or invalid.
+func (c Config) Threads() int {
+	threads, err := strconv.Atoi(c.v["threads"])
+	if err != nil || threads <= 0 {
+		return 0
+	}
+	return threads
+}
+
+// Niceness returns the scheduling priority of the input
+// processes, 0 if unset or invalid. Range is -20 to 19.
+func (c Config) Niceness() int {
+	niceness, err := strconv.Atoi(c.v["niceness"])
+	if err != nil || niceness < -20 || niceness > 19 {
+		return 0
+	}
+	return niceness
+}
+
+// CPUAffinity returns the list of CPUs that the input processes
+// are pinned to, for example "0,2-3". Empty if unset or invalid.
+func (c Config) CPUAffinity() string {
+	cpus := strings.ReplaceAll(c.v["cpuAffinity"], " ", "")
+	if err := checkCPUList(cpus); err != nil {
+		return ""
+	}
+	return cpus
+}
+
+// CheckResourceLimits returns a error if
+// a resource limit is set but invalid.
+func (c Config) CheckResourceLimits() error {
+	if v := c.v["threads"]; v != "" {
+		if threads, err := strconv.Atoi(v); err != nil || threads <= 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidThreads, v)
+		}
+	}
+	if v := c.v["niceness"]; v != "" {
+		if niceness, err := strconv.Atoi(v); err != nil || niceness < -20 || niceness > 19 {
+			return fmt.Errorf("%w: %v", ErrInvalidNiceness, v)
+		}
+	}
+	return checkCPUList(strings.ReplaceAll(c.v["cpuAffinity"], " ", ""))
+}
+
+// checkCPUList validates a comma separated list of CPU numbers and ranges.
+func checkCPUList(cpus string) error {
+	if cpus == "" {
+		return nil
+	}
+	for _, part := range strings.Split(cpus, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidCPUAffinity, cpus)
+		}
+		if !isRange {
+			continue
+		}
+		end, err := strconv.Atoi(last)
+		if err != nil || end < start {
+			return fmt.Errorf("%w: %v", ErrInvalidCPUAffinity, cpus)
+		}
+	}
+	return nil
+}
+
+// wrapCommand runs the command through taskset and nice if the
+// CPU affinity or niceness is set. The limits are inherited by
+// every thread, they're set before the binary is executed.
+func (c Config) wrapCommand(bin string, args []string) (string, []string) {
+	if cpus := c.CPUAffinity(); cpus != "" {
+		args = append([]string{"-c", cpus, bin}, args...)
+		bin = "taskset"
+	}
+	if niceness := c.Niceness(); niceness != 0 {
+		args = append([]string{"-n", strconv.Itoa(niceness), bin}, args...)
+		bin = "nice"
+	}
+	return bin, args
+}
+
+// Resources is the resource usage of the monitor processes.
+type Resources struct {
+	// CPU usage in percent of one core.
+	CPU float64 `json:"cpu"`
+
+	// Resident memory in bytes.
+	RSS uint64 `json:"rss"`
+}
+
+// The CPU usage is averaged over at least this long.
+const usageSampleInterval = 5 * time.Second
+
+// processUsage samples the resource usage of the running input process.
+type processUsage struct {
+	proc       *process.Process
+	usage      Resources
+	sampleTime time.Time
+	mu         sync.Mutex
+}
+
+func (u *processUsage) started(pid int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.proc = &process.Process{Pid: int32(pid)}
+	u.usage = Resources{}
+	u.sampleTime = time.Now()
+
+	// The first call only stores the CPU times.
+	u.proc.Percent(0) //nolint:errcheck
+}
+
+func (u *processUsage) stopped() {
+	u.mu.Lock()
+	u.proc = nil
+	u.usage = Resources{}
+	u.mu.Unlock()
+}
+
+// get returns the cached usage if it's newer than the sample interval.
+func (u *processUsage) get(now time.Time) Resources {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.proc == nil || now.Sub(u.sampleTime) < usageSampleInterval {
+		return u.usage
+	}
+	u.sampleTime = now
+
+	if cpu, err := u.proc.Percent(0); err == nil {
+		u.usage.CPU = cpu
+	}
+	if mem, err := u.proc.MemoryInfo(); err == nil {
+		u.usage.RSS = mem.RSS
+	}
+	return u.usage
+}
+
+// MonitorResources returns the resource usage of all running monitors.
+func (m *Manager) MonitorResources() map[string]Resources {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	resources := make(map[string]Resources)
+	for id, monitor := range m.runningMonitors {
+		resources[id] = monitor.resources(now)
+	}
+	return resources
+}
+
+// resources returns the combined usage of the input processes.
+func (m *Monitor) resources(now time.Time) Resources {
+	var total Resources
+	for _, input := range []*InputProcess{m.mainInput, m.subInput} {
+		if input == nil {
+			continue
+		}
+		usage := input.usage.get(now)
+		total.CPU += usage.CPU
+		total.RSS += usage.RSS
+	}
+	return total
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckResourceLimits(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   RawConfig
+		expected error
+	}{
+		{"empty", RawConfig{}, nil},
+		{"ok", RawConfig{"threads": "2", "niceness": "-5", "cpuAffinity": "0, 2-3"}, nil},
+		{"threadsZero", RawConfig{"threads": "0"}, ErrInvalidThreads},
+		{"threadsNaN", RawConfig{"threads": "x"}, ErrInvalidThreads},
+		{"nicenessMin", RawConfig{"niceness": "-21"}, ErrInvalidNiceness},
+		{"nicenessMax", RawConfig{"niceness": "20"}, ErrInvalidNiceness},
+		{"cpuNegative", RawConfig{"cpuAffinity": "-1"}, ErrInvalidCPUAffinity},
+		{"cpuRange", RawConfig{"cpuAffinity": "3-2"}, ErrInvalidCPUAffinity},
+		{"cpuEmpty", RawConfig{"cpuAffinity": "0,,1"}, ErrInvalidCPUAffinity},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewConfig(tc.config).CheckResourceLimits()
+			require.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestWrapCommand(t *testing.T) {
+	args := []string{"-i", "x"}
+	t.Run("unset", func(t *testing.T) {
+		bin, actual := NewConfig(RawConfig{}).wrapCommand("ffmpeg", args)
+		require.Equal(t, "ffmpeg", bin)
+		require.Equal(t, args, actual)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		c := NewConfig(RawConfig{"niceness": "x", "cpuAffinity": "x"})
+		bin, actual := c.wrapCommand("ffmpeg", args)
+		require.Equal(t, "ffmpeg", bin)
+		require.Equal(t, args, actual)
+	})
+	t.Run("both", func(t *testing.T) {
+		c := NewConfig(RawConfig{"niceness": "10", "cpuAffinity": "0, 2-3"})
+		bin, actual := c.wrapCommand("ffmpeg", args)
+		require.Equal(t, "nice", bin)
+		expected := []string{"-n", "10", "taskset", "-c", "0,2-3", "ffmpeg", "-i", "x"}
+		require.Equal(t, expected, actual)
+	})
+}
+
+func TestProcessUsage(t *testing.T) {
+	var u processUsage
+	now := time.Now()
+	require.Equal(t, Resources{}, u.get(now))
+
+	u.started(os.Getpid())
+	require.Equal(t, Resources{}, u.get(time.Now()))
+
+	usage := u.get(time.Now().Add(usageSampleInterval))
+	require.NotZero(t, usage.RSS)
+
+	u.stopped()
+	require.Equal(t, Resources{}, u.get(time.Now().Add(2*usageSampleInterval)))
+}
@@ -0,0 +1,378 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedule weekly time windows.
+//
+// Format: "<days> <start>-<end>", multiple windows are separated by ";".
+// Days are "mon" to "sun", a range "mon-fri", a list "sat,sun" or "*".
+// Time is "hh:mm" in local time and a window may cross midnight.
+//
+// Example: "mon-fri 18:00-07:00; sat,sun 00:00-24:00"
+type Schedule struct {
+	windows []scheduleWindow
+}
+
+type scheduleWindow struct {
+	days  [7]bool // Indexed by time.Weekday.
+	start int     // Minutes since midnight.
+	end   int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Errors.
+var (
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	ErrInvalidDay      = errors.New("invalid day")
+	ErrInvalidTime     = errors.New("invalid time")
+)
+
+// ParseSchedule parses a schedule, returns nil if the input is empty.
+func ParseSchedule(input string) (*Schedule, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	var schedule Schedule
+	for _, rawWindow := range strings.Split(input, ";") {
+		fields := strings.Fields(rawWindow)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSchedule, rawWindow)
+		}
+
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		start, end, err := parseTimeRange(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		schedule.windows = append(schedule.windows, scheduleWindow{
+			days:  days,
+			start: start,
+			end:   end,
+		})
+	}
+	return &schedule, nil
+}
+
+func parseDays(input string) ([7]bool, error) {
+	var days [7]bool
+	if input == "*" {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, part := range strings.Split(strings.ToLower(input), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, exist := weekdays[first]
+		if !exist {
+			return days, fmt.Errorf("%w: %q", ErrInvalidDay, first)
+		}
+		if !isRange {
+			days[start] = true
+			continue
+		}
+		end, exist := weekdays[last]
+		if !exist {
+			return days, fmt.Errorf("%w: %q", ErrInvalidDay, last)
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeRange(input string) (int, int, error) {
+	rawStart, rawEnd, ok := strings.Cut(input, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, input)
+	}
+	start, err := parseClock(rawStart)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(rawEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("%w: empty window: %q", ErrInvalidTime, input)
+	}
+	return start, end, nil
+}
+
+// Returns minutes since midnight, "24:00" is allowed.
+func parseClock(input string) (int, error) {
+	rawHour, rawMinute, ok := strings.Cut(input, ":")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, input)
+	}
+	hour, err := strconv.Atoi(rawHour)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, input)
+	}
+	minute, err := strconv.Atoi(rawMinute)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, input)
+	}
+	minutes := hour*60 + minute
+	if hour < 0 || minute < 0 || minute > 59 || minutes > 24*60 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, input)
+	}
+	return minutes, nil
+}
+
+// Contains returns true if the time is within the schedule.
+// A nil schedule contains all times.
+func (s *Schedule) Contains(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// Window crosses midnight.
+		if w.days[today] && minute >= w.start {
+			return true
+		}
+		if w.days[yesterday] && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange returns the next time the schedule changes between
+// active and inactive. Returns false if it never changes.
+func (s *Schedule) NextChange(t time.Time) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	state := s.Contains(t)
+	next := t.Truncate(time.Minute)
+	for i := 0; i <= 7*24*60; i++ {
+		next = next.Add(time.Minute)
+		if s.Contains(next) != state {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Arm override states.
+const (
+	ArmAuto   = "auto"
+	ArmArm    = "arm"
+	ArmDisarm = "disarm"
+)
+
+type armOverride struct {
+	state string
+	until time.Time
+}
+
+// armOverrides temporary arm and disarm overrides by monitor ID.
+type armOverrides struct {
+	overrides map[string]armOverride
+	mu        sync.Mutex
+}
+
+func newArmOverrides() *armOverrides {
+	return &armOverrides{overrides: make(map[string]armOverride)}
+}
+
+func (o *armOverrides) set(monitorID string, state string, until time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if state == ArmAuto {
+		delete(o.overrides, monitorID)
+		return
+	}
+	o.overrides[monitorID] = armOverride{state: state, until: until}
+}
+
+// get returns the active override state.
+func (o *armOverrides) get(monitorID string, now time.Time) (string, time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	override, exist := o.overrides[monitorID]
+	if !exist {
+		return ArmAuto, time.Time{}
+	}
+	if !override.until.IsZero() && !now.Before(override.until) {
+		delete(o.overrides, monitorID)
+		return ArmAuto, time.Time{}
+	}
+	return override.state, override.until
+}
+
+// Schedule config keys. The global schedule in the general
+// config is used if the monitor doesn't have its own.
+const (
+	recordScheduleKey = "recordSchedule"
+	armScheduleKey    = "armSchedule"
+)
+
+func (m *Monitor) scheduleValue(key string) string {
+	if value := m.Config.Get(key); value != "" {
+		return value
+	}
+	if m.general != nil {
+		return m.general.Get()[key]
+	}
+	return ""
+}
+
+// Armed returns true if events should be recorded. Temporary
+// overrides take precedence over the arm schedule.
+func (m *Monitor) Armed(now time.Time) bool {
+	if m.armOverrides != nil {
+		switch state, _ := m.armOverrides.get(m.Config.ID(), now); state {
+		case ArmArm:
+			return true
+		case ArmDisarm:
+			return false
+		}
+	}
+
+	schedule, err := ParseSchedule(m.scheduleValue(armScheduleKey))
+	if err != nil {
+		// Invalid schedules are rejected when saved, stay
+		// armed rather than silently dropping events.
+		return true
+	}
+	return schedule.Contains(now)
+}
+
+// runRecordSchedule sends continuous recording events while the
+// record schedule is active. Blocks until ctx is canceled.
+func (m *Monitor) runRecordSchedule(ctx context.Context) {
+	infinite := time.Duration(1<<63 - 62135596801)
+
+	schedule, err := ParseSchedule(m.scheduleValue(recordScheduleKey))
+	if err != nil {
+		m.logf(log.LevelError, "invalid record schedule, recording continuously: %v", err)
+		schedule = nil
+	}
+
+	for {
+		now := time.Now()
+		next, changes := schedule.NextChange(now)
+
+		if schedule.Contains(now) {
+			duration := infinite
+			if changes {
+				duration = next.Sub(now)
+			}
+			err := m.recorder.sendEvent(ctx, storage.Event{
+				Time:        now,
+				Trigger:     storage.TriggerContinuous,
+				RecDuration: duration,
+			})
+			if err != nil {
+				m.logf(log.LevelError, "could not start continuous recording: %v", err)
+			}
+		}
+		if !changes {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// ArmState arm state of a monitor.
+type ArmState struct {
+	Armed    bool      `json:"armed"`
+	Override string    `json:"override"`
+	Until    time.Time `json:"until"`
+}
+
+// ArmState returns the arm state of a running monitor.
+func (m *Manager) ArmState(id string) (ArmState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	monitor, exist := m.runningMonitors[id]
+	if !exist {
+		return ArmState{}, ErrNotExist
+	}
+
+	now := time.Now()
+	override, until := m.armOverrides.get(id, now)
+	return ArmState{
+		Armed:    monitor.Armed(now),
+		Override: override,
+		Until:    until,
+	}, nil
+}
+
+// ErrInvalidArmState invalid arm state.
+var ErrInvalidArmState = errors.New("invalid arm state")
+
+// SetArmOverride temporarily arms or disarms the monitor. A zero
+// duration lasts until changed. "auto" removes the override.
+func (m *Manager) SetArmOverride(id string, state string, duration time.Duration) error {
+	switch state {
+	case ArmAuto, ArmArm, ArmDisarm:
+	default:
+		return fmt.Errorf("%w: %v", ErrInvalidArmState, state)
+	}
+
+	m.mu.Lock()
+	_, exist := m.rawConfigs[id]
+	m.mu.Unlock()
+	if !exist {
+		return ErrNotExist
+	}
+
+	var until time.Time
+	if duration > 0 {
+		until = time.Now().Add(duration)
+	}
+	m.armOverrides.set(id, state, until)
+	return nil
+}
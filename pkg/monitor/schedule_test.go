@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 2001-01-01 is a Monday.
+func scheduleTime(day int, hour int, minute int) time.Time {
+	return time.Date(2001, 1, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseSchedule(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		schedule, err := ParseSchedule(" ")
+		require.NoError(t, err)
+		require.Nil(t, schedule)
+		require.True(t, schedule.Contains(scheduleTime(1, 0, 0)))
+	})
+
+	cases := map[string]struct {
+		input       string
+		expectedErr error
+	}{
+		"ok":          {"mon-fri 08:00-17:00; sat,sun 00:00-24:00", nil},
+		"wildcard":    {"* 22:00-06:00", nil},
+		"trailing":    {"mon 08:00-09:00;", nil},
+		"fields":      {"mon 08:00 09:00", ErrInvalidSchedule},
+		"day":         {"monday 08:00-09:00", ErrInvalidDay},
+		"dayRange":    {"mon-xyz 08:00-09:00", ErrInvalidDay},
+		"noRange":     {"mon 08:00", ErrInvalidTime},
+		"hour":        {"mon 25:00-26:00", ErrInvalidTime},
+		"minute":      {"mon 08:60-09:00", ErrInvalidTime},
+		"emptyWindow": {"mon 08:00-08:00", ErrInvalidTime},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSchedule(tc.input)
+			require.True(t, errors.Is(err, tc.expectedErr), "got: %v", err)
+		})
+	}
+}
+
+func TestScheduleContains(t *testing.T) {
+	schedule, err := ParseSchedule("mon-fri 18:00-07:00; sun 10:00-12:00")
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		time     time.Time
+		expected bool
+	}{
+		"monEvening":  {scheduleTime(1, 18, 0), true},
+		"monDay":      {scheduleTime(1, 12, 0), false},
+		"monMorning":  {scheduleTime(1, 6, 59), false},
+		"tueMorning":  {scheduleTime(2, 6, 59), true},
+		"tueEnd":      {scheduleTime(2, 7, 0), false},
+		"satMorning":  {scheduleTime(6, 6, 0), true},
+		"satEvening":  {scheduleTime(6, 18, 0), false},
+		"sunInside":   {scheduleTime(7, 11, 0), true},
+		"sunOutside":  {scheduleTime(7, 12, 0), false},
+		"friMidnight": {scheduleTime(5, 23, 59), true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, schedule.Contains(tc.time))
+		})
+	}
+}
+
+func TestScheduleNextChange(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		schedule, err := ParseSchedule("mon 08:00-17:00")
+		require.NoError(t, err)
+
+		next, changes := schedule.NextChange(scheduleTime(1, 9, 30))
+		require.True(t, changes)
+		require.Equal(t, scheduleTime(1, 17, 0), next)
+
+		next, changes = schedule.NextChange(scheduleTime(1, 17, 0))
+		require.True(t, changes)
+		require.Equal(t, scheduleTime(8, 8, 0), next)
+	})
+	t.Run("never", func(t *testing.T) {
+		schedule, err := ParseSchedule("* 00:00-24:00")
+		require.NoError(t, err)
+
+		_, changes := schedule.NextChange(scheduleTime(1, 9, 30))
+		require.False(t, changes)
+	})
+}
+
+func TestMonitorArmed(t *testing.T) {
+	newTestMonitor := func(armSchedule string) *Monitor {
+		return &Monitor{
+			Config: NewConfig(RawConfig{
+				"id":          "1",
+				"armSchedule": armSchedule,
+			}),
+			armOverrides: newArmOverrides(),
+		}
+	}
+	inside := scheduleTime(1, 9, 0)
+	outside := scheduleTime(1, 18, 0)
+
+	t.Run("noSchedule", func(t *testing.T) {
+		require.True(t, newTestMonitor("").Armed(outside))
+	})
+	t.Run("schedule", func(t *testing.T) {
+		m := newTestMonitor("mon 08:00-17:00")
+		require.True(t, m.Armed(inside))
+		require.False(t, m.Armed(outside))
+	})
+	t.Run("override", func(t *testing.T) {
+		m := newTestMonitor("mon 08:00-17:00")
+		m.armOverrides.set("1", ArmDisarm, inside.Add(time.Hour))
+		require.False(t, m.Armed(inside))
+		require.True(t, m.Armed(inside.Add(time.Hour)))
+
+		m.armOverrides.set("1", ArmArm, time.Time{})
+		require.True(t, m.Armed(outside))
+
+		m.armOverrides.set("1", ArmAuto, time.Time{})
+		require.False(t, m.Armed(outside))
+	})
+}
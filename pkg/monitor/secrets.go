@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"fmt"
+	"net/url"
+	"nvr/pkg/log"
+	"nvr/pkg/secret"
+	"strings"
+)
+
+// Config keys that are encrypted at rest, the input URLs
+// usually include the username and password of the camera.
+var secretKeys = []string{"mainInput", "subInput", "backupInput", "onvifPassword"}
+
+// encryptConfig returns a copy of the config with the secret values encrypted.
+func encryptConfig(c *secret.Cipher, rawConf RawConfig) (RawConfig, error) {
+	return encryptValues(c, rawConf["id"], rawConf)
+}
+
+// encryptValues encrypts the secret values of a copy
+// of the config. The values are bound to the scope.
+func encryptValues(c *secret.Cipher, scope string, rawConf RawConfig) (RawConfig, error) {
+	encrypted := make(RawConfig, len(rawConf))
+	for key, value := range rawConf {
+		encrypted[key] = value
+	}
+	for _, key := range secretKeys {
+		value, exist := rawConf[key]
+		if !exist {
+			continue
+		}
+		v, err := c.Encrypt(scope, key, value)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %v: %w", key, err)
+		}
+		encrypted[key] = v
+	}
+	return encrypted, nil
+}
+
+// decryptConfig decrypts the secret values in place. Values that cannot
+// be decrypted are kept encrypted, the monitor won't start until they
+// are re-entered. This happens if a backup is restored on another machine.
+func decryptConfig(c *secret.Cipher, rawConf RawConfig) {
+	decryptValues(c, rawConf["id"], rawConf)
+}
+
+func decryptValues(c *secret.Cipher, scope string, rawConf RawConfig) {
+	for _, key := range secretKeys {
+		value, exist := rawConf[key]
+		if !exist {
+			continue
+		}
+		v, err := c.Decrypt(scope, key, value)
+		if err != nil {
+			continue
+		}
+		rawConf[key] = v
+	}
+}
+
+// encryptedKeys returns the secret keys that couldn't be decrypted.
+func (c Config) encryptedKeys() []string {
+	var keys []string
+	for _, key := range secretKeys {
+		if secret.IsEncrypted(c.v[key]) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// RedactConfig returns a copy of the config with the passwords
+// replaced by log.RedactedPassword. The config can be set again
+// without changes, the redacted passwords are restored by MonitorSet.
+func RedactConfig(rawConf RawConfig) RawConfig {
+	redacted := make(RawConfig, len(rawConf))
+	for key, value := range rawConf {
+		redacted[key] = value
+	}
+	for _, key := range secretKeys {
+		value := redacted[key]
+		switch {
+		case value == "":
+		case key == "onvifPassword":
+			redacted[key] = log.RedactedPassword
+		default:
+			redacted[key] = log.RedactPasswords(value)
+		}
+	}
+	return redacted
+}
+
+// unredactConfig returns a copy of the config with
+// the redacted passwords restored from the old config.
+func unredactConfig(rawConf RawConfig, old RawConfig) RawConfig {
+	c := make(RawConfig, len(rawConf))
+	for key, value := range rawConf {
+		c[key] = value
+	}
+	for _, key := range secretKeys {
+		value := c[key]
+		switch {
+		case key == "onvifPassword":
+			if value == log.RedactedPassword {
+				c[key] = old[key]
+			}
+		case strings.Contains(value, ":"+log.RedactedPassword+"@"):
+			c[key] = strings.Replace(value,
+				":"+log.RedactedPassword+"@", ":"+urlPassword(old[key])+"@", 1)
+		}
+	}
+	return c
+}
+
+// urlPassword returns the escaped password of the URL.
+func urlPassword(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	_, password, _ := strings.Cut(u.User.String(), ":")
+	return password
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nvr/pkg/log"
+	"nvr/pkg/secret"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecrets(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "secret.key")
+	secrets, err := secret.NewCipher(storage.SecretStoreFile, keyPath)
+	require.NoError(t, err)
+
+	configDir := prepareDir(t)
+	configPath := filepath.Join(configDir, "x.json")
+	data := []byte(`{"id":"x","mainInput":"rtsp://admin:pass@x"}`)
+	require.NoError(t, os.WriteFile(configPath, data, 0o600))
+
+	newManager := func(secrets *secret.Cipher) *Manager {
+		manager, err := NewManager(
+			configDir,
+			storage.ConfigEnv{},
+			nil,
+			log.NewDummyLogger(),
+			nil,
+			nil,
+			secrets,
+			&Hooks{Migrate: func(RawConfig) error { return nil }},
+		)
+		require.NoError(t, err)
+		return manager
+	}
+
+	// Plain text values are encrypted on startup.
+	manager := newManager(secrets)
+	require.Equal(t, "rtsp://admin:pass@x", manager.rawConfigs["x"]["mainInput"])
+
+	saved := readConfig(t, configPath)
+	require.True(t, secret.IsEncrypted(saved["mainInput"]))
+	require.Equal(t, "x", saved["id"])
+
+	// And when set.
+	err = manager.MonitorSet("x", RawConfig{
+		"id":        "x",
+		"mainInput": "rtsp://admin:pass2@x",
+		"subInput":  "rtsp://admin:pass2@x/sub",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "rtsp://admin:pass2@x", manager.rawConfigs["x"]["mainInput"])
+
+	rawSaved, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(rawSaved), "pass2"))
+
+	// Reloaded.
+	manager = newManager(secrets)
+	require.Equal(t, "rtsp://admin:pass2@x/sub", manager.rawConfigs["x"]["subInput"])
+
+	// Wrong key.
+	otherSecrets, err := secret.NewCipher(
+		storage.SecretStoreFile, filepath.Join(t.TempDir(), "secret.key"))
+	require.NoError(t, err)
+
+	manager = newManager(otherSecrets)
+	c := NewConfig(manager.rawConfigs["x"])
+	require.Equal(t, []string{"mainInput", "subInput"}, c.encryptedKeys())
+
+	// The encrypted values are kept.
+	require.Equal(t, readConfig(t, configPath)["mainInput"], c.MainInput())
+	manager = newManager(secrets)
+	require.Equal(t, "rtsp://admin:pass2@x", manager.rawConfigs["x"]["mainInput"])
+}
+
+func TestRedactConfig(t *testing.T) {
+	c := RawConfig{
+		"id":            "x",
+		"mainInput":     "rtsp://admin:p%40ss@x/main",
+		"subInput":      "rtsp://x/sub",
+		"onvifPassword": "pass",
+	}
+	redacted := RedactConfig(c)
+	expected := RawConfig{
+		"id":            "x",
+		"mainInput":     "rtsp://admin:********@x/main",
+		"subInput":      "rtsp://x/sub",
+		"onvifPassword": "********",
+	}
+	require.Equal(t, expected, redacted)
+	require.Equal(t, "pass", c["onvifPassword"])
+
+	// Setting the redacted config restores the passwords.
+	require.Equal(t, c, unredactConfig(redacted, c))
+
+	// Changed values are kept.
+	redacted["mainInput"] = "rtsp://admin2:********@y/main"
+	redacted["onvifPassword"] = "new"
+	expected = RawConfig{
+		"id":            "x",
+		"mainInput":     "rtsp://admin2:p%40ss@y/main",
+		"subInput":      "rtsp://x/sub",
+		"onvifPassword": "new",
+	}
+	require.Equal(t, expected, unredactConfig(redacted, c))
+}
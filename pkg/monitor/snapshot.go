@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/video/hls"
+	"nvr/pkg/video/mp4muxer"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot is a JPEG image of a monitor.
+type Snapshot struct {
+	Image []byte
+	Time  time.Time
+}
+
+// ErrSnapshotUnavailable the monitor doesn't have a frame yet.
+var ErrSnapshotUnavailable = errors.New("snapshot unavailable")
+
+// Snapshot returns the most recent keyframe of a running monitor.
+func (m *Manager) Snapshot(ctx context.Context, id string) (*Snapshot, error) {
+	m.mu.Lock()
+	monitor, exist := m.runningMonitors[id]
+	m.mu.Unlock()
+	if !exist {
+		return nil, ErrNotExist
+	}
+	return monitor.snapshot(ctx)
+}
+
+// snapshot returns the first frame of the latest HLS segment
+// from the main input. Segments start with a keyframe, so the
+// image is at most one segment duration old. The image is
+// cached until the next segment.
+func (m *Monitor) snapshot(ctx context.Context) (*Snapshot, error) {
+	getMuxer := m.mainInput.serverPath.HLSMuxer
+	if getMuxer == nil {
+		return nil, ErrSnapshotUnavailable
+	}
+	muxer, err := getMuxer(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: get muxer: %w", ErrSnapshotUnavailable, err)
+	}
+
+	seg, err := muxer.LatestSegment()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSnapshotUnavailable, err)
+	}
+
+	img, err := m.snapshotCache.get(seg, func() ([]byte, error) {
+		var video bytes.Buffer
+		err := mp4muxer.GenerateThumbnailVideo(&video, seg, muxer.VideoTrack())
+		if err != nil {
+			return nil, fmt.Errorf("generate video: %w", err)
+		}
+		return encodeJPEG(ctx, m.Env.FFmpegBin, &video)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Image: img, Time: seg.StartTime}, nil
+}
+
+// encodeJPEG converts the first frame of the video to JPEG.
+func encodeJPEG(ctx context.Context, ffmpegBin string, video *bytes.Buffer) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, ffmpegBin,
+		"-threads", "1", "-loglevel", "error",
+		"-i", "-",
+		"-frames:v", "1", "-f", "image2", "-c:v", "mjpeg", "pipe:1",
+	)
+	var stdout bytes.Buffer
+	var stderr strings.Builder
+	cmd.Stdin = video
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("encode jpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// snapshotCache keeps the image of the latest segment.
+// Concurrent requests for the same segment are encoded once.
+type snapshotCache struct {
+	segment *hls.Segment
+	image   []byte
+	mu      sync.Mutex
+}
+
+func (c *snapshotCache) get(seg *hls.Segment, encode func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.segment == seg {
+		return c.image, nil
+	}
+	img, err := encode()
+	if err != nil {
+		return nil, err
+	}
+	c.segment = seg
+	c.image = img
+	return img, nil
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"nvr/pkg/video/hls"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotCache(t *testing.T) {
+	var c snapshotCache
+	encodeCount := 0
+	encode := func(img string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			encodeCount++
+			return []byte(img), nil
+		}
+	}
+
+	seg1 := &hls.Segment{ID: 1}
+	img, err := c.get(seg1, encode("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), img)
+
+	// Cached.
+	img, err = c.get(seg1, encode("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), img)
+	require.Equal(t, 1, encodeCount)
+
+	// New segment.
+	seg2 := &hls.Segment{ID: 2}
+	img, err = c.get(seg2, encode("c"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("c"), img)
+	require.Equal(t, 2, encodeCount)
+
+	// Errors are not cached.
+	errEncode := errors.New("x")
+	seg3 := &hls.Segment{ID: 3}
+	_, err = c.get(seg3, func() ([]byte, error) { return nil, errEncode })
+	require.ErrorIs(t, err, errEncode)
+	img, err = c.get(seg3, encode("d"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("d"), img)
+}
+
+func TestManagerSnapshot(t *testing.T) {
+	t.Run("notExist", func(t *testing.T) {
+		m := &Manager{runningMonitors: make(monitors)}
+		_, err := m.Snapshot(context.Background(), "x")
+		require.ErrorIs(t, err, ErrNotExist)
+	})
+	t.Run("notStarted", func(t *testing.T) {
+		m := &Manager{runningMonitors: monitors{
+			"x": {mainInput: &InputProcess{}},
+		}}
+		_, err := m.Snapshot(context.Background(), "x")
+		require.ErrorIs(t, err, ErrSnapshotUnavailable)
+	})
+}
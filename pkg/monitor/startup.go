@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StartAfter returns the IDs of the monitors that
+// should be started before this monitor.
+func (c Config) StartAfter() []string {
+	var ids []string
+	for _, id := range strings.Split(c.v["startAfter"], ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// startOrder returns the monitor IDs sorted by ID and with every monitor
+// placed after the monitors in its "startAfter" field. Unknown
+// dependencies are ignored and dependency cycles are broken.
+func startOrder(configs RawConfigs) []string {
+	ids := make([]string, 0, len(configs))
+	for id := range configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	order := make([]string, 0, len(ids))
+	visited := make(map[string]bool)
+
+	var visit func(string)
+	visit = func(id string) {
+		if _, exist := configs[id]; !exist || visited[id] {
+			return
+		}
+		visited[id] = true
+		for _, dep := range NewConfig(configs[id]).StartAfter() {
+			visit(dep)
+		}
+		order = append(order, id)
+	}
+	for _, id := range ids {
+		visit(id)
+	}
+	return order
+}
+
+// StartMonitors starts all monitors in dependency order. If a startup
+// batch size is set, enabled monitors are started in batches with
+// the startup delay in between. Blocks until all monitors have
+// been started, ctx is canceled or StopMonitors is called.
+func (m *Manager) StartMonitors(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m.mu.Lock()
+	m.startCancel = cancel
+	order := startOrder(m.rawConfigs)
+	m.mu.Unlock()
+
+	batchSize := m.env.StartupBatchSize
+	delay := time.Duration(m.env.StartupDelay) * time.Second
+
+	started := 0
+	for _, id := range order {
+		m.mu.Lock()
+		rawConf, exist := m.rawConfigs[id]
+		enabled := exist && NewConfig(rawConf).enabled()
+		m.mu.Unlock()
+
+		if enabled && batchSize > 0 && started != 0 && started%batchSize == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		m.mu.Lock()
+		if ctx.Err() != nil {
+			m.mu.Unlock()
+			return
+		}
+		_, running := m.runningMonitors[id]
+		if _, exist := m.rawConfigs[id]; exist && !running {
+			m.unsafeStartMonitor(id)
+		}
+		m.mu.Unlock()
+
+		if enabled {
+			started++
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartOrder(t *testing.T) {
+	cases := map[string]struct {
+		configs  RawConfigs
+		expected []string
+	}{
+		"sorted": {
+			RawConfigs{"3": {}, "1": {}, "2": {}},
+			[]string{"1", "2", "3"},
+		},
+		"dependency": {
+			RawConfigs{
+				"1": {"startAfter": "3"},
+				"2": {},
+				"3": {"startAfter": " 2 "},
+			},
+			[]string{"2", "3", "1"},
+		},
+		"unknown": {
+			RawConfigs{"1": {"startAfter": "x,"}, "2": {}},
+			[]string{"1", "2"},
+		},
+		"cycle": {
+			RawConfigs{"1": {"startAfter": "2"}, "2": {"startAfter": "1"}},
+			[]string{"2", "1"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, startOrder(tc.configs))
+		})
+	}
+}
+
+func TestStartMonitorsDisabled(t *testing.T) {
+	manager := &Manager{
+		rawConfigs: RawConfigs{
+			"1": {"id": "1", "enable": "false"},
+			"2": {"id": "2", "enable": "false"},
+		},
+		runningMonitors: make(monitors),
+		env:             storage.ConfigEnv{StartupBatchSize: 1, StartupDelay: 3600},
+		logger:          log.NewDummyLogger(),
+	}
+
+	// Disabled monitors are not counted in the batches.
+	manager.StartMonitors(context.Background())
+	require.Len(t, manager.runningMonitors, 2)
+	manager.StopMonitors()
+}
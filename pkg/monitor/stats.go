@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"nvr/pkg/video"
+	"sync"
+	"time"
+)
+
+// Number of samples kept of each stream, a day at a one minute interval.
+const streamStatsSize = 24 * 60
+
+// StreamSample is a sample of a live stream.
+type StreamSample struct {
+	Time  time.Time `json:"time"`
+	Ready bool      `json:"ready"`
+
+	// Zero if the stream isn't ready.
+	Bitrate int64   `json:"bitrate"` // Bits per second.
+	FPS     float64 `json:"fps"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+}
+
+// StreamStats is the sample history of a live stream, oldest first.
+type StreamStats struct {
+	Samples []StreamSample `json:"samples"`
+
+	// Bitrate of the ready samples, zero if there are none.
+	AvgBitrate int64 `json:"avgBitrate"`
+	MinBitrate int64 `json:"minBitrate"`
+	MaxBitrate int64 `json:"maxBitrate"`
+
+	// Number of times the stream went from ready to not ready.
+	Drops int `json:"drops"`
+}
+
+// Stats of the live streams of a monitor.
+type Stats struct {
+	Main StreamStats  `json:"main"`
+	Sub  *StreamStats `json:"sub,omitempty"`
+}
+
+// streamStats samples the renditions of the monitors and keeps
+// a rolling history of each stream, keyed by the path name.
+type streamStats struct {
+	size       int
+	renditions func(ctx context.Context, monitorID string) []video.Rendition
+
+	history map[string][]StreamSample
+	mu      sync.Mutex
+}
+
+func newStreamStats(renditions func(context.Context, string) []video.Rendition) *streamStats {
+	return &streamStats{
+		size:       streamStatsSize,
+		renditions: renditions,
+		history:    make(map[string][]StreamSample),
+	}
+}
+
+// sample adds a sample of each stream of the monitors and
+// deletes the history of monitors that aren't in the list.
+func (s *streamStats) sample(ctx context.Context, monitorIDs []string, now time.Time) {
+	samples := make(map[string]StreamSample)
+	for _, id := range monitorIDs {
+		for _, r := range s.renditions(ctx, id) {
+			samples[r.Path] = StreamSample{
+				Time:    now,
+				Ready:   r.Ready,
+				Bitrate: r.Bitrate,
+				FPS:     r.FPS,
+				Width:   r.Width,
+				Height:  r.Height,
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	isMonitor := make(map[string]bool)
+	for _, id := range monitorIDs {
+		isMonitor[video.PathName(id, false)] = true
+		isMonitor[video.PathName(id, true)] = true
+	}
+	for path := range s.history {
+		if !isMonitor[path] {
+			delete(s.history, path)
+		}
+	}
+	// A stream that disappeared isn't ready.
+	for path := range s.history {
+		if _, exist := samples[path]; !exist {
+			samples[path] = StreamSample{Time: now}
+		}
+	}
+	for path, sample := range samples {
+		history := append(s.history[path], sample)
+		if len(history) > s.size {
+			history = history[len(history)-s.size:]
+		}
+		s.history[path] = history
+	}
+}
+
+// stats returns the statistics of a stream, false if it has no samples.
+func (s *streamStats) stats(pathName string) (StreamStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history, exist := s.history[pathName]
+	if !exist {
+		return StreamStats{}, false
+	}
+
+	stats := StreamStats{Samples: append([]StreamSample(nil), history...)}
+	var sum int64
+	var ready int64
+	for i, sample := range history {
+		if i > 0 && history[i-1].Ready && !sample.Ready {
+			stats.Drops++
+		}
+		if !sample.Ready {
+			continue
+		}
+		if ready == 0 || sample.Bitrate < stats.MinBitrate {
+			stats.MinBitrate = sample.Bitrate
+		}
+		if sample.Bitrate > stats.MaxBitrate {
+			stats.MaxBitrate = sample.Bitrate
+		}
+		sum += sample.Bitrate
+		ready++
+	}
+	if ready != 0 {
+		stats.AvgBitrate = sum / ready
+	}
+	return stats, true
+}
+
+// RunStreamStats samples the live streams of the running
+// monitors every interval until the context is canceled.
+func (m *Manager) RunStreamStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			ids := make([]string, 0, len(m.runningMonitors))
+			for id, monitor := range m.runningMonitors {
+				// Disabled monitors have no streams.
+				if monitor.Config.enabled() {
+					ids = append(ids, id)
+				}
+			}
+			m.mu.Unlock()
+			m.streamStats.sample(ctx, ids, now)
+		}
+	}
+}
+
+// StreamStats returns the live stream statistics of a
+// monitor, false if the monitor wasn't sampled yet.
+func (m *Manager) StreamStats(monitorID string) (Stats, bool) {
+	main, exist := m.streamStats.stats(video.PathName(monitorID, false))
+	if !exist {
+		return Stats{}, false
+	}
+	stats := Stats{Main: main}
+	if sub, exist := m.streamStats.stats(video.PathName(monitorID, true)); exist {
+		stats.Sub = &sub
+	}
+	return stats, true
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"nvr/pkg/video"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamStats(t *testing.T) {
+	renditions := map[string][]video.Rendition{}
+	s := newStreamStats(func(_ context.Context, id string) []video.Rendition {
+		return renditions[id]
+	})
+	s.size = 3
+
+	ready := func(bitrate int64) []video.Rendition {
+		return []video.Rendition{
+			{Path: "m1", Ready: true, Bitrate: bitrate, FPS: 25, Width: 1920, Height: 1080},
+			{Path: "m1_sub", Ready: true, Bitrate: bitrate / 10},
+		}
+	}
+	t0 := time.Unix(0, 0).UTC()
+	t1, t2, t3 := t0.Add(time.Minute), t0.Add(2*time.Minute), t0.Add(3*time.Minute)
+
+	renditions["m1"] = ready(4000)
+	s.sample(context.Background(), []string{"m1"}, t0)
+	renditions["m1"] = ready(1000)
+	s.sample(context.Background(), []string{"m1"}, t1)
+	// The sub stream disappeared.
+	renditions["m1"] = []video.Rendition{{Path: "m1"}}
+	s.sample(context.Background(), []string{"m1"}, t2)
+	renditions["m1"] = ready(2000)
+	s.sample(context.Background(), []string{"m1"}, t3)
+
+	// The first sample was rotated out.
+	stats, exist := s.stats("m1")
+	require.True(t, exist)
+	expected := StreamStats{
+		Samples: []StreamSample{
+			{Time: t1, Ready: true, Bitrate: 1000, FPS: 25, Width: 1920, Height: 1080},
+			{Time: t2},
+			{Time: t3, Ready: true, Bitrate: 2000, FPS: 25, Width: 1920, Height: 1080},
+		},
+		AvgBitrate: 1500,
+		MinBitrate: 1000,
+		MaxBitrate: 2000,
+		Drops:      1,
+	}
+	require.Equal(t, expected, stats)
+
+	sub, exist := s.stats("m1_sub")
+	require.True(t, exist)
+	require.Equal(t, StreamSample{Time: t2}, sub.Samples[1])
+	require.Equal(t, 1, sub.Drops)
+
+	// Deleted monitors are removed.
+	s.sample(context.Background(), nil, t3)
+	_, exist = s.stats("m1")
+	require.False(t, exist)
+}
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamTestRequest is a candidate input of a monitor.
+type StreamTestRequest struct {
+	URL string `json:"url"`
+
+	// Used if the URL doesn't contain a username.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Camera credential ID, used instead of the username and password.
+	Credential string `json:"credential,omitempty"`
+
+	// RTSP transport, "tcp" or "udp". Defaults to tcp.
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// Stream test error categories.
+const (
+	StreamTestErrAuth             = "auth"
+	StreamTestErrTimeout          = "timeout"
+	StreamTestErrUnreachable      = "unreachable"
+	StreamTestErrNotFound         = "notFound"
+	StreamTestErrUnsupportedCodec = "unsupportedCodec"
+	StreamTestErrUnknown          = "unknown"
+)
+
+// StreamTestResult is the result of a stream test. Error is empty if the
+// stream can be used. The streams are included even if the codec is
+// unsupported.
+type StreamTestResult struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	Video *StreamTestVideo `json:"video,omitempty"`
+	Audio *StreamTestAudio `json:"audio,omitempty"`
+
+	// Warnings that don't prevent the stream from being used.
+	Warnings []string `json:"warnings"`
+}
+
+// StreamTestVideo video stream of a tested input.
+type StreamTestVideo struct {
+	Codec  string  `json:"codec"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	FPS    float64 `json:"fps"`
+}
+
+// StreamTestAudio audio stream of a tested input.
+type StreamTestAudio struct {
+	Codec      string `json:"codec"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   string `json:"channels"`
+}
+
+// Stream test errors.
+var (
+	ErrInvalidStreamURL   = errors.New("invalid stream url")
+	ErrStreamTestsRunning = errors.New("too many stream tests are running")
+)
+
+// Schemes that FFmpeg may open, local files and
+// protocols like "concat:" are not allowed.
+var streamTestSchemes = map[string]bool{
+	"rtsp":  true,
+	"rtsps": true,
+	"rtmp":  true,
+	"http":  true,
+	"https": true,
+}
+
+// Max duration and number of concurrent stream tests.
+const (
+	streamTestTimeout = 10 * time.Second
+	maxStreamTests    = 4
+)
+
+// streamProbeFunc runs FFmpeg and returns stderr.
+type streamProbeFunc func(ctx context.Context, bin string, args []string) (string, error)
+
+func runStreamProbe(ctx context.Context, bin string, args []string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+// StreamTester probes candidate stream URLs before a monitor is saved.
+type StreamTester struct {
+	ffmpegBin   string
+	credentials *Credentials
+	timeout     time.Duration
+	sem         chan struct{}
+	probe       streamProbeFunc
+}
+
+// NewStreamTester creates a stream tester. Credentials may be nil.
+func NewStreamTester(ffmpegBin string, credentials *Credentials) *StreamTester {
+	return &StreamTester{
+		ffmpegBin:   ffmpegBin,
+		credentials: credentials,
+		timeout:     streamTestTimeout,
+		sem:         make(chan struct{}, maxStreamTests),
+		probe:       runStreamProbe,
+	}
+}
+
+// Test opens the stream with FFmpeg and returns the stream information.
+// Connection failures are categorized in the result, the returned error
+// is only set if the request is invalid or the test could not be run.
+func (t *StreamTester) Test(ctx context.Context, req StreamTestRequest) (*StreamTestResult, error) {
+	u, err := t.streamURL(req)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := req.Protocol
+	switch protocol {
+	case "":
+		protocol = "tcp"
+	case "tcp", "udp":
+	default:
+		return nil, fmt.Errorf("%w: protocol %q", ErrInvalidStreamURL, protocol)
+	}
+
+	select {
+	case t.sem <- struct{}{}:
+		defer func() { <-t.sem }()
+	default:
+		return nil, ErrStreamTestsRunning
+	}
+
+	args := []string{"-hide_banner", "-nostdin"}
+	if u.Scheme == "rtsp" || u.Scheme == "rtsps" {
+		args = append(args, "-rtsp_transport", protocol)
+	}
+	// Without a output, FFmpeg exits after printing the streams.
+	args = append(args, "-i", u.String())
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	stderr, err := t.probe(ctx, t.ffmpegBin, args)
+	if ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &StreamTestResult{
+				Error:    StreamTestErrTimeout,
+				Message:  "no response within " + t.timeout.String(),
+				Warnings: []string{},
+			}, nil
+		}
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("run ffmpeg: %w", err)
+	}
+
+	// The URL may contain the password.
+	stderr = strings.ReplaceAll(stderr, u.String(), u.Redacted())
+	return parseStreamTest(stderr), nil
+}
+
+// streamURL validates the URL and adds the credentials.
+func (t *StreamTester) streamURL(req StreamTestRequest) (*url.URL, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Host == "" || !streamTestSchemes[strings.ToLower(u.Scheme)] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStreamURL, req.URL)
+	}
+	if u.User != nil {
+		return u, nil
+	}
+
+	username, password := req.Username, req.Password
+	if req.Credential != "" {
+		if t.credentials == nil {
+			return nil, fmt.Errorf("%w: %v", ErrCredentialNotExist, req.Credential)
+		}
+		cred, err := t.credentials.Get(req.Credential)
+		if err != nil {
+			return nil, err
+		}
+		username, password = cred.Username, cred.Password
+	}
+	if username != "" || password != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	return u, nil
+}
+
+var (
+	streamTestVideoRegex      = regexp.MustCompile(`Stream #\d+:\d+.*?: Video: (\w+)`)
+	streamTestAudioRegex      = regexp.MustCompile(`Stream #\d+:\d+.*?: Audio: (\w+)`)
+	streamTestResolutionRegex = regexp.MustCompile(`, (\d{2,5})x(\d{2,5})`)
+	streamTestFPSRegex        = regexp.MustCompile(`, ([\d.]+) fps`)
+	streamTestSampleRateRegex = regexp.MustCompile(`, (\d+) Hz, ([^,]+)`)
+)
+
+// parseStreamTest parses the stderr of FFmpeg.
+//
+//	Stream #0:0: Video: h264 (Main), yuvj420p(pc), 1920x1080, 25 fps, 25 tbr, 90k tbn
+//	Stream #0:1: Audio: aac (LC), 16000 Hz, mono, fltp
+func parseStreamTest(stderr string) *StreamTestResult {
+	result := &StreamTestResult{Warnings: []string{}}
+	for _, line := range strings.Split(stderr, "\n") {
+		if m := streamTestVideoRegex.FindStringSubmatch(line); m != nil && result.Video == nil {
+			video := &StreamTestVideo{Codec: m[1]}
+			if m := streamTestResolutionRegex.FindStringSubmatch(line); m != nil {
+				video.Width, _ = strconv.Atoi(m[1])
+				video.Height, _ = strconv.Atoi(m[2])
+			}
+			if m := streamTestFPSRegex.FindStringSubmatch(line); m != nil {
+				video.FPS, _ = strconv.ParseFloat(m[1], 64)
+			}
+			result.Video = video
+		}
+		if m := streamTestAudioRegex.FindStringSubmatch(line); m != nil && result.Audio == nil {
+			audio := &StreamTestAudio{Codec: m[1]}
+			if m := streamTestSampleRateRegex.FindStringSubmatch(line); m != nil {
+				audio.SampleRate, _ = strconv.Atoi(m[1])
+				audio.Channels = strings.TrimSpace(m[2])
+			}
+			result.Audio = audio
+		}
+	}
+
+	if result.Video == nil && result.Audio == nil {
+		result.Error, result.Message = streamTestError(stderr)
+		return result
+	}
+
+	switch {
+	case result.Video == nil:
+		result.Error = StreamTestErrUnsupportedCodec
+		result.Message = "the stream doesn't contain video"
+	case result.Video.Codec != "h264":
+		result.Error = StreamTestErrUnsupportedCodec
+		result.Message = fmt.Sprintf(
+			"video codec %v must be transcoded to h264 with a video encoder", result.Video.Codec)
+	}
+	if result.Audio != nil && result.Audio.Codec != "aac" {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"audio codec %v must be transcoded to aac with a audio encoder, or disabled",
+			result.Audio.Codec))
+	}
+	return result
+}
+
+// streamTestError categorizes the error of a stream
+// that could not be opened and returns the last line.
+func streamTestError(stderr string) (string, string) {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	message := strings.TrimSpace(lines[len(lines)-1])
+
+	if class := ffmpegErrorClass(stderr); class != "" {
+		return class, message
+	}
+	return StreamTestErrUnknown, message
+}
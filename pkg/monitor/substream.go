@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"nvr/pkg/video"
+	"strconv"
+	"strings"
+)
+
+// Generated sub stream limits.
+const (
+	defaultAutoSubStreamHeight = 360
+	minAutoSubStreamHeight     = 144
+	maxAutoSubStreamHeight     = 1080
+)
+
+// ErrInvalidAutoSubStreamHeight invalid generated sub stream height.
+var ErrInvalidAutoSubStreamHeight = errors.New("invalid auto sub stream height")
+
+// AutoSubStream returns true if the sub stream is generated from the main
+// stream. Used by cameras that only provide a single high resolution
+// stream, so that the live grid doesn't have to play the main stream.
+// Ignored if the sub input is set.
+func (c Config) AutoSubStream() bool {
+	return c.v["autoSubStream"] == "true" && c.SubInput() == "" && !c.IsVirtual()
+}
+
+// autoSubStreamHeight returns the height of the generated sub stream.
+func (c Config) autoSubStreamHeight() int {
+	height, err := strconv.Atoi(c.v["autoSubStreamHeight"])
+	if err != nil || height < minAutoSubStreamHeight || height > maxAutoSubStreamHeight {
+		return defaultAutoSubStreamHeight
+	}
+	// The encoder requires a even height.
+	return height - height%2
+}
+
+// CheckAutoSubStream returns a error if the height is set and invalid.
+func (c Config) CheckAutoSubStream() error {
+	v := c.v["autoSubStreamHeight"]
+	if v == "" {
+		return nil
+	}
+	height, err := strconv.Atoi(v)
+	if err != nil || height < minAutoSubStreamHeight || height > maxAutoSubStreamHeight {
+		return fmt.Errorf("%w: %v, must be between %v and %v",
+			ErrInvalidAutoSubStreamHeight, v, minAutoSubStreamHeight, maxAutoSubStreamHeight)
+	}
+	return nil
+}
+
+// isAutoSubStream returns true if the input process generates the sub stream.
+func (i *InputProcess) isAutoSubStream() bool {
+	return i.isSubInput && i.Config.AutoSubStream()
+}
+
+// mainRTSPaddress returns the address of the main stream on the internal
+// RTSP server. The sub stream is on the same server with a suffix.
+func (i *InputProcess) mainRTSPaddress() string {
+	return strings.TrimSuffix(i.RTSPaddress(), video.SubPathSuffix)
+}
+
+func (i *InputProcess) generateAutoSubStreamArgs() string {
+	// OUTPUT
+	// -threads 1 -loglevel error -rtsp_transport tcp -i rtsp://127.0.0.1:2021/test
+	// -an -vf scale=-2:360 -c:v libx264 -preset veryfast -tune zerolatency
+	// -force_key_frames expr:gte(t,n_forced*2)
+	// -f rtsp -rtsp_transport tcp rtsp://127.0.0.1:2021/test_sub
+
+	// The main stream is read from the internal RTSP server, the
+	// camera only has to provide a single stream. The privacy mask
+	// is already applied to the main stream.
+	c := i.Config
+	args := "-threads 1 -loglevel " + c.LogLevel()
+	if c.Hwaccel() != "" {
+		args += " -hwaccel " + c.Hwaccel()
+	}
+	args += " -rtsp_transport " + i.RTSPprotocol() + " -i " + i.input()
+
+	// Live grids are muted.
+	args += " -an"
+
+	if threads := c.Threads(); threads != 0 {
+		args += " -threads " + strconv.Itoa(threads)
+	}
+	args += " -vf scale=-2:" + strconv.Itoa(c.autoSubStreamHeight()) +
+		" -c:v libx264 -preset veryfast -tune zerolatency" +
+		// HLS segments start at key frames.
+		" -force_key_frames expr:gte(t,n_forced*2)"
+	args += " -f rtsp -rtsp_transport " + i.RTSPprotocol() + " " + i.RTSPaddress()
+
+	return args
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"testing"
+
+	"nvr/pkg/video"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoSubStream(t *testing.T) {
+	cases := map[string]struct {
+		config     RawConfig
+		expected   bool
+		subEnabled bool
+	}{
+		"enabled":  {RawConfig{"autoSubStream": "true"}, true, true},
+		"disabled": {RawConfig{"autoSubStream": "false"}, false, false},
+		"unset":    {RawConfig{}, false, false},
+		"subInput": {RawConfig{"autoSubStream": "true", "subInput": "x"}, false, true},
+		"virtual": {
+			RawConfig{"autoSubStream": "true", "monitorType": "virtual"},
+			false, false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewConfig(tc.config)
+			require.Equal(t, tc.expected, c.AutoSubStream())
+			require.Equal(t, tc.subEnabled, c.SubInputEnabled())
+		})
+	}
+}
+
+func TestAutoSubStreamHeight(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected int
+		valid    bool
+	}{
+		"unset":   {"", 360, true},
+		"ok":      {"480", 480, true},
+		"odd":     {"241", 240, true},
+		"min":     {"144", 144, true},
+		"max":     {"1080", 1080, true},
+		"low":     {"143", 360, false},
+		"high":    {"1081", 360, false},
+		"invalid": {"x", 360, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewConfig(RawConfig{"autoSubStreamHeight": tc.input})
+			require.Equal(t, tc.expected, c.autoSubStreamHeight())
+			if tc.valid {
+				require.NoError(t, c.CheckAutoSubStream())
+			} else {
+				require.ErrorIs(t, c.CheckAutoSubStream(), ErrInvalidAutoSubStreamHeight)
+			}
+		})
+	}
+}
+
+func TestGenAutoSubStreamArgs(t *testing.T) {
+	t.Run("minimal", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"logLevel":      "1",
+				"autoSubStream": "true",
+			}),
+			isSubInput: true,
+			serverPath: video.ServerPath{
+				RtspProtocol: "tcp",
+				RtspAddress:  "rtsp://127.0.0.1:2021/m1" + video.SubPathSuffix,
+			},
+		}
+		require.True(t, i.isAutoSubStream())
+		require.Equal(t, "rtsp://127.0.0.1:2021/m1", i.input())
+
+		actual := i.generateAutoSubStreamArgs()
+		expected := "-threads 1 -loglevel 1 -rtsp_transport tcp" +
+			" -i rtsp://127.0.0.1:2021/m1 -an -vf scale=-2:360" +
+			" -c:v libx264 -preset veryfast -tune zerolatency" +
+			" -force_key_frames expr:gte(t,n_forced*2)" +
+			" -f rtsp -rtsp_transport tcp rtsp://127.0.0.1:2021/m1_sub"
+		require.Equal(t, expected, actual)
+	})
+	t.Run("maximal", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"logLevel":            "1",
+				"hwaccel":             "2",
+				"threads":             "3",
+				"autoSubStream":       "true",
+				"autoSubStreamHeight": "240",
+			}),
+			isSubInput: true,
+			serverPath: video.ServerPath{
+				RtspProtocol: "udp",
+				RtspAddress:  "rtsp://127.0.0.1:2021/m1" + video.SubPathSuffix,
+			},
+		}
+		actual := i.generateAutoSubStreamArgs()
+		expected := "-threads 1 -loglevel 1 -hwaccel 2 -rtsp_transport udp" +
+			" -i rtsp://127.0.0.1:2021/m1 -an -threads 3 -vf scale=-2:240" +
+			" -c:v libx264 -preset veryfast -tune zerolatency" +
+			" -force_key_frames expr:gte(t,n_forced*2)" +
+			" -f rtsp -rtsp_transport udp rtsp://127.0.0.1:2021/m1_sub"
+		require.Equal(t, expected, actual)
+	})
+	t.Run("mainInput", func(t *testing.T) {
+		i := &InputProcess{
+			Config:     NewConfig(RawConfig{"autoSubStream": "true"}),
+			isSubInput: false,
+		}
+		require.False(t, i.isAutoSubStream())
+	})
+}
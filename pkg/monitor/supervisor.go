@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"nvr/pkg/log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FFmpeg output classes, the error classes are the same as the stream test.
+const (
+	FFmpegCorrupt = "corrupt"
+	FFmpegStats   = "stats"
+)
+
+// ffmpegError is a error message of FFmpeg, for example
+// {"auth", "method DESCRIBE failed: 401 Unauthorized"}.
+type ffmpegError struct {
+	class string
+	msg   string
+}
+
+func (e ffmpegError) Error() string {
+	return e.class + ": " + e.msg
+}
+
+var ffmpegErrorClasses = []struct {
+	class   string
+	substrs []string
+}{
+	{StreamTestErrAuth, []string{"401 Unauthorized", "403 Forbidden"}},
+	{StreamTestErrNotFound, []string{"404 Not Found", "454 Session Not Found"}},
+	{StreamTestErrTimeout, []string{"Connection timed out", "Operation timed out"}},
+	{StreamTestErrUnreachable, []string{
+		"Connection refused", "No route to host", "Network is unreachable",
+		"Name or service not known", "Temporary failure in name resolution",
+	}},
+}
+
+// ffmpegErrorClass returns the error class of the
+// output, empty if it doesn't contain a known error.
+func ffmpegErrorClass(output string) string {
+	for _, c := range ffmpegErrorClasses {
+		for _, substr := range c.substrs {
+			if strings.Contains(output, substr) {
+				return c.class
+			}
+		}
+	}
+	return ""
+}
+
+// Decoder and demuxer messages about damaged or missing data.
+var ffmpegCorruptSubstrs = []string{
+	"corrupt decoded frame",
+	"error while decoding MB",
+	"concealing",
+	"Invalid NAL unit",
+	"non-existing PPS",
+	"no frame!",
+	"Packet corrupt",
+	"RTP: missed",
+	"max delay reached",
+}
+
+// "frame=  250 fps= 25 q=-1.0 size=N/A time=00:00:10.00 bitrate=N/A speed=1x".
+var ffmpegStatsFPSRegex = regexp.MustCompile(`^frame=\s*\d+\s+fps=\s*([\d.]+)`)
+
+// classifyFFmpegLine returns the class of a line of
+// FFmpeg output, empty if the line isn't recognized.
+func classifyFFmpegLine(line string) string {
+	if class := ffmpegErrorClass(line); class != "" {
+		return class
+	}
+	if ffmpegStatsFPSRegex.MatchString(line) {
+		return FFmpegStats
+	}
+	for _, substr := range ffmpegCorruptSubstrs {
+		if strings.Contains(line, substr) {
+			return FFmpegCorrupt
+		}
+	}
+	return ""
+}
+
+// Corrupt frames are summarized at most once per interval.
+const corruptLogInterval = time.Minute
+
+// supervisor parses the stderr of a input process. Errors are logged
+// with their class and kept so the crash error explains why the process
+// exited. The frame rate and corrupt frames are reported to the health.
+type supervisor struct {
+	processName string
+	level       log.Level // Level of unrecognized lines.
+	logf        logFunc
+	health      *inputHealth
+	now         func() time.Time
+
+	lastErr       *ffmpegError
+	corrupt       int // Corrupt frames since the last summary.
+	lastCorruptAt time.Time
+	mu            sync.Mutex
+}
+
+func newSupervisor(i *InputProcess) *supervisor {
+	return &supervisor{
+		processName: i.ProcessName(),
+		level:       log.FFmpegLevel(i.Config.LogLevel()),
+		logf:        i.logf,
+		health:      i.health,
+		now:         time.Now,
+	}
+}
+
+// parse handles a line of stderr.
+func (s *supervisor) parse(line string) {
+	class := classifyFFmpegLine(line)
+	switch class {
+	case FFmpegStats:
+		m := ffmpegStatsFPSRegex.FindStringSubmatch(line)
+		fps, _ := strconv.ParseFloat(m[1], 64)
+		s.updateHealth(func(h *InputHealth) { h.FPS = fps })
+		s.logf(log.LevelDebug, "%v process: %v", s.processName, line)
+	case FFmpegCorrupt:
+		s.updateHealth(func(h *InputHealth) { h.CorruptFrames++ })
+		s.corruptFrame(line)
+	case "":
+		s.logf(s.level, "%v process: %v", s.processName, line)
+	default:
+		s.mu.Lock()
+		s.lastErr = &ffmpegError{class: class, msg: line}
+		s.mu.Unlock()
+		s.logf(log.LevelError, "%v process: %v: %v", s.processName, class, line)
+	}
+}
+
+// corruptFrame logs the first corrupt frame and then a
+// summary at most once per interval to avoid flooding the logs.
+func (s *supervisor) corruptFrame(line string) {
+	s.mu.Lock()
+	s.corrupt++
+	now := s.now()
+	if now.Sub(s.lastCorruptAt) < corruptLogInterval {
+		s.mu.Unlock()
+		return
+	}
+	count := s.corrupt
+	s.corrupt = 0
+	s.lastCorruptAt = now
+	s.mu.Unlock()
+
+	s.logf(log.LevelWarning, "%v process: %v: %v corrupt frames: %v",
+		s.processName, FFmpegCorrupt, count, line)
+}
+
+func (s *supervisor) updateHealth(fn func(*InputHealth)) {
+	if s.health != nil {
+		s.health.update(fn)
+	}
+}
+
+// lastError returns the last error message, nil if there was none.
+func (s *supervisor) lastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastErr == nil {
+		return nil
+	}
+	return *s.lastErr
+}
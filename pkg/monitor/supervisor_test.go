@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyFFmpegLine(t *testing.T) {
+	cases := map[string]string{
+		"[rtsp @ 0x1] method DESCRIBE failed: 401 Unauthorized":            StreamTestErrAuth,
+		"[rtsp @ 0x1] method DESCRIBE failed: 404 Not Found":               StreamTestErrNotFound,
+		"rtsp://x: Connection timed out":                                   StreamTestErrTimeout,
+		"rtsp://x: Connection refused":                                     StreamTestErrUnreachable,
+		"[h264 @ 0x1] error while decoding MB 10 20, bytestream -5":        FFmpegCorrupt,
+		"[h264 @ 0x1] concealing 120 DC, 120 AC, 120 MV errors in P frame": FFmpegCorrupt,
+		"frame=  250 fps= 25 q=-1.0 size=N/A time=00:00:10.00 speed=1x":    FFmpegStats,
+		"Stream mapping:": "",
+	}
+	for line, expected := range cases {
+		require.Equal(t, expected, classifyFFmpegLine(line), line)
+	}
+}
+
+func newTestSupervisor() (*supervisor, *[]string) {
+	var logs []string
+	levels := map[log.Level]string{
+		log.LevelError:   "error",
+		log.LevelWarning: "warning",
+		log.LevelDebug:   "debug",
+	}
+	s := &supervisor{
+		processName: "main",
+		level:       log.LevelError,
+		logf: func(level log.Level, format string, a ...interface{}) {
+			logs = append(logs, levels[level]+": "+fmt.Sprintf(format, a...))
+		},
+		health: newInputHealth(nil),
+		now:    time.Now,
+	}
+	return s, &logs
+}
+
+func TestSupervisor(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		s, logs := newTestSupervisor()
+		require.NoError(t, s.lastError())
+
+		s.parse("[rtsp @ 0x1] method DESCRIBE failed: 401 Unauthorized")
+		s.parse("rtsp://x: Server returned 401 Unauthorized (authorization failed)")
+		require.Equal(t, []string{
+			"error: main process: auth: [rtsp @ 0x1] method DESCRIBE failed: 401 Unauthorized",
+			"error: main process: auth: rtsp://x: Server returned 401 Unauthorized (authorization failed)",
+		}, *logs)
+
+		err := fmt.Errorf("crashed: %w: %w", errors.New("exit status 1"), s.lastError())
+		require.Equal(t, "crashed: exit status 1: auth: rtsp://x: "+
+			"Server returned 401 Unauthorized (authorization failed)", err.Error())
+
+		s.health.crashed(err, time.Time{})
+		require.Equal(t, StreamTestErrAuth, s.health.get().LastErrorClass)
+
+		s.health.crashed(errors.New("x"), time.Time{})
+		require.Empty(t, s.health.get().LastErrorClass)
+	})
+	t.Run("stats", func(t *testing.T) {
+		s, logs := newTestSupervisor()
+		s.parse("frame=  250 fps= 12.5 q=-1.0 size=N/A time=00:00:10.00 speed=1x")
+		require.Equal(t, 12.5, s.health.get().FPS)
+		require.Equal(t, []string{"debug: main process: frame=  250 fps= 12.5 " +
+			"q=-1.0 size=N/A time=00:00:10.00 speed=1x"}, *logs)
+
+		s.health.starting()
+		require.Zero(t, s.health.get().FPS)
+	})
+	t.Run("corrupt", func(t *testing.T) {
+		s, logs := newTestSupervisor()
+		now := time.Unix(0, 0)
+		s.now = func() time.Time { return now }
+
+		const line = "[h264 @ 0x1] error while decoding MB 1 2, bytestream -5"
+		s.parse(line)
+		s.parse(line)
+		s.parse(line)
+		now = now.Add(corruptLogInterval)
+		s.parse(line)
+		require.Equal(t, []string{
+			"warning: main process: corrupt: 1 corrupt frames: " + line,
+			"warning: main process: corrupt: 3 corrupt frames: " + line,
+		}, *logs)
+		require.Equal(t, 4, s.health.get().CorruptFrames)
+	})
+	t.Run("unknown", func(t *testing.T) {
+		s, logs := newTestSupervisor()
+		s.parse("x")
+		require.Equal(t, []string{"error: main process: x"}, *logs)
+		require.NoError(t, s.lastError())
+	})
+}
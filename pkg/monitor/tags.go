@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tag limits.
+const (
+	maxTags      = 32
+	maxTagLength = 64
+)
+
+// ErrInvalidTags invalid monitor tags.
+var ErrInvalidTags = errors.New("invalid tags")
+
+// Lowercase letters, digits and "-_.:", for example "building:b".
+var tagRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]*$`)
+
+// Tags returns the sorted and deduplicated tags in the comma separated
+// "tags" field. Tags are free-form labels like location or owner.
+func (c Config) Tags() []string {
+	return ParseTags(c.v["tags"])
+}
+
+// ParseTags parses a comma separated list of tags. Tags are
+// case-insensitive and surrounding spaces are ignored.
+func ParseTags(csv string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(csv, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// CheckTags returns a error if a tag contains
+// invalid characters or if there are too many.
+func (c Config) CheckTags() error {
+	tags := c.Tags()
+	if len(tags) > maxTags {
+		return fmt.Errorf("%w: %v, max %v", ErrInvalidTags, len(tags), maxTags)
+	}
+	for _, tag := range tags {
+		if len(tag) > maxTagLength || !tagRegex.MatchString(tag) {
+			return fmt.Errorf("%w: %q", ErrInvalidTags, tag)
+		}
+	}
+	return nil
+}
+
+// HasTags returns true if the monitor has all the tags.
+func (c Config) HasTags(tags []string) bool {
+	own := c.Tags()
+	for _, tag := range tags {
+		i := sort.SearchStrings(own, tag)
+		if i == len(own) || own[i] != tag {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		c := NewConfig(RawConfig{"tags": " Outdoor,garage,, outdoor "})
+		require.Equal(t, []string{"garage", "outdoor"}, c.Tags())
+		require.Equal(t, []string{}, NewConfig(RawConfig{}).Tags())
+	})
+	t.Run("hasTags", func(t *testing.T) {
+		c := NewConfig(RawConfig{"tags": "garage,outdoor,building:b"})
+		require.True(t, c.HasTags(nil))
+		require.True(t, c.HasTags([]string{"outdoor", "garage"}))
+		require.False(t, c.HasTags([]string{"garage", "indoor"}))
+		require.False(t, NewConfig(RawConfig{}).HasTags([]string{"garage"}))
+	})
+	t.Run("check", func(t *testing.T) {
+		require.NoError(t, NewConfig(RawConfig{"tags": "building:b,floor-2,x_y.z"}).CheckTags())
+		require.NoError(t, NewConfig(RawConfig{}).CheckTags())
+	})
+	tooMany := make([]string, maxTags+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i)
+	}
+	cases := map[string]string{
+		"space":    "a b",
+		"prefix":   "-a",
+		"char":     "a/b",
+		"length":   strings.Repeat("a", maxTagLength+1),
+		"tooMany":  strings.Join(tooMany, ","),
+		"nonASCII": "å",
+	}
+	for name, tags := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := NewConfig(RawConfig{"tags": tags}).CheckTags()
+			require.ErrorIs(t, err, ErrInvalidTags)
+		})
+	}
+}
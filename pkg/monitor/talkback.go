@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"sync"
+)
+
+// Talkback errors.
+var (
+	ErrTalkbackDisabled = errors.New("talkback is disabled for this monitor")
+	ErrTalkbackBusy     = errors.New("someone else is talking")
+)
+
+// TalkbackEnabled returns true if the camera speaker can be used.
+func (c Config) TalkbackEnabled() bool {
+	return c.v["talkback"] == "true"
+}
+
+// talkbacks tracks the active talkback sessions, only
+// one user can talk to a monitor at the same time.
+type talkbacks struct {
+	users map[string]string
+	mu    sync.Mutex
+}
+
+func newTalkbacks() *talkbacks {
+	return &talkbacks{users: make(map[string]string)}
+}
+
+func (t *talkbacks) acquire(monitorID, username string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if user, busy := t.users[monitorID]; busy {
+		return fmt.Errorf("%w: %v", ErrTalkbackBusy, user)
+	}
+	t.users[monitorID] = username
+	return nil
+}
+
+func (t *talkbacks) release(monitorID string) {
+	t.mu.Lock()
+	delete(t.users, monitorID)
+	t.mu.Unlock()
+}
+
+// Talkback is a active talkback session. The speaker
+// is released for other users when it's closed.
+type Talkback struct {
+	*Backchannel
+	onClose func()
+	once    sync.Once
+}
+
+// Close closes the backchannel and releases the speaker.
+func (t *Talkback) Close() error {
+	var err error
+	t.once.Do(func() {
+		err = t.Backchannel.Close()
+		t.onClose()
+	})
+	return err
+}
+
+// StartTalkback opens the audio backchannel of the monitor's main input.
+// The username is logged and reported to other users while they talk.
+func (m *Manager) StartTalkback(ctx context.Context, id string, username string) (*Talkback, error) {
+	m.mu.Lock()
+	rawConf, exist := m.rawConfigs[id]
+	var err error
+	if exist {
+		rawConf, err = m.unsafeResolvedConfig(id)
+	}
+	m.mu.Unlock()
+	switch {
+	case !exist:
+		return nil, ErrNotExist
+	case err != nil:
+		return nil, err
+	}
+
+	config := NewConfig(rawConf)
+	if !config.TalkbackEnabled() {
+		return nil, ErrTalkbackDisabled
+	}
+
+	dialer, err := config.Dialer()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.talkbacks.acquire(id, username); err != nil {
+		return nil, err
+	}
+	backchannel, err := m.dialBackchannel(ctx, config.MainInput(), dialer)
+	if err != nil {
+		m.talkbacks.release(id)
+		return nil, err
+	}
+
+	m.logger.Log(log.Entry{
+		Level:     log.LevelInfo,
+		Src:       "monitor",
+		MonitorID: id,
+		Msg:       fmt.Sprintf("talkback started by %v", username),
+	})
+	return &Talkback{
+		Backchannel: backchannel,
+		onClose: func() {
+			m.talkbacks.release(id)
+			m.logger.Log(log.Entry{
+				Level:     log.LevelInfo,
+				Src:       "monitor",
+				MonitorID: id,
+				Msg:       fmt.Sprintf("talkback stopped by %v", username),
+			})
+		},
+	}, nil
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartTalkback(t *testing.T) {
+	_, manager := newTestManager(t)
+	manager.rawConfigs["1"]["talkback"] = "true"
+
+	var dialed string
+	errDial := errors.New("mock")
+	manager.dialBackchannel = func(_ context.Context, url string, _ *net.Dialer) (*Backchannel, error) {
+		dialed = url
+		return nil, errDial
+	}
+
+	_, err := manager.StartTalkback(context.Background(), "1", "alice")
+	require.ErrorIs(t, err, errDial)
+	require.Equal(t, "x1", dialed)
+
+	// The speaker is released if the dial fails.
+	require.NoError(t, manager.talkbacks.acquire("1", "bob"))
+	_, err = manager.StartTalkback(context.Background(), "1", "alice")
+	require.ErrorIs(t, err, ErrTalkbackBusy)
+	require.ErrorContains(t, err, "bob")
+
+	_, err = manager.StartTalkback(context.Background(), "2", "alice")
+	require.ErrorIs(t, err, ErrTalkbackDisabled)
+
+	_, err = manager.StartTalkback(context.Background(), "x", "alice")
+	require.ErrorIs(t, err, ErrNotExist)
+}
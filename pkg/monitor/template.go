@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nvr/pkg/secret"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Template is a named monitor config that new monitors can be created
+// from. Values may contain variables, "${name}", that are replaced when
+// the template is applied. Example: "rtsp://${ip}:554/stream"
+type Template struct {
+	Name   string    `json:"name"`
+	Config RawConfig `json:"config"`
+}
+
+// Template errors.
+var (
+	ErrTemplateNotExist    = errors.New("monitor template does not exist")
+	ErrInvalidTemplateName = errors.New("invalid template name")
+	ErrTemplateVariable    = errors.New("missing template variable")
+)
+
+var templateNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var templateVariableRegex = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// Variables returns the sorted names of the variables used by the template.
+func (t Template) Variables() []string {
+	found := make(map[string]struct{})
+	for _, value := range t.Config {
+		for _, match := range templateVariableRegex.FindAllStringSubmatch(value, -1) {
+			found[match[1]] = struct{}{}
+		}
+	}
+	vars := make([]string, 0, len(found))
+	for name := range found {
+		vars = append(vars, name)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// Apply returns the config of a new monitor with the variables replaced.
+func (t Template) Apply(id string, name string, vars map[string]string) (RawConfig, error) {
+	var missing []string
+	for _, v := range t.Variables() {
+		if _, exist := vars[v]; !exist {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateVariable, strings.Join(missing, ", "))
+	}
+
+	c := make(RawConfig, len(t.Config)+2)
+	for key, value := range t.Config {
+		c[key] = templateVariableRegex.ReplaceAllStringFunc(value, func(match string) string {
+			return vars[match[2:len(match)-1]]
+		})
+	}
+	c["id"] = id
+	c["name"] = name
+	return c, nil
+}
+
+// Templates stores the monitor templates, one JSON file per
+// template. The input URLs are encrypted like monitor configs.
+type Templates struct {
+	templates map[string]Template
+	path      string
+	secrets   *secret.Cipher
+	mu        sync.Mutex
+}
+
+// NewTemplates loads the templates from the directory.
+func NewTemplates(configPath string, secrets *secret.Cipher) (*Templates, error) {
+	if err := os.MkdirAll(configPath, 0o700); err != nil {
+		return nil, fmt.Errorf("create templates directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read templates directory: %w", err)
+	}
+
+	templates := make(map[string]Template)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(configPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read template: %w", err)
+		}
+		var t Template
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, fmt.Errorf("unmarshal template: %w: %v", err, entry.Name())
+		}
+		if t.Config == nil {
+			t.Config = make(RawConfig)
+		}
+		decryptValues(secrets, templateScope(t.Name), t.Config)
+		templates[t.Name] = t
+	}
+
+	return &Templates{
+		templates: templates,
+		path:      configPath,
+		secrets:   secrets,
+	}, nil
+}
+
+// Secrets of templates are bound to the template
+// name to not collide with monitor IDs.
+func templateScope(name string) string {
+	return "template:" + name
+}
+
+func (t *Templates) templatePath(name string) string {
+	return filepath.Join(t.path, name+".json")
+}
+
+// List returns all templates sorted by name.
+func (t *Templates) List() []Template {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	templates := []Template{}
+	for _, tpl := range t.templates {
+		templates = append(templates, tpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// Get returns a template by name.
+func (t *Templates) Get(name string) (Template, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tpl, exist := t.templates[name]
+	if !exist {
+		return Template{}, fmt.Errorf("%w: %v", ErrTemplateNotExist, name)
+	}
+	return tpl, nil
+}
+
+// Set creates or updates a template. The id and name
+// keys are removed, they're set when the template is applied.
+func (t *Templates) Set(tpl Template) error {
+	if !templateNameRegex.MatchString(tpl.Name) {
+		return fmt.Errorf("%w: %q", ErrInvalidTemplateName, tpl.Name)
+	}
+
+	c := make(RawConfig, len(tpl.Config))
+	for key, value := range tpl.Config {
+		if key != "id" && key != "name" {
+			c[key] = value
+		}
+	}
+	tpl.Config = c
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	encrypted, err := encryptValues(t.secrets, templateScope(tpl.Name), tpl.Config)
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(Template{Name: tpl.Name, Config: encrypted}, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshal template: %w", err)
+	}
+	if err := os.WriteFile(t.templatePath(tpl.Name), raw, 0o600); err != nil {
+		return fmt.Errorf("write template: %w", err)
+	}
+	t.templates[tpl.Name] = tpl
+	return nil
+}
+
+// Delete deletes a template by name.
+func (t *Templates) Delete(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exist := t.templates[name]; !exist {
+		return fmt.Errorf("%w: %v", ErrTemplateNotExist, name)
+	}
+	if err := os.Remove(t.templatePath(name)); err != nil {
+		return err
+	}
+	delete(t.templates, name)
+	return nil
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nvr/pkg/secret"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateApply(t *testing.T) {
+	tpl := Template{
+		Name: "a",
+		Config: RawConfig{
+			"enable":    "true",
+			"mainInput": "rtsp://${user}:${pass}@${ip}:554/main",
+			"subInput":  "rtsp://${user}:${pass}@${ip}:554/sub",
+		},
+	}
+	require.Equal(t, []string{"ip", "pass", "user"}, tpl.Variables())
+
+	t.Run("ok", func(t *testing.T) {
+		c, err := tpl.Apply("x", "y", map[string]string{
+			"ip": "192.168.1.10", "user": "admin", "pass": "$1",
+		})
+		require.NoError(t, err)
+
+		expected := RawConfig{
+			"id":        "x",
+			"name":      "y",
+			"enable":    "true",
+			"mainInput": "rtsp://admin:$1@192.168.1.10:554/main",
+			"subInput":  "rtsp://admin:$1@192.168.1.10:554/sub",
+		}
+		require.Equal(t, expected, c)
+
+		// The template is not modified.
+		require.Equal(t, "rtsp://${user}:${pass}@${ip}:554/main", tpl.Config["mainInput"])
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := tpl.Apply("x", "y", map[string]string{"ip": "192.168.1.10"})
+		require.ErrorIs(t, err, ErrTemplateVariable)
+		require.Contains(t, err.Error(), "pass, user")
+	})
+}
+
+func TestTemplates(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "secret.key")
+	secrets, err := secret.NewCipher(storage.SecretStoreFile, keyPath)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	templates, err := NewTemplates(dir, secrets)
+	require.NoError(t, err)
+	require.Empty(t, templates.List())
+
+	err = templates.Set(Template{Name: "a b"})
+	require.ErrorIs(t, err, ErrInvalidTemplateName)
+
+	tpl := Template{
+		Name: "a",
+		Config: RawConfig{
+			"id":        "x",
+			"name":      "y",
+			"mainInput": "rtsp://admin:pass@${ip}",
+		},
+	}
+	require.NoError(t, templates.Set(tpl))
+	require.NoError(t, templates.Set(Template{Name: "b", Config: RawConfig{}}))
+
+	// The ID and name are removed and the input is encrypted.
+	expected := Template{
+		Name:   "a",
+		Config: RawConfig{"mainInput": "rtsp://admin:pass@${ip}"},
+	}
+	got, err := templates.Get("a")
+	require.NoError(t, err)
+	require.Equal(t, expected, got)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "a.json"))
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(raw), "pass"))
+
+	// Reloaded.
+	templates, err = NewTemplates(dir, secrets)
+	require.NoError(t, err)
+	list := templates.List()
+	require.Len(t, list, 2)
+	require.Equal(t, expected, list[0])
+	require.Equal(t, "b", list[1].Name)
+
+	require.NoError(t, templates.Delete("b"))
+	_, err = templates.Get("b")
+	require.ErrorIs(t, err, ErrTemplateNotExist)
+	require.ErrorIs(t, templates.Delete("b"), ErrTemplateNotExist)
+	require.NoFileExists(t, filepath.Join(dir, "b.json"))
+}
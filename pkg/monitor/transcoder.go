@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"errors"
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/gstreamer"
+	"nvr/pkg/storage"
+	"strings"
+)
+
+// Transcoder backends.
+const (
+	TranscoderAuto      = "auto"
+	TranscoderFFmpeg    = "ffmpeg"
+	TranscoderGStreamer = "gstreamer"
+)
+
+// Transcoders capabilities of the available transcoder backends.
+// Backends that could not be probed are nil.
+type Transcoders struct {
+	FFmpeg    *ffmpeg.Capabilities    `json:"ffmpeg"`
+	GStreamer *gstreamer.Capabilities `json:"gstreamer"`
+}
+
+// ProbeTranscoders detects the capabilities of the transcoder backends.
+func ProbeTranscoders(env storage.ConfigEnv) *Transcoders {
+	// Errors are ignored, GStreamer is usually not installed.
+	ffmpegCapabilities, _ := ffmpeg.ProbeCapabilities(env.FFmpegBin)
+	gstCapabilities, _ := gstreamer.ProbeCapabilities(env.GStreamerBin)
+
+	return &Transcoders{
+		FFmpeg:    ffmpegCapabilities,
+		GStreamer: gstCapabilities,
+	}
+}
+
+// Transcoder errors.
+var (
+	ErrGStreamerNotInstalled = errors.New("gstreamer is not installed")
+	ErrGStreamerFFmpegOpts   = errors.New(
+		"input options and hardware acceleration are not supported by GStreamer")
+)
+
+// transcoder returns the backend used by the input process. Returns
+// a error if GStreamer is selected but wasn't detected, or if the
+// monitor has FFmpeg options that can't be passed to GStreamer.
+func (i *InputProcess) transcoder() (string, error) {
+	transcoder := i.selectTranscoder()
+	if transcoder != TranscoderGStreamer {
+		return transcoder, nil
+	}
+	if i.transcoders == nil || i.transcoders.GStreamer == nil {
+		return "", ErrGStreamerNotInstalled
+	}
+	if i.Config.InputOpts() != "" || i.Config.Hwaccel() != "" {
+		return "", ErrGStreamerFFmpegOpts
+	}
+	return TranscoderGStreamer, nil
+}
+
+// selectTranscoder returns the configured backend. Auto will use
+// GStreamer if the video encoder is a GStreamer element that isn't
+// available in FFmpeg, otherwise FFmpeg. Privacy masks, virtual
+// monitors and auto sub streams are only supported by FFmpeg.
+func (i *InputProcess) selectTranscoder() string {
+	if len(i.privacyZones) != 0 || i.Config.IsVirtual() || i.isAutoSubStream() {
+		return TranscoderFFmpeg
+	}
+	switch i.Config.Transcoder() {
+	case TranscoderFFmpeg:
+		return TranscoderFFmpeg
+	case TranscoderGStreamer:
+		return TranscoderGStreamer
+	}
+
+	if i.transcoders == nil {
+		return TranscoderFFmpeg
+	}
+	encoder := i.Config.VideoEncoder()
+	if encoder == "" || encoder == "copy" || i.transcoders.FFmpeg.HasEncoder(encoder) {
+		return TranscoderFFmpeg
+	}
+	if i.transcoders.GStreamer.HasElement(encoder) {
+		return TranscoderGStreamer
+	}
+	return TranscoderFFmpeg
+}
+
+func (i *InputProcess) generateGStreamerArgs() string {
+	// OUTPUT
+	// -q -e rtspsrc location=rtsp://x ! parsebin
+	// ! rtspclientsink location=rtsp://127.0.0.1:2021/test protocols=tcp
+
+	c := i.Config
+	args := "-q -e rtspsrc location=" + i.input()
+	if c.GStreamerSourceOpts() != "" {
+		args += " " + c.GStreamerSourceOpts()
+	}
+
+	encoder := c.VideoEncoder()
+	if encoder == "" || encoder == "copy" {
+		args += " ! parsebin"
+	} else {
+		args += " ! decodebin ! videoconvert ! " + encoder + " ! parsebin"
+	}
+
+	args += " ! rtspclientsink location=" + i.RTSPaddress() +
+		" protocols=" + strings.ToLower(i.RTSPprotocol())
+
+	return args
+}
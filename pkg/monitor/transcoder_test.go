@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"nvr/pkg/ffmpeg"
+	"nvr/pkg/gstreamer"
+	"nvr/pkg/video"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscoder(t *testing.T) {
+	transcoders := &Transcoders{
+		FFmpeg:    &ffmpeg.Capabilities{Encoders: []string{"libx264"}},
+		GStreamer: &gstreamer.Capabilities{Elements: []string{"v4l2h264enc"}},
+	}
+	noGStreamer := &Transcoders{
+		FFmpeg: &ffmpeg.Capabilities{Encoders: []string{"libx264"}},
+	}
+	cases := map[string]struct {
+		config      RawConfig
+		transcoders *Transcoders
+		expected    string
+		expectedErr error
+	}{
+		"default": {RawConfig{}, nil, TranscoderFFmpeg, nil},
+		"ffmpeg": {
+			RawConfig{"transcoder": "ffmpeg", "videoEncoder": "v4l2h264enc"},
+			transcoders,
+			TranscoderFFmpeg,
+			nil,
+		},
+		"gstreamer": {
+			RawConfig{"transcoder": "gstreamer", "videoEncoder": "copy"},
+			transcoders,
+			TranscoderGStreamer,
+			nil,
+		},
+		"gstreamerNotInstalled": {
+			RawConfig{"transcoder": "gstreamer", "videoEncoder": "copy"},
+			noGStreamer,
+			"",
+			ErrGStreamerNotInstalled,
+		},
+		"gstreamerNotProbed": {
+			RawConfig{"transcoder": "gstreamer", "videoEncoder": "copy"},
+			nil,
+			"",
+			ErrGStreamerNotInstalled,
+		},
+		"gstreamerInputOpts": {
+			RawConfig{"transcoder": "gstreamer", "inputOptions": "-rtsp_transport tcp"},
+			transcoders,
+			"",
+			ErrGStreamerFFmpegOpts,
+		},
+		"gstreamerHwaccel": {
+			RawConfig{"transcoder": "gstreamer", "hwaccel": "vaapi"},
+			transcoders,
+			"",
+			ErrGStreamerFFmpegOpts,
+		},
+		"gstreamerVirtual": {
+			RawConfig{"transcoder": "gstreamer", "monitorType": "virtual"},
+			noGStreamer,
+			TranscoderFFmpeg,
+			nil,
+		},
+		"autoCopy":   {RawConfig{"videoEncoder": "copy"}, transcoders, TranscoderFFmpeg, nil},
+		"autoFFmpeg": {RawConfig{"videoEncoder": "libx264 -preset fast"}, transcoders, TranscoderFFmpeg, nil},
+		"autoGStreamer": {
+			RawConfig{"videoEncoder": "v4l2h264enc"},
+			transcoders,
+			TranscoderGStreamer,
+			nil,
+		},
+		"autoGStreamerInputOpts": {
+			RawConfig{"videoEncoder": "v4l2h264enc", "inputOptions": "-rtsp_transport tcp"},
+			transcoders,
+			"",
+			ErrGStreamerFFmpegOpts,
+		},
+		"autoUnknown": {RawConfig{"videoEncoder": "x"}, transcoders, TranscoderFFmpeg, nil},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			i := &InputProcess{
+				Config:      NewConfig(tc.config),
+				transcoders: tc.transcoders,
+			}
+			transcoder, err := i.transcoder()
+			require.ErrorIs(t, err, tc.expectedErr)
+			require.Equal(t, tc.expected, transcoder)
+		})
+	}
+}
+
+func TestGenGStreamerArgs(t *testing.T) {
+	t.Run("minimal", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"mainInput":    "1",
+				"videoEncoder": "copy",
+			}),
+			serverPath: video.ServerPath{
+				RtspProtocol: "TCP",
+				RtspAddress:  "2",
+			},
+		}
+		actual := i.generateGStreamerArgs()
+		expected := "-q -e rtspsrc location=1 ! parsebin ! rtspclientsink location=2 protocols=tcp"
+		require.Equal(t, expected, actual)
+	})
+	t.Run("maximal", func(t *testing.T) {
+		i := &InputProcess{
+			Config: NewConfig(RawConfig{
+				"gstreamerSourceOptions": "1",
+				"inputOptions":           "x",
+				"subInput":               "2",
+				"videoEncoder":           "3",
+			}),
+			isSubInput: true,
+			serverPath: video.ServerPath{
+				RtspProtocol: "tcp",
+				RtspAddress:  "4",
+			},
+		}
+		actual := i.generateGStreamerArgs()
+		expected := "-q -e rtspsrc location=2 1 ! decodebin ! videoconvert" +
+			" ! 3 ! parsebin ! rtspclientsink location=4 protocols=tcp"
+		require.Equal(t, expected, actual)
+	})
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/log"
+	"strconv"
+)
+
+// Monitor types.
+const (
+	MonitorTypeCamera = "camera"
+
+	// Virtual monitors only proxy and record a remote stream. The
+	// stream is copied without transcoding, or published directly
+	// to the RTSP server of the video server if the input is empty.
+	MonitorTypeVirtual = "virtual"
+)
+
+// ErrInvalidMonitorType invalid monitor type.
+var ErrInvalidMonitorType = errors.New("invalid monitor type")
+
+// Type returns the monitor type, camera if unset.
+func (c Config) Type() string {
+	if c.v["monitorType"] == "" {
+		return MonitorTypeCamera
+	}
+	return c.v["monitorType"]
+}
+
+// IsVirtual returns true if the monitor is a virtual monitor.
+func (c Config) IsVirtual() bool {
+	return c.Type() == MonitorTypeVirtual
+}
+
+// CheckMonitorType returns a error if the monitor type is unknown.
+func (c Config) CheckMonitorType() error {
+	switch c.Type() {
+	case MonitorTypeCamera, MonitorTypeVirtual:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidMonitorType, c.v["monitorType"])
+}
+
+// isPublished returns true if the input is published
+// directly to the RTSP server instead of being pulled.
+func (i *InputProcess) isPublished() bool {
+	return i.Config.IsVirtual() && i.input() == ""
+}
+
+func (i *InputProcess) generateVirtualArgs() string {
+	// OUTPUT
+	// -threads 1 -loglevel error -i rtsp://x -c copy
+	// -f rtsp -rtsp_transport tcp rtsp://127.0.0.1:2021/test
+
+	c := i.Config
+	args := "-threads 1 -loglevel " + c.LogLevel()
+	if c.InputOpts() != "" {
+		args += " " + c.InputOpts()
+	}
+	if i.sourceAddr != "" {
+		args += " -local_addr " + i.sourceAddr
+	}
+	args += " -i " + i.input()
+
+	if c.audioEnabled() {
+		if stream := c.AudioStream(); stream != -1 {
+			args += " -map 0:v:0 -map 0:a:" + strconv.Itoa(stream) + "?"
+		}
+		args += " -c:a copy"
+	} else {
+		args += " -an"
+	}
+	args += " -c:v copy"
+	args += " -f rtsp -rtsp_transport " + i.RTSPprotocol() + " " + i.RTSPaddress()
+
+	return args
+}
+
+// ErrPublisherStalled the published stream stalled.
+var ErrPublisherStalled = errors.New("published stream stalled")
+
+// waitForPublisher blocks until the context is canceled. The stream is
+// published by the remote source, a stall cancels the process context.
+func (i *InputProcess) waitForPublisher(ctx context.Context, processCTX context.Context) error {
+	i.logf(log.LevelInfo, "%v process: waiting for stream to be published to %v",
+		i.ProcessName(), i.RTSPaddress())
+	<-processCTX.Done()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return ErrPublisherStalled
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nvr/pkg/video"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorType(t *testing.T) {
+	c := NewConfig(RawConfig{"subInput": "x"})
+	require.Equal(t, MonitorTypeCamera, c.Type())
+	require.False(t, c.IsVirtual())
+	require.True(t, c.SubInputEnabled())
+	require.NoError(t, c.CheckMonitorType())
+
+	c = NewConfig(RawConfig{"monitorType": "virtual", "subInput": "x"})
+	require.True(t, c.IsVirtual())
+	require.False(t, c.SubInputEnabled())
+	require.NoError(t, c.CheckMonitorType())
+
+	c = NewConfig(RawConfig{"monitorType": "x"})
+	require.ErrorIs(t, c.CheckMonitorType(), ErrInvalidMonitorType)
+}
+
+func TestGenVirtualArgs(t *testing.T) {
+	i := &InputProcess{
+		Config: NewConfig(RawConfig{
+			"monitorType":  "virtual",
+			"logLevel":     "1",
+			"hwaccel":      "x",
+			"inputOptions": "2",
+			"mainInput":    "3",
+			"audioEncoder": "aac",
+			"audioStream":  "1",
+			"videoEncoder": "libx264",
+			"threads":      "4",
+		}),
+		serverPath: video.ServerPath{
+			RtspProtocol: "4",
+			RtspAddress:  "5",
+		},
+	}
+	actual := i.generateVirtualArgs()
+	expected := "-threads 1 -loglevel 1 2 -i 3 -map 0:v:0 -map 0:a:1? -c:a copy" +
+		" -c:v copy -f rtsp -rtsp_transport 4 5"
+	require.Equal(t, expected, actual)
+}
+
+func TestRunInputProcessPublished(t *testing.T) {
+	newInput := func() *InputProcess {
+		i := newTestInputProcess()
+		i.Config.v["monitorType"] = "virtual"
+		i.Config.v["stallTimeout"] = "1"
+		i.health = newInputHealth(nil)
+		i.newVideoServerPath = func(context.Context, string, video.PathConf) (*video.ServerPath, error) {
+			return &video.ServerPath{
+				HLSMuxer: func(context.Context) (video.IHLSMuxer, error) {
+					return nil, errors.New("no one is publishing")
+				},
+			}, nil
+		}
+		return i
+	}
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.NoError(t, runInputProcess(ctx, newInput()))
+	})
+	t.Run("stalled", func(t *testing.T) {
+		err := runInputProcess(context.Background(), newInput())
+		require.ErrorIs(t, err, ErrPublisherStalled)
+	})
+}
@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package nvrtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"nvr/pkg/video/gortsplib"
+	"nvr/pkg/video/gortsplib/pkg/base"
+	"nvr/pkg/video/gortsplib/pkg/conn"
+	"nvr/pkg/video/gortsplib/pkg/headers"
+	"nvr/pkg/video/gortsplib/pkg/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Baseline profile, 320x240, pic_order_cnt_type 2 so that DTS equals PTS.
+var (
+	cameraSPS = []byte{0x67, 0x42, 0xc0, 0x1e, 0xda, 0x05, 0x07, 0xe4}
+	cameraPPS = []byte{0x68, 0xce, 0x3c, 0x80}
+)
+
+// The server doesn't decode the frames, the
+// slice data only has to be the right type.
+var (
+	cameraIDR    = []byte{0x65, 0x88, 0x84, 0x00, 0x33, 0xff}
+	cameraNonIDR = []byte{0x41, 0x9a, 0x02, 0x04, 0x33, 0xff}
+)
+
+// Camera behavior.
+const (
+	cameraFPS         = 10
+	cameraIDRInterval = 5 // Frames.
+)
+
+// Camera is a simulated camera that publishes a H264 stream to the
+// RTSP server of the NVR, the same way the stream of a virtual monitor
+// is published. It reconnects if the server closes the connection.
+type Camera struct {
+	host      string
+	monitorID string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	started   bool
+	paused    bool
+	connected bool
+	frames    int
+	mu        sync.Mutex
+}
+
+// NewCamera returns a camera that publishes to the monitor.
+// The camera doesn't connect until it's started.
+func (s *Server) NewCamera(monitorID string) *Camera {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Camera{
+		host:      "127.0.0.1:" + strconv.Itoa(s.Env.RTSPPort),
+		monitorID: monitorID,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	s.t.Cleanup(c.Close)
+	return c
+}
+
+// Start starts publishing the stream.
+func (c *Camera) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return
+	}
+	c.started = true
+	go c.run()
+}
+
+// Pause stops sending frames without closing the connection,
+// like a camera that stalled. Resume continues the stream.
+func (c *Camera) Pause() {
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+}
+
+// Resume continues a paused stream.
+func (c *Camera) Resume() {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+}
+
+// Connected returns true if the camera is publishing.
+func (c *Camera) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Frames returns the number of frames that have been sent.
+func (c *Camera) Frames() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frames
+}
+
+// Close disconnects the camera. Can be called multiple times.
+func (c *Camera) Close() {
+	c.cancel()
+	c.mu.Lock()
+	started := c.started
+	c.mu.Unlock()
+	if !started {
+		return
+	}
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func (c *Camera) run() {
+	defer close(c.done)
+	for {
+		// The path doesn't exist until the monitor has started and
+		// is removed when the monitor restarts, keep trying.
+		c.publish() //nolint:errcheck
+
+		c.mu.Lock()
+		c.connected = false
+		c.mu.Unlock()
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// ErrCameraRequest the server rejected a request.
+var ErrCameraRequest = errors.New("request failed")
+
+func (c *Camera) publish() error { //nolint:funlen
+	var d net.Dialer
+	nconn, err := d.DialContext(c.ctx, "tcp", c.host)
+	if err != nil {
+		return err
+	}
+	defer nconn.Close()
+
+	// Close the connection when the camera is closed.
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		nconn.Close()
+	}()
+
+	rconn := conn.NewConn(nconn)
+	pathURL, err := url.Parse("rtsp://" + c.host + "/" + c.monitorID)
+	if err != nil {
+		return err
+	}
+	trackURL, err := url.Parse("rtsp://" + c.host + "/" + c.monitorID + "/trackID=0")
+	if err != nil {
+		return err
+	}
+
+	track := &gortsplib.TrackH264{
+		PayloadType:       96,
+		SPS:               cameraSPS,
+		PPS:               cameraPPS,
+		PacketizationMode: 1,
+	}
+	track.SetControl("trackID=0")
+
+	cseq := 0
+	request := func(req base.Request) (*base.Response, error) {
+		cseq++
+		if req.Header == nil {
+			req.Header = base.Header{}
+		}
+		req.Header["CSeq"] = base.HeaderValue{fmt.Sprint(cseq)}
+		if err := rconn.WriteRequest(&req); err != nil {
+			return nil, err
+		}
+		res, err := rconn.ReadResponse()
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != base.StatusOK {
+			return nil, fmt.Errorf("%w: %v %v", ErrCameraRequest, req.Method, res.StatusCode)
+		}
+		return res, nil
+	}
+
+	_, err = request(base.Request{
+		Method: base.Announce,
+		URL:    pathURL,
+		Header: base.Header{"Content-Type": base.HeaderValue{"application/sdp"}},
+		Body:   gortsplib.Tracks{track}.Marshal(),
+	})
+	if err != nil {
+		return err
+	}
+
+	mode := headers.TransportModeRecord
+	transport := headers.Transport{
+		Mode:           &mode,
+		InterleavedIDs: &[2]int{0, 1},
+	}
+	res, err := request(base.Request{
+		Method: base.Setup,
+		URL:    trackURL,
+		Header: base.Header{"Transport": transport.Marshal()},
+	})
+	if err != nil {
+		return err
+	}
+
+	var session headers.Session
+	if err := session.Unmarshal(res.Header["Session"]); err != nil {
+		return err
+	}
+
+	_, err = request(base.Request{
+		Method: base.Record,
+		URL:    pathURL,
+		Header: base.Header{"Session": base.HeaderValue{session.Session}},
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.connected = true
+	c.mu.Unlock()
+
+	// Discard everything the server sends and detect disconnects.
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := rconn.ReadInterleavedFrameOrResponse(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	return c.stream(ctx, rconn, track, readErr)
+}
+
+func (c *Camera) stream(
+	ctx context.Context,
+	rconn *conn.Conn,
+	track *gortsplib.TrackH264,
+	readErr chan error,
+) error {
+	encoder := track.CreateEncoder()
+	buf := make([]byte, 2048)
+	ticker := time.NewTicker(time.Second / cameraFPS)
+	defer ticker.Stop()
+
+	start := time.Now()
+	frame := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		paused := c.paused
+		c.mu.Unlock()
+		if paused {
+			// Start the next frame with a IDR so the stream can be decoded.
+			frame = 0
+			continue
+		}
+
+		var nalus [][]byte
+		if frame%cameraIDRInterval == 0 {
+			nalus = [][]byte{cameraSPS, cameraPPS, cameraIDR}
+		} else {
+			nalus = [][]byte{cameraNonIDR}
+		}
+		frame++
+
+		packets, err := encoder.Encode(nalus, time.Since(start))
+		if err != nil {
+			return err
+		}
+		for _, pkt := range packets {
+			payload, err := pkt.Marshal()
+			if err != nil {
+				return err
+			}
+			fr := base.InterleavedFrame{Channel: 0, Payload: payload}
+			if err := rconn.WriteInterleavedFrame(&fr, buf); err != nil {
+				return err
+			}
+		}
+
+		c.mu.Lock()
+		c.frames++
+		c.mu.Unlock()
+	}
+}
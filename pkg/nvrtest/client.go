@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package nvrtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Client is a API client that authenticates as a user.
+type Client struct {
+	url      string
+	username string
+	password string
+
+	token string // CSRF token.
+	mu    sync.Mutex
+}
+
+// Client returns a API client for the user. Empty
+// credentials send requests without authentication.
+func (s *Server) Client(username string, password string) *Client {
+	return &Client{
+		url:      s.URL,
+		username: username,
+		password: password,
+	}
+}
+
+// AdminClient returns a API client for the admin account.
+func (s *Server) AdminClient() *Client {
+	return s.Client(AdminUsername, AdminPassword)
+}
+
+// Do sends a request to the path, the body is marshaled as JSON if not
+// nil. The CSRF token is included in requests that aren't GET requests.
+func (c *Client) Do(method string, path string, body interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if method != http.MethodGet {
+		token, err := c.Token()
+		if err != nil {
+			return nil, fmt.Errorf("csrf token: %w", err)
+		}
+		req.Header.Set("X-CSRF-TOKEN", token)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// ErrStatus unexpected response status.
+var ErrStatus = errors.New("unexpected status")
+
+// GetJSON sends a GET request and unmarshals the response into v.
+func (c *Client) GetJSON(path string, v interface{}) error {
+	res, err := c.Do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %v %v", ErrStatus, path, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Token returns the CSRF token of the user. Only admins can
+// request their token, empty for other users and anonymous clients.
+func (c *Client) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" || c.username == "" {
+		return c.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.url+"/api/user/my-token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", nil
+	default:
+		return "", fmt.Errorf("%w: %v", ErrStatus, res.Status)
+	}
+	token, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	c.token = string(token)
+	return c.token, nil
+}
+
+// WithInvalidToken returns a copy of the client that
+// sends a invalid CSRF token, for testing CSRF.
+func (c *Client) WithInvalidToken() *Client {
+	return &Client{
+		url:      c.url,
+		username: c.username,
+		password: c.password,
+		token:    "invalid",
+	}
+}
@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package nvrtest is a integration test harness. It runs the full
+// app, web, video and storage, in temporary directories and provides
+// simulated cameras and a API client for black-box tests.
+//
+// Addons are tested by importing them in the test file:
+//
+//	import _ "nvr/addons/myaddon"
+//
+//	func TestMyAddon(t *testing.T) {
+//		s := nvrtest.NewServer(t, nvrtest.Options{
+//			Monitors: []monitor.RawConfig{nvrtest.VirtualMonitor("m1")},
+//		})
+//		s.NewCamera("m1").Start()
+//		...
+//	}
+//
+// Only one server can run at the same time because the temporary
+// directory of the app is shared, tests must not be parallel.
+package nvrtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"nvr"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	// The server needs a authenticator.
+	_ "nvr/addons/auth/basic"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Accounts created by the harness.
+const (
+	AdminUsername = "admin"
+	AdminPassword = "admin-password"
+	UserUsername  = "user"
+	UserPassword  = "user-password"
+)
+
+// Options of the test server.
+type Options struct {
+	// Monitors are saved before the server starts.
+	Monitors []monitor.RawConfig
+
+	// Env overrides values in env.yaml, for example "hlsMemory".
+	Env map[string]interface{}
+}
+
+// VirtualMonitor returns the config of a enabled virtual monitor
+// that records 3 second videos from a published stream.
+func VirtualMonitor(id string) monitor.RawConfig {
+	return monitor.RawConfig{
+		"id":              id,
+		"name":            id,
+		"enable":          "true",
+		"monitorType":     monitor.MonitorTypeVirtual,
+		"timestampOffset": "0",
+		"videoLength":     "0.05",
+	}
+}
+
+// Server is a running NVR.
+type Server struct {
+	// URL of the web server, "http://127.0.0.1:port".
+	URL string
+	Env storage.ConfigEnv
+	App *nvr.App
+
+	t testing.TB
+}
+
+// NewServer starts a server in temporary directories.
+// The server is stopped when the test finishes.
+func NewServer(t testing.TB, opts Options) *Server {
+	t.Helper()
+
+	// The app clears "$TMPDIR/nvr" on start.
+	t.Setenv("TMPDIR", t.TempDir())
+
+	homeDir := t.TempDir()
+	configDir := filepath.Join(homeDir, "configs")
+	monitorsDir := filepath.Join(configDir, "monitors")
+	must(t, os.MkdirAll(monitorsDir, 0o700))
+
+	// The binaries must exist but are never called for
+	// virtual monitors, thumbnails fail and are logged.
+	fakeBin := filepath.Join(homeDir, "fake-bin")
+	must(t, os.WriteFile(fakeBin, []byte("#!/bin/sh\nexit 1\n"), 0o700)) //nolint:gosec
+
+	env := map[string]interface{}{
+		"port":        freePort(t),
+		"rtspPort":    freePort(t),
+		"hlsPort":     freePort(t),
+		"goBin":       fakeBin,
+		"ffmpegBin":   fakeBin,
+		"homeDir":     homeDir,
+		"secretStore": storage.SecretStoreNone,
+	}
+	for key, value := range opts.Env {
+		env[key] = value
+	}
+	envYAML, err := yaml.Marshal(env)
+	must(t, err)
+	envPath := filepath.Join(configDir, "env.yaml")
+	must(t, os.WriteFile(envPath, envYAML, 0o600))
+
+	must(t, writeUsers(filepath.Join(configDir, "users.json")))
+
+	for _, config := range opts.Monitors {
+		raw, err := json.Marshal(config)
+		must(t, err)
+		path := filepath.Join(monitorsDir, config["id"]+".json")
+		must(t, os.WriteFile(path, raw, 0o600))
+	}
+
+	wg := &sync.WaitGroup{}
+	app, err := nvr.NewApp(envPath, wg)
+	must(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fatal := make(chan error, 1)
+	go func() { fatal <- app.Start(ctx) }()
+
+	s := &Server{
+		URL: fmt.Sprintf("http://127.0.0.1:%v", app.Env.Port),
+		Env: app.Env,
+		App: app,
+		t:   t,
+	}
+	t.Cleanup(func() {
+		unregisterMonitors(app.Env.ConfigDir)
+		app.StopMonitors()
+		cancel()
+		wg.Wait()
+
+		ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel2()
+		app.Shutdown(ctx2) //nolint:errcheck
+		<-fatal
+		app.DB.Close()
+	})
+
+	if err := s.waitForWeb(fatal); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// ErrTimeout timed out waiting for a condition.
+var ErrTimeout = errors.New("timeout")
+
+func (s *Server) waitForWeb(fatal chan error) error {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-fatal:
+			fatal <- err
+			return fmt.Errorf("start app: %w", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		res, err := http.Get(s.URL + "/logout")
+		if err == nil {
+			res.Body.Close()
+			return nil
+		}
+	}
+	return fmt.Errorf("web server: %w", ErrTimeout)
+}
+
+// WaitFor calls fn until it returns true and fails the test
+// if it doesn't return true before the timeout.
+func (s *Server) WaitFor(timeout time.Duration, msg string, fn func() bool) {
+	s.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	s.t.Fatalf("%v: %v", msg, ErrTimeout)
+}
+
+// ErrMonitorNotRunning the monitor isn't running.
+var ErrMonitorNotRunning = errors.New("monitor is not running")
+
+// SendEvent sends a event to the monitor, like a detector addon.
+func (s *Server) SendEvent(monitorID string, event storage.Event) error {
+	monitorsMu.Lock()
+	m, exist := monitors[monitorKey(s.Env.ConfigDir, monitorID)]
+	monitorsMu.Unlock()
+	if !exist {
+		return fmt.Errorf("%w: %v", ErrMonitorNotRunning, monitorID)
+	}
+	return m.SendEvent(event)
+}
+
+// Running monitors of all servers by config directory and monitor ID.
+var (
+	monitors   = make(map[string]*monitor.Monitor)
+	monitorsMu sync.Mutex
+)
+
+func init() {
+	nvr.RegisterMonitorStartHook(func(_ context.Context, m *monitor.Monitor) {
+		monitorsMu.Lock()
+		defer monitorsMu.Unlock()
+		monitors[monitorKey(m.Env.ConfigDir, m.Config.ID())] = m
+	})
+}
+
+func monitorKey(configDir string, monitorID string) string {
+	return configDir + "/" + monitorID
+}
+
+func unregisterMonitors(configDir string) {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+	for key, m := range monitors {
+		if m.Env.ConfigDir == configDir {
+			delete(monitors, key)
+		}
+	}
+}
+
+func writeUsers(path string) error {
+	accounts := make(map[string]auth.Account)
+	for i, user := range []struct {
+		username string
+		password string
+		isAdmin  bool
+	}{
+		{AdminUsername, AdminPassword, true},
+		{UserUsername, UserPassword, false},
+	} {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.password), bcrypt.MinCost)
+		if err != nil {
+			return err
+		}
+		id := fmt.Sprint(i + 1)
+		accounts[id] = auth.Account{
+			ID:       id,
+			Username: user.username,
+			Password: hash,
+			IsAdmin:  user.isAdmin,
+		}
+	}
+	raw, err := json.Marshal(accounts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
+
+func freePort(t testing.TB) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	must(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func must(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
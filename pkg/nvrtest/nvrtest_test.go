@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package nvrtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/video"
+	"nvr/pkg/video/gortsplib/pkg/h264"
+	"nvr/pkg/web/apiclient"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCameraSPS(t *testing.T) {
+	var sps h264.SPS
+	require.NoError(t, sps.Unmarshal(cameraSPS))
+	require.Equal(t, 320, sps.Width())
+	require.Equal(t, 240, sps.Height())
+	require.Equal(t, uint32(2), sps.PicOrderCntType)
+}
+
+func TestAuth(t *testing.T) {
+	s := NewServer(t, Options{})
+
+	status := func(c *Client, method string, path string) int {
+		res, err := c.Do(method, path, nil)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	anonymous := s.Client("", "")
+	user := s.Client(UserUsername, UserPassword)
+	admin := s.AdminClient()
+
+	require.Equal(t, http.StatusUnauthorized, status(anonymous, http.MethodGet, "/api/monitor/list"))
+	require.Equal(t, http.StatusOK, status(user, http.MethodGet, "/api/monitor/list"))
+	require.Equal(t, http.StatusOK, status(admin, http.MethodGet, "/api/monitor/list"))
+
+	require.Equal(t, http.StatusUnauthorized, status(user, http.MethodGet, "/api/users"))
+	require.Equal(t, http.StatusOK, status(admin, http.MethodGet, "/api/users"))
+
+	wrongPassword := s.Client(AdminUsername, "x")
+	require.Equal(t, http.StatusUnauthorized, status(wrongPassword, http.MethodGet, "/api/users"))
+
+	// CSRF.
+	path := "/api/recording/delete/2000-01-01_00-00-00_x"
+	invalidToken := admin.WithInvalidToken()
+	require.Equal(t, http.StatusUnauthorized, status(invalidToken, http.MethodDelete, path))
+	require.Equal(t, http.StatusNotFound, status(admin, http.MethodDelete, path))
+}
+
+func TestRecording(t *testing.T) {
+	s := NewServer(t, Options{
+		Monitors: []monitor.RawConfig{VirtualMonitor("m1")},
+	})
+	admin := s.AdminClient()
+
+	camera := s.NewCamera("m1")
+	camera.Start()
+
+	pathReady := func() bool {
+		var paths []video.PathStats
+		require.NoError(t, admin.GetJSON("/api/video/paths", &paths))
+		for _, path := range paths {
+			if path.Name == "m1" {
+				return path.Ready
+			}
+		}
+		return false
+	}
+	s.WaitFor(10*time.Second, "path ready", pathReady)
+	require.True(t, camera.Connected())
+
+	// The recording starts at the next segment, events
+	// before the start of the recording aren't included.
+	err := s.SendEvent("m1", storage.Event{
+		Time:        time.Now().Add(time.Second),
+		Detections:  []storage.Detection{{Label: "person", Score: 90}},
+		Duration:    time.Second,
+		RecDuration: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	query := "/api/recording/query?limit=10&time=9999-12-31_23-59-59&data=true"
+	var recordings []storage.Recording
+	s.WaitFor(20*time.Second, "recording", func() bool {
+		require.NoError(t, admin.GetJSON(query, &recordings))
+		return len(recordings) != 0
+	})
+	rec := recordings[0]
+	require.True(t, strings.HasSuffix(rec.ID, "_m1"), rec.ID)
+	require.NotNil(t, rec.Data)
+	require.NotEmpty(t, rec.Data.Events)
+	require.Equal(t, "person", rec.Data.Events[0].Detections[0].Label)
+
+	// Delete.
+	res, err := admin.Do(http.MethodDelete, "/api/recording/delete/"+rec.ID, nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	require.NoError(t, admin.GetJSON(query, &recordings))
+	for _, r := range recordings {
+		require.NotEqual(t, rec.ID, r.ID)
+	}
+
+	// Disconnect.
+	camera.Close()
+	s.WaitFor(10*time.Second, "path not ready", func() bool { return !pathReady() })
+}
+
+func TestAPIClient(t *testing.T) {
+	s := NewServer(t, Options{
+		Monitors: []monitor.RawConfig{VirtualMonitor("m1")},
+	})
+	ctx := context.Background()
+	admin := apiclient.New(s.URL, AdminUsername, AdminPassword)
+
+	monitors, err := admin.MonitorList(ctx, apiclient.MonitorListParams{})
+	require.NoError(t, err)
+	require.Contains(t, monitors, "m1")
+
+	page, err := admin.MonitorListList(ctx, apiclient.MonitorListParams{}, apiclient.ListParams{Fields: []string{"id"}})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+
+	// CSRF.
+	err = admin.MonitorArm(ctx, apiclient.MonitorArmParams{ID: "m1", State: monitor.ArmDisarm})
+	require.NoError(t, err)
+	state, err := admin.MonitorArmState(ctx, apiclient.MonitorArmStateParams{ID: "m1"})
+	require.NoError(t, err)
+	require.Equal(t, monitor.ArmDisarm, state.Override)
+
+	spec, err := admin.Spec(ctx)
+	require.NoError(t, err)
+	require.Contains(t, spec, "paths")
+
+	user := apiclient.New(s.URL, UserUsername, UserPassword)
+	_, err = user.Users(ctx)
+	var statusErr *apiclient.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
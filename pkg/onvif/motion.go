@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package onvif
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MotionConfig is the cell motion detection config of the camera.
+// The image is divided into a grid of cells, motion is only
+// detected in the active cells. The grid size is set by the camera.
+type MotionConfig struct {
+	// Sensitivity from 0 to 100.
+	Sensitivity int `json:"sensitivity"`
+
+	Columns int `json:"columns"`
+	Rows    int `json:"rows"`
+
+	// One string per row with a "1" for each
+	// active cell and a "0" for inactive cells.
+	Cells []string `json:"cells"`
+}
+
+// ErrInvalidMotionConfig invalid motion config.
+var ErrInvalidMotionConfig = errors.New("invalid motion config")
+
+func (c MotionConfig) validate() error {
+	if c.Sensitivity < 0 || c.Sensitivity > 100 {
+		return fmt.Errorf("%w: sensitivity must be between 0 and 100", ErrInvalidMotionConfig)
+	}
+	if len(c.Cells) != c.Rows {
+		return fmt.Errorf("%w: expected %v rows, got %v", ErrInvalidMotionConfig, c.Rows, len(c.Cells))
+	}
+	for i, row := range c.Cells {
+		if len(row) != c.Columns || strings.Trim(row, "01") != "" {
+			return fmt.Errorf("%w: row %v must be %v zeros or ones", ErrInvalidMotionConfig, i, c.Columns)
+		}
+	}
+	return nil
+}
+
+type simpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+type vector struct {
+	X string `xml:"x,attr"`
+	Y string `xml:"y,attr"`
+}
+
+type cellLayout struct {
+	Columns   int     `xml:"Columns,attr"`
+	Rows      int     `xml:"Rows,attr"`
+	Translate *vector `xml:"Transformation>Translate"`
+	Scale     *vector `xml:"Transformation>Scale"`
+}
+
+// analyticsItem is a analytics module or rule.
+type analyticsItem struct {
+	Name        string       `xml:"Name,attr"`
+	Type        string       `xml:"Type,attr"`
+	SimpleItems []simpleItem `xml:"Parameters>SimpleItem"`
+	Layout      *cellLayout  `xml:"Parameters>ElementItem>CellLayout"`
+}
+
+// isType compares the local name of the type, the namespace prefix may vary.
+func (i analyticsItem) isType(localName string) bool {
+	_, name, found := strings.Cut(i.Type, ":")
+	if !found {
+		name = i.Type
+	}
+	return name == localName
+}
+
+func (i analyticsItem) get(name string) string {
+	for _, item := range i.SimpleItems {
+		if item.Name == name {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+// set updates the item or appends it if it doesn't exist.
+func (i *analyticsItem) set(name string, value string) {
+	for j, item := range i.SimpleItems {
+		if item.Name == name {
+			i.SimpleItems[j].Value = value
+			return
+		}
+	}
+	i.SimpleItems = append(i.SimpleItems, simpleItem{Name: name, Value: value})
+}
+
+// marshal returns the item as a element of the analytics service.
+func (i analyticsItem) marshal(element string) string {
+	_, typ, found := strings.Cut(i.Type, ":")
+	if !found {
+		typ = i.Type
+	}
+	var b strings.Builder
+	b.WriteString(`<tan:` + element + ` Name="` + escape(i.Name) + `" Type="tt:` + escape(typ) + `">`)
+	b.WriteString(`<tt:Parameters>`)
+	for _, item := range i.SimpleItems {
+		b.WriteString(`<tt:SimpleItem Name="` + escape(item.Name) + `" Value="` + escape(item.Value) + `"/>`)
+	}
+	if l := i.Layout; l != nil {
+		b.WriteString(`<tt:ElementItem Name="Layout"><tt:CellLayout Columns="` +
+			strconv.Itoa(l.Columns) + `" Rows="` + strconv.Itoa(l.Rows) + `">`)
+		b.WriteString(`<tt:Transformation>`)
+		if l.Translate != nil {
+			b.WriteString(`<tt:Translate x="` + escape(l.Translate.X) + `" y="` + escape(l.Translate.Y) + `"/>`)
+		}
+		if l.Scale != nil {
+			b.WriteString(`<tt:Scale x="` + escape(l.Scale.X) + `" y="` + escape(l.Scale.Y) + `"/>`)
+		}
+		b.WriteString(`</tt:Transformation></tt:CellLayout></tt:ElementItem>`)
+	}
+	b.WriteString(`</tt:Parameters></tan:` + element + `>`)
+	return b.String()
+}
+
+// motionAnalytics is the cell motion engine and detector of a configuration.
+type motionAnalytics struct {
+	token  string
+	module analyticsItem
+	rule   analyticsItem
+}
+
+func (c *Client) motionAnalytics(ctx context.Context, mediaService string) (motionAnalytics, error) {
+	var res struct {
+		Configurations []struct {
+			Token   string          `xml:"token,attr"`
+			Modules []analyticsItem `xml:"AnalyticsEngineConfiguration>AnalyticsModule"`
+			Rules   []analyticsItem `xml:"RuleEngineConfiguration>Rule"`
+		} `xml:"Body>GetVideoAnalyticsConfigurationsResponse>Configurations"`
+	}
+	body := `<trt:GetVideoAnalyticsConfigurations/>`
+	if err := c.call(ctx, mediaService, body, &res); err != nil {
+		return motionAnalytics{}, fmt.Errorf("get video analytics configurations: %w", err)
+	}
+
+	for _, config := range res.Configurations {
+		var a motionAnalytics
+		var hasModule, hasRule bool
+		for _, module := range config.Modules {
+			if module.isType("CellMotionEngine") && module.Layout != nil {
+				a.module, hasModule = module, true
+				break
+			}
+		}
+		for _, rule := range config.Rules {
+			if rule.isType("CellMotionDetector") {
+				a.rule, hasRule = rule, true
+				break
+			}
+		}
+		if hasModule && hasRule {
+			a.token = config.Token
+			return a, nil
+		}
+	}
+	return motionAnalytics{}, fmt.Errorf("cell motion detection: %w", ErrNotSupported)
+}
+
+// GetMotion returns the cell motion detection config of the camera.
+func (c *Client) GetMotion(ctx context.Context) (MotionConfig, error) {
+	s, err := c.services(ctx)
+	if err != nil {
+		return MotionConfig{}, err
+	}
+	a, err := c.motionAnalytics(ctx, s.media)
+	if err != nil {
+		return MotionConfig{}, err
+	}
+
+	sensitivity, err := strconv.Atoi(a.module.get("Sensitivity"))
+	if err != nil {
+		return MotionConfig{}, fmt.Errorf("%w: sensitivity: %v", ErrUnexpected, err)
+	}
+	columns, rows := a.module.Layout.Columns, a.module.Layout.Rows
+	cells, err := decodeActiveCells(a.rule.get("ActiveCells"), columns, rows)
+	if err != nil {
+		return MotionConfig{}, fmt.Errorf("%w: active cells: %v", ErrUnexpected, err)
+	}
+	return MotionConfig{
+		Sensitivity: sensitivity,
+		Columns:     columns,
+		Rows:        rows,
+		Cells:       cells,
+	}, nil
+}
+
+// SetMotion updates the sensitivity and active cells of the camera.
+// The grid size must match the camera, see GetMotion. Other
+// parameters of the motion module and rule are kept.
+func (c *Client) SetMotion(ctx context.Context, config MotionConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	s, err := c.services(ctx)
+	if err != nil {
+		return err
+	}
+	if s.analytics == "" {
+		return fmt.Errorf("analytics service: %w", ErrNotSupported)
+	}
+	a, err := c.motionAnalytics(ctx, s.media)
+	if err != nil {
+		return err
+	}
+	if config.Columns != a.module.Layout.Columns || config.Rows != a.module.Layout.Rows {
+		return fmt.Errorf("%w: camera grid is %vx%v", ErrInvalidMotionConfig,
+			a.module.Layout.Columns, a.module.Layout.Rows)
+	}
+
+	a.module.set("Sensitivity", strconv.Itoa(config.Sensitivity))
+	body := `<tan:ModifyAnalyticsModules><tan:ConfigurationToken>` + escape(a.token) +
+		`</tan:ConfigurationToken>` + a.module.marshal("AnalyticsModule") + `</tan:ModifyAnalyticsModules>`
+	if err := c.call(ctx, s.analytics, body, &struct{}{}); err != nil {
+		return fmt.Errorf("modify analytics modules: %w", err)
+	}
+
+	a.rule.set("ActiveCells", encodeActiveCells(config.Cells))
+	body = `<tan:ModifyRules><tan:ConfigurationToken>` + escape(a.token) +
+		`</tan:ConfigurationToken>` + a.rule.marshal("Rule") + `</tan:ModifyRules>`
+	if err := c.call(ctx, s.analytics, body, &struct{}{}); err != nil {
+		return fmt.Errorf("modify rules: %w", err)
+	}
+	return nil
+}
+
+// decodeActiveCells decodes the base64 encoded and PackBits compressed
+// bitmap of active cells. The cells are ordered row by row, the most
+// significant bit is the first cell. Missing cells are inactive.
+func decodeActiveCells(value string, columns, rows int) ([]string, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	bitmap, err := unpackBits(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]string, rows)
+	for row := 0; row < rows; row++ {
+		var b strings.Builder
+		for col := 0; col < columns; col++ {
+			i := row*columns + col
+			if i/8 < len(bitmap) && bitmap[i/8]&(0x80>>(i%8)) != 0 {
+				b.WriteByte('1')
+			} else {
+				b.WriteByte('0')
+			}
+		}
+		cells[row] = b.String()
+	}
+	return cells, nil
+}
+
+func encodeActiveCells(cells []string) string {
+	bits := strings.Join(cells, "")
+	bitmap := make([]byte, (len(bits)+7)/8)
+	for i, c := range bits {
+		if c == '1' {
+			bitmap[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(packBits(bitmap))
+}
+
+var errPackBits = errors.New("truncated packbits data")
+
+// unpackBits decompresses PackBits data.
+func unpackBits(data []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := int(int8(data[i]))
+		i++
+		switch {
+		case n >= 0:
+			if i+n+1 > len(data) {
+				return nil, errPackBits
+			}
+			out = append(out, data[i:i+n+1]...)
+			i += n + 1
+		case n != -128:
+			if i >= len(data) {
+				return nil, errPackBits
+			}
+			for j := 0; j < 1-n; j++ {
+				out = append(out, data[i])
+			}
+			i++
+		}
+	}
+	return out, nil
+}
+
+// packBits compresses data with PackBits. Runs of three or
+// more equal bytes are repeated, everything else is literal.
+func packBits(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && run < 128 && data[i+run] == data[i] {
+			run++
+		}
+		if run >= 3 {
+			out = append(out, byte(1-run), data[i])
+			i += run
+			continue
+		}
+
+		// Literal until the next run of three.
+		start := i
+		for i < len(data) && i-start < 128 {
+			if i+2 < len(data) && data[i] == data[i+1] && data[i] == data[i+2] {
+				break
+			}
+			i++
+		}
+		out = append(out, byte(i-start-1))
+		out = append(out, data[start:i]...)
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package onvif
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeader(t *testing.T) {
+	nonce := make([]byte, 16)
+	for i := range nonce {
+		nonce[i] = byte(i)
+	}
+	header := securityHeader("a&b", "pass", nonce, "2000-01-01T00:00:00.000Z")
+	require.Contains(t, header, "<wsse:Username>a&amp;b</wsse:Username>")
+	require.Contains(t, header, "#PasswordDigest\">synpu7taswQ5uXCsN31A1yUImZk=</wsse:Password>")
+	require.Contains(t, header, "#Base64Binary\">AAECAwQFBgcICQoLDA0ODw==</wsse:Nonce>")
+	require.Contains(t, header, "<wsu:Created>2000-01-01T00:00:00.000Z</wsu:Created>")
+}
+
+func TestPackBits(t *testing.T) {
+	// Example from the TIFF specification.
+	packed := []byte{
+		0xfe, 0xaa, 0x02, 0x80, 0x00, 0x2a, 0xfd, 0xaa,
+		0x03, 0x80, 0x00, 0x2a, 0x22, 0xf7, 0xaa,
+	}
+	unpacked := []byte{
+		0xaa, 0xaa, 0xaa, 0x80, 0x00, 0x2a, 0xaa, 0xaa, 0xaa, 0xaa, 0x80, 0x00,
+		0x2a, 0x22, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa,
+	}
+	actual, err := unpackBits(packed)
+	require.NoError(t, err)
+	require.Equal(t, unpacked, actual)
+
+	actual, err = unpackBits(packBits(unpacked))
+	require.NoError(t, err)
+	require.Equal(t, unpacked, actual)
+
+	long := make([]byte, 300)
+	for i := 200; i < len(long); i++ {
+		long[i] = byte(i)
+	}
+	actual, err = unpackBits(packBits(long))
+	require.NoError(t, err)
+	require.Equal(t, long, actual)
+
+	_, err = unpackBits([]byte{0x02, 0x00})
+	require.ErrorIs(t, err, errPackBits)
+}
+
+func TestActiveCells(t *testing.T) {
+	cells, err := decodeActiveCells("AMM=", 4, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1100", "0011"}, cells)
+	require.Equal(t, "AMM=", encodeActiveCells(cells))
+
+	// Missing cells are inactive.
+	cells, err = decodeActiveCells("AMM=", 4, 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1100", "0011", "0000"}, cells)
+}
+
+const (
+	capabilitiesResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope"
+ xmlns:tds="http://www.onvif.org/ver10/device/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+<SOAP-ENV:Body><tds:GetCapabilitiesResponse><tds:Capabilities>
+<tt:Analytics><tt:XAddr>SERVER/onvif/analytics</tt:XAddr></tt:Analytics>
+<tt:Media><tt:XAddr>SERVER/onvif/media</tt:XAddr></tt:Media>
+</tds:Capabilities></tds:GetCapabilitiesResponse></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+
+	analyticsResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"
+ xmlns:trt="http://www.onvif.org/ver10/media/wsdl" xmlns:ns="http://www.onvif.org/ver10/schema">
+<env:Body><trt:GetVideoAnalyticsConfigurationsResponse>
+<trt:Configurations token="VideoAnalytics_1">
+<ns:Name>a</ns:Name>
+<ns:AnalyticsEngineConfiguration>
+<ns:AnalyticsModule Name="MyCellMotion" Type="ns:CellMotionEngine"><ns:Parameters>
+<ns:SimpleItem Name="Sensitivity" Value="50"/>
+<ns:ElementItem Name="Layout"><ns:CellLayout Columns="4" Rows="2"><ns:Transformation>
+<ns:Translate x="-1.0" y="-1.0"/><ns:Scale x="0.5" y="1.0"/>
+</ns:Transformation></ns:CellLayout></ns:ElementItem>
+</ns:Parameters></ns:AnalyticsModule>
+</ns:AnalyticsEngineConfiguration>
+<ns:RuleEngineConfiguration>
+<ns:Rule Name="MyMotionRule" Type="ns:CellMotionDetector"><ns:Parameters>
+<ns:SimpleItem Name="MinCount" Value="5"/>
+<ns:SimpleItem Name="ActiveCells" Value="AMM="/>
+</ns:Parameters></ns:Rule>
+</ns:RuleEngineConfiguration>
+</trt:Configurations>
+</trt:GetVideoAnalyticsConfigurationsResponse></env:Body></env:Envelope>`
+
+	emptyResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body/></env:Envelope>`
+
+	faultResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"
+ xmlns:ter="http://www.onvif.org/ver10/error"><env:Body><env:Fault>
+<env:Code><env:Value>env:Sender</env:Value>
+<env:Subcode><env:Value>ter:NotAuthorized</env:Value></env:Subcode></env:Code>
+<env:Reason><env:Text xml:lang="en">Sender not Authorized</env:Text></env:Reason>
+</env:Fault></env:Body></env:Envelope>`
+)
+
+// newTestCamera returns a client connected to a fake camera.
+// The bodies of the requests to the analytics service are
+// sent to the modify channel.
+func newTestCamera(t *testing.T, modify chan<- string) *Client {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body := string(raw)
+
+		if !strings.Contains(body, "<wsse:Username>admin</wsse:Username>") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(faultResponse)) //nolint:errcheck
+			return
+		}
+
+		switch r.URL.Path {
+		case "/onvif/device_service":
+			w.Write([]byte(strings.ReplaceAll(capabilitiesResponse, "SERVER", server.URL))) //nolint:errcheck
+		case "/onvif/media":
+			w.Write([]byte(analyticsResponse)) //nolint:errcheck
+		case "/onvif/analytics":
+			modify <- body
+			w.Write([]byte(emptyResponse)) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL+"/onvif/device_service", "admin", "pass", nil)
+}
+
+func TestGetMotion(t *testing.T) {
+	c := newTestCamera(t, nil)
+	config, err := c.GetMotion(context.Background())
+	require.NoError(t, err)
+
+	expected := MotionConfig{
+		Sensitivity: 50,
+		Columns:     4,
+		Rows:        2,
+		Cells:       []string{"1100", "0011"},
+	}
+	require.Equal(t, expected, config)
+
+	t.Run("unauthorized", func(t *testing.T) {
+		c := newTestCamera(t, nil)
+		c.username = "x"
+		_, err := c.GetMotion(context.Background())
+		require.ErrorIs(t, err, ErrFault)
+		require.Contains(t, err.Error(), "ter:NotAuthorized Sender not Authorized")
+	})
+}
+
+func TestSetMotion(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		modify := make(chan string, 2)
+		c := newTestCamera(t, modify)
+
+		config := MotionConfig{
+			Sensitivity: 80,
+			Columns:     4,
+			Rows:        2,
+			Cells:       []string{"1111", "0000"},
+		}
+		require.NoError(t, c.SetMotion(context.Background(), config))
+
+		module := <-modify
+		require.Contains(t, module, `<tan:ModifyAnalyticsModules><tan:ConfigurationToken>VideoAnalytics_1<`)
+		require.Contains(t, module, `<tan:AnalyticsModule Name="MyCellMotion" Type="tt:CellMotionEngine">`)
+		require.Contains(t, module, `<tt:SimpleItem Name="Sensitivity" Value="80"/>`)
+		require.Contains(t, module, `<tt:CellLayout Columns="4" Rows="2"><tt:Transformation>`+
+			`<tt:Translate x="-1.0" y="-1.0"/><tt:Scale x="0.5" y="1.0"/>`)
+
+		rule := <-modify
+		require.Contains(t, rule, `<tan:ModifyRules><tan:ConfigurationToken>VideoAnalytics_1<`)
+		require.Contains(t, rule, `<tt:SimpleItem Name="MinCount" Value="5"/>`)
+		require.Contains(t, rule, `<tt:SimpleItem Name="ActiveCells" Value="APA="/>`)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		c := newTestCamera(t, nil)
+		cases := []MotionConfig{
+			{Sensitivity: 101, Columns: 4, Rows: 2, Cells: []string{"1111", "0000"}},
+			{Sensitivity: 50, Columns: 4, Rows: 2, Cells: []string{"1111"}},
+			{Sensitivity: 50, Columns: 4, Rows: 2, Cells: []string{"1111", "00x0"}},
+			{Sensitivity: 50, Columns: 2, Rows: 1, Cells: []string{"11"}},
+		}
+		for _, tc := range cases {
+			err := c.SetMotion(context.Background(), tc)
+			require.ErrorIs(t, err, ErrInvalidMotionConfig)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"net/http"
+	"nvr/pkg/rpc/nvrpb"
+	"nvr/pkg/web/auth"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// NewGRPCServer returns a gRPC server with the service registered. All
+// calls are authenticated, see authenticator. The server uses TLS if
+// tlsConfig isn't nil.
+func NewGRPCServer(
+	s *Server,
+	a auth.Authenticator,
+	tokens []string,
+	tlsConfig *tls.Config,
+) *grpc.Server {
+	authenticator := &authenticator{auth: a, tokens: tokens}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(authenticator.unary),
+		grpc.StreamInterceptor(authenticator.stream),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	nvrpb.RegisterNVRServer(server, s)
+	return server
+}
+
+// Methods that require admin privileges, like their REST counterparts.
+var adminMethods = map[string]bool{
+	nvrpb.NVR_StreamLogs_FullMethodName: true,
+	nvrpb.NVR_ExportLogs_FullMethodName: true,
+}
+
+// authenticator validates the "authorization" metadata of calls. API
+// tokens are sent as "Bearer <token>" and have admin privileges. Other
+// values are validated by the authenticator like the header of HTTP
+// requests, for example "Basic <base64>". Streams are validated again
+// for every message, they're closed if the user was deleted, demoted
+// or had their password changed. Users that must change their
+// password are rejected until they have changed it.
+type authenticator struct {
+	auth   auth.Authenticator
+	tokens []string
+}
+
+func (a *authenticator) unary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if err := a.authenticate(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := a.authenticate(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, &authStream{
+		ServerStream: ss,
+		authenticate: func() error {
+			return a.authenticate(ss.Context(), info.FullMethod)
+		},
+	})
+}
+
+// authStream authenticates the stream before every message.
+type authStream struct {
+	grpc.ServerStream
+	authenticate func() error
+}
+
+func (s *authStream) SendMsg(m interface{}) error {
+	if err := s.authenticate(); err != nil {
+		return err
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *authStream) RecvMsg(m interface{}) error {
+	if err := s.authenticate(); err != nil {
+		return err
+	}
+	return s.ServerStream.RecvMsg(m)
+}
+
+func (a *authenticator) authenticate(ctx context.Context, method string) error {
+	var authorization string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) != 0 {
+		authorization = values[0]
+	}
+
+	if token, isToken := strings.CutPrefix(authorization, "Bearer "); isToken {
+		if !a.validToken(token) {
+			return status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil
+	}
+
+	r := &http.Request{Header: http.Header{}}
+	r.Header.Set("Authorization", authorization)
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	res := a.auth.ValidateRequest(r)
+	switch {
+	case !res.LockedUntil.IsZero():
+		return status.Error(codes.ResourceExhausted, "too many failed login attempts")
+	case !res.IsValid:
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	case res.User.MustChangePassword && res.Impersonator == nil:
+		return status.Error(codes.PermissionDenied, "password change required")
+	case adminMethods[method] && !res.User.IsAdmin:
+		return status.Error(codes.PermissionDenied, "admin privileges required")
+	}
+	return nil
+}
+
+// validToken compares the token to all tokens in constant time.
+func (a *authenticator) validToken(token string) bool {
+	valid := 0
+	for _, t := range a.tokens {
+		valid |= subtle.ConstantTimeCompare([]byte(token), []byte(t))
+	}
+	return valid == 1
+}
@@ -0,0 +1,394 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: nvrpb/nvr.proto
+
+package nvrpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	NVR_ListMonitors_FullMethodName    = "/nvr.v1.NVR/ListMonitors"
+	NVR_ListGroups_FullMethodName      = "/nvr.v1.NVR/ListGroups"
+	NVR_QueryRecordings_FullMethodName = "/nvr.v1.NVR/QueryRecordings"
+	NVR_StreamEvents_FullMethodName    = "/nvr.v1.NVR/StreamEvents"
+	NVR_StreamLogs_FullMethodName      = "/nvr.v1.NVR/StreamLogs"
+	NVR_ExportLogs_FullMethodName      = "/nvr.v1.NVR/ExportLogs"
+)
+
+// NVRClient is the client API for NVR service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NVRClient interface {
+	// Censored monitor configs, see GET /api/monitor/list.
+	ListMonitors(ctx context.Context, in *ListMonitorsRequest, opts ...grpc.CallOption) (*ListMonitorsResponse, error)
+	// Monitor groups, see GET /api/group/configs.
+	ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error)
+	// Recordings, see GET /api/recording/query.
+	QueryRecordings(ctx context.Context, in *QueryRecordingsRequest, opts ...grpc.CallOption) (*QueryRecordingsResponse, error)
+	// Live events of all monitors, see WS /api/monitor/events.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (NVR_StreamEventsClient, error)
+	// Live logs, see WS /api/log/feed. Requires admin.
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (NVR_StreamLogsClient, error)
+	// All stored logs that match, newest first. The stream
+	// ends after the oldest entry, see GET /api/log/query
+	// with format. Requires admin.
+	ExportLogs(ctx context.Context, in *ExportLogsRequest, opts ...grpc.CallOption) (NVR_ExportLogsClient, error)
+}
+
+type nVRClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNVRClient(cc grpc.ClientConnInterface) NVRClient {
+	return &nVRClient{cc}
+}
+
+func (c *nVRClient) ListMonitors(ctx context.Context, in *ListMonitorsRequest, opts ...grpc.CallOption) (*ListMonitorsResponse, error) {
+	out := new(ListMonitorsResponse)
+	err := c.cc.Invoke(ctx, NVR_ListMonitors_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nVRClient) ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error) {
+	out := new(ListGroupsResponse)
+	err := c.cc.Invoke(ctx, NVR_ListGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nVRClient) QueryRecordings(ctx context.Context, in *QueryRecordingsRequest, opts ...grpc.CallOption) (*QueryRecordingsResponse, error) {
+	out := new(QueryRecordingsResponse)
+	err := c.cc.Invoke(ctx, NVR_QueryRecordings_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nVRClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (NVR_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &NVR_ServiceDesc.Streams[0], NVR_StreamEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nVRStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NVR_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type nVRStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *nVRStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nVRClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (NVR_StreamLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &NVR_ServiceDesc.Streams[1], NVR_StreamLogs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nVRStreamLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NVR_StreamLogsClient interface {
+	Recv() (*LogEntry, error)
+	grpc.ClientStream
+}
+
+type nVRStreamLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *nVRStreamLogsClient) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nVRClient) ExportLogs(ctx context.Context, in *ExportLogsRequest, opts ...grpc.CallOption) (NVR_ExportLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &NVR_ServiceDesc.Streams[2], NVR_ExportLogs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nVRExportLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NVR_ExportLogsClient interface {
+	Recv() (*LogEntry, error)
+	grpc.ClientStream
+}
+
+type nVRExportLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *nVRExportLogsClient) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NVRServer is the server API for NVR service.
+// All implementations must embed UnimplementedNVRServer
+// for forward compatibility
+type NVRServer interface {
+	// Censored monitor configs, see GET /api/monitor/list.
+	ListMonitors(context.Context, *ListMonitorsRequest) (*ListMonitorsResponse, error)
+	// Monitor groups, see GET /api/group/configs.
+	ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error)
+	// Recordings, see GET /api/recording/query.
+	QueryRecordings(context.Context, *QueryRecordingsRequest) (*QueryRecordingsResponse, error)
+	// Live events of all monitors, see WS /api/monitor/events.
+	StreamEvents(*StreamEventsRequest, NVR_StreamEventsServer) error
+	// Live logs, see WS /api/log/feed. Requires admin.
+	StreamLogs(*StreamLogsRequest, NVR_StreamLogsServer) error
+	// All stored logs that match, newest first. The stream
+	// ends after the oldest entry, see GET /api/log/query
+	// with format. Requires admin.
+	ExportLogs(*ExportLogsRequest, NVR_ExportLogsServer) error
+	mustEmbedUnimplementedNVRServer()
+}
+
+// UnimplementedNVRServer must be embedded to have forward compatible implementations.
+type UnimplementedNVRServer struct {
+}
+
+func (UnimplementedNVRServer) ListMonitors(context.Context, *ListMonitorsRequest) (*ListMonitorsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMonitors not implemented")
+}
+func (UnimplementedNVRServer) ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (UnimplementedNVRServer) QueryRecordings(context.Context, *QueryRecordingsRequest) (*QueryRecordingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryRecordings not implemented")
+}
+func (UnimplementedNVRServer) StreamEvents(*StreamEventsRequest, NVR_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedNVRServer) StreamLogs(*StreamLogsRequest, NVR_StreamLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedNVRServer) ExportLogs(*ExportLogsRequest, NVR_ExportLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportLogs not implemented")
+}
+func (UnimplementedNVRServer) mustEmbedUnimplementedNVRServer() {}
+
+// UnsafeNVRServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NVRServer will
+// result in compilation errors.
+type UnsafeNVRServer interface {
+	mustEmbedUnimplementedNVRServer()
+}
+
+func RegisterNVRServer(s grpc.ServiceRegistrar, srv NVRServer) {
+	s.RegisterService(&NVR_ServiceDesc, srv)
+}
+
+func _NVR_ListMonitors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMonitorsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NVRServer).ListMonitors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NVR_ListMonitors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NVRServer).ListMonitors(ctx, req.(*ListMonitorsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NVR_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NVRServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NVR_ListGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NVRServer).ListGroups(ctx, req.(*ListGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NVR_QueryRecordings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRecordingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NVRServer).QueryRecordings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NVR_QueryRecordings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NVRServer).QueryRecordings(ctx, req.(*QueryRecordingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NVR_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NVRServer).StreamEvents(m, &nVRStreamEventsServer{stream})
+}
+
+type NVR_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type nVRStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *nVRStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _NVR_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NVRServer).StreamLogs(m, &nVRStreamLogsServer{stream})
+}
+
+type NVR_StreamLogsServer interface {
+	Send(*LogEntry) error
+	grpc.ServerStream
+}
+
+type nVRStreamLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *nVRStreamLogsServer) Send(m *LogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _NVR_ExportLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NVRServer).ExportLogs(m, &nVRExportLogsServer{stream})
+}
+
+type NVR_ExportLogsServer interface {
+	Send(*LogEntry) error
+	grpc.ServerStream
+}
+
+type nVRExportLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *nVRExportLogsServer) Send(m *LogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// NVR_ServiceDesc is the grpc.ServiceDesc for NVR service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NVR_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nvr.v1.NVR",
+	HandlerType: (*NVRServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListMonitors",
+			Handler:    _NVR_ListMonitors_Handler,
+		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _NVR_ListGroups_Handler,
+		},
+		{
+			MethodName: "QueryRecordings",
+			Handler:    _NVR_QueryRecordings_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _NVR_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _NVR_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportLogs",
+			Handler:       _NVR_ExportLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "nvrpb/nvr.proto",
+}
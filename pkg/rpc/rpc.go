@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package rpc serves the gRPC API that mirrors the REST handlers. The
+// service is defined in nvrpb/nvr.proto, regenerate the code with:
+//
+//	go generate ./pkg/rpc
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative nvrpb/nvr.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nvr/pkg/feed"
+	"nvr/pkg/group"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/rpc/nvrpb"
+	"nvr/pkg/storage"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the NVR service.
+type Server struct {
+	nvrpb.UnimplementedNVRServer
+
+	monitors func() monitor.RawConfigs
+	groups   *group.Manager
+	crawler  *storage.Crawler
+	events   *feed.Buffer[monitor.LiveEvent]
+	logs     *feed.Buffer[log.Entry]
+	logStore *log.Store
+	logger   log.ILogger
+}
+
+// NewServer returns a new NVR service.
+func NewServer(
+	monitors func() monitor.RawConfigs,
+	groups *group.Manager,
+	crawler *storage.Crawler,
+	events *feed.Buffer[monitor.LiveEvent],
+	logs *feed.Buffer[log.Entry],
+	logStore *log.Store,
+	logger log.ILogger,
+) *Server {
+	return &Server{
+		monitors: monitors,
+		groups:   groups,
+		crawler:  crawler,
+		events:   events,
+		logs:     logs,
+		logStore: logStore,
+		logger:   logger,
+	}
+}
+
+// ListMonitors returns the censored monitor configs sorted by ID.
+func (s *Server) ListMonitors(
+	context.Context, *nvrpb.ListMonitorsRequest,
+) (*nvrpb.ListMonitorsResponse, error) {
+	res := &nvrpb.ListMonitorsResponse{}
+	for id, config := range s.monitors() {
+		res.Monitors = append(res.Monitors, &nvrpb.Monitor{
+			Id:     id,
+			Name:   config["name"],
+			Config: config,
+		})
+	}
+	sort.Slice(res.Monitors, func(i, j int) bool {
+		return res.Monitors[i].Id < res.Monitors[j].Id
+	})
+	return res, nil
+}
+
+// ListGroups returns the groups sorted by ID.
+func (s *Server) ListGroups(
+	context.Context, *nvrpb.ListGroupsRequest,
+) (*nvrpb.ListGroupsResponse, error) {
+	res := &nvrpb.ListGroupsResponse{}
+	for id, config := range s.groups.Configs() {
+		monitorIDs, err := s.groups.MonitorIDs(id)
+		if err != nil && !errors.Is(err, group.ErrGroupNotExist) {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		res.Groups = append(res.Groups, &nvrpb.Group{
+			Id:         id,
+			Name:       config["name"],
+			MonitorIds: monitorIDs,
+		})
+	}
+	sort.Slice(res.Groups, func(i, j int) bool {
+		return res.Groups[i].Id < res.Groups[j].Id
+	})
+	return res, nil
+}
+
+// Maximum number of recordings returned by QueryRecordings.
+const maxRecordingsLimit = 1000
+
+// QueryRecordings returns recordings and the time of the next page.
+func (s *Server) QueryRecordings(
+	_ context.Context, req *nvrpb.QueryRecordingsRequest,
+) (*nvrpb.QueryRecordingsResponse, error) {
+	if req.Limit < 1 || req.Limit > maxRecordingsLimit {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"limit must be between 1 and %v", maxRecordingsLimit)
+	}
+	time := req.Time
+	if time == "" {
+		time = "9999-12-31"
+		if req.Reverse {
+			time = "0000-01-01"
+		}
+	}
+	if len(time) < 10 {
+		return nil, status.Error(codes.InvalidArgument, "time value to short")
+	}
+
+	recordings, err := s.crawler.RecordingByQuery(&storage.CrawlerQuery{
+		Time:        time,
+		Limit:       int(req.Limit),
+		Reverse:     req.Reverse,
+		Monitors:    req.MonitorIds,
+		IncludeData: req.IncludeData,
+	})
+	if err != nil {
+		s.logger.Log(log.Entry{
+			Level: log.LevelError,
+			Src:   "app",
+			Msg:   fmt.Sprintf("grpc: could not process recording query: %v", err),
+		})
+		return nil, status.Error(codes.Internal, "could not process recording query")
+	}
+
+	res := &nvrpb.QueryRecordingsResponse{}
+	for _, rec := range recordings {
+		res.Recordings = append(res.Recordings, newRecording(rec))
+	}
+	if len(recordings) == int(req.Limit) {
+		res.Next = recordings[len(recordings)-1].ID
+	}
+	return res, nil
+}
+
+// StreamEvents sends the live events until the client disconnects.
+func (s *Server) StreamEvents(
+	req *nvrpb.StreamEventsRequest, stream nvrpb.NVR_StreamEventsServer,
+) error {
+	return streamFeed(stream.Context(), s.events, req.Cursor,
+		func(item feed.Item[monitor.LiveEvent]) error {
+			if !log.StringInStrings(item.Value.MonitorID, req.MonitorIds) {
+				return nil
+			}
+			return stream.Send(newLiveEvent(item))
+		},
+	)
+}
+
+// StreamLogs sends the live logs until the client disconnects.
+func (s *Server) StreamLogs(
+	req *nvrpb.StreamLogsRequest, stream nvrpb.NVR_StreamLogsServer,
+) error {
+	levels := make([]log.Level, 0, len(req.Levels))
+	for _, level := range req.Levels {
+		levels = append(levels, log.Level(level))
+	}
+	return streamFeed(stream.Context(), s.logs, req.Cursor,
+		func(item feed.Item[log.Entry]) error {
+			entry := item.Value
+			if !log.LevelInLevels(entry.Level, levels) ||
+				!log.StringInStrings(entry.Src, req.Sources) ||
+				!log.StringInStrings(entry.MonitorID, req.MonitorIds) {
+				return nil
+			}
+			return stream.Send(&nvrpb.LogEntry{
+				Level:     uint32(entry.Level),
+				Source:    entry.Src,
+				MonitorId: entry.MonitorID,
+				Message:   entry.Msg,
+				Time:      uint64(entry.Time),
+				Cursor:    item.Cursor,
+			})
+		},
+	)
+}
+
+// ExportLogs sends all the stored logs that match, newest first.
+// Send blocks while the client is behind, which pauses the read.
+func (s *Server) ExportLogs(
+	req *nvrpb.ExportLogsRequest, stream nvrpb.NVR_ExportLogsServer,
+) error {
+	q := log.Query{
+		Levels:   make([]log.Level, 0, len(req.Levels)),
+		Sources:  req.Sources,
+		Monitors: req.MonitorIds,
+		Time:     log.UnixMicro(req.Time),
+	}
+	for _, level := range req.Levels {
+		q.Levels = append(q.Levels, log.Level(level))
+	}
+	return s.logStore.Export(stream.Context(), q, func(entry log.Entry) error {
+		return stream.Send(&nvrpb.LogEntry{
+			Level:     uint32(entry.Level),
+			Source:    entry.Src,
+			MonitorId: entry.MonitorID,
+			Message:   entry.Msg,
+			Time:      uint64(entry.Time),
+		})
+	})
+}
+
+// streamFeed calls send with the items after cursor until ctx is
+// canceled. A zero cursor starts with the items after the latest.
+func streamFeed[T any](
+	ctx context.Context,
+	history *feed.Buffer[T],
+	cursor uint64,
+	send func(feed.Item[T]) error,
+) error {
+	if cursor == 0 {
+		cursor = history.Cursor()
+	}
+	for {
+		items := history.Wait(ctx, cursor)
+		if items == nil {
+			return nil
+		}
+		for _, item := range items {
+			if err := send(item); err != nil {
+				return err
+			}
+			cursor = item.Cursor
+		}
+	}
+}
+
+func newRecording(rec storage.Recording) *nvrpb.Recording {
+	r := &nvrpb.Recording{
+		Id:        rec.ID,
+		Protected: rec.Protected,
+	}
+	if rec.Data == nil {
+		return r
+	}
+	r.Data = &nvrpb.RecordingData{
+		Start:             timestamppb.New(rec.Data.Start),
+		End:               timestamppb.New(rec.Data.End),
+		Maintenance:       rec.Data.Maintenance,
+		MaintenanceReason: rec.Data.MaintenanceReason,
+	}
+	for _, event := range rec.Data.Events {
+		r.Data.Events = append(r.Data.Events, &nvrpb.Event{
+			Time:       timestamppb.New(event.Time),
+			Detections: newDetections(event.Detections),
+			Duration:   durationpb.New(event.Duration),
+		})
+	}
+	return r
+}
+
+func newLiveEvent(item feed.Item[monitor.LiveEvent]) *nvrpb.Event {
+	event := item.Value
+	return &nvrpb.Event{
+		MonitorId:  event.MonitorID,
+		Time:       timestamppb.New(event.Time),
+		Detections: newDetections(event.Detections),
+		Duration:   durationpb.New(event.Duration),
+		Rule:       event.Rule,
+		Message:    event.Message,
+		Cursor:     item.Cursor,
+	}
+}
+
+func newDetections(detections []storage.Detection) []*nvrpb.Detection {
+	var res []*nvrpb.Detection
+	for _, d := range detections {
+		detection := &nvrpb.Detection{
+			Label: d.Label,
+			Score: d.Score,
+		}
+		if d.Region != nil && d.Region.Rect != nil {
+			for _, v := range d.Region.Rect {
+				detection.Rect = append(detection.Rect, int32(v))
+			}
+		}
+		if d.Region != nil && d.Region.Polygon != nil {
+			for _, p := range *d.Region.Polygon {
+				detection.Polygon = append(detection.Polygon,
+					&nvrpb.Point{X: int32(p[0]), Y: int32(p[1])})
+			}
+		}
+		res = append(res, detection)
+	}
+	return res
+}
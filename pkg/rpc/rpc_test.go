@@ -0,0 +1,345 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"nvr/pkg/feed"
+	"nvr/pkg/group"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/rpc/nvrpb"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// stubAuth accepts "user", "admin", "mustChange" and,
+// until it's revoked, "revocable" as the authorization header.
+type stubAuth struct {
+	auth.Authenticator
+	revoked *atomic.Bool
+}
+
+func (a stubAuth) ValidateRequest(r *http.Request) auth.ValidateResponse {
+	switch r.Header.Get("Authorization") {
+	case "user":
+		return auth.ValidateResponse{IsValid: true}
+	case "admin":
+		return auth.ValidateResponse{IsValid: true, User: auth.Account{IsAdmin: true}}
+	case "mustChange":
+		return auth.ValidateResponse{IsValid: true, User: auth.Account{MustChangePassword: true}}
+	case "revocable":
+		return auth.ValidateResponse{IsValid: !a.revoked.Load()}
+	}
+	return auth.ValidateResponse{}
+}
+
+const testToken = "0123456789abcdef"
+
+type testServer struct {
+	client   nvrpb.NVRClient
+	events   *feed.Buffer[monitor.LiveEvent]
+	logs     *feed.Buffer[log.Entry]
+	logStore *log.Store
+	revoked  *atomic.Bool
+}
+
+func newTestServer(t *testing.T) testServer {
+	t.Helper()
+
+	groups, err := group.NewManager(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, groups.GroupSet("g1", group.Config{
+		"id": "g1", "name": "a", "monitors": `["m1","m2"]`,
+	}))
+
+	rawData, err := json.Marshal(storage.RecordingData{
+		Start: time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC),
+		Events: []storage.Event{{
+			Time: time.Date(2000, 1, 1, 1, 0, 1, 0, time.UTC),
+			Detections: []storage.Detection{{
+				Label: "person",
+				Score: 50,
+			}},
+		}},
+	})
+	require.NoError(t, err)
+	crawler := storage.NewCrawler(fstest.MapFS{
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.json": {Data: rawData},
+		"2000/01/01/m2/2000-01-01_02-00-00_m2.json": {Data: []byte("{}")},
+	})
+
+	monitors := func() monitor.RawConfigs {
+		return monitor.RawConfigs{
+			"m2": {"id": "m2", "name": "b"},
+			"m1": {"id": "m1", "name": "a"},
+		}
+	}
+	events := feed.NewBuffer[monitor.LiveEvent](10)
+	logs := feed.NewBuffer[log.Entry](10)
+
+	logStore, err := log.NewStore(t.TempDir(), &sync.WaitGroup{}, nil)
+	require.NoError(t, err)
+
+	s := NewServer(monitors, groups, crawler, events, logs, logStore, log.NewDummyLogger())
+	revoked := &atomic.Bool{}
+	server := NewGRPCServer(s, stubAuth{revoked: revoked}, []string{testToken}, nil)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return testServer{
+		client:   nvrpb.NewNVRClient(conn),
+		events:   events,
+		logs:     logs,
+		logStore: logStore,
+		revoked:  revoked,
+	}
+}
+
+func withAuth(ctx context.Context, authorization string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+}
+
+func TestAuth(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		authorization string
+		code          codes.Code
+	}{
+		"token":      {"Bearer " + testToken, codes.OK},
+		"user":       {"user", codes.OK},
+		"none":       {"", codes.Unauthenticated},
+		"wrongToken": {"Bearer x", codes.Unauthenticated},
+		"wrongUser":  {"x", codes.Unauthenticated},
+		"mustChange": {"mustChange", codes.PermissionDenied},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.client.ListMonitors(
+				withAuth(ctx, tc.authorization), &nvrpb.ListMonitorsRequest{})
+			require.Equal(t, tc.code, status.Code(err))
+		})
+	}
+
+	t.Run("adminOnly", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := s.client.StreamLogs(withAuth(ctx, "user"), &nvrpb.StreamLogsRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+	t.Run("revalidateStream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		s.events.Push(monitor.LiveEvent{MonitorID: "m1"})
+		s.events.Push(monitor.LiveEvent{MonitorID: "m1"})
+		stream, err := s.client.StreamEvents(
+			withAuth(ctx, "revocable"),
+			&nvrpb.StreamEventsRequest{MonitorIds: []string{"m1"}, Cursor: 1},
+		)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+
+		s.revoked.Store(true)
+		s.events.Push(monitor.LiveEvent{MonitorID: "m1"})
+		_, err = stream.Recv()
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestListMonitors(t *testing.T) {
+	s := newTestServer(t)
+	ctx := withAuth(context.Background(), "user")
+
+	res, err := s.client.ListMonitors(ctx, &nvrpb.ListMonitorsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.Monitors, 2)
+	require.Equal(t, "m1", res.Monitors[0].Id)
+	require.Equal(t, "a", res.Monitors[0].Name)
+	require.Equal(t, map[string]string{"id": "m1", "name": "a"}, res.Monitors[0].Config)
+	require.Equal(t, "m2", res.Monitors[1].Id)
+}
+
+func TestListGroups(t *testing.T) {
+	s := newTestServer(t)
+	ctx := withAuth(context.Background(), "user")
+
+	res, err := s.client.ListGroups(ctx, &nvrpb.ListGroupsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.Groups, 1)
+	require.Equal(t, "g1", res.Groups[0].Id)
+	require.Equal(t, "a", res.Groups[0].Name)
+	require.Equal(t, []string{"m1", "m2"}, res.Groups[0].MonitorIds)
+}
+
+func TestQueryRecordings(t *testing.T) {
+	s := newTestServer(t)
+	ctx := withAuth(context.Background(), "user")
+
+	res, err := s.client.QueryRecordings(ctx, &nvrpb.QueryRecordingsRequest{
+		Limit:       1,
+		MonitorIds:  []string{"m1"},
+		IncludeData: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Recordings, 1)
+	rec := res.Recordings[0]
+	require.Equal(t, "2000-01-01_01-00-00_m1", rec.Id)
+	require.Equal(t, "2000-01-01_01-00-00_m1", res.Next)
+	require.Equal(t, time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC), rec.Data.Start.AsTime())
+	require.Len(t, rec.Data.Events, 1)
+	require.Equal(t, "person", rec.Data.Events[0].Detections[0].Label)
+
+	res, err = s.client.QueryRecordings(ctx, &nvrpb.QueryRecordingsRequest{
+		Limit:   10,
+		Reverse: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Recordings, 2)
+	require.Equal(t, "2000-01-01_01-00-00_m1", res.Recordings[0].Id)
+	require.Nil(t, res.Recordings[0].Data)
+	require.Equal(t, "", res.Next)
+
+	_, err = s.client.QueryRecordings(ctx, &nvrpb.QueryRecordingsRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStreamEvents(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(withAuth(context.Background(), "user"))
+	defer cancel()
+
+	s.events.Push(monitor.LiveEvent{MonitorID: "m1"})
+	s.events.Push(monitor.LiveEvent{MonitorID: "m1"})
+	s.events.Push(monitor.LiveEvent{
+		MonitorID: "m2",
+		Rule:      "a",
+		Time:      time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	stream, err := s.client.StreamEvents(ctx, &nvrpb.StreamEventsRequest{
+		MonitorIds: []string{"m2"},
+		Cursor:     1,
+	})
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "m2", event.MonitorId)
+	require.Equal(t, "a", event.Rule)
+	require.Equal(t, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), event.Time.AsTime())
+	require.Equal(t, uint64(3), event.Cursor)
+}
+
+func TestStreamLogs(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(withAuth(context.Background(), "admin"))
+	defer cancel()
+
+	s.logs.Push(log.Entry{Level: log.LevelInfo, Src: "app", Msg: "a", Time: 1})
+	s.logs.Push(log.Entry{Level: log.LevelInfo, Src: "app", Msg: "b", Time: 2})
+	s.logs.Push(log.Entry{Level: log.LevelError, Src: "app", Msg: "c", Time: 3})
+
+	stream, err := s.client.StreamLogs(ctx, &nvrpb.StreamLogsRequest{
+		Levels:  []uint32{uint32(log.LevelError)},
+		Sources: []string{"app"},
+		Cursor:  1,
+	})
+	require.NoError(t, err)
+
+	entry, err := stream.Recv()
+	require.NoError(t, err)
+	expected := &nvrpb.LogEntry{
+		Level:   uint32(log.LevelError),
+		Source:  "app",
+		Message: "c",
+		Time:    3,
+		Cursor:  3,
+	}
+	require.Equal(t, expected.String(), entry.String())
+}
+
+func TestExportLogs(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(withAuth(context.Background(), "admin"))
+	defer cancel()
+
+	logger := log.NewLogger(&sync.WaitGroup{}, nil)
+	require.NoError(t, logger.Start(ctx))
+	s.logStore.SaveLogs(ctx, logger)
+
+	// Entries are dropped until the store has subscribed.
+	require.Eventually(t, func() bool {
+		logger.Log(log.Entry{Level: log.LevelDebug, Src: "test", Msg: "ready"})
+		entries, err := s.logStore.Query(log.Query{Sources: []string{"test"}, Limit: 1})
+		return err == nil && len(entries) != 0
+	}, time.Second, 10*time.Millisecond)
+
+	logger.Log(log.Entry{Level: log.LevelError, Src: "app", Msg: "a"})
+	logger.Log(log.Entry{Level: log.LevelInfo, Src: "app", Msg: "b"})
+	logger.Log(log.Entry{Level: log.LevelError, Src: "app", MonitorID: "m1", Msg: "c"})
+	require.Eventually(t, func() bool {
+		entries, err := s.logStore.Query(log.Query{Sources: []string{"app"}})
+		return err == nil && len(entries) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	stream, err := s.client.ExportLogs(ctx, &nvrpb.ExportLogsRequest{
+		Levels: []uint32{uint32(log.LevelError)},
+	})
+	require.NoError(t, err)
+
+	var messages []string
+	for {
+		entry, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		messages = append(messages, entry.Message)
+	}
+	require.Equal(t, []string{"c", "a"}, messages)
+
+	t.Run("adminOnly", func(t *testing.T) {
+		stream, err := s.client.ExportLogs(
+			withAuth(context.Background(), "user"), &nvrpb.ExportLogsRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// keyStore stores the master key.
+type keyStore interface {
+	// load returns os.ErrNotExist if there is no key.
+	load() ([]byte, error)
+	store(key []byte) error
+	name() string
+}
+
+// fileKeyStore stores the key base64 encoded in a file.
+type fileKeyStore struct {
+	path string
+}
+
+func (s *fileKeyStore) load() ([]byte, error) {
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+}
+
+func (s *fileKeyStore) exists() (bool, error) {
+	_, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *fileKeyStore) store(key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	raw := base64.StdEncoding.EncodeToString(key) + "\n"
+
+	// Fail instead of overwriting an existing key.
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(raw); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *fileKeyStore) name() string {
+	return "file " + s.path
+}
+
+// ErrKeyringUnavailable keyring unavailable.
+var ErrKeyringUnavailable = errors.New("keyring unavailable")
+
+// Keyring service and account of the master key.
+const (
+	keyringService = "os-nvr"
+	keyringAccount = "master-key"
+)
+
+// commandKeyring stores the key in the OS keyring through its command line
+// tool, secret-tool on Linux and security on macOS. This avoids a D-Bus
+// dependency for the Secret Service API.
+type commandKeyring struct {
+	// lookupCmd prints the key to stdout.
+	lookupCmd func() *exec.Cmd
+
+	// notFound returns true if the lookup
+	// failed because the key doesn't exist.
+	notFound func(exitCode int, stderr string) bool
+
+	// storeCmd reads the key from stdin.
+	storeCmd func(key string) *exec.Cmd
+}
+
+func newKeyring() (*commandKeyring, error) {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		bin, err := exec.LookPath("secret-tool")
+		if err != nil {
+			return nil, fmt.Errorf("%w: secret-tool: %w", ErrKeyringUnavailable, err)
+		}
+		return &commandKeyring{
+			lookupCmd: func() *exec.Cmd {
+				return exec.Command(bin, "lookup",
+					"service", keyringService, "account", keyringAccount)
+			},
+			notFound: func(exitCode int, stderr string) bool {
+				// Other errors are also status 1 but print to stderr.
+				return exitCode == 1 && stderr == ""
+			},
+			storeCmd: func(key string) *exec.Cmd {
+				cmd := exec.Command(bin, "store", "--label=OS-NVR master key",
+					"service", keyringService, "account", keyringAccount)
+				cmd.Stdin = strings.NewReader(key)
+				return cmd
+			},
+		}, nil
+	case "darwin":
+		bin, err := exec.LookPath("security")
+		if err != nil {
+			return nil, fmt.Errorf("%w: security: %w", ErrKeyringUnavailable, err)
+		}
+		return &commandKeyring{
+			lookupCmd: func() *exec.Cmd {
+				return exec.Command(bin, "find-generic-password",
+					"-s", keyringService, "-a", keyringAccount, "-w")
+			},
+			notFound: func(exitCode int, _ string) bool {
+				return exitCode == 44 // errSecItemNotFound.
+			},
+			storeCmd: func(key string) *exec.Cmd {
+				// The password argument is read from a interactive
+				// command on stdin to keep the key out of argv.
+				cmd := exec.Command(bin, "-i")
+				cmd.Stdin = strings.NewReader(fmt.Sprintf(
+					"add-generic-password -s %v -a %v -w %v\n",
+					keyringService, keyringAccount, key))
+				return cmd
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrKeyringUnavailable, runtime.GOOS)
+}
+
+func (k *commandKeyring) load() ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := k.lookupCmd()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && k.notFound(exitErr.ExitCode(), stderr.String()) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+}
+
+func (k *commandKeyring) store(key []byte) error {
+	var stderr bytes.Buffer
+	cmd := k.storeCmd(base64.StdEncoding.EncodeToString(key))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (k *commandKeyring) name() string {
+	return "keyring"
+}
@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package secret encrypts credentials at rest. Values are encrypted with
+// AES-GCM using a key derived from a master key for each scope, the
+// scope is usually the monitor ID. The master key is kept in the OS
+// keyring, or in a key file outside the config directory.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"nvr/pkg/storage"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Prefix of encrypted values.
+const prefix = "enc:v1:"
+
+const keySize = 32
+
+// IsEncrypted returns true if the value is encrypted.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Cipher encrypts and decrypts values. A nil
+// cipher stores the values in plain text.
+type Cipher struct {
+	masterKey []byte
+	store     string
+}
+
+// Errors.
+var (
+	ErrNoKey       = errors.New("value is encrypted but there is no key")
+	ErrInvalidKey  = errors.New("invalid key")
+	ErrInvalidData = errors.New("invalid encrypted value")
+)
+
+// NewCipher returns a cipher with the master key from the secret
+// store, the key is generated if it doesn't exist. Returns
+// nil if the store is none.
+func NewCipher(store string, keyPath string) (*Cipher, error) {
+	var ks keyStore
+	switch store {
+	case storage.SecretStoreNone:
+		return nil, nil //nolint:nilnil
+	case storage.SecretStoreKeyring:
+		keyring, err := newKeyring()
+		if err != nil {
+			return nil, err
+		}
+		ks = keyring
+	case storage.SecretStoreFile:
+		ks = &fileKeyStore{path: keyPath}
+	default:
+		// The keyring is nil if it isn't installed.
+		var keyring keyStore
+		if k, err := newKeyring(); err == nil {
+			keyring = k
+		}
+		auto, err := autoKeyStore(&fileKeyStore{path: keyPath}, keyring)
+		if err != nil {
+			return nil, err
+		}
+		ks = auto
+	}
+
+	key, err := loadOrGenerateKey(ks)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", ks.name(), err)
+	}
+	return &Cipher{masterKey: key, store: ks.name()}, nil
+}
+
+// ErrKeyringFailed the keyring is installed but not usable.
+var ErrKeyringFailed = errors.New("keyring failed")
+
+// autoKeyStore prefers the keyring but keeps using the key file if it
+// already exists. A new key is only generated if neither store has a
+// key, the key may be in a keyring that failed to load so that's an
+// error instead of a reason to fall back to the file.
+func autoKeyStore(file *fileKeyStore, keyring keyStore) (keyStore, error) {
+	fileExists, err := file.exists()
+	if err != nil {
+		return nil, err
+	}
+	if keyring == nil {
+		return file, nil
+	}
+
+	_, err = keyring.load()
+	switch {
+	case err == nil:
+		return keyring, nil
+	case fileExists:
+		return file, nil
+	case errors.Is(err, os.ErrNotExist):
+		return keyring, nil
+	default:
+		// No session bus for example.
+		return nil, fmt.Errorf("%w: %w", ErrKeyringFailed, err)
+	}
+}
+
+func loadOrGenerateKey(ks keyStore) ([]byte, error) {
+	key, err := ks.load()
+	if err == nil {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("%w: size %v", ErrInvalidKey, len(key))
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load key: %w", err)
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := ks.store(key); err != nil {
+		return nil, fmt.Errorf("store key: %w", err)
+	}
+	return key, nil
+}
+
+// Store returns the name of the secret store.
+func (c *Cipher) Store() string {
+	if c == nil {
+		return storage.SecretStoreNone
+	}
+	return c.store
+}
+
+// aead returns the cipher for the scope.
+func (c *Cipher) aead(scope string) (cipher.AEAD, error) {
+	key := make([]byte, keySize)
+	kdf := hkdf.New(sha256.New, c.masterKey, nil, []byte("nvr:"+scope))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts the value. The scope and name must be the same when
+// decrypting, this prevents values from being moved between monitors.
+// Empty and already encrypted values are returned unchanged.
+func (c *Cipher) Encrypt(scope string, name string, value string) (string, error) {
+	if c == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	aead, err := c.aead(scope)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the value. Values that
+// aren't encrypted are returned unchanged.
+func (c *Cipher) Decrypt(scope string, name string, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrNoKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+	aead, err := c.aead(scope)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidData
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("%w: wrong key or scope", ErrInvalidData)
+	}
+	return string(plaintext), nil
+}
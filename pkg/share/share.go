@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package share issues time-limited links to the live stream of a
+// monitor or to a recording. The links can be opened without login,
+// for example to send a clip to the police or to cast to a TV.
+//
+// A token is the link ID and a HMAC signature of the link, the
+// links are also stored so that admins can list and revoke them.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"nvr/pkg/kv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Link kinds.
+const (
+	KindLive      = "live"
+	KindRecording = "recording"
+)
+
+// Link is a issued share link.
+type Link struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	MonitorID   string    `json:"monitorId,omitempty"`
+	RecordingID string    `json:"recordingId,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedBy   string    `json:"createdBy"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+
+	// Set if a admin revoked the link.
+	RevokedBy string    `json:"revokedBy,omitempty"`
+	Revoked   time.Time `json:"revoked,omitempty"`
+
+	// Number of times the link was opened.
+	Uses     int       `json:"uses"`
+	LastUsed time.Time `json:"lastUsed,omitempty"`
+}
+
+// CreateRequest is a request to create a share link.
+type CreateRequest struct {
+	Kind        string `json:"kind"`
+	MonitorID   string `json:"monitorId,omitempty"`
+	RecordingID string `json:"recordingId,omitempty"`
+	Note        string `json:"note,omitempty"`
+
+	// Seconds until the link expires, defaults to a day.
+	Duration int `json:"duration,omitempty"`
+}
+
+// Link limits.
+const (
+	defaultDuration = 24 * time.Hour
+	maxDuration     = 30 * 24 * time.Hour
+	maxNoteLength   = 256
+
+	// Links are kept for the audit this long after they expired.
+	auditRetention = 90 * 24 * time.Hour
+)
+
+// Database buckets.
+const (
+	keyBucket  = "share"
+	linkBucket = "share-links"
+)
+
+// Errors.
+var (
+	ErrInvalidRequest = errors.New("invalid share request")
+	ErrInvalidToken   = errors.New("invalid share token")
+	ErrLinkNotExist   = errors.New("share link does not exist")
+	ErrExpired        = errors.New("share link expired")
+	ErrRevoked        = errors.New("share link revoked")
+)
+
+// Manager creates and validates share links.
+type Manager struct {
+	db  *kv.DB
+	key []byte
+	mu  sync.Mutex
+
+	now func() time.Time
+}
+
+// NewManager loads or creates the signing key.
+func NewManager(db *kv.DB) (*Manager, error) {
+	var key []byte
+	err := db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(keyBucket)
+		if k := bucket.Get("key"); k != nil {
+			key = append([]byte(nil), k...)
+			return nil
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		return bucket.Put("key", key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load share key: %w", err)
+	}
+	return &Manager{db: db, key: key, now: time.Now}, nil
+}
+
+func (m *Manager) sign(l Link) string {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(strings.Join([]string{
+		l.ID,
+		l.Kind,
+		l.MonitorID,
+		l.RecordingID,
+		strconv.FormatInt(l.Expires.Unix(), 10),
+	}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Token returns the token of the link, "<id>.<signature>".
+func (m *Manager) Token(l Link) string {
+	return l.ID + "." + m.sign(l)
+}
+
+// Create creates a link and returns it with the token.
+func (m *Manager) Create(req CreateRequest, username string) (*Link, string, error) {
+	switch {
+	case req.Kind == KindLive && req.MonitorID == "":
+		return nil, "", fmt.Errorf("%w: monitor id missing", ErrInvalidRequest)
+	case req.Kind == KindLive:
+		req.RecordingID = ""
+	case req.Kind == KindRecording && req.RecordingID == "":
+		return nil, "", fmt.Errorf("%w: recording id missing", ErrInvalidRequest)
+	case req.Kind == KindRecording:
+		req.MonitorID = ""
+	default:
+		return nil, "", fmt.Errorf("%w: kind %q", ErrInvalidRequest, req.Kind)
+	}
+
+	duration := time.Duration(req.Duration) * time.Second
+	switch {
+	case req.Duration < 0 || duration > maxDuration:
+		return nil, "", fmt.Errorf("%w: duration must be between 0 and %v",
+			ErrInvalidRequest, maxDuration)
+	case req.Duration == 0:
+		duration = defaultDuration
+	}
+	if len(req.Note) > maxNoteLength {
+		return nil, "", fmt.Errorf("%w: note is longer than %v", ErrInvalidRequest, maxNoteLength)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	now := m.now()
+	link := Link{
+		ID:          hex.EncodeToString(id),
+		Kind:        req.Kind,
+		MonitorID:   req.MonitorID,
+		RecordingID: req.RecordingID,
+		Note:        req.Note,
+		CreatedBy:   username,
+		Created:     now,
+		Expires:     now.Add(duration).Truncate(time.Second),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(linkBucket)
+		if err := pruneLinks(bucket, now.Add(-auditRetention)); err != nil {
+			return err
+		}
+		return bucket.PutJSON(link.ID, link)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("save share link: %w", err)
+	}
+	return &link, m.Token(link), nil
+}
+
+// pruneLinks deletes the links that expired before t.
+func pruneLinks(bucket *kv.Bucket, t time.Time) error {
+	var expired []string
+	err := bucket.ForEach(func(id string, _ []byte) error {
+		var l Link
+		if _, err := bucket.GetJSON(id, &l); err != nil {
+			return err
+		}
+		if l.Expires.Before(t) {
+			expired = append(expired, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range expired {
+		if err := bucket.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) get(id string) (Link, error) {
+	var l Link
+	var exist bool
+	err := m.db.View(func(tx *kv.Tx) error {
+		var err error
+		exist, err = tx.Bucket(linkBucket).GetJSON(id, &l)
+		return err
+	})
+	if err != nil {
+		return Link{}, err
+	}
+	if !exist {
+		return Link{}, fmt.Errorf("%w: %v", ErrLinkNotExist, id)
+	}
+	return l, nil
+}
+
+// Validate returns the link of the token. Returns a error if the
+// signature doesn't match or the link is expired or revoked.
+func (m *Manager) Validate(token string) (*Link, error) {
+	id, signature, found := strings.Cut(token, ".")
+	if !found || id == "" {
+		return nil, ErrInvalidToken
+	}
+
+	l, err := m.get(id)
+	if errors.Is(err, ErrLinkNotExist) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(signature), []byte(m.sign(l))) {
+		return nil, ErrInvalidToken
+	}
+
+	switch {
+	case !l.Revoked.IsZero():
+		return nil, ErrRevoked
+	case !m.now().Before(l.Expires):
+		return nil, ErrExpired
+	}
+	return &l, nil
+}
+
+// RecordUse increments the use count of the link for the audit.
+func (m *Manager) RecordUse(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update(id, func(l *Link) {
+		l.Uses++
+		l.LastUsed = m.now()
+	})
+}
+
+// Revoke revokes the link, it stays in the list for the audit.
+func (m *Manager) Revoke(id string, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update(id, func(l *Link) {
+		if l.Revoked.IsZero() {
+			l.Revoked = m.now()
+			l.RevokedBy = username
+		}
+	})
+}
+
+func (m *Manager) update(id string, fn func(*Link)) error {
+	return m.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(linkBucket)
+		var l Link
+		exist, err := bucket.GetJSON(id, &l)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return fmt.Errorf("%w: %v", ErrLinkNotExist, id)
+		}
+		fn(&l)
+		return bucket.PutJSON(id, l)
+	})
+}
+
+// List returns the issued links, newest first. Expired
+// and revoked links are included for the audit.
+func (m *Manager) List() ([]Link, error) {
+	links := []Link{}
+	err := m.db.View(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(linkBucket)
+		return bucket.ForEach(func(id string, _ []byte) error {
+			var l Link
+			if _, err := bucket.GetJSON(id, &l); err != nil {
+				return err
+			}
+			links = append(links, l)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list share links: %w", err)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Created.After(links[j].Created)
+	})
+	return links, nil
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package share
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/kv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nvr.db")
+	db, err := kv.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	m, err := NewManager(db)
+	require.NoError(t, err)
+	m.now = func() time.Time { return time.Unix(1000, 0) }
+	return m, path
+}
+
+func TestCreate(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	cases := map[string]CreateRequest{
+		"kind":        {Kind: "x", MonitorID: "m1"},
+		"monitor":     {Kind: KindLive},
+		"recording":   {Kind: KindRecording},
+		"duration":    {Kind: KindLive, MonitorID: "m1", Duration: 31 * 24 * 60 * 60},
+		"negDuration": {Kind: KindLive, MonitorID: "m1", Duration: -1},
+	}
+	for name, req := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := m.Create(req, "admin")
+			require.ErrorIs(t, err, ErrInvalidRequest)
+		})
+	}
+
+	link, token, err := m.Create(CreateRequest{
+		Kind:        KindRecording,
+		MonitorID:   "m1",
+		RecordingID: "2000-01-01_00-00-00_m1",
+		Note:        "case 123",
+	}, "admin")
+	require.NoError(t, err)
+	require.Equal(t, "", link.MonitorID)
+	require.Equal(t, "admin", link.CreatedBy)
+	require.Equal(t, time.Unix(1000, 0).Add(defaultDuration), link.Expires)
+	require.Equal(t, link.ID+"."+m.sign(*link), token)
+}
+
+func TestValidate(t *testing.T) {
+	m, path := newTestManager(t)
+	link, token, err := m.Create(CreateRequest{
+		Kind: KindLive, MonitorID: "m1", Duration: 60,
+	}, "admin")
+	require.NoError(t, err)
+
+	got, err := m.Validate(token)
+	require.NoError(t, err)
+	require.Equal(t, "m1", got.MonitorID)
+
+	// The key is persisted.
+	require.NoError(t, m.db.Close())
+	db, err := kv.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	m2, err := NewManager(db)
+	require.NoError(t, err)
+	m2.now = m.now
+	_, err = m2.Validate(token)
+	require.NoError(t, err)
+	m = m2
+
+	invalid := []string{"", "x", link.ID, link.ID + ".x", "x." + m.sign(*link)}
+	for _, token := range invalid {
+		_, err := m.Validate(token)
+		require.ErrorIs(t, err, ErrInvalidToken, token)
+	}
+
+	m.now = func() time.Time { return time.Unix(1060, 0) }
+	_, err = m.Validate(token)
+	require.ErrorIs(t, err, ErrExpired)
+
+	m.now = func() time.Time { return time.Unix(1030, 0) }
+	require.NoError(t, m.Revoke(link.ID, "admin2"))
+	_, err = m.Validate(token)
+	require.ErrorIs(t, err, ErrRevoked)
+
+	require.ErrorIs(t, m.Revoke("x", "admin"), ErrLinkNotExist)
+}
+
+func TestAudit(t *testing.T) {
+	m, _ := newTestManager(t)
+	link1, _, err := m.Create(CreateRequest{Kind: KindLive, MonitorID: "m1"}, "a")
+	require.NoError(t, err)
+
+	m.now = func() time.Time { return time.Unix(2000, 0) }
+	link2, _, err := m.Create(CreateRequest{Kind: KindLive, MonitorID: "m2"}, "b")
+	require.NoError(t, err)
+
+	require.NoError(t, m.RecordUse(link1.ID))
+	require.NoError(t, m.RecordUse(link1.ID))
+	require.NoError(t, m.Revoke(link1.ID, "b"))
+
+	links, err := m.List()
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	require.Equal(t, link2.ID, links[0].ID)
+	require.Equal(t, link1.ID, links[1].ID)
+	require.Equal(t, 2, links[1].Uses)
+	require.True(t, links[1].LastUsed.Equal(time.Unix(2000, 0)))
+	require.Equal(t, "b", links[1].RevokedBy)
+
+	// Links are pruned after the audit retention.
+	m.now = func() time.Time { return time.Unix(2000, 0).Add(auditRetention + defaultDuration) }
+	_, _, err = m.Create(CreateRequest{Kind: KindLive, MonitorID: "m3"}, "c")
+	require.NoError(t, err)
+	links, err = m.List()
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	require.Equal(t, "m2", links[1].MonitorID)
+}
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package speedtest measures the latency and throughput between the
+// server and a client, and recommends the live stream for the client.
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Result of a speed test.
+type Result struct {
+	// Round trip time.
+	Latency time.Duration `json:"latency"`
+
+	// Server to client throughput in bits per second.
+	Throughput int64 `json:"throughput"`
+
+	Time time.Time `json:"time"`
+}
+
+// Conn is implemented by *websocket.Conn.
+type Conn interface {
+	WriteMessage(messageType int, data []byte) error
+	ReadMessage() (messageType int, p []byte, err error)
+}
+
+// Websocket message types.
+const (
+	textMessage   = 1
+	binaryMessage = 2
+)
+
+// Message is sent to the client as a text message. The
+// client must echo "ping" and "sync" messages back.
+type Message struct {
+	Type   string  `json:"type"`
+	ID     int     `json:"id,omitempty"`
+	Result *Result `json:"result,omitempty"`
+}
+
+// Message types.
+const (
+	MessagePing   = "ping"
+	MessageSync   = "sync"
+	MessageResult = "result"
+)
+
+// Limits.
+const (
+	pingCount = 5
+
+	// The payload is doubled each round until a round
+	// takes longer than the target duration.
+	firstPayloadSize    = 64 * 1024
+	maxPayloadSize      = 16 * 1024 * 1024
+	payloadChunkSize    = 64 * 1024
+	targetRoundDuration = time.Second
+)
+
+// ErrUnexpectedMessage the client didn't echo the message.
+var ErrUnexpectedMessage = errors.New("unexpected message")
+
+// Run measures the latency and throughput over the connection and
+// sends the result to the client. Latency is the median round trip
+// time of the pings. Throughput is measured by sending binary payloads
+// followed by a sync message, the round trip time is subtracted from
+// the time until the sync message is echoed back.
+func Run(conn Conn) (Result, error) {
+	rtts := make([]time.Duration, pingCount)
+	for i := range rtts {
+		rtt, err := roundTrip(conn, Message{Type: MessagePing, ID: i + 1})
+		if err != nil {
+			return Result{}, fmt.Errorf("ping: %w", err)
+		}
+		rtts[i] = rtt
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	latency := rtts[len(rtts)/2]
+
+	var throughput int64
+	chunk := make([]byte, payloadChunkSize)
+	for size := firstPayloadSize; size <= maxPayloadSize; size *= 2 {
+		start := time.Now()
+		for sent := 0; sent < size; sent += len(chunk) {
+			if err := conn.WriteMessage(binaryMessage, chunk); err != nil {
+				return Result{}, fmt.Errorf("write payload: %w", err)
+			}
+		}
+		if _, err := roundTrip(conn, Message{Type: MessageSync}); err != nil {
+			return Result{}, fmt.Errorf("sync: %w", err)
+		}
+
+		elapsed := time.Since(start)
+		transfer := elapsed - latency
+		if transfer <= 0 {
+			transfer = elapsed
+		}
+		throughput = int64(float64(size*8) / transfer.Seconds())
+		if elapsed >= targetRoundDuration {
+			break
+		}
+	}
+
+	result := Result{
+		Latency:    latency,
+		Throughput: throughput,
+		Time:       time.Now(),
+	}
+	rawResult, err := json.Marshal(Message{Type: MessageResult, Result: &result})
+	if err != nil {
+		return Result{}, err
+	}
+	if err := conn.WriteMessage(textMessage, rawResult); err != nil {
+		return Result{}, fmt.Errorf("write result: %w", err)
+	}
+	return result, nil
+}
+
+// roundTrip sends the message and waits for the client to echo it.
+func roundTrip(conn Conn, msg Message) (time.Duration, error) {
+	rawMsg, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if err := conn.WriteMessage(textMessage, rawMsg); err != nil {
+		return 0, err
+	}
+	typ, echo, err := conn.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	if typ != textMessage || string(echo) != string(rawMsg) {
+		return 0, fmt.Errorf("%w: %q", ErrUnexpectedMessage, echo)
+	}
+	return time.Since(start), nil
+}
+
+// Recommendation of the live stream.
+type Recommendation struct {
+	Sub    bool   `json:"sub"`
+	Reason string `json:"reason"`
+}
+
+// Assumed bitrate of the main stream if it's unknown.
+const defaultMainBitrate = 4_000_000
+
+// Fraction of the throughput that can be used by the stream.
+const throughputHeadroom = 0.75
+
+// Recommend the main stream if the throughput can sustain its bitrate,
+// otherwise the sub stream if it's available. The bitrates are in bits
+// per second, zero if unknown.
+func Recommend(r Result, mainBitrate int64, subBitrate int64, subAvailable bool) Recommendation {
+	if mainBitrate == 0 {
+		mainBitrate = defaultMainBitrate
+	}
+	available := int64(float64(r.Throughput) * throughputHeadroom)
+
+	if available >= mainBitrate {
+		return Recommendation{Sub: false, Reason: "throughput is sufficient for the main stream"}
+	}
+	if !subAvailable {
+		return Recommendation{Sub: false, Reason: "sub stream is not available"}
+	}
+	if subBitrate != 0 && available < subBitrate {
+		return Recommendation{Sub: true, Reason: "throughput is insufficient for both streams"}
+	}
+	return Recommendation{Sub: true, Reason: "throughput is insufficient for the main stream"}
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package speedtest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// echoConn echoes text messages like a client.
+type echoConn struct {
+	pending  [][]byte
+	received int
+	last     []byte
+}
+
+func (c *echoConn) WriteMessage(messageType int, data []byte) error {
+	if messageType == binaryMessage {
+		c.received += len(data)
+		return nil
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg.Type == MessageResult {
+		c.last = data
+		return nil
+	}
+	c.pending = append(c.pending, data)
+	return nil
+}
+
+func (c *echoConn) ReadMessage() (int, []byte, error) {
+	msg := c.pending[0]
+	c.pending = c.pending[1:]
+	return textMessage, msg, nil
+}
+
+func TestRun(t *testing.T) {
+	conn := &echoConn{}
+	result, err := Run(conn)
+	require.NoError(t, err)
+	require.Greater(t, result.Throughput, int64(0))
+
+	// Every round doubles the payload until the max size.
+	require.Equal(t, 2*maxPayloadSize-firstPayloadSize, conn.received)
+
+	var msg Message
+	require.NoError(t, json.Unmarshal(conn.last, &msg))
+	require.Equal(t, MessageResult, msg.Type)
+	require.Equal(t, result.Throughput, msg.Result.Throughput)
+}
+
+type badConn struct{ echoConn }
+
+func (c *badConn) ReadMessage() (int, []byte, error) {
+	return textMessage, []byte("x"), nil
+}
+
+func TestRunUnexpectedMessage(t *testing.T) {
+	_, err := Run(&badConn{})
+	require.ErrorIs(t, err, ErrUnexpectedMessage)
+}
+
+func TestRecommend(t *testing.T) {
+	cases := map[string]struct {
+		throughput   int64
+		main         int64
+		sub          int64
+		subAvailable bool
+		expected     bool
+	}{
+		"fast":           {10_000_000, 4_000_000, 500_000, true, false},
+		"slow":           {2_000_000, 4_000_000, 500_000, true, true},
+		"headroom":       {5_000_000, 4_000_000, 500_000, true, true},
+		"noSub":          {2_000_000, 4_000_000, 0, false, false},
+		"unknownBitrate": {4_000_000, 0, 0, true, true},
+		"unknownFast":    {6_000_000, 0, 0, true, false},
+		"tooSlowForBoth": {100_000, 4_000_000, 500_000, true, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := Result{Throughput: tc.throughput}
+			rec := Recommend(r, tc.main, tc.sub, tc.subAvailable)
+			require.Equal(t, tc.expected, rec.Sub, rec.Reason)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore()
+	now := time.Unix(1000, 0)
+	session := SessionKey("a", "1.2.3.4")
+
+	_, exist := s.Get(session, now)
+	require.False(t, exist)
+
+	s.Set(session, Result{Throughput: 1, Time: now})
+	r, exist := s.Get(session, now.Add(time.Minute))
+	require.True(t, exist)
+	require.Equal(t, int64(1), r.Throughput)
+
+	_, exist = s.Get(SessionKey("a", "1.2.3.5"), now)
+	require.False(t, exist)
+
+	_, exist = s.Get(session, now.Add(resultRetention))
+	require.False(t, exist)
+
+	for i := 0; i < storePruneSize; i++ {
+		s.Set(SessionKey("b", string(rune(i))), Result{Time: now})
+	}
+	s.Set("c", Result{Time: now.Add(resultRetention)})
+	require.Len(t, s.results, 1)
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package speedtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Results expire since the network of a client may change.
+const resultRetention = time.Hour
+
+// Number of stored sessions before expired results are pruned.
+const storePruneSize = 1024
+
+// Store keeps the latest result of each session. A session is
+// identified by the account ID and the client IP address.
+type Store struct {
+	results map[string]Result
+	mu      sync.Mutex
+}
+
+// NewStore creates a new store.
+func NewStore() *Store {
+	return &Store{results: make(map[string]Result)}
+}
+
+// SessionKey returns the key of the session.
+func SessionKey(accountID string, ip string) string {
+	return accountID + " " + ip
+}
+
+// Set stores the result of the session.
+func (s *Store) Set(session string, r Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.results) >= storePruneSize {
+		s.pruneUnsafe(r.Time)
+	}
+	s.results[session] = r
+}
+
+// Get returns the result of the session if it hasn't expired.
+func (s *Store) Get(session string, now time.Time) (Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, exist := s.results[session]
+	if !exist || now.Sub(r.Time) >= resultRetention {
+		return Result{}, false
+	}
+	return r, true
+}
+
+func (s *Store) pruneUnsafe(now time.Time) {
+	for session, r := range s.results {
+		if now.Sub(r.Time) >= resultRetention {
+			delete(s.results, session)
+		}
+	}
+}
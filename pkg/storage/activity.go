@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ActivityRange is a range of seconds of a recording that
+// contained events. The seconds are relative to the start
+// of the recording, the end is exclusive.
+type ActivityRange [2]int
+
+// NewActivityIndex returns the sparse activity index of a recording.
+// Each event marks the seconds from its time until the end of its
+// duration, at least one second. Overlapping and adjacent ranges
+// are merged and the result is sorted.
+func NewActivityIndex(start time.Time, end time.Time, events []Event) []ActivityRange {
+	length := int(math.Ceil(end.Sub(start).Seconds()))
+	if length <= 0 {
+		return nil
+	}
+
+	var ranges []ActivityRange
+	for _, e := range events {
+		first := int(math.Floor(e.Time.Sub(start).Seconds()))
+		last := int(math.Ceil(e.Time.Add(e.Duration).Sub(start).Seconds()))
+		if last <= first {
+			last = first + 1
+		}
+		first = max(first, 0)
+		last = min(last, length)
+		if first >= last {
+			continue
+		}
+		ranges = append(ranges, ActivityRange{first, last})
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	merged := []ActivityRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		prev := &merged[len(merged)-1]
+		if r[0] <= prev[1] {
+			prev[1] = max(prev[1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// ActivityIndex returns the stored activity index, or
+// computes it from the events for older recordings.
+func (d RecordingData) ActivityIndex() []ActivityRange {
+	if d.Activity != nil {
+		return d.Activity
+	}
+	return NewActivityIndex(d.Start, d.End, d.Events)
+}
+
+// isActive returns true if the time range overlaps the activity index.
+func isActive(recStart time.Time, activity []ActivityRange, start time.Time, end time.Time) bool {
+	for _, r := range activity {
+		activeStart := recStart.Add(time.Duration(r[0]) * time.Second)
+		activeEnd := recStart.Add(time.Duration(r[1]) * time.Second)
+		if start.Before(activeEnd) && end.After(activeStart) {
+			return true
+		}
+	}
+	return false
+}
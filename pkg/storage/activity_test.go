@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewActivityIndex(t *testing.T) {
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	end := start.Add(60 * time.Second)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	cases := map[string]struct {
+		events   []Event
+		expected []ActivityRange
+	}{
+		"empty": {nil, nil},
+		"instant": {
+			[]Event{{Time: at(5500 * time.Millisecond)}},
+			[]ActivityRange{{5, 6}},
+		},
+		"duration": {
+			[]Event{{Time: at(10 * time.Second), Duration: 2500 * time.Millisecond}},
+			[]ActivityRange{{10, 13}},
+		},
+		"merge": {
+			[]Event{
+				{Time: at(20 * time.Second), Duration: 5 * time.Second},
+				{Time: at(3 * time.Second)},
+				{Time: at(4 * time.Second)},
+				{Time: at(22 * time.Second), Duration: time.Second},
+			},
+			[]ActivityRange{{3, 5}, {20, 25}},
+		},
+		"clamp": {
+			[]Event{
+				{Time: at(-2 * time.Second), Duration: 3 * time.Second},
+				{Time: at(58 * time.Second), Duration: 10 * time.Second},
+				{Time: at(70 * time.Second)},
+			},
+			[]ActivityRange{{0, 1}, {58, 60}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, NewActivityIndex(start, end, tc.events))
+		})
+	}
+}
+
+func TestActivityIndex(t *testing.T) {
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	data := RecordingData{
+		Start:  start,
+		End:    start.Add(time.Minute),
+		Events: []Event{{Time: start.Add(time.Second)}},
+	}
+	require.Equal(t, []ActivityRange{{1, 2}}, data.ActivityIndex())
+
+	data.Activity = []ActivityRange{{3, 4}}
+	require.Equal(t, []ActivityRange{{3, 4}}, data.ActivityIndex())
+}
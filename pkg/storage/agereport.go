@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// AgeReportBuckets are the upper bounds of the age buckets in days.
+// The last bucket holds the recordings that are older than the last bound.
+var AgeReportBuckets = []int{1, 2, 7, 14, 30, 60, 90, 180, 365}
+
+// AgeReport is the size of the recordings of each monitor grouped by
+// age. The age is the number of whole days since the recording day.
+type AgeReport struct {
+	Generated time.Time `json:"generated"`
+
+	// Upper bounds in days, see AgeReportBuckets.
+	Buckets []int `json:"buckets"`
+
+	// Bytes in each bucket by monitor ID, len(Buckets)+1 values.
+	Monitors map[string][]int64 `json:"monitors"`
+}
+
+// ageBucket returns the index of the bucket that the age belongs to.
+func ageBucket(days int) int {
+	for i, bound := range AgeReportBuckets {
+		if days < bound {
+			return i
+		}
+	}
+	return len(AgeReportBuckets)
+}
+
+// generateAgeReport walks the recording directories, see crawler.go.
+// Directories that can't be read are skipped, recordings may be
+// deleted while the report is generated.
+func generateAgeReport(fileSystem fs.FS, now time.Time) AgeReport {
+	report := AgeReport{
+		Generated: now,
+		Buckets:   AgeReportBuckets,
+		Monitors:  make(map[string][]int64),
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	years, _ := fs.ReadDir(fileSystem, ".")
+	for _, year := range years {
+		months, _ := fs.ReadDir(fileSystem, year.Name())
+		for _, month := range months {
+			monthPath := path.Join(year.Name(), month.Name())
+			days, _ := fs.ReadDir(fileSystem, monthPath)
+			for _, day := range days {
+				dayPath := path.Join(monthPath, day.Name())
+				date, err := time.ParseInLocation("2006/01/02", dayPath, now.Location())
+				if err != nil {
+					continue
+				}
+				bucket := ageBucket(int(today.Sub(date).Hours() / 24))
+
+				monitors, _ := fs.ReadDir(fileSystem, dayPath)
+				for _, monitor := range monitors {
+					if !monitor.IsDir() {
+						continue
+					}
+					size := dirSize(fileSystem, path.Join(dayPath, monitor.Name()))
+
+					id := monitor.Name()
+					if report.Monitors[id] == nil {
+						report.Monitors[id] = make([]int64, len(AgeReportBuckets)+1)
+					}
+					report.Monitors[id][bucket] += size
+				}
+			}
+		}
+	}
+	return report
+}
+
+// dirSize returns the total size of the files in the directory.
+func dirSize(fileSystem fs.FS, dir string) int64 {
+	entries, _ := fs.ReadDir(fileSystem, dir)
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size += info.Size()
+	}
+	return size
+}
+
+// ageReportCache generates the report at most once per day.
+type ageReportCache struct {
+	fs     fs.FS
+	report AgeReport
+	valid  bool
+	mu     sync.Mutex
+}
+
+func (c *ageReportCache) get(now time.Time) AgeReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	y1, m1, d1 := c.report.Generated.Date()
+	y2, m2, d2 := now.Date()
+	if c.valid && y1 == y2 && m1 == m2 && d1 == d2 {
+		return c.report
+	}
+	c.report = generateAgeReport(c.fs, now)
+	c.valid = true
+	return c.report
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgeReport(t *testing.T) {
+	data := func(size int) *fstest.MapFile {
+		return &fstest.MapFile{Data: make([]byte, size)}
+	}
+	testFS := fstest.MapFS{
+		"2000/03/10/m1/2000-03-10_1_m1.mdat": data(100),
+		"2000/03/10/m1/2000-03-10_1_m1.meta": data(10),
+		"2000/03/10/m2/2000-03-10_1_m2.mdat": data(1),
+		"2000/03/09/m1/2000-03-09_1_m1.mdat": data(20),
+		"2000/03/01/m1/2000-03-01_1_m1.mdat": data(30),
+		"1999/01/01/m2/1999-01-01_1_m2.mdat": data(40),
+		"2000/03/x/m1/x.mdat":                data(1000),
+		"2000/03/10/file":                    data(1000),
+	}
+	now := time.Date(2000, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	report := generateAgeReport(testFS, now)
+	expected := AgeReport{
+		Generated: now,
+		Buckets:   AgeReportBuckets,
+		Monitors: map[string][]int64{
+			"m1": {110, 20, 0, 30, 0, 0, 0, 0, 0, 0},
+			"m2": {1, 0, 0, 0, 0, 0, 0, 0, 0, 40},
+		},
+	}
+	require.Equal(t, expected, report)
+
+	t.Run("cache", func(t *testing.T) {
+		cache := ageReportCache{fs: testFS}
+		require.Equal(t, expected, cache.get(now))
+
+		// Cached for the rest of the day.
+		testFS["2000/03/10/m3/2000-03-10_1_m3.mdat"] = data(5)
+		require.Equal(t, expected, cache.get(now.Add(11*time.Hour)))
+
+		// The recordings are a day older the next day.
+		report := cache.get(now.Add(12 * time.Hour))
+		require.Equal(t, []int64{0, 5, 0, 0, 0, 0, 0, 0, 0, 0}, report.Monitors["m3"])
+		require.Equal(t, []int64{0, 110, 20, 30, 0, 0, 0, 0, 0, 0}, report.Monitors["m1"])
+	})
+}
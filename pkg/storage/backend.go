@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Backend stores the files of a recordings directory. Names are slash
+// separated paths relative to the root of the backend, see fs.ValidPath.
+// Errors for missing files must match fs.ErrNotExist.
+//
+// The file system implementation is FileBackend, other backends like
+// object storage only have to implement these methods. Use NewBackendFS
+// to read a backend as a fs.FS, the crawler works on any fs.FS.
+type Backend interface {
+	// Read opens a file for reading.
+	Read(name string) (io.ReadCloser, error)
+
+	// Write creates or truncates a file and its parent directories.
+	// The file is complete when the writer is closed.
+	Write(name string) (io.WriteCloser, error)
+
+	// List returns the entries of a directory sorted by name.
+	List(dir string) ([]fs.DirEntry, error)
+
+	// Delete removes a file, or a directory and its contents.
+	// Deleting a file that doesn't exist isn't an error.
+	Delete(name string) error
+
+	// Stat returns the info of a file or directory.
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// FileBackend stores files in a directory on the local file system.
+type FileBackend struct {
+	root      string
+	removeAll func(string) error
+}
+
+// NewFileBackend returns a backend that stores files in the root directory.
+func NewFileBackend(root string) *FileBackend {
+	return &FileBackend{root: root, removeAll: os.RemoveAll}
+}
+
+func (b *FileBackend) path(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(b.root, filepath.FromSlash(name)), nil
+}
+
+// Read implements Backend.
+func (b *FileBackend) Read(name string) (io.ReadCloser, error) {
+	path, err := b.path("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Write implements Backend.
+func (b *FileBackend) Write(name string) (io.WriteCloser, error) {
+	path, err := b.path("write", name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+}
+
+// List implements Backend.
+func (b *FileBackend) List(dir string) ([]fs.DirEntry, error) {
+	path, err := b.path("list", dir)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(path)
+}
+
+// Delete implements Backend. The root can't be deleted.
+func (b *FileBackend) Delete(name string) error {
+	path, err := b.path("delete", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &fs.PathError{Op: "delete", Path: name, Err: fs.ErrInvalid}
+	}
+	return b.removeAll(path)
+}
+
+// Stat implements Backend.
+func (b *FileBackend) Stat(name string) (fs.FileInfo, error) {
+	path, err := b.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
+
+// backendFS is a read only view of a backend.
+type backendFS struct {
+	backend Backend
+}
+
+// NewBackendFS returns the backend as a read only file system.
+func NewBackendFS(backend Backend) fs.FS {
+	return backendFS{backend: backend}
+}
+
+func (f backendFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.backend.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &backendDir{backend: f.backend, name: name, info: info}, nil
+	}
+	r, err := f.backend.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := r.(fs.File); ok {
+		return file, nil
+	}
+	return &backendFile{ReadCloser: r, info: info}, nil
+}
+
+func (f backendFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.backend.List(name)
+}
+
+func (f backendFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.backend.Stat(name)
+}
+
+type backendFile struct {
+	io.ReadCloser
+	info fs.FileInfo
+}
+
+func (f *backendFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+type backendDir struct {
+	backend Backend
+	name    string
+	info    fs.FileInfo
+
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *backendDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *backendDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *backendDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *backendDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.backend.List(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+var errIsDirectory = errors.New("is a directory")
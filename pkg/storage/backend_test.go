@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBackend(t *testing.T) {
+	root := t.TempDir()
+	b := NewFileBackend(root)
+
+	w, err := b.Write("2000/01/01/m1/a.json")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := b.Read("2000/01/01/m1/a.json")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "abc", string(data))
+
+	info, err := b.Stat("2000/01/01/m1/a.json")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), info.Size())
+
+	entries, err := b.List("2000/01/01")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "m1", entries[0].Name())
+
+	_, err = b.Read("../a")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+	require.ErrorIs(t, b.Delete("."), fs.ErrInvalid)
+
+	require.NoError(t, b.Delete("2000"))
+	require.NoError(t, b.Delete("2000"))
+	_, err = b.Stat("2000")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.DirExists(t, root)
+}
+
+func TestBackendFS(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"2000/01/01/m1/a.json", "2000/01/01/m1/a.mp4", "b"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o600))
+	}
+
+	fileSystem := NewBackendFS(NewFileBackend(root))
+	require.NoError(t, fstest.TestFS(fileSystem, "2000/01/01/m1/a.json", "2000/01/01/m1/a.mp4", "b"))
+
+	file, err := fs.ReadFile(fileSystem, "b")
+	require.NoError(t, err)
+	require.Equal(t, "b", string(file))
+}
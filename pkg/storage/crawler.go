@@ -21,7 +21,9 @@ import (
 //         └── Monitor2
 //             ├── YYYY-MM-DD_hh-mm-ss_monitor2.jpeg  // Thumbnail.
 //             ├── YYYY-MM-DD_hh-mm-ss_monitor2.mp4   // Video.
-//             └── YYYY-MM-DD_hh-mm-ss_monitor2.json  // Event data.
+//             ├── YYYY-MM-DD_hh-mm-ss_monitor2.json  // Event data.
+//             ├── YYYY-MM-DD_hh-mm-ss_monitor2.protected  // Optional marker.
+//             └── YYYY-MM-DD_hh-mm-ss_monitor2.corrupt    // Optional marker.
 //
 // Event data is only generated If video was saved successfully.
 // The job of these functions are to on-request find and return recording IDs.
@@ -36,6 +38,14 @@ type CrawlerQuery struct {
 	// If event data should be read from file and included.
 	IncludeData bool
 
+	// Recordings that don't match are skipped.
+	Filter RecordingFilter
+
+	// Recordings with a greater ID are skipped if set. Paginated queries
+	// set it to the latest recording when the first page was read, so
+	// the pages don't change while new recordings are being written.
+	Until string
+
 	// Query scoped cache to avoid reading the same directory twice.
 	cache queryCache
 }
@@ -47,7 +57,8 @@ type Crawler struct {
 	fs fs.FS
 }
 
-// NewCrawler creates new crawler.
+// NewCrawler creates new crawler. The file system is usually
+// the recordings of every backend, see Manager.RecordingsFS.
 func NewCrawler(fileSystem fs.FS) *Crawler {
 	return &Crawler{fs: fileSystem}
 }
@@ -89,21 +100,164 @@ func (c *Crawler) RecordingByQuery(q *CrawlerQuery) ([]Recording, error) {
 			return recordings, nil
 		}
 
-		data := func() *RecordingData {
-			if q.IncludeData {
-				return readDataFile(file.fs)
+		if q.Until != "" && file.name > q.Until {
+			if q.Reverse {
+				return recordings, nil
 			}
-			return nil
-		}()
+			continue
+		}
 
+		var data *RecordingData
+		if q.IncludeData || !q.Filter.IsZero() {
+			data = readDataFile(file.fs)
+		}
+		if !q.Filter.Match(data) {
+			continue
+		}
+		if !q.IncludeData {
+			data = nil
+		}
+
+		var status string
+		if file.corrupt {
+			status = RecordingStatusCorrupt
+		}
 		recordings = append(recordings, Recording{
-			ID:   filepath.Base(file.path),
-			Data: data,
+			ID:        filepath.Base(file.path),
+			Protected: file.protected,
+			Data:      data,
+			Status:    status,
 		})
 	}
 	return recordings, nil
 }
 
+// CountRecordings returns the number of recordings of the selected
+// monitors and the ID of the latest recording. Recordings with a greater
+// ID than q.Until are not counted. The filter is ignored because it would
+// require reading the data of every recording, the count is then a upper
+// bound. The time, limit and reverse fields are also ignored.
+func (c *Crawler) CountRecordings(q *CrawlerQuery) (int, string, error) {
+	selected := func(monitor string) bool {
+		if len(q.Monitors) == 0 {
+			return true
+		}
+		for _, m := range q.Monitors {
+			if m == monitor {
+				return true
+			}
+		}
+		return false
+	}
+
+	count := 0
+	latest := ""
+	var walk func(path string, depth int) error
+	walk = func(path string, depth int) error {
+		entries, err := fs.ReadDir(c.fs, path)
+		if err != nil {
+			return fmt.Errorf("read directory: %v: %w", path, err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			switch {
+			case depth < monitorDepth+1:
+				if !entry.IsDir() || (depth == monitorDepth && !selected(name)) {
+					continue
+				}
+				if err := walk(filepath.Join(path, name), depth+1); err != nil {
+					return err
+				}
+			case strings.HasSuffix(name, ".json"):
+				id := strings.TrimSuffix(name, ".json")
+				if q.Until != "" && id > q.Until {
+					continue
+				}
+				count++
+				if id > latest {
+					latest = id
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(".", 0); err != nil {
+		return 0, "", err
+	}
+	return count, latest, nil
+}
+
+// RecordingEvent is an event with the recording it belongs to.
+type RecordingEvent struct {
+	RecordingID string `json:"recordingId"`
+	MonitorID   string `json:"monitorId"`
+	Event
+}
+
+// Maximum number of recordings read by a single events query.
+const maxEventQueryRecordings = 1000
+
+// EventsByQuery returns the events of the recordings that match the query
+// and the ID of the last read recording, the time of the next page. The
+// events are ordered by recording, recordings are never split between pages
+// so a page may contain more than limit events. A page may contain fewer
+// events if many recordings have no events. The ID is empty on the last page.
+func (c *Crawler) EventsByQuery(q *CrawlerQuery) ([]RecordingEvent, string, error) {
+	if q.Limit < 1 {
+		return nil, "", fmt.Errorf("limit: %v: %w", q.Limit, ErrInvalidValue)
+	}
+	events := []RecordingEvent{}
+	cursor := q.Time
+	for read := 0; read < maxEventQueryRecordings; {
+		recordings, err := c.RecordingByQuery(&CrawlerQuery{
+			Time:        cursor,
+			Limit:       q.Limit,
+			Reverse:     q.Reverse,
+			Monitors:    q.Monitors,
+			IncludeData: true,
+			Filter:      q.Filter,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, rec := range recordings {
+			events = append(events, recordingEvents(rec, q.Reverse)...)
+			cursor = rec.ID
+			read++
+			if len(events) >= q.Limit || read >= maxEventQueryRecordings {
+				return events, cursor, nil
+			}
+		}
+		if len(recordings) < q.Limit {
+			return events, "", nil
+		}
+	}
+	return events, cursor, nil
+}
+
+// recordingEvents returns the events of the recording in the order of the query.
+func recordingEvents(rec Recording, reverse bool) []RecordingEvent {
+	if rec.Data == nil {
+		return nil
+	}
+	var monitorID string
+	if path, err := RecordingIDToPath(rec.ID); err == nil {
+		monitorID = filepath.Base(filepath.Dir(path))
+	}
+
+	events := make([]RecordingEvent, len(rec.Data.Events))
+	for i, e := range rec.Data.Events {
+		events[i] = RecordingEvent{RecordingID: rec.ID, MonitorID: monitorID, Event: e}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if reverse {
+			return events[i].Time.Before(events[j].Time)
+		}
+		return events[i].Time.After(events[j].Time)
+	})
+	return events
+}
+
 func readDataFile(fileSystem fs.FS) *RecordingData {
 	rawData, err := fs.ReadFile(fileSystem, ".")
 	if err != nil {
@@ -114,6 +268,7 @@ func readDataFile(fileSystem fs.FS) *RecordingData {
 	if err != nil {
 		return nil
 	}
+	data.upgrade()
 	return &data
 }
 
@@ -186,6 +341,10 @@ type dir struct {
 	depth  int
 	parent *dir
 	query  *CrawlerQuery
+
+	// Only set on recordings.
+	protected bool
+	corrupt   bool
 }
 
 const (
@@ -272,6 +431,8 @@ func (d *dir) findAllFiles() ([]dir, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read monitor directory: %v: %w", monitorPath, err)
 		}
+		protected := protectedIDs(files)
+		corrupt := corruptIDs(files)
 		for _, file := range files {
 			if file.IsDir() {
 				return nil, fmt.Errorf("%v: %w", monitorPath, ErrUnexpectedDir)
@@ -287,13 +448,18 @@ func (d *dir) findAllFiles() ([]dir, error) {
 				return nil, fmt.Errorf("file fs: %v: %w", jsonPath, err)
 			}
 
+			name := strings.TrimSuffix(file.Name(), ".json")
+			_, isProtected := protected[name]
+			_, isCorrupt := corrupt[name]
 			allFiles = append(allFiles, dir{
-				fs:     fileFS,
-				name:   strings.TrimSuffix(file.Name(), ".json"),
-				path:   path,
-				parent: d,
-				depth:  d.depth + 2,
-				query:  d.query,
+				fs:        fileFS,
+				name:      name,
+				path:      path,
+				parent:    d,
+				depth:     d.depth + 2,
+				query:     d.query,
+				protected: isProtected,
+				corrupt:   isCorrupt,
 			})
 		}
 	}
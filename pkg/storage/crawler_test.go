@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -168,9 +169,36 @@ func TestRecordingByQuery(t *testing.T) {
 		if err := json.Unmarshal(crawlerTestData, &expected); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
+		// Version 0 data is upgraded.
+		expected.Summarize()
 
 		actual := *rec[0].Data
 		require.Equal(t, actual, expected)
+		require.Equal(t, []string{"a"}, actual.Labels)
+	})
+	t.Run("filter", func(t *testing.T) {
+		c := NewCrawler(crawlerTestFS)
+		rec, err := c.RecordingByQuery(
+			&CrawlerQuery{
+				Time:   "9999-01-01",
+				Limit:  2,
+				Filter: RecordingFilter{Labels: []string{"x", "a"}},
+			},
+		)
+		require.NoError(t, err)
+		require.Len(t, rec, 1)
+		require.Equal(t, "2099-01-01_1_m1", rec[0].ID)
+		require.Nil(t, rec[0].Data)
+
+		rec, err = c.RecordingByQuery(
+			&CrawlerQuery{
+				Time:   "9999-01-01",
+				Limit:  1,
+				Filter: RecordingFilter{MinDuration: 3 * time.Second},
+			},
+		)
+		require.NoError(t, err)
+		require.Empty(t, rec)
 	})
 	t.Run("missingData", func(t *testing.T) {
 		c := NewCrawler(crawlerTestFS)
@@ -200,3 +228,143 @@ func TestRecordingIDToPath(t *testing.T) {
 		require.ErrorIs(t, err, ErrInvalidRecordingID)
 	})
 }
+
+func newEventsTestData(t *testing.T, times ...string) []byte {
+	t.Helper()
+	data := RecordingData{}
+	for _, s := range times {
+		eventTime, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		data.Events = append(data.Events, Event{Time: eventTime})
+	}
+	rawData, err := json.Marshal(data)
+	require.NoError(t, err)
+	return rawData
+}
+
+func TestEventsByQuery(t *testing.T) {
+	testFS := fstest.MapFS{
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.json": {
+			Data: newEventsTestData(t, "2000-01-01T01:00:01Z", "2000-01-01T01:00:02Z"),
+		},
+		"2000/01/01/m2/2000-01-01_02-00-00_m2.json": {Data: newEventsTestData(t)},
+		"2000/01/01/m2/2000-01-01_03-00-00_m2.json": {
+			Data: newEventsTestData(t, "2000-01-01T03:00:01Z"),
+		},
+		"2000/01/01/m3/2000-01-01_04-00-00_m3.json": {
+			Data: newEventsTestData(t, "2000-01-01T04:00:01Z"),
+		},
+	}
+	type result struct {
+		RecordingID string
+		MonitorID   string
+		Time        string
+	}
+	query := func(q *CrawlerQuery) ([]result, string) {
+		events, next, err := NewCrawler(testFS).EventsByQuery(q)
+		require.NoError(t, err)
+		results := []result{}
+		for _, e := range events {
+			results = append(results, result{
+				RecordingID: e.RecordingID,
+				MonitorID:   e.MonitorID,
+				Time:        e.Time.Format("15:04:05"),
+			})
+		}
+		return results, next
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		q := &CrawlerQuery{
+			Time:     "9999-01-01",
+			Limit:    1,
+			Monitors: []string{"m1", "m2"},
+		}
+		events, next := query(q)
+		require.Equal(t, []result{
+			{"2000-01-01_03-00-00_m2", "m2", "03:00:01"},
+		}, events)
+		require.Equal(t, "2000-01-01_03-00-00_m2", next)
+
+		q.Time = next
+		events, next = query(q)
+		require.Equal(t, []result{
+			{"2000-01-01_01-00-00_m1", "m1", "01:00:02"},
+			{"2000-01-01_01-00-00_m1", "m1", "01:00:01"},
+		}, events)
+		require.Equal(t, "2000-01-01_01-00-00_m1", next)
+
+		q.Time = next
+		events, next = query(q)
+		require.Equal(t, []result{}, events)
+		require.Equal(t, "", next)
+	})
+	t.Run("reverse", func(t *testing.T) {
+		events, next := query(&CrawlerQuery{
+			Time:     "0000-01-01",
+			Limit:    10,
+			Reverse:  true,
+			Monitors: []string{"m1", "m3"},
+		})
+		require.Equal(t, []result{
+			{"2000-01-01_01-00-00_m1", "m1", "01:00:01"},
+			{"2000-01-01_01-00-00_m1", "m1", "01:00:02"},
+			{"2000-01-01_04-00-00_m3", "m3", "04:00:01"},
+		}, events)
+		require.Equal(t, "", next)
+	})
+	t.Run("limitErr", func(t *testing.T) {
+		_, _, err := NewCrawler(testFS).EventsByQuery(&CrawlerQuery{Time: "9999-01-01"})
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+}
+
+func TestCountRecordings(t *testing.T) {
+	c := NewCrawler(fstest.MapFS{
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.json": {},
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.mp4":  {},
+		"2000/01/01/m2/2000-01-01_02-00-00_m2.json": {},
+		"2000/01/02/m1/2000-01-02_01-00-00_m1.json": {},
+	})
+	count, latest, err := c.CountRecordings(&CrawlerQuery{})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, "2000-01-02_01-00-00_m1", latest)
+
+	count, latest, err = c.CountRecordings(&CrawlerQuery{
+		Monitors: []string{"m2"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, "2000-01-01_02-00-00_m2", latest)
+
+	count, _, err = c.CountRecordings(&CrawlerQuery{
+		Until: "2000-01-01_02-00-00_m2",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestRecordingByQueryUntil(t *testing.T) {
+	c := NewCrawler(crawlerTestFS)
+	query := func(time string, reverse bool) []string {
+		recordings, err := c.RecordingByQuery(&CrawlerQuery{
+			Time:    time,
+			Limit:   3,
+			Reverse: reverse,
+			Until:   "2004-01-01_1_m1",
+		})
+		require.NoError(t, err)
+		var ids []string
+		for _, rec := range recordings {
+			ids = append(ids, rec.ID)
+		}
+		return ids
+	}
+	require.Equal(t,
+		[]string{"2004-01-01_1_m1", "2003-01-01_1_m2", "2003-01-01_1_m1"},
+		query("9999-01-01", false))
+	require.Equal(t,
+		[]string{"2004-01-01_1_m1"},
+		query("2003-01-01_1_m2", true))
+}
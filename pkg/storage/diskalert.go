@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"nvr/pkg/feed"
+	"time"
+)
+
+// DiskAlert is sent when the disk usage changes alert level.
+type DiskAlert struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Percent int       `json:"percent"`
+
+	// Projected retention in days, only set for the retention levels.
+	RetentionDays int `json:"retentionDays,omitempty"`
+}
+
+// Disk alert levels.
+const (
+	DiskAlertOK   = "ok"
+	DiskAlertHigh = "high"
+	DiskAlertFull = "full"
+
+	// A recording was saved to the failover directory because no
+	// storage volume is writable. Restored is sent when all recordings
+	// have been moved back to the volumes. See Volumes.RunFailover.
+	DiskAlertFailover = "failover"
+	DiskAlertRestored = "restored"
+
+	// The projected retention fell below the minimum retention or is
+	// back above it. See Manager.ForecastLoop.
+	DiskAlertRetentionLow = "retentionLow"
+	DiskAlertRetentionOK  = "retentionOk"
+)
+
+// Storage is pruned at 99% usage, staying
+// above it means that pruning is failing.
+const (
+	diskAlertHighPercent = 90
+	diskAlertFullPercent = 99
+)
+
+const diskAlertHistorySize = 16
+
+func diskAlertLevel(percent int) string {
+	switch {
+	case percent >= diskAlertFullPercent:
+		return DiskAlertFull
+	case percent >= diskAlertHighPercent:
+		return DiskAlertHigh
+	default:
+		return DiskAlertOK
+	}
+}
+
+// checkAlert sends a alert if the level changed since the
+// last update. The update lock must be held.
+func (d *disk) checkAlert(usage DiskUsage, now time.Time) {
+	if d.alerts == nil {
+		return
+	}
+	level := diskAlertLevel(usage.Percent)
+	if level == d.alertLevel {
+		return
+	}
+	d.alertLevel = level
+	d.alerts.Push(DiskAlert{
+		Time:    now,
+		Level:   level,
+		Percent: usage.Percent,
+	})
+}
+
+// DiskAlerts returns the buffer with the recent disk alerts.
+// The usage is checked when the disk usage is updated.
+func (s *Manager) DiskAlerts() *feed.Buffer[DiskAlert] {
+	return s.disk.alerts
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"nvr/pkg/feed"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskAlert(t *testing.T) {
+	d := &disk{
+		alerts:     feed.NewBuffer[DiskAlert](diskAlertHistorySize),
+		alertLevel: DiskAlertOK,
+	}
+	now := time.Unix(1, 0).UTC()
+	for _, percent := range []int{10, 89, 90, 95, 99, 100, 50} {
+		d.checkAlert(DiskUsage{Percent: percent}, now)
+	}
+
+	items, _ := d.alerts.Since(0)
+	var alerts []DiskAlert
+	for _, item := range items {
+		alerts = append(alerts, item.Value)
+	}
+	expected := []DiskAlert{
+		{Time: now, Level: DiskAlertHigh, Percent: 90},
+		{Time: now, Level: DiskAlertFull, Percent: 99},
+		{Time: now, Level: DiskAlertOK, Percent: 50},
+	}
+	require.Equal(t, expected, alerts)
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Download limits.
+const (
+	MaxDownloadRecordings = 100
+	MaxDownloadDuration   = 24 * time.Hour
+)
+
+// Download errors.
+var (
+	ErrNoDownloadRecordings      = errors.New("no recordings")
+	ErrTooManyDownloadRecordings = errors.New("too many recordings")
+	ErrInvalidDownloadRange      = errors.New("invalid time range")
+	ErrDuplicateRecordingID      = errors.New("duplicate recording ID")
+)
+
+// RecordingsInRange returns the IDs of the finished recordings that overlap
+// the time range, oldest first. All monitors are included if monitors is empty.
+func RecordingsInRange(
+	recordingsDirs []string,
+	monitors []string,
+	start time.Time,
+	end time.Time,
+) ([]string, error) {
+	if !end.After(start) || end.Sub(start) > MaxDownloadDuration {
+		return nil, fmt.Errorf("%w: max duration %v", ErrInvalidDownloadRange, MaxDownloadDuration)
+	}
+	for _, id := range monitors {
+		if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMonitorID, id)
+		}
+	}
+
+	type recording struct {
+		id    string
+		start time.Time
+	}
+	var recordings []recording
+
+	// Recordings that start the day before may overlap the range.
+	firstDay := start.UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	for day := firstDay; !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, recordingsDir := range recordingsDirs {
+			dayDir := filepath.Join(recordingsDir, day.Format("2006/01/02"))
+			dayMonitors := monitors
+			if len(dayMonitors) == 0 {
+				var err error
+				dayMonitors, err = readDirNames(dayDir)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for _, monitorID := range dayMonitors {
+				dir := filepath.Join(dayDir, monitorID)
+				entries, err := os.ReadDir(dir)
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("read directory: %w", err)
+				}
+				for _, entry := range entries {
+					recID, isData := strings.CutSuffix(entry.Name(), ".json")
+					if !isData {
+						continue
+					}
+					data, err := readRecordingData(filepath.Join(dir, recID))
+					if err != nil {
+						continue
+					}
+					if !data.End.After(start) || !data.Start.Before(end) {
+						continue
+					}
+					recordings = append(recordings, recording{id: recID, start: data.Start})
+				}
+			}
+		}
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].start.Before(recordings[j].start)
+	})
+	ids := make([]string, 0, len(recordings))
+	for _, rec := range recordings {
+		ids = append(ids, rec.id)
+	}
+	return ids, nil
+}
+
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func readRecordingData(recordingPath string) (*RecordingData, error) {
+	rawData, err := os.ReadFile(recordingPath + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var data RecordingData
+	if err := json.Unmarshal(rawData, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DownloadFile is a recording in a download archive.
+type DownloadFile struct {
+	RecordingID string
+	Source      PlaybackSource
+
+	// Name of the video in the archive without extension,
+	// "<monitor-id>_<2006-01-02_15-04-05>".
+	Name string
+
+	// Nil if the recording has no data file.
+	Data *RecordingData
+}
+
+// NewDownload returns the files of the recordings. All recordings must exist,
+// the archive is streamed so errors can't be reported after it has started.
+func NewDownload(recordingsDirs []string, ids []string) ([]DownloadFile, error) {
+	if len(ids) == 0 {
+		return nil, ErrNoDownloadRecordings
+	}
+	if len(ids) > MaxDownloadRecordings {
+		return nil, fmt.Errorf("%w: %v, max %v",
+			ErrTooManyDownloadRecordings, len(ids), MaxDownloadRecordings)
+	}
+
+	files := make([]DownloadFile, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateRecordingID, id)
+		}
+		seen[id] = true
+
+		recPath, err := RecordingIDToPath(id)
+		if err != nil {
+			return nil, err
+		}
+		recordingsDir := FindRecordingsDir(recordingsDirs, recPath)
+		path := filepath.Join(recordingsDir, recPath)
+
+		source, err := FindPlaybackSource(path)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", id, err)
+		}
+
+		file := DownloadFile{
+			RecordingID: id,
+			Source:      source,
+			Name:        id[20:] + "_" + id[:19],
+		}
+		if data, err := readRecordingData(path); err == nil {
+			file.Data = data
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// WriteDownloadZip streams the recordings as a ZIP archive. Each video
+// is followed by a JSON file with the same name and the recording data.
+// The videos are already compressed and are stored without compression.
+func WriteDownloadZip(w io.Writer, files []DownloadFile, cache *VideoCache) error {
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		if err := writeDownloadVideo(zw, file, cache); err != nil {
+			return fmt.Errorf("%v: %w", file.RecordingID, err)
+		}
+		if file.Data == nil {
+			continue
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.Name + ".json",
+			Method:   zip.Deflate,
+			Modified: file.Data.End,
+		})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeDownloadVideo(zw *zip.Writer, file DownloadFile, cache *VideoCache) error {
+	ext := ".mp4"
+	if file.Source.Format == PlaybackH264 {
+		ext = ".h264"
+	}
+	video, err := file.Source.Open(cache)
+	if err != nil {
+		return err
+	}
+	defer video.Close()
+
+	header := &zip.FileHeader{Name: file.Name + ext, Method: zip.Store}
+	if file.Data != nil {
+		header.Modified = file.Data.Start
+	}
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, video)
+	return err
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownload(t *testing.T) {
+	recordingsDir := t.TempDir()
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	metaID := filepath.Base(writeVODRecording(t, recordingsDir, start, nil))
+
+	// Raw H264 recording of another monitor without a data file.
+	h264ID := "2000-01-02_00-01-00_m2"
+	h264Dir := filepath.Join(recordingsDir, "2000", "01", "02", "m2")
+	require.NoError(t, os.MkdirAll(h264Dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(h264Dir, h264ID+".h264"), []byte("h264"), 0o600))
+
+	dirs := []string{recordingsDir}
+
+	t.Run("inRange", func(t *testing.T) {
+		ids, err := RecordingsInRange(dirs, nil, start.Add(-time.Hour), start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []string{metaID}, ids)
+
+		ids, err = RecordingsInRange(dirs, []string{"m2"}, start.Add(-time.Hour), start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, ids)
+
+		ids, err = RecordingsInRange(dirs, nil, start.Add(time.Minute), start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, ids)
+	})
+	t.Run("inRangeErr", func(t *testing.T) {
+		_, err := RecordingsInRange(dirs, nil, start, start)
+		require.ErrorIs(t, err, ErrInvalidDownloadRange)
+
+		_, err = RecordingsInRange(dirs, nil, start, start.Add(25*time.Hour))
+		require.ErrorIs(t, err, ErrInvalidDownloadRange)
+
+		_, err = RecordingsInRange(dirs, []string{".."}, start, start.Add(time.Hour))
+		require.ErrorIs(t, err, ErrInvalidMonitorID)
+	})
+	t.Run("zip", func(t *testing.T) {
+		files, err := NewDownload(dirs, []string{metaID, h264ID})
+		require.NoError(t, err)
+
+		var b bytes.Buffer
+		require.NoError(t, WriteDownloadZip(&b, files, nil))
+
+		zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+		require.NoError(t, err)
+
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		expected := []string{
+			"m1_2000-01-02_00-00-00.mp4",
+			"m1_2000-01-02_00-00-00.json",
+			"m2_2000-01-02_00-01-00.h264",
+		}
+		require.Equal(t, expected, names)
+
+		video, err := zr.File[0].Open()
+		require.NoError(t, err)
+		rawVideo, err := io.ReadAll(video)
+		require.NoError(t, err)
+		require.Equal(t, "ftyp", string(rawVideo[4:8]))
+
+		rawData, err := zr.File[1].Open()
+		require.NoError(t, err)
+		var data RecordingData
+		require.NoError(t, json.NewDecoder(rawData).Decode(&data))
+		require.True(t, data.Start.Equal(start))
+
+		h264, err := zr.File[2].Open()
+		require.NoError(t, err)
+		rawH264, err := io.ReadAll(h264)
+		require.NoError(t, err)
+		require.Equal(t, "h264", string(rawH264))
+	})
+	t.Run("downloadErr", func(t *testing.T) {
+		_, err := NewDownload(dirs, nil)
+		require.ErrorIs(t, err, ErrNoDownloadRecordings)
+
+		_, err = NewDownload(dirs, make([]string, MaxDownloadRecordings+1))
+		require.ErrorIs(t, err, ErrTooManyDownloadRecordings)
+
+		_, err = NewDownload(dirs, []string{metaID, metaID})
+		require.ErrorIs(t, err, ErrDuplicateRecordingID)
+
+		_, err = NewDownload(dirs, []string{"x"})
+		require.ErrorIs(t, err, ErrInvalidRecordingID)
+
+		_, err = NewDownload(dirs, []string{"2000-01-02_00-02-00_m1"})
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
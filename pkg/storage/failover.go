@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"nvr/pkg/feed"
+	"nvr/pkg/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SetAlerts sets the buffer that failover alerts are pushed to.
+func (v *Volumes) SetAlerts(alerts *feed.Buffer[DiskAlert]) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.alerts = alerts
+}
+
+// setFailover sends a alert if the failover
+// state changed. The lock must be held.
+func (v *Volumes) setFailover(failover bool) {
+	if v.failover == failover {
+		return
+	}
+	v.failover = failover
+
+	level := DiskAlertRestored
+	if failover {
+		level = DiskAlertFailover
+		v.logf(log.LevelWarning, "no writable storage volume, recording to the failover directory")
+	} else {
+		v.logf(log.LevelInfo, "storage volumes are writable again, recordings moved from the failover directory")
+	}
+	if v.alerts != nil {
+		v.alerts.Push(DiskAlert{Time: time.Now(), Level: level})
+	}
+}
+
+// RunFailover moves the complete recordings from the failover directory
+// to the storage volumes on an interval, until ctx is canceled. Recordings
+// that were left over before a restart are also moved. The pinned function
+// returns the pinned storage volume of the monitor, the recordings are
+// moved to it like RecordingsDir would select it for a new recording.
+func (v *Volumes) RunFailover(
+	ctx context.Context,
+	interval time.Duration,
+	pinned func(monitorID string) string,
+) {
+	if v.failoverDir == "" {
+		return
+	}
+	for {
+		if err := v.migrateFailover(ctx, pinned); err != nil && !errors.Is(err, context.Canceled) {
+			v.logf(log.LevelError, "move recordings from failover directory: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// migrateFailover moves the complete recordings if a volume is writable.
+// The lock isn't held while moving, it would block new recordings.
+func (v *Volumes) migrateFailover(ctx context.Context, pinned func(monitorID string) string) error {
+	selectDst := func(monitorID string) (string, error) {
+		pinnedDir := ""
+		if pinned != nil {
+			pinnedDir = pinned(monitorID)
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.selectDir(pinnedDir)
+	}
+
+	srcDir := recordingsDir(v.failoverDir)
+	remaining, err := migrateDir(ctx, srcDir, selectDst, ".", 0)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if remaining == 0 {
+		v.mu.Lock()
+		v.setFailover(false)
+		v.mu.Unlock()
+	}
+	return nil
+}
+
+// migrateDir moves the recordings in the directory at the depth, 0 is the
+// recordings directory. Empty directories are removed. Returns the number
+// of remaining entries in the directory. The destination is selected per
+// monitor directory, the monitor is skipped if no volume is writable.
+func migrateDir(
+	ctx context.Context,
+	srcDir string,
+	selectDst func(monitorID string) (string, error),
+	dir string,
+	depth int,
+) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	entries, err := os.ReadDir(filepath.Join(srcDir, dir))
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := len(entries)
+	if depth == monitorDirDepth {
+		dstDir, err := selectDst(filepath.Base(dir))
+		if err != nil {
+			// The volumes are still unavailable.
+			return remaining, nil //nolint:nilerr
+		}
+		moved, err := migrateRecordings(srcDir, dstDir, dir, entries)
+		remaining -= moved
+		if err != nil {
+			return remaining, err
+		}
+	} else {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			child := filepath.Join(dir, entry.Name())
+			n, err := migrateDir(ctx, srcDir, selectDst, child, depth+1)
+			if err != nil {
+				return remaining, err
+			}
+			// Fails if a recording was started in the directory.
+			if n == 0 && os.Remove(filepath.Join(srcDir, child)) == nil {
+				remaining--
+			}
+		}
+	}
+	return remaining, nil
+}
+
+// migrateRecordings moves the complete recordings in the monitor directory.
+// The data file is written last, recordings without one are still being
+// recorded. Returns the number of moved files.
+func migrateRecordings(srcDir string, dstDir string, dir string, entries []os.DirEntry) (int, error) {
+	recordings := make(map[string][]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		id := recordingIDFromFile(entry.Name())
+		recordings[id] = append(recordings[id], entry.Name())
+	}
+
+	ids := make([]string, 0, len(recordings))
+	for id := range recordings {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	moved := 0
+	for _, id := range ids {
+		files := recordings[id]
+		if !hasFile(files, id+".json") {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Join(dstDir, dir), 0o755); err != nil {
+			return moved, err
+		}
+		// The data file is moved last so that the
+		// recording isn't shown until it's complete.
+		sort.Slice(files, func(i, j int) bool {
+			return !strings.HasSuffix(files[i], ".json") && strings.HasSuffix(files[j], ".json")
+		})
+		for _, file := range files {
+			src := filepath.Join(srcDir, dir, file)
+			dst := filepath.Join(dstDir, dir, file)
+			if err := moveFile(src, dst); err != nil {
+				return moved, fmt.Errorf("move %v: %w", file, err)
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func hasFile(files []string, name string) bool {
+	for _, file := range files {
+		if file == name {
+			return true
+		}
+	}
+	return false
+}
+
+// moveFile renames the file, or copies and removes it if
+// the destination is on a different file system.
+func moveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (v *Volumes) logf(level log.Level, format string, a ...interface{}) {
+	if v.logger == nil {
+		return
+	}
+	v.logger.Log(log.Entry{
+		Level: level,
+		Src:   "app",
+		Msg:   fmt.Sprintf(format, a...),
+	})
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nvr/pkg/feed"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumesFailover(t *testing.T) {
+	tempDir := t.TempDir()
+	storageDir := filepath.Join(tempDir, "storage")
+	failoverDir := filepath.Join(tempDir, "failover")
+
+	unavailable := true
+	alerts := feed.NewBuffer[DiskAlert](4)
+	v := &Volumes{
+		storageDirs: []string{storageDir},
+		strategy:    StrategySequential,
+		failoverDir: failoverDir,
+		alerts:      alerts,
+		checkWritable: func(dir string) error {
+			if unavailable && dir == recordingsDir(storageDir) {
+				return errors.New("mock")
+			}
+			return checkWritable(dir)
+		},
+		freeSpace: func(string) (uint64, error) { return volumeMinFree, nil },
+	}
+	levels := func() []string {
+		items, _ := alerts.Since(0)
+		var levels []string
+		for _, item := range items {
+			levels = append(levels, item.Value.Level)
+		}
+		return levels
+	}
+
+	dir, err := v.RecordingsDir("")
+	require.NoError(t, err)
+	require.Equal(t, recordingsDir(failoverDir), dir)
+	require.Equal(t, []string{DiskAlertFailover}, levels())
+
+	monitorDir := filepath.Join(dir, "2000/01/01/m1")
+	require.NoError(t, os.MkdirAll(monitorDir, 0o755))
+	writeFile := func(name string) {
+		require.NoError(t, os.WriteFile(filepath.Join(monitorDir, name), []byte(name), 0o600))
+	}
+	// Complete.
+	writeFile("2000-01-01_01-01-01_m1.meta")
+	writeFile("2000-01-01_01-01-01_m1.mdat")
+	writeFile("2000-01-01_01-01-01_m1.jpeg")
+	writeFile("2000-01-01_01-01-01_m1.json")
+	// Recording.
+	writeFile("2000-01-01_02-02-02_m1.meta")
+	writeFile("2000-01-01_02-02-02_m1.mdat")
+
+	// Volume is still unavailable.
+	require.NoError(t, v.migrateFailover(context.Background(), nil))
+	require.NoDirExists(t, filepath.Join(storageDir, "recordings/2000"))
+
+	unavailable = false
+	require.NoError(t, v.migrateFailover(context.Background(), nil))
+
+	dstDir := filepath.Join(storageDir, "recordings/2000/01/01/m1")
+	entries, err := os.ReadDir(dstDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{
+		"2000-01-01_01-01-01_m1.jpeg",
+		"2000-01-01_01-01-01_m1.json",
+		"2000-01-01_01-01-01_m1.mdat",
+		"2000-01-01_01-01-01_m1.meta",
+	}, names)
+	content, err := os.ReadFile(filepath.Join(dstDir, "2000-01-01_01-01-01_m1.json"))
+	require.NoError(t, err)
+	require.Equal(t, "2000-01-01_01-01-01_m1.json", string(content))
+
+	// The recording in progress is kept.
+	require.FileExists(t, filepath.Join(monitorDir, "2000-01-01_02-02-02_m1.meta"))
+	require.Equal(t, []string{DiskAlertFailover}, levels())
+
+	// Finish the recording.
+	writeFile("2000-01-01_02-02-02_m1.json")
+	require.NoError(t, v.migrateFailover(context.Background(), nil))
+	require.FileExists(t, filepath.Join(dstDir, "2000-01-01_02-02-02_m1.json"))
+	require.NoDirExists(t, filepath.Join(dir, "2000"))
+	require.DirExists(t, dir)
+	require.Equal(t, []string{DiskAlertFailover, DiskAlertRestored}, levels())
+
+	// Volume is writable.
+	dir, err = v.RecordingsDir("")
+	require.NoError(t, err)
+	require.Equal(t, recordingsDir(storageDir), dir)
+}
+
+func TestVolumesFailoverPinned(t *testing.T) {
+	tempDir := t.TempDir()
+	storageDir := filepath.Join(tempDir, "storage")
+	volume := filepath.Join(tempDir, "volume")
+	failoverDir := filepath.Join(tempDir, "failover")
+
+	v := &Volumes{
+		storageDirs:   []string{storageDir, volume},
+		strategy:      StrategySequential,
+		failoverDir:   failoverDir,
+		checkWritable: checkWritable,
+		freeSpace:     func(string) (uint64, error) { return volumeMinFree, nil },
+	}
+	pinned := func(monitorID string) string {
+		if monitorID == "m1" {
+			return volume
+		}
+		return ""
+	}
+
+	for _, id := range []string{"m1", "m2"} {
+		monitorDir := filepath.Join(recordingsDir(failoverDir), "2000/01/01", id)
+		require.NoError(t, os.MkdirAll(monitorDir, 0o755))
+		name := filepath.Join(monitorDir, "2000-01-01_01-01-01_"+id+".json")
+		require.NoError(t, os.WriteFile(name, nil, 0o600))
+	}
+
+	require.NoError(t, v.migrateFailover(context.Background(), pinned))
+	require.FileExists(t, filepath.Join(
+		recordingsDir(volume), "2000/01/01/m1/2000-01-01_01-01-01_m1.json"))
+	require.FileExists(t, filepath.Join(
+		recordingsDir(storageDir), "2000/01/01/m2/2000-01-01_01-01-01_m2.json"))
+	require.NoDirExists(t, filepath.Join(recordingsDir(failoverDir), "2000"))
+}
+
+func TestVolumesFailoverUnwritable(t *testing.T) {
+	v := newTestVolumes(StrategySequential, "", "")
+	v.failoverDir = "/failover"
+	v.checkWritable = func(string) error { return errors.New("mock") }
+	_, err := v.RecordingsDir("")
+	require.ErrorIs(t, err, ErrNoWritableVolume)
+}
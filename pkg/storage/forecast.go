@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"nvr/pkg/log"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ForecastDays is the number of complete days that the usage rates are averaged over.
+const ForecastDays = 7
+
+// Forecast projects when the storage will be full and how many days
+// of recordings it can hold from the recording rate of the last days.
+type Forecast struct {
+	Generated time.Time `json:"generated"`
+
+	// Number of complete days that the rates are averaged over. Days before
+	// the oldest recording aren't counted, the storage may have been pruned
+	// or the app installed recently. Zero if there are no recordings yet.
+	Days int `json:"days"`
+
+	// Usage rates by monitor ID.
+	Monitors map[string]ForecastRate `json:"monitors"`
+
+	// Usage rate of all monitors.
+	Total ForecastRate `json:"total"`
+
+	// Disk usage and max disk usage in bytes.
+	Used int64 `json:"used"`
+	Max  int64 `json:"max"`
+
+	// Whole days until the storage is full and pruning starts, zero if
+	// it's already full. -1 if the rate or max disk usage is unknown.
+	DaysUntilFull int `json:"daysUntilFull"`
+
+	// Whole days of recordings that the storage holds once it's
+	// full. -1 if the rate or max disk usage is unknown.
+	RetentionDays int `json:"retentionDays"`
+
+	// The minimum retention in days, zero if it isn't set.
+	MinRetentionDays int `json:"minRetentionDays"`
+}
+
+// ForecastRate average bytes per day and per week.
+type ForecastRate struct {
+	BytesPerDay  int64 `json:"bytesPerDay"`
+	BytesPerWeek int64 `json:"bytesPerWeek"`
+}
+
+func newForecastRate(bytesPerDay int64) ForecastRate {
+	return ForecastRate{BytesPerDay: bytesPerDay, BytesPerWeek: bytesPerDay * 7}
+}
+
+// usageRates returns the average bytes per day of each monitor and the number
+// of days they're averaged over. Only the last complete days are read,
+// today is still being recorded. Directories that can't be read are skipped.
+func usageRates(fileSystem fs.FS, now time.Time) (int, map[string]int64) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	days := 0
+	sizes := make(map[string]int64)
+	for age := 1; age <= ForecastDays; age++ {
+		dayPath := today.AddDate(0, 0, -age).Format("2006/01/02")
+		monitors, _ := fs.ReadDir(fileSystem, dayPath)
+		for _, monitor := range monitors {
+			if !monitor.IsDir() {
+				continue
+			}
+			size := dirSize(fileSystem, path.Join(dayPath, monitor.Name()))
+			if size == 0 {
+				continue
+			}
+			sizes[monitor.Name()] += size
+			days = age
+		}
+	}
+
+	rates := make(map[string]int64, len(sizes))
+	for id, size := range sizes {
+		rates[id] = size / int64(days)
+	}
+	return days, rates
+}
+
+// newForecast projects the disk usage at the rates.
+func newForecast(
+	now time.Time,
+	days int,
+	rates map[string]int64,
+	usage DiskUsage,
+	minRetentionDays int,
+) Forecast {
+	forecast := Forecast{
+		Generated:        now,
+		Days:             days,
+		Monitors:         make(map[string]ForecastRate, len(rates)),
+		Used:             usage.Used,
+		Max:              usage.Max,
+		DaysUntilFull:    -1,
+		RetentionDays:    -1,
+		MinRetentionDays: minRetentionDays,
+	}
+	var total int64
+	for id, rate := range rates {
+		forecast.Monitors[id] = newForecastRate(rate)
+		total += rate
+	}
+	forecast.Total = newForecastRate(total)
+
+	if total == 0 || usage.Max == 0 {
+		return forecast
+	}
+	// Storage is pruned at this usage.
+	limit := usage.Max * diskAlertFullPercent / 100
+	forecast.DaysUntilFull = int(max(0, limit-usage.Used) / total)
+	forecast.RetentionDays = int(limit / total)
+	return forecast
+}
+
+// retentionLow returns true if the projected retention is below the minimum.
+func (f Forecast) retentionLow() bool {
+	return f.MinRetentionDays != 0 && f.RetentionDays != -1 &&
+		f.RetentionDays < f.MinRetentionDays
+}
+
+// forecaster caches the usage rates, they're calculated at most once per day.
+type forecaster struct {
+	fs fs.FS
+
+	ratesDay  time.Time
+	ratesDays int
+	rates     map[string]int64
+	valid     bool
+
+	// The last retention check was below the minimum.
+	retentionLow bool
+
+	mu sync.Mutex
+}
+
+func (f *forecaster) usageRates(now time.Time) (int, map[string]int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	y1, m1, d1 := f.ratesDay.Date()
+	y2, m2, d2 := now.Date()
+	if f.valid && y1 == y2 && m1 == m2 && d1 == d2 {
+		return f.ratesDays, f.rates
+	}
+	f.ratesDays, f.rates = usageRates(f.fs, now)
+	f.ratesDay = now
+	f.valid = true
+	return f.ratesDays, f.rates
+}
+
+// checkRetention returns a alert if the forecast crossed the minimum retention
+// since the last check. Returns false if nothing changed.
+func (f *forecaster) checkRetention(forecast Forecast) (DiskAlert, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	low := forecast.retentionLow()
+	if low == f.retentionLow {
+		return DiskAlert{}, false
+	}
+	f.retentionLow = low
+
+	level := DiskAlertRetentionOK
+	if low {
+		level = DiskAlertRetentionLow
+	}
+	return DiskAlert{
+		Time:          forecast.Generated,
+		Level:         level,
+		RetentionDays: forecast.RetentionDays,
+	}, true
+}
+
+// Forecast returns the storage usage forecast of all volumes.
+func (s *Manager) Forecast() (Forecast, error) {
+	usage, err := s.DiskUsage(10 * time.Minute)
+	if err != nil {
+		return Forecast{}, fmt.Errorf("update disk usage: %w", err)
+	}
+	minRetentionDays, err := s.disk.general.MinRetentionDays()
+	if err != nil {
+		return Forecast{}, err
+	}
+	now := time.Now()
+	days, rates := s.forecaster.usageRates(now)
+	return newForecast(now, days, rates, usage, minRetentionDays), nil
+}
+
+// ForecastLoop checks the forecast on an interval until the context is canceled.
+// A disk alert is sent when the projected retention falls below the minimum
+// retention and when it's back above it.
+func (s *Manager) ForecastLoop(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			if err := s.checkForecast(); err != nil {
+				s.logger.Log(log.Entry{
+					Level: log.LevelError,
+					Src:   "app",
+					Msg:   fmt.Sprintf("could not forecast storage usage: %v", err),
+				})
+			}
+		}
+	}
+}
+
+func (s *Manager) checkForecast() error {
+	forecast, err := s.Forecast()
+	if err != nil {
+		return err
+	}
+	alert, changed := s.forecaster.checkRetention(forecast)
+	if !changed {
+		return nil
+	}
+
+	if alert.Level == DiskAlertRetentionLow {
+		s.logger.Log(log.Entry{
+			Level: log.LevelWarning,
+			Src:   "app",
+			Msg: fmt.Sprintf("storage: projected retention is %v days,"+
+				" below the minimum of %v days, recording %v per day",
+				forecast.RetentionDays, forecast.MinRetentionDays,
+				formatDiskUsage(float64(forecast.Total.BytesPerDay))),
+		})
+	} else {
+		s.logger.Log(log.Entry{
+			Level: log.LevelInfo,
+			Src:   "app",
+			Msg:   "storage: projected retention is back above the minimum",
+		})
+	}
+	if s.disk.alerts != nil {
+		s.disk.alerts.Push(alert)
+	}
+	return nil
+}
+
+// MinRetentionDays returns the configured minimum retention
+// in days, zero if it isn't set. See Manager.Forecast.
+func (general *ConfigGeneral) MinRetentionDays() (int, error) {
+	general.mu.Lock()
+	defer general.mu.Unlock()
+	return ParseMinRetentionDays(general.Config["minRetentionDays"])
+}
+
+// ParseMinRetentionDays parses the minimum retention setting.
+func ParseMinRetentionDays(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("minRetentionDays: %q: %w", v, ErrInvalidValue)
+	}
+	return days, nil
+}
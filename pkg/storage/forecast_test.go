@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageRates(t *testing.T) {
+	data := func(size int) *fstest.MapFile {
+		return &fstest.MapFile{Data: make([]byte, size)}
+	}
+	now := time.Date(2000, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("ok", func(t *testing.T) {
+		testFS := fstest.MapFS{
+			// Today isn't complete.
+			"2000/03/10/m1/2000-03-10_1_m1.mdat": data(1000),
+			"2000/03/09/m1/2000-03-09_1_m1.mdat": data(100),
+			"2000/03/09/m1/2000-03-09_1_m1.meta": data(10),
+			"2000/03/09/m2/2000-03-09_1_m2.mdat": data(30),
+			"2000/03/08/m1/2000-03-08_1_m1.mdat": data(110),
+			"2000/03/07/m1/2000-03-07_1_m1.mdat": data(110),
+			"2000/03/07/file":                    data(1000),
+			// Older than the forecast.
+			"2000/03/02/m1/2000-03-02_1_m1.mdat": data(1000),
+		}
+		days, rates := usageRates(testFS, now)
+		require.Equal(t, 3, days)
+		require.Equal(t, map[string]int64{"m1": 110, "m2": 10}, rates)
+	})
+	t.Run("window", func(t *testing.T) {
+		testFS := fstest.MapFS{
+			"2000/03/09/m1/2000-03-09_1_m1.mdat": data(70),
+			"2000/03/03/m1/2000-03-03_1_m1.mdat": data(70),
+			"2000/02/29/m1/2000-02-29_1_m1.mdat": data(1000),
+		}
+		days, rates := usageRates(testFS, now)
+		require.Equal(t, ForecastDays, days)
+		require.Equal(t, map[string]int64{"m1": 20}, rates)
+	})
+	t.Run("empty", func(t *testing.T) {
+		days, rates := usageRates(fstest.MapFS{}, now)
+		require.Equal(t, 0, days)
+		require.Empty(t, rates)
+	})
+}
+
+func TestNewForecast(t *testing.T) {
+	now := time.Unix(1, 0).UTC()
+	rates := map[string]int64{"m1": 300, "m2": 100}
+
+	t.Run("ok", func(t *testing.T) {
+		usage := DiskUsage{Used: 2000, Max: 10000}
+		expected := Forecast{
+			Generated: now,
+			Days:      7,
+			Monitors: map[string]ForecastRate{
+				"m1": {BytesPerDay: 300, BytesPerWeek: 2100},
+				"m2": {BytesPerDay: 100, BytesPerWeek: 700},
+			},
+			Total:            ForecastRate{BytesPerDay: 400, BytesPerWeek: 2800},
+			Used:             2000,
+			Max:              10000,
+			DaysUntilFull:    19,
+			RetentionDays:    24,
+			MinRetentionDays: 7,
+		}
+		require.Equal(t, expected, newForecast(now, 7, rates, usage, 7))
+	})
+	t.Run("full", func(t *testing.T) {
+		forecast := newForecast(now, 7, rates, DiskUsage{Used: 10000, Max: 10000}, 0)
+		require.Equal(t, 0, forecast.DaysUntilFull)
+		require.Equal(t, 24, forecast.RetentionDays)
+	})
+	t.Run("unknown", func(t *testing.T) {
+		forecast := newForecast(now, 0, nil, DiskUsage{Used: 10, Max: 10000}, 7)
+		require.Equal(t, -1, forecast.DaysUntilFull)
+		require.Equal(t, -1, forecast.RetentionDays)
+		require.False(t, forecast.retentionLow())
+
+		forecast = newForecast(now, 7, rates, DiskUsage{Used: 10}, 7)
+		require.Equal(t, -1, forecast.DaysUntilFull)
+		require.Equal(t, -1, forecast.RetentionDays)
+	})
+}
+
+func TestForecaster(t *testing.T) {
+	t.Run("checkRetention", func(t *testing.T) {
+		now := time.Unix(1, 0).UTC()
+		forecast := func(retentionDays int, minRetentionDays int) Forecast {
+			return Forecast{
+				Generated:        now,
+				RetentionDays:    retentionDays,
+				MinRetentionDays: minRetentionDays,
+			}
+		}
+		var f forecaster
+
+		_, changed := f.checkRetention(forecast(10, 7))
+		require.False(t, changed)
+
+		alert, changed := f.checkRetention(forecast(6, 7))
+		require.True(t, changed)
+		require.Equal(t, DiskAlert{Time: now, Level: DiskAlertRetentionLow, RetentionDays: 6}, alert)
+
+		// Only sent once.
+		_, changed = f.checkRetention(forecast(5, 7))
+		require.False(t, changed)
+
+		alert, changed = f.checkRetention(forecast(7, 7))
+		require.True(t, changed)
+		require.Equal(t, DiskAlert{Time: now, Level: DiskAlertRetentionOK, RetentionDays: 7}, alert)
+
+		// Disabled.
+		_, changed = f.checkRetention(forecast(1, 0))
+		require.False(t, changed)
+	})
+	t.Run("cache", func(t *testing.T) {
+		testFS := fstest.MapFS{
+			"2000/03/09/m1/2000-03-09_1_m1.mdat": {Data: make([]byte, 10)},
+		}
+		f := forecaster{fs: testFS}
+		now := time.Date(2000, 3, 10, 12, 0, 0, 0, time.UTC)
+
+		days, rates := f.usageRates(now)
+		require.Equal(t, 1, days)
+		require.Equal(t, map[string]int64{"m1": 10}, rates)
+
+		// Cached for the rest of the day.
+		testFS["2000/03/09/m2/2000-03-09_1_m2.mdat"] = &fstest.MapFile{Data: make([]byte, 5)}
+		_, rates = f.usageRates(now.Add(11 * time.Hour))
+		require.Equal(t, map[string]int64{"m1": 10}, rates)
+
+		days, rates = f.usageRates(now.Add(12 * time.Hour))
+		require.Equal(t, 2, days)
+		require.Equal(t, map[string]int64{"m1": 5, "m2": 2}, rates)
+	})
+}
+
+func TestParseMinRetentionDays(t *testing.T) {
+	days, err := ParseMinRetentionDays("")
+	require.NoError(t, err)
+	require.Equal(t, 0, days)
+
+	days, err = ParseMinRetentionDays("7")
+	require.NoError(t, err)
+	require.Equal(t, 7, days)
+
+	_, err = ParseMinRetentionDays("-1")
+	require.ErrorIs(t, err, ErrInvalidValue)
+
+	_, err = ParseMinRetentionDays("x")
+	require.ErrorIs(t, err, ErrInvalidValue)
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Activity heatmap intervals.
+const (
+	HeatmapHour   = "hour"
+	HeatmapMinute = "minute"
+)
+
+// Maximum number of buckets in a heatmap, one week per minute.
+const maxHeatmapBuckets = 7 * 24 * 60
+
+// Heatmap errors.
+var (
+	ErrInvalidHeatmapRange    = errors.New("invalid time range")
+	ErrInvalidHeatmapInterval = errors.New("invalid interval")
+)
+
+// ActivityHeatmap is the number of recordings and events of each monitor
+// per time bucket. Bucket i starts at Start plus i intervals.
+type ActivityHeatmap struct {
+	Start    time.Time `json:"start"`
+	Interval string    `json:"interval"`
+	Buckets  int       `json:"buckets"`
+
+	// Monitors without recordings in the range are omitted.
+	Monitors map[string]HeatmapCounts `json:"monitors"`
+}
+
+// HeatmapCounts are the counts of a monitor. A recording is counted
+// in every bucket it overlaps, a event in the bucket of its time.
+type HeatmapCounts struct {
+	Recordings []int `json:"recordings"`
+	Events     []int `json:"events"`
+}
+
+func heatmapInterval(interval string) (time.Duration, error) {
+	switch interval {
+	case HeatmapHour:
+		return time.Hour, nil
+	case HeatmapMinute:
+		return time.Minute, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidHeatmapInterval, interval)
+	}
+}
+
+// NewActivityHeatmap returns the heatmap of the finished recordings that
+// overlap the time range. The start is truncated to the interval. All
+// monitors are included if monitors is empty.
+func NewActivityHeatmap(
+	recordingsDirs []string,
+	monitors []string,
+	start time.Time,
+	end time.Time,
+	interval string,
+) (*ActivityHeatmap, error) {
+	bucketSize, err := heatmapInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	start = start.Truncate(bucketSize)
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: end must be after start", ErrInvalidHeatmapRange)
+	}
+	buckets := int((end.Sub(start) + bucketSize - 1) / bucketSize)
+	if buckets > maxHeatmapBuckets {
+		return nil, fmt.Errorf("%w: max %v %v buckets", ErrInvalidHeatmapRange, maxHeatmapBuckets, interval)
+	}
+	for _, id := range monitors {
+		if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMonitorID, id)
+		}
+	}
+
+	h := &ActivityHeatmap{
+		Start:    start,
+		Interval: interval,
+		Buckets:  buckets,
+		Monitors: make(map[string]HeatmapCounts),
+	}
+	bucket := func(t time.Time) int {
+		return int(t.Sub(start) / bucketSize)
+	}
+	add := func(monitorID string, data RecordingData) {
+		counts, exist := h.Monitors[monitorID]
+		if !exist {
+			counts = HeatmapCounts{
+				Recordings: make([]int, buckets),
+				Events:     make([]int, buckets),
+			}
+			h.Monitors[monitorID] = counts
+		}
+		first := max(bucket(data.Start), 0)
+		last := min(bucket(data.End.Add(-time.Nanosecond)), buckets-1)
+		for i := first; i <= last; i++ {
+			counts.Recordings[i]++
+		}
+		for _, e := range data.Events {
+			if !e.Time.Before(start) && e.Time.Before(end) {
+				counts.Events[bucket(e.Time)]++
+			}
+		}
+	}
+
+	// Recordings that start the day before may overlap the range.
+	firstDay := start.UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	for day := firstDay; !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, recordingsDir := range recordingsDirs {
+			dayDir := filepath.Join(recordingsDir, day.Format("2006/01/02"))
+			ids, err := heatmapMonitors(dayDir, monitors)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				err := readDirHeatmapData(filepath.Join(dayDir, id), start, end, func(data RecordingData) {
+					add(id, data)
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return h, nil
+}
+
+// heatmapMonitors returns the selected monitors, or all
+// monitors in the day directory if none are selected.
+func heatmapMonitors(dayDir string, monitors []string) ([]string, error) {
+	if len(monitors) != 0 {
+		return monitors, nil
+	}
+	entries, err := os.ReadDir(dayDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+// readDirHeatmapData calls add with the data of each
+// recording in the directory that overlaps the range.
+func readDirHeatmapData(
+	dir string,
+	start time.Time,
+	end time.Time,
+	add func(RecordingData),
+) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		// The data file is written when the recording is finished.
+		rawData, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var data RecordingData
+		if err := json.Unmarshal(rawData, &data); err != nil {
+			continue
+		}
+		if !data.End.After(start) || !data.Start.Before(end) {
+			continue
+		}
+		add(data)
+	}
+	return nil
+}
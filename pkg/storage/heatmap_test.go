@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeHeatmapRecording(
+	t *testing.T,
+	recordingsDir string,
+	monitorID string,
+	start time.Time,
+	end time.Time,
+	events []Event,
+) {
+	t.Helper()
+	dir := filepath.Join(recordingsDir, start.Format("2006/01/02"), monitorID)
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	data, err := json.Marshal(RecordingData{Start: start, End: end, Events: events})
+	require.NoError(t, err)
+	path := filepath.Join(dir, start.Format("2006-01-02_15-04-05_")+monitorID+".json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestActivityHeatmap(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	day := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	hour := func(h int, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+
+	// Starts the day before.
+	writeHeatmapRecording(t, dir1, "m1", hour(-1, 30), hour(0, 30), []Event{
+		{Time: hour(-1, 40)},
+		{Time: hour(0, 10)},
+	})
+	// Spans two hours.
+	writeHeatmapRecording(t, dir1, "m1", hour(1, 50), hour(2, 10), []Event{
+		{Time: hour(1, 55)},
+		{Time: hour(2, 5)},
+		{Time: hour(2, 6)},
+	})
+	// Ends at the start of a bucket.
+	writeHeatmapRecording(t, dir2, "m2", hour(1, 0), hour(2, 0), nil)
+	// Outside the range.
+	writeHeatmapRecording(t, dir2, "m3", hour(5, 0), hour(6, 0), nil)
+
+	dirs := []string{dir1, dir2}
+
+	t.Run("hour", func(t *testing.T) {
+		h, err := NewActivityHeatmap(dirs, nil, hour(0, 20), hour(3, 0), HeatmapHour)
+		require.NoError(t, err)
+		require.Equal(t, &ActivityHeatmap{
+			Start:    day,
+			Interval: HeatmapHour,
+			Buckets:  3,
+			Monitors: map[string]HeatmapCounts{
+				"m1": {
+					Recordings: []int{1, 1, 1},
+					Events:     []int{1, 1, 2},
+				},
+				"m2": {
+					Recordings: []int{0, 1, 0},
+					Events:     []int{0, 0, 0},
+				},
+			},
+		}, h)
+	})
+	t.Run("minute", func(t *testing.T) {
+		h, err := NewActivityHeatmap(dirs, []string{"m1"}, hour(2, 4), hour(2, 7), HeatmapMinute)
+		require.NoError(t, err)
+		require.Equal(t, 3, h.Buckets)
+		require.Equal(t, HeatmapCounts{
+			Recordings: []int{1, 1, 1},
+			Events:     []int{0, 1, 1},
+		}, h.Monitors["m1"])
+		require.Len(t, h.Monitors, 1)
+	})
+	t.Run("empty", func(t *testing.T) {
+		h, err := NewActivityHeatmap(dirs, nil, hour(10, 0), hour(11, 0), HeatmapHour)
+		require.NoError(t, err)
+		require.Empty(t, h.Monitors)
+	})
+	t.Run("invalidInterval", func(t *testing.T) {
+		_, err := NewActivityHeatmap(dirs, nil, hour(0, 0), hour(1, 0), "day")
+		require.ErrorIs(t, err, ErrInvalidHeatmapInterval)
+	})
+	t.Run("invalidRange", func(t *testing.T) {
+		_, err := NewActivityHeatmap(dirs, nil, hour(1, 0), hour(0, 0), HeatmapHour)
+		require.ErrorIs(t, err, ErrInvalidHeatmapRange)
+
+		_, err = NewActivityHeatmap(dirs, nil, hour(0, 0), hour(24*8, 0), HeatmapMinute)
+		require.ErrorIs(t, err, ErrInvalidHeatmapRange)
+	})
+	t.Run("invalidMonitor", func(t *testing.T) {
+		_, err := NewActivityHeatmap(dirs, []string{".."}, hour(0, 0), hour(1, 0), HeatmapHour)
+		require.ErrorIs(t, err, ErrInvalidMonitorID)
+	})
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrRecordingLocked recording is locked.
+var ErrRecordingLocked = errors.New("recording is in use")
+
+// recordingLocks keeps recordings that are being read from being deleted.
+// Exports, downloads and verification lock the recordings while the purge
+// loop, scrubber and delete API skip locked recordings. The locks are
+// shared by the whole process, like the files they protect.
+var recordingLocks = newLocks()
+
+type locks struct {
+	mu     sync.Mutex
+	counts map[string]int
+
+	// Recordings that are being deleted, they can't be locked until
+	// the delete is done. Broadcast on deleteDone when it's done.
+	deleting   map[string]int
+	deleteDone *sync.Cond
+}
+
+func newLocks() *locks {
+	l := &locks{
+		counts:   make(map[string]int),
+		deleting: make(map[string]int),
+	}
+	l.deleteDone = sync.NewCond(&l.mu)
+	return l
+}
+
+// lock increments the lock count of every ID and returns a function
+// that decrements them, the function is idempotent. Waits for
+// deletes of the IDs to finish, the files may not exist afterwards.
+func (l *locks) lock(ids []string) func() {
+	ids = append([]string(nil), ids...)
+	l.mu.Lock()
+	for l.anyDeleting(ids) {
+		l.deleteDone.Wait()
+	}
+	for _, id := range ids {
+		l.counts[id]++
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for _, id := range ids {
+				l.counts[id]--
+				if l.counts[id] <= 0 {
+					delete(l.counts, id)
+				}
+			}
+		})
+	}
+}
+
+func (l *locks) anyDeleting(ids []string) bool {
+	for _, id := range ids {
+		if l.deleting[id] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *locks) locked(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[id] > 0
+}
+
+// tryDelete calls fn if none of the IDs are locked, the IDs can't be
+// locked until fn returns. Returns ErrRecordingLocked if any are locked.
+func (l *locks) tryDelete(ids []string, fn func() error) error {
+	l.mu.Lock()
+	for _, id := range ids {
+		if l.counts[id] > 0 {
+			l.mu.Unlock()
+			return fmt.Errorf("%w: %v", ErrRecordingLocked, id)
+		}
+	}
+	for _, id := range ids {
+		l.deleting[id]++
+	}
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		for _, id := range ids {
+			l.deleting[id]--
+			if l.deleting[id] <= 0 {
+				delete(l.deleting, id)
+			}
+		}
+		l.mu.Unlock()
+		l.deleteDone.Broadcast()
+	}()
+	return fn()
+}
+
+// LockRecordings prevents the recordings from being deleted until the
+// returned function is called. Locks are reference counted, a recording
+// can be locked by multiple readers at the same time.
+func LockRecordings(ids ...string) (unlock func()) {
+	return recordingLocks.lock(ids)
+}
+
+// RecordingLocked returns true if the recording is locked.
+func RecordingLocked(recID string) bool {
+	return recordingLocks.locked(recID)
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocks(t *testing.T) {
+	t.Run("refCount", func(t *testing.T) {
+		l := newLocks()
+		unlock1 := l.lock([]string{"a", "b"})
+		unlock2 := l.lock([]string{"a"})
+		require.True(t, l.locked("a"))
+		require.True(t, l.locked("b"))
+		require.False(t, l.locked("c"))
+
+		unlock1()
+		require.True(t, l.locked("a"))
+		require.False(t, l.locked("b"))
+
+		unlock2()
+		require.False(t, l.locked("a"))
+		require.Empty(t, l.counts)
+	})
+	t.Run("idempotent", func(t *testing.T) {
+		l := newLocks()
+		unlock1 := l.lock([]string{"a"})
+		unlock2 := l.lock([]string{"a"})
+		unlock1()
+		unlock1()
+		require.True(t, l.locked("a"))
+		unlock2()
+		require.False(t, l.locked("a"))
+	})
+	t.Run("copyIDs", func(t *testing.T) {
+		l := newLocks()
+		ids := []string{"a"}
+		unlock := l.lock(ids)
+		ids[0] = "b"
+		unlock()
+		require.False(t, l.locked("a"))
+		require.Empty(t, l.counts)
+	})
+	t.Run("tryDelete", func(t *testing.T) {
+		l := newLocks()
+		unlock := l.lock([]string{"a"})
+		called := false
+		err := l.tryDelete([]string{"b", "a"}, func() error {
+			called = true
+			return nil
+		})
+		require.ErrorIs(t, err, ErrRecordingLocked)
+		require.False(t, called)
+
+		unlock()
+		require.NoError(t, l.tryDelete([]string{"b", "a"}, func() error {
+			called = true
+			return nil
+		}))
+		require.True(t, called)
+		require.Empty(t, l.deleting)
+	})
+	t.Run("lockDuringDelete", func(t *testing.T) {
+		l := newLocks()
+		locked := make(chan struct{})
+		err := l.tryDelete([]string{"a"}, func() error {
+			go func() {
+				l.lock([]string{"a"})
+				close(locked)
+			}()
+			// Not locked until the delete is done.
+			select {
+			case <-locked:
+				t.Fatal("locked during delete")
+			case <-time.After(10 * time.Millisecond):
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		<-locked
+		require.True(t, l.locked("a"))
+		require.ErrorIs(t, l.tryDelete([]string{"a"}, nil), ErrRecordingLocked)
+	})
+}
+
+// deleteHookBackend calls onDelete before each delete.
+type deleteHookBackend struct {
+	Backend
+	onDelete func()
+}
+
+func (b *deleteHookBackend) Delete(name string) error {
+	b.onDelete()
+	return b.Backend.Delete(name)
+}
+
+func TestPruneConcurrentLock(t *testing.T) {
+	recordingsDir := t.TempDir()
+	dir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	recA := "2000-01-01_01-01-01_m1"
+	recB := "2000-01-01_02-02-02_m1"
+	writeTestRecording(t, dir, recA, TriggerContinuous)
+	writeTestRecording(t, dir, recB, TriggerContinuous)
+
+	var unlockB func()
+	filesOfA := -1
+	lockedA := make(chan struct{})
+	backend := &deleteHookBackend{
+		Backend: NewFileBackend(recordingsDir),
+		onDelete: func() {
+			if unlockB != nil {
+				return
+			}
+			// B is locked after the recordings were listed.
+			unlockB = LockRecordings(recB)
+
+			// A is locked while it's being deleted.
+			go func() {
+				defer LockRecordings(recA)()
+				entries, _ := os.ReadDir(dir)
+				filesOfA = 0
+				for _, entry := range entries {
+					if strings.HasPrefix(entry.Name(), recA) {
+						filesOfA++
+					}
+				}
+				close(lockedA)
+			}()
+		},
+	}
+
+	removed, err := removeContinuous(backend, "2000/01/01")
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	unlockB()
+
+	// Locking A waited for the delete to finish.
+	<-lockedA
+	require.Zero(t, filesOfA)
+	require.Equal(t,
+		[]string{recB + ".jpeg", recB + ".json", recB + ".mp4"},
+		listDirectory(t, dir),
+	)
+}
+
+func TestPruneLocked(t *testing.T) {
+	recordingsDir := filepath.Join(t.TempDir(), "recordings")
+	m := &Manager{
+		storageDir: filepath.Dir(recordingsDir),
+		disk: &disk{
+			general: &ConfigGeneral{
+				Config: map[string]string{"diskSpace": "1"},
+			},
+			diskUsageBytes: highUsage,
+		},
+		removeAll: os.RemoveAll,
+		logger:    log.NewDummyLogger(),
+	}
+
+	day1 := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	day2 := filepath.Join(recordingsDir, "2000", "01", "02", "m1")
+	require.NoError(t, os.MkdirAll(day1, 0o700))
+	require.NoError(t, os.MkdirAll(day2, 0o700))
+
+	writeTestRecording(t, day1, "2000-01-01_01-01-01_m1", TriggerContinuous)
+	writeTestRecording(t, day1, "2000-01-01_02-02-02_m1", TriggerMotion)
+	writeTestRecording(t, day2, "2000-01-02_01-01-01_m1", TriggerMotion)
+
+	unlock := LockRecordings("2000-01-01_01-01-01_m1", "2000-01-01_02-02-02_m1")
+	defer unlock()
+
+	// The locked day is skipped.
+	require.NoError(t, m.prune())
+	require.Len(t, listDirectory(t, day1), 6)
+	require.NoDirExists(t, day2)
+
+	unlock()
+	require.NoError(t, m.prune())
+	require.Equal(t,
+		[]string{
+			"2000-01-01_02-02-02_m1.jpeg",
+			"2000-01-01_02-02-02_m1.json",
+			"2000-01-01_02-02-02_m1.mp4",
+		},
+		listDirectory(t, day1),
+	)
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordingDataVersion is the schema version of the recording data
+// written by the recorder. Older versions are upgraded when read.
+//
+//	0: start, end, events, activity and maintenance.
+//	1: version, triggers, labels, zones, duration, width and height.
+const RecordingDataVersion = 1
+
+// Event triggers.
+const (
+	TriggerContinuous = "continuous"
+	TriggerMotion     = "motion"
+	TriggerObject     = "object"
+
+	// Promoted log entry, these events are not recorded.
+	TriggerLog = "log"
+)
+
+// Summarize sets the version and the event summary of the data.
+// The duration is the difference between start and end if unset.
+func (d *RecordingData) Summarize() {
+	triggers := make(map[string]struct{})
+	labels := make(map[string]struct{})
+	zones := make(map[string]struct{})
+	for _, e := range d.Events {
+		if e.Trigger != "" {
+			triggers[e.Trigger] = struct{}{}
+		}
+		for _, detection := range e.Detections {
+			if detection.Label != "" {
+				labels[detection.Label] = struct{}{}
+			}
+			if detection.Zone != "" {
+				zones[detection.Zone] = struct{}{}
+			}
+		}
+	}
+	d.Triggers = sortedKeys(triggers)
+	d.Labels = sortedKeys(labels)
+	d.Zones = sortedKeys(zones)
+
+	if d.Duration == 0 && d.End.After(d.Start) {
+		d.Duration = d.End.Sub(d.Start)
+	}
+	d.Version = RecordingDataVersion
+}
+
+// Temporary data file, "YYYY-MM-DD_hh-mm-ss_monitor.json.tmp".
+const tmpExt = ".tmp"
+
+// WriteRecordingData writes the data file of the recording. The file is
+// written to a temporary file that is synced and renamed, a recording is
+// considered finished when the data file exists, so it must be complete.
+func WriteRecordingData(recordingPath string, data RecordingData) error {
+	raw, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	dataPath := recordingPath + ".json"
+	tmpPath := dataPath + tmpExt
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(raw); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dataPath)
+}
+
+// upgrade summarizes data written before the current version.
+func (d *RecordingData) upgrade() {
+	if d.Version < RecordingDataVersion {
+		d.Summarize()
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RecordingFilter filters recordings by their data. A recording matches
+// if it contains any of the triggers, labels and zones. Empty fields
+// match everything.
+type RecordingFilter struct {
+	Triggers    []string
+	Labels      []string
+	Zones       []string
+	MinDuration time.Duration
+}
+
+// IsZero returns true if the filter matches everything.
+func (f RecordingFilter) IsZero() bool {
+	return len(f.Triggers) == 0 &&
+		len(f.Labels) == 0 &&
+		len(f.Zones) == 0 &&
+		f.MinDuration == 0
+}
+
+// Match returns true if the data matches the filter.
+// Recordings without data only match a empty filter.
+func (f RecordingFilter) Match(d *RecordingData) bool {
+	if f.IsZero() {
+		return true
+	}
+	if d == nil {
+		return false
+	}
+	return containsAny(d.Triggers, f.Triggers) &&
+		containsAny(d.Labels, f.Labels) &&
+		containsAny(d.Zones, f.Zones) &&
+		d.Duration >= f.MinDuration
+}
+
+func containsAny(values []string, want []string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, w := range want {
+		for _, v := range values {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseRecordingFilter parses comma separated triggers, labels
+// and zones, and the minimum duration in seconds.
+func ParseRecordingFilter(triggers, labels, zones, minDuration string) (RecordingFilter, error) {
+	split := func(csv string) []string {
+		var values []string
+		for _, v := range strings.Split(csv, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+
+	filter := RecordingFilter{
+		Triggers: split(triggers),
+		Labels:   split(labels),
+		Zones:    split(zones),
+	}
+	if minDuration != "" {
+		seconds, err := strconv.Atoi(minDuration)
+		if err != nil || seconds < 0 {
+			return RecordingFilter{}, fmt.Errorf("minDuration: %w: %q", ErrInvalidValue, minDuration)
+		}
+		filter.MinDuration = time.Duration(seconds) * time.Second
+	}
+	return filter, nil
+}
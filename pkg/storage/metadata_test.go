@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := RecordingData{
+		Start: start,
+		End:   start.Add(time.Minute),
+		Events: []Event{
+			{Trigger: TriggerObject, Detections: []Detection{{Label: "person"}, {Label: "car"}}},
+			{Trigger: TriggerMotion, Detections: []Detection{{Zone: "1"}, {Zone: "0"}}},
+			{Trigger: TriggerObject, Detections: []Detection{{Label: "person"}}},
+		},
+	}
+	data.Summarize()
+
+	require.Equal(t, RecordingDataVersion, data.Version)
+	require.Equal(t, []string{TriggerMotion, TriggerObject}, data.Triggers)
+	require.Equal(t, []string{"car", "person"}, data.Labels)
+	require.Equal(t, []string{"0", "1"}, data.Zones)
+	require.Equal(t, time.Minute, data.Duration)
+}
+
+func TestRecordingFilter(t *testing.T) {
+	data := &RecordingData{
+		Triggers: []string{TriggerObject},
+		Labels:   []string{"car", "person"},
+		Duration: time.Minute,
+	}
+	cases := map[string]struct {
+		filter   RecordingFilter
+		expected bool
+	}{
+		"empty":       {RecordingFilter{}, true},
+		"label":       {RecordingFilter{Labels: []string{"dog", "person"}}, true},
+		"labelMiss":   {RecordingFilter{Labels: []string{"dog"}}, false},
+		"trigger":     {RecordingFilter{Triggers: []string{TriggerObject}}, true},
+		"zoneMiss":    {RecordingFilter{Zones: []string{"0"}}, false},
+		"minDuration": {RecordingFilter{MinDuration: 2 * time.Minute}, false},
+		"all": {RecordingFilter{
+			Triggers:    []string{TriggerObject},
+			Labels:      []string{"car"},
+			MinDuration: time.Minute,
+		}, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.filter.Match(data))
+		})
+	}
+	require.False(t, RecordingFilter{Labels: []string{"a"}}.Match(nil))
+}
+
+func TestParseRecordingFilter(t *testing.T) {
+	filter, err := ParseRecordingFilter("object", "person, car,", "", "30")
+	require.NoError(t, err)
+	expected := RecordingFilter{
+		Triggers:    []string{"object"},
+		Labels:      []string{"person", "car"},
+		MinDuration: 30 * time.Second,
+	}
+	require.Equal(t, expected, filter)
+
+	_, err = ParseRecordingFilter("", "", "", "-1")
+	require.ErrorIs(t, err, ErrInvalidValue)
+}
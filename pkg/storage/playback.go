@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"nvr/pkg/crypt"
+	"os"
+	"path/filepath"
+)
+
+// Playback source formats.
+const (
+	PlaybackMeta = "meta" // .meta and .mdat files, see VideoReader.
+	PlaybackMP4  = "mp4"
+	PlaybackH264 = "h264" // Raw H264 Annex B stream.
+)
+
+// PlaybackSource file used to play a recording.
+type PlaybackSource struct {
+	Path   string
+	Format string
+
+	// Remux is true if browsers can't play the file directly,
+	// for example MP4 files without the "moov" box before the
+	// "mdat" box and raw H264 streams.
+	Remux bool
+
+	// Encrypted at rest, FFmpeg can't read the file. See Open.
+	Encrypted bool
+}
+
+// PlaybackVideo is the video of a playback source.
+type PlaybackVideo interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+// Open opens the video of the source. The meta format is read
+// as a MP4 file and encrypted files are decrypted.
+func (s PlaybackSource) Open(cache *VideoCache) (PlaybackVideo, error) {
+	if s.Format == PlaybackMeta {
+		video, err := NewVideoReader(s.Path, cache)
+		if err != nil {
+			return nil, err
+		}
+		return video, nil
+	}
+	return crypt.Open(s.Path)
+}
+
+// RecordingID returns the ID of the recording.
+func (s PlaybackSource) RecordingID() string {
+	name := filepath.Base(s.Path)
+	if s.Format == PlaybackMeta {
+		return name
+	}
+	return recordingIDFromFile(name)
+}
+
+// FindPlaybackSource returns the playback source of the recording
+// at path. Returns os.ErrNotExist if the recording has no video.
+func FindPlaybackSource(path string) (PlaybackSource, error) {
+	if fileExist(path + ".meta") {
+		return PlaybackSource{Path: path, Format: PlaybackMeta}, nil
+	}
+
+	if mp4Path := path + ".mp4"; fileExist(mp4Path) {
+		file, err := crypt.Open(mp4Path)
+		if err != nil {
+			return PlaybackSource{}, err
+		}
+		defer file.Close()
+
+		fastStart, err := isFastStart(file)
+		if err != nil {
+			return PlaybackSource{}, fmt.Errorf("read mp4 boxes: %w", err)
+		}
+		return PlaybackSource{
+			Path:      mp4Path,
+			Format:    PlaybackMP4,
+			Remux:     !fastStart,
+			Encrypted: file.Encrypted(),
+		}, nil
+	}
+
+	if h264Path := path + ".h264"; fileExist(h264Path) {
+		return PlaybackSource{Path: h264Path, Format: PlaybackH264, Remux: true}, nil
+	}
+	return PlaybackSource{}, os.ErrNotExist
+}
+
+func fileExist(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// ErrInvalidBoxSize invalid box size.
+var ErrInvalidBoxSize = errors.New("invalid box size")
+
+// isFastStart reads the top level boxes and returns
+// true if the "moov" box is before the "mdat" box.
+func isFastStart(r io.ReadSeeker) (bool, error) {
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return false, err
+		}
+		size := uint64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := uint64(8)
+
+		switch string(header[4:8]) {
+		case "moov":
+			return true, nil
+		case "mdat":
+			return false, nil
+		}
+
+		switch size {
+		case 0: // Box extends to end of file.
+			return false, nil
+		case 1: // 64 bit size.
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return false, err
+			}
+			size = binary.BigEndian.Uint64(header[8:16])
+			headerSize = 16
+		}
+		if size < headerSize {
+			return false, ErrInvalidBoxSize
+		}
+
+		if _, err := r.Seek(int64(size-headerSize), io.SeekCurrent); err != nil {
+			return false, err
+		}
+	}
+}
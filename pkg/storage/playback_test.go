@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testBox(typ string, payloadSize int) []byte {
+	size := 8 + payloadSize
+	box := []byte{
+		byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size),
+	}
+	box = append(box, typ...)
+	return append(box, make([]byte, payloadSize)...)
+}
+
+func TestIsFastStart(t *testing.T) {
+	largeFree := []byte{0, 0, 0, 1, 'f', 'r', 'e', 'e', 0, 0, 0, 0, 0, 0, 0, 20}
+	largeFree = append(largeFree, make([]byte, 4)...)
+
+	cases := map[string]struct {
+		input       [][]byte
+		expected    bool
+		expectedErr error
+	}{
+		"fastStart": {
+			[][]byte{testBox("ftyp", 4), testBox("moov", 10), testBox("mdat", 10)},
+			true, nil,
+		},
+		"moovAtEnd": {
+			[][]byte{testBox("ftyp", 4), testBox("mdat", 10), testBox("moov", 10)},
+			false, nil,
+		},
+		"largeSize": {
+			[][]byte{testBox("ftyp", 4), largeFree, testBox("moov", 10)},
+			true, nil,
+		},
+		"invalidSize": {
+			[][]byte{{0, 0, 0, 4, 'f', 'r', 'e', 'e'}},
+			false, ErrInvalidBoxSize,
+		},
+		"noBoxes": {
+			[][]byte{testBox("ftyp", 4)},
+			false, io.EOF,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := isFastStart(bytes.NewReader(bytes.Join(tc.input, nil)))
+			require.ErrorIs(t, err, tc.expectedErr)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFindPlaybackSource(t *testing.T) {
+	write := func(t *testing.T, path string, data []byte) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+
+	t.Run("meta", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rec")
+		write(t, path+".meta", nil)
+		write(t, path+".mp4", nil)
+
+		source, err := FindPlaybackSource(path)
+		require.NoError(t, err)
+		require.Equal(t, PlaybackSource{Path: path, Format: PlaybackMeta}, source)
+		require.Equal(t, "rec", source.RecordingID())
+	})
+	t.Run("mp4", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rec")
+		write(t, path+".mp4", bytes.Join([][]byte{testBox("moov", 0), testBox("mdat", 0)}, nil))
+
+		source, err := FindPlaybackSource(path)
+		require.NoError(t, err)
+		require.Equal(t, PlaybackSource{Path: path + ".mp4", Format: PlaybackMP4}, source)
+		require.Equal(t, "rec", source.RecordingID())
+	})
+	t.Run("mp4Remux", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rec")
+		write(t, path+".mp4", bytes.Join([][]byte{testBox("mdat", 0), testBox("moov", 0)}, nil))
+
+		source, err := FindPlaybackSource(path)
+		require.NoError(t, err)
+		require.Equal(t, PlaybackSource{Path: path + ".mp4", Format: PlaybackMP4, Remux: true}, source)
+	})
+	t.Run("h264", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rec")
+		write(t, path+".h264", nil)
+
+		source, err := FindPlaybackSource(path)
+		require.NoError(t, err)
+		require.Equal(t, PlaybackSource{Path: path + ".h264", Format: PlaybackH264, Remux: true}, source)
+	})
+	t.Run("notExist", func(t *testing.T) {
+		_, err := FindPlaybackSource(filepath.Join(t.TempDir(), "rec"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
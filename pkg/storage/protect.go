@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Protected recordings have an empty marker file next to the video,
+// "YYYY-MM-DD_hh-mm-ss_monitor.protected". They are excluded from
+// pruning and cannot be deleted until they're unprotected.
+const protectedExt = ".protected"
+
+// ErrRecordingProtected recording is protected.
+var ErrRecordingProtected = errors.New("recording is protected")
+
+// SetRecordingProtected protects or unprotects a recording.
+// Returns os.ErrNotExist if the recording doesn't exist.
+func SetRecordingProtected(recordingsDir string, recID string, protect bool) error {
+	recPath, err := RecordingIDToPath(recID)
+	if err != nil {
+		return err
+	}
+	fullRecPath := filepath.Join(recordingsDir, recPath)
+
+	if _, err := os.Stat(fullRecPath + ".json"); err != nil {
+		return err
+	}
+
+	markerPath := fullRecPath + protectedExt
+	if !protect {
+		err := os.Remove(markerPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(markerPath, nil, 0o600)
+}
+
+// RecordingProtected returns true if the recording is protected.
+func RecordingProtected(recordingsDir string, recID string) (bool, error) {
+	recPath, err := RecordingIDToPath(recID)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(filepath.Join(recordingsDir, recPath) + protectedExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// recordingIDFromFile returns the recording ID of a recording file.
+func recordingIDFromFile(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// protectedIDs returns the IDs of the protected recordings in the entries.
+// Locked recordings are also protected until they're unlocked.
+func protectedIDs(entries []fs.DirEntry) map[string]struct{} {
+	ids := make(map[string]struct{})
+	for _, entry := range entries {
+		id := recordingIDFromFile(entry.Name())
+		if strings.HasSuffix(entry.Name(), protectedExt) || RecordingLocked(id) {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// dayProtection counts the protected and unprotected files in the day directory.
+func dayProtection(backend Backend, day string) (protected int, unprotected int, err error) {
+	monitorDirs, err := backend.List(day)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read day directory: %w", err)
+	}
+	for _, monitorDir := range monitorDirs {
+		if !monitorDir.IsDir() {
+			unprotected++
+			continue
+		}
+		entries, err := backend.List(path.Join(day, monitorDir.Name()))
+		if err != nil {
+			return 0, 0, fmt.Errorf("read monitor directory: %w", err)
+		}
+		ids := protectedIDs(entries)
+		for _, entry := range entries {
+			if _, exist := ids[recordingIDFromFile(entry.Name())]; exist {
+				protected++
+			} else {
+				unprotected++
+			}
+		}
+	}
+	return protected, unprotected, nil
+}
+
+// dayRecordingIDs returns the IDs of the recordings in the day directory.
+func dayRecordingIDs(backend Backend, day string) ([]string, error) {
+	monitorDirs, err := backend.List(day)
+	if err != nil {
+		return nil, fmt.Errorf("read day directory: %w", err)
+	}
+	var ids []string
+	for _, monitorDir := range monitorDirs {
+		if !monitorDir.IsDir() {
+			continue
+		}
+		entries, err := backend.List(path.Join(day, monitorDir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read monitor directory: %w", err)
+		}
+		ids = append(ids, entryRecordingIDs(entries)...)
+	}
+	return ids, nil
+}
+
+func entryRecordingIDs(entries []fs.DirEntry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, recordingIDFromFile(entry.Name()))
+	}
+	return ids
+}
+
+// removeDay removes all recordings from the day except the protected ones.
+// A recording that is locked while the day is being removed is protected
+// on the next prune.
+func removeDay(backend Backend, day string) error {
+	protected, _, err := dayProtection(backend, day)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if protected == 0 {
+		ids, err := dayRecordingIDs(backend, day)
+		if err != nil {
+			return err
+		}
+		err = recordingLocks.tryDelete(ids, func() error {
+			return backend.Delete(day)
+		})
+		if errors.Is(err, ErrRecordingLocked) {
+			return nil
+		}
+		return err
+	}
+
+	monitorDirs, err := backend.List(day)
+	if err != nil {
+		return fmt.Errorf("read day directory: %w", err)
+	}
+	var errs []error
+	for _, monitorDir := range monitorDirs {
+		monitorPath := path.Join(day, monitorDir.Name())
+		entries, err := backend.List(monitorPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read monitor directory: %w", err))
+			continue
+		}
+		protected := protectedIDs(entries)
+		if len(protected) == 0 {
+			err := recordingLocks.tryDelete(entryRecordingIDs(entries), func() error {
+				return backend.Delete(monitorPath)
+			})
+			if err != nil && !errors.Is(err, ErrRecordingLocked) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		files := make(map[string][]string)
+		for _, entry := range entries {
+			id := recordingIDFromFile(entry.Name())
+			if _, exist := protected[id]; !exist {
+				files[id] = append(files[id], entry.Name())
+			}
+		}
+		if _, err := deleteRecordings(backend, monitorPath, files); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
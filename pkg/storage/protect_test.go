@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRecordingProtected(t *testing.T) {
+	recordingsDir := t.TempDir()
+	recDir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	recID := "2000-01-01_02-02-02_m1"
+	require.NoError(t, os.MkdirAll(recDir, 0o700))
+	createFiles(t, recDir, []string{recID + ".json", recID + ".mp4"})
+
+	err := SetRecordingProtected(recordingsDir, recID, true)
+	require.NoError(t, err)
+	protected, err := RecordingProtected(recordingsDir, recID)
+	require.NoError(t, err)
+	require.True(t, protected)
+
+	err = DeleteRecording(recordingsDir, recID)
+	require.ErrorIs(t, err, ErrRecordingProtected)
+	require.Len(t, listDirectory(t, recDir), 3)
+
+	err = SetRecordingProtected(recordingsDir, recID, false)
+	require.NoError(t, err)
+	protected, err = RecordingProtected(recordingsDir, recID)
+	require.NoError(t, err)
+	require.False(t, protected)
+
+	// Unprotecting twice is not an error.
+	require.NoError(t, SetRecordingProtected(recordingsDir, recID, false))
+
+	require.NoError(t, DeleteRecording(recordingsDir, recID))
+	require.Empty(t, listDirectory(t, recDir))
+
+	t.Run("notExist", func(t *testing.T) {
+		err := SetRecordingProtected(recordingsDir, recID, true)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("invalidID", func(t *testing.T) {
+		err := SetRecordingProtected(recordingsDir, "x", true)
+		require.ErrorIs(t, err, ErrInvalidRecordingID)
+	})
+}
+
+func TestPurgeProtected(t *testing.T) {
+	tempDir := t.TempDir()
+	recordingsDir := filepath.Join(tempDir, "recordings")
+
+	m := &Manager{
+		storageDir: tempDir,
+		disk: &disk{
+			general: &ConfigGeneral{
+				Config: map[string]string{"diskSpace": "1"},
+			},
+			diskUsageBytes: highUsage,
+		},
+		removeAll: os.RemoveAll,
+		logger:    log.NewDummyLogger(),
+	}
+
+	day1 := filepath.Join(recordingsDir, "2000", "01", "01")
+	day2 := filepath.Join(recordingsDir, "2000", "01", "02")
+	for _, dir := range []string{
+		filepath.Join(day1, "m1"),
+		filepath.Join(day1, "m2"),
+		filepath.Join(day2, "m1"),
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0o700))
+	}
+	createFiles(t, filepath.Join(day1, "m1"), []string{
+		"2000-01-01_01-01-01_m1.json",
+		"2000-01-01_01-01-01_m1.mp4",
+		"2000-01-01_01-01-01_m1.protected",
+		"2000-01-01_02-02-02_m1.json",
+		"2000-01-01_02-02-02_m1.mp4",
+	})
+	createFiles(t, filepath.Join(day1, "m2"), []string{
+		"2000-01-01_01-01-01_m2.json",
+	})
+	createFiles(t, filepath.Join(day2, "m1"), []string{
+		"2000-01-02_01-01-01_m1.json",
+	})
+
+	// Unprotected recordings are deleted from the oldest day.
+	require.NoError(t, m.prune())
+	require.Equal(t, []string{"m1"}, listDirectory(t, day1))
+	require.Equal(t,
+		[]string{
+			"2000-01-01_01-01-01_m1.json",
+			"2000-01-01_01-01-01_m1.mp4",
+			"2000-01-01_01-01-01_m1.protected",
+		},
+		listDirectory(t, filepath.Join(day1, "m1")),
+	)
+
+	// Days with only protected recordings are skipped.
+	require.NoError(t, m.prune())
+	require.NoDirExists(t, day2)
+	require.Len(t, listDirectory(t, filepath.Join(day1, "m1")), 3)
+
+	// Nothing left to prune.
+	require.NoError(t, m.prune())
+	require.Len(t, listDirectory(t, filepath.Join(day1, "m1")), 3)
+}
+
+func TestCrawlerProtected(t *testing.T) {
+	recordingsDir := t.TempDir()
+	recDir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	require.NoError(t, os.MkdirAll(recDir, 0o700))
+	createFiles(t, recDir, []string{
+		"2000-01-01_01-01-01_m1.json",
+		"2000-01-01_01-01-01_m1.protected",
+		"2000-01-01_02-02-02_m1.json",
+	})
+
+	c := NewCrawler(os.DirFS(recordingsDir))
+	recordings, err := c.RecordingByQuery(&CrawlerQuery{
+		Time:  "2001-01-01_00-00-00",
+		Limit: 3,
+	})
+	require.NoError(t, err)
+
+	expected := []Recording{
+		{ID: "2000-01-01_02-02-02_m1"},
+		{ID: "2000-01-01_01-01-01_m1", Protected: true},
+	}
+	require.Equal(t, expected, recordings)
+}
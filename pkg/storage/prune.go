@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"nvr/pkg/log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// pruneContinuous deletes the continuous recordings from the oldest day that
+// has any on every volume. Returns false if there was nothing to delete.
+func (s *Manager) pruneContinuous(backends []Backend) (bool, error) {
+	var errs []error
+	oldest := ""
+	for _, backend := range backends {
+		day, err := oldestContinuousDay(backend)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if day != "" && (oldest == "" || day < oldest) {
+			oldest = day
+		}
+	}
+	if oldest == "" {
+		return false, errors.Join(errs...)
+	}
+
+	removed := 0
+	for _, backend := range backends {
+		n, err := removeContinuous(backend, oldest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("remove continuous recordings: %w", err))
+		}
+		removed += n
+	}
+
+	s.logger.Log(log.Entry{
+		Level: log.LevelInfo,
+		Src:   "app",
+		Msg: fmt.Sprintf("pruning storage: deleted %v continuous recordings from %q",
+			removed, oldest),
+	})
+	return true, errors.Join(errs...)
+}
+
+// isContinuous returns true if the recording only contains continuous events
+// or no events at all, it was recorded because of "Always record" and nothing
+// happened during it. Events without a trigger are from before triggers were
+// recorded and are treated as motion or object events.
+func isContinuous(data RecordingData) bool {
+	for _, e := range data.Events {
+		if e.Trigger != TriggerContinuous {
+			return false
+		}
+	}
+	return true
+}
+
+// continuousRecordings returns the IDs of the unprotected continuous
+// recordings in the monitor directory. Recordings without readable
+// data are treated as event recordings.
+func continuousRecordings(backend Backend, monitorDir string) ([]string, error) {
+	entries, err := backend.List(monitorDir)
+	if err != nil {
+		return nil, fmt.Errorf("read monitor directory: %w", err)
+	}
+	protected := protectedIDs(entries)
+
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".json")
+		if _, exist := protected[id]; exist {
+			continue
+		}
+		data, err := readBackendRecordingData(backend, path.Join(monitorDir, name))
+		if err != nil || !isContinuous(data) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func readBackendRecordingData(backend Backend, name string) (RecordingData, error) {
+	file, err := backend.Read(name)
+	if err != nil {
+		return RecordingData{}, err
+	}
+	defer file.Close()
+
+	raw, err := io.ReadAll(file)
+	if err != nil {
+		return RecordingData{}, err
+	}
+	var data RecordingData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return RecordingData{}, err
+	}
+	return data, nil
+}
+
+// dayHasContinuous returns true if the day contains continuous recordings.
+func dayHasContinuous(backend Backend, day string) (bool, error) {
+	monitorDirs, err := backend.List(day)
+	if err != nil {
+		return false, fmt.Errorf("read day directory: %w", err)
+	}
+	for _, monitorDir := range monitorDirs {
+		if !monitorDir.IsDir() {
+			continue
+		}
+		ids, err := continuousRecordings(backend, path.Join(day, monitorDir.Name()))
+		if err != nil {
+			return false, err
+		}
+		if len(ids) != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// oldestContinuousDay returns the path of the oldest day with continuous
+// recordings relative to the recordings directory. Returns an empty
+// string if there are no continuous recordings.
+func oldestContinuousDay(backend Backend) (string, error) {
+	const dayDepth = 3
+
+	var walk func(dir string, depth int) (string, error)
+	walk = func(dir string, depth int) (string, error) {
+		list, err := backend.List(dir)
+		if err != nil {
+			return "", fmt.Errorf("read directory %v: %w", dir, err)
+		}
+		for _, entry := range list {
+			if !entry.IsDir() {
+				continue
+			}
+			child := path.Join(dir, entry.Name())
+			if depth != dayDepth {
+				day, err := walk(child, depth+1)
+				if err != nil || day != "" {
+					return day, err
+				}
+				continue
+			}
+			found, err := dayHasContinuous(backend, child)
+			if err != nil {
+				return "", err
+			}
+			if found {
+				return child, nil
+			}
+		}
+		return "", nil
+	}
+	return walk(".", 1)
+}
+
+// removeContinuous removes the continuous recordings from the day.
+// Returns the number of removed recordings.
+func removeContinuous(backend Backend, day string) (int, error) {
+	monitorDirs, err := backend.List(day)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("read day directory: %w", err)
+	}
+
+	removed := 0
+	var errs []error
+	for _, monitorDir := range monitorDirs {
+		if !monitorDir.IsDir() {
+			continue
+		}
+		monitorPath := path.Join(day, monitorDir.Name())
+		ids, err := continuousRecordings(backend, monitorPath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		remove := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			remove[id] = struct{}{}
+		}
+
+		entries, err := backend.List(monitorPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read monitor directory: %w", err))
+			continue
+		}
+		files := make(map[string][]string)
+		for _, entry := range entries {
+			id := recordingID(entry.Name(), monitorDir.Name())
+			if _, exist := remove[id]; exist {
+				files[id] = append(files[id], entry.Name())
+			}
+		}
+		n, err := deleteRecordings(backend, monitorPath, files)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		removed += n
+	}
+	return removed, errors.Join(errs...)
+}
+
+// deleteRecordings deletes the files of each recording in the directory,
+// files is a map of recording IDs to file names. Recordings that have been
+// locked since they were listed are kept. Returns the number of deleted
+// recordings.
+func deleteRecordings(backend Backend, dir string, files map[string][]string) (int, error) {
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	deleted := 0
+	var errs []error
+	for _, id := range ids {
+		err := recordingLocks.tryDelete([]string{id}, func() error {
+			var errs []error
+			for _, name := range files[id] {
+				if err := backend.Delete(path.Join(dir, name)); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		})
+		if errors.Is(err, ErrRecordingLocked) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// recordingID returns the recording ID of a file in the monitor directory,
+// "YYYY-MM-DD_hh-mm-ss_monitor.json.tmp" is also supported. Monitor IDs
+// may contain dots, so the length of the ID is used instead of the extension.
+func recordingID(name string, monitorID string) string {
+	n := len("2006-01-02_15-04-05_") + len(monitorID)
+	if len(name) <= n || name[n] != '.' {
+		return ""
+	}
+	return name[:n]
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsContinuous(t *testing.T) {
+	cases := map[string]struct {
+		events   []Event
+		expected bool
+	}{
+		"noEvents":   {nil, true},
+		"continuous": {[]Event{{Trigger: TriggerContinuous}}, true},
+		"motion": {
+			[]Event{{Trigger: TriggerContinuous}, {Trigger: TriggerMotion}},
+			false,
+		},
+		"object":    {[]Event{{Trigger: TriggerObject}}, false},
+		"noTrigger": {[]Event{{}}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isContinuous(RecordingData{Events: tc.events}))
+		})
+	}
+}
+
+func TestRecordingID(t *testing.T) {
+	cases := map[string]struct {
+		name, monitorID, expected string
+	}{
+		"json":    {"2000-01-01_01-01-01_m1.json", "m1", "2000-01-01_01-01-01_m1"},
+		"tmp":     {"2000-01-01_01-01-01_m1.json.tmp", "m1", "2000-01-01_01-01-01_m1"},
+		"dot":     {"2000-01-01_01-01-01_m.1.mp4", "m.1", "2000-01-01_01-01-01_m.1"},
+		"short":   {"x.json", "m1", ""},
+		"noExt":   {"2000-01-01_01-01-01_m1", "m1", ""},
+		"otherID": {"2000-01-01_01-01-01_m11.json", "m1", ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, recordingID(tc.name, tc.monitorID))
+		})
+	}
+}
+
+func writeTestRecording(t *testing.T, dir string, recID string, triggers ...string) {
+	t.Helper()
+	var events []Event
+	for _, trigger := range triggers {
+		events = append(events, Event{Time: time.Unix(1, 0), Trigger: trigger})
+	}
+	path := filepath.Join(dir, recID)
+	require.NoError(t, WriteRecordingData(path, RecordingData{Events: events}))
+	createFiles(t, dir, []string{recID + ".mp4", recID + ".jpeg"})
+}
+
+func TestPruneContinuous(t *testing.T) {
+	recordingsDir := filepath.Join(t.TempDir(), "recordings")
+	m := &Manager{
+		storageDir: filepath.Dir(recordingsDir),
+		disk: &disk{
+			general: &ConfigGeneral{
+				Config: map[string]string{"diskSpace": "1"},
+			},
+			diskUsageBytes: highUsage,
+		},
+		removeAll: os.RemoveAll,
+		logger:    log.NewDummyLogger(),
+	}
+
+	day1 := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	day2 := filepath.Join(recordingsDir, "2000", "01", "02", "m1")
+	require.NoError(t, os.MkdirAll(day1, 0o700))
+	require.NoError(t, os.MkdirAll(day2, 0o700))
+
+	writeTestRecording(t, day1, "2000-01-01_01-01-01_m1", TriggerMotion)
+	writeTestRecording(t, day1, "2000-01-01_02-02-02_m1", TriggerContinuous)
+	writeTestRecording(t, day2, "2000-01-02_01-01-01_m1", TriggerObject)
+	writeTestRecording(t, day2, "2000-01-02_02-02-02_m1")
+	writeTestRecording(t, day2, "2000-01-02_03-03-03_m1", TriggerContinuous)
+	require.NoError(t, SetRecordingProtected(recordingsDir, "2000-01-02_03-03-03_m1", true))
+
+	// Continuous recordings are deleted from the oldest day first.
+	require.NoError(t, m.prune())
+	require.Equal(t,
+		[]string{
+			"2000-01-01_01-01-01_m1.jpeg",
+			"2000-01-01_01-01-01_m1.json",
+			"2000-01-01_01-01-01_m1.mp4",
+		},
+		listDirectory(t, day1),
+	)
+	require.Len(t, listDirectory(t, day2), 10)
+
+	// Then from the next day, protected recordings are kept.
+	require.NoError(t, m.prune())
+	require.Len(t, listDirectory(t, day1), 3)
+	require.Equal(t,
+		[]string{
+			"2000-01-02_01-01-01_m1.jpeg",
+			"2000-01-02_01-01-01_m1.json",
+			"2000-01-02_01-01-01_m1.mp4",
+			"2000-01-02_03-03-03_m1.jpeg",
+			"2000-01-02_03-03-03_m1.json",
+			"2000-01-02_03-03-03_m1.mp4",
+			"2000-01-02_03-03-03_m1.protected",
+		},
+		listDirectory(t, day2),
+	)
+
+	// Event recordings are deleted once there are no continuous recordings.
+	require.NoError(t, m.prune())
+	require.NoDirExists(t, filepath.Dir(day1))
+	require.Len(t, listDirectory(t, day2), 7)
+
+	require.NoError(t, m.prune())
+	require.Equal(t,
+		[]string{
+			"2000-01-02_03-03-03_m1.jpeg",
+			"2000-01-02_03-03-03_m1.json",
+			"2000-01-02_03-03-03_m1.mp4",
+			"2000-01-02_03-03-03_m1.protected",
+		},
+		listDirectory(t, day2),
+	)
+}
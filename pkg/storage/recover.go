@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"nvr/pkg/crypt"
+	"nvr/pkg/log"
+	"nvr/pkg/video/customformat"
+	"nvr/pkg/video/gortsplib/pkg/h264"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoVideoSamples the recording doesn't have any complete video samples.
+var ErrNoVideoSamples = errors.New("no complete video samples")
+
+// RecoverRecordings finalizes the recordings that were left half-written
+// by a crash or power loss. Videos without a data file are truncated to
+// the last complete sample and get a data file without events, videos
+// that can't be recovered are marked as corrupt. Returns the IDs of the
+// recovered recordings. Must be called before the monitors are started,
+// recordings in progress also don't have a data file.
+func RecoverRecordings(ctx context.Context, recordingsDirs []string, logger log.ILogger) []string {
+	logf := func(level log.Level, format string, a ...interface{}) {
+		logger.Log(log.Entry{
+			Level: level,
+			Src:   "app",
+			Msg:   fmt.Sprintf(format, a...),
+		})
+	}
+
+	var recovered []string
+	for _, dir := range recordingsDirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if entry.IsDir() {
+				return nil
+			}
+
+			// Data file that was never renamed.
+			if strings.HasSuffix(path, ".json"+tmpExt) {
+				os.Remove(path)
+				return nil
+			}
+
+			recPath, isMeta := strings.CutSuffix(path, ".meta")
+			if !isMeta || fileExist(recPath+".json") || fileExist(recPath+corruptExt) {
+				return nil
+			}
+
+			recID := filepath.Base(recPath)
+			if err := recoverRecording(recPath); err != nil {
+				logf(log.LevelError, "recording %v is corrupt: %v", recID, err)
+				if err := markRecordingCorrupt(recPath, err); err != nil {
+					logf(log.LevelError, "mark recording %v as corrupt: %v", recID, err)
+				}
+				return nil
+			}
+			logf(log.LevelInfo, "recording %v: recovered", recID)
+			recovered = append(recovered, recID)
+			return nil
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logf(log.LevelError, "recover recordings: %v", err)
+		}
+	}
+	return recovered
+}
+
+func recoverRecording(recPath string) error {
+	header, samples, err := recoverVideo(recPath)
+	if err != nil {
+		return err
+	}
+
+	var hasVideo bool
+	var end int64
+	for _, s := range samples {
+		if !s.IsAudioSample {
+			hasVideo = true
+		}
+		if s.Next > end {
+			end = s.Next
+		}
+	}
+	if !hasVideo {
+		return ErrNoVideoSamples
+	}
+
+	start := time.Unix(0, header.StartTime)
+	endTime := time.Unix(0, end)
+	data := RecordingData{
+		Start:     start,
+		End:       endTime,
+		Activity:  NewActivityIndex(start, endTime, nil),
+		Recovered: true,
+	}
+	var sps h264.SPS
+	if err := sps.Unmarshal(header.VideoSPS); err == nil {
+		data.Width = sps.Width()
+		data.Height = sps.Height()
+	}
+	data.Summarize()
+
+	return WriteRecordingData(recPath, data)
+}
+
+// recoverVideo truncates the video files. Encrypted mdat files
+// aren't truncated, the torn last chunk is ignored when it's read.
+func recoverVideo(recPath string) (*customformat.Header, []customformat.Sample, error) {
+	mdat, err := crypt.Open(recPath + ".mdat")
+	if err != nil {
+		return nil, nil, err
+	}
+	encrypted, mdatSize := mdat.Encrypted(), mdat.Size()
+	mdat.Close()
+
+	if encrypted {
+		return customformat.RecoverMeta(recPath+".meta", mdatSize)
+	}
+	return customformat.Recover(recPath+".meta", recPath+".mdat")
+}
+
+// markRecordingCorrupt writes the corrupt marker with the reason.
+func markRecordingCorrupt(recPath string, reason error) error {
+	return os.WriteFile(recPath+corruptExt, []byte(reason.Error()), 0o600)
+}
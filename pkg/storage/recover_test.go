@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/crypt"
+	"nvr/pkg/log"
+	"nvr/pkg/video/customformat"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestMeta(t *testing.T, recPath string, samples []customformat.Sample, mdatSize int) {
+	t.Helper()
+	header := customformat.Header{StartTime: time.Unix(1000, 0).UnixNano()}
+	meta := header.Marshal()
+	for _, s := range samples {
+		meta = append(meta, s.Marshal()...)
+	}
+	require.NoError(t, os.WriteFile(recPath+".meta", meta, 0o600))
+	require.NoError(t, os.WriteFile(recPath+".mdat", make([]byte, mdatSize), 0o600))
+}
+
+func TestRecoverRecordings(t *testing.T) {
+	dir := t.TempDir()
+	monitorDir := filepath.Join(dir, "2000", "01", "01", "m1")
+	require.NoError(t, os.MkdirAll(monitorDir, 0o755))
+	path := func(id string) string {
+		return filepath.Join(monitorDir, id)
+	}
+
+	samples := []customformat.Sample{
+		{IsSyncSample: true, Next: time.Unix(1001, 0).UnixNano(), Size: 4},
+		{Next: time.Unix(1002, 0).UnixNano(), Offset: 4, Size: 4},
+	}
+
+	// The mdat data of the last sample is missing.
+	writeTestMeta(t, path("crashed"), samples, 6)
+
+	// Only audio.
+	writeTestMeta(t, path("audio"), []customformat.Sample{{IsAudioSample: true}}, 0)
+
+	// Finished recording.
+	writeTestMeta(t, path("finished"), samples, 8)
+	require.NoError(t, os.WriteFile(path("finished")+".json", []byte("{}"), 0o600))
+	require.NoError(t, os.WriteFile(path("finished")+".json.tmp", nil, 0o600))
+
+	recovered := RecoverRecordings(
+		context.Background(),
+		[]string{dir, filepath.Join(dir, "nil")},
+		log.NewDummyLogger(),
+	)
+	require.Equal(t, []string{"crashed"}, recovered)
+
+	raw, err := os.ReadFile(path("crashed") + ".json")
+	require.NoError(t, err)
+	var data RecordingData
+	require.NoError(t, json.Unmarshal(raw, &data))
+	require.True(t, data.Start.Equal(time.Unix(1000, 0)))
+	require.True(t, data.End.Equal(time.Unix(1001, 0)))
+	require.Equal(t, time.Second, data.Duration)
+	require.Equal(t, RecordingDataVersion, data.Version)
+	require.True(t, data.Recovered)
+
+	info, err := os.Stat(path("crashed") + ".mdat")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), info.Size())
+
+	reason, err := os.ReadFile(path("audio") + corruptExt)
+	require.NoError(t, err)
+	require.Equal(t, ErrNoVideoSamples.Error(), string(reason))
+	require.NoFileExists(t, path("audio")+".json")
+
+	raw, err = os.ReadFile(path("finished") + ".json")
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(raw))
+	require.NoFileExists(t, path("finished")+".json.tmp")
+
+	// Nothing left to recover.
+	recovered = RecoverRecordings(context.Background(), []string{dir}, log.NewDummyLogger())
+	require.Empty(t, recovered)
+}
+
+func TestWriteRecordingData(t *testing.T) {
+	recPath := filepath.Join(t.TempDir(), "x")
+	data := RecordingData{Start: time.Unix(1, 0).UTC(), End: time.Unix(2, 0).UTC()}
+	require.NoError(t, WriteRecordingData(recPath, data))
+
+	raw, err := os.ReadFile(recPath + ".json")
+	require.NoError(t, err)
+	var got RecordingData
+	require.NoError(t, json.Unmarshal(raw, &got))
+	require.Equal(t, data, got)
+	require.NoFileExists(t, recPath+".json.tmp")
+}
+
+func TestRecoverRecordingsEncrypted(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	keyring, err := crypt.ParseKeyring([]byte("keys:\n  a: " + key + "\ndefault: a\n"))
+	require.NoError(t, err)
+	crypt.SetKeyring(keyring)
+	defer crypt.SetKeyring(nil)
+
+	dir := t.TempDir()
+	monitorDir := filepath.Join(dir, "2000", "01", "01", "m1")
+	require.NoError(t, os.MkdirAll(monitorDir, 0o755))
+	recPath := filepath.Join(monitorDir, "crashed")
+
+	samples := []customformat.Sample{
+		{IsSyncSample: true, Next: time.Unix(1001, 0).UnixNano(), Size: 4},
+		{Next: time.Unix(1002, 0).UnixNano(), Offset: 4, Size: 4},
+	}
+	writeTestMeta(t, recPath, samples, 0)
+
+	// The data of the last sample was never flushed.
+	mdat, err := crypt.Create(recPath+".mdat", "m1")
+	require.NoError(t, err)
+	_, err = mdat.Write([]byte{1, 2, 3, 4})
+	require.NoError(t, err)
+	require.NoError(t, mdat.Flush())
+	require.NoError(t, mdat.Close())
+
+	recovered := RecoverRecordings(context.Background(), []string{dir}, log.NewDummyLogger())
+	require.Equal(t, []string{"crashed"}, recovered)
+
+	raw, err := os.ReadFile(recPath + ".json")
+	require.NoError(t, err)
+	var data RecordingData
+	require.NoError(t, json.Unmarshal(raw, &data))
+	require.True(t, data.End.Equal(time.Unix(1001, 0)))
+
+	// The encrypted file isn't truncated.
+	mdatData, err := crypt.ReadFile(recPath + ".mdat")
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, mdatData)
+}
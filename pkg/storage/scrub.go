@@ -0,0 +1,308 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"nvr/pkg/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files and directories that were modified recently may belong
+// to a recording in progress and are never removed by the scrubber.
+const scrubMinAge = time.Hour
+
+// Depth of the monitor directories, "YYYY/MM/DD/monitor".
+const monitorDirDepth = 4
+
+// Temporary file of the verifier, "YYYY-MM-DD_hh-mm-ss_monitor.mp4.repair".
+const repairExt = ".repair"
+
+// ScrubReport is the result of the last scrub.
+type ScrubReport struct {
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Removed files and directories relative
+	// to the recordings directory of the volume.
+	Removed []string `json:"removed"`
+
+	// Bytes freed by the removed files.
+	Freed int64 `json:"freed"`
+}
+
+// Scrubber removes files that don't belong to a complete recording
+// and empty directories from the recordings directories. Leftovers
+// of crashed recordings are never shown by the crawler and only
+// use space. Protected recordings are never touched. Crashed recordings
+// are recovered like RecoverRecordings does at startup before they're
+// considered incomplete, recorders that are restarted after a panic
+// leave them behind without a restart of the app.
+//
+// Removed:
+//   - Thumbnails, data files and markers without a video file.
+//   - Video files without a data file, the recording didn't finish.
+//   - Temporary files of the verifier.
+//   - Empty year, month, day and monitor directories.
+type Scrubber struct {
+	recordingsDirs []string
+	logger         log.ILogger
+	trigger        chan struct{}
+
+	report ScrubReport
+	mu     sync.Mutex
+}
+
+// NewScrubber returns a scrubber for the recordings directories.
+func NewScrubber(recordingsDirs []string, logger log.ILogger) *Scrubber {
+	return &Scrubber{
+		recordingsDirs: recordingsDirs,
+		logger:         logger,
+		trigger:        make(chan struct{}, 1),
+	}
+}
+
+// Run scrubs the recordings on an interval, or when
+// triggered, until the context is canceled.
+func (s *Scrubber) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		case <-s.trigger:
+		}
+		s.scrub(ctx, time.Now())
+	}
+}
+
+// Trigger starts a scrub. Returns false if one is already pending.
+func (s *Scrubber) Trigger() bool {
+	select {
+	case s.trigger <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Report returns the report of the running or last scrub.
+func (s *Scrubber) Report() ScrubReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := s.report
+	report.Removed = append([]string{}, report.Removed...)
+	return report
+}
+
+func (s *Scrubber) scrub(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	s.report = ScrubReport{Running: true, Started: now}
+	s.mu.Unlock()
+
+	for _, dir := range s.recordingsDirs {
+		_, err := s.scrubDir(ctx, dir, ".", 0, now)
+		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, os.ErrNotExist) {
+			s.logf(log.LevelError, "scrub recordings: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.report.Running = false
+	s.report.Finished = time.Now()
+	removed, freed := len(s.report.Removed), s.report.Freed
+	s.mu.Unlock()
+
+	if removed != 0 {
+		s.logf(log.LevelInfo, "storage scrub: removed %v files and directories, freed %v bytes", removed, freed)
+	}
+}
+
+// scrubDir scrubs the directory at the depth, 0 is the recordings
+// directory and 1 the years. Returns true if the directory was removed.
+func (s *Scrubber) scrubDir(
+	ctx context.Context,
+	recordingsDir string,
+	dir string,
+	depth int,
+	now time.Time,
+) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	path := filepath.Join(recordingsDir, dir)
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	// Checked before the contents are removed, that updates the time.
+	recent := now.Sub(info.ModTime()) < scrubMinAge
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+
+	remaining := len(entries)
+	if depth == monitorDirDepth {
+		remaining -= s.scrubRecordings(recordingsDir, dir, entries, now)
+	} else {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			removed, err := s.scrubDir(ctx, recordingsDir, filepath.Join(dir, entry.Name()), depth+1, now)
+			if err != nil {
+				return false, err
+			}
+			if removed {
+				remaining--
+			}
+		}
+	}
+
+	// Don't delete the recordings directory.
+	if remaining != 0 || recent || depth == 0 {
+		return false, nil
+	}
+	// Fails if a recording was started in the directory.
+	if err := os.Remove(path); err != nil {
+		return false, nil
+	}
+	s.removed(dir, 0)
+	return true, nil
+}
+
+type scrubFile struct {
+	name string
+	size int64
+}
+
+// scrubRecordings removes the incomplete recordings in
+// the monitor directory. Returns the number of removed files.
+func (s *Scrubber) scrubRecordings(
+	recordingsDir string,
+	dir string,
+	entries []fs.DirEntry,
+	now time.Time,
+) int {
+	recordings := make(map[string][]scrubFile)
+	recent := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		id := recordingIDFromFile(strings.TrimSuffix(name, repairExt))
+		recordings[id] = append(recordings[id], scrubFile{name: name, size: info.Size()})
+		if now.Sub(info.ModTime()) < scrubMinAge {
+			recent[id] = true
+		}
+	}
+
+	ids := make([]string, 0, len(recordings))
+	for id := range recordings {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	removed := 0
+	for _, id := range ids {
+		if recent[id] || RecordingLocked(id) {
+			continue
+		}
+		if s.recoverRecording(filepath.Join(recordingsDir, dir, id), id, recordings[id]) {
+			continue
+		}
+		// Locked recordings are kept until the next scrub.
+		recordingLocks.tryDelete([]string{id}, func() error { //nolint:errcheck
+			for _, file := range orphanedFiles(id, recordings[id]) {
+				path := filepath.Join(recordingsDir, dir, file.name)
+				if err := os.Remove(path); err != nil {
+					s.logf(log.LevelError, "scrub recordings: %v", err)
+					continue
+				}
+				s.removed(filepath.Join(dir, file.name), file.size)
+				removed++
+			}
+			return nil
+		})
+	}
+	return removed
+}
+
+// recoverRecording writes the data file of a crashed recording that only
+// has the video files. Returns true if the recording was recovered.
+func (s *Scrubber) recoverRecording(recPath string, id string, files []scrubFile) bool {
+	has := fileExts(id, files)
+	if !has[".meta"] || !has[".mdat"] || has[".json"] || has[corruptExt] || has[protectedExt] {
+		return false
+	}
+	if err := recoverRecording(recPath); err != nil {
+		s.logf(log.LevelError, "recover recording %v: %v", id, err)
+		return false
+	}
+	s.logf(log.LevelInfo, "recording %v: recovered", id)
+	return true
+}
+
+// fileExts returns the extensions of the files of the recording.
+func fileExts(id string, files []scrubFile) map[string]bool {
+	has := make(map[string]bool, len(files))
+	for _, file := range files {
+		has[strings.TrimPrefix(file.name, id)] = true
+	}
+	return has
+}
+
+// orphanedFiles returns the files of the recording that should be removed.
+func orphanedFiles(id string, files []scrubFile) []scrubFile {
+	has := fileExts(id, files)
+	if has[protectedExt] {
+		return nil
+	}
+
+	complete := has[".json"] && (has[".mp4"] || has[".meta"])
+	var orphaned []scrubFile
+	for _, file := range files {
+		ext := strings.TrimPrefix(file.name, id)
+		switch ext {
+		case ".mp4" + repairExt:
+			orphaned = append(orphaned, file)
+		case ".mp4", ".meta", ".mdat", ".json", ".jpeg", corruptExt:
+			if !complete {
+				orphaned = append(orphaned, file)
+			}
+		}
+	}
+	return orphaned
+}
+
+func (s *Scrubber) removed(path string, size int64) {
+	s.mu.Lock()
+	s.report.Removed = append(s.report.Removed, filepath.ToSlash(path))
+	s.report.Freed += size
+	s.mu.Unlock()
+}
+
+func (s *Scrubber) logf(level log.Level, format string, a ...interface{}) {
+	s.logger.Log(log.Entry{
+		Level: level,
+		Src:   "app",
+		Msg:   fmt.Sprintf(format, a...),
+	})
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+	"nvr/pkg/video/customformat"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubber(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, data string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	}
+
+	day := "2000/01/01/m1/"
+	// Complete recordings.
+	writeFile(day+"2000-01-01_01-00-00_m1.meta", "x")
+	writeFile(day+"2000-01-01_01-00-00_m1.mdat", "x")
+	writeFile(day+"2000-01-01_01-00-00_m1.json", "{}")
+	writeFile(day+"2000-01-01_01-00-00_m1.jpeg", "x")
+	writeFile(day+"2000-01-01_02-00-00_m1.mp4", "x")
+	writeFile(day+"2000-01-01_02-00-00_m1.json", "{}")
+	writeFile(day+"2000-01-01_02-00-00_m1.corrupt", "x")
+	writeFile(day+"2000-01-01_02-00-00_m1.mp4.repair", "xx")
+
+	// Orphaned sidecars.
+	writeFile(day+"2000-01-01_03-00-00_m1.jpeg", "xxx")
+	writeFile(day+"2000-01-01_03-00-00_m1.json", "{}")
+
+	// Crashed recording.
+	writeFile(day+"2000-01-01_04-00-00_m1.meta", "x")
+	writeFile(day+"2000-01-01_04-00-00_m1.mdat", "x")
+
+	// Recoverable crashed recording.
+	writeTestMeta(t, filepath.Join(dir, day, "2000-01-01_06-00-00_m1"), []customformat.Sample{
+		{IsSyncSample: true, Next: time.Unix(1001, 0).UnixNano(), Size: 4},
+	}, 4)
+
+	// Protected and unknown files are kept.
+	writeFile(day+"2000-01-01_05-00-00_m1.jpeg", "x")
+	writeFile(day+"2000-01-01_05-00-00_m1.protected", "")
+	writeFile(day+"unknown.txt", "x")
+
+	// Empty directories.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2000/01/02/m1"), 0o700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2000/02/01/m1"), 0o700))
+
+	// Recent files are kept.
+	writeFile("2000/01/03/m1/2000-01-03_01-00-00_m1.jpeg", "x")
+	future := time.Now().Add(2 * time.Hour)
+	path := filepath.Join(dir, "2000/01/03/m1/2000-01-03_01-00-00_m1.jpeg")
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	s := NewScrubber([]string{dir, filepath.Join(dir, "nil")}, log.NewDummyLogger())
+	s.scrub(context.Background(), time.Now().Add(time.Hour+time.Minute))
+
+	report := s.Report()
+	require.False(t, report.Running)
+	sort.Strings(report.Removed)
+	expected := []string{
+		"2000/01/01/m1/2000-01-01_02-00-00_m1.mp4.repair",
+		"2000/01/01/m1/2000-01-01_03-00-00_m1.jpeg",
+		"2000/01/01/m1/2000-01-01_03-00-00_m1.json",
+		"2000/01/01/m1/2000-01-01_04-00-00_m1.mdat",
+		"2000/01/01/m1/2000-01-01_04-00-00_m1.meta",
+		"2000/01/02",
+		"2000/01/02/m1",
+		"2000/02",
+		"2000/02/01",
+		"2000/02/01/m1",
+	}
+	require.Equal(t, expected, report.Removed)
+	require.Equal(t, int64(9), report.Freed)
+
+	var remaining []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		require.NoError(t, err)
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			remaining = append(remaining, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	expected = []string{
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.jpeg",
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.json",
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.mdat",
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.meta",
+		"2000/01/01/m1/2000-01-01_02-00-00_m1.corrupt",
+		"2000/01/01/m1/2000-01-01_02-00-00_m1.json",
+		"2000/01/01/m1/2000-01-01_02-00-00_m1.mp4",
+		"2000/01/01/m1/2000-01-01_05-00-00_m1.jpeg",
+		"2000/01/01/m1/2000-01-01_05-00-00_m1.protected",
+		"2000/01/01/m1/2000-01-01_06-00-00_m1.json",
+		"2000/01/01/m1/2000-01-01_06-00-00_m1.mdat",
+		"2000/01/01/m1/2000-01-01_06-00-00_m1.meta",
+		"2000/01/01/m1/unknown.txt",
+		"2000/01/03/m1/2000-01-03_01-00-00_m1.jpeg",
+	}
+	require.Equal(t, expected, remaining)
+
+	require.True(t, s.Trigger())
+	require.False(t, s.Trigger())
+}
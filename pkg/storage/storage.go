@@ -4,13 +4,20 @@ package storage
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/netip"
+	"net/url"
+	"nvr/pkg/feed"
 	"nvr/pkg/log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,23 +30,40 @@ import (
 type Manager struct {
 	storageDir   string
 	storageDirFS fs.FS
+	volumes      []string
 	disk         *disk
+	ageReport    *ageReportCache
+	forecaster   *forecaster
 	removeAll    func(string) error
 
 	logger log.ILogger
 }
 
-// NewManager returns new manager.
-func NewManager(storageDir string, general *ConfigGeneral, log log.ILogger) *Manager {
+// NewManager returns new manager. Disk usage and
+// pruning includes the additional storage volumes.
+func NewManager(
+	storageDir string,
+	volumes []string,
+	general *ConfigGeneral,
+	log log.ILogger,
+) *Manager {
 	storageDirFS := os.DirFS(storageDir)
-	return &Manager{
+	volumesFS := []fs.FS{storageDirFS}
+	for _, volume := range volumes {
+		volumesFS = append(volumesFS, os.DirFS(volume))
+	}
+	s := &Manager{
 		storageDir:   storageDir,
 		storageDirFS: storageDirFS,
-		disk:         newDisk(general, storageDirFS),
+		volumes:      volumes,
+		disk:         newDisk(general, NewMultiFS(volumesFS...)),
 		removeAll:    os.RemoveAll,
 
 		logger: log,
 	}
+	s.ageReport = &ageReportCache{fs: s.RecordingsFS()}
+	s.forecaster = &forecaster{fs: s.RecordingsFS()}
+	return s
 }
 
 // RecordingsDir Returns path to recordings diectory.
@@ -47,6 +71,32 @@ func (s *Manager) RecordingsDir() string {
 	return filepath.Join(s.storageDir, "recordings")
 }
 
+func (s *Manager) recordingsDirs() []string {
+	dirs := []string{s.RecordingsDir()}
+	for _, volume := range s.volumes {
+		dirs = append(dirs, recordingsDir(volume))
+	}
+	return dirs
+}
+
+// recordingsBackends returns the backend of the recordings directory of every volume.
+func (s *Manager) recordingsBackends() []Backend {
+	var backends []Backend
+	for _, dir := range s.recordingsDirs() {
+		backends = append(backends, &FileBackend{root: dir, removeAll: s.removeAll})
+	}
+	return backends
+}
+
+// RecordingsFS returns the recordings of all volumes as a single file system.
+func (s *Manager) RecordingsFS() fs.FS {
+	var fileSystems []fs.FS
+	for _, backend := range s.recordingsBackends() {
+		fileSystems = append(fileSystems, NewBackendFS(backend))
+	}
+	return NewMultiFS(fileSystems...)
+}
+
 // DiskUsageCached returns cached value and its age.
 func (s *Manager) DiskUsageCached() (DiskUsage, time.Duration) {
 	return s.disk.usageCached()
@@ -58,8 +108,16 @@ func (s *Manager) DiskUsage(maxAge time.Duration) (DiskUsage, error) {
 	return s.disk.usage(maxAge)
 }
 
-// prune checks if disk usage is above 99%,
-// if true deletes all files from the oldest day.
+// AgeReport returns the recording age report of all
+// volumes. The report is generated once per day.
+func (s *Manager) AgeReport() AgeReport {
+	return s.ageReport.get(time.Now())
+}
+
+// prune checks if disk usage is above 99%, if true deletes the
+// continuous recordings from the oldest day that has any. All
+// unprotected files from the oldest day on every volume are
+// deleted once there are no continuous recordings left.
 func (s *Manager) prune() error {
 	usage, err := s.DiskUsage(10 * time.Minute)
 	if err != nil {
@@ -69,47 +127,98 @@ func (s *Manager) prune() error {
 		return nil
 	}
 
-	const dayDepth = 3
+	// Unreadable volumes are skipped to keep pruning the others.
+	backends := s.recordingsBackends()
+	pruned, err := s.pruneContinuous(backends)
+	if pruned {
+		return err
+	}
 
-	// Find the oldest day.
-	path := s.RecordingsDir()
-	for depth := 1; depth <= dayDepth; depth++ {
-		list, err := fs.ReadDir(os.DirFS(path), ".")
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	oldest := ""
+	for _, backend := range backends {
+		day, err := oldestDay(backend)
 		if err != nil {
-			return fmt.Errorf("read directory %v: %w", path, err)
-		}
-
-		isDirEmpty := len(list) == 0
-		if isDirEmpty {
-			// Don't delete the recordings directory.
-			if depth == 1 {
-				return nil
-			}
-
-			if err := s.removeAll(path); err != nil {
-				return fmt.Errorf("remove empty directory: %w", err)
-			}
-
-			path = s.RecordingsDir()
-			depth = 0
+			errs = append(errs, err)
 			continue
 		}
-
-		firstFile := list[0].Name()
-		path = filepath.Join(path, firstFile)
+		if day != "" && (oldest == "" || day < oldest) {
+			oldest = day
+		}
+	}
+	if oldest == "" {
+		return errors.Join(errs...)
 	}
 
 	s.logger.Log(log.Entry{
 		Level: log.LevelInfo,
 		Src:   "app",
-		Msg:   fmt.Sprintf("pruning storage: deleting %q", path),
+		Msg:   fmt.Sprintf("pruning storage: deleting %q", oldest),
 	})
 
 	// Delete all files from that day
-	if err := s.removeAll(path); err != nil {
-		return fmt.Errorf("remove directory: %w", err)
+	for _, backend := range backends {
+		if err := removeDay(backend, oldest); err != nil {
+			errs = append(errs, fmt.Errorf("remove directory: %w", err))
+		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// oldestDay returns the path of the oldest day relative to the recordings
+// directory and removes empty directories along the way. Days that only
+// contain protected recordings are skipped. Returns an empty string if
+// there are no days.
+func oldestDay(backend Backend) (string, error) {
+	day, _, err := oldestDayIn(backend, ".", 1)
+	return day, err
+}
+
+// oldestDayIn searches the directory at the depth, 1 is the years.
+// Returns true if the directory is empty and was removed.
+func oldestDayIn(backend Backend, dir string, depth int) (string, bool, error) {
+	const dayDepth = 3
+
+	list, err := backend.List(dir)
+	if err != nil {
+		return "", false, fmt.Errorf("read directory %v: %w", dir, err)
+	}
+
+	removed := 0
+	for _, entry := range list {
+		child := path.Join(dir, entry.Name())
+		if depth == dayDepth {
+			protected, unprotected, err := dayProtection(backend, child)
+			if err != nil {
+				return "", false, err
+			}
+			if protected != 0 && unprotected == 0 {
+				continue
+			}
+			return child, false, nil
+		}
+
+		day, childRemoved, err := oldestDayIn(backend, child, depth+1)
+		if err != nil || day != "" {
+			return day, false, err
+		}
+		if childRemoved {
+			removed++
+		}
+	}
+
+	// Don't delete the recordings directory.
+	isDirEmpty := removed == len(list)
+	if !isDirEmpty || depth == 1 {
+		return "", false, nil
+	}
+	if err := backend.Delete(dir); err != nil {
+		return "", false, fmt.Errorf("remove empty directory: %w", err)
+	}
+	return "", true, nil
 }
 
 // PurgeLoop runs Purge on an interval until context is canceled.
@@ -140,6 +249,8 @@ type disk struct {
 	lastUpdate time.Time
 	cacheLock  sync.Mutex
 
+	alerts     *feed.Buffer[DiskAlert]
+	alertLevel string
 	updateLock sync.Mutex
 }
 
@@ -148,6 +259,8 @@ func newDisk(general *ConfigGeneral, storageDirFS fs.FS) *disk {
 		general:        general,
 		diskUsageBytes: diskUsageBytes,
 		storageDirFS:   storageDirFS,
+		alerts:         feed.NewBuffer[DiskAlert](diskAlertHistorySize),
+		alertLevel:     DiskAlertOK,
 	}
 }
 
@@ -190,11 +303,14 @@ func (d *disk) usage(maxAge time.Duration) (DiskUsage, error) {
 		return DiskUsage{}, err
 	}
 
+	now := time.Now()
 	d.cacheLock.Lock()
 	d.cache = updatedUsage
-	d.lastUpdate = time.Now()
+	d.lastUpdate = now
 	d.cacheLock.Unlock()
 
+	d.checkAlert(updatedUsage, now)
+
 	return updatedUsage, nil
 }
 
@@ -281,14 +397,521 @@ type ConfigEnv struct {
 	HLSPortExpose  bool   `yaml:"hlsPortExpose"`
 	GoBin          string `yaml:"goBin"`
 	FFmpegBin      string `yaml:"ffmpegBin"`
+	GStreamerBin   string `yaml:"gstreamerBin"`
+
+	// IP addresses that the RTSP and HLS servers listen on, IPv6
+	// addresses are allowed. "::" listens on all interfaces. Defaults
+	// to the loopback interface, or all interfaces if exposed.
+	RTSPBind []string `yaml:"rtspBind"`
+	HLSBind  []string `yaml:"hlsBind"`
+
+	// Number of monitors to start at once during startup and
+	// the delay in seconds between the batches. Zero batch size
+	// starts all monitors at once.
+	StartupBatchSize int `yaml:"startupBatchSize"`
+	StartupDelay     int `yaml:"startupDelay"`
 
 	StorageDir string `yaml:"storageDir"`
 	TempDir    string
 
+	// Additional storage directories for recordings,
+	// usually on other disks. See Volumes.
+	StorageVolumes  []string `yaml:"storageVolumes"`
+	StorageStrategy string   `yaml:"storageStrategy"`
+
+	// Recordings are saved here when no storage volume is writable,
+	// for example on a tmpfs. Disabled if empty.
+	StorageFailoverDir string `yaml:"storageFailoverDir"`
+
+	// Fields exposed by the unauthenticated public
+	// status API. Empty disables the API.
+	PublicStatus []string `yaml:"publicStatus"`
+
+	// Rules that promote log entries to events.
+	LogEventRules []log.PromotionRule `yaml:"logEventRules"`
+
+	// Format of the logs printed to stdout, "text" or "json".
+	LogFormat string `yaml:"logFormat"`
+
+	// Forward the logs to syslog or remote collectors.
+	LogForward []log.ForwardConfig `yaml:"logForward"`
+
+	// Log HTTP requests, disabled by default.
+	AccessLog AccessLog `yaml:"accessLog"`
+
+	// URL that alerts are posted to as JSON, disabled by default.
+	AlertWebhook string `yaml:"alertWebhook"`
+
+	// Limits on failed login attempts.
+	AuthRateLimit AuthRateLimit `yaml:"authRateLimit"`
+
+	// Where the key that encrypts credentials at rest is stored.
+	SecretStore string `yaml:"secretStore"`
+
+	// Encrypt new recordings, disabled by default.
+	RecordingEncryption RecordingEncryption `yaml:"recordingEncryption"`
+
+	// URL path prefix that the app is served under, for example
+	// "/nvr" if a reverse proxy forwards "https://example.com/nvr/".
+	BasePath string `yaml:"basePath"`
+
+	// IP addresses or CIDR ranges of reverse proxies that are trusted to
+	// set the X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host
+	// headers. The forwarding headers of other requests are removed.
+	TrustedProxies []string `yaml:"trustedProxies"`
+
+	// Authentication by a SSO reverse proxy, disabled by default.
+	ProxyAuth ProxyAuth `yaml:"proxyAuth"`
+
+	// Cross-origin requests to the API, disabled by default.
+	CORS CORS `yaml:"cors"`
+
+	// HTTPS, disabled by default.
+	TLS TLS `yaml:"tls"`
+
+	// gRPC API, disabled by default.
+	GRPC GRPC `yaml:"grpc"`
+
+	// Streams pushed over RTMP, disabled by default.
+	Ingest Ingest `yaml:"ingest"`
+
+	// Signed releases, disabled by default.
+	Update Update `yaml:"update"`
+
+	// Limits on concurrent live streams, unlimited by default.
+	LiveSessions LiveSessions `yaml:"liveSessions"`
+
+	// Requirements of new passwords.
+	PasswordPolicy PasswordPolicy `yaml:"passwordPolicy"`
+
+	// Memory limits of the cached HLS segments, unlimited by default.
+	HLSMemory HLSMemory `yaml:"hlsMemory"`
+
+	// Watch live streams from some minutes ago, disabled by default.
+	HLSTimeshift HLSTimeshift `yaml:"hlsTimeshift"`
+
 	HomeDir   string `yaml:"homeDir"`
 	ConfigDir string
 }
 
+// AuthRateLimit locks IP addresses and accounts after too many failed
+// login attempts. Zero values are replaced by defaults, a negative
+// number of attempts disables that limit.
+type AuthRateLimit struct {
+	// Failed attempts allowed within the window.
+	MaxAttemptsIP      int `yaml:"maxAttemptsIP"`
+	MaxAttemptsAccount int `yaml:"maxAttemptsAccount"`
+
+	// Window and lockout duration in seconds.
+	Window  int `yaml:"window"`
+	Lockout int `yaml:"lockout"`
+}
+
+// AccessLog logs the HTTP requests to the "access" log source.
+type AccessLog struct {
+	Enable bool `yaml:"enable"`
+
+	// Log one of every N successful HLS requests, the live view requests
+	// a segment every few seconds for each stream. Failed requests are
+	// always logged. Zero is the default, one logs every request.
+	HLSSampleRate int `yaml:"hlsSampleRate"`
+}
+
+// DefaultAccessLogHLSSampleRate default HLS sample rate.
+const DefaultAccessLogHLSSampleRate = 100
+
+func (c *AccessLog) validate() error {
+	if c.HLSSampleRate == 0 {
+		c.HLSSampleRate = DefaultAccessLogHLSSampleRate
+	}
+	if c.HLSSampleRate < 0 {
+		return fmt.Errorf("accessLog: hlsSampleRate '%v': %w", c.HLSSampleRate, ErrInvalidValue)
+	}
+	return nil
+}
+
+// RecordingEncryption encrypts the video and thumbnail of new recordings
+// with AES-256-GCM. The keys are read from a key file or the output of a
+// command, see crypt.ParseKeyring for the format. The metadata and event
+// snapshots aren't encrypted.
+type RecordingEncryption struct {
+	// Absolute path to the key file.
+	KeyFile string `yaml:"keyFile"`
+
+	// Command that prints the key file to stdout, for
+	// example a script that fetches the keys from a KMS.
+	KeyCommand []string `yaml:"keyCommand"`
+
+	// Don't encrypt the thumbnails, they're displayed in the recordings list.
+	PlainThumbnails bool `yaml:"plainThumbnails"`
+}
+
+// Enabled returns true if keys are configured.
+func (c RecordingEncryption) Enabled() bool {
+	return c.KeyFile != "" || len(c.KeyCommand) != 0
+}
+
+func (c RecordingEncryption) validate() error {
+	if c.KeyFile != "" && len(c.KeyCommand) != 0 {
+		return fmt.Errorf("recordingEncryption: keyFile and keyCommand can't be combined: %w",
+			ErrInvalidValue)
+	}
+	if c.KeyFile != "" && !filepath.IsAbs(c.KeyFile) {
+		return fmt.Errorf("recordingEncryption: keyFile '%v': must be absolute: %w",
+			c.KeyFile, ErrInvalidValue)
+	}
+	if len(c.KeyCommand) != 0 && c.KeyCommand[0] == "" {
+		return fmt.Errorf("recordingEncryption: keyCommand: empty: %w", ErrInvalidValue)
+	}
+	return nil
+}
+
+// ProxyAuth authenticates the users by the headers that a SSO reverse
+// proxy, like Authelia or oauth2-proxy, sets on authenticated requests.
+// Only the headers of requests from trusted proxies are used.
+type ProxyAuth struct {
+	Enable bool `yaml:"enable"`
+
+	// Header with the username, "Remote-User" by default.
+	UserHeader string `yaml:"userHeader"`
+
+	// Header with the comma separated groups of
+	// the user, "Remote-Groups" by default.
+	GroupsHeader string `yaml:"groupsHeader"`
+
+	// Members of these groups are admins and other users aren't.
+	// The role of the account is used if there are no admin groups.
+	AdminGroups []string `yaml:"adminGroups"`
+
+	// Only members of these groups and admins may use
+	// the app. Everyone may use the app if it's empty.
+	UserGroups []string `yaml:"userGroups"`
+
+	// Create accounts for unknown users.
+	AutoProvision bool `yaml:"autoProvision"`
+
+	// Logout redirects to this URL, for example the logout page of the proxy.
+	LogoutURL string `yaml:"logoutURL"`
+}
+
+// Default proxy auth headers.
+const (
+	DefaultProxyAuthUserHeader   = "Remote-User"
+	DefaultProxyAuthGroupsHeader = "Remote-Groups"
+)
+
+func (c *ProxyAuth) validate(trustedProxies []string) error {
+	if !c.Enable {
+		return nil
+	}
+	if len(trustedProxies) == 0 {
+		return fmt.Errorf("proxyAuth: trustedProxies is required: %w", ErrInvalidValue)
+	}
+	if c.UserHeader == "" {
+		c.UserHeader = DefaultProxyAuthUserHeader
+	}
+	if c.GroupsHeader == "" {
+		c.GroupsHeader = DefaultProxyAuthGroupsHeader
+	}
+	if c.LogoutURL != "" {
+		u, err := url.Parse(c.LogoutURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("proxyAuth: logoutURL '%v': %w", c.LogoutURL, ErrInvalidValue)
+		}
+	}
+	return nil
+}
+
+// CORS allows web pages on other origins, for example third-party
+// dashboards, to call the API. Disabled if there are no origins.
+type CORS struct {
+	// Origins in the "<scheme>://<host>[:<port>]" format. "*" allows
+	// any origin, it can't be combined with credentials.
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+
+	// Allow requests with cookies or basic auth.
+	AllowCredentials bool `yaml:"allowCredentials"`
+
+	// Seconds that browsers cache preflight responses, zero is the default.
+	MaxAge int `yaml:"maxAge"`
+}
+
+// DefaultCORSMaxAge default preflight cache duration in seconds.
+const DefaultCORSMaxAge = 600
+
+func (c *CORS) validate() error {
+	for i, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("cors: allowedOrigins '*' can't be combined"+
+					" with allowCredentials: %w", ErrInvalidValue)
+			}
+			continue
+		}
+		// Browsers send the origin in lower case without a trailing slash.
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" || u.User != nil || u.Path != "" ||
+			u.RawQuery != "" || u.Fragment != "" || strings.ContainsAny(origin, "?#") {
+			return fmt.Errorf("cors: allowedOrigins '%v': %w", c.AllowedOrigins[i], ErrInvalidValue)
+		}
+		c.AllowedOrigins[i] = origin
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = DefaultCORSMaxAge
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("cors: maxAge '%v': %w", c.MaxAge, ErrInvalidValue)
+	}
+	return nil
+}
+
+// TLS serves the app over HTTPS using a certificate from files or
+// from Let's Encrypt. Certificate files are reloaded when modified.
+type TLS struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// Get certificates for the domains from Let's Encrypt using ACME.
+	// Can't be combined with the certificate files.
+	AutocertDomains []string `yaml:"autocertDomains"`
+	AutocertEmail   string   `yaml:"autocertEmail"`
+	AutocertDir     string   `yaml:"autocertDir"`
+
+	// Port of the HTTP server that redirects to HTTPS and
+	// answers ACME HTTP challenges. Zero disables it.
+	RedirectPort int `yaml:"redirectPort"`
+}
+
+// Enabled returns true if HTTPS is configured.
+func (c TLS) Enabled() bool {
+	return c.CertFile != "" || len(c.AutocertDomains) != 0
+}
+
+// Autocert returns true if the certificates are from Let's Encrypt.
+func (c TLS) Autocert() bool {
+	return len(c.AutocertDomains) != 0
+}
+
+// GRPC serves the gRPC API on a separate port. Uses
+// the same certificate as the web server if TLS is enabled.
+type GRPC struct {
+	// Zero disables the API.
+	Port int `yaml:"port"`
+
+	// API tokens with admin privileges. Clients send the token in
+	// the "authorization" metadata as "Bearer <token>". Credentials
+	// of users are also accepted as "Basic <base64>".
+	Tokens []string `yaml:"tokens"`
+}
+
+// Ingest accepts streams that encoders and cameras push over RTMP.
+type Ingest struct {
+	// Zero disables the RTMP server.
+	RTMPPort int `yaml:"rtmpPort"`
+
+	// IP addresses that the RTMP server listens on. Defaults
+	// to all interfaces since the streams are authenticated.
+	RTMPBind []string `yaml:"rtmpBind"`
+
+	// Stream keys by stream name. Encoders publish to
+	// "rtmp://<host>:<rtmpPort>/<name>/<key>" and monitors read the
+	// stream from "rtsp://127.0.0.1:<rtspPort>/ingest/<name>".
+	Streams map[string]string `yaml:"streams"`
+}
+
+const minIngestKeyLength = 16
+
+var reIngestStreamName = regexp.MustCompile(`^[0-9a-zA-Z_\-\.~]+$`)
+
+func (c *Ingest) validate() error {
+	if c.RTMPPort < 0 || c.RTMPPort > 65535 {
+		return fmt.Errorf("ingest: rtmpPort '%v': %w", c.RTMPPort, ErrInvalidValue)
+	}
+	rtmpBind, err := bindHosts("ingest: rtmp", c.RTMPBind, len(c.RTMPBind) == 0)
+	if err != nil {
+		return err
+	}
+	c.RTMPBind = rtmpBind
+	for name, key := range c.Streams {
+		if !reIngestStreamName.MatchString(name) {
+			return fmt.Errorf("ingest: stream name '%v': %w", name, ErrInvalidValue)
+		}
+		if len(key) < minIngestKeyLength {
+			return fmt.Errorf("ingest: stream %v: key must be at least %v characters: %w",
+				name, minIngestKeyLength, ErrInvalidValue)
+		}
+	}
+	return nil
+}
+
+// Update installs signed releases from a release manifest.
+type Update struct {
+	// HTTPS URL of the release manifest, empty disables updates.
+	URL string `yaml:"url"`
+
+	// Base64 encoded Ed25519 key that release signatures are verified with.
+	PublicKey string `yaml:"publicKey"`
+}
+
+// Key returns the decoded public key.
+func (c Update) Key() ed25519.PublicKey {
+	key, _ := base64.StdEncoding.DecodeString(c.PublicKey)
+	return key
+}
+
+func (c Update) validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("update: url '%v': %w", c.URL, ErrInvalidValue)
+	}
+	if len(c.Key()) != ed25519.PublicKeySize {
+		return fmt.Errorf("update: publicKey '%v': %w", c.PublicKey, ErrInvalidValue)
+	}
+	return nil
+}
+
+// LiveSessions limits the number of live streams each
+// user can watch at the same time. Admins are not limited.
+type LiveSessions struct {
+	// Maximum number of streams per user, zero is unlimited.
+	Limit int `yaml:"limit"`
+
+	// Limits of specific users by username, overrides Limit.
+	Users map[string]int `yaml:"users"`
+}
+
+// UserLimit returns the limit of the user, zero if unlimited.
+func (c LiveSessions) UserLimit(username string) int {
+	if limit, exist := c.Users[username]; exist {
+		return limit
+	}
+	return c.Limit
+}
+
+func (c LiveSessions) validate() error {
+	if c.Limit < 0 {
+		return fmt.Errorf("liveSessions: limit '%v': %w", c.Limit, ErrInvalidValue)
+	}
+	for username, limit := range c.Users {
+		if limit < 0 {
+			return fmt.Errorf("liveSessions: users: %v '%v': %w", username, limit, ErrInvalidValue)
+		}
+	}
+	return nil
+}
+
+// HLSMemory limits the memory used by the segments that the HLS
+// muxers keep in memory, in megabytes. Zero is unlimited.
+type HLSMemory struct {
+	// Shared by all paths.
+	Limit int `yaml:"limit"`
+
+	// Limit of each path.
+	PathLimit int `yaml:"pathLimit"`
+}
+
+func (c HLSMemory) validate() error {
+	if c.Limit < 0 {
+		return fmt.Errorf("hlsMemory: limit '%v': %w", c.Limit, ErrInvalidValue)
+	}
+	if c.PathLimit < 0 {
+		return fmt.Errorf("hlsMemory: pathLimit '%v': %w", c.PathLimit, ErrInvalidValue)
+	}
+	return nil
+}
+
+// HLSTimeshift keeps the HLS segments of the last
+// minutes on disk, in a sliding window per stream.
+type HLSTimeshift struct {
+	// Length of the window, zero disables timeshift.
+	Minutes int `yaml:"minutes"`
+
+	// Directory of the segments, defaults to the temporary
+	// directory. A tmpfs like /dev/shm avoids disk writes.
+	Dir string `yaml:"dir"`
+}
+
+// Maximum timeshift window, one day.
+const maxHLSTimeshiftMinutes = 24 * 60
+
+func (c HLSTimeshift) validate() error {
+	if c.Minutes < 0 || c.Minutes > maxHLSTimeshiftMinutes {
+		return fmt.Errorf("hlsTimeshift: minutes '%v': %w", c.Minutes, ErrInvalidValue)
+	}
+	if c.Dir != "" && !filepath.IsAbs(c.Dir) {
+		return fmt.Errorf("hlsTimeshift: dir '%v': %w", c.Dir, ErrPathNotAbsolute)
+	}
+	return nil
+}
+
+// PasswordPolicy requirements of new passwords, existing passwords
+// are not checked until they are changed. Zero MinLength is replaced
+// by the default.
+type PasswordPolicy struct {
+	MinLength int `yaml:"minLength"`
+
+	// Require at least one character of each class.
+	RequireUpper  bool `yaml:"requireUpper"`
+	RequireLower  bool `yaml:"requireLower"`
+	RequireDigit  bool `yaml:"requireDigit"`
+	RequireSymbol bool `yaml:"requireSymbol"`
+}
+
+// DefaultPasswordMinLength default minimum password length.
+const DefaultPasswordMinLength = 8
+
+func (c *PasswordPolicy) validate() error {
+	if c.MinLength == 0 {
+		c.MinLength = DefaultPasswordMinLength
+	}
+	if c.MinLength < 0 {
+		return fmt.Errorf("passwordPolicy: minLength '%v': %w", c.MinLength, ErrInvalidValue)
+	}
+	return nil
+}
+
+// Minimum length of gRPC API tokens.
+const minGRPCTokenLength = 16
+
+func (c GRPC) validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("grpc: port '%v': %w", c.Port, ErrInvalidValue)
+	}
+	for _, token := range c.Tokens {
+		if len(token) < minGRPCTokenLength {
+			return fmt.Errorf("grpc: tokens must be at least %v characters: %w",
+				minGRPCTokenLength, ErrInvalidValue)
+		}
+	}
+	return nil
+}
+
+// Secret stores.
+const (
+	// Keyring if available, otherwise file.
+	SecretStoreAuto    = "auto"
+	SecretStoreKeyring = "keyring"
+	SecretStoreFile    = "file"
+
+	// Credentials are stored in plain text.
+	SecretStoreNone = "none"
+)
+
+// Public status fields.
+const (
+	PublicStatusSystem   = "system"
+	PublicStatusMonitors = "monitors"
+	PublicStatusStorage  = "storage"
+
+	// Per-monitor CPU and memory usage.
+	PublicStatusResources = "resources"
+)
+
 // ErrPathNotAbsolute path is not absolute.
 var ErrPathNotAbsolute = errors.New("path is not absolute")
 
@@ -318,6 +941,9 @@ func NewConfigEnv(envPath string, envYAML []byte) (*ConfigEnv, error) {
 	if env.FFmpegBin == "" {
 		env.FFmpegBin = "/usr/bin/ffmpeg"
 	}
+	if env.GStreamerBin == "" {
+		env.GStreamerBin = "/usr/bin/gst-launch-1.0"
+	}
 	if env.HomeDir == "" {
 		env.HomeDir = filepath.Dir(env.ConfigDir)
 	}
@@ -338,21 +964,248 @@ func NewConfigEnv(envPath string, envYAML []byte) (*ConfigEnv, error) {
 	if !filepath.IsAbs(env.FFmpegBin) {
 		return nil, fmt.Errorf("ffmpegBin '%v': %w", env.FFmpegBin, ErrPathNotAbsolute)
 	}
+	if !filepath.IsAbs(env.GStreamerBin) {
+		return nil, fmt.Errorf("gstreamerBin '%v': %w", env.GStreamerBin, ErrPathNotAbsolute)
+	}
 	if !filepath.IsAbs(env.HomeDir) {
 		return nil, fmt.Errorf("homeDir '%v': %w", env.HomeDir, ErrPathNotAbsolute)
 	}
 	if !filepath.IsAbs(env.StorageDir) {
 		return nil, fmt.Errorf("StorageDir '%v': %w", env.StorageDir, ErrPathNotAbsolute)
 	}
+	for _, volume := range env.StorageVolumes {
+		if !filepath.IsAbs(volume) {
+			return nil, fmt.Errorf("storageVolumes '%v': %w", volume, ErrPathNotAbsolute)
+		}
+	}
+
+	if env.StorageFailoverDir != "" && !filepath.IsAbs(env.StorageFailoverDir) {
+		return nil, fmt.Errorf("storageFailoverDir '%v': %w", env.StorageFailoverDir, ErrPathNotAbsolute)
+	}
+
+	rtspBind, err := bindHosts("rtsp", env.RTSPBind, env.RTSPPortExpose)
+	if err != nil {
+		return nil, err
+	}
+	env.RTSPBind = rtspBind
+	hlsBind, err := bindHosts("hls", env.HLSBind, env.HLSPortExpose)
+	if err != nil {
+		return nil, err
+	}
+	env.HLSBind = hlsBind
+
+	switch env.StorageStrategy {
+	case "":
+		env.StorageStrategy = StrategySequential
+	case StrategySequential, StrategyRoundRobin:
+	default:
+		return nil, fmt.Errorf("storageStrategy '%v': %w", env.StorageStrategy, ErrInvalidValue)
+	}
+
+	if env.AuthRateLimit.MaxAttemptsIP == 0 {
+		env.AuthRateLimit.MaxAttemptsIP = 10
+	}
+	if env.AuthRateLimit.MaxAttemptsAccount == 0 {
+		env.AuthRateLimit.MaxAttemptsAccount = 20
+	}
+	if env.AuthRateLimit.Window <= 0 {
+		env.AuthRateLimit.Window = 600
+	}
+	if env.AuthRateLimit.Lockout <= 0 {
+		env.AuthRateLimit.Lockout = 900
+	}
+
+	switch env.LogFormat {
+	case "":
+		env.LogFormat = log.FormatText
+	case log.FormatText, log.FormatJSON:
+	default:
+		return nil, fmt.Errorf("logFormat '%v': %w", env.LogFormat, ErrInvalidValue)
+	}
+
+	switch env.SecretStore {
+	case "":
+		env.SecretStore = SecretStoreAuto
+	case SecretStoreAuto, SecretStoreKeyring, SecretStoreFile, SecretStoreNone:
+	default:
+		return nil, fmt.Errorf("secretStore '%v': %w", env.SecretStore, ErrInvalidValue)
+	}
+
+	env.BasePath = strings.TrimRight(env.BasePath, "/")
+	if env.BasePath != "" && (!strings.HasPrefix(env.BasePath, "/") ||
+		path.Clean(env.BasePath) != env.BasePath) {
+		return nil, fmt.Errorf("basePath '%v': %w", env.BasePath, ErrInvalidValue)
+	}
+
+	if env.AlertWebhook != "" {
+		u, err := url.Parse(env.AlertWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("alertWebhook '%v': %w", env.AlertWebhook, ErrInvalidValue)
+		}
+	}
+
+	for _, proxy := range env.TrustedProxies {
+		if _, err := ParseTrustedProxy(proxy); err != nil {
+			return nil, fmt.Errorf("trustedProxies '%v': %w", proxy, ErrInvalidValue)
+		}
+	}
+
+	if err := env.ProxyAuth.validate(env.TrustedProxies); err != nil {
+		return nil, err
+	}
+	if err := env.AccessLog.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.RecordingEncryption.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.CORS.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.TLS.validate(env.HomeDir); err != nil {
+		return nil, err
+	}
+	if err := env.GRPC.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.Ingest.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.Update.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.LiveSessions.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.PasswordPolicy.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.HLSMemory.validate(); err != nil {
+		return nil, err
+	}
+	if err := env.HLSTimeshift.validate(); err != nil {
+		return nil, err
+	}
+
+	for _, field := range env.PublicStatus {
+		switch field {
+		case PublicStatusSystem, PublicStatusMonitors, PublicStatusStorage, PublicStatusResources:
+		default:
+			return nil, fmt.Errorf("publicStatus '%v': %w", field, ErrInvalidValue)
+		}
+	}
 
 	return &env, nil
 }
 
+// bindHosts validates and returns the bind hosts of a service.
+// An empty host listens on all interfaces.
+func bindHosts(service string, hosts []string, expose bool) ([]string, error) {
+	if len(hosts) == 0 {
+		if expose {
+			return []string{""}, nil
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+	if expose {
+		return nil, fmt.Errorf("%vBind and %vPortExpose can't be combined: %w", service, service, ErrInvalidValue)
+	}
+	seen := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return nil, fmt.Errorf("%vBind '%v': %w", service, host, ErrInvalidValue)
+		}
+		if seen[ip.String()] {
+			return nil, fmt.Errorf("%vBind '%v': duplicate: %w", service, host, ErrInvalidValue)
+		}
+		seen[ip.String()] = true
+	}
+	return hosts, nil
+}
+
+func (c *TLS) validate(homeDir string) error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("tls: certFile and keyFile must both be set: %w", ErrInvalidValue)
+	}
+	if c.CertFile != "" && c.Autocert() {
+		return fmt.Errorf("tls: certFile and autocertDomains can't be combined: %w", ErrInvalidValue)
+	}
+	if c.Autocert() && c.AutocertDir == "" {
+		c.AutocertDir = filepath.Join(homeDir, "autocert")
+	}
+	for _, file := range []string{c.CertFile, c.KeyFile, c.AutocertDir} {
+		if file != "" && !filepath.IsAbs(file) {
+			return fmt.Errorf("tls '%v': %w", file, ErrPathNotAbsolute)
+		}
+	}
+	if c.RedirectPort < 0 {
+		return fmt.Errorf("tls: redirectPort '%v': %w", c.RedirectPort, ErrInvalidValue)
+	}
+	return nil
+}
+
+// ParseTrustedProxy parses a IP address or CIDR range.
+func ParseTrustedProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
 // RecordingsDir return recordings directory.
 func (env ConfigEnv) RecordingsDir() string {
 	return filepath.Join(env.StorageDir, "recordings")
 }
 
+// EventClipsDir returns the directory where event clips are saved.
+func (env ConfigEnv) EventClipsDir() string {
+	return filepath.Join(env.StorageDir, "events")
+}
+
+// EventSnapshotsDir returns the directory where event snapshots are saved.
+func (env ConfigEnv) EventSnapshotsDir() string {
+	return filepath.Join(env.StorageDir, "snapshots")
+}
+
+// StorageDirs returns the storage directory followed by the storage volumes.
+func (env ConfigEnv) StorageDirs() []string {
+	return append([]string{env.StorageDir}, env.StorageVolumes...)
+}
+
+// RecordingsDirs returns the recordings directory of every storage volume.
+func (env ConfigEnv) RecordingsDirs() []string {
+	var dirs []string
+	for _, dir := range env.StorageDirs() {
+		dirs = append(dirs, recordingsDir(dir))
+	}
+	return dirs
+}
+
+// SecretKeyPath returns the path of the key file used by the file secret
+// store. It's outside the config directory to keep it out of backups.
+func (env ConfigEnv) SecretKeyPath() string {
+	return filepath.Join(env.HomeDir, "secret.key")
+}
+
+// PublicStatusExposed returns true if the field is exposed by the public status API.
+func (env ConfigEnv) PublicStatusExposed(field string) bool {
+	for _, f := range env.PublicStatus {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
 // PrepareEnvironment prepares directories.
 func (env ConfigEnv) PrepareEnvironment() error {
 	err := os.MkdirAll(env.RecordingsDir(), 0o700)
@@ -472,40 +1325,56 @@ func (general *ConfigGeneral) DiskSpace() (int64, error) {
 }
 
 // DeleteRecording delete a recording by ID.
-// Will return os.ErrNotExist if the recording doesn't exists.
+// Will return os.ErrNotExist if the recording doesn't exists,
+// ErrRecordingProtected if the recording is protected and
+// ErrRecordingLocked if the recording is being read.
 func DeleteRecording(recordingsDir, recID string) error {
 	// RecordingIDToPath will validate the ID.
 	recPath, err := RecordingIDToPath(recID)
 	if err != nil {
 		return fmt.Errorf("recording id to path: %q %w", recID, err)
 	}
+	if RecordingLocked(recID) {
+		return fmt.Errorf("%w: %v", ErrRecordingLocked, recID)
+	}
+
+	protected, err := RecordingProtected(recordingsDir, recID)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return fmt.Errorf("%w: %v", ErrRecordingProtected, recID)
+	}
 
 	fullRecPath := filepath.Join(recordingsDir, recPath)
 	recDir := filepath.Dir(fullRecPath)
 
-	var returnedError error
-	recordingExists := false
-	entries, err := fs.ReadDir(os.DirFS(recDir), ".")
-	if err != nil {
-		return fmt.Errorf("read directory: %q %w", recDir, err)
-	}
-	for _, entry := range entries {
-		name := entry.Name()
-		if !strings.HasPrefix(name, recID) {
-			continue
-		}
-		path := filepath.Join(recDir, name)
-		err := os.Remove(path)
+	// The recording may have been locked after the check above.
+	return recordingLocks.tryDelete([]string{recID}, func() error {
+		var returnedError error
+		recordingExists := false
+		entries, err := fs.ReadDir(os.DirFS(recDir), ".")
 		if err != nil {
-			returnedError = fmt.Errorf("delete file: %q %w", path, err)
+			return fmt.Errorf("read directory: %q %w", recDir, err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, recID) {
+				continue
+			}
+			path := filepath.Join(recDir, name)
+			err := os.Remove(path)
+			if err != nil {
+				returnedError = fmt.Errorf("delete file: %q %w", path, err)
+			}
+			recordingExists = true
 		}
-		recordingExists = true
-	}
 
-	if !recordingExists {
-		return os.ErrNotExist
-	}
-	return returnedError
+		if !recordingExists {
+			return os.ErrNotExist
+		}
+		return returnedError
+	})
 }
 
 func dirExist(path string) bool {
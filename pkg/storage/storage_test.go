@@ -370,15 +370,100 @@ func newTestEnv(t *testing.T) (string, *ConfigEnv, func()) {
 	require.NoError(t, err)
 
 	env := &ConfigEnv{
-		Port:       2020,
-		RTSPPort:   2021,
-		HLSPort:    2022,
-		GoBin:      goBin,
-		FFmpegBin:  ffmpegBin,
-		StorageDir: filepath.Join(homeDir, "storage"),
-		TempDir:    filepath.Join(homeDir, "nvr"),
-		HomeDir:    homeDir,
-		ConfigDir:  configDir,
+		Port:         2020,
+		RTSPPort:     2021,
+		HLSPort:      2022,
+		RTSPBind:     []string{"127.0.0.1", "::1"},
+		HLSBind:      []string{"::"},
+		GoBin:        goBin,
+		FFmpegBin:    ffmpegBin,
+		GStreamerBin: "/usr/bin/gst-launch-1.0",
+		StorageDir:   filepath.Join(homeDir, "storage"),
+		TempDir:      filepath.Join(homeDir, "nvr"),
+
+		StorageVolumes:  []string{filepath.Join(homeDir, "volume2")},
+		StorageStrategy: StrategyRoundRobin,
+
+		PublicStatus: []string{PublicStatusSystem, PublicStatusStorage},
+		LogEventRules: []log.PromotionRule{{
+			Name:    "a",
+			Sources: []string{"monitor"},
+			Levels:  []string{"error"},
+			Pattern: "crashed",
+			Count:   3,
+			Window:  60,
+		}},
+		LogFormat: log.FormatJSON,
+		LogForward: []log.ForwardConfig{{
+			Network: "udp",
+			Address: "127.0.0.1:514",
+			Format:  log.FormatSyslog,
+			Sources: []string{"app"},
+			Levels:  []string{"error", "warning"},
+		}},
+		AccessLog: AccessLog{
+			Enable:        true,
+			HLSSampleRate: 10,
+		},
+		AlertWebhook: "https://example.com/hook",
+		AuthRateLimit: AuthRateLimit{
+			MaxAttemptsIP:      5,
+			MaxAttemptsAccount: -1,
+			Window:             60,
+			Lockout:            120,
+		},
+		SecretStore: SecretStoreFile,
+		RecordingEncryption: RecordingEncryption{
+			KeyCommand:      []string{"/usr/bin/nvr-keys", "get"},
+			PlainThumbnails: true,
+		},
+		BasePath:       "/nvr",
+		TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "::1"},
+		ProxyAuth: ProxyAuth{
+			Enable:        true,
+			UserHeader:    "X-Forwarded-User",
+			GroupsHeader:  "X-Forwarded-Groups",
+			AdminGroups:   []string{"admins"},
+			UserGroups:    []string{"nvr"},
+			AutoProvision: true,
+			LogoutURL:     "https://auth.example.com/logout",
+		},
+		CORS: CORS{
+			AllowedOrigins:   []string{"https://example.com"},
+			AllowCredentials: true,
+			MaxAge:           60,
+		},
+		TLS: TLS{
+			AutocertDomains: []string{"example.com"},
+			AutocertEmail:   "a@example.com",
+			AutocertDir:     filepath.Join(homeDir, "certs"),
+			RedirectPort:    80,
+		},
+		GRPC: GRPC{
+			Port:   2023,
+			Tokens: []string{"0123456789abcdef"},
+		},
+		Ingest: Ingest{
+			RTMPPort: 1935,
+			RTMPBind: []string{"::"},
+			Streams:  map[string]string{"cam1": "0123456789abcdef"},
+		},
+		Update: Update{
+			URL:       "https://example.com/releases/latest.json",
+			PublicKey: "MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqo=",
+		},
+		LiveSessions: LiveSessions{
+			Limit: 4,
+			Users: map[string]int{"a": 8},
+		},
+		PasswordPolicy: PasswordPolicy{
+			MinLength:    12,
+			RequireUpper: true,
+			RequireDigit: true,
+		},
+
+		HomeDir:   homeDir,
+		ConfigDir: configDir,
 	}
 
 	return envPath, env, cancelFunc
@@ -401,15 +486,44 @@ func TestNewConfigEnv(t *testing.T) {
 		require.NoError(t, err)
 
 		expected := ConfigEnv{
-			Port:       2020,
-			RTSPPort:   2021,
-			HLSPort:    2022,
-			GoBin:      filepath.Join(homeDir, "go"),
-			FFmpegBin:  filepath.Join(homeDir, "ffmpeg"),
-			StorageDir: filepath.Join(homeDir, "storage"),
-			TempDir:    env.TempDir,
-			HomeDir:    homeDir,
-			ConfigDir:  filepath.Join(homeDir, "configs"),
+			Port:         2020,
+			RTSPPort:     2021,
+			HLSPort:      2022,
+			RTSPBind:     []string{"127.0.0.1"},
+			HLSBind:      []string{"127.0.0.1"},
+			GoBin:        filepath.Join(homeDir, "go"),
+			FFmpegBin:    filepath.Join(homeDir, "ffmpeg"),
+			GStreamerBin: "/usr/bin/gst-launch-1.0",
+			StorageDir:   filepath.Join(homeDir, "storage"),
+			TempDir:      env.TempDir,
+
+			StorageVolumes:  []string{},
+			StorageStrategy: StrategySequential,
+
+			PublicStatus:  []string{},
+			LogEventRules: []log.PromotionRule{},
+			LogFormat:     log.FormatText,
+			LogForward:    []log.ForwardConfig{},
+			AccessLog:     AccessLog{HLSSampleRate: DefaultAccessLogHLSSampleRate},
+			AuthRateLimit: AuthRateLimit{
+				MaxAttemptsIP:      10,
+				MaxAttemptsAccount: 20,
+				Window:             600,
+				Lockout:            900,
+			},
+			SecretStore:         SecretStoreAuto,
+			RecordingEncryption: RecordingEncryption{KeyCommand: []string{}},
+			TrustedProxies:      []string{},
+			ProxyAuth:           ProxyAuth{AdminGroups: []string{}, UserGroups: []string{}},
+			CORS:                CORS{AllowedOrigins: []string{}, MaxAge: DefaultCORSMaxAge},
+			TLS:                 TLS{AutocertDomains: []string{}},
+			GRPC:                GRPC{Tokens: []string{}},
+			Ingest:              Ingest{RTMPBind: []string{""}, Streams: map[string]string{}},
+			LiveSessions:        LiveSessions{Users: map[string]int{}},
+			PasswordPolicy:      PasswordPolicy{MinLength: DefaultPasswordMinLength},
+
+			HomeDir:   homeDir,
+			ConfigDir: filepath.Join(homeDir, "configs"),
 		}
 		require.Equal(t, *env, expected)
 	})
@@ -490,6 +604,483 @@ func TestNewConfigEnv(t *testing.T) {
 		_, err = NewConfigEnv(envPath, envYAML)
 		require.ErrorIs(t, err, ErrPathNotAbsolute)
 	})
+	t.Run("gstreamerBinAbs", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.GStreamerBin = "."
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrPathNotAbsolute)
+	})
+	t.Run("storageVolumesAbs", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.StorageVolumes = []string{"."}
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrPathNotAbsolute)
+	})
+	t.Run("storageFailoverDirAbs", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.StorageFailoverDir = "."
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrPathNotAbsolute)
+	})
+	t.Run("bindExpose", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.RTSPBind = nil
+		testEnv.RTSPPortExpose = true
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		env, err := NewConfigEnv(envPath, envYAML)
+		require.NoError(t, err)
+		require.Equal(t, []string{""}, env.RTSPBind)
+	})
+	t.Run("bindExposeErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.HLSPortExpose = true
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("bindErr", func(t *testing.T) {
+		for _, hosts := range [][]string{{"x"}, {"127.0.0.1:2021"}, {"::1", "::1"}} {
+			envPath, testEnv, cancel := newTestEnv(t)
+			defer cancel()
+
+			testEnv.RTSPBind = hosts
+
+			envYAML, err := yaml.Marshal(testEnv)
+			require.NoError(t, err)
+
+			_, err = NewConfigEnv(envPath, envYAML)
+			require.ErrorIs(t, err, ErrInvalidValue, hosts)
+		}
+	})
+	t.Run("storageStrategyErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.StorageStrategy = "x"
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("alertWebhookErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.AlertWebhook = "example.com/hook"
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("logFormatErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.LogFormat = "x"
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("secretStoreErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.SecretStore = "x"
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("basePathTrailingSlash", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.BasePath = "/nvr/"
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		env, err := NewConfigEnv(envPath, envYAML)
+		require.NoError(t, err)
+		require.Equal(t, "/nvr", env.BasePath)
+	})
+	t.Run("basePathErr", func(t *testing.T) {
+		for _, basePath := range []string{"nvr", "/a/../b", "/a//b"} {
+			envPath, testEnv, cancel := newTestEnv(t)
+			defer cancel()
+
+			testEnv.BasePath = basePath
+
+			envYAML, err := yaml.Marshal(testEnv)
+			require.NoError(t, err)
+
+			_, err = NewConfigEnv(envPath, envYAML)
+			require.ErrorIs(t, err, ErrInvalidValue, basePath)
+		}
+	})
+	t.Run("trustedProxiesErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.TrustedProxies = []string{"127.0.0.1", "x"}
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("proxyAuth", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.TrustedProxies = []string{"127.0.0.1"}
+		testEnv.ProxyAuth = ProxyAuth{Enable: true}
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		env, err := NewConfigEnv(envPath, envYAML)
+		require.NoError(t, err)
+		require.Equal(t, "Remote-User", env.ProxyAuth.UserHeader)
+		require.Equal(t, "Remote-Groups", env.ProxyAuth.GroupsHeader)
+	})
+	t.Run("proxyAuthErr", func(t *testing.T) {
+		cases := map[string]struct {
+			trustedProxies []string
+			proxyAuth      ProxyAuth
+		}{
+			"noTrustedProxies": {nil, ProxyAuth{Enable: true}},
+			"logoutURL": {
+				[]string{"127.0.0.1"},
+				ProxyAuth{Enable: true, LogoutURL: "/logout"},
+			},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.TrustedProxies = tc.trustedProxies
+				testEnv.ProxyAuth = tc.proxyAuth
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("tlsAutocertDir", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.TLS.AutocertDir = ""
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		env, err := NewConfigEnv(envPath, envYAML)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(testEnv.HomeDir, "autocert"), env.TLS.AutocertDir)
+	})
+	t.Run("tlsErr", func(t *testing.T) {
+		cases := map[string]struct {
+			tls TLS
+			err error
+		}{
+			"keyMissing":  {TLS{CertFile: "/a"}, ErrInvalidValue},
+			"certMissing": {TLS{KeyFile: "/a"}, ErrInvalidValue},
+			"combined": {
+				TLS{CertFile: "/a", KeyFile: "/b", AutocertDomains: []string{"x"}},
+				ErrInvalidValue,
+			},
+			"certAbs":      {TLS{CertFile: "a", KeyFile: "/b"}, ErrPathNotAbsolute},
+			"redirectPort": {TLS{RedirectPort: -1}, ErrInvalidValue},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.TLS = tc.tls
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, tc.err)
+			})
+		}
+	})
+	t.Run("grpcErr", func(t *testing.T) {
+		cases := map[string]GRPC{
+			"port":  {Port: -1},
+			"token": {Tokens: []string{"short"}},
+		}
+		for name, grpc := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.GRPC = grpc
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("ingestErr", func(t *testing.T) {
+		key := "0123456789abcdef"
+		cases := map[string]Ingest{
+			"port": {RTMPPort: -1},
+			"bind": {RTMPBind: []string{"x"}},
+			"name": {Streams: map[string]string{"a/b": key}},
+			"key":  {Streams: map[string]string{"a": "short"}},
+		}
+		for name, ingest := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.Ingest = ingest
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("updateErr", func(t *testing.T) {
+		key := "MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqo="
+		cases := map[string]Update{
+			"http":      {URL: "http://example.com/latest.json", PublicKey: key},
+			"host":      {URL: "https:///latest.json", PublicKey: key},
+			"noKey":     {URL: "https://example.com/latest.json"},
+			"keyBase64": {URL: "https://example.com/latest.json", PublicKey: "x"},
+			"keySize":   {URL: "https://example.com/latest.json", PublicKey: "AAAA"},
+		}
+		for name, update := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.Update = update
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("liveSessionsErr", func(t *testing.T) {
+		cases := map[string]LiveSessions{
+			"limit": {Limit: -1},
+			"user":  {Users: map[string]int{"a": -1}},
+		}
+		for name, liveSessions := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.LiveSessions = liveSessions
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("passwordPolicyErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.PasswordPolicy.MinLength = -1
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("hlsMemoryErr", func(t *testing.T) {
+		cases := map[string]HLSMemory{
+			"limit":     {Limit: -1},
+			"pathLimit": {PathLimit: -1},
+		}
+		for name, hlsMemory := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.HLSMemory = hlsMemory
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("hlsTimeshiftErr", func(t *testing.T) {
+		cases := map[string]struct {
+			timeshift HLSTimeshift
+			expected  error
+		}{
+			"negative": {HLSTimeshift{Minutes: -1}, ErrInvalidValue},
+			"tooLong":  {HLSTimeshift{Minutes: 24*60 + 1}, ErrInvalidValue},
+			"dir":      {HLSTimeshift{Minutes: 1, Dir: "shm"}, ErrPathNotAbsolute},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.HLSTimeshift = tc.timeshift
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, tc.expected)
+			})
+		}
+	})
+	t.Run("cors", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.CORS = CORS{
+			AllowedOrigins: []string{"HTTPS://a.example.com/", "http://b:8080"},
+		}
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		env, err := NewConfigEnv(envPath, envYAML)
+		require.NoError(t, err)
+		require.Equal(t, CORS{
+			AllowedOrigins: []string{"https://a.example.com", "http://b:8080"},
+			MaxAge:         DefaultCORSMaxAge,
+		}, env.CORS)
+	})
+	t.Run("accessLogErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.AccessLog = AccessLog{Enable: true, HLSSampleRate: -1}
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
+	t.Run("recordingEncryptionErr", func(t *testing.T) {
+		cases := map[string]RecordingEncryption{
+			"relative": {KeyFile: "keys.yaml"},
+			"both":     {KeyFile: "/keys.yaml", KeyCommand: []string{"x"}},
+			"command":  {KeyCommand: []string{""}},
+		}
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.RecordingEncryption = c
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("corsErr", func(t *testing.T) {
+		cases := map[string]CORS{
+			"path":        {AllowedOrigins: []string{"https://a.example.com/x"}},
+			"scheme":      {AllowedOrigins: []string{"ftp://a.example.com"}},
+			"noHost":      {AllowedOrigins: []string{"a.example.com"}},
+			"query":       {AllowedOrigins: []string{"https://a.example.com?x"}},
+			"credentials": {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			"maxAge":      {MaxAge: -1},
+		}
+		for name, cors := range cases {
+			t.Run(name, func(t *testing.T) {
+				envPath, testEnv, cancel := newTestEnv(t)
+				defer cancel()
+
+				testEnv.CORS = cors
+
+				envYAML, err := yaml.Marshal(testEnv)
+				require.NoError(t, err)
+
+				_, err = NewConfigEnv(envPath, envYAML)
+				require.ErrorIs(t, err, ErrInvalidValue)
+			})
+		}
+	})
+	t.Run("publicStatusErr", func(t *testing.T) {
+		envPath, testEnv, cancel := newTestEnv(t)
+		defer cancel()
+
+		testEnv.PublicStatus = []string{PublicStatusSystem, "x"}
+
+		envYAML, err := yaml.Marshal(testEnv)
+		require.NoError(t, err)
+
+		_, err = NewConfigEnv(envPath, envYAML)
+		require.ErrorIs(t, err, ErrInvalidValue)
+	})
 	t.Run("homeDirAbs", func(t *testing.T) {
 		envPath, testEnv, cancel := newTestEnv(t)
 		defer cancel()
@@ -715,6 +1306,21 @@ func TestDeleteRecording(t *testing.T) {
 		err := DeleteRecording(recordingsDir, "2000-01-01_02-02-02_m1")
 		require.ErrorIs(t, err, os.ErrNotExist)
 	})
+	t.Run("lockedErr", func(t *testing.T) {
+		recordingsDir := t.TempDir()
+		recDir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+		recID := "2000-01-01_02-02-02_m1"
+		require.NoError(t, os.MkdirAll(recDir, 0o700))
+		createFiles(t, recDir, []string{recID + ".json", recID + ".mp4"})
+
+		unlock := LockRecordings(recID)
+		err := DeleteRecording(recordingsDir, recID)
+		require.ErrorIs(t, err, ErrRecordingLocked)
+		require.Len(t, listDirectory(t, recDir), 2)
+
+		unlock()
+		require.NoError(t, DeleteRecording(recordingsDir, recID))
+	})
 }
 
 func createFiles(t *testing.T, dir string, paths []string) {
@@ -11,8 +11,12 @@ import (
 // `.mp4`, `.jpeg` or `.json` can be appended to the
 // path to get the video, thumbnail or data file.
 type Recording struct {
-	ID   string         `json:"id"`
-	Data *RecordingData `json:"data"`
+	ID        string         `json:"id"`
+	Protected bool           `json:"protected"`
+	Data      *RecordingData `json:"data"`
+
+	// RecordingStatusCorrupt if the recording failed verification.
+	Status string `json:"status,omitempty"`
 }
 
 // RecordingData recording data marshaled to json and saved next to video and thumbnail.
@@ -20,6 +24,32 @@ type RecordingData struct {
 	Start  time.Time `json:"start"`
 	End    time.Time `json:"end"`
 	Events []Event   `json:"events"`
+
+	// Seconds of the recording that contained events, see NewActivityIndex.
+	Activity []ActivityRange `json:"activity,omitempty"`
+
+	// Set if the monitor was in maintenance during the recording.
+	Maintenance       bool   `json:"maintenance,omitempty"`
+	MaintenanceReason string `json:"maintenanceReason,omitempty"`
+
+	// Schema version, see RecordingDataVersion.
+	Version int `json:"version,omitempty"`
+
+	// Summary of the events, sorted and without duplicates.
+	Triggers []string `json:"triggers,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+	Zones    []string `json:"zones,omitempty"`
+
+	// Length of the video.
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Resolution of the video.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	// Set if the data was written by RecoverRecordings after a
+	// crash. The events of recovered recordings are lost.
+	Recovered bool `json:"recovered,omitempty"`
 }
 
 // Events .
@@ -46,6 +76,7 @@ func (e *Events) QueryAndPrune(start, end time.Time) Events {
 // Event is a recording trigger event.
 type Event struct {
 	Time        time.Time     `json:"time,omitempty"`
+	Trigger     string        `json:"trigger,omitempty"`
 	Detections  []Detection   `json:"detections,omitempty"`
 	Duration    time.Duration `json:"duration,omitempty"`
 	RecDuration time.Duration `json:"-"`
@@ -75,6 +106,12 @@ type Detection struct {
 	Label  string  `json:"label,omitempty"`
 	Score  float64 `json:"score,omitempty"`
 	Region *Region `json:"region,omitempty"`
+
+	// Detection zone that triggered, if the detector has zones.
+	Zone string `json:"zone,omitempty"`
+
+	// Recognized text, for example the number of a license plate.
+	Text string `json:"text,omitempty"`
 }
 
 // Region where detection occurred.
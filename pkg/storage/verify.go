@@ -0,0 +1,353 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"nvr/pkg/crypt"
+	"nvr/pkg/log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unrecoverable recordings have a marker file next to the video,
+// "YYYY-MM-DD_hh-mm-ss_monitor.corrupt", that contains the reason.
+const corruptExt = ".corrupt"
+
+// RecordingStatusCorrupt is the status of recordings that
+// failed verification and couldn't be repaired.
+const RecordingStatusCorrupt = "corrupt"
+
+// Recording verification errors.
+var (
+	ErrZeroLength   = errors.New("zero-length file")
+	ErrTruncatedBox = errors.New("truncated box")
+	ErrMissingMoov  = errors.New("missing moov box")
+
+	ErrRepairEncrypted = errors.New("encrypted files can't be repaired")
+)
+
+// checkMP4 reads the top level boxes of the file and returns a error if
+// the file is empty, a box extends past the end of the file or the file
+// doesn't have a "moov" box. Usually caused by power loss while writing.
+func checkMP4(path string) error {
+	file, err := crypt.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fileSize := uint64(file.Size())
+	if fileSize == 0 {
+		return ErrZeroLength
+	}
+
+	var hasMoov bool
+	header := make([]byte, 16)
+	for offset := uint64(0); offset < fileSize; {
+		if _, err := file.ReadAt(header[:8], int64(offset)); err != nil {
+			return fmt.Errorf("%w: header at %v", ErrTruncatedBox, offset)
+		}
+		size := uint64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerSize := uint64(8)
+
+		switch size {
+		case 0: // Box extends to end of file.
+			size = fileSize - offset
+		case 1: // 64 bit size.
+			if _, err := file.ReadAt(header[8:16], int64(offset)+8); err != nil {
+				return fmt.Errorf("%w: %q header at %v", ErrTruncatedBox, boxType, offset)
+			}
+			size = binary.BigEndian.Uint64(header[8:16])
+			headerSize = 16
+		}
+		if size < headerSize {
+			return fmt.Errorf("%w: %q at %v", ErrInvalidBoxSize, boxType, offset)
+		}
+		if size > fileSize-offset {
+			return fmt.Errorf("%w: %q at %v", ErrTruncatedBox, boxType, offset)
+		}
+		if boxType == "moov" {
+			hasMoov = true
+		}
+		offset += size
+	}
+	if !hasMoov {
+		return ErrMissingMoov
+	}
+	return nil
+}
+
+// checkMeta returns a error if the meta or mdat file is empty.
+// The meta format doesn't have a index that can be truncated.
+func checkMeta(recordingPath string) error {
+	for _, ext := range []string{".meta", ".mdat"} {
+		info, err := os.Stat(recordingPath + ext)
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return fmt.Errorf("%v: %w", ext, ErrZeroLength)
+		}
+	}
+	return nil
+}
+
+// corruptIDs returns the IDs of the corrupt recordings in the entries.
+func corruptIDs(entries []fs.DirEntry) map[string]struct{} {
+	ids := make(map[string]struct{})
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), corruptExt) {
+			ids[recordingIDFromFile(entry.Name())] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// VerifyReport is the result of the last verification.
+type VerifyReport struct {
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Number of recordings that were checked, recordings
+	// that passed a previous run aren't checked again.
+	Checked  int      `json:"checked"`
+	Repaired []string `json:"repaired"`
+	Corrupt  []string `json:"corrupt"`
+}
+
+// remuxFunc copies the streams of src into a new MP4 file at dst.
+type remuxFunc func(ctx context.Context, src string, dst string) error
+
+func newFFmpegRemux(bin string) remuxFunc {
+	return func(ctx context.Context, src string, dst string) error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, bin,
+			"-y", "-loglevel", "error", "-i", src,
+			"-c", "copy", "-movflags", "+faststart", "-f", "mp4", dst)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
+
+// Verifier checks finished recordings in the background. MP4 files
+// with a truncated or missing "moov" box are remuxed with FFmpeg.
+// Recordings that can't be repaired are marked as corrupt.
+type Verifier struct {
+	recordingsDirs []string
+	remux          remuxFunc
+	logger         log.ILogger
+	trigger        chan struct{}
+
+	// Size and modification time of the files that passed.
+	verified map[string]verifiedFile
+
+	report VerifyReport
+	mu     sync.Mutex
+}
+
+type verifiedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewVerifier returns a verifier for the recordings directories.
+func NewVerifier(recordingsDirs []string, ffmpegBin string, logger log.ILogger) *Verifier {
+	return &Verifier{
+		recordingsDirs: recordingsDirs,
+		remux:          newFFmpegRemux(ffmpegBin),
+		logger:         logger,
+		trigger:        make(chan struct{}, 1),
+		verified:       make(map[string]verifiedFile),
+	}
+}
+
+// Run verifies the recordings on an interval, or when
+// triggered, until the context is canceled.
+func (v *Verifier) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		case <-v.trigger:
+		}
+		v.verify(ctx)
+	}
+}
+
+// Trigger starts a verification. Returns false if one is already pending.
+func (v *Verifier) Trigger() bool {
+	select {
+	case v.trigger <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Report returns the report of the running or last verification.
+func (v *Verifier) Report() VerifyReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	report := v.report
+	report.Repaired = append([]string{}, report.Repaired...)
+	report.Corrupt = append([]string{}, report.Corrupt...)
+	return report
+}
+
+func (v *Verifier) verify(ctx context.Context) {
+	v.mu.Lock()
+	v.report = VerifyReport{Running: true, Started: time.Now()}
+	v.mu.Unlock()
+
+	for _, dir := range v.recordingsDirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			if recPath, isMP4 := strings.CutSuffix(path, ".mp4"); isMP4 {
+				v.verifyRecording(ctx, recPath, true)
+			} else if recPath, isMeta := strings.CutSuffix(path, ".meta"); isMeta {
+				v.verifyRecording(ctx, recPath, false)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			v.logf(log.LevelError, "verify recordings: %v", err)
+		}
+	}
+
+	v.mu.Lock()
+	v.report.Running = false
+	v.report.Finished = time.Now()
+	v.mu.Unlock()
+}
+
+func (v *Verifier) verifyRecording(ctx context.Context, recPath string, isMP4 bool) {
+	// The data file is written when the recording is finished.
+	if !fileExist(recPath+".json") || fileExist(recPath+corruptExt) {
+		return
+	}
+	videoPath := recPath + ".meta"
+	if isMP4 {
+		videoPath = recPath + ".mp4"
+	}
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return
+	}
+	stat := verifiedFile{size: info.Size(), modTime: info.ModTime()}
+	if v.verified[videoPath] == stat {
+		return
+	}
+
+	v.mu.Lock()
+	v.report.Checked++
+	v.mu.Unlock()
+
+	recID := filepath.Base(recPath)
+	// The recording may be replaced by the repair.
+	defer LockRecordings(recID)()
+	if !isMP4 {
+		if err := checkMeta(recPath); err != nil {
+			v.markCorrupt(recPath, err)
+			return
+		}
+		v.verified[videoPath] = stat
+		return
+	}
+
+	checkErr := checkMP4(videoPath)
+	if checkErr == nil {
+		v.verified[videoPath] = stat
+		return
+	}
+	if errors.Is(checkErr, ErrZeroLength) {
+		v.markCorrupt(recPath, checkErr)
+		return
+	}
+
+	v.logf(log.LevelWarning, "recording %v: %v, trying to repair", recID, checkErr)
+	if err := v.repair(ctx, videoPath); err != nil {
+		if ctx.Err() == nil {
+			v.markCorrupt(recPath, fmt.Errorf("%w, repair: %v", checkErr, err))
+		}
+		return
+	}
+	if info, err := os.Stat(videoPath); err == nil {
+		v.verified[videoPath] = verifiedFile{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	v.mu.Lock()
+	v.report.Repaired = append(v.report.Repaired, recID)
+	v.mu.Unlock()
+	v.logf(log.LevelInfo, "recording %v: repaired", recID)
+}
+
+// repair remuxes the file into a temporary file and
+// replaces the original if the new file is valid.
+func (v *Verifier) repair(ctx context.Context, path string) error {
+	// FFmpeg can't read the file and would write it unencrypted.
+	encrypted, err := crypt.IsEncrypted(path)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return ErrRepairEncrypted
+	}
+
+	tmpPath := path + ".repair"
+	defer os.Remove(tmpPath)
+
+	if err := v.remux(ctx, path, tmpPath); err != nil {
+		return err
+	}
+	if err := checkMP4(tmpPath); err != nil {
+		return fmt.Errorf("remuxed file: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (v *Verifier) markCorrupt(recPath string, reason error) {
+	recID := filepath.Base(recPath)
+	v.logf(log.LevelError, "recording %v is corrupt: %v", recID, reason)
+	if err := markRecordingCorrupt(recPath, reason); err != nil {
+		v.logf(log.LevelError, "mark recording %v as corrupt: %v", recID, err)
+	}
+
+	v.mu.Lock()
+	v.report.Corrupt = append(v.report.Corrupt, recID)
+	v.mu.Unlock()
+}
+
+func (v *Verifier) logf(level log.Level, format string, a ...interface{}) {
+	v.logger.Log(log.Entry{
+		Level: level,
+		Src:   "app",
+		Msg:   fmt.Sprintf(format, a...),
+	})
+}
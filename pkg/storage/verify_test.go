@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mp4Box(typ string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], typ)
+	return append(box, payload...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestCheckMP4(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("isom"))
+	moov := mp4Box("moov", make([]byte, 10))
+	mdat := mp4Box("mdat", make([]byte, 20))
+	mdatToEnd := []byte{0, 0, 0, 0, 'm', 'd', 'a', 't', 1, 2, 3}
+	largeMdat := []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0, 0, 0, 0, 0, 0, 18, 1, 2}
+
+	cases := map[string]struct {
+		data     []byte
+		expected error
+	}{
+		"ok":          {concat(ftyp, moov, mdat), nil},
+		"moovLast":    {concat(ftyp, mdat, moov), nil},
+		"sizeToEnd":   {concat(ftyp, moov, mdatToEnd), nil},
+		"largeSize":   {concat(ftyp, moov, largeMdat), nil},
+		"zeroLength":  {nil, ErrZeroLength},
+		"missingMoov": {concat(ftyp, mdat), ErrMissingMoov},
+		"truncated":   {concat(ftyp, mdat, moov)[:len(ftyp)+len(mdat)+12], ErrTruncatedBox},
+		"header":      {concat(ftyp, mdat, moov[:4]), ErrTruncatedBox},
+		"invalidSize": {concat(ftyp, []byte{0, 0, 0, 4, 'm', 'o', 'o', 'v'}), ErrInvalidBoxSize},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "x.mp4")
+			require.NoError(t, os.WriteFile(path, tc.data, 0o600))
+			err := checkMP4(path)
+			if tc.expected == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	valid := concat(mp4Box("ftyp", nil), mp4Box("moov", nil), mp4Box("mdat", nil))
+	truncated := valid[:len(valid)-4]
+	noMoov := concat(mp4Box("ftyp", nil), mp4Box("mdat", nil))
+
+	recordingsDir := t.TempDir()
+	dir := filepath.Join(recordingsDir, "2000", "01", "02", "m1")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	writeRec := func(id string, ext string, data []byte) {
+		path := filepath.Join(dir, id)
+		require.NoError(t, os.WriteFile(path+ext, data, 0o600))
+		require.NoError(t, os.WriteFile(path+".json", []byte("{}"), 0o600))
+	}
+	writeRec("2000-01-02_00-00-01_m1", ".mp4", valid)
+	writeRec("2000-01-02_00-00-02_m1", ".mp4", truncated)
+	writeRec("2000-01-02_00-00-03_m1", ".mp4", noMoov)
+	writeRec("2000-01-02_00-00-04_m1", ".mp4", nil)
+	writeRec("2000-01-02_00-00-05_m1", ".meta", nil)
+
+	// Unfinished recordings are skipped.
+	unfinished := filepath.Join(dir, "2000-01-02_00-00-06_m1.mp4")
+	require.NoError(t, os.WriteFile(unfinished, nil, 0o600))
+
+	v := NewVerifier([]string{recordingsDir}, "", log.NewDummyLogger())
+	// The fake remux can't recover files without a moov box.
+	v.remux = func(_ context.Context, src string, dst string) error {
+		if errors.Is(checkMP4(src), ErrMissingMoov) {
+			return os.WriteFile(dst, noMoov, 0o600)
+		}
+		return os.WriteFile(dst, valid, 0o600)
+	}
+	v.verify(context.Background())
+
+	report := v.Report()
+	require.False(t, report.Running)
+	require.Equal(t, 5, report.Checked)
+	require.Equal(t, []string{"2000-01-02_00-00-02_m1"}, report.Repaired)
+	require.Equal(t, []string{
+		"2000-01-02_00-00-03_m1",
+		"2000-01-02_00-00-04_m1",
+		"2000-01-02_00-00-05_m1",
+	}, report.Corrupt)
+
+	repaired, err := os.ReadFile(filepath.Join(dir, "2000-01-02_00-00-02_m1.mp4"))
+	require.NoError(t, err)
+	require.Equal(t, valid, repaired)
+
+	reason, err := os.ReadFile(filepath.Join(dir, "2000-01-02_00-00-03_m1.corrupt"))
+	require.NoError(t, err)
+	require.Contains(t, string(reason), "missing moov box, repair: remuxed file: missing moov box")
+
+	// Verified and corrupt recordings aren't checked again.
+	v.verify(context.Background())
+	require.Equal(t, 0, v.Report().Checked)
+
+	t.Run("crawler", func(t *testing.T) {
+		recordings, err := NewCrawler(os.DirFS(recordingsDir)).RecordingByQuery(&CrawlerQuery{
+			Time:  "2000-01-02_00-00-04_m1",
+			Limit: 2,
+		})
+		require.NoError(t, err)
+		require.Len(t, recordings, 2)
+		require.Equal(t, "2000-01-02_00-00-03_m1", recordings[0].ID)
+		require.Equal(t, RecordingStatusCorrupt, recordings[0].Status)
+		require.Equal(t, "2000-01-02_00-00-02_m1", recordings[1].ID)
+		require.Empty(t, recordings[1].Status)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"nvr/pkg/crypt"
 	"nvr/pkg/video/customformat"
 	"nvr/pkg/video/mp4muxer"
 	"os"
@@ -23,34 +24,20 @@ type VideoReader struct {
 	i int64 // current reading index
 
 	modTime time.Time
+	index   *VideoIndex
 }
 
 // NewVideoReader creates a video reader.
 // Caller must call Close() when done.
 func NewVideoReader(recordingPath string, cache *VideoCache) (*VideoReader, error) {
-	metaPath := recordingPath + ".meta"
 	mdatPath := recordingPath + ".mdat"
 
-	var meta *videoMetadata
-	var err error
-	if cache != nil {
-		var exist bool
-		meta, exist = cache.get(recordingPath)
-		if !exist {
-			meta, err = readVideoMetadata(metaPath)
-			if err != nil {
-				return nil, err
-			}
-			cache.add(recordingPath, meta)
-		}
-	} else {
-		meta, err = readVideoMetadata(metaPath)
-		if err != nil {
-			return nil, err
-		}
+	meta, err := loadVideoMetadata(recordingPath, cache)
+	if err != nil {
+		return nil, err
 	}
 
-	mdat, err := os.Open(mdatPath)
+	mdat, err := crypt.Open(mdatPath)
 	if err != nil {
 		return nil, fmt.Errorf("open mdat file: %w", err)
 	}
@@ -63,26 +50,38 @@ func NewVideoReader(recordingPath string, cache *VideoCache) (*VideoReader, erro
 		mdatSize: meta.mdatSize,
 
 		modTime: meta.modTime,
+		index:   meta.index,
 	}, nil
 }
 
+func loadVideoMetadata(recordingPath string, cache *VideoCache) (*videoMetadata, error) {
+	metaPath := recordingPath + ".meta"
+	if cache == nil {
+		return readVideoMetadata(metaPath)
+	}
+
+	meta, exist := cache.get(recordingPath)
+	if exist {
+		return meta, nil
+	}
+	meta, err := readVideoMetadata(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	cache.add(recordingPath, meta)
+	return meta, nil
+}
+
 func readVideoMetadata(metaPath string) (*videoMetadata, error) {
 	metaStat, err := os.Stat(metaPath)
 	if err != nil {
 		return nil, fmt.Errorf("stat meta file: %w", err)
 	}
-	metaSize := int(metaStat.Size())
 	modTime := metaStat.ModTime()
 
-	meta, err := os.Open(metaPath)
+	header, samples, err := readMetaSamples(metaPath)
 	if err != nil {
-		return nil, fmt.Errorf("open meta file: %w", err)
-	}
-	defer meta.Close()
-
-	reader, header, err := customformat.NewReader(meta, metaSize)
-	if err != nil {
-		return nil, fmt.Errorf("new reader: %w", err)
+		return nil, err
 	}
 
 	videoTrack, audioTrack, err := header.GetTracks()
@@ -90,11 +89,6 @@ func readVideoMetadata(metaPath string) (*videoMetadata, error) {
 		return nil, fmt.Errorf("get tracks: %w", err)
 	}
 
-	samples, err := reader.ReadAllSamples()
-	if err != nil {
-		return nil, fmt.Errorf("read all samples: %w", err)
-	}
-
 	metaBuf := &bytes.Buffer{}
 	mdatSize, err := mp4muxer.GenerateMP4(
 		metaBuf, header.StartTime, samples, videoTrack, audioTrack)
@@ -102,13 +96,132 @@ func readVideoMetadata(metaPath string) (*videoMetadata, error) {
 		return nil, fmt.Errorf("generate meta: %w", err)
 	}
 
+	index := newVideoIndex(
+		header.StartTime, samples, int64(metaBuf.Len()), mdatSize)
+
 	return &videoMetadata{
 		buf:      metaBuf.Bytes(),
 		mdatSize: mdatSize,
 		modTime:  modTime,
+		index:    index,
+		header:   header,
+		samples:  samples,
 	}, nil
 }
 
+func readMetaSamples(metaPath string) (*customformat.Header, []customformat.Sample, error) {
+	meta, err := os.Open(metaPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open meta file: %w", err)
+	}
+	defer meta.Close()
+
+	metaStat, err := meta.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("stat meta file: %w", err)
+	}
+
+	reader, header, err := customformat.NewReader(meta, int(metaStat.Size()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("new reader: %w", err)
+	}
+
+	samples, err := reader.ReadAllSamples()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read all samples: %w", err)
+	}
+	return header, samples, nil
+}
+
+// VideoIndex seek index of a recording used by the player to
+// scrub without downloading the file. Times are in seconds
+// relative to the start of the recording and offsets are
+// byte offsets in the generated mp4 file.
+type VideoIndex struct {
+	Start     time.Time       `json:"start"`
+	Duration  float64         `json:"duration"`
+	Size      int64           `json:"size"`
+	Keyframes []float64       `json:"keyframes"`
+	Fragments []VideoFragment `json:"fragments"`
+}
+
+// VideoFragment byte range that starts on a keyframe and can
+// be requested independently using a HTTP range request.
+type VideoFragment struct {
+	Start  float64 `json:"start"`
+	Offset int64   `json:"offset"`
+	Size   int64   `json:"size"`
+}
+
+// Minimum duration of a fragment, long recordings would
+// otherwise have one fragment per keyframe.
+const videoFragmentDuration = 10 * time.Second
+
+func newVideoIndex(
+	startTime int64,
+	samples []customformat.Sample,
+	metaSize int64,
+	mdatSize int64,
+) *VideoIndex {
+	index := &VideoIndex{
+		Start:     time.Unix(0, startTime).UTC(),
+		Size:      metaSize + mdatSize,
+		Keyframes: []float64{},
+		Fragments: []VideoFragment{},
+	}
+
+	var endTime int64
+	for _, sample := range samples {
+		if sample.IsAudioSample {
+			continue
+		}
+		endTime = sample.Next
+		if sample.IsSyncSample {
+			pts := sample.PTS - startTime
+			index.Keyframes = append(index.Keyframes, time.Duration(pts).Seconds())
+		}
+	}
+
+	for _, i := range fragmentStarts(samples) {
+		index.Fragments = append(index.Fragments, VideoFragment{
+			Start:  time.Duration(samples[i].PTS - startTime).Seconds(),
+			Offset: metaSize + int64(samples[i].Offset),
+		})
+	}
+
+	for i := range index.Fragments {
+		end := index.Size
+		if i+1 < len(index.Fragments) {
+			end = index.Fragments[i+1].Offset
+		}
+		index.Fragments[i].Size = end - index.Fragments[i].Offset
+	}
+
+	if endTime > startTime {
+		index.Duration = time.Duration(endTime - startTime).Seconds()
+	}
+	return index
+}
+
+// fragmentStarts returns the indexes of the keyframe samples that start
+// a fragment. The VOD segments use the same boundaries as the seek index.
+func fragmentStarts(samples []customformat.Sample) []int {
+	var starts []int
+	var fragmentStart int64
+	for i, sample := range samples {
+		if sample.IsAudioSample || !sample.IsSyncSample {
+			continue
+		}
+		isFirst := len(starts) == 0
+		if !isFirst && time.Duration(sample.PTS-fragmentStart) < videoFragmentDuration {
+			continue
+		}
+		fragmentStart = sample.PTS
+		starts = append(starts, i)
+	}
+	return starts
+}
+
 // Read implements io.Reader .
 func (r *VideoReader) Read(p []byte) (int, error) {
 	if r.i >= r.metaSize+r.mdatSize {
@@ -201,6 +314,11 @@ func (r *VideoReader) Size() int64 {
 	return r.metaSize + r.mdatSize
 }
 
+// Index returns the seek index of the video.
+func (r *VideoReader) Index() *VideoIndex {
+	return r.index
+}
+
 // VideoCache Caches the n most recent video readers.
 type VideoCache struct {
 	items map[string]*videoMetadata
@@ -215,6 +333,9 @@ type videoMetadata struct {
 	buf      []byte
 	mdatSize int64
 	modTime  time.Time
+	index    *VideoIndex
+	header   *customformat.Header
+	samples  []customformat.Sample
 
 	key string
 	age int
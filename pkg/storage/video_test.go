@@ -3,9 +3,11 @@ package storage
 import (
 	"bytes"
 	"io"
+	"nvr/pkg/video/customformat"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -112,6 +114,31 @@ func TestVideoReader(t *testing.T) {
 	require.ErrorIs(t, err, errNegativePosition)
 }
 
+func TestNewVideoIndex(t *testing.T) {
+	second := int64(time.Second)
+	samples := []customformat.Sample{
+		{IsSyncSample: true, PTS: 0, Next: second, Offset: 0, Size: 10},
+		{IsAudioSample: true, PTS: 0, Next: second, Offset: 10, Size: 5},
+		{PTS: 1 * second, Next: 2 * second, Offset: 15, Size: 10},
+		{IsSyncSample: true, PTS: 5 * second, Next: 6 * second, Offset: 25, Size: 10},
+		{IsSyncSample: true, PTS: 12 * second, Next: 13 * second, Offset: 35, Size: 10},
+	}
+
+	index := newVideoIndex(0, samples, 100, 45)
+
+	expected := &VideoIndex{
+		Start:     time.Unix(0, 0).UTC(),
+		Duration:  13,
+		Size:      145,
+		Keyframes: []float64{0, 5, 12},
+		Fragments: []VideoFragment{
+			{Start: 0, Offset: 100, Size: 35},
+			{Start: 12, Offset: 135, Size: 10},
+		},
+	}
+	require.Equal(t, expected, index)
+}
+
 func TestVideoReaderCache(t *testing.T) {
 	cache := NewVideoCache()
 	cache.maxSize = 3
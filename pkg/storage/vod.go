@@ -0,0 +1,354 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"nvr/pkg/crypt"
+	"nvr/pkg/video/customformat"
+	"nvr/pkg/video/hls"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VODSegment is a segment of a HLS VOD playlist. Each segment is a
+// fragment of the seek index of a recording in the meta format.
+type VODSegment struct {
+	RecordingID string
+	Fragment    int
+	Start       time.Time
+	Duration    time.Duration
+
+	// Set if the segment overlaps the activity index of the recording.
+	Active bool
+}
+
+// MaxVODDuration is the longest time range of a VOD playlist.
+const MaxVODDuration = 24 * time.Hour
+
+// VOD errors.
+var (
+	ErrInvalidVODRange     = errors.New("invalid time range")
+	ErrInvalidMonitorID    = errors.New("invalid monitor id")
+	ErrVODSegmentNotExist  = errors.New("segment does not exist")
+	ErrVODNoVideoSamples   = errors.New("no video samples")
+	errVODInvalidDataRange = errors.New("invalid sample data range")
+)
+
+// VODSegments returns the segments of the finished recordings of a
+// monitor that overlap the time range, ordered by wall-clock time.
+// Recordings that aren't in the meta format are skipped.
+func VODSegments(
+	recordingsDirs []string,
+	monitorID string,
+	start time.Time,
+	end time.Time,
+) ([]VODSegment, error) {
+	if !end.After(start) || end.Sub(start) > MaxVODDuration {
+		return nil, fmt.Errorf("%w: max duration %v", ErrInvalidVODRange, MaxVODDuration)
+	}
+	if monitorID == "" || monitorID == "." || monitorID == ".." ||
+		strings.ContainsAny(monitorID, `/\`) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMonitorID, monitorID)
+	}
+
+	// Recordings that start the day before may overlap the range.
+	firstDay := start.UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+
+	var segments []VODSegment
+	for day := firstDay; !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, recordingsDir := range recordingsDirs {
+			dir := filepath.Join(recordingsDir, day.Format("2006/01/02"), monitorID)
+			dirSegments, err := readDirVODSegments(dir, start, end)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, dirSegments...)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Start.Before(segments[j].Start)
+	})
+	return segments, nil
+}
+
+func readDirVODSegments(dir string, start time.Time, end time.Time) ([]VODSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+
+	var segments []VODSegment
+	for _, entry := range entries {
+		recID, isMeta := strings.CutSuffix(entry.Name(), ".meta")
+		if !isMeta {
+			continue
+		}
+		path := filepath.Join(dir, recID)
+
+		// The data file is written when the recording is finished.
+		rawData, err := os.ReadFile(path + ".json")
+		if err != nil {
+			continue
+		}
+		var data RecordingData
+		if err := json.Unmarshal(rawData, &data); err != nil {
+			continue
+		}
+		if !data.End.After(start) || !data.Start.Before(end) {
+			continue
+		}
+
+		_, samples, err := readMetaSamples(path + ".meta")
+		if err != nil {
+			return nil, fmt.Errorf("read samples %v: %w", recID, err)
+		}
+		activity := data.ActivityIndex()
+		for _, s := range recordingVODSegments(recID, samples) {
+			segmentEnd := s.Start.Add(s.Duration)
+			if s.Start.Before(end) && segmentEnd.After(start) {
+				s.Active = isActive(data.Start, activity, s.Start, segmentEnd)
+				segments = append(segments, s)
+			}
+		}
+	}
+	return segments, nil
+}
+
+func recordingVODSegments(recID string, samples []customformat.Sample) []VODSegment {
+	var endTime int64
+	for _, sample := range samples {
+		if !sample.IsAudioSample {
+			endTime = sample.Next
+		}
+	}
+
+	starts := fragmentStarts(samples)
+	segments := make([]VODSegment, len(starts))
+	for i, first := range starts {
+		segmentEnd := endTime
+		if i+1 < len(starts) {
+			segmentEnd = samples[starts[i+1]].PTS
+		}
+		pts := samples[first].PTS
+		segments[i] = VODSegment{
+			RecordingID: recID,
+			Fragment:    i,
+			Start:       time.Unix(0, pts).UTC(),
+			Duration:    time.Duration(segmentEnd - pts),
+		}
+	}
+	return segments
+}
+
+// ActiveVODSegments returns the active segments.
+func ActiveVODSegments(segments []VODSegment) []VODSegment {
+	var active []VODSegment
+	for _, s := range segments {
+		if s.Active {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// VODActivityClass is the class of the date ranges that mark activity.
+const VODActivityClass = "nvr-activity"
+
+// GenerateVODPlaylist generates a HLS VOD playlist. Every recording has
+// its own initialization segment and timeline, gaps between recordings
+// are skipped by the player. The URIs are relative to the playlist.
+//
+// Consecutive active segments are marked with a EXT-X-DATERANGE tag
+// so that the player can skip the inactive periods between them.
+//
+// If at isn't zero, the player is told to start at that wall-clock time.
+func GenerateVODPlaylist(segments []VODSegment, at time.Time) []byte {
+	var targetDuration float64
+	for _, s := range segments {
+		targetDuration = math.Max(targetDuration, math.Ceil(s.Duration.Seconds()))
+	}
+
+	cnt := "#EXTM3U\n"
+	cnt += "#EXT-X-VERSION:7\n"
+	cnt += "#EXT-X-PLAYLIST-TYPE:VOD\n"
+	cnt += "#EXT-X-INDEPENDENT-SEGMENTS\n"
+	cnt += "#EXT-X-TARGETDURATION:" + strconv.FormatFloat(targetDuration, 'f', 0, 64) + "\n"
+	cnt += "#EXT-X-MEDIA-SEQUENCE:0\n"
+	if !at.IsZero() && len(segments) != 0 {
+		offset := vodStartOffset(segments, at)
+		cnt += "#EXT-X-START:TIME-OFFSET=" +
+			strconv.FormatFloat(offset.Seconds(), 'f', 5, 64) + ",PRECISE=YES\n"
+	}
+
+	var activityCount int
+	for i, s := range segments {
+		id := url.QueryEscape(s.RecordingID)
+		// Segments may be left out, for example by ActiveVODSegments.
+		contiguous := i != 0 && isContiguous(segments[i-1], s)
+		if i != 0 && !contiguous {
+			cnt += "#EXT-X-DISCONTINUITY\n"
+		}
+		if i == 0 || s.RecordingID != segments[i-1].RecordingID {
+			cnt += "#EXT-X-MAP:URI=\"init.mp4?id=" + id + "\"\n"
+		}
+		cnt += "#EXT-X-PROGRAM-DATE-TIME:" + formatVODTime(s.Start) + "\n"
+		if s.Active && (!contiguous || !segments[i-1].Active) {
+			cnt += vodActivityRange(activityCount, segments[i:])
+			activityCount++
+		}
+		cnt += "#EXTINF:" + strconv.FormatFloat(s.Duration.Seconds(), 'f', 5, 64) + ",\n" +
+			"segment.m4s?id=" + id + "&n=" + strconv.Itoa(s.Fragment) + "\n"
+	}
+
+	cnt += "#EXT-X-ENDLIST\n"
+	return []byte(cnt)
+}
+
+// vodStartOffset returns the playlist position of the wall-clock time.
+// Gaps between the segments are skipped by the player, a time in a gap
+// starts at the next segment and a time after the last segment starts
+// at the last segment.
+func vodStartOffset(segments []VODSegment, at time.Time) time.Duration {
+	var offset time.Duration
+	for _, s := range segments {
+		if at.Before(s.Start) {
+			return offset
+		}
+		if at.Before(s.Start.Add(s.Duration)) {
+			return offset + at.Sub(s.Start)
+		}
+		offset += s.Duration
+	}
+	return offset - segments[len(segments)-1].Duration
+}
+
+func formatVODTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.999Z07:00")
+}
+
+func isContiguous(prev VODSegment, s VODSegment) bool {
+	return prev.RecordingID == s.RecordingID && prev.Fragment+1 == s.Fragment
+}
+
+// vodActivityRange returns the date range tag of the
+// active segments at the start of the list.
+func vodActivityRange(n int, segments []VODSegment) string {
+	duration := segments[0].Duration
+	for i := 1; i < len(segments); i++ {
+		if !segments[i].Active || !isContiguous(segments[i-1], segments[i]) {
+			break
+		}
+		duration += segments[i].Duration
+	}
+	return "#EXT-X-DATERANGE:ID=\"activity-" + strconv.Itoa(n) + "\"," +
+		"CLASS=\"" + VODActivityClass + "\"," +
+		"START-DATE=\"" + formatVODTime(segments[0].Start) + "\"," +
+		"DURATION=" + strconv.FormatFloat(duration.Seconds(), 'f', 3, 64) + "\n"
+}
+
+// VODInit returns the fMP4 initialization segment of a recording.
+func VODInit(recordingPath string, cache *VideoCache) ([]byte, error) {
+	meta, err := loadVideoMetadata(recordingPath, cache)
+	if err != nil {
+		return nil, err
+	}
+	videoTrack, audioTrack, err := meta.header.GetTracks()
+	if err != nil {
+		return nil, fmt.Errorf("get tracks: %w", err)
+	}
+	return hls.GenerateInit(videoTrack, audioTrack)
+}
+
+// VODSegmentData returns a fragment of a recording as a fMP4 segment.
+// The decode times are relative to the start of the recording.
+func VODSegmentData(recordingPath string, fragment int, cache *VideoCache) ([]byte, error) {
+	meta, err := loadVideoMetadata(recordingPath, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	starts := fragmentStarts(meta.samples)
+	if fragment < 0 || fragment >= len(starts) {
+		return nil, fmt.Errorf("%w: %v", ErrVODSegmentNotExist, fragment)
+	}
+	samples := meta.samples[starts[fragment]:]
+	if fragment+1 < len(starts) {
+		samples = meta.samples[starts[fragment]:starts[fragment+1]]
+	}
+
+	data, err := readSampleData(recordingPath+".mdat", samples)
+	if err != nil {
+		return nil, err
+	}
+
+	_, audioTrack, err := meta.header.GetTracks()
+	if err != nil {
+		return nil, fmt.Errorf("get tracks: %w", err)
+	}
+
+	var videoSamples []*hls.VideoSample
+	var audioSamples []*hls.AudioSample
+	first := int64(samples[0].Offset)
+	for _, s := range samples {
+		start := int64(s.Offset) - first
+		end := start + int64(s.Size)
+		if start < 0 || end > int64(len(data)) {
+			return nil, errVODInvalidDataRange
+		}
+		buf := data[start:end]
+		if s.IsAudioSample {
+			if audioTrack != nil {
+				audioSamples = append(audioSamples, &hls.AudioSample{
+					AU:      buf,
+					PTS:     s.PTS,
+					NextPTS: s.Next,
+				})
+			}
+			continue
+		}
+		videoSamples = append(videoSamples, &hls.VideoSample{
+			PTS:        s.PTS,
+			DTS:        s.DTS,
+			AVCC:       buf,
+			IdrPresent: s.IsSyncSample,
+			Duration:   time.Duration(s.Next - s.DTS),
+		})
+	}
+	if len(videoSamples) == 0 {
+		return nil, ErrVODNoVideoSamples
+	}
+
+	return hls.GeneratePart(meta.header.StartTime, audioTrack, videoSamples, audioSamples)
+}
+
+// readSampleData reads the data of consecutive samples in one read.
+func readSampleData(mdatPath string, samples []customformat.Sample) ([]byte, error) {
+	first := int64(samples[0].Offset)
+	last := samples[len(samples)-1]
+	size := int64(last.Offset) + int64(last.Size) - first
+	if size < 0 {
+		return nil, errVODInvalidDataRange
+	}
+
+	mdat, err := crypt.Open(mdatPath)
+	if err != nil {
+		return nil, fmt.Errorf("open mdat file: %w", err)
+	}
+	defer mdat.Close()
+
+	data := make([]byte, size)
+	if _, err := mdat.ReadAt(data, first); err != nil {
+		return nil, fmt.Errorf("read mdat file: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/video/customformat"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeVODRecording(t *testing.T, recordingsDir string, start time.Time, events []Event) string {
+	t.Helper()
+	recID := start.Format("2006-01-02_15-04-05_") + "m1"
+	dir := filepath.Join(recordingsDir, start.Format("2006/01/02"), "m1")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	path := filepath.Join(dir, recID)
+
+	second := int64(time.Second)
+	t0 := start.UnixNano()
+	header := customformat.Header{
+		VideoSPS:  []byte{103, 0, 0, 0, 172, 217, 0},
+		VideoPPS:  []byte{2, 3, 4},
+		StartTime: t0,
+	}
+	samples := []customformat.Sample{
+		{IsSyncSample: true, PTS: t0, DTS: t0, Next: t0 + second, Offset: 0, Size: 4},
+		{IsSyncSample: true, PTS: t0 + 5*second, DTS: t0 + 5*second, Next: t0 + 6*second, Offset: 4, Size: 4},
+		{IsSyncSample: true, PTS: t0 + 12*second, DTS: t0 + 12*second, Next: t0 + 13*second, Offset: 8, Size: 4},
+		{PTS: t0 + 13*second, DTS: t0 + 13*second, Next: t0 + 14*second, Offset: 12, Size: 4},
+	}
+	meta := header.Marshal()
+	for _, s := range samples {
+		meta = append(meta, s.Marshal()...)
+	}
+	require.NoError(t, os.WriteFile(path+".meta", meta, 0o600))
+	require.NoError(t, os.WriteFile(path+".mdat", make([]byte, 16), 0o600))
+
+	data, err := json.Marshal(RecordingData{
+		Start:  start,
+		End:    start.Add(14 * time.Second),
+		Events: events,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path+".json", data, 0o600))
+	return path
+}
+
+func TestVODSegments(t *testing.T) {
+	recordingsDir := t.TempDir()
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	path := writeVODRecording(t, recordingsDir, start, nil)
+	recID := filepath.Base(path)
+
+	t.Run("all", func(t *testing.T) {
+		segments, err := VODSegments([]string{recordingsDir}, "m1", start, start.Add(time.Hour))
+		require.NoError(t, err)
+		expected := []VODSegment{
+			{RecordingID: recID, Fragment: 0, Start: start, Duration: 12 * time.Second},
+			{RecordingID: recID, Fragment: 1, Start: start.Add(12 * time.Second), Duration: 2 * time.Second},
+		}
+		require.Equal(t, expected, segments)
+
+		expectedPlaylist := "#EXTM3U\n" +
+			"#EXT-X-VERSION:7\n" +
+			"#EXT-X-PLAYLIST-TYPE:VOD\n" +
+			"#EXT-X-INDEPENDENT-SEGMENTS\n" +
+			"#EXT-X-TARGETDURATION:12\n" +
+			"#EXT-X-MEDIA-SEQUENCE:0\n" +
+			"#EXT-X-MAP:URI=\"init.mp4?id=2000-01-02_00-00-00_m1\"\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2000-01-02T00:00:00Z\n" +
+			"#EXTINF:12.00000,\n" +
+			"segment.m4s?id=2000-01-02_00-00-00_m1&n=0\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2000-01-02T00:00:12Z\n" +
+			"#EXTINF:2.00000,\n" +
+			"segment.m4s?id=2000-01-02_00-00-00_m1&n=1\n" +
+			"#EXT-X-ENDLIST\n"
+		require.Equal(t, expectedPlaylist, string(GenerateVODPlaylist(segments, time.Time{})))
+	})
+	t.Run("partial", func(t *testing.T) {
+		segments, err := VODSegments(
+			[]string{recordingsDir}, "m1", start.Add(13*time.Second), start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, segments, 1)
+		require.Equal(t, 1, segments[0].Fragment)
+	})
+	t.Run("empty", func(t *testing.T) {
+		segments, err := VODSegments(
+			[]string{recordingsDir}, "m1", start.Add(time.Minute), start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, segments)
+	})
+	t.Run("invalidRange", func(t *testing.T) {
+		_, err := VODSegments([]string{recordingsDir}, "m1", start, start)
+		require.ErrorIs(t, err, ErrInvalidVODRange)
+		_, err = VODSegments([]string{recordingsDir}, "m1", start, start.Add(25*time.Hour))
+		require.ErrorIs(t, err, ErrInvalidVODRange)
+	})
+	t.Run("invalidMonitor", func(t *testing.T) {
+		_, err := VODSegments([]string{recordingsDir}, "../m1", start, start.Add(time.Hour))
+		require.ErrorIs(t, err, ErrInvalidMonitorID)
+	})
+}
+
+func TestVODActivity(t *testing.T) {
+	recordingsDir := t.TempDir()
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	writeVODRecording(t, recordingsDir, start, []Event{{Time: start.Add(13 * time.Second)}})
+
+	segments, err := VODSegments([]string{recordingsDir}, "m1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+	require.False(t, segments[0].Active)
+	require.True(t, segments[1].Active)
+	require.Equal(t, segments[1:], ActiveVODSegments(segments))
+
+	t.Run("playlist", func(t *testing.T) {
+		segments := []VODSegment{
+			{RecordingID: "a", Fragment: 0, Start: start, Duration: 4 * time.Second, Active: true},
+			{RecordingID: "a", Fragment: 1, Start: start.Add(4 * time.Second), Duration: 4 * time.Second, Active: true},
+			{RecordingID: "a", Fragment: 2, Start: start.Add(8 * time.Second), Duration: 4 * time.Second},
+			{RecordingID: "a", Fragment: 3, Start: start.Add(12 * time.Second), Duration: 4 * time.Second, Active: true},
+		}
+		expected := "#EXTM3U\n" +
+			"#EXT-X-VERSION:7\n" +
+			"#EXT-X-PLAYLIST-TYPE:VOD\n" +
+			"#EXT-X-INDEPENDENT-SEGMENTS\n" +
+			"#EXT-X-TARGETDURATION:4\n" +
+			"#EXT-X-MEDIA-SEQUENCE:0\n" +
+			"#EXT-X-MAP:URI=\"init.mp4?id=a\"\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2000-01-02T00:00:00Z\n" +
+			"#EXT-X-DATERANGE:ID=\"activity-0\",CLASS=\"nvr-activity\"," +
+			"START-DATE=\"2000-01-02T00:00:00Z\",DURATION=8.000\n" +
+			"#EXTINF:4.00000,\n" +
+			"segment.m4s?id=a&n=0\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2000-01-02T00:00:04Z\n" +
+			"#EXTINF:4.00000,\n" +
+			"segment.m4s?id=a&n=1\n" +
+			"#EXT-X-DISCONTINUITY\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2000-01-02T00:00:12Z\n" +
+			"#EXT-X-DATERANGE:ID=\"activity-1\",CLASS=\"nvr-activity\"," +
+			"START-DATE=\"2000-01-02T00:00:12Z\",DURATION=4.000\n" +
+			"#EXTINF:4.00000,\n" +
+			"segment.m4s?id=a&n=3\n" +
+			"#EXT-X-ENDLIST\n"
+		require.Equal(t, expected, string(GenerateVODPlaylist(ActiveVODSegments(segments), time.Time{})))
+	})
+}
+
+func TestVODStartOffset(t *testing.T) {
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	segments := []VODSegment{
+		{RecordingID: "a", Fragment: 0, Start: start, Duration: 4 * time.Second},
+		{RecordingID: "a", Fragment: 1, Start: start.Add(4 * time.Second), Duration: 4 * time.Second},
+		{RecordingID: "b", Fragment: 0, Start: start.Add(time.Minute), Duration: 4 * time.Second},
+	}
+	cases := map[string]struct {
+		at       time.Time
+		expected time.Duration
+	}{
+		"before": {start.Add(-time.Hour), 0},
+		"first":  {start.Add(1500 * time.Millisecond), 1500 * time.Millisecond},
+		"second": {start.Add(5 * time.Second), 5 * time.Second},
+		"gap":    {start.Add(30 * time.Second), 8 * time.Second},
+		"last":   {start.Add(time.Minute + time.Second), 9 * time.Second},
+		"after":  {start.Add(time.Hour), 8 * time.Second},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, vodStartOffset(segments, tc.at))
+		})
+	}
+
+	playlist := string(GenerateVODPlaylist(segments, start.Add(5*time.Second)))
+	require.Contains(t, playlist, "#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXT-X-START:TIME-OFFSET=5.00000,PRECISE=YES\n")
+}
+
+func TestVODSegmentData(t *testing.T) {
+	start := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	path := writeVODRecording(t, t.TempDir(), start, nil)
+
+	init, err := VODInit(path, nil)
+	require.NoError(t, err)
+	require.Equal(t, "ftyp", string(init[4:8]))
+
+	segment, err := VODSegmentData(path, 1, NewVideoCache())
+	require.NoError(t, err)
+	require.Equal(t, "moof", string(segment[4:8]))
+	require.Equal(t, "mdat", string(segment[len(segment)-12:len(segment)-8]))
+
+	_, err = VODSegmentData(path, 2, nil)
+	require.ErrorIs(t, err, ErrVODSegmentNotExist)
+
+	_, err = VODSegmentData(filepath.Join(filepath.Dir(path), "x"), 0, nil)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"nvr/pkg/feed"
+	"nvr/pkg/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Storage volume strategies.
+const (
+	// StrategySequential fills the volumes in order.
+	StrategySequential = "sequential"
+
+	// StrategyRoundRobin rotates between the volumes for every recording.
+	StrategyRoundRobin = "round-robin"
+)
+
+// volumeMinFree is the free space below which a volume is considered full.
+const volumeMinFree = 1000 * 1000 * 1000
+
+// ErrNoWritableVolume no writable storage volume.
+var ErrNoWritableVolume = errors.New("no writable storage volume")
+
+// Volumes selects the storage volume for new recordings.
+type Volumes struct {
+	storageDirs []string
+	strategy    string
+	next        int
+
+	// Used when no volume is writable, see RunFailover.
+	failoverDir string
+	failover    bool
+	alerts      *feed.Buffer[DiskAlert]
+	logger      log.ILogger
+
+	checkWritable func(dir string) error
+	freeSpace     func(dir string) (uint64, error)
+
+	mu sync.Mutex
+}
+
+// NewVolumes creates a volume selector from the env config.
+func NewVolumes(env ConfigEnv, logger log.ILogger) *Volumes {
+	return &Volumes{
+		storageDirs:   env.StorageDirs(),
+		strategy:      env.StorageStrategy,
+		failoverDir:   env.StorageFailoverDir,
+		logger:        logger,
+		checkWritable: checkWritable,
+		freeSpace:     freeSpace,
+	}
+}
+
+// RecordingsDir returns the recordings directory for a new recording. The
+// pinned storage directory is used if set and available. Volumes that
+// are unwritable or full are skipped, the last writable volume is used
+// as a fallback if all volumes are full. The failover directory is
+// used if set and no volume is writable.
+func (v *Volumes) RecordingsDir(pinned string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	dir, err := v.selectDir(pinned)
+	if !errors.Is(err, ErrNoWritableVolume) || v.failoverDir == "" {
+		return dir, err
+	}
+
+	failoverDir := recordingsDir(v.failoverDir)
+	if err := v.checkWritable(failoverDir); err != nil {
+		return "", fmt.Errorf("%w: failover directory: %v", ErrNoWritableVolume, err)
+	}
+	v.setFailover(true)
+	return failoverDir, nil
+}
+
+// Writable returns ErrNoWritableVolume if no volume, including
+// the failover directory, is mounted and writable.
+func (v *Volumes) Writable() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	dirs := v.storageDirs
+	if v.failoverDir != "" {
+		dirs = append(dirs[:len(dirs):len(dirs)], v.failoverDir)
+	}
+	var errs []error
+	for _, dir := range dirs {
+		err := v.checkWritable(recordingsDir(dir))
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("%w: %w", ErrNoWritableVolume, errors.Join(errs...))
+}
+
+// selectDir returns the recordings directory of the volume
+// for a new recording. The lock must be held.
+func (v *Volumes) selectDir(pinned string) (string, error) {
+	if pinned != "" {
+		for _, dir := range v.storageDirs {
+			if dir == pinned && v.available(dir) {
+				return recordingsDir(dir), nil
+			}
+		}
+	}
+
+	start := 0
+	if v.strategy == StrategyRoundRobin {
+		start = v.next % len(v.storageDirs)
+		v.next = start + 1
+	}
+
+	fallback := ""
+	for i := range v.storageDirs {
+		dir := v.storageDirs[(start+i)%len(v.storageDirs)]
+		if v.checkWritable(recordingsDir(dir)) != nil {
+			continue
+		}
+		if v.notFull(dir) {
+			return recordingsDir(dir), nil
+		}
+		fallback = dir
+	}
+	if fallback != "" {
+		return recordingsDir(fallback), nil
+	}
+	return "", ErrNoWritableVolume
+}
+
+func (v *Volumes) available(dir string) bool {
+	return v.checkWritable(recordingsDir(dir)) == nil && v.notFull(dir)
+}
+
+func (v *Volumes) notFull(dir string) bool {
+	free, err := v.freeSpace(dir)
+	return err != nil || free >= volumeMinFree
+}
+
+func recordingsDir(storageDir string) string {
+	return filepath.Join(storageDir, "recordings")
+}
+
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".write-test")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+func freeSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("statfs: %w", err)
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil //nolint:unconvert
+}
+
+// multiFS merges multiple file systems into one. Directory listings are
+// merged and files are opened from the first file system that has them.
+// Used to read the recordings from every volume as a single tree.
+type multiFS []fs.FS
+
+// NewMultiFS returns a read only file system that merges the file systems.
+func NewMultiFS(fileSystems ...fs.FS) fs.FS {
+	if len(fileSystems) == 1 {
+		return fileSystems[0]
+	}
+	return multiFS(fileSystems)
+}
+
+func (m multiFS) Open(name string) (fs.File, error) {
+	var firstErr error
+	for _, fileSystem := range m {
+		file, err := fileSystem.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func (m multiFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := make(map[string]struct{})
+	var entries []fs.DirEntry
+	var firstErr error
+	found := false
+	for _, fileSystem := range m {
+		list, err := fs.ReadDir(fileSystem, name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		for _, entry := range list {
+			if _, exist := seen[entry.Name()]; exist {
+				continue
+			}
+			seen[entry.Name()] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	if !found {
+		return nil, firstErr
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// FindRecordingsDir returns the recordings directory that contains the
+// recording files at recPath. Returns the first directory if none do.
+func FindRecordingsDir(recordingsDirs []string, recPath string) string {
+	for _, dir := range recordingsDirs {
+		fullPath := filepath.Join(dir, recPath)
+		entries, err := os.ReadDir(filepath.Dir(fullPath))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), filepath.Base(fullPath)) {
+				return dir
+			}
+		}
+	}
+	return recordingsDirs[0]
+}
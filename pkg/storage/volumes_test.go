@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVolumes(strategy string, unwritable string, full string) *Volumes {
+	return &Volumes{
+		storageDirs: []string{"/a", "/b", "/c"},
+		strategy:    strategy,
+		checkWritable: func(dir string) error {
+			if dir == recordingsDir(unwritable) {
+				return errors.New("mock")
+			}
+			return nil
+		},
+		freeSpace: func(dir string) (uint64, error) {
+			if dir == full {
+				return 0, nil
+			}
+			return volumeMinFree, nil
+		},
+	}
+}
+
+func TestVolumesRecordingsDir(t *testing.T) {
+	next := func(t *testing.T, v *Volumes, pinned string) string {
+		t.Helper()
+		dir, err := v.RecordingsDir(pinned)
+		require.NoError(t, err)
+		return dir
+	}
+
+	t.Run("sequential", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "", "")
+		require.Equal(t, "/a/recordings", next(t, v, ""))
+		require.Equal(t, "/a/recordings", next(t, v, ""))
+	})
+	t.Run("sequentialFull", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "", "/a")
+		require.Equal(t, "/b/recordings", next(t, v, ""))
+	})
+	t.Run("failover", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "/a", "")
+		require.Equal(t, "/b/recordings", next(t, v, ""))
+	})
+	t.Run("roundRobin", func(t *testing.T) {
+		v := newTestVolumes(StrategyRoundRobin, "/b", "")
+		require.Equal(t, "/a/recordings", next(t, v, ""))
+		require.Equal(t, "/c/recordings", next(t, v, ""))
+		require.Equal(t, "/c/recordings", next(t, v, ""))
+		require.Equal(t, "/a/recordings", next(t, v, ""))
+	})
+	t.Run("pinned", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "/b", "")
+		require.Equal(t, "/c/recordings", next(t, v, "/c"))
+		require.Equal(t, "/a/recordings", next(t, v, "/b"))
+		require.Equal(t, "/a/recordings", next(t, v, "/x"))
+	})
+	t.Run("allFull", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "/c", "/a")
+		v.freeSpace = func(string) (uint64, error) { return 0, nil }
+		require.Equal(t, "/b/recordings", next(t, v, ""))
+	})
+	t.Run("noWritable", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "", "")
+		v.checkWritable = func(string) error { return errors.New("mock") }
+		_, err := v.RecordingsDir("")
+		require.ErrorIs(t, err, ErrNoWritableVolume)
+	})
+}
+
+func TestVolumesWritable(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "/a", "")
+		require.NoError(t, v.Writable())
+	})
+	t.Run("noWritable", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "", "")
+		v.checkWritable = func(string) error { return errors.New("mock") }
+		require.ErrorIs(t, v.Writable(), ErrNoWritableVolume)
+	})
+	t.Run("failoverDir", func(t *testing.T) {
+		v := newTestVolumes(StrategySequential, "", "")
+		v.failoverDir = "/failover"
+		v.checkWritable = func(dir string) error {
+			if dir == recordingsDir("/failover") {
+				return nil
+			}
+			return errors.New("mock")
+		}
+		require.NoError(t, v.Writable())
+		require.Len(t, v.storageDirs, 3)
+	})
+}
+
+func TestMultiFS(t *testing.T) {
+	fileSystem := NewMultiFS(
+		fstest.MapFS{
+			"2000/01/01/m1/a.json": {Data: []byte("1")},
+			"2000/01/02/m1/b.json": {Data: []byte("2")},
+		},
+		fstest.MapFS{
+			"2000/01/01/m2/c.json": {Data: []byte("3")},
+			"2000/01/02/m1/b.json": {Data: []byte("x")},
+		},
+	)
+
+	entries, err := fs.ReadDir(fileSystem, "2000/01/01")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "m1", entries[0].Name())
+	require.Equal(t, "m2", entries[1].Name())
+
+	file, err := fs.ReadFile(fileSystem, "2000/01/02/m1/b.json")
+	require.NoError(t, err)
+	require.Equal(t, "2", string(file))
+
+	sub, err := fs.Sub(fileSystem, "2000/01/01")
+	require.NoError(t, err)
+	file, err = fs.ReadFile(sub, "m2/c.json")
+	require.NoError(t, err)
+	require.Equal(t, "3", string(file))
+
+	_, err = fs.ReadDir(fileSystem, "nil")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestPurgeVolumes(t *testing.T) {
+	tempDir := t.TempDir()
+	storageDir := filepath.Join(tempDir, "storage")
+	volume := filepath.Join(tempDir, "volume")
+
+	m := &Manager{
+		storageDir: storageDir,
+		volumes:    []string{volume},
+		disk: &disk{
+			general: &ConfigGeneral{
+				Config: map[string]string{"diskSpace": "1"},
+			},
+			diskUsageBytes: highUsage,
+		},
+		removeAll: os.RemoveAll,
+		logger:    log.NewDummyLogger(),
+	}
+
+	writeEmptyDirs(t, storageDir, []string{"recordings/2000/01/02/x/x/x"})
+	writeEmptyDirs(t, volume, []string{
+		"recordings/2000/01/01/x/x/x",
+		"recordings/2000/01/02/x/x/x",
+	})
+
+	require.NoError(t, m.prune())
+	require.Equal(t, []string{"recordings/2000/01/02/x/x/x"}, listEmptyDirs(t, storageDir))
+	require.Equal(t, []string{"recordings/2000/01/02/x/x/x"}, listEmptyDirs(t, volume))
+
+	require.NoError(t, m.prune())
+	require.Equal(t, []string{"recordings/2000/01"}, listEmptyDirs(t, storageDir))
+	require.Equal(t, []string{"recordings/2000/01"}, listEmptyDirs(t, volume))
+}
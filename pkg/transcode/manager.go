@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package transcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrProfileNotExist profile does not exist.
+var ErrProfileNotExist = errors.New("transcode profile does not exist")
+
+// Manager stores the profiles, one JSON file per profile.
+type Manager struct {
+	profiles map[string]Profile
+	path     string
+	mu       sync.Mutex
+}
+
+// NewManager loads the profiles from the directory.
+func NewManager(configPath string) (*Manager, error) {
+	if err := os.MkdirAll(configPath, 0o700); err != nil {
+		return nil, fmt.Errorf("create profiles directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read profiles directory: %w", err)
+	}
+
+	profiles := make(map[string]Profile)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(configPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read profile: %w", err)
+		}
+		var p Profile
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("unmarshal profile: %w: %v", err, entry.Name())
+		}
+		profiles[p.Name] = p
+	}
+
+	return &Manager{
+		profiles: profiles,
+		path:     configPath,
+	}, nil
+}
+
+func (m *Manager) profilePath(name string) string {
+	return filepath.Join(m.path, name+".json")
+}
+
+// Profiles returns all profiles sorted by name, including
+// the default profile if it isn't overridden.
+func (m *Manager) Profiles() []Profile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profiles := []Profile{}
+	if _, exist := m.profiles[DefaultProfileName]; !exist {
+		profiles = append(profiles, DefaultProfile)
+	}
+	for _, p := range m.profiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// Profile returns a profile by name. Empty name returns the default profile.
+func (m *Manager) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfileName
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, exist := m.profiles[name]
+	if exist {
+		return p, nil
+	}
+	if name == DefaultProfileName {
+		return DefaultProfile, nil
+	}
+	return Profile{}, fmt.Errorf("%w: %v", ErrProfileNotExist, name)
+}
+
+// Set creates or updates a profile.
+func (m *Manager) Set(p Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	raw, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshal profile: %w", err)
+	}
+	if err := os.WriteFile(m.profilePath(p.Name), raw, 0o600); err != nil {
+		return fmt.Errorf("write profile: %w", err)
+	}
+	m.profiles[p.Name] = p
+	return nil
+}
+
+// Delete deletes a profile. Deleting the default profile
+// restores the built-in default.
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exist := m.profiles[name]; !exist {
+		return fmt.Errorf("%w: %v", ErrProfileNotExist, name)
+	}
+	if err := os.Remove(m.profilePath(name)); err != nil {
+		return err
+	}
+	delete(m.profiles, name)
+	return nil
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package transcode manages named encoder profiles that are shared by
+// the jobs that encode recordings, for example exports. The profiles
+// are converted to FFmpeg arguments so the encoder settings aren't
+// hard-coded in each job type.
+package transcode
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Codecs.
+const (
+	CodecH264 = "h264"
+	CodecH265 = "h265"
+)
+
+// Hardware acceleration methods, empty is software encoding.
+const (
+	HWAccelNone         = ""
+	HWAccelVAAPI        = "vaapi"
+	HWAccelNVENC        = "nvenc"
+	HWAccelQSV          = "qsv"
+	HWAccelVideoToolbox = "videotoolbox"
+)
+
+// Profile encoder settings.
+type Profile struct {
+	Name  string `json:"name"`
+	Codec string `json:"codec"`
+
+	// Output size, zero keeps the input size.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Target bitrate in kbit/s, zero uses constant quality.
+	Bitrate int `json:"bitrate"`
+
+	HWAccel string `json:"hwaccel"`
+}
+
+// DefaultProfileName is used if a job doesn't specify a profile.
+const DefaultProfileName = "default"
+
+// DefaultProfile is the built-in profile, it can be overridden by
+// a profile with the same name.
+var DefaultProfile = Profile{
+	Name:  DefaultProfileName,
+	Codec: CodecH264,
+}
+
+// VAAPI render device.
+const vaapiDevice = "/dev/dri/renderD128"
+
+// Limits.
+const (
+	maxNameLength = 32
+	maxSize       = 7680
+	maxBitrate    = 100000
+)
+
+// Profile errors.
+var (
+	ErrInvalidName    = errors.New("invalid name")
+	ErrInvalidCodec   = errors.New("invalid codec")
+	ErrInvalidSize    = errors.New("invalid size")
+	ErrInvalidBitrate = errors.New("invalid bitrate")
+	ErrInvalidHWAccel = errors.New("invalid hwaccel")
+)
+
+var reName = regexp.MustCompile(`^[0-9a-zA-Z_-]+$`)
+
+// Validate returns an error if the profile is invalid.
+func (p Profile) Validate() error {
+	if len(p.Name) > maxNameLength || !reName.MatchString(p.Name) {
+		return fmt.Errorf("%w: %q, only alphanumeric characters, "+
+			"underscore and minus, max %v bytes", ErrInvalidName, p.Name, maxNameLength)
+	}
+	switch p.Codec {
+	case CodecH264, CodecH265:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidCodec, p.Codec)
+	}
+	for _, v := range []int{p.Width, p.Height} {
+		// Most encoders require even dimensions.
+		if v < 0 || v > maxSize || v%2 != 0 {
+			return fmt.Errorf("%w: %vx%v, must be even and max %v",
+				ErrInvalidSize, p.Width, p.Height, maxSize)
+		}
+	}
+	if p.Bitrate < 0 || p.Bitrate > maxBitrate {
+		return fmt.Errorf("%w: %v, max %v kbit/s", ErrInvalidBitrate, p.Bitrate, maxBitrate)
+	}
+	switch p.HWAccel {
+	case HWAccelNone, HWAccelVAAPI, HWAccelNVENC, HWAccelQSV, HWAccelVideoToolbox:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidHWAccel, p.HWAccel)
+	}
+	return nil
+}
+
+// Encoder returns the name of the FFmpeg encoder.
+func (p Profile) Encoder() string {
+	codec := "h264"
+	software := "libx264"
+	if p.Codec == CodecH265 {
+		codec = "hevc"
+		software = "libx265"
+	}
+	if p.HWAccel == HWAccelNone {
+		return software
+	}
+	return codec + "_" + p.HWAccel
+}
+
+// InputArgs returns the FFmpeg arguments that must be placed before the inputs.
+func (p Profile) InputArgs() []string {
+	if p.HWAccel == HWAccelVAAPI {
+		return []string{"-vaapi_device", vaapiDevice}
+	}
+	return nil
+}
+
+// Filter returns the video filter that is applied before the
+// encoder, empty if none. VAAPI needs the frames uploaded to
+// the GPU, the other encoders accept software frames.
+func (p Profile) Filter() string {
+	var filter string
+	if p.Width != 0 || p.Height != 0 {
+		width, height := p.Width, p.Height
+		if width == 0 {
+			width = -2
+		}
+		if height == 0 {
+			height = -2
+		}
+		filter = "scale=" + strconv.Itoa(width) + ":" + strconv.Itoa(height)
+	}
+	if p.HWAccel == HWAccelVAAPI {
+		if filter != "" {
+			filter += ","
+		}
+		filter += "format=nv12,hwupload"
+	}
+	return filter
+}
+
+// OutputArgs returns the FFmpeg encoder arguments.
+func (p Profile) OutputArgs() []string {
+	args := []string{"-c:v", p.Encoder()}
+	if p.Bitrate != 0 {
+		bitrate := strconv.Itoa(p.Bitrate) + "k"
+		bufsize := strconv.Itoa(p.Bitrate*2) + "k"
+		args = append(args, "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufsize)
+	}
+	if p.HWAccel == HWAccelNone {
+		args = append(args, "-preset", "veryfast")
+		if p.Bitrate == 0 {
+			args = append(args, "-crf", "23")
+		}
+	}
+	if p.HWAccel != HWAccelVAAPI {
+		args = append(args, "-pix_fmt", "yuv420p")
+	}
+	if p.Codec == CodecH265 {
+		// Required by Apple devices.
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
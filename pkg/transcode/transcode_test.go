@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package transcode
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Profile{Name: "a", Codec: CodecH264}
+
+	testCases := map[string]struct {
+		modify func(*Profile)
+		err    error
+	}{
+		"ok":          {func(p *Profile) {}, nil},
+		"emptyName":   {func(p *Profile) { p.Name = "" }, ErrInvalidName},
+		"invalidName": {func(p *Profile) { p.Name = "a/b" }, ErrInvalidName},
+		"longName": {
+			func(p *Profile) { p.Name = strings.Repeat("a", maxNameLength+1) },
+			ErrInvalidName,
+		},
+		"codec":        {func(p *Profile) { p.Codec = "vp9" }, ErrInvalidCodec},
+		"oddWidth":     {func(p *Profile) { p.Width = 641 }, ErrInvalidSize},
+		"bigHeight":    {func(p *Profile) { p.Height = maxSize + 2 }, ErrInvalidSize},
+		"negBitrate":   {func(p *Profile) { p.Bitrate = -1 }, ErrInvalidBitrate},
+		"bigBitrate":   {func(p *Profile) { p.Bitrate = maxBitrate + 1 }, ErrInvalidBitrate},
+		"hwaccel":      {func(p *Profile) { p.HWAccel = "x" }, ErrInvalidHWAccel},
+		"validHWAccel": {func(p *Profile) { p.HWAccel = HWAccelNVENC }, nil},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := valid
+			tc.modify(&p)
+			require.ErrorIs(t, p.Validate(), tc.err)
+		})
+	}
+	require.NoError(t, DefaultProfile.Validate())
+}
+
+func TestArgs(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		require.Nil(t, DefaultProfile.InputArgs())
+		require.Equal(t, "", DefaultProfile.Filter())
+		require.Equal(t,
+			"-c:v libx264 -preset veryfast -crf 23 -pix_fmt yuv420p",
+			strings.Join(DefaultProfile.OutputArgs(), " "),
+		)
+	})
+	t.Run("softwareBitrate", func(t *testing.T) {
+		p := Profile{Codec: CodecH265, Width: 1280, Bitrate: 2000}
+		require.Equal(t, "scale=1280:-2", p.Filter())
+		require.Equal(t,
+			"-c:v libx265 -b:v 2000k -maxrate 2000k -bufsize 4000k"+
+				" -preset veryfast -pix_fmt yuv420p -tag:v hvc1",
+			strings.Join(p.OutputArgs(), " "),
+		)
+	})
+	t.Run("nvenc", func(t *testing.T) {
+		p := Profile{Codec: CodecH264, Width: 640, Height: 360, HWAccel: HWAccelNVENC}
+		require.Nil(t, p.InputArgs())
+		require.Equal(t, "scale=640:360", p.Filter())
+		require.Equal(t, "-c:v h264_nvenc -pix_fmt yuv420p", strings.Join(p.OutputArgs(), " "))
+	})
+	t.Run("vaapi", func(t *testing.T) {
+		p := Profile{Codec: CodecH264, HWAccel: HWAccelVAAPI}
+		require.Equal(t, []string{"-vaapi_device", vaapiDevice}, p.InputArgs())
+		require.Equal(t, "format=nv12,hwupload", p.Filter())
+		require.Equal(t, "-c:v h264_vaapi", strings.Join(p.OutputArgs(), " "))
+	})
+}
+
+func TestManager(t *testing.T) {
+	t.Run("crud", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := NewManager(dir)
+		require.NoError(t, err)
+		require.Equal(t, []Profile{DefaultProfile}, m.Profiles())
+
+		p, err := m.Profile("")
+		require.NoError(t, err)
+		require.Equal(t, DefaultProfile, p)
+
+		_, err = m.Profile("a")
+		require.ErrorIs(t, err, ErrProfileNotExist)
+
+		a := Profile{Name: "a", Codec: CodecH265, Bitrate: 1000}
+		require.NoError(t, m.Set(a))
+		require.ErrorIs(t, m.Set(Profile{Name: "b"}), ErrInvalidCodec)
+
+		p, err = m.Profile("a")
+		require.NoError(t, err)
+		require.Equal(t, a, p)
+		require.Equal(t, []Profile{a, DefaultProfile}, m.Profiles())
+
+		// Override the default profile.
+		def := Profile{Name: DefaultProfileName, Codec: CodecH264, Height: 720}
+		require.NoError(t, m.Set(def))
+		p, err = m.Profile("")
+		require.NoError(t, err)
+		require.Equal(t, def, p)
+
+		// Reload from disk.
+		m, err = NewManager(dir)
+		require.NoError(t, err)
+		require.Equal(t, []Profile{a, def}, m.Profiles())
+
+		require.NoError(t, m.Delete("a"))
+		require.NoError(t, m.Delete(DefaultProfileName))
+		require.ErrorIs(t, m.Delete("a"), ErrProfileNotExist)
+		require.Equal(t, []Profile{DefaultProfile}, m.Profiles())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+	t.Run("unmarshalErr", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "a.json"), []byte("nil"), 0o600)
+		require.NoError(t, err)
+
+		_, err = NewManager(dir)
+		require.Error(t, err)
+	})
+}
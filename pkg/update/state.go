@@ -0,0 +1,415 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package update
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The app can't replace its own source tree while it's running, the
+// update is handed over to the start script through state files.
+//
+// <homeDir>/update
+// ├── staged/        Verified release that is applied on the next start.
+// ├── previous/      Entries of the home directory that the release replaced.
+// ├── pending.json   A release is staged.
+// ├── trial.json     The release is applied but hasn't passed the health check.
+// └── rollback.json  The last release that was rolled back.
+//
+// 1. The app stages a release, writes pending.json and exits.
+// 2. The start script calls Apply, which swaps the top level entries of
+//    the home directory one rename at a time, and starts the app.
+// 3. The app calls Confirm after the health check has passed.
+// 4. If the app exits before that, the start script calls Rollback.
+
+const (
+	stateDir     = "update"
+	stagedDir    = "staged"
+	previousDir  = "previous"
+	pendingFile  = "pending.json"
+	trialFile    = "trial.json"
+	rollbackFile = "rollback.json"
+
+	versionFile = "VERSION"
+)
+
+// Top level entries of the home directory that a release can't replace.
+var protectedEntries = map[string]bool{
+	stateDir:  true,
+	"configs": true,
+	"storage": true,
+	".git":    true,
+}
+
+// state of a staged or applied release.
+type state struct {
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previousVersion"`
+
+	// Top level entries of the release.
+	Entries []string `json:"entries"`
+
+	// Entries that existed before the release was applied.
+	Replaced []string `json:"replaced"`
+}
+
+// RollbackInfo is a release that failed the health check.
+type RollbackInfo struct {
+	Version         string    `json:"version"`
+	PreviousVersion string    `json:"previousVersion"`
+	Reason          string    `json:"reason"`
+	Time            time.Time `json:"time"`
+}
+
+// Dir returns the state directory.
+func Dir(homeDir string) string {
+	return filepath.Join(homeDir, stateDir)
+}
+
+// CurrentVersion returns the version of the release in the
+// home directory, "dev" if it isn't a release.
+func CurrentVersion(homeDir string) string {
+	raw, err := os.ReadFile(filepath.Join(homeDir, versionFile))
+	if err != nil {
+		return "dev"
+	}
+	version := strings.TrimSpace(string(raw))
+	if version == "" {
+		return "dev"
+	}
+	return version
+}
+
+// Pending returns true if a release is staged.
+func Pending(homeDir string) bool {
+	_, err := os.Stat(filepath.Join(Dir(homeDir), pendingFile))
+	return err == nil
+}
+
+// InTrial returns true if the running release hasn't passed the health check.
+func InTrial(homeDir string) bool {
+	_, err := os.Stat(filepath.Join(Dir(homeDir), trialFile))
+	return err == nil
+}
+
+// LastRollback returns the last release that was rolled back, nil if none.
+func LastRollback(homeDir string) (*RollbackInfo, error) {
+	var info RollbackInfo
+	err := readJSON(filepath.Join(Dir(homeDir), rollbackFile), &info)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Apply moves the staged release into the home directory if a release is
+// pending, the replaced entries are kept for Rollback. Called by the start
+// script before the app is started. Apply can be repeated if it's interrupted.
+func Apply(homeDir string) (bool, error) {
+	dir := Dir(homeDir)
+	var s state
+	err := readJSON(filepath.Join(dir, pendingFile), &s)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, previousDir), 0o700); err != nil {
+		return false, err
+	}
+	for _, name := range s.Entries {
+		staged := filepath.Join(dir, stagedDir, name)
+		if !exist(staged) {
+			// Already applied.
+			continue
+		}
+		current := filepath.Join(homeDir, name)
+		previous := filepath.Join(dir, previousDir, name)
+		if exist(current) && !exist(previous) {
+			if err := os.Rename(current, previous); err != nil {
+				return false, fmt.Errorf("back up %v: %w", name, err)
+			}
+		}
+		if err := os.Rename(staged, current); err != nil {
+			return false, fmt.Errorf("apply %v: %w", name, err)
+		}
+	}
+
+	err = os.Rename(filepath.Join(dir, pendingFile), filepath.Join(dir, trialFile))
+	if err != nil {
+		return false, err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, stagedDir)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Rollback restores the replaced entries if the running release hasn't
+// passed the health check. Called by the start script after the app has
+// exited. Rollback can be repeated if it's interrupted.
+func Rollback(homeDir string, reason string) (bool, error) {
+	dir := Dir(homeDir)
+	var s state
+	err := readJSON(filepath.Join(dir, trialFile), &s)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	replaced := make(map[string]bool, len(s.Replaced))
+	for _, name := range s.Replaced {
+		replaced[name] = true
+	}
+	for _, name := range s.Entries {
+		current := filepath.Join(homeDir, name)
+		if !replaced[name] {
+			// New in this release.
+			if err := os.RemoveAll(current); err != nil {
+				return false, err
+			}
+			continue
+		}
+		previous := filepath.Join(dir, previousDir, name)
+		if !exist(previous) {
+			// Already restored.
+			continue
+		}
+		if err := os.RemoveAll(current); err != nil {
+			return false, err
+		}
+		if err := os.Rename(previous, current); err != nil {
+			return false, fmt.Errorf("restore %v: %w", name, err)
+		}
+	}
+
+	err = writeJSON(filepath.Join(dir, rollbackFile), RollbackInfo{
+		Version:         s.Version,
+		PreviousVersion: s.PreviousVersion,
+		Reason:          reason,
+		Time:            time.Now().UTC(),
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(filepath.Join(dir, trialFile)); err != nil {
+		return false, err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, previousDir)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Confirm keeps the running release, called by
+// the app after the health check has passed.
+func Confirm(homeDir string) error {
+	dir := Dir(homeDir)
+	err := os.Remove(filepath.Join(dir, trialFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, previousDir)); err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(dir, rollbackFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Archive limits.
+const (
+	maxArchiveFiles = 100000
+	maxArchiveSize  = 4 * 1024 * 1024 * 1024
+)
+
+// ErrInvalidArchive the release archive is invalid.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// stage extracts the release archive and marks it as pending.
+func stage(homeDir string, version string, archive io.Reader) error {
+	dir := Dir(homeDir)
+	staged := filepath.Join(dir, stagedDir)
+	for _, d := range []string{staged, filepath.Join(dir, previousDir)} {
+		if err := os.RemoveAll(d); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(staged, 0o700); err != nil {
+		return err
+	}
+
+	entries, err := extractArchive(archive, staged)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	if !exist(filepath.Join(staged, "go.mod")) {
+		return fmt.Errorf("%w: go.mod missing", ErrInvalidArchive)
+	}
+	err = os.WriteFile(filepath.Join(staged, versionFile), []byte(version+"\n"), 0o600)
+	if err != nil {
+		return err
+	}
+	if !contains(entries, versionFile) {
+		entries = append(entries, versionFile)
+	}
+
+	var replaced []string
+	for _, name := range entries {
+		if exist(filepath.Join(homeDir, name)) {
+			replaced = append(replaced, name)
+		}
+	}
+	return writeJSON(filepath.Join(dir, pendingFile), state{
+		Version:         version,
+		PreviousVersion: CurrentVersion(homeDir),
+		Entries:         entries,
+		Replaced:        replaced,
+	})
+}
+
+// extractArchive extracts a tar.gz of the source tree, only regular files
+// and directories are allowed. Returns the top level entries.
+func extractArchive(r io.Reader, dir string) ([]string, error) { //nolint:funlen
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	defer gz.Close()
+
+	var entries []string
+	var files int
+	var size int64
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar: %w", err)
+		}
+
+		files++
+		if files > maxArchiveFiles {
+			return nil, fmt.Errorf("more than %v files", maxArchiveFiles)
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if !isLocalPath(name) {
+			return nil, fmt.Errorf("invalid path: %q", header.Name)
+		}
+		top, _, _ := strings.Cut(name, "/")
+		if protectedEntries[top] {
+			return nil, fmt.Errorf("protected path: %q", header.Name)
+		}
+		if !contains(entries, top) {
+			entries = append(entries, top)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			size += header.Size
+			if size > maxArchiveSize {
+				return nil, fmt.Errorf("larger than %v bytes", maxArchiveSize)
+			}
+			if err := writeFile(target, tr, header.FileInfo().Mode()); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported file type: %q", header.Name)
+		}
+	}
+}
+
+func isLocalPath(name string) bool {
+	return !path.IsAbs(name) && name != ".." && !strings.HasPrefix(name, "../") &&
+		!strings.Contains(name, `\`)
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	// Only the executable bit is kept.
+	perm := os.FileMode(0o644)
+	if mode&0o100 != 0 {
+		perm = 0o755
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func readJSON(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("unmarshal %v: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Writes to a temporary file first, the state changes atomically.
+func writeJSON(path string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func exist(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
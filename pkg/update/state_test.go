@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testFile struct {
+	name    string
+	content string
+	typ     byte
+}
+
+func newTestArchive(t *testing.T, files []testFile) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		typ := f.typ
+		if typ == 0 {
+			typ = tar.TypeReg
+		}
+		header := &tar.Header{
+			Name:     f.name,
+			Typeflag: typ,
+			Mode:     0o644,
+			Size:     int64(len(f.content)),
+		}
+		if typ != tar.TypeReg {
+			header.Size = 0
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		if typ == tar.TypeReg {
+			_, err := tw.Write([]byte(f.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return b.Bytes()
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+// newTestHome creates a home directory with the v1.0.0 release.
+func newTestHome(t *testing.T) string {
+	homeDir := t.TempDir()
+	writeTestFile(t, filepath.Join(homeDir, "VERSION"), "v1.0.0\n")
+	writeTestFile(t, filepath.Join(homeDir, "go.mod"), "old")
+	writeTestFile(t, filepath.Join(homeDir, "pkg", "a.go"), "old")
+	writeTestFile(t, filepath.Join(homeDir, "configs", "env.yaml"), "config")
+	return homeDir
+}
+
+var testRelease = []testFile{
+	{name: "go.mod", content: "new"},
+	{name: "pkg/", typ: tar.TypeDir},
+	{name: "pkg/b.go", content: "new"},
+	{name: "web/index.js", content: "new"},
+}
+
+func TestApplyAndRollback(t *testing.T) {
+	t.Run("apply", func(t *testing.T) {
+		homeDir := newTestHome(t)
+		archive := newTestArchive(t, testRelease)
+		require.NoError(t, stage(homeDir, "v1.1.0", bytes.NewReader(archive)))
+		require.True(t, Pending(homeDir))
+
+		applied, err := Apply(homeDir)
+		require.NoError(t, err)
+		require.True(t, applied)
+		require.False(t, Pending(homeDir))
+		require.True(t, InTrial(homeDir))
+
+		require.Equal(t, "v1.1.0", CurrentVersion(homeDir))
+		require.Equal(t, "new", readTestFile(t, filepath.Join(homeDir, "go.mod")))
+		require.Equal(t, "new", readTestFile(t, filepath.Join(homeDir, "pkg", "b.go")))
+		require.NoFileExists(t, filepath.Join(homeDir, "pkg", "a.go"))
+		require.Equal(t, "config", readTestFile(t, filepath.Join(homeDir, "configs", "env.yaml")))
+
+		require.NoError(t, Confirm(homeDir))
+		require.False(t, InTrial(homeDir))
+		require.NoDirExists(t, filepath.Join(Dir(homeDir), previousDir))
+
+		// Nothing to apply or roll back.
+		applied, err = Apply(homeDir)
+		require.NoError(t, err)
+		require.False(t, applied)
+		rolledBack, err := Rollback(homeDir, "x")
+		require.NoError(t, err)
+		require.False(t, rolledBack)
+	})
+	t.Run("rollback", func(t *testing.T) {
+		homeDir := newTestHome(t)
+		archive := newTestArchive(t, testRelease)
+		require.NoError(t, stage(homeDir, "v1.1.0", bytes.NewReader(archive)))
+		_, err := Apply(homeDir)
+		require.NoError(t, err)
+
+		rolledBack, err := Rollback(homeDir, "exit status 1")
+		require.NoError(t, err)
+		require.True(t, rolledBack)
+		require.False(t, InTrial(homeDir))
+
+		require.Equal(t, "v1.0.0", CurrentVersion(homeDir))
+		require.Equal(t, "old", readTestFile(t, filepath.Join(homeDir, "go.mod")))
+		require.Equal(t, "old", readTestFile(t, filepath.Join(homeDir, "pkg", "a.go")))
+		require.NoDirExists(t, filepath.Join(homeDir, "web"))
+
+		info, err := LastRollback(homeDir)
+		require.NoError(t, err)
+		require.Equal(t, "v1.1.0", info.Version)
+		require.Equal(t, "v1.0.0", info.PreviousVersion)
+		require.Equal(t, "exit status 1", info.Reason)
+	})
+	t.Run("resumeApply", func(t *testing.T) {
+		homeDir := newTestHome(t)
+		archive := newTestArchive(t, testRelease)
+		require.NoError(t, stage(homeDir, "v1.1.0", bytes.NewReader(archive)))
+
+		// Interrupted after go.mod was swapped.
+		dir := Dir(homeDir)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, previousDir), 0o700))
+		require.NoError(t, os.Rename(
+			filepath.Join(homeDir, "go.mod"), filepath.Join(dir, previousDir, "go.mod")))
+		require.NoError(t, os.Rename(
+			filepath.Join(dir, stagedDir, "go.mod"), filepath.Join(homeDir, "go.mod")))
+
+		applied, err := Apply(homeDir)
+		require.NoError(t, err)
+		require.True(t, applied)
+		require.Equal(t, "new", readTestFile(t, filepath.Join(homeDir, "go.mod")))
+
+		_, err = Rollback(homeDir, "")
+		require.NoError(t, err)
+		require.Equal(t, "old", readTestFile(t, filepath.Join(homeDir, "go.mod")))
+	})
+}
+
+func TestStage(t *testing.T) {
+	cases := map[string][]testFile{
+		"traversal":    {{name: "go.mod"}, {name: "../x"}},
+		"absolute":     {{name: "go.mod"}, {name: "/x"}},
+		"protected":    {{name: "go.mod"}, {name: "configs/env.yaml"}},
+		"git":          {{name: "go.mod"}, {name: ".git/config"}},
+		"symlink":      {{name: "go.mod"}, {name: "x", typ: tar.TypeSymlink}},
+		"missingGoMod": {{name: "main.go"}},
+	}
+	for name, files := range cases {
+		t.Run(name, func(t *testing.T) {
+			homeDir := newTestHome(t)
+			archive := newTestArchive(t, files)
+			err := stage(homeDir, "v1.1.0", bytes.NewReader(archive))
+			require.ErrorIs(t, err, ErrInvalidArchive)
+			require.False(t, Pending(homeDir))
+		})
+	}
+	t.Run("notGzip", func(t *testing.T) {
+		homeDir := newTestHome(t)
+		err := stage(homeDir, "v1.1.0", bytes.NewReader([]byte("x")))
+		require.ErrorIs(t, err, ErrInvalidArchive)
+	})
+}
+
+func TestCurrentVersion(t *testing.T) {
+	require.Equal(t, "dev", CurrentVersion(t.TempDir()))
+	require.Equal(t, "v1.0.0", CurrentVersion(newTestHome(t)))
+}
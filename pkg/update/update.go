@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package update installs signed releases and rolls
+// back releases that fail their health check.
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nvr/pkg/log"
+)
+
+// Download limits.
+const (
+	maxManifestSize = 1024 * 1024
+	maxReleaseSize  = 1024 * 1024 * 1024
+)
+
+// Errors.
+var (
+	ErrDisabled         = errors.New("updates are disabled")
+	ErrNoUpdate         = errors.New("no update available")
+	ErrInProgress       = errors.New("update in progress")
+	ErrInvalidRelease   = errors.New("invalid release")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Release is the latest release in the manifest.
+type Release struct {
+	Version   string    `json:"version"`
+	URL       string    `json:"url"`
+	SHA256    string    `json:"sha256"`
+	Signature string    `json:"signature"`
+	Changelog string    `json:"changelog"`
+	Published time.Time `json:"published"`
+}
+
+// SignedMessage returns the message that the release
+// signature covers. The checksum covers the archive.
+func (r Release) SignedMessage() []byte {
+	return []byte(r.Version + "\n" + r.SHA256)
+}
+
+// verify the release signature.
+func (r Release) verify(key ed25519.PublicKey) error {
+	if _, ok := parseVersion(r.Version); !ok {
+		return fmt.Errorf("%w: version: %q", ErrInvalidRelease, r.Version)
+	}
+	if checksum, err := hex.DecodeString(r.SHA256); err != nil || len(checksum) != sha256.Size {
+		return fmt.Errorf("%w: sha256: %q", ErrInvalidRelease, r.SHA256)
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if !ed25519.Verify(key, r.SignedMessage(), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Config of the updater.
+type Config struct {
+	// URL of the release manifest.
+	URL string
+
+	// Key that release signatures are verified with.
+	PublicKey ed25519.PublicKey
+}
+
+// Updater checks for, downloads and stages releases.
+type Updater struct {
+	homeDir string
+	config  Config
+	client  *http.Client
+	logger  log.ILogger
+
+	mu         sync.Mutex
+	inProgress bool
+	restart    chan struct{}
+	restarted  bool
+}
+
+// NewUpdater creates a updater. Updates are disabled if the URL is empty.
+func NewUpdater(
+	homeDir string,
+	config Config,
+	client *http.Client,
+	logger log.ILogger,
+) *Updater {
+	return &Updater{
+		homeDir: homeDir,
+		config:  config,
+		client:  client,
+		logger:  logger,
+		restart: make(chan struct{}),
+	}
+}
+
+// Restart is closed when the app should exit to let
+// the start script apply or roll back a release.
+func (u *Updater) Restart() <-chan struct{} {
+	return u.restart
+}
+
+func (u *Updater) signalRestart() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.restarted {
+		u.restarted = true
+		close(u.restart)
+	}
+}
+
+func (u *Updater) enabled() bool {
+	return u.config.URL != ""
+}
+
+// Check returns the latest release if it's newer than the current version.
+func (u *Updater) Check(ctx context.Context) (*Release, error) {
+	if !u.enabled() {
+		return nil, ErrDisabled
+	}
+	var release Release
+	body, err := u.get(ctx, u.config.URL, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("%w: manifest: %w", ErrInvalidRelease, err)
+	}
+	if err := release.verify(u.config.PublicKey); err != nil {
+		return nil, err
+	}
+	if !newer(release.Version, CurrentVersion(u.homeDir)) {
+		return nil, ErrNoUpdate
+	}
+	return &release, nil
+}
+
+// VersionInfo is the current version and the update state.
+type VersionInfo struct {
+	Current         string `json:"current"`
+	UpdatesEnabled  bool   `json:"updatesEnabled"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+
+	// Latest release, only set if it's newer than the current version.
+	Latest *Release `json:"latest,omitempty"`
+
+	// Set if the check failed.
+	CheckError string `json:"checkError,omitempty"`
+
+	// The current release hasn't passed the health check.
+	Trial bool `json:"trial"`
+
+	// A release is staged and applied on the next restart.
+	Pending bool `json:"pending"`
+
+	LastRollback *RollbackInfo `json:"lastRollback,omitempty"`
+}
+
+// Info returns the version info, the latest release is only checked if check is true.
+func (u *Updater) Info(ctx context.Context, check bool) VersionInfo {
+	info := VersionInfo{
+		Current:        CurrentVersion(u.homeDir),
+		UpdatesEnabled: u.enabled(),
+		Trial:          InTrial(u.homeDir),
+		Pending:        Pending(u.homeDir),
+	}
+	lastRollback, err := LastRollback(u.homeDir)
+	if err != nil {
+		u.logf(log.LevelError, "update: read rollback state: %v", err)
+	}
+	info.LastRollback = lastRollback
+
+	if !check || !u.enabled() {
+		return info
+	}
+	release, err := u.Check(ctx)
+	switch {
+	case errors.Is(err, ErrNoUpdate):
+	case err != nil:
+		info.CheckError = err.Error()
+	default:
+		info.Latest = release
+		info.UpdateAvailable = true
+	}
+	return info
+}
+
+// Update downloads, verifies and stages the latest release, then signals a
+// restart. The start script applies the release before the app is started.
+func (u *Updater) Update(ctx context.Context) (*Release, error) {
+	u.mu.Lock()
+	if u.inProgress || u.restarted {
+		u.mu.Unlock()
+		return nil, ErrInProgress
+	}
+	u.inProgress = true
+	u.mu.Unlock()
+
+	defer func() {
+		u.mu.Lock()
+		u.inProgress = false
+		u.mu.Unlock()
+	}()
+
+	release, err := u.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u.logf(log.LevelInfo, "update: downloading %v", release.Version)
+	archivePath, err := u.download(ctx, *release)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	defer os.Remove(archivePath)
+
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	if err := stage(u.homeDir, release.Version, archive); err != nil {
+		return nil, fmt.Errorf("stage: %w", err)
+	}
+
+	u.logf(log.LevelInfo, "update: %v staged, restarting", release.Version)
+	u.signalRestart()
+	return release, nil
+}
+
+// download saves the archive to a temporary file and verifies the checksum.
+func (u *Updater) download(ctx context.Context, release Release) (string, error) {
+	body, err := u.get(ctx, release.URL, maxReleaseSize)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	dir := Dir(u.homeDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	archivePath := filepath.Join(dir, "download.tar.gz")
+	file, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		os.Remove(archivePath)
+		return "", err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != strings.ToLower(release.SHA256) {
+		os.Remove(archivePath)
+		return "", ErrChecksumMismatch
+	}
+	return archivePath, nil
+}
+
+// ErrTooLarge the response body exceeded the limit.
+var ErrTooLarge = errors.New("response too large")
+
+func (u *Updater) get(ctx context.Context, url string, limit int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRelease, res.Status)
+	}
+	return &limitedBody{r: res.Body, n: limit}, nil
+}
+
+// limitedBody returns ErrTooLarge instead of
+// silently truncating like io.LimitReader.
+type limitedBody struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	if b.n < 0 {
+		return 0, ErrTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.r.Close()
+}
+
+// ConfirmTrial keeps the current release if it's in trial and passes the
+// health check after the delay. If the check fails, a restart is signaled
+// and the start script rolls back the release.
+func (u *Updater) ConfirmTrial(ctx context.Context, delay time.Duration, check func() error) {
+	if !InTrial(u.homeDir) {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	version := CurrentVersion(u.homeDir)
+	if err := check(); err != nil {
+		u.logf(log.LevelError, "update: %v failed health check, rolling back: %v", version, err)
+		u.signalRestart()
+		return
+	}
+	if err := Confirm(u.homeDir); err != nil {
+		u.logf(log.LevelError, "update: confirm %v: %v", version, err)
+		return
+	}
+	u.logf(log.LevelInfo, "update: %v passed health check", version)
+}
+
+func (u *Updater) logf(level log.Level, format string, a ...interface{}) {
+	u.logger.Log(log.Entry{
+		Level: level,
+		Src:   "app",
+		Msg:   fmt.Sprintf(format, a...),
+	})
+}
+
+// parseVersion parses "vMAJOR.MINOR.PATCH".
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	fields := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if !strings.HasPrefix(version, "v") || len(fields) != 3 {
+		return parsed, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 || strconv.Itoa(n) != field {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
+
+// newer returns true if a is newer than b. Versions
+// that can't be parsed, like "dev", are the oldest.
+func newer(a, b string) bool {
+	va, ok := parseVersion(a)
+	if !ok {
+		return false
+	}
+	vb, ok := parseVersion(b)
+	if !ok {
+		return true
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i]
+		}
+	}
+	return false
+}
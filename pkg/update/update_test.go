@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	url     string
+	release Release
+	archive []byte
+}
+
+func newTestServer(t *testing.T, key ed25519.PrivateKey, version string) *testServer {
+	s := &testServer{archive: newTestArchive(t, testRelease)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(s.release) //nolint:errcheck
+	})
+	mux.HandleFunc("/release.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(s.archive) //nolint:errcheck
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	s.url = server.URL + "/manifest.json"
+	checksum := sha256.Sum256(s.archive)
+	s.release = Release{
+		Version:   version,
+		URL:       server.URL + "/release.tar.gz",
+		SHA256:    hex.EncodeToString(checksum[:]),
+		Changelog: "changes",
+	}
+	s.release.Signature = base64.StdEncoding.EncodeToString(
+		ed25519.Sign(key, s.release.SignedMessage()))
+	return s
+}
+
+func newTestUpdater(t *testing.T, version string) (*Updater, *testServer, string) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	s := newTestServer(t, key, version)
+
+	homeDir := newTestHome(t)
+	config := Config{URL: s.url, PublicKey: pub}
+	return NewUpdater(homeDir, config, http.DefaultClient, log.NewDummyLogger()), s, homeDir
+}
+
+func TestUpdate(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		u, _, homeDir := newTestUpdater(t, "v1.1.0")
+
+		info := u.Info(context.Background(), true)
+		require.True(t, info.UpdateAvailable)
+		require.Equal(t, "v1.0.0", info.Current)
+		require.Equal(t, "v1.1.0", info.Latest.Version)
+		require.Equal(t, "changes", info.Latest.Changelog)
+
+		release, err := u.Update(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "v1.1.0", release.Version)
+		require.True(t, Pending(homeDir))
+		<-u.Restart()
+
+		_, err = u.Update(context.Background())
+		require.ErrorIs(t, err, ErrInProgress)
+	})
+	t.Run("noUpdate", func(t *testing.T) {
+		u, _, _ := newTestUpdater(t, "v1.0.0")
+		_, err := u.Update(context.Background())
+		require.ErrorIs(t, err, ErrNoUpdate)
+
+		info := u.Info(context.Background(), true)
+		require.False(t, info.UpdateAvailable)
+		require.Empty(t, info.CheckError)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		u := NewUpdater(t.TempDir(), Config{}, http.DefaultClient, log.NewDummyLogger())
+		_, err := u.Update(context.Background())
+		require.ErrorIs(t, err, ErrDisabled)
+		require.False(t, u.Info(context.Background(), true).UpdatesEnabled)
+	})
+	t.Run("invalidSignature", func(t *testing.T) {
+		u, s, homeDir := newTestUpdater(t, "v1.1.0")
+		s.release.Version = "v1.2.0"
+
+		_, err := u.Update(context.Background())
+		require.ErrorIs(t, err, ErrInvalidSignature)
+		require.False(t, Pending(homeDir))
+
+		info := u.Info(context.Background(), true)
+		require.Equal(t, ErrInvalidSignature.Error(), info.CheckError)
+	})
+	t.Run("checksumMismatch", func(t *testing.T) {
+		u, s, homeDir := newTestUpdater(t, "v1.1.0")
+		s.archive = append(s.archive, 0)
+
+		_, err := u.Update(context.Background())
+		require.ErrorIs(t, err, ErrChecksumMismatch)
+		require.False(t, Pending(homeDir))
+	})
+}
+
+func TestConfirmTrial(t *testing.T) {
+	newTrial := func(t *testing.T) (*Updater, string) {
+		u, _, homeDir := newTestUpdater(t, "v1.1.0")
+		_, err := u.Update(context.Background())
+		require.NoError(t, err)
+		_, err = Apply(homeDir)
+		require.NoError(t, err)
+
+		// New process.
+		return NewUpdater(homeDir, u.config, u.client, u.logger), homeDir
+	}
+	t.Run("ok", func(t *testing.T) {
+		u, homeDir := newTrial(t)
+		u.ConfirmTrial(context.Background(), 0, func() error { return nil })
+		require.False(t, InTrial(homeDir))
+		select {
+		case <-u.Restart():
+			t.Fatal("unexpected restart")
+		default:
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		u, homeDir := newTrial(t)
+		u.ConfirmTrial(context.Background(), 0, func() error { return errors.New("x") })
+		require.True(t, InTrial(homeDir))
+		select {
+		case <-u.Restart():
+		case <-time.After(time.Second):
+			t.Fatal("no restart")
+		}
+	})
+}
+
+func TestNewer(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"v1.0.1", "v1.0.0", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "v1.99.99", true},
+		{"v1.0.0", "v1.0.0", false},
+		{"v1.0.0", "v1.0.1", false},
+		{"v1.0.0", "dev", true},
+		{"dev", "v1.0.0", false},
+		{"1.0.0", "dev", false},
+		{"v1.0", "dev", false},
+		{"v1.01.0", "dev", false},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, newer(tc.a, tc.b), "%v %v", tc.a, tc.b)
+	}
+}
@@ -2,53 +2,74 @@ package video
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"nvr/pkg/log"
 	"nvr/pkg/storage"
 	"nvr/pkg/video/gortsplib"
 	"nvr/pkg/video/hls"
-	"strconv"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Server is an instance of rtsp-simple-server.
 type Server struct {
+	// Addresses that local clients connect to.
 	rtspAddress string
 	hlsAddress  string
-	pathManager *pathManager
-	rtspServer  *rtspServer
-	hlsServer   *hlsServer
-	wg          *sync.WaitGroup
+
+	hlsAddresses []string
+	pathManager  *pathManager
+	rtspServer   *rtspServer
+	hlsServer    *hlsServer
+	rtmpServer   *rtmpServer
+	wg           *sync.WaitGroup
+
+	memoryBudget *hls.MemoryBudget
 }
 
 const readBufferCount = 2048
 
 // NewServer allocates a server.
 func NewServer(log *log.Logger, wg *sync.WaitGroup, env storage.ConfigEnv) *Server {
-	rtspAddress := func() string {
-		if env.RTSPPortExpose {
-			return ":" + strconv.Itoa(env.RTSPPort)
-		}
-		return "127.0.0.1:" + strconv.Itoa(env.RTSPPort)
-	}()
-	hlsAddress := func() string {
-		if env.HLSPortExpose {
-			return ":" + strconv.Itoa(env.HLSPort)
-		}
-		return "127.0.0.1:" + strconv.Itoa(env.HLSPort)
-	}()
-
-	hlsServer := newHLSServer(wg, readBufferCount, log)
+	rtspAddresses := listenAddresses(env.RTSPBind, env.RTSPPort)
+	hlsAddresses := listenAddresses(env.HLSBind, env.HLSPort)
+
+	memoryBudget := hls.NewMemoryBudget(
+		int64(env.HLSMemory.Limit)*int64(mb),
+		int64(env.HLSMemory.PathLimit)*int64(mb),
+	)
+	timeshiftDir := env.HLSTimeshift.Dir
+	if timeshiftDir == "" {
+		timeshiftDir = env.TempDir
+	}
+	timeshift := timeshiftConfig{
+		// The directory is cleared on start.
+		dir:    filepath.Join(timeshiftDir, "timeshift"),
+		window: time.Duration(env.HLSTimeshift.Minutes) * time.Minute,
+	}
+	hlsServer := newHLSServer(wg, readBufferCount, memoryBudget, timeshift, log)
 	pathManager := newPathManager(wg, log, hlsServer)
-	rtspServer := newRTSPServer(wg, rtspAddress, readBufferCount, pathManager, log)
+	rtspServer := newRTSPServer(wg, rtspAddresses, readBufferCount, pathManager, log)
+
+	var rtmpServer *rtmpServer
+	if env.Ingest.RTMPPort != 0 {
+		rtmpAddresses := listenAddresses(env.Ingest.RTMPBind, env.Ingest.RTMPPort)
+		rtmpServer = newRTMPServer(wg, rtmpAddresses, env.Ingest.Streams, pathManager, log)
+	}
 
 	return &Server{
-		rtspAddress: rtspAddress,
-		hlsAddress:  hlsAddress,
-		pathManager: pathManager,
-		rtspServer:  rtspServer,
-		hlsServer:   hlsServer,
-		wg:          wg,
+		rtspAddress:  clientAddress(env.RTSPBind, env.RTSPPort),
+		hlsAddress:   clientAddress(env.HLSBind, env.HLSPort),
+		hlsAddresses: hlsAddresses,
+		pathManager:  pathManager,
+		rtspServer:   rtspServer,
+		hlsServer:    hlsServer,
+		rtmpServer:   rtmpServer,
+		wg:           wg,
+
+		memoryBudget: memoryBudget,
 	}
 }
 
@@ -62,10 +83,17 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 
-	if err := s.hlsServer.start(ctx2, s.hlsAddress); err != nil {
+	if err := s.hlsServer.start(ctx2, s.hlsAddresses); err != nil {
 		cancel()
 		return err
 	}
+
+	if s.rtmpServer != nil {
+		if err := s.rtmpServer.start(ctx2); err != nil {
+			cancel()
+			return err
+		}
+	}
 	return nil
 }
 
@@ -81,6 +109,7 @@ type IHLSMuxer interface {
 	AudioTrack() *gortsplib.TrackMPEG4Audio
 	WaitForSegFinalized()
 	NextSegment(maybePrevSeg *hls.Segment) (*hls.Segment, error)
+	LatestSegment() (*hls.Segment, error)
 }
 
 // ServerPath .
@@ -111,6 +140,54 @@ func (s *Server) PathExist(name string) bool {
 	return s.pathManager.pathExist(name)
 }
 
+// PathStats returns the statistics of all paths.
+func (s *Server) PathStats() []PathStats {
+	return s.pathManager.pathStats()
+}
+
+// RTSPReaders returns the RTSP clients that are reading a path.
+func (s *Server) RTSPReaders() []RTSPReader {
+	return s.pathManager.rtspReaders()
+}
+
+// CloseRTSPReader disconnects a RTSP reader by session ID.
+func (s *Server) CloseRTSPReader(id string) error {
+	return s.pathManager.readerClose(id)
+}
+
+// HLSMemory returns the statistics of the
+// memory budget shared by the HLS muxers.
+func (s *Server) HLSMemory() hls.MemoryBudgetStats {
+	return s.memoryBudget.Stats()
+}
+
+// LiveMuxer returns the HLS muxer of a path. Used to
+// stream the fMP4 parts directly to the clients.
+func (s *Server) LiveMuxer(ctx context.Context, pathName string) (*hls.Muxer, error) {
+	if !s.PathExist(pathName) {
+		return nil, ErrPathNotExist
+	}
+	muxer, err := s.hlsServer.MuxerByPathName(ctx, pathName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: (%s)", ErrPathNoOnePublishing, pathName)
+	}
+	return muxer, nil
+}
+
+// StreamBitrate returns the bitrate of the latest
+// HLS segment of a path in bits per second.
+func (s *Server) StreamBitrate(ctx context.Context, pathName string) (int64, error) {
+	muxer, err := s.LiveMuxer(ctx, pathName)
+	if err != nil {
+		return 0, err
+	}
+	segment, err := muxer.LatestSegment()
+	if err != nil {
+		return 0, err
+	}
+	return segment.Bitrate(), nil
+}
+
 // HandleHLS handle hls requests.
 func (s *Server) HandleHLS() http.HandlerFunc {
 	return s.hlsServer.HandleRequest()
@@ -2,11 +2,15 @@ package video
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
 	"nvr/pkg/log"
+	"nvr/pkg/video/hls"
 
 	"github.com/stretchr/testify/require"
 )
@@ -57,3 +61,223 @@ func TestNewPath(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 	require.False(t, p.PathExist("mypath"))
 }
+
+func TestPathStats(t *testing.T) {
+	p, cancel := newTestServer(t)
+	defer cancel()
+
+	ctx, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	_, err := p.NewPath(ctx, "b", PathConf{MonitorID: "x", IsSub: true})
+	require.NoError(t, err)
+	_, err = p.NewPath(ctx, "a", PathConf{MonitorID: "x"})
+	require.NoError(t, err)
+
+	expected := []PathStats{
+		{Name: "a", MonitorID: "x"},
+		{Name: "b", MonitorID: "x", IsSub: true},
+	}
+	require.Equal(t, expected, p.PathStats())
+}
+
+func TestNewPathAlreadyExist(t *testing.T) {
+	p, cancel := newTestServer(t)
+	defer cancel()
+
+	ctx, cancel2 := context.WithCancel(context.Background())
+	_, err := p.NewPath(ctx, "x", PathConf{MonitorID: "x"})
+	require.NoError(t, err)
+
+	_, err = p.NewPath(context.Background(), "x", PathConf{MonitorID: "x"})
+	require.ErrorIs(t, err, ErrPathAlreadyExist)
+
+	// The name can be reused after the path is removed.
+	cancel2()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx3, cancel3 := context.WithCancel(context.Background())
+	defer cancel3()
+	_, err = p.NewPath(ctx3, "x", PathConf{MonitorID: "x"})
+	require.NoError(t, err)
+}
+
+func TestSnapshotMap(t *testing.T) {
+	var m snapshotMap[int]
+	_, exist := m.get("a")
+	require.False(t, exist)
+
+	err := m.update(func(m map[string]int) error {
+		m["a"] = 1
+		return nil
+	})
+	require.NoError(t, err)
+	old := m.load()
+
+	// Failed updates are discarded.
+	err = m.update(func(m map[string]int) error {
+		m["b"] = 2
+		return context.Canceled
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	err = m.update(func(m map[string]int) error {
+		delete(m, "a")
+		m["c"] = 3
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Loaded snapshots are not modified by later updates.
+	require.Equal(t, map[string]int{"a": 1}, old)
+	require.Equal(t, map[string]int{"c": 3}, m.load())
+}
+
+func TestHLSMuxerByPathName(t *testing.T) {
+	s := newHLSServer(nil, 0, nil, timeshiftConfig{}, nil)
+	s.ctx = context.Background()
+
+	_, err := s.MuxerByPathName(context.Background(), "x")
+	require.ErrorIs(t, err, context.Canceled)
+
+	muxer := &hls.Muxer{}
+	m := &HLSMuxer{muxer: muxer}
+	s.setMuxer("x", m)
+
+	actual, err := s.MuxerByPathName(context.Background(), "x")
+	require.NoError(t, err)
+	require.Equal(t, muxer, actual)
+
+	// Replaced muxers are not deleted.
+	s.deleteMuxer("x", &HLSMuxer{})
+	_, err = s.MuxerByPathName(context.Background(), "x")
+	require.NoError(t, err)
+
+	s.deleteMuxer("x", m)
+	_, err = s.MuxerByPathName(context.Background(), "x")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// Lookups while other paths are added and removed.
+func BenchmarkPathExist(b *testing.B) {
+	wg := sync.WaitGroup{}
+	pm := newPathManager(&wg, log.NewDummyLogger(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 100; i++ {
+		_, err := pm.AddPath(ctx, strconv.Itoa(i), PathConf{MonitorID: "x"})
+		require.NoError(b, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ctx2, cancel2 := context.WithCancel(ctx)
+			pm.AddPath(ctx2, "churn"+strconv.Itoa(i), PathConf{MonitorID: "x"}) //nolint:errcheck
+			cancel2()
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if !pm.pathExist(strconv.Itoa(i % 100)) {
+				b.Error("path should exist")
+			}
+			i++
+		}
+	})
+	b.StopTimer()
+
+	close(done)
+	cancel()
+	wg.Wait()
+}
+
+func BenchmarkHLSMuxerByPathName(b *testing.B) {
+	s := newHLSServer(nil, 0, nil, timeshiftConfig{}, nil)
+	s.ctx = context.Background()
+	for i := 0; i < 100; i++ {
+		s.setMuxer(strconv.Itoa(i), &HLSMuxer{muxer: &hls.Muxer{}})
+	}
+
+	ctx := context.Background()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := s.MuxerByPathName(ctx, strconv.Itoa(i%100)); err != nil {
+				b.Error(err)
+			}
+			i++
+		}
+	})
+}
+
+func TestHLSClientCount(t *testing.T) {
+	m := &HLSMuxer{clients: make(map[string]time.Time)}
+	now := time.Unix(100, 0)
+
+	m.clientSeen("a", now)
+	m.clientSeen("a", now)
+	m.clientSeen("b", now.Add(-hlsClientTimeout-time.Second))
+	m.clientSeen("c", now)
+
+	require.Equal(t, 2, m.clientCount(now))
+	require.Equal(t, 0, m.clientCount(now.Add(hlsClientTimeout+time.Second)))
+}
+
+func TestHLSClientID(t *testing.T) {
+	newRequest := func(cookie string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/hls/m1/stream.m3u8", nil)
+		r.RemoteAddr = "10.0.0.1:1000"
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: hlsSessionCookie, Value: cookie})
+		}
+		return r
+	}
+
+	// Two clients behind the same NAT or proxy.
+	m := &HLSMuxer{clients: make(map[string]time.Time)}
+	now := time.Unix(100, 0)
+	m.clientSeen(hlsClientID(httptest.NewRecorder(), newRequest("a")), now)
+	m.clientSeen(hlsClientID(httptest.NewRecorder(), newRequest("b")), now)
+	m.clientSeen(hlsClientID(httptest.NewRecorder(), newRequest("a")), now)
+	require.Equal(t, 2, m.clientCount(now))
+
+	t.Run("newSession", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		require.Equal(t, "addr:10.0.0.1", hlsClientID(w, newRequest("")))
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		require.Equal(t, hlsSessionCookie, cookies[0].Name)
+		require.Len(t, cookies[0].Value, 32)
+		require.True(t, cookies[0].HttpOnly)
+
+		w = httptest.NewRecorder()
+		id := hlsClientID(w, newRequest(cookies[0].Value))
+		require.Equal(t, "session:"+cookies[0].Value, id)
+		require.Empty(t, w.Result().Cookies())
+	})
+}
+
+func TestRTSPReaders(t *testing.T) {
+	p, cancel := newTestServer(t)
+	defer cancel()
+
+	ctx, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	_, err := p.NewPath(ctx, "a", PathConf{MonitorID: "x"})
+	require.NoError(t, err)
+
+	require.Equal(t, []RTSPReader{}, p.RTSPReaders())
+	require.ErrorIs(t, p.CloseRTSPReader("123"), ErrReaderNotExist)
+}
@@ -0,0 +1,71 @@
+package customformat
+
+import (
+	"fmt"
+	"os"
+)
+
+// Recover truncates a meta and mdat file that were left half-written
+// by a crash to the last complete sample and returns the header and the
+// remaining samples. The data of a sample is written to the mdat file
+// before the sample is written to the meta file, so a sample is only
+// incomplete if the mdat file was truncated by a power loss.
+func Recover(metaPath string, mdatPath string) (*Header, []Sample, error) {
+	mdatInfo, err := os.Stat(mdatPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, samples, err := RecoverMeta(metaPath, mdatInfo.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mdatEnd int64
+	if len(samples) > 0 {
+		last := samples[len(samples)-1]
+		mdatEnd = int64(last.Offset) + int64(last.Size)
+	}
+	if err := os.Truncate(mdatPath, mdatEnd); err != nil {
+		return nil, nil, fmt.Errorf("truncate mdat: %w", err)
+	}
+	return header, samples, nil
+}
+
+// RecoverMeta truncates the meta file to the last sample that's within
+// mdatSize and leaves the mdat file unchanged. Used when the size of the
+// data in the mdat file isn't the size of the file, i.e. if it's encrypted.
+func RecoverMeta(metaPath string, mdatSize int64) (*Header, []Sample, error) {
+	meta, err := os.OpenFile(metaPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer meta.Close()
+
+	metaInfo, err := meta.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, header, err := NewReader(meta, int(metaInfo.Size()))
+	if err != nil {
+		return nil, nil, err
+	}
+	samples, err := reader.ReadAllSamples()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read samples: %w", err)
+	}
+
+	n := 0
+	for n < len(samples) && int64(samples[n].Offset)+int64(samples[n].Size) <= mdatSize {
+		n++
+	}
+	samples = samples[:n]
+
+	if err := meta.Truncate(int64(header.Size() + n*sampleSize)); err != nil {
+		return nil, nil, fmt.Errorf("truncate meta: %w", err)
+	}
+	if err := meta.Sync(); err != nil {
+		return nil, nil, err
+	}
+	return header, samples, nil
+}
@@ -0,0 +1,82 @@
+package customformat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	metaPath := filepath.Join(dir, "x.meta")
+	mdatPath := filepath.Join(dir, "x.mdat")
+
+	header := Header{
+		VideoSPS:  []byte{1},
+		VideoPPS:  []byte{2},
+		StartTime: 1000,
+	}
+	samples := []Sample{
+		{IsSyncSample: true, DTS: 1000, Next: 2000, Offset: 0, Size: 4},
+		{DTS: 2000, Next: 3000, Offset: 4, Size: 4},
+		{DTS: 3000, Next: 4000, Offset: 8, Size: 4},
+	}
+	meta := header.Marshal()
+	for _, s := range samples {
+		meta = append(meta, s.Marshal()...)
+	}
+	// Partial sample.
+	meta = append(meta, samples[0].Marshal()[:10]...)
+
+	t.Run("ok", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(metaPath, meta, 0o600))
+		// The data of the last sample is incomplete.
+		require.NoError(t, os.WriteFile(mdatPath, make([]byte, 10), 0o600))
+
+		gotHeader, gotSamples, err := Recover(metaPath, mdatPath)
+		require.NoError(t, err)
+		require.Equal(t, header.StartTime, gotHeader.StartTime)
+		require.Equal(t, samples[:2], gotSamples)
+
+		metaInfo, err := os.Stat(metaPath)
+		require.NoError(t, err)
+		require.Equal(t, int64(header.Size()+2*sampleSize), metaInfo.Size())
+
+		mdatInfo, err := os.Stat(mdatPath)
+		require.NoError(t, err)
+		require.Equal(t, int64(8), mdatInfo.Size())
+	})
+	t.Run("emptyMdat", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(metaPath, meta, 0o600))
+		require.NoError(t, os.WriteFile(mdatPath, nil, 0o600))
+
+		_, gotSamples, err := Recover(metaPath, mdatPath)
+		require.NoError(t, err)
+		require.Empty(t, gotSamples)
+
+		metaInfo, err := os.Stat(metaPath)
+		require.NoError(t, err)
+		require.Equal(t, int64(header.Size()), metaInfo.Size())
+	})
+	t.Run("metaOnly", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(metaPath, meta, 0o600))
+		require.NoError(t, os.WriteFile(mdatPath, make([]byte, 20), 0o600))
+
+		_, gotSamples, err := RecoverMeta(metaPath, 9)
+		require.NoError(t, err)
+		require.Equal(t, samples[:2], gotSamples)
+
+		mdatInfo, err := os.Stat(mdatPath)
+		require.NoError(t, err)
+		require.Equal(t, int64(20), mdatInfo.Size())
+	})
+	t.Run("truncatedHeader", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(metaPath, meta[:5], 0o600))
+		require.NoError(t, os.WriteFile(mdatPath, nil, 0o600))
+
+		_, _, err := Recover(metaPath, mdatPath)
+		require.Error(t, err)
+	})
+}
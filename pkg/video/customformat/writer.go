@@ -1,6 +1,7 @@
 package customformat
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -14,6 +15,13 @@ type Writer struct {
 	mdat io.Writer // Output file.
 
 	mdatPos int
+	metaBuf bytes.Buffer
+}
+
+// flusher is implemented by mdat writers that buffer data, the
+// buffered data is flushed before the samples are written to meta.
+type flusher interface {
+	Flush() error
 }
 
 // NewWriter creates a new Writer and writes the header.
@@ -32,7 +40,9 @@ func NewWriter(meta io.Writer, mdat io.Writer, header Header) (*Writer, error) {
 }
 
 // WriteSegment Writes a HLS segment in the custom format to the output files.
+// The samples are written to meta after their data is written to mdat.
 func (w *Writer) WriteSegment(segment *hls.Segment) error {
+	w.metaBuf.Reset()
 	samples := sortSamples(*segment)
 	for _, s := range samples {
 		switch v := s.(type) {
@@ -48,6 +58,15 @@ func (w *Writer) WriteSegment(segment *hls.Segment) error {
 			log.Fatalf("unexpected type: %v", v)
 		}
 	}
+
+	if f, ok := w.mdat.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("flush mdat: %w", err)
+		}
+	}
+	if _, err := w.meta.Write(w.metaBuf.Bytes()); err != nil {
+		return fmt.Errorf("write meta: %w", err)
+	}
 	return nil
 }
 
@@ -102,11 +121,7 @@ func (w *Writer) writeVideoSample(sample *hls.VideoSample) error {
 	}
 	w.mdatPos += n
 
-	_, err = w.meta.Write(marshaled)
-	if err != nil {
-		return err
-	}
-
+	w.metaBuf.Write(marshaled)
 	return nil
 }
 
@@ -126,10 +141,6 @@ func (w *Writer) writeAudioSample(sample *hls.AudioSample) error {
 	}
 	w.mdatPos += n
 
-	_, err = w.meta.Write(marshaled)
-	if err != nil {
-		return err
-	}
-
+	w.metaBuf.Write(marshaled)
 	return nil
 }
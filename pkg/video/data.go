@@ -37,6 +37,13 @@ func (d *dataH264) getNTP() time.Time {
 	return d.ntp
 }
 
+// H264Frame H264 NALUs with the same timestamp.
+type H264Frame struct {
+	NTP   time.Time
+	PTS   time.Duration
+	NALUs [][]byte
+}
+
 type dataMPEG4Audio struct {
 	trackID    int
 	rtpPackets []*rtp.Packet
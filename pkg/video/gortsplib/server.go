@@ -111,7 +111,7 @@ func NewServer(
 	writeTimeout time.Duration,
 	readBufferCount int,
 	writeBufferCount int,
-	address string,
+	listener net.Listener,
 ) *Server {
 	return &Server{
 		handler:          handler,
@@ -119,7 +119,7 @@ func NewServer(
 		writeTimeout:     writeTimeout,
 		readBufferCount:  readBufferCount,
 		writeBufferCount: writeBufferCount,
-		rtspAddress:      address,
+		tcpListener:      listener,
 	}
 }
 
@@ -161,14 +161,16 @@ func (s *Server) Start() error {
 		s.checkStreamPeriod = 1 * time.Second
 	}
 
-	if s.rtspAddress == "" {
-		return ErrServerMissingRTSPaddress
-	}
-
-	var err error
-	s.tcpListener, err = s.listen("tcp", s.rtspAddress)
-	if err != nil {
-		return err
+	// The listener may be provided by NewServer.
+	if s.tcpListener == nil {
+		if s.rtspAddress == "" {
+			return ErrServerMissingRTSPaddress
+		}
+		var err error
+		s.tcpListener, err = s.listen("tcp", s.rtspAddress)
+		if err != nil {
+			return err
+		}
 	}
 
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/rtp"
@@ -168,6 +169,7 @@ type ServerSession struct {
 	announcedTracks    []*ServerSessionAnnouncedTrack // publish
 	writerRunning      bool
 	writeBuffer        *ringbuffer.RingBuffer
+	bytesSent          atomic.Uint64
 
 	// writer channels
 	writerDone chan struct{}
@@ -211,6 +213,11 @@ func (ss *ServerSession) Close() error {
 	return nil
 }
 
+// BytesSent returns the number of bytes written to the reader.
+func (ss *ServerSession) BytesSent() uint64 {
+	return ss.bytesSent.Load()
+}
+
 // State returns the state of the session.
 func (ss *ServerSession) State() ServerSessionState {
 	return ss.state
@@ -813,7 +820,10 @@ func (ss *ServerSession) runWriter() {
 		fr.Payload = payload
 
 		ss.tcpConn.nconn.SetWriteDeadline(time.Now().Add(ss.s.writeTimeout)) //nolint:errcheck
-		ss.tcpConn.conn.WriteInterleavedFrame(fr, buf)                       //nolint:errcheck
+		if err := ss.tcpConn.conn.WriteInterleavedFrame(fr, buf); err == nil {
+			// 4 byte interleaved header.
+			ss.bytesSent.Add(uint64(len(payload) + 4))
+		}
 	}
 
 	for {
@@ -371,7 +371,7 @@ func initGenerateMvex(audioTrackExist bool) mp4.Boxes {
 	return mvex
 }
 
-func generateInit( //nolint:funlen
+func GenerateInit( //nolint:funlen
 	videoTrack *gortsplib.TrackH264,
 	audioTrack *gortsplib.TrackMPEG4Audio,
 ) ([]byte, error) {
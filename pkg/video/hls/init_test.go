@@ -20,7 +20,7 @@ func TestGenerateInit(t *testing.T) {
 	videoTrack := &gortsplib.TrackH264{SPS: sps}
 	audioTrack := &gortsplib.TrackMPEG4Audio{Config: &mpeg4audio.Config{ChannelCount: 1}}
 
-	actual, err := generateInit(
+	actual, err := GenerateInit(
 		videoTrack,
 		audioTrack,
 	)
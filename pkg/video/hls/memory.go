@@ -0,0 +1,81 @@
+package hls
+
+import (
+	"sync/atomic"
+)
+
+// MemoryBudget limits the memory used by the cached segments of
+// all muxers that share it. Zero limits are unlimited.
+type MemoryBudget struct {
+	limit     int64
+	pathLimit int64
+	used      atomic.Int64
+}
+
+// NewMemoryBudget allocates a budget. The limit is shared by all
+// muxers and the path limit applies to each muxer, in bytes.
+func NewMemoryBudget(limit int64, pathLimit int64) *MemoryBudget {
+	return &MemoryBudget{
+		limit:     limit,
+		pathLimit: pathLimit,
+	}
+}
+
+// MemoryBudgetStats statistics of a memory budget.
+type MemoryBudgetStats struct {
+	Used      int64 `json:"used"`
+	Limit     int64 `json:"limit"`
+	PathLimit int64 `json:"pathLimit"`
+}
+
+// Stats returns the statistics of the budget.
+func (b *MemoryBudget) Stats() MemoryBudgetStats {
+	if b == nil {
+		return MemoryBudgetStats{}
+	}
+	return MemoryBudgetStats{
+		Used:      b.used.Load(),
+		Limit:     b.limit,
+		PathLimit: b.pathLimit,
+	}
+}
+
+func (b *MemoryBudget) add(n int64) {
+	if b != nil {
+		b.used.Add(n)
+	}
+}
+
+// exceeded returns true if the path or the shared limit is exceeded.
+func (b *MemoryBudget) exceeded(pathUsed int64) bool {
+	if b == nil {
+		return false
+	}
+	if b.pathLimit > 0 && pathUsed > b.pathLimit {
+		return true
+	}
+	return b.limit > 0 && b.used.Load() > b.limit
+}
+
+// MemoryStats statistics of the segments cached by a muxer.
+type MemoryStats struct {
+	// Bytes used by the cached segments.
+	Bytes int64 `json:"bytes"`
+
+	// Number of cached segments.
+	Segments int `json:"segments"`
+
+	// Segments evicted early to stay within the memory budget.
+	Evictions uint64 `json:"evictions"`
+}
+
+// Minimum number of segments each muxer keeps regardless of the budget.
+const minCachedSegments = 3
+
+func (s *Segment) memorySize() int64 {
+	var size int64
+	for _, part := range s.Parts {
+		size += int64(len(part.renderedContent))
+	}
+	return size
+}
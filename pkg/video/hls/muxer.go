@@ -36,7 +36,7 @@ type Muxer struct {
 // ErrTrackInvalid invalid H264 track: SPS or PPS not provided into the SDP.
 var ErrTrackInvalid = errors.New("invalid H264 track: SPS or PPS not provided into the SDP")
 
-// NewMuxer allocates a Muxer.
+// NewMuxer allocates a Muxer. Timeshift is optional.
 func NewMuxer(
 	ctx context.Context,
 	id uint16,
@@ -44,11 +44,13 @@ func NewMuxer(
 	segmentDuration time.Duration,
 	partDuration time.Duration,
 	segmentMaxSize uint64,
+	budget *MemoryBudget,
+	timeshift *Timeshift,
 	logf log.Func,
 	videoTrack *gortsplib.TrackH264,
 	audioTrack *gortsplib.TrackMPEG4Audio,
 ) *Muxer {
-	playlist := newPlaylist(ctx, id, segmentCount)
+	playlist := newPlaylist(ctx, id, segmentCount, budget)
 	go playlist.start()
 
 	m := &Muxer{
@@ -57,6 +59,16 @@ func NewMuxer(
 		videoTrack: videoTrack,
 	}
 
+	onSegmentFinalized := m.playlist.onSegmentFinalized
+	if timeshift != nil {
+		onSegmentFinalized = func(segment *Segment) {
+			if err := timeshift.add(segment); err != nil && !errors.Is(err, ErrTimeshiftClosed) {
+				logf(log.LevelError, "%v", err)
+			}
+			m.playlist.onSegmentFinalized(segment)
+		}
+	}
+
 	m.segmenter = newSegmenter(
 		id,
 		time.Now().UnixNano(),
@@ -65,7 +77,7 @@ func NewMuxer(
 		segmentMaxSize,
 		videoTrack,
 		audioTrack,
-		m.playlist.onSegmentFinalized,
+		onSegmentFinalized,
 		m.playlist.partFinalized,
 	)
 	return m
@@ -96,22 +108,10 @@ func (m *Muxer) File(
 	}
 
 	if name == "init.mp4" {
-		m.mutex.Lock()
-		defer m.mutex.Unlock()
-
-		sps := m.videoTrack.SPS
-
-		if m.initContent == nil ||
-			(!bytes.Equal(m.videoLastSPS, sps) ||
-				!bytes.Equal(m.videoLastPPS, m.videoTrack.PPS)) {
-			initContent, err := generateInit(m.videoTrack, m.audioTrack)
-			if err != nil {
-				m.logf(log.LevelError, "generate init.mp4: %w", err)
-				return &MuxerFileResponse{Status: http.StatusInternalServerError}
-			}
-			m.videoLastSPS = m.videoTrack.SPS
-			m.videoLastPPS = m.videoTrack.PPS
-			m.initContent = initContent
+		initContent, err := m.Init()
+		if err != nil {
+			m.logf(log.LevelError, "generate init.mp4: %w", err)
+			return &MuxerFileResponse{Status: http.StatusInternalServerError}
 		}
 
 		return &MuxerFileResponse{
@@ -119,13 +119,49 @@ func (m *Muxer) File(
 			Header: map[string]string{
 				"Content-Type": "video/mp4",
 			},
-			Body: bytes.NewReader(m.initContent),
+			Body: bytes.NewReader(initContent),
 		}
 	}
 
 	return m.playlist.file(name, msn, part, skip)
 }
 
+// Init returns the fMP4 initialization segment, init.mp4.
+func (m *Muxer) Init() ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sps := m.videoTrack.SPS
+
+	if m.initContent == nil ||
+		(!bytes.Equal(m.videoLastSPS, sps) ||
+			!bytes.Equal(m.videoLastPPS, m.videoTrack.PPS)) {
+		initContent, err := GenerateInit(m.videoTrack, m.audioTrack)
+		if err != nil {
+			return nil, err
+		}
+		m.videoLastSPS = m.videoTrack.SPS
+		m.videoLastPPS = m.videoTrack.PPS
+		m.initContent = initContent
+	}
+	return m.initContent, nil
+}
+
+// Codecs returns the RFC 6381 codecs string of the
+// tracks, for example "avc1.640029,mp4a.40.2".
+func (m *Muxer) Codecs() string {
+	return codecs(m.videoTrack, m.audioTrack)
+}
+
+// Subscribe returns a channel that receives every finalized part,
+// starting with the next independent part. Parts can be appended
+// after init.mp4 to play the stream with Media Source Extensions.
+// The channel is closed when ctx is canceled, the muxer is closed
+// or if the subscriber falls too far behind.
+func (m *Muxer) Subscribe(ctx context.Context) <-chan *MuxerPart {
+	return m.playlist.subscribe(ctx)
+}
+
 // VideoTrack returns the stream video track.
 func (m *Muxer) VideoTrack() *gortsplib.TrackH264 {
 	return m.videoTrack
@@ -143,10 +179,21 @@ func (m *Muxer) WaitForSegFinalized() {
 
 // NextSegment returns the first segment with a ID greater than prevID.
 // Will wait for new segments if the next segment isn't cached.
+// Returns ErrFellBehind if the next segment has been evicted.
 func (m *Muxer) NextSegment(maybePrevSeg *Segment) (*Segment, error) {
 	return m.playlist.nextSegment(maybePrevSeg)
 }
 
+// MemoryStats returns the memory statistics of the cached segments.
+func (m *Muxer) MemoryStats() MemoryStats {
+	return m.playlist.memoryStats()
+}
+
+// LatestSegment returns the most recent finalized segment.
+func (m *Muxer) LatestSegment() (*Segment, error) {
+	return m.playlist.latestSegment()
+}
+
 // VideoTimescale the number of time units that pass per second.
 const VideoTimescale = 90000
 
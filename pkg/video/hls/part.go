@@ -183,7 +183,7 @@ func generateAudioTraf(
 	}
 }
 
-func generatePart( //nolint:funlen
+func GeneratePart( //nolint:funlen
 	muxerStartTime int64,
 	audioTrack *gortsplib.TrackMPEG4Audio,
 	videoSamples []*VideoSample,
@@ -312,6 +312,16 @@ func (p *MuxerPart) name() string {
 	return partName(p.id)
 }
 
+// Content returns the rendered fMP4 fragment, moof and mdat boxes.
+func (p *MuxerPart) Content() []byte {
+	return p.renderedContent
+}
+
+// IsIndependent returns true if the part starts with a IDR frame.
+func (p *MuxerPart) IsIndependent() bool {
+	return p.isIndependent
+}
+
 func (p *MuxerPart) reader() io.Reader {
 	return bytes.NewReader(p.renderedContent)
 }
@@ -327,7 +337,7 @@ func (p *MuxerPart) duration() time.Duration {
 func (p *MuxerPart) finalize() error {
 	if len(p.VideoSamples) > 0 || len(p.AudioSamples) > 0 {
 		var err error
-		p.renderedContent, err = generatePart(
+		p.renderedContent, err = GeneratePart(
 			p.muxerStartTime,
 			p.audioTrack,
 			p.VideoSamples,
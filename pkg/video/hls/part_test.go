@@ -13,7 +13,7 @@ import (
 
 func TestGeneratePart(t *testing.T) {
 	t.Run("minimal", func(t *testing.T) {
-		actual, err := generatePart(
+		actual, err := GeneratePart(
 			0,
 			&gortsplib.TrackMPEG4Audio{},
 			[]*VideoSample{{
@@ -49,7 +49,7 @@ func TestGeneratePart(t *testing.T) {
 		require.Equal(t, expected, actual)
 	})
 	t.Run("videoSample", func(t *testing.T) {
-		actual, err := generatePart(
+		actual, err := GeneratePart(
 			0,
 			&gortsplib.TrackMPEG4Audio{},
 			[]*VideoSample{{
@@ -86,7 +86,7 @@ func TestGeneratePart(t *testing.T) {
 		require.Equal(t, expected, actual)
 	})
 	t.Run("audioSample", func(t *testing.T) {
-		actual, err := generatePart(
+		actual, err := GeneratePart(
 			0,
 			&gortsplib.TrackMPEG4Audio{Config: &mpeg4audio.Config{}},
 			[]*VideoSample{{
@@ -139,7 +139,7 @@ func TestGeneratePart(t *testing.T) {
 		require.Equal(t, expected, actual)
 	})
 	t.Run("videoAndAudioSample", func(t *testing.T) {
-		actual, err := generatePart(
+		actual, err := GeneratePart(
 			0,
 			&gortsplib.TrackMPEG4Audio{Config: &mpeg4audio.Config{}},
 			[]*VideoSample{{
@@ -193,7 +193,7 @@ func TestGeneratePart(t *testing.T) {
 		require.Equal(t, expected, actual)
 	})
 	t.Run("multipleVideoSample", func(t *testing.T) {
-		actual, err := generatePart(
+		actual, err := GeneratePart(
 			0,
 			&gortsplib.TrackMPEG4Audio{},
 			[]*VideoSample{
@@ -266,7 +266,7 @@ func TestGeneratePart(t *testing.T) {
 			Duration:   133333333,
 		}
 
-		actual, err := generatePart(
+		actual, err := GeneratePart(
 			muxerStartTime,
 			&gortsplib.TrackMPEG4Audio{
 				Config: &mpeg4audio.Config{ChannelCount: 1, SampleRate: 44100},
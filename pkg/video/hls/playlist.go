@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
-	"io"
+	"errors"
 	"math"
 	"net/http"
 	"nvr/pkg/video/gortsplib"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -74,6 +75,7 @@ type playlist struct {
 	muxerID uint16
 
 	segmentCount int
+	budget       *MemoryBudget
 
 	segments           []SegmentOrGap
 	segmentsByName     map[string]*Segment
@@ -83,10 +85,20 @@ type playlist struct {
 	nextSegmentParts   []*MuxerPart
 	nextPartID         uint64
 
+	// ID of the last evicted segment, readers
+	// that haven't read it have fallen behind.
+	lastEvictedID uint64
+	hasEvicted    bool
+
+	memoryUsed     atomic.Int64
+	cachedSegments atomic.Int64
+	evictions      atomic.Uint64
+
 	playlistsOnHold    map[blockingPlaylistRequest]struct{}
 	partsOnHold        map[blockingPartRequest]struct{}
 	segFinalOnHold     map[chan struct{}]struct{}
 	nextSegmentsOnHold map[nextSegmentRequest2]struct{}
+	subscribers        map[*partSubscriber]struct{}
 
 	chPlaylist         chan playlistRequest
 	chSegment          chan segmentRequest
@@ -96,13 +108,22 @@ type playlist struct {
 	chBlockingPart     chan blockingPartRequest
 	chWaitForSegFinal  chan chan struct{}
 	chNextSegment      chan nextSegmentRequest
+	chLatestSegment    chan chan *Segment
+	chSubscribe        chan *partSubscriber
+	chUnsubscribe      chan *partSubscriber
 }
 
-func newPlaylist(ctx context.Context, muxerID uint16, segmentCount int) *playlist {
+func newPlaylist(
+	ctx context.Context,
+	muxerID uint16,
+	segmentCount int,
+	budget *MemoryBudget,
+) *playlist {
 	return &playlist{
 		ctx:            ctx,
 		muxerID:        muxerID,
 		segmentCount:   segmentCount,
+		budget:         budget,
 		segmentsByName: make(map[string]*Segment),
 		partsByName:    make(map[string]*MuxerPart),
 
@@ -110,6 +131,7 @@ func newPlaylist(ctx context.Context, muxerID uint16, segmentCount int) *playlis
 		partsOnHold:        make(map[blockingPartRequest]struct{}),
 		segFinalOnHold:     make(map[chan struct{}]struct{}),
 		nextSegmentsOnHold: make(map[nextSegmentRequest2]struct{}),
+		subscribers:        make(map[*partSubscriber]struct{}),
 
 		chPlaylist:         make(chan playlistRequest),
 		chSegment:          make(chan segmentRequest),
@@ -119,6 +141,9 @@ func newPlaylist(ctx context.Context, muxerID uint16, segmentCount int) *playlis
 		chBlockingPart:     make(chan blockingPartRequest),
 		chWaitForSegFinal:  make(chan chan struct{}),
 		chNextSegment:      make(chan nextSegmentRequest),
+		chLatestSegment:    make(chan chan *Segment),
+		chSubscribe:        make(chan *partSubscriber),
+		chUnsubscribe:      make(chan *partSubscriber),
 	}
 }
 
@@ -169,8 +194,18 @@ func (p *playlist) start() { //nolint:funlen,gocognit
 			p.nextPartID = part.id + 1
 
 			p.checkPending()
+			p.sendToSubscribers(part)
 			close(req.done)
 
+		case sub := <-p.chSubscribe:
+			p.subscribers[sub] = struct{}{}
+
+		case sub := <-p.chUnsubscribe:
+			if _, exist := p.subscribers[sub]; exist {
+				close(sub.parts)
+				delete(p.subscribers, sub)
+			}
+
 		case req := <-p.chBlockingPlaylist:
 			// If the _HLS_msn is greater than the Media Sequence Number of the last
 			// Media Segment in the current Playlist plus two, or if the _HLS_part
@@ -220,6 +255,10 @@ func (p *playlist) start() { //nolint:funlen,gocognit
 			p.segFinalOnHold[res] = struct{}{}
 
 		case req := <-p.chNextSegment:
+			if p.fellBehind(req.maybePrevSeg) {
+				req.res <- nextSegmentResponse{err: ErrFellBehind}
+				continue
+			}
 			prevID := func() uint64 {
 				if req.maybePrevSeg == nil {
 					return 0
@@ -244,7 +283,7 @@ func (p *playlist) start() { //nolint:funlen,gocognit
 				return nil
 			}()
 			if seg != nil {
-				req.res <- seg
+				req.res <- nextSegmentResponse{seg: seg}
 			} else {
 				req := nextSegmentRequest2{
 					prevID: prevID,
@@ -252,6 +291,9 @@ func (p *playlist) start() { //nolint:funlen,gocognit
 				}
 				p.nextSegmentsOnHold[req] = struct{}{}
 			}
+
+		case res := <-p.chLatestSegment:
+			res <- p.latestSegmentUnsafe()
 		}
 	}
 }
@@ -305,6 +347,11 @@ func (p *playlist) cleanup() {
 	for req := range p.nextSegmentsOnHold {
 		close(req.res)
 	}
+	for sub := range p.subscribers {
+		close(sub.parts)
+	}
+	p.budget.add(-p.memoryUsed.Swap(0))
+	p.cachedSegments.Store(0)
 }
 
 func (p *playlist) hasContent() bool {
@@ -442,30 +489,34 @@ func primaryPlaylist(
 		Header: map[string]string{
 			"Content-Type": `audio/mpegURL`,
 		},
-		Body: func() io.Reader {
-			var codecs []string
+		Body: bytes.NewReader([]byte("#EXTM3U\n" +
+			"#EXT-X-VERSION:9\n" +
+			"#EXT-X-INDEPENDENT-SEGMENTS\n" +
+			"\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=200000,CODECS=\"" + codecs(videoTrack, audioTrack) + "\"\n" +
+			"stream.m3u8\n")),
+	}
+}
 
-			sps := videoTrack.SPS
-			if len(sps) >= 4 {
-				codecs = append(codecs, "avc1."+hex.EncodeToString(sps[1:4]))
-			}
+// https://developer.mozilla.org/en-US/docs/Web/Media/Formats/codecs_parameter
+func codecs(
+	videoTrack *gortsplib.TrackH264,
+	audioTrack *gortsplib.TrackMPEG4Audio,
+) string {
+	var codecs []string
 
-			// https://developer.mozilla.org/en-US/docs/Web/Media/Formats/codecs_parameter
-			if audioTrack != nil {
-				codecs = append(
-					codecs,
-					"mp4a.40."+strconv.FormatInt(int64(audioTrack.Config.Type), 10),
-				)
-			}
+	sps := videoTrack.SPS
+	if len(sps) >= 4 {
+		codecs = append(codecs, "avc1."+hex.EncodeToString(sps[1:4]))
+	}
 
-			return bytes.NewReader([]byte("#EXTM3U\n" +
-				"#EXT-X-VERSION:9\n" +
-				"#EXT-X-INDEPENDENT-SEGMENTS\n" +
-				"\n" +
-				"#EXT-X-STREAM-INF:BANDWIDTH=200000,CODECS=\"" + strings.Join(codecs, ",") + "\"\n" +
-				"stream.m3u8\n"))
-		}(),
+	if audioTrack != nil {
+		codecs = append(
+			codecs,
+			"mp4a.40."+strconv.FormatInt(int64(audioTrack.Config.Type), 10),
+		)
 	}
+	return strings.Join(codecs, ",")
 }
 
 func (p *playlist) fullPlaylist(isDeltaUpdate bool) []byte { //nolint:funlen
@@ -527,9 +578,9 @@ func (p *playlist) fullPlaylist(isDeltaUpdate bool) []byte { //nolint:funlen
 
 		switch seg := sog.(type) {
 		case *Segment:
-			if (len(p.segments) - i) <= 2 {
-				cnt += "#EXT-X-PROGRAM-DATE-TIME:" + seg.StartTime.Format("2006-01-02T15:04:05.999Z07:00") + "\n"
-			}
+			// Every segment has its wall-clock time so that the player
+			// can map the playback position to real time.
+			cnt += "#EXT-X-PROGRAM-DATE-TIME:" + seg.StartTime.Format("2006-01-02T15:04:05.999Z07:00") + "\n"
 
 			if (len(p.segments) - i) <= 2 {
 				for _, part := range seg.Parts {
@@ -647,20 +698,21 @@ func (p *playlist) segmentFinalized(segment *Segment) {
 	p.nextSegmentID = segment.ID + 1
 	p.nextSegmentParts = p.nextSegmentParts[:0]
 
-	if len(p.segments) > p.segmentCount {
-		toDelete := p.segments[0]
+	size := segment.memorySize()
+	p.memoryUsed.Add(size)
+	p.budget.add(size)
+	p.cachedSegments.Add(1)
 
-		if toDeleteSeg, ok := toDelete.(*Segment); ok {
-			for _, part := range toDeleteSeg.Parts {
-				delete(p.partsByName, part.name())
-			}
+	if len(p.segments) > p.segmentCount {
+		p.deleteOldest()
+	}
 
-			delete(p.segmentsByName, toDeleteSeg.name)
+	// Evict the oldest segments until the memory is within the budget.
+	for p.budget.exceeded(p.memoryUsed.Load()) &&
+		p.cachedSegments.Load() > minCachedSegments {
+		if p.deleteOldest() {
+			p.evictions.Add(1)
 		}
-
-		p.segments[0] = nil // Free memory!
-		p.segments = p.segments[1:]
-		p.segmentDeleteCount++
 	}
 
 	for done := range p.segFinalOnHold {
@@ -669,7 +721,7 @@ func (p *playlist) segmentFinalized(segment *Segment) {
 	}
 	for req := range p.nextSegmentsOnHold {
 		if segment.ID > req.prevID {
-			req.res <- segment
+			req.res <- nextSegmentResponse{seg: segment}
 			delete(p.nextSegmentsOnHold, req)
 		}
 	}
@@ -677,6 +729,53 @@ func (p *playlist) segmentFinalized(segment *Segment) {
 	p.checkPending()
 }
 
+// deleteOldest deletes the first segment or gap
+// and returns true if it was a segment.
+func (p *playlist) deleteOldest() bool {
+	toDelete := p.segments[0]
+	p.segments[0] = nil // Free memory!
+	p.segments = p.segments[1:]
+	p.segmentDeleteCount++
+
+	toDeleteSeg, ok := toDelete.(*Segment)
+	if !ok {
+		return false
+	}
+	for _, part := range toDeleteSeg.Parts {
+		delete(p.partsByName, part.name())
+	}
+	delete(p.segmentsByName, toDeleteSeg.name)
+
+	size := toDeleteSeg.memorySize()
+	p.memoryUsed.Add(-size)
+	p.budget.add(-size)
+	p.cachedSegments.Add(-1)
+
+	p.lastEvictedID = toDeleteSeg.ID
+	p.hasEvicted = true
+	return true
+}
+
+// fellBehind returns true if the segment after the
+// previous segment of the reader has been deleted.
+func (p *playlist) fellBehind(maybePrevSeg *Segment) bool {
+	if maybePrevSeg == nil || !p.hasEvicted {
+		return false
+	}
+	prevSeg := maybePrevSeg
+	return prevSeg.muxerID == p.muxerID &&
+		prevSeg.ID < p.nextSegmentID &&
+		prevSeg.ID < p.lastEvictedID
+}
+
+func (p *playlist) memoryStats() MemoryStats {
+	return MemoryStats{
+		Bytes:     p.memoryUsed.Load(),
+		Segments:  int(p.cachedSegments.Load()),
+		Evictions: p.evictions.Load(),
+	}
+}
+
 type partFinalizedRequest struct {
 	part *MuxerPart
 	done chan struct{}
@@ -706,16 +805,24 @@ func (p *playlist) waitForSegFinalized() {
 
 type nextSegmentRequest struct {
 	maybePrevSeg *Segment
-	res          chan *Segment
+	res          chan nextSegmentResponse
 }
 
 type nextSegmentRequest2 struct {
 	prevID uint64
-	res    chan *Segment
+	res    chan nextSegmentResponse
 }
 
+type nextSegmentResponse struct {
+	seg *Segment
+	err error
+}
+
+// ErrFellBehind the reader fell behind and the next segment was evicted.
+var ErrFellBehind = errors.New("reader fell behind, next segment was evicted")
+
 func (p *playlist) nextSegment(maybePrevSeg *Segment) (*Segment, error) {
-	nextSegmentRes := make(chan *Segment)
+	nextSegmentRes := make(chan nextSegmentResponse)
 	nextSegmentReq := nextSegmentRequest{
 		maybePrevSeg: maybePrevSeg,
 		res:          nextSegmentRes,
@@ -725,9 +832,90 @@ func (p *playlist) nextSegment(maybePrevSeg *Segment) (*Segment, error) {
 		return nil, context.Canceled
 	case p.chNextSegment <- nextSegmentReq:
 		res := <-nextSegmentRes
-		if res == nil {
+		if res.err != nil {
+			return nil, res.err
+		}
+		if res.seg == nil {
 			return nil, context.Canceled
 		}
-		return res, nil
+		return res.seg, nil
+	}
+}
+
+// ErrNoSegment no finalized segment.
+var ErrNoSegment = errors.New("no finalized segment")
+
+func (p *playlist) latestSegment() (*Segment, error) {
+	res := make(chan *Segment)
+	select {
+	case <-p.ctx.Done():
+		return nil, context.Canceled
+	case p.chLatestSegment <- res:
+		seg := <-res
+		if seg == nil {
+			return nil, ErrNoSegment
+		}
+		return seg, nil
+	}
+}
+
+func (p *playlist) latestSegmentUnsafe() *Segment {
+	for i := len(p.segments) - 1; i >= 0; i-- {
+		if seg, ok := p.segments[i].(*Segment); ok {
+			return seg
+		}
+	}
+	return nil
+}
+
+// Number of parts a subscriber can fall behind before it's dropped.
+const subscriberBufferSize = 64
+
+type partSubscriber struct {
+	parts   chan *MuxerPart
+	started bool
+}
+
+// sendToSubscribers sends the part to every subscriber without blocking.
+// Subscribers start at the first independent part and are dropped if
+// they fall too far behind.
+func (p *playlist) sendToSubscribers(part *MuxerPart) {
+	for sub := range p.subscribers {
+		if !sub.started {
+			if !part.isIndependent {
+				continue
+			}
+			sub.started = true
+		}
+		select {
+		case sub.parts <- part:
+		default:
+			close(sub.parts)
+			delete(p.subscribers, sub)
+		}
 	}
 }
+
+func (p *playlist) subscribe(ctx context.Context) <-chan *MuxerPart {
+	sub := &partSubscriber{
+		parts: make(chan *MuxerPart, subscriberBufferSize),
+	}
+	select {
+	case <-p.ctx.Done():
+		close(sub.parts)
+		return sub.parts
+	case p.chSubscribe <- sub:
+	}
+
+	go func() {
+		select {
+		case <-p.ctx.Done():
+		case <-ctx.Done():
+			select {
+			case <-p.ctx.Done():
+			case p.chUnsubscribe <- sub:
+			}
+		}
+	}()
+	return sub.parts
+}
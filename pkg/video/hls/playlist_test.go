@@ -2,7 +2,9 @@ package hls
 
 import (
 	"context"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,7 +13,7 @@ func TestNextSegment(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	playlist := newPlaylist(ctx, 0, 3)
+	playlist := newPlaylist(ctx, 0, 3, nil)
 	go playlist.start()
 
 	seg5 := &Segment{ID: 5}
@@ -52,3 +54,185 @@ func TestNextSegment(t *testing.T) {
 		<-done
 	})
 }
+
+func TestSubscribe(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		playlist := newPlaylist(ctx, 0, 3, nil)
+		go playlist.start()
+
+		parts := playlist.subscribe(ctx)
+
+		part0 := &MuxerPart{id: 0}
+		part1 := &MuxerPart{id: 1, isIndependent: true}
+		part2 := &MuxerPart{id: 2}
+		playlist.partFinalized(part0)
+		playlist.partFinalized(part1)
+		playlist.partFinalized(part2)
+
+		// Starts at the first independent part.
+		require.Equal(t, part1, <-parts)
+		require.Equal(t, part2, <-parts)
+	})
+	t.Run("unsubscribe", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		playlist := newPlaylist(ctx, 0, 3, nil)
+		go playlist.start()
+
+		subCtx, subCancel := context.WithCancel(context.Background())
+		parts := playlist.subscribe(subCtx)
+		subCancel()
+
+		_, ok := <-parts
+		require.False(t, ok)
+	})
+	t.Run("slowSubscriber", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		playlist := newPlaylist(ctx, 0, 3, nil)
+		go playlist.start()
+
+		parts := playlist.subscribe(ctx)
+		for i := 0; i <= subscriberBufferSize; i++ {
+			playlist.partFinalized(&MuxerPart{id: uint64(i), isIndependent: true})
+		}
+
+		n := 0
+		for range parts {
+			n++
+		}
+		require.Equal(t, subscriberBufferSize, n)
+	})
+	t.Run("muxerClosed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		playlist := newPlaylist(ctx, 0, 3, nil)
+		go playlist.start()
+
+		parts := playlist.subscribe(context.Background())
+		cancel()
+
+		_, ok := <-parts
+		require.False(t, ok)
+	})
+}
+
+func TestLatestSegment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	playlist := newPlaylist(ctx, 0, 3, nil)
+	go playlist.start()
+
+	_, err := playlist.latestSegment()
+	require.ErrorIs(t, err, ErrNoSegment)
+
+	seg5 := &Segment{ID: 5}
+	seg6 := &Segment{ID: 6}
+	playlist.onSegmentFinalized(seg5)
+	playlist.onSegmentFinalized(seg6)
+
+	seg, err := playlist.latestSegment()
+	require.NoError(t, err)
+	require.Equal(t, seg6, seg)
+}
+
+func newTestSegment(id uint64, size int) *Segment {
+	return &Segment{
+		ID:   id,
+		name: "seg" + strconv.FormatUint(id, 10),
+		Parts: []*MuxerPart{{
+			id:              id,
+			renderedContent: make([]byte, size),
+		}},
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	t.Run("pathLimit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		budget := NewMemoryBudget(0, 200)
+		playlist := newPlaylist(ctx, 0, 100, budget)
+		go playlist.start()
+
+		for i := uint64(1); i <= 4; i++ {
+			playlist.onSegmentFinalized(newTestSegment(i, 50))
+		}
+		require.Equal(t, MemoryStats{Bytes: 200, Segments: 4}, playlist.memoryStats())
+
+		playlist.onSegmentFinalized(newTestSegment(5, 50))
+		playlist.onSegmentFinalized(newTestSegment(6, 50))
+		expected := MemoryStats{Bytes: 200, Segments: 4, Evictions: 2}
+		require.Equal(t, expected, playlist.memoryStats())
+		require.Equal(t, int64(200), budget.Stats().Used)
+
+		// The minimum number of segments is kept.
+		playlist.onSegmentFinalized(newTestSegment(7, 500))
+		require.Equal(t, 3, playlist.memoryStats().Segments)
+	})
+	t.Run("shared", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		budget := NewMemoryBudget(500, 0)
+		playlist1 := newPlaylist(ctx, 0, 100, budget)
+		go playlist1.start()
+
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		defer cancel2()
+		playlist2 := newPlaylist(ctx2, 0, 100, budget)
+		go playlist2.start()
+
+		for i := uint64(1); i <= 5; i++ {
+			playlist1.onSegmentFinalized(newTestSegment(i, 100))
+		}
+		for i := uint64(1); i <= 4; i++ {
+			playlist2.onSegmentFinalized(newTestSegment(i, 100))
+		}
+		require.Equal(t, int64(800), budget.Stats().Used)
+		require.Equal(t, uint64(1), playlist2.memoryStats().Evictions)
+
+		// Each path evicts its own segments when it finalizes a new one.
+		playlist1.onSegmentFinalized(newTestSegment(6, 100))
+		require.Equal(t, int64(600), budget.Stats().Used)
+		require.Equal(t, uint64(3), playlist1.memoryStats().Evictions)
+
+		// Memory is released when the muxer is closed.
+		cancel2()
+		require.Eventually(t, func() bool {
+			return budget.Stats().Used == 300
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("fellBehind", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		playlist := newPlaylist(ctx, 0, 100, NewMemoryBudget(0, 150))
+		go playlist.start()
+
+		segs := make([]*Segment, 6)
+		for i := range segs {
+			segs[i] = newTestSegment(uint64(i+1), 50)
+			playlist.onSegmentFinalized(segs[i])
+		}
+
+		_, err := playlist.nextSegment(segs[0])
+		require.ErrorIs(t, err, ErrFellBehind)
+
+		seg, err := playlist.nextSegment(segs[2])
+		require.NoError(t, err)
+		require.Equal(t, segs[3], seg)
+
+		// Readers of other muxers start from the oldest segment.
+		seg, err = playlist.nextSegment(&Segment{ID: 1, muxerID: 1})
+		require.NoError(t, err)
+		require.Equal(t, segs[3], seg)
+	})
+}
@@ -115,6 +115,26 @@ func (s *Segment) finalize(nextVideoSample *VideoSample) error {
 	return nil
 }
 
+// Bitrate returns the bitrate of a finalized segment in bits per second.
+func (s *Segment) Bitrate() int64 {
+	if s.RenderedDuration <= 0 {
+		return 0
+	}
+	return int64(float64(s.size*8) / s.RenderedDuration.Seconds())
+}
+
+// FPS returns the video frame rate of a finalized segment.
+func (s *Segment) FPS() float64 {
+	if s.RenderedDuration <= 0 {
+		return 0
+	}
+	frames := 0
+	for _, part := range s.Parts {
+		frames += len(part.VideoSamples)
+	}
+	return float64(frames) / s.RenderedDuration.Seconds()
+}
+
 // ErrMaximumSegmentSize reached maximum segment size.
 var ErrMaximumSegmentSize = errors.New("reached maximum segment size")
 
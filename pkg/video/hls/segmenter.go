@@ -67,6 +67,7 @@ type segmenter struct {
 	firstSegmentFinalized          bool
 	sampleDurations                map[time.Duration]struct{}
 	adjustedPartDuration           time.Duration
+	wallClock                      wallClock
 }
 
 func newSegmenter(
@@ -188,6 +189,8 @@ func (m *segmenter) writeH264Entry( //nolint:funlen
 		dts -= m.startDTS
 	}
 
+	m.wallClock.update(ntp, dts)
+
 	avcc := h264.AVCCMarshal(au)
 
 	sample := &VideoSample{
@@ -217,7 +220,7 @@ func (m *segmenter) writeH264Entry( //nolint:funlen
 		m.currentSegment = newSegment(
 			m.genSegmentID(),
 			m.muxerID,
-			ntp,
+			m.wallClock.at(time.Duration(sample.DTS-m.muxerStartTime)),
 			time.Duration(sample.DTS-m.muxerStartTime),
 			m.muxerStartTime,
 			m.segmentMaxSize,
@@ -252,7 +255,8 @@ func (m *segmenter) writeH264Entry( //nolint:funlen
 			m.currentSegment = newSegment(
 				m.genSegmentID(),
 				m.muxerID,
-				ntp,
+				// The segment starts with the next sample.
+				m.wallClock.at(time.Duration(m.nextVideoSample.DTS-m.muxerStartTime)),
 				time.Duration(sample.DTS-m.muxerStartTime),
 				m.muxerStartTime,
 				m.segmentMaxSize,
@@ -0,0 +1,248 @@
+package hls
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeshiftPlaylist is the name of the timeshift playlist.
+const TimeshiftPlaylist = "timeshift.m3u8"
+
+// Prefix of the timeshift segment files, they're named
+// differently from the live segments to avoid conflicts.
+const timeshiftSegmentPrefix = "ts"
+
+// ErrTimeshiftClosed the timeshift was closed.
+var ErrTimeshiftClosed = errors.New("timeshift closed")
+
+type timeshiftSegment struct {
+	id        uint64
+	startTime time.Time
+	duration  time.Duration
+}
+
+func (s timeshiftSegment) name() string {
+	return timeshiftSegmentPrefix + strconv.FormatUint(s.id, 10) + ".mp4"
+}
+
+// Timeshift keeps the finalized segments of the last window on disk,
+// allowing clients to watch the live stream from some minutes ago.
+// The segments are served by a EVENT playlist that grows until the
+// window is full, then the oldest segments are dropped.
+type Timeshift struct {
+	dir    string
+	window time.Duration
+
+	mu       sync.Mutex
+	segments []timeshiftSegment
+	closed   bool
+}
+
+// NewTimeshift creates a new directory for the segments in the parent
+// directory. Each muxer has its own directory, the directory of the
+// previous muxer may not have been removed yet.
+func NewTimeshift(parentDir string, name string, window time.Duration) (*Timeshift, error) {
+	if err := os.MkdirAll(parentDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create timeshift directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(parentDir, name+"_")
+	if err != nil {
+		return nil, fmt.Errorf("create timeshift directory: %w", err)
+	}
+	return &Timeshift{
+		dir:    dir,
+		window: window,
+	}, nil
+}
+
+// add writes the segment to disk and removes the segments
+// that are older than the window. Called by the segmenter.
+func (t *Timeshift) add(segment *Segment) error {
+	if t.isClosed() {
+		return ErrTimeshiftClosed
+	}
+
+	seg := timeshiftSegment{
+		id:        segment.ID,
+		startTime: segment.StartTime,
+		duration:  segment.RenderedDuration,
+	}
+
+	// Writes are done without the lock to not block the readers.
+	path := filepath.Join(t.dir, seg.name())
+	if err := writeTimeshiftSegment(path, segment.reader()); err != nil {
+		return fmt.Errorf("write timeshift segment: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		os.Remove(path)
+		return ErrTimeshiftClosed
+	}
+	t.segments = append(t.segments, seg)
+
+	end := seg.startTime.Add(seg.duration)
+	for len(t.segments) > 1 && end.Sub(t.segments[0].startTime) > t.window {
+		os.Remove(filepath.Join(t.dir, t.segments[0].name()))
+		t.segments = t.segments[1:]
+	}
+	return nil
+}
+
+func (t *Timeshift) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+func writeTimeshiftSegment(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Playlist returns the EVENT playlist of the retained segments. If offset
+// is positive, clients are told to start playing offset before the end.
+func (t *Timeshift) Playlist(offset time.Duration) *MuxerFileResponse {
+	return t.playlistResponse(func() time.Duration { return offset })
+}
+
+// PlaylistAt returns the EVENT playlist of the retained segments, clients
+// are told to start playing at the wall-clock time. Times before the
+// window start at the oldest segment and times after it start at live.
+func (t *Timeshift) PlaylistAt(at time.Time) *MuxerFileResponse {
+	return t.playlistResponse(func() time.Duration { return t.offsetAt(at) })
+}
+
+// playlistResponse calls offset with the lock held.
+func (t *Timeshift) playlistResponse(offset func() time.Duration) *MuxerFileResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.segments) == 0 {
+		return &MuxerFileResponse{Status: http.StatusNotFound}
+	}
+
+	return &MuxerFileResponse{
+		Status: http.StatusOK,
+		Header: map[string]string{
+			"Content-Type": `audio/mpegURL`,
+		},
+		Body: bytes.NewReader(t.playlist(offset())),
+	}
+}
+
+// offsetAt returns the duration between the wall-clock time and the end
+// of the segments. The program date time of each segment is used instead
+// of the end time, the durations may not add up to the wall-clock time.
+func (t *Timeshift) offsetAt(at time.Time) time.Duration {
+	var offset time.Duration
+	for i := len(t.segments) - 1; i >= 0; i-- {
+		seg := t.segments[i]
+		if !at.Before(seg.startTime) {
+			return offset + max(seg.duration-at.Sub(seg.startTime), 0)
+		}
+		offset += seg.duration
+	}
+	return offset
+}
+
+func (t *Timeshift) playlist(offset time.Duration) []byte {
+	var targetDuration time.Duration
+	var total time.Duration
+	for _, seg := range t.segments {
+		targetDuration = max(targetDuration, seg.duration)
+		total += seg.duration
+	}
+
+	cnt := "#EXTM3U\n"
+	cnt += "#EXT-X-VERSION:9\n"
+	cnt += "#EXT-X-TARGETDURATION:" +
+		strconv.FormatFloat(math.Ceil(targetDuration.Seconds()), 'f', 0, 64) + "\n"
+	cnt += "#EXT-X-PLAYLIST-TYPE:EVENT\n"
+	cnt += "#EXT-X-MEDIA-SEQUENCE:" + strconv.FormatUint(t.segments[0].id, 10) + "\n"
+	if offset > 0 {
+		offset = min(offset, total)
+		cnt += "#EXT-X-START:TIME-OFFSET=-" +
+			strconv.FormatFloat(offset.Seconds(), 'f', 5, 64) + ",PRECISE=YES\n"
+	}
+	cnt += "#EXT-X-MAP:URI=\"init.mp4\"\n"
+
+	for _, seg := range t.segments {
+		cnt += "#EXT-X-PROGRAM-DATE-TIME:" + seg.startTime.Format("2006-01-02T15:04:05.999Z07:00") + "\n" +
+			"#EXTINF:" + strconv.FormatFloat(seg.duration.Seconds(), 'f', 5, 64) + ",\n" +
+			seg.name() + "\n"
+	}
+	return []byte(cnt)
+}
+
+// IsTimeshiftSegment returns true if the file name is a timeshift segment.
+func IsTimeshiftSegment(name string) bool {
+	return strings.HasPrefix(name, timeshiftSegmentPrefix) && strings.HasSuffix(name, ".mp4")
+}
+
+// Segment returns a retained segment by file name.
+func (t *Timeshift) Segment(name string) *MuxerFileResponse {
+	id, err := strconv.ParseUint(
+		strings.TrimSuffix(strings.TrimPrefix(name, timeshiftSegmentPrefix), ".mp4"), 10, 64)
+	if err != nil {
+		return &MuxerFileResponse{Status: http.StatusBadRequest}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.hasSegment(id) {
+		return &MuxerFileResponse{Status: http.StatusNotFound}
+	}
+
+	content, err := os.ReadFile(filepath.Join(t.dir, timeshiftSegment{id: id}.name()))
+	if err != nil {
+		return &MuxerFileResponse{Status: http.StatusInternalServerError}
+	}
+
+	return &MuxerFileResponse{
+		Status: http.StatusOK,
+		Header: map[string]string{
+			"Content-Type": "video/mp4",
+		},
+		Body: bytes.NewReader(content),
+	}
+}
+
+func (t *Timeshift) hasSegment(id uint64) bool {
+	for _, seg := range t.segments {
+		if seg.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Close removes the directory and the segments.
+func (t *Timeshift) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	t.segments = nil
+	return os.RemoveAll(t.dir)
+}
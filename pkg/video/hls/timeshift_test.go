@@ -0,0 +1,138 @@
+package hls
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTimeshiftSegment(id uint64, start time.Time, content string) *Segment {
+	return &Segment{
+		ID:               id,
+		StartTime:        start,
+		RenderedDuration: time.Second,
+		Parts: []*MuxerPart{
+			{renderedContent: []byte(content[:1])},
+			{renderedContent: []byte(content[1:])},
+		},
+	}
+}
+
+func readTimeshiftResponse(t *testing.T, res *MuxerFileResponse) string {
+	t.Helper()
+	require.Equal(t, http.StatusOK, res.Status)
+	raw, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func TestTimeshift(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	t.Run("window", func(t *testing.T) {
+		ts, err := NewTimeshift(t.TempDir(), "x", 3*time.Second)
+		require.NoError(t, err)
+		defer ts.Close()
+
+		require.Equal(t, http.StatusNotFound, ts.Playlist(0).Status)
+
+		for i := uint64(0); i < 5; i++ {
+			seg := newTestTimeshiftSegment(
+				i, start.Add(time.Duration(i)*time.Second), "ab"+string(rune('0'+i)))
+			require.NoError(t, ts.add(seg))
+		}
+
+		expected := "#EXTM3U\n" +
+			"#EXT-X-VERSION:9\n" +
+			"#EXT-X-TARGETDURATION:1\n" +
+			"#EXT-X-PLAYLIST-TYPE:EVENT\n" +
+			"#EXT-X-MEDIA-SEQUENCE:2\n" +
+			"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2001-02-03T04:05:08Z\n" +
+			"#EXTINF:1.00000,\n" +
+			"ts2.mp4\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2001-02-03T04:05:09Z\n" +
+			"#EXTINF:1.00000,\n" +
+			"ts3.mp4\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2001-02-03T04:05:10Z\n" +
+			"#EXTINF:1.00000,\n" +
+			"ts4.mp4\n"
+		require.Equal(t, expected, readTimeshiftResponse(t, ts.Playlist(0)))
+
+		require.Equal(t, "ab3", readTimeshiftResponse(t, ts.Segment("ts3.mp4")))
+		require.Equal(t, http.StatusNotFound, ts.Segment("ts1.mp4").Status)
+		require.Equal(t, http.StatusBadRequest, ts.Segment("tsx.mp4").Status)
+
+		entries, err := os.ReadDir(ts.dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+	})
+	t.Run("offset", func(t *testing.T) {
+		ts, err := NewTimeshift(t.TempDir(), "x", time.Minute)
+		require.NoError(t, err)
+		defer ts.Close()
+
+		require.NoError(t, ts.add(newTestTimeshiftSegment(0, start, "ab")))
+		require.NoError(t, ts.add(newTestTimeshiftSegment(1, start.Add(time.Second), "ab")))
+
+		playlist := readTimeshiftResponse(t, ts.Playlist(1500*time.Millisecond))
+		require.Contains(t, playlist, "#EXT-X-START:TIME-OFFSET=-1.50000,PRECISE=YES\n")
+
+		// Limited to the duration of the segments.
+		playlist = readTimeshiftResponse(t, ts.Playlist(time.Hour))
+		require.Contains(t, playlist, "#EXT-X-START:TIME-OFFSET=-2.00000,PRECISE=YES\n")
+	})
+	t.Run("at", func(t *testing.T) {
+		ts, err := NewTimeshift(t.TempDir(), "x", time.Minute)
+		require.NoError(t, err)
+		defer ts.Close()
+
+		require.Equal(t, http.StatusNotFound, ts.PlaylistAt(start).Status)
+
+		for i := uint64(0); i < 3; i++ {
+			seg := newTestTimeshiftSegment(i, start.Add(time.Duration(i)*time.Second), "ab")
+			require.NoError(t, ts.add(seg))
+		}
+
+		cases := map[string]struct {
+			at       time.Time
+			expected time.Duration
+		}{
+			"first":  {start, 3 * time.Second},
+			"middle": {start.Add(1250 * time.Millisecond), 1750 * time.Millisecond},
+			"last":   {start.Add(2 * time.Second), time.Second},
+			"before": {start.Add(-time.Hour), 3 * time.Second},
+			"after":  {start.Add(time.Hour), 0},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				require.Equal(t, tc.expected, ts.offsetAt(tc.at))
+			})
+		}
+
+		playlist := readTimeshiftResponse(t, ts.PlaylistAt(start.Add(1250*time.Millisecond)))
+		require.Contains(t, playlist, "#EXT-X-START:TIME-OFFSET=-1.75000,PRECISE=YES\n")
+	})
+	t.Run("close", func(t *testing.T) {
+		parentDir := t.TempDir()
+		ts, err := NewTimeshift(parentDir, "x", time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, ts.add(newTestTimeshiftSegment(0, start, "ab")))
+		require.NoError(t, ts.Close())
+
+		_, err = os.Stat(ts.dir)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		err = ts.add(newTestTimeshiftSegment(1, start, "ab"))
+		require.ErrorIs(t, err, ErrTimeshiftClosed)
+
+		entries, err := os.ReadDir(parentDir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
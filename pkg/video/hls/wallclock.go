@@ -0,0 +1,34 @@
+package hls
+
+import (
+	"time"
+)
+
+// Frames that arrive later than this behind the wall clock
+// re-anchor it, for example after a stall or if the camera
+// clock runs slow.
+const maxWallClockDrift = time.Second
+
+// wallClock maps the media timestamps to wall-clock time. It's anchored
+// to the arrival time of a frame and then follows the media timestamps,
+// so the segment start times don't inherit the network jitter. Frames
+// never arrive before they're captured, the clock is moved back if a
+// frame arrives earlier than expected.
+type wallClock struct {
+	anchor    time.Time
+	anchorDTS time.Duration
+}
+
+// update is called with the arrival time and the DTS of each frame.
+func (c *wallClock) update(ntp time.Time, dts time.Duration) {
+	drift := ntp.Sub(c.at(dts))
+	if c.anchor.IsZero() || drift < 0 || drift > maxWallClockDrift {
+		c.anchor = ntp
+		c.anchorDTS = dts
+	}
+}
+
+// at returns the wall-clock time of the DTS.
+func (c *wallClock) at(dts time.Duration) time.Time {
+	return c.anchor.Add(dts - c.anchorDTS)
+}
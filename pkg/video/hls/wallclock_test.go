@@ -0,0 +1,30 @@
+package hls
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallClock(t *testing.T) {
+	start := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	ms := time.Millisecond
+
+	var c wallClock
+	c.update(start, 0)
+	require.Equal(t, start, c.at(0))
+
+	// Jitter doesn't move the clock.
+	c.update(start.Add(150*ms), 100*ms)
+	require.Equal(t, start.Add(100*ms), c.at(100*ms))
+
+	// A frame that arrives early moves the clock back.
+	c.update(start.Add(180*ms), 200*ms)
+	require.Equal(t, start.Add(180*ms), c.at(200*ms))
+
+	// Frames that are too late re-anchor the clock.
+	c.update(start.Add(5*time.Second), 300*ms)
+	require.Equal(t, start.Add(5*time.Second), c.at(300*ms))
+	require.Equal(t, start.Add(5100*ms), c.at(400*ms))
+}
@@ -2,14 +2,19 @@ package video
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"nvr/pkg/log"
 	"nvr/pkg/video/gortsplib"
 	"nvr/pkg/video/gortsplib/pkg/mpeg4audio"
 	"nvr/pkg/video/gortsplib/pkg/ringbuffer"
 	"nvr/pkg/video/hls"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -20,6 +25,8 @@ type muxerCloseFunc func(*HLSMuxer)
 type HLSMuxer struct {
 	wg              *sync.WaitGroup
 	readBufferCount int
+	memoryBudget    *hls.MemoryBudget
+	timeshiftConf   timeshiftConfig
 	path            *path
 	pathConf        PathConf
 	muxerClose      muxerCloseFunc
@@ -28,15 +35,21 @@ type HLSMuxer struct {
 	ctxCancel   func()
 	ringBuffer  *ringbuffer.RingBuffer
 	muxer       *hls.Muxer
+	timeshift   *hls.Timeshift
 	nextMuxerID uint16
 
 	// in
 	chRequest chan *hlsMuxerRequest
+
+	clients   map[string]time.Time // Client ID and last request.
+	clientsMu sync.Mutex
 }
 
 func newHLSMuxer(
 	parentCtx context.Context,
 	readBufferCount int,
+	memoryBudget *hls.MemoryBudget,
+	timeshiftConf timeshiftConfig,
 	wg *sync.WaitGroup,
 	path *path,
 	muxerClose muxerCloseFunc,
@@ -45,6 +58,8 @@ func newHLSMuxer(
 
 	return &HLSMuxer{
 		readBufferCount: readBufferCount,
+		memoryBudget:    memoryBudget,
+		timeshiftConf:   timeshiftConf,
 		wg:              wg,
 		path:            path,
 		pathConf:        *path.conf,
@@ -52,6 +67,7 @@ func newHLSMuxer(
 		ctx:             ctx,
 		ctxCancel:       ctxCancel,
 		chRequest:       make(chan *hlsMuxerRequest),
+		clients:         make(map[string]time.Time),
 	}
 }
 
@@ -77,6 +93,14 @@ func (m *HLSMuxer) run(tracks gortsplib.Tracks) error {
 		return fmt.Errorf("parse tracks: %w", err)
 	}
 
+	if m.timeshiftConf.window > 0 {
+		m.timeshift, err = hls.NewTimeshift(
+			m.timeshiftConf.dir, m.path.name, m.timeshiftConf.window)
+		if err != nil {
+			return err
+		}
+	}
+
 	m.muxer = m.createMuxer(videoTrack, audioTrack)
 
 	m.ringBuffer, err = ringbuffer.New(uint64(m.readBufferCount))
@@ -113,6 +137,7 @@ func (m *HLSMuxer) run(tracks gortsplib.Tracks) error {
 			case <-m.ctx.Done():
 				cleanup()
 				<-innerErr
+				m.closeTimeshift()
 				return
 
 			case req := <-m.chRequest:
@@ -120,6 +145,7 @@ func (m *HLSMuxer) run(tracks gortsplib.Tracks) error {
 
 			case err := <-innerErr:
 				cleanup()
+				m.closeTimeshift()
 				if !errors.Is(err, context.Canceled) {
 					m.logf("closed: %v", err)
 				}
@@ -131,6 +157,28 @@ func (m *HLSMuxer) run(tracks gortsplib.Tracks) error {
 	return nil
 }
 
+// timeshiftConfig the timeshift window is disabled if zero.
+type timeshiftConfig struct {
+	dir    string
+	window time.Duration
+}
+
+func (m *HLSMuxer) closeTimeshift() {
+	if m.timeshift == nil {
+		return
+	}
+	if err := m.timeshift.Close(); err != nil {
+		m.logf("close timeshift: %v", err)
+	}
+}
+
+func (m *HLSMuxer) memoryStats() hls.MemoryStats {
+	if m.muxer == nil {
+		return hls.MemoryStats{}
+	}
+	return m.muxer.MemoryStats()
+}
+
 func (m *HLSMuxer) genMuxerID() uint16 {
 	id := m.nextMuxerID
 	m.nextMuxerID++
@@ -202,6 +250,8 @@ func (m *HLSMuxer) createMuxer(
 		hlsSegmentDuration,
 		hlsPartDuration,
 		hlsSegmentMaxSize,
+		m.memoryBudget,
+		m.timeshift,
 		muxerLogFunc,
 		videoTrack,
 		audioTrack,
@@ -249,6 +299,14 @@ func (m *HLSMuxer) runWriter(
 			if err != nil {
 				return fmt.Errorf("muxer error: %w", err)
 			}
+
+			if m.pathConf.OnH264 != nil {
+				m.pathConf.OnH264(H264Frame{
+					NTP:   tdata.ntp,
+					PTS:   pts,
+					NALUs: tdata.nalus,
+				})
+			}
 		} else if audioTrack != nil && data.getTrackID() == audioTrackID {
 			tdata := data.(*dataMPEG4Audio) //nolint:forcetypeassert
 
@@ -276,13 +334,16 @@ func (m *HLSMuxer) runWriter(
 }
 
 type hlsMuxerRequest struct {
-	path string
-	file string
-	req  *http.Request
-	res  chan *hls.MuxerFileResponse
+	path     string
+	file     string
+	req      *http.Request
+	clientID string
+	res      chan *hls.MuxerFileResponse
 }
 
 func (m *HLSMuxer) handleRequest(req *hlsMuxerRequest) *hls.MuxerFileResponse {
+	m.clientSeen(req.clientID, time.Now())
+
 	p := req.req.URL.Query()
 	msn := func() string {
 		if len(p["_HLS_msn"]) > 0 {
@@ -303,9 +364,100 @@ func (m *HLSMuxer) handleRequest(req *hlsMuxerRequest) *hls.MuxerFileResponse {
 		return ""
 	}()
 
+	if req.file == hls.TimeshiftPlaylist || hls.IsTimeshiftSegment(req.file) {
+		return m.handleTimeshiftRequest(req)
+	}
+
 	return m.muxer.File(req.file, msn, part, skip)
 }
 
+// handleTimeshiftRequest serves the timeshift playlist and segments. The
+// "offset" query parameter of the playlist is the number of seconds
+// behind live that the client should start playing from. The "time"
+// query parameter is the RFC3339 wall-clock time to start playing
+// from instead, it overrides the offset.
+func (m *HLSMuxer) handleTimeshiftRequest(req *hlsMuxerRequest) *hls.MuxerFileResponse {
+	if m.timeshift == nil {
+		return &hls.MuxerFileResponse{Status: http.StatusNotFound}
+	}
+	if req.file != hls.TimeshiftPlaylist {
+		return m.timeshift.Segment(req.file)
+	}
+
+	if rawTime := req.req.URL.Query().Get("time"); rawTime != "" {
+		at, err := time.Parse(time.RFC3339, rawTime)
+		if err != nil {
+			return &hls.MuxerFileResponse{Status: http.StatusBadRequest}
+		}
+		return m.timeshift.PlaylistAt(at)
+	}
+
+	var offset time.Duration
+	if rawOffset := req.req.URL.Query().Get("offset"); rawOffset != "" {
+		seconds, err := strconv.ParseFloat(rawOffset, 64)
+		if err != nil || math.IsNaN(seconds) || seconds < 0 {
+			return &hls.MuxerFileResponse{Status: http.StatusBadRequest}
+		}
+		seconds = math.Min(seconds, m.timeshiftConf.window.Seconds())
+		offset = time.Duration(seconds * float64(time.Second))
+	}
+	return m.timeshift.Playlist(offset)
+}
+
+// HLS clients that haven't made a request within
+// this duration are no longer counted as consumers.
+const hlsClientTimeout = 10 * time.Second
+
+// HLS is stateless and clients behind the same NAT or reverse
+// proxy have the same address, the cookie identifies each client.
+const hlsSessionCookie = "hls-session"
+
+// hlsClientID returns the ID of the client that made the request. The
+// session cookie is set if the request doesn't have one, clients that
+// don't keep cookies are identified by their address. The address is
+// already resolved from the forwarding headers of trusted proxies.
+func hlsClientID(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(hlsSessionCookie); err == nil && cookie.Value != "" {
+		return "session:" + cookie.Value
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     hlsSessionCookie,
+		Value:    hex.EncodeToString(b),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+func (m *HLSMuxer) clientSeen(clientID string, now time.Time) {
+	m.clientsMu.Lock()
+	defer m.clientsMu.Unlock()
+	m.clients[clientID] = now
+}
+
+// clientCount returns the number of recently active HLS clients.
+func (m *HLSMuxer) clientCount(now time.Time) int {
+	m.clientsMu.Lock()
+	defer m.clientsMu.Unlock()
+
+	for clientID, lastSeen := range m.clients {
+		if now.Sub(lastSeen) > hlsClientTimeout {
+			delete(m.clients, clientID)
+		}
+	}
+	return len(m.clients)
+}
+
 // onRequest is called by hlsserver.Server (forwarded from ServeHTTP).
 func (m *HLSMuxer) onRequest(req *hlsMuxerRequest) {
 	select {
@@ -10,6 +10,7 @@ import (
 	"nvr/pkg/log"
 	"nvr/pkg/video/gortsplib"
 	"nvr/pkg/video/hls"
+	"os"
 	gopath "path"
 	"strings"
 	"sync"
@@ -18,49 +19,60 @@ import (
 
 type hlsServer struct {
 	readBufferCount int
+	memoryBudget    *hls.MemoryBudget
+	timeshift       timeshiftConfig
 	logger          *log.Logger
 
-	ctx    context.Context
-	wg     *sync.WaitGroup
-	muxers map[string]*HLSMuxer
+	ctx context.Context
+	wg  *sync.WaitGroup
+
+	// Only modified by run(), requests look up
+	// the muxer in the snapshot without blocking.
+	muxers snapshotMap[*HLSMuxer]
 
 	// in
 	chPathSourceReady    chan pathSourceReadyRequest
 	chPathSourceNotReady chan string
-	chRequest            chan *hlsMuxerRequest
-	chMuxerbyPathName    chan muxerByPathNameRequest
 	chMuxerClose         chan *HLSMuxer
 }
 
 func newHLSServer(
 	wg *sync.WaitGroup,
 	readBufferCount int,
+	memoryBudget *hls.MemoryBudget,
+	timeshift timeshiftConfig,
 	logger *log.Logger,
 ) *hlsServer {
 	return &hlsServer{
 		readBufferCount:      readBufferCount,
+		memoryBudget:         memoryBudget,
+		timeshift:            timeshift,
 		logger:               logger,
 		wg:                   wg,
-		muxers:               make(map[string]*HLSMuxer),
 		chPathSourceReady:    make(chan pathSourceReadyRequest),
 		chPathSourceNotReady: make(chan string),
-		chRequest:            make(chan *hlsMuxerRequest),
-		chMuxerbyPathName:    make(chan muxerByPathNameRequest),
 		chMuxerClose:         make(chan *HLSMuxer),
 	}
 }
 
-func (s *hlsServer) start(ctx context.Context, address string) error {
+func (s *hlsServer) start(ctx context.Context, addresses []string) error {
 	s.ctx = ctx
 
-	ln, err := net.Listen("tcp", address)
+	// Remove the timeshift segments left by a previous run.
+	if s.timeshift.window > 0 {
+		if err := os.RemoveAll(s.timeshift.dir); err != nil {
+			return fmt.Errorf("remove timeshift directory: %w", err)
+		}
+	}
+
+	ln, err := listenTCP(addresses)
 	if err != nil {
 		return err
 	}
 	s.logger.Log(log.Entry{
 		Level: log.LevelInfo,
 		Src:   "app",
-		Msg:   fmt.Sprintf("HLS: listener opened on %v", address),
+		Msg:   fmt.Sprintf("HLS: listener opened on %v", strings.Join(addresses, ", ")),
 	})
 
 	s.wg.Add(2)
@@ -116,13 +128,16 @@ func (s *hlsServer) run() {
 			return
 
 		case req := <-s.chPathSourceReady:
-			if _, exist := s.muxers[req.path.name]; exist {
+			if _, exist := s.muxers.get(req.path.name); exist {
 				req.res <- pathSourceReadyResponse{err: ErrMuxerAleadyExists}
+				continue
 			}
 
 			m := newHLSMuxer(
 				s.ctx,
 				s.readBufferCount,
+				s.memoryBudget,
+				s.timeshift,
 				s.wg,
 				req.path,
 				s.muxerClose,
@@ -134,40 +149,39 @@ func (s *hlsServer) run() {
 				}
 				continue
 			}
-			s.muxers[req.path.name] = m
+			s.setMuxer(req.path.name, m)
 			req.res <- pathSourceReadyResponse{muxer: m}
 
 		case pathName := <-s.chPathSourceNotReady:
-			if c, exist := s.muxers[pathName]; exist {
+			if c, exist := s.muxers.get(pathName); exist {
 				c.close()
-				delete(s.muxers, pathName)
+				s.deleteMuxer(pathName, c)
 			}
 
-		case req := <-s.chRequest:
-			m, exist := s.muxers[req.path]
-			if exist {
-				m.onRequest(req)
-				continue
-			}
-			req.res <- &hls.MuxerFileResponse{Status: http.StatusNotFound}
-
-		case req := <-s.chMuxerbyPathName:
-			m, exist := s.muxers[req.pathName]
-			if exist {
-				req.res <- m
-				continue
-			}
-			req.res <- nil
-
 		case c := <-s.chMuxerClose:
-			_, exist := s.muxers[c.path.name]
-			if exist {
-				delete(s.muxers, c.path.name)
-			}
+			s.deleteMuxer(c.path.name, c)
 		}
 	}
 }
 
+func (s *hlsServer) setMuxer(pathName string, m *HLSMuxer) {
+	s.muxers.update(func(muxers map[string]*HLSMuxer) error { //nolint:errcheck
+		muxers[pathName] = m
+		return nil
+	})
+}
+
+// deleteMuxer removes the muxer if it hasn't been replaced.
+func (s *hlsServer) deleteMuxer(pathName string, m *HLSMuxer) {
+	if cur, exist := s.muxers.get(pathName); !exist || cur != m {
+		return
+	}
+	s.muxers.update(func(muxers map[string]*HLSMuxer) error { //nolint:errcheck
+		delete(muxers, pathName)
+		return nil
+	})
+}
+
 func (s *hlsServer) HandleRequest() http.HandlerFunc { //nolint:funlen
 	return func(w http.ResponseWriter, r *http.Request) {
 		// s.logf(log.LevelInfo, "[conn %v] %s %s", r.RemoteAddr, r.Method, r.URL.Path)
@@ -206,34 +220,42 @@ func (s *hlsServer) HandleRequest() http.HandlerFunc { //nolint:funlen
 		}()
 
 		if fname == "" && !strings.HasSuffix(dir, "/") {
-			w.Header().Set("Location", "/hls/"+dir+"/")
+			// Relative since the app may be served under a base path.
+			w.Header().Set("Location", gopath.Base(dir)+"/")
 			w.WriteHeader(http.StatusMovedPermanently)
 			return
 		}
 
 		dir = strings.TrimSuffix(dir, "/")
 
-		cres := make(chan *hls.MuxerFileResponse)
-		hreq := &hlsMuxerRequest{
-			path: dir,
-			file: fname,
-			req:  r,
-			res:  cres,
+		if s.ctx.Err() != nil {
+			return
 		}
 
-		select {
-		case <-s.ctx.Done():
-		case s.chRequest <- hreq:
-			res := <-cres
+		m, exist := s.muxers.get(dir)
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
 
-			for k, v := range res.Header {
-				w.Header().Set(k, v)
-			}
-			w.WriteHeader(res.Status)
+		// Buffered because onRequest may respond from this goroutine.
+		cres := make(chan *hls.MuxerFileResponse, 1)
+		m.onRequest(&hlsMuxerRequest{
+			path:     dir,
+			file:     fname,
+			req:      r,
+			clientID: hlsClientID(w, r),
+			res:      cres,
+		})
+		res := <-cres
+
+		for k, v := range res.Header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(res.Status)
 
-			if res.Body != nil {
-				io.Copy(w, res.Body) //nolint:errcheck
-			}
+		if res.Body != nil {
+			io.Copy(w, res.Body) //nolint:errcheck
 		}
 	}
 }
@@ -282,28 +304,14 @@ func (s *hlsServer) muxerClose(c *HLSMuxer) {
 	}
 }
 
-type muxerByPathNameRequest struct {
-	pathName string
-	res      chan *HLSMuxer
-}
-
 // MuxerByPathName .
 func (s *hlsServer) MuxerByPathName(ctx context.Context, pathName string) (*hls.Muxer, error) {
-	muxerByPathNameRes := make(chan *HLSMuxer)
-	muxerByPathNameReq := muxerByPathNameRequest{
-		pathName: pathName,
-		res:      muxerByPathNameRes,
-	}
-	select {
-	case <-ctx.Done():
+	if ctx.Err() != nil || s.ctx.Err() != nil {
 		return nil, context.Canceled
-	case <-s.ctx.Done():
+	}
+	m, exist := s.muxers.get(pathName)
+	if !exist || m.muxer == nil {
 		return nil, context.Canceled
-	case s.chMuxerbyPathName <- muxerByPathNameReq:
-		res := <-muxerByPathNameRes
-		if res == nil || res.muxer == nil {
-			return nil, context.Canceled
-		}
-		return res.muxer, nil
 	}
+	return m.muxer, nil
 }
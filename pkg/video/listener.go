@@ -0,0 +1,148 @@
+package video
+
+import (
+	"net"
+	"strconv"
+	"sync"
+)
+
+// listenAddresses returns the addresses to listen on for the bind hosts.
+// Empty hosts listen on the loopback interface.
+func listenAddresses(hosts []string, port int) []string {
+	if len(hosts) == 0 {
+		hosts = []string{"127.0.0.1"}
+	}
+	addresses := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	return addresses
+}
+
+// clientAddress returns the address that local clients, like the
+// monitors and addons, use to connect to one of the bind hosts.
+// A loopback address is preferred. Wildcard hosts are
+// replaced by the loopback address of the same family.
+func clientAddress(hosts []string, port int) string {
+	if len(hosts) == 0 {
+		hosts = []string{"127.0.0.1"}
+	}
+	p := strconv.Itoa(port)
+	for _, host := range hosts {
+		ip := net.ParseIP(host)
+		if ip != nil && ip.IsLoopback() {
+			return net.JoinHostPort(host, p)
+		}
+	}
+	for _, host := range hosts {
+		switch {
+		case host == "" || host == "0.0.0.0":
+			return net.JoinHostPort("127.0.0.1", p)
+		case host == "::":
+			return net.JoinHostPort("::1", p)
+		}
+	}
+	return net.JoinHostPort(hosts[0], p)
+}
+
+// listenTCP listens on all the addresses. The listeners
+// are closed if any of them fails to open.
+func listenTCP(addresses []string) (net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	if len(listeners) == 1 {
+		return listeners[0], nil
+	}
+	return newMultiListener(listeners), nil
+}
+
+// multiListener accepts connections from several listeners.
+type multiListener struct {
+	listeners []net.Listener
+	conns     chan net.Conn
+	errs      chan error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	l := &multiListener{
+		listeners: listeners,
+		conns:     make(chan net.Conn),
+		errs:      make(chan error),
+		done:      make(chan struct{}),
+	}
+	l.wg.Add(len(listeners))
+	for _, ln := range listeners {
+		go l.accept(ln)
+	}
+	return l
+}
+
+func (l *multiListener) accept(ln net.Listener) {
+	defer l.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			// Temporary errors are retried by the caller.
+			if ne, ok := err.(net.Error); ok && ne.Timeout() { //nolint:errorlint
+				continue
+			}
+			return
+		}
+		select {
+		case l.conns <- conn:
+		case <-l.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Accept implements net.Listener.
+func (l *multiListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes all the listeners.
+func (l *multiListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		for _, ln := range l.listeners {
+			if err2 := ln.Close(); err2 != nil && err == nil {
+				err = err2
+			}
+		}
+		l.wg.Wait()
+	})
+	return err
+}
+
+// Addr returns the address of the first listener.
+func (l *multiListener) Addr() net.Addr {
+	return l.listeners[0].Addr()
+}
@@ -0,0 +1,56 @@
+package video
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAddress(t *testing.T) {
+	cases := []struct {
+		hosts    []string
+		expected string
+	}{
+		{nil, "127.0.0.1:2021"},
+		{[]string{""}, "127.0.0.1:2021"},
+		{[]string{"0.0.0.0"}, "127.0.0.1:2021"},
+		{[]string{"::"}, "[::1]:2021"},
+		{[]string{"192.168.1.2", "::1"}, "[::1]:2021"},
+		{[]string{"192.168.1.2", "::"}, "[::1]:2021"},
+		{[]string{"192.168.1.2"}, "192.168.1.2:2021"},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, clientAddress(tc.hosts, 2021), tc.hosts)
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	ln, err := listenTCP([]string{"127.0.0.1:0", "127.0.0.2:0"})
+	require.NoError(t, err)
+	multi := ln.(*multiListener)
+
+	for _, l := range multi.listeners {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		accepted, err := ln.Accept()
+		require.NoError(t, err)
+		require.Equal(t, conn.LocalAddr().String(), accepted.RemoteAddr().String())
+		accepted.Close()
+	}
+
+	require.NoError(t, ln.Close())
+	_, err = ln.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+
+	t.Run("addressInUse", func(t *testing.T) {
+		first, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer first.Close()
+
+		_, err = listenTCP([]string{"127.0.0.1:0", first.Addr().String()})
+		require.Error(t, err)
+	})
+}
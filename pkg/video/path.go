@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"nvr/pkg/log"
 	"nvr/pkg/video/gortsplib"
+	"nvr/pkg/video/hls"
 	"regexp"
 	"sync"
+	"time"
 )
 
 type pathHLSServer interface {
@@ -15,6 +17,11 @@ type pathHLSServer interface {
 	pathSourceNotReady(pathName string)
 }
 
+// pathSource is the publisher of a path.
+type pathSource interface {
+	close()
+}
+
 type path struct {
 	name      string
 	conf      *PathConf
@@ -22,7 +29,7 @@ type path struct {
 	hlsServer pathHLSServer
 	logger    log.ILogger
 
-	source      *rtspSession
+	source      pathSource
 	sourceReady bool
 	stream      *stream
 	readers     map[*rtspSession]struct{}
@@ -58,6 +65,14 @@ func newPath(
 }
 
 func (pa *path) logf(level log.Level, format string, a ...interface{}) {
+	if pa.conf.IsIngest {
+		pa.logger.Log(log.Entry{
+			Level: level,
+			Src:   "app",
+			Msg:   fmt.Sprintf("%v: %v", pa.name, fmt.Sprintf(format, a...)),
+		})
+		return
+	}
 	processName := func() string {
 		if pa.conf.IsSub {
 			return "sub"
@@ -81,7 +96,9 @@ func (pa *path) close() {
 	}
 
 	if pa.sourceReady {
-		pa.hlsServer.pathSourceNotReady(pa.name)
+		if !pa.conf.IsIngest {
+			pa.hlsServer.pathSourceNotReady(pa.name)
+		}
 		pa.sourceReady = false
 	}
 	if pa.source != nil {
@@ -124,7 +141,7 @@ func (pa *path) streamGet() (*stream, error) {
 }
 
 // publisherAdd is called by a publisher through pathManager.
-func (pa *path) publisherAdd(session *rtspSession) (*path, error) {
+func (pa *path) publisherAdd(session pathSource) (*path, error) {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 	if pa.canceled {
@@ -147,15 +164,47 @@ func (pa *path) publisherStart(tracks gortsplib.Tracks) (*stream, error) {
 		return nil, context.Canceled
 	}
 
-	hlsMuxer, err := pa.hlsServer.pathSourceReady(pa, tracks)
-	if err != nil {
-		return nil, err
+	// Ingest paths are only read by the monitors.
+	var hlsMuxer *HLSMuxer
+	if !pa.conf.IsIngest {
+		var err error
+		hlsMuxer, err = pa.hlsServer.pathSourceReady(pa, tracks)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	pa.stream = newStream(tracks, hlsMuxer)
 	pa.sourceReady = true
 
-	return pa.stream, err
+	return pa.stream, nil
+}
+
+// publisherRemove is called by a publisher that may reconnect. Unlike
+// close, the path is kept. The readers are closed so they reconnect
+// to the next stream.
+func (pa *path) publisherRemove(source pathSource) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	if pa.canceled || pa.source != source {
+		return
+	}
+
+	if pa.sourceReady {
+		if !pa.conf.IsIngest {
+			pa.hlsServer.pathSourceNotReady(pa.name)
+		}
+		pa.sourceReady = false
+	}
+	if pa.stream != nil {
+		pa.stream.close()
+		pa.stream = nil
+	}
+	for r := range pa.readers {
+		r.close()
+		delete(pa.readers, r)
+	}
+	pa.source = nil
 }
 
 // readerRemove is called by a rtsp session.
@@ -196,6 +245,95 @@ func (pa *path) readerStart(session *rtspSession) {
 	pa.readers[session] = struct{}{}
 }
 
+// PathStats statistics of a path. The camera is only
+// connected once per path and shared by the consumers.
+type PathStats struct {
+	Name string `json:"name"`
+
+	// MonitorID is empty for ingest paths.
+	MonitorID string `json:"monitorID"`
+	IsSub     bool   `json:"isSub"`
+
+	// Ready is true when the input process is publishing.
+	Ready bool `json:"ready"`
+
+	// RTSPReaders number of RTSP clients reading the path,
+	// for example detection addons and re-streaming.
+	RTSPReaders int `json:"rtspReaders"`
+
+	// HLSClients number of HLS clients that have made a request
+	// in the last 10 seconds, for example the live page.
+	HLSClients int `json:"hlsClients"`
+
+	// HLSMemory memory used by the cached HLS segments.
+	HLSMemory hls.MemoryStats `json:"hlsMemory"`
+}
+
+func (pa *path) stats() PathStats {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	stats := PathStats{
+		Name:        pa.name,
+		MonitorID:   pa.conf.MonitorID,
+		IsSub:       pa.conf.IsSub,
+		Ready:       pa.sourceReady,
+		RTSPReaders: len(pa.readers),
+	}
+	if pa.stream != nil && pa.stream.hlsMuxer != nil {
+		stats.HLSClients = pa.stream.hlsMuxer.clientCount(time.Now())
+		stats.HLSMemory = pa.stream.hlsMuxer.memoryStats()
+	}
+	return stats
+}
+
+// RTSPReader is a RTSP client that's reading a path.
+type RTSPReader struct {
+	// ID of the RTSP session.
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	MonitorID  string    `json:"monitorID"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Started    time.Time `json:"started"`
+
+	// Bytes sent to the reader.
+	Bytes uint64 `json:"bytes"`
+}
+
+func (pa *path) rtspReaders() []RTSPReader {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	readers := make([]RTSPReader, 0, len(pa.readers))
+	for r := range pa.readers {
+		readers = append(readers, RTSPReader{
+			ID:         r.id,
+			Path:       pa.name,
+			MonitorID:  pa.conf.MonitorID,
+			RemoteAddr: r.author.NetConn().RemoteAddr().String(),
+			Started:    r.created,
+			Bytes:      r.ss.BytesSent(),
+		})
+	}
+	return readers
+}
+
+// readerClose closes the reader with the session ID.
+// Returns false if the path doesn't have the reader.
+func (pa *path) readerClose(id string) bool {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	for r := range pa.readers {
+		if r.id == id {
+			// The reader is removed by the session.
+			r.close()
+			return true
+		}
+	}
+	return false
+}
+
 // Errors.
 var (
 	ErrEmptyName    = errors.New("name can not be empty")
@@ -231,6 +369,14 @@ func isValidPathName(name string) error {
 type PathConf struct {
 	MonitorID string
 	IsSub     bool
+
+	// IsIngest is true for paths that RTMP encoders publish to.
+	// They don't have a monitor or a HLS muxer.
+	IsIngest bool
+
+	// OnH264 is called for every H264 frame. It's called
+	// from the muxer goroutine and must not block.
+	OnH264 func(H264Frame)
 }
 
 // Errors.
@@ -246,7 +392,7 @@ func (pconf *PathConf) CheckAndFillMissing(name string) error {
 	if name == "" {
 		return ErrEmptyPathName
 	}
-	if pconf.MonitorID == "" {
+	if pconf.MonitorID == "" && !pconf.IsIngest {
 		return ErrEmptyMonitorID
 	}
 
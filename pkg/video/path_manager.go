@@ -3,10 +3,12 @@ package video
 import (
 	"context"
 	"errors"
+	"fmt"
 	"nvr/pkg/log"
 	"nvr/pkg/video/gortsplib"
 	"nvr/pkg/video/gortsplib/pkg/base"
 	"nvr/pkg/video/hls"
+	"sort"
 	"sync"
 )
 
@@ -16,14 +18,15 @@ type pathManagerHLSServer interface {
 	MuxerByPathName(ctx context.Context, pathName string) (*hls.Muxer, error)
 }
 
+// pathManager keeps the paths in a copy-on-write snapshot. Lookups are
+// wait-free because they happen on every RTSP and HLS request, while
+// paths are only added and removed when monitors are restarted.
 type pathManager struct {
 	wg  *sync.WaitGroup
 	log log.ILogger
-	mu  sync.Mutex
 
 	hlsServer pathManagerHLSServer
-	pathConfs map[string]*PathConf
-	paths     map[string]*path
+	paths     snapshotMap[*path]
 }
 
 func newPathManager(
@@ -36,8 +39,6 @@ func newPathManager(
 		log: log,
 
 		hlsServer: hlsServer,
-		pathConfs: make(map[string]*PathConf),
-		paths:     make(map[string]*path),
 	}
 }
 
@@ -45,6 +46,7 @@ func newPathManager(
 var (
 	ErrPathAlreadyExist = errors.New("path already exist")
 	ErrPathNotExist     = errors.New("path not exist")
+	ErrReaderNotExist   = errors.New("reader not exist")
 )
 
 // AddPath add path to pathManager.
@@ -53,33 +55,31 @@ func (pm *pathManager) AddPath(
 	name string,
 	newConf PathConf,
 ) (HlsMuxerFunc, error) {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
 	err := newConf.CheckAndFillMissing(name)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, exist := pm.pathConfs[name]; exist {
-		return nil, ErrPathAlreadyExist
+	var pa *path
+	err = pm.paths.update(func(paths map[string]*path) error {
+		if _, exist := paths[name]; exist {
+			return ErrPathAlreadyExist
+		}
+		pa = newPath(
+			ctx,
+			name,
+			&newConf,
+			pm.wg,
+			pm.hlsServer,
+			pm.log,
+		)
+		paths[name] = pa
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	config := &newConf
-
-	// Add config.
-	pm.pathConfs[name] = config
-
-	// Add path.
-	pm.paths[name] = newPath(
-		ctx,
-		name,
-		config,
-		pm.wg,
-		pm.hlsServer,
-		pm.log,
-	)
-
 	hlsMuxer := func(ctx context.Context) (IHLSMuxer, error) {
 		return pm.hlsServer.MuxerByPathName(ctx, name)
 	}
@@ -88,15 +88,16 @@ func (pm *pathManager) AddPath(
 		// Remove path.
 		<-ctx.Done()
 
-		pm.mu.Lock()
-		defer pm.mu.Unlock()
+		pm.paths.update(func(paths map[string]*path) error { //nolint:errcheck
+			if paths[name] == pa {
+				delete(paths, name)
+			}
+			return nil
+		})
 
-		// Remove config.
-		delete(pm.pathConfs, name)
-
-		// Close and remove path.
-		go pm.paths[name].close()
-		delete(pm.paths, name)
+		// Readers that loaded the path before it was removed
+		// will get an error because the path is canceled.
+		go pa.close()
 	}()
 
 	return hlsMuxer, nil
@@ -104,10 +105,7 @@ func (pm *pathManager) AddPath(
 
 // Testing.
 func (pm *pathManager) pathExist(name string) bool {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	_, exist := pm.pathConfs[name]
+	_, exist := pm.paths.get(name)
 	return exist
 }
 
@@ -115,10 +113,7 @@ func (pm *pathManager) pathExist(name string) bool {
 func (pm *pathManager) onDescribe(
 	pathName string,
 ) (*base.Response, *gortsplib.ServerStream, error) {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	path, exist := pm.paths[pathName]
+	path, exist := pm.paths.get(pathName)
 	if !exist {
 		return &base.Response{
 			StatusCode: base.StatusNotFound,
@@ -140,15 +135,12 @@ func (pm *pathManager) onDescribe(
 	return &base.Response{StatusCode: base.StatusOK}, stream.rtspStream, nil
 }
 
-// publisherAdd is called by a rtsp publisher.
+// publisherAdd is called by a rtsp or rtmp publisher.
 func (pm *pathManager) publisherAdd(
 	name string,
-	session *rtspSession,
+	session pathSource,
 ) (*path, error) {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	path, exist := pm.paths[name]
+	path, exist := pm.paths.get(name)
 	if !exist {
 		return nil, ErrPathNotExist
 	}
@@ -160,21 +152,53 @@ func (pm *pathManager) readerAdd(
 	name string,
 	session *rtspSession,
 ) (*path, *stream, error) {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	path, exist := pm.paths[name]
+	path, exist := pm.paths.get(name)
 	if !exist {
 		return nil, nil, ErrPathNotExist
 	}
 	return path.readerAdd(session)
 }
 
-func (pm *pathManager) pathLogfByName(name string) log.Func {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
+// pathStats returns the statistics of all paths sorted by name.
+func (pm *pathManager) pathStats() []PathStats {
+	paths := pm.paths.load()
+	stats := make([]PathStats, 0, len(paths))
+	for _, path := range paths {
+		stats = append(stats, path.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// rtspReaders returns the RTSP readers of all paths sorted by start time.
+func (pm *pathManager) rtspReaders() []RTSPReader {
+	readers := []RTSPReader{}
+	for _, path := range pm.paths.load() {
+		readers = append(readers, path.rtspReaders()...)
+	}
+	sort.Slice(readers, func(i, j int) bool {
+		if readers[i].Started.Equal(readers[j].Started) {
+			return readers[i].ID < readers[j].ID
+		}
+		return readers[i].Started.Before(readers[j].Started)
+	})
+	return readers
+}
+
+// readerClose closes the RTSP reader with the session ID.
+func (pm *pathManager) readerClose(id string) error {
+	for _, path := range pm.paths.load() {
+		if path.readerClose(id) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrReaderNotExist, id)
+}
 
-	path, exist := pm.paths[name]
+func (pm *pathManager) pathLogfByName(name string) log.Func {
+	path, exist := pm.paths.get(name)
 	if exist {
 		return path.logf
 	}
@@ -0,0 +1,92 @@
+package video
+
+import (
+	"context"
+	"nvr/pkg/video/gortsplib"
+	"nvr/pkg/video/gortsplib/pkg/h264"
+	"nvr/pkg/video/hls"
+)
+
+// SubPathSuffix is appended to the monitor ID to get the path of the
+// sub stream, the main stream path is the monitor ID. All protocols
+// use the same path names, for example "/hls/<path>/index.m3u8".
+const SubPathSuffix = "_sub"
+
+// PathName returns the path name of the main or sub stream of a monitor.
+func PathName(monitorID string, isSub bool) string {
+	if isSub {
+		return monitorID + SubPathSuffix
+	}
+	return monitorID
+}
+
+// Rendition names.
+const (
+	RenditionMain = "main"
+	RenditionSub  = "sub"
+)
+
+// Rendition is a live stream of a monitor.
+type Rendition struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+
+	// Ready is true when the input process is publishing.
+	Ready bool `json:"ready"`
+
+	// Zero if the stream isn't ready.
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	Bitrate int64   `json:"bitrate"` // Bits per second of the latest segment.
+	FPS     float64 `json:"fps"`     // Frame rate of the latest segment.
+	Codecs  string  `json:"codecs"`
+}
+
+// Renditions returns the live streams of a monitor, main
+// stream first. Streams without a path are not included.
+func (s *Server) Renditions(ctx context.Context, monitorID string) []Rendition {
+	renditions := []Rendition{}
+	for _, isSub := range []bool{false, true} {
+		pathName := PathName(monitorID, isSub)
+		if !s.PathExist(pathName) {
+			continue
+		}
+		name := RenditionMain
+		if isSub {
+			name = RenditionSub
+		}
+		rendition := Rendition{Name: name, Path: pathName}
+
+		muxer, err := s.LiveMuxer(ctx, pathName)
+		if err == nil {
+			fillRendition(&rendition, muxer)
+		}
+		renditions = append(renditions, rendition)
+	}
+	return renditions
+}
+
+type renditionMuxer interface {
+	VideoTrack() *gortsplib.TrackH264
+	LatestSegment() (*hls.Segment, error)
+	Codecs() string
+}
+
+func fillRendition(r *Rendition, muxer renditionMuxer) {
+	videoTrack := muxer.VideoTrack()
+	if videoTrack == nil {
+		return
+	}
+	r.Ready = true
+
+	var sps h264.SPS
+	if err := sps.Unmarshal(videoTrack.SafeSPS()); err == nil {
+		r.Width = sps.Width()
+		r.Height = sps.Height()
+	}
+	if segment, err := muxer.LatestSegment(); err == nil {
+		r.Bitrate = segment.Bitrate()
+		r.FPS = segment.FPS()
+	}
+	r.Codecs = muxer.Codecs()
+}
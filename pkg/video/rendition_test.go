@@ -0,0 +1,84 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nvr/pkg/video/gortsplib"
+	"nvr/pkg/video/hls"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathName(t *testing.T) {
+	require.Equal(t, "x", PathName("x", false))
+	require.Equal(t, "x_sub", PathName("x", true))
+}
+
+type mockRenditionMuxer struct {
+	videoTrack *gortsplib.TrackH264
+	segment    *hls.Segment
+}
+
+func (m mockRenditionMuxer) VideoTrack() *gortsplib.TrackH264 { return m.videoTrack }
+
+func (m mockRenditionMuxer) LatestSegment() (*hls.Segment, error) {
+	if m.segment == nil {
+		return nil, errors.New("mock")
+	}
+	return m.segment, nil
+}
+
+func (m mockRenditionMuxer) Codecs() string { return "avc1.64000c" }
+
+func TestFillRendition(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		sps := []byte{
+			0x67, 0x64, 0x00, 0x0c, 0xac, 0x3b, 0x50, 0xb0,
+			0x4b, 0x42, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00,
+			0x00, 0x03, 0x00, 0x3d, 0x08,
+		}
+		r := Rendition{Name: RenditionSub, Path: "x_sub"}
+		fillRendition(&r, mockRenditionMuxer{videoTrack: &gortsplib.TrackH264{SPS: sps}})
+
+		expected := Rendition{
+			Name:   RenditionSub,
+			Path:   "x_sub",
+			Ready:  true,
+			Width:  352,
+			Height: 288,
+			Codecs: "avc1.64000c",
+		}
+		require.Equal(t, expected, r)
+	})
+	t.Run("fps", func(t *testing.T) {
+		samples := make([]*hls.VideoSample, 25)
+		segment := &hls.Segment{
+			Parts: []*hls.MuxerPart{
+				{VideoSamples: samples[:10]},
+				{VideoSamples: samples[10:]},
+			},
+			RenderedDuration: 2 * time.Second,
+		}
+		r := Rendition{Name: RenditionMain, Path: "x"}
+		fillRendition(&r, mockRenditionMuxer{
+			videoTrack: &gortsplib.TrackH264{},
+			segment:    segment,
+		})
+		require.Equal(t, 12.5, r.FPS)
+	})
+	t.Run("notReady", func(t *testing.T) {
+		r := Rendition{Name: RenditionMain, Path: "x"}
+		fillRendition(&r, mockRenditionMuxer{})
+		require.Equal(t, Rendition{Name: RenditionMain, Path: "x"}, r)
+	})
+}
+
+func TestRenditionsNoPaths(t *testing.T) {
+	s, cancel := newTestServer(t)
+	defer cancel()
+
+	require.Equal(t, []Rendition{}, s.Renditions(context.Background(), "x"))
+}
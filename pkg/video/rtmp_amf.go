@@ -0,0 +1,217 @@
+package video
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AMF0 markers.
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfDate        = 0x0b
+	amfLongString  = 0x0c
+)
+
+// amfObj is a decoded AMF0 object or ECMA array.
+type amfObj map[string]interface{}
+
+// Errors.
+var (
+	ErrAMFShort       = errors.New("amf: buffer too short")
+	ErrAMFUnsupported = errors.New("amf: unsupported marker")
+)
+
+// amfDecode decodes all the values of a command or data message.
+// Numbers are decoded as float64, objects as amfObj and null or
+// undefined as nil.
+func amfDecode(buf []byte) ([]interface{}, error) {
+	var values []interface{}
+	for len(buf) > 0 {
+		v, n, err := amfDecodeValue(buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		buf = buf[n:]
+	}
+	return values, nil
+}
+
+const amfMaxDepth = 16
+
+func amfDecodeValue(buf []byte, depth int) (interface{}, int, error) { //nolint:funlen
+	if depth > amfMaxDepth {
+		return nil, 0, fmt.Errorf("amf: max depth exceeded: %w", ErrAMFUnsupported)
+	}
+	if len(buf) < 1 {
+		return nil, 0, ErrAMFShort
+	}
+	switch buf[0] {
+	case amfNumber:
+		if len(buf) < 9 {
+			return nil, 0, ErrAMFShort
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(buf[1:9])), 9, nil
+
+	case amfBoolean:
+		if len(buf) < 2 {
+			return nil, 0, ErrAMFShort
+		}
+		return buf[1] != 0, 2, nil
+
+	case amfString:
+		s, n, err := amfDecodeString(buf[1:])
+		return s, n + 1, err
+
+	case amfLongString:
+		if len(buf) < 5 {
+			return nil, 0, ErrAMFShort
+		}
+		size := int(binary.BigEndian.Uint32(buf[1:5]))
+		if len(buf)-5 < size {
+			return nil, 0, ErrAMFShort
+		}
+		return string(buf[5 : 5+size]), 5 + size, nil
+
+	case amfNull, amfUndefined:
+		return nil, 1, nil
+
+	case amfObject:
+		obj, n, err := amfDecodeProperties(buf[1:], depth)
+		return obj, n + 1, err
+
+	case amfECMAArray:
+		// The count is only a hint.
+		if len(buf) < 5 {
+			return nil, 0, ErrAMFShort
+		}
+		obj, n, err := amfDecodeProperties(buf[5:], depth)
+		return obj, n + 5, err
+
+	case amfStrictArray:
+		if len(buf) < 5 {
+			return nil, 0, ErrAMFShort
+		}
+		count := int(binary.BigEndian.Uint32(buf[1:5]))
+		pos := 5
+		var arr []interface{}
+		for i := 0; i < count; i++ {
+			v, n, err := amfDecodeValue(buf[pos:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, v)
+			pos += n
+		}
+		return arr, pos, nil
+
+	case amfDate:
+		if len(buf) < 11 {
+			return nil, 0, ErrAMFShort
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(buf[1:9])), 11, nil
+
+	default:
+		return nil, 0, fmt.Errorf("%w: 0x%02x", ErrAMFUnsupported, buf[0])
+	}
+}
+
+func amfDecodeString(buf []byte) (string, int, error) {
+	if len(buf) < 2 {
+		return "", 0, ErrAMFShort
+	}
+	size := int(binary.BigEndian.Uint16(buf))
+	if len(buf)-2 < size {
+		return "", 0, ErrAMFShort
+	}
+	return string(buf[2 : 2+size]), 2 + size, nil
+}
+
+func amfDecodeProperties(buf []byte, depth int) (amfObj, int, error) {
+	obj := make(amfObj)
+	pos := 0
+	for {
+		key, n, err := amfDecodeString(buf[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += n
+		if key == "" {
+			if len(buf[pos:]) < 1 {
+				return nil, 0, ErrAMFShort
+			}
+			if buf[pos] == amfObjectEnd {
+				return obj, pos + 1, nil
+			}
+		}
+		v, n, err := amfDecodeValue(buf[pos:], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		obj[key] = v
+		pos += n
+	}
+}
+
+// amfEncode encodes the values. Supported types are float64, int,
+// bool, string, amfObj and nil. Object keys are sorted.
+func amfEncode(values ...interface{}) []byte {
+	var buf []byte
+	for _, v := range values {
+		buf = amfAppendValue(buf, v)
+	}
+	return buf
+}
+
+func amfAppendValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case float64:
+		buf = append(buf, amfNumber)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+
+	case int:
+		return amfAppendValue(buf, float64(v))
+
+	case bool:
+		if v {
+			return append(buf, amfBoolean, 1)
+		}
+		return append(buf, amfBoolean, 0)
+
+	case string:
+		buf = append(buf, amfString)
+		return amfAppendString(buf, v)
+
+	case amfObj:
+		buf = append(buf, amfObject)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			buf = amfAppendString(buf, key)
+			buf = amfAppendValue(buf, v[key])
+		}
+		return append(buf, 0, 0, amfObjectEnd)
+
+	default:
+		return append(buf, amfNull)
+	}
+}
+
+func amfAppendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
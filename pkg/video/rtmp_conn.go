@@ -0,0 +1,348 @@
+package video
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RTMP message types.
+const (
+	rtmpMsgSetChunkSize     = 1
+	rtmpMsgAbort            = 2
+	rtmpMsgAck              = 3
+	rtmpMsgUserControl      = 4
+	rtmpMsgWindowAckSize    = 5
+	rtmpMsgSetPeerBandwidth = 6
+	rtmpMsgAudio            = 8
+	rtmpMsgVideo            = 9
+	rtmpMsgDataAMF3         = 15
+	rtmpMsgCommandAMF3      = 17
+	rtmpMsgDataAMF0         = 18
+	rtmpMsgCommandAMF0      = 20
+)
+
+// Chunk stream IDs used by the server.
+const (
+	rtmpCSIDControl = 2
+	rtmpCSIDCommand = 3
+	rtmpCSIDStatus  = 5
+)
+
+const (
+	rtmpVersion       = 3
+	rtmpHandshakeSize = 1536
+
+	rtmpDefaultChunkSize = 128
+	rtmpMaxChunkStreams  = 64
+
+	// Limits the memory used by a single message. The largest
+	// messages are key frames, which are far below this.
+	rtmpMaxMessageSize = 8 * 1024 * 1024
+)
+
+// rtmpMessage is a reassembled RTMP message.
+type rtmpMessage struct {
+	typ       uint8
+	streamID  uint32
+	timestamp uint32
+	body      []byte
+}
+
+// Errors.
+var (
+	ErrRTMPVersion          = errors.New("unsupported RTMP version")
+	ErrRTMPInvalidChunk     = errors.New("invalid chunk")
+	ErrRTMPTooManyStreams   = errors.New("too many chunk streams")
+	ErrRTMPMessageTooLarge  = errors.New("message too large")
+	ErrRTMPInvalidChunkSize = errors.New("invalid chunk size")
+)
+
+// rtmpHandshake performs the server side of the simple handshake. The
+// digest handshake is only required by Flash players, not by encoders.
+func rtmpHandshake(rw io.ReadWriter) error {
+	c0c1 := make([]byte, 1+rtmpHandshakeSize)
+	if _, err := io.ReadFull(rw, c0c1); err != nil {
+		return fmt.Errorf("read C0+C1: %w", err)
+	}
+	if c0c1[0] != rtmpVersion {
+		return fmt.Errorf("%w: %v", ErrRTMPVersion, c0c1[0])
+	}
+
+	s0s1s2 := make([]byte, 1+2*rtmpHandshakeSize)
+	s0s1s2[0] = rtmpVersion
+	// Time and zero fields are left empty.
+	if _, err := rand.Read(s0s1s2[9 : 1+rtmpHandshakeSize]); err != nil {
+		return err
+	}
+	// S2 echoes C1.
+	copy(s0s1s2[1+rtmpHandshakeSize:], c0c1[1:])
+	if _, err := rw.Write(s0s1s2); err != nil {
+		return fmt.Errorf("write S0+S1+S2: %w", err)
+	}
+
+	c2 := make([]byte, rtmpHandshakeSize)
+	if _, err := io.ReadFull(rw, c2); err != nil {
+		return fmt.Errorf("read C2: %w", err)
+	}
+	return nil
+}
+
+// rtmpChunkStream is the state of a chunk stream. Headers of
+// later chunks are compressed relative to the previous chunk.
+type rtmpChunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typ       uint8
+	streamID  uint32
+	extended  bool
+
+	// Body of the message that is being reassembled.
+	body []byte
+}
+
+// rtmpReader reads messages from the chunk streams.
+type rtmpReader struct {
+	r         *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*rtmpChunkStream
+}
+
+func newRTMPReader(r *bufio.Reader) *rtmpReader {
+	return &rtmpReader{
+		r:         r,
+		chunkSize: rtmpDefaultChunkSize,
+		streams:   make(map[uint32]*rtmpChunkStream),
+	}
+}
+
+func (r *rtmpReader) setChunkSize(size uint32) error {
+	// The most significant bit must be zero.
+	if size == 0 || size > 0x7fffffff {
+		return fmt.Errorf("%w: %v", ErrRTMPInvalidChunkSize, size)
+	}
+	r.chunkSize = size
+	return nil
+}
+
+// abort discards the partial message of the chunk stream.
+func (r *rtmpReader) abort(csid uint32) {
+	if cs, exist := r.streams[csid]; exist {
+		cs.body = nil
+	}
+}
+
+// readMessage reads chunks until a message is complete.
+func (r *rtmpReader) readMessage() (*rtmpMessage, error) {
+	for {
+		msg, err := r.readChunk()
+		if err != nil {
+			return nil, err
+		}
+		if msg != nil {
+			return msg, nil
+		}
+	}
+}
+
+func (r *rtmpReader) readChunk() (*rtmpMessage, error) { //nolint:funlen
+	b0, err := r.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	format := b0 >> 6
+	csid := uint32(b0 & 0x3f)
+	switch csid {
+	case 0:
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b)
+	case 1:
+		var b [2]byte
+		if _, err := io.ReadFull(r.r, b[:]); err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b[0]) + uint32(b[1])*256
+	}
+
+	cs, exist := r.streams[csid]
+	if !exist {
+		if format != 0 {
+			return nil, fmt.Errorf("%w: first chunk of stream %v has format %v",
+				ErrRTMPInvalidChunk, csid, format)
+		}
+		if len(r.streams) >= rtmpMaxChunkStreams {
+			return nil, ErrRTMPTooManyStreams
+		}
+		cs = &rtmpChunkStream{}
+		r.streams[csid] = cs
+	}
+	if format != 3 && len(cs.body) != 0 {
+		return nil, fmt.Errorf("%w: new header before the end of the message", ErrRTMPInvalidChunk)
+	}
+
+	var h [11]byte
+	switch format {
+	case 0:
+		if _, err := io.ReadFull(r.r, h[:11]); err != nil {
+			return nil, err
+		}
+		timestamp := uint24(h[0:3])
+		cs.length = uint24(h[3:6])
+		cs.typ = h[6]
+		cs.streamID = binary.LittleEndian.Uint32(h[7:11])
+		cs.extended = timestamp == 0xffffff
+		if cs.extended {
+			if timestamp, err = r.readUint32(); err != nil {
+				return nil, err
+			}
+		}
+		cs.timestamp = timestamp
+		cs.delta = 0
+
+	case 1, 2:
+		size := 7
+		if format == 2 {
+			size = 3
+		}
+		if _, err := io.ReadFull(r.r, h[:size]); err != nil {
+			return nil, err
+		}
+		delta := uint24(h[0:3])
+		if format == 1 {
+			cs.length = uint24(h[3:6])
+			cs.typ = h[6]
+		}
+		cs.extended = delta == 0xffffff
+		if cs.extended {
+			if delta, err = r.readUint32(); err != nil {
+				return nil, err
+			}
+		}
+		cs.delta = delta
+		cs.timestamp += delta
+
+	case 3:
+		if cs.extended {
+			if _, err := r.readUint32(); err != nil {
+				return nil, err
+			}
+		}
+		if len(cs.body) == 0 {
+			// New message with the same header.
+			cs.timestamp += cs.delta
+		}
+	}
+
+	if cs.length > rtmpMaxMessageSize {
+		return nil, fmt.Errorf("%w: %v", ErrRTMPMessageTooLarge, cs.length)
+	}
+	if cs.body == nil {
+		cs.body = make([]byte, 0, cs.length)
+	}
+
+	n := min(r.chunkSize, cs.length-uint32(len(cs.body)))
+	start := len(cs.body)
+	cs.body = cs.body[:start+int(n)]
+	if _, err := io.ReadFull(r.r, cs.body[start:]); err != nil {
+		return nil, err
+	}
+
+	if uint32(len(cs.body)) < cs.length {
+		return nil, nil
+	}
+	msg := &rtmpMessage{
+		typ:       cs.typ,
+		streamID:  cs.streamID,
+		timestamp: cs.timestamp,
+		body:      cs.body,
+	}
+	cs.body = nil
+	return msg, nil
+}
+
+func (r *rtmpReader) readUint32() (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func appendUint24(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>16), byte(v>>8), byte(v))
+}
+
+// rtmpWriter writes messages as chunks.
+type rtmpWriter struct {
+	w         io.Writer
+	chunkSize int
+}
+
+func newRTMPWriter(w io.Writer) *rtmpWriter {
+	return &rtmpWriter{
+		w:         w,
+		chunkSize: rtmpDefaultChunkSize,
+	}
+}
+
+// writeMessage writes the message in a single write call.
+// The first chunk has a full header, the rest have none.
+func (w *rtmpWriter) writeMessage(csid uint8, msg *rtmpMessage) error {
+	buf := make([]byte, 0, 12+len(msg.body)+len(msg.body)/w.chunkSize)
+	buf = append(buf, csid&0x3f)
+	buf = appendUint24(buf, min(msg.timestamp, 0xffffff))
+	buf = appendUint24(buf, uint32(len(msg.body)))
+	buf = append(buf, msg.typ)
+	buf = binary.LittleEndian.AppendUint32(buf, msg.streamID)
+	if msg.timestamp >= 0xffffff {
+		buf = binary.BigEndian.AppendUint32(buf, msg.timestamp)
+	}
+
+	body := msg.body
+	for {
+		n := min(len(body), w.chunkSize)
+		buf = append(buf, body[:n]...)
+		body = body[n:]
+		if len(body) == 0 {
+			break
+		}
+		buf = append(buf, 0xc0|csid&0x3f)
+		if msg.timestamp >= 0xffffff {
+			buf = binary.BigEndian.AppendUint32(buf, msg.timestamp)
+		}
+	}
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func (w *rtmpWriter) writeControl(typ uint8, body []byte) error {
+	return w.writeMessage(rtmpCSIDControl, &rtmpMessage{typ: typ, body: body})
+}
+
+func (w *rtmpWriter) writeSetChunkSize(size int) error {
+	err := w.writeControl(rtmpMsgSetChunkSize, binary.BigEndian.AppendUint32(nil, uint32(size)))
+	if err != nil {
+		return err
+	}
+	w.chunkSize = size
+	return nil
+}
+
+func (w *rtmpWriter) writeCommand(csid uint8, streamID uint32, values ...interface{}) error {
+	return w.writeMessage(csid, &rtmpMessage{
+		typ:      rtmpMsgCommandAMF0,
+		streamID: streamID,
+		body:     amfEncode(values...),
+	})
+}
@@ -0,0 +1,647 @@
+package video
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"nvr/pkg/log"
+	"nvr/pkg/video/gortsplib"
+	"nvr/pkg/video/gortsplib/pkg/h264"
+	"nvr/pkg/video/gortsplib/pkg/mpeg4audio"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IngestPathPrefix is the prefix of the paths that RTMP
+// encoders publish to. The monitors read them over RTSP.
+const IngestPathPrefix = "ingest/"
+
+// rtmpServer accepts RTMP publishers and feeds the ingest
+// paths. Playing streams over RTMP isn't supported.
+type rtmpServer struct {
+	addresses   []string
+	streams     map[string]string
+	pathManager *pathManager
+	logger      *log.Logger
+
+	ctx context.Context
+	wg  *sync.WaitGroup
+}
+
+func newRTMPServer(
+	wg *sync.WaitGroup,
+	addresses []string,
+	streams map[string]string,
+	pathManager *pathManager,
+	logger *log.Logger,
+) *rtmpServer {
+	return &rtmpServer{
+		addresses:   addresses,
+		streams:     streams,
+		pathManager: pathManager,
+		logger:      logger,
+		wg:          wg,
+	}
+}
+
+func (s *rtmpServer) logf(level log.Level, format string, a ...interface{}) {
+	s.logger.Log(log.Entry{
+		Level: level,
+		Src:   "app",
+		Msg:   fmt.Sprintf("RTMP: %s", fmt.Sprintf(format, a...)),
+	})
+}
+
+func (s *rtmpServer) start(ctx context.Context) error {
+	s.ctx = ctx
+
+	// The ingest paths exist while the server is running
+	// so the monitors can connect before the encoders.
+	for name := range s.streams {
+		_, err := s.pathManager.AddPath(ctx, IngestPathPrefix+name, PathConf{IsIngest: true})
+		if err != nil {
+			return fmt.Errorf("add ingest path: %w", err)
+		}
+	}
+
+	ln, err := listenTCP(s.addresses)
+	if err != nil {
+		return err
+	}
+	s.logf(log.LevelInfo, "listener opened on %v", strings.Join(s.addresses, ", "))
+
+	s.wg.Add(1)
+	go s.run(ln)
+
+	return nil
+}
+
+func (s *rtmpServer) run(ln net.Listener) {
+	defer s.wg.Done()
+
+	var connWG sync.WaitGroup
+	defer connWG.Wait()
+
+	go func() {
+		<-s.ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		nconn, err := ln.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() { //nolint:errorlint
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			s.logf(log.LevelError, "accept: %v", err)
+			return
+		}
+
+		c := newRTMPConn(nconn, s.streams, s.pathManager, s.logf)
+		connWG.Add(1)
+		go func() {
+			defer connWG.Done()
+			c.run(s.ctx)
+		}()
+	}
+}
+
+// Errors.
+var (
+	ErrRTMPPlayNotSupported = errors.New("playing is not supported")
+	ErrRTMPUnknownStream    = errors.New("unknown stream")
+	ErrRTMPInvalidKey       = errors.New("invalid stream key")
+	ErrRTMPVideoCodec       = errors.New("unsupported video codec, only H264 is supported")
+	ErrRTMPNoVideo          = errors.New("no video sequence header")
+	ErrRTMPInvalidVideo     = errors.New("invalid video message")
+	ErrRTMPInvalidConfig    = errors.New("invalid AVC decoder configuration")
+	ErrRTMPTooManyMessages  = errors.New("too many messages before")
+)
+
+// Messages that are read before the first video frame.
+const rtmpMaxSetupMessages = 256
+
+// rtmpConn is a connection from a RTMP publisher.
+type rtmpConn struct {
+	nconn       net.Conn
+	streams     map[string]string
+	pathManager *pathManager
+	serverLogf  log.Func
+
+	r *rtmpReader
+	w *rtmpWriter
+
+	bytesRead     uint64
+	ackWindowSize uint32
+	lastAck       uint64
+
+	// Name of the ingest stream.
+	name string
+
+	videoTrack *gortsplib.TrackH264
+	audioTrack *gortsplib.TrackMPEG4Audio
+}
+
+func newRTMPConn(
+	nconn net.Conn,
+	streams map[string]string,
+	pathManager *pathManager,
+	serverLogf log.Func,
+) *rtmpConn {
+	c := &rtmpConn{
+		nconn:       nconn,
+		streams:     streams,
+		pathManager: pathManager,
+		serverLogf:  serverLogf,
+	}
+	c.r = newRTMPReader(bufio.NewReader(rtmpConnReader{c}))
+	c.w = newRTMPWriter(rtmpConnWriter{c})
+	return c
+}
+
+// rtmpConnReader sets the read deadline and counts the bytes
+// for the acknowledgements.
+type rtmpConnReader struct{ c *rtmpConn }
+
+func (r rtmpConnReader) Read(p []byte) (int, error) {
+	r.c.nconn.SetReadDeadline(time.Now().Add(readTimeout)) //nolint:errcheck
+	n, err := r.c.nconn.Read(p)
+	r.c.bytesRead += uint64(n)
+	return n, err
+}
+
+type rtmpConnWriter struct{ c *rtmpConn }
+
+func (w rtmpConnWriter) Write(p []byte) (int, error) {
+	w.c.nconn.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
+	return w.c.nconn.Write(p)
+}
+
+func (c *rtmpConn) logf(level log.Level, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	if c.name == "" {
+		c.serverLogf(level, "%v: %v", c.nconn.RemoteAddr(), msg)
+		return
+	}
+	c.serverLogf(level, "%v %v: %v", c.name, c.nconn.RemoteAddr(), msg)
+}
+
+// close is called by the path.
+func (c *rtmpConn) close() {
+	c.nconn.Close()
+}
+
+func (c *rtmpConn) run(ctx context.Context) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		c.nconn.Close()
+	}()
+
+	err := c.runInner()
+	if err != nil && ctx.Err() == nil {
+		c.logf(log.LevelError, "closed: %v", err)
+	} else {
+		c.logf(log.LevelDebug, "closed")
+	}
+}
+
+func (c *rtmpConn) runInner() error {
+	if err := rtmpHandshake(rtmpConnReadWriter{c}); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+
+	app, key, err := c.readPublish()
+	if err != nil {
+		return err
+	}
+
+	path, err := c.publisherAdd(app, key)
+	if err != nil {
+		c.writeStatus("error", "NetStream.Publish.BadName", err.Error()) //nolint:errcheck
+		return err
+	}
+	defer path.publisherRemove(c)
+
+	err = c.writeStatus("status", "NetStream.Publish.Start", "Start publishing")
+	if err != nil {
+		return err
+	}
+	c.logf(log.LevelInfo, "publishing")
+
+	firstFrame, err := c.readTracks()
+	if err != nil {
+		return err
+	}
+	tracks := gortsplib.Tracks{c.videoTrack}
+	if c.audioTrack != nil {
+		tracks = append(tracks, c.audioTrack)
+	}
+
+	stream, err := path.publisherStart(tracks)
+	if err != nil {
+		return err
+	}
+
+	msg := firstFrame
+	for {
+		if err := c.writeData(stream, msg); err != nil {
+			return err
+		}
+		if msg, err = c.readMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+type rtmpConnReadWriter struct{ c *rtmpConn }
+
+func (rw rtmpConnReadWriter) Read(p []byte) (int, error) {
+	return rtmpConnReader(rw).Read(p)
+}
+
+func (rw rtmpConnReadWriter) Write(p []byte) (int, error) {
+	return rtmpConnWriter(rw).Write(p)
+}
+
+func (c *rtmpConn) publisherAdd(app string, key string) (*path, error) {
+	expectedKey, exist := c.streams[app]
+	if !exist {
+		return nil, fmt.Errorf("%w: %q", ErrRTMPUnknownStream, app)
+	}
+	c.name = app
+	if subtle.ConstantTimeCompare([]byte(key), []byte(expectedKey)) != 1 {
+		return nil, ErrRTMPInvalidKey
+	}
+	return c.pathManager.publisherAdd(IngestPathPrefix+app, c)
+}
+
+// readMessage reads the next message and handles the protocol control messages.
+func (c *rtmpConn) readMessage() (*rtmpMessage, error) {
+	for {
+		msg, err := c.r.readMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		if c.ackWindowSize != 0 && c.bytesRead-c.lastAck >= uint64(c.ackWindowSize) {
+			c.lastAck = c.bytesRead
+			// The sequence number wraps around.
+			ack := binary.BigEndian.AppendUint32(nil, uint32(c.bytesRead))
+			if err := c.w.writeControl(rtmpMsgAck, ack); err != nil {
+				return nil, err
+			}
+		}
+
+		switch msg.typ {
+		case rtmpMsgSetChunkSize:
+			if len(msg.body) < 4 {
+				return nil, ErrRTMPInvalidChunkSize
+			}
+			if err := c.r.setChunkSize(binary.BigEndian.Uint32(msg.body)); err != nil {
+				return nil, err
+			}
+
+		case rtmpMsgAbort:
+			if len(msg.body) >= 4 {
+				c.r.abort(binary.BigEndian.Uint32(msg.body))
+			}
+
+		case rtmpMsgWindowAckSize:
+			if len(msg.body) >= 4 {
+				c.ackWindowSize = binary.BigEndian.Uint32(msg.body)
+			}
+
+		case rtmpMsgAck, rtmpMsgUserControl, rtmpMsgSetPeerBandwidth:
+
+		default:
+			return msg, nil
+		}
+	}
+}
+
+// Server window acknowledgement size and chunk size.
+const (
+	rtmpWindowAckSize = 2500000
+	rtmpChunkSize     = 4096
+)
+
+// readPublish handles the commands until the client publishes.
+// Returns the application name and the stream key.
+func (c *rtmpConn) readPublish() (string, string, error) { //nolint:funlen
+	var app string
+	for i := 0; i < rtmpMaxSetupMessages; i++ {
+		msg, err := c.readMessage()
+		if err != nil {
+			return "", "", err
+		}
+
+		body := msg.body
+		switch msg.typ {
+		case rtmpMsgCommandAMF0:
+		case rtmpMsgCommandAMF3:
+			// AMF3 commands start with a format byte and are AMF0 encoded.
+			if len(body) > 0 {
+				body = body[1:]
+			}
+		default:
+			continue
+		}
+
+		values, err := amfDecode(body)
+		if err != nil {
+			return "", "", fmt.Errorf("decode command: %w", err)
+		}
+		if len(values) < 2 {
+			continue
+		}
+		name, _ := values[0].(string)
+		txID, _ := values[1].(float64)
+
+		switch name {
+		case "connect":
+			if len(values) < 3 {
+				return "", "", fmt.Errorf("connect: %w", ErrAMFShort)
+			}
+			obj, _ := values[2].(amfObj)
+			app, _ = obj["app"].(string)
+			app, _, _ = strings.Cut(app, "?")
+			app = strings.Trim(app, "/")
+			if err := c.writeConnectResult(txID); err != nil {
+				return "", "", err
+			}
+
+		case "createStream":
+			// The publisher uses message stream 1.
+			err := c.w.writeCommand(rtmpCSIDCommand, 0, "_result", txID, nil, 1)
+			if err != nil {
+				return "", "", err
+			}
+
+		case "publish":
+			if len(values) < 4 {
+				return "", "", fmt.Errorf("publish: %w", ErrAMFShort)
+			}
+			key, _ := values[3].(string)
+			key, _, _ = strings.Cut(key, "?")
+			return app, key, nil
+
+		case "play":
+			return "", "", ErrRTMPPlayNotSupported
+		}
+	}
+	return "", "", fmt.Errorf("%w: publish", ErrRTMPTooManyMessages)
+}
+
+func (c *rtmpConn) writeConnectResult(txID float64) error {
+	err := c.w.writeControl(rtmpMsgWindowAckSize,
+		binary.BigEndian.AppendUint32(nil, rtmpWindowAckSize))
+	if err != nil {
+		return err
+	}
+	// Dynamic limit type.
+	bandwidth := binary.BigEndian.AppendUint32(nil, rtmpWindowAckSize)
+	err = c.w.writeControl(rtmpMsgSetPeerBandwidth, append(bandwidth, 2))
+	if err != nil {
+		return err
+	}
+	if err := c.w.writeSetChunkSize(rtmpChunkSize); err != nil {
+		return err
+	}
+	return c.w.writeCommand(rtmpCSIDCommand, 0,
+		"_result",
+		txID,
+		amfObj{
+			"fmsVer":       "FMS/3,0,1,123",
+			"capabilities": 31,
+		},
+		amfObj{
+			"level":          "status",
+			"code":           "NetConnection.Connect.Success",
+			"description":    "Connection succeeded.",
+			"objectEncoding": 0,
+		},
+	)
+}
+
+func (c *rtmpConn) writeStatus(level, code, description string) error {
+	return c.w.writeCommand(rtmpCSIDStatus, 1,
+		"onStatus",
+		0,
+		nil,
+		amfObj{
+			"level":       level,
+			"code":        code,
+			"description": description,
+		},
+	)
+}
+
+// FLV codec IDs and packet types.
+const (
+	flvCodecH264 = 7
+	flvCodecAAC  = 10
+
+	flvPacketSequenceHeader = 0
+	flvPacketData           = 1
+)
+
+// readTracks reads the sequence headers until the first video frame,
+// which is returned. Audio that isn't AAC is ignored.
+func (c *rtmpConn) readTracks() (*rtmpMessage, error) {
+	for i := 0; i < rtmpMaxSetupMessages; i++ {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg.typ {
+		case rtmpMsgVideo:
+			if len(msg.body) < 2 {
+				return nil, ErrRTMPInvalidVideo
+			}
+			if msg.body[0]&0x0f != flvCodecH264 {
+				return nil, fmt.Errorf("%w: %v", ErrRTMPVideoCodec, msg.body[0]&0x0f)
+			}
+			switch msg.body[1] {
+			case flvPacketSequenceHeader:
+				if err := c.onVideoSequenceHeader(msg.body); err != nil {
+					return nil, err
+				}
+			case flvPacketData:
+				if c.videoTrack != nil {
+					return msg, nil
+				}
+			}
+
+		case rtmpMsgAudio:
+			if len(msg.body) < 2 || msg.body[0]>>4 != flvCodecAAC {
+				continue
+			}
+			if msg.body[1] == flvPacketSequenceHeader {
+				var config mpeg4audio.Config
+				if err := config.Unmarshal(msg.body[2:]); err != nil {
+					return nil, fmt.Errorf("audio config: %w", err)
+				}
+				c.audioTrack = &gortsplib.TrackMPEG4Audio{
+					PayloadType:      97,
+					Config:           &config,
+					SizeLength:       13,
+					IndexLength:      3,
+					IndexDeltaLength: 3,
+				}
+			}
+		}
+	}
+	return nil, ErrRTMPNoVideo
+}
+
+func (c *rtmpConn) onVideoSequenceHeader(body []byte) error {
+	// Composition time is zero.
+	if len(body) < 5 {
+		return ErrRTMPInvalidVideo
+	}
+	sps, pps, err := parseAVCDecoderConfig(body[5:])
+	if err != nil {
+		return err
+	}
+	if c.videoTrack == nil {
+		c.videoTrack = &gortsplib.TrackH264{
+			PayloadType:       96,
+			SPS:               sps,
+			PPS:               pps,
+			PacketizationMode: 1,
+		}
+		return nil
+	}
+	c.videoTrack.SafeSetSPS(sps)
+	c.videoTrack.SafeSetPPS(pps)
+	return nil
+}
+
+// parseAVCDecoderConfig returns the first SPS and PPS of a
+// AVCDecoderConfigurationRecord. The NALU lengths must be 4 bytes.
+func parseAVCDecoderConfig(buf []byte) ([]byte, []byte, error) {
+	if len(buf) < 6 {
+		return nil, nil, ErrRTMPInvalidConfig
+	}
+	if lengthSize := buf[4]&0x03 + 1; lengthSize != 4 {
+		return nil, nil, fmt.Errorf("%w: unsupported NALU length size: %v",
+			ErrRTMPInvalidConfig, lengthSize)
+	}
+
+	readNALU := func(buf []byte) ([]byte, []byte, error) {
+		if len(buf) < 2 {
+			return nil, nil, ErrRTMPInvalidConfig
+		}
+		size := int(binary.BigEndian.Uint16(buf))
+		if size == 0 || len(buf)-2 < size {
+			return nil, nil, ErrRTMPInvalidConfig
+		}
+		return buf[2 : 2+size], buf[2+size:], nil
+	}
+
+	spsCount := int(buf[5] & 0x1f)
+	buf = buf[6:]
+	var sps []byte
+	for i := 0; i < spsCount; i++ {
+		nalu, rest, err := readNALU(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sps == nil {
+			sps = nalu
+		}
+		buf = rest
+	}
+
+	if len(buf) < 1 {
+		return nil, nil, ErrRTMPInvalidConfig
+	}
+	ppsCount := int(buf[0])
+	buf = buf[1:]
+	var pps []byte
+	for i := 0; i < ppsCount; i++ {
+		nalu, rest, err := readNALU(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pps == nil {
+			pps = nalu
+		}
+		buf = rest
+	}
+
+	if sps == nil || pps == nil {
+		return nil, nil, fmt.Errorf("%w: missing SPS or PPS", ErrRTMPInvalidConfig)
+	}
+	return sps, pps, nil
+}
+
+// writeData writes a media message to the stream.
+// Audio is the second track if present.
+func (c *rtmpConn) writeData(stream *stream, msg *rtmpMessage) error {
+	switch msg.typ {
+	case rtmpMsgVideo:
+		if len(msg.body) < 2 {
+			return ErrRTMPInvalidVideo
+		}
+		switch msg.body[1] {
+		case flvPacketSequenceHeader:
+			return c.onVideoSequenceHeader(msg.body)
+
+		case flvPacketData:
+			if len(msg.body) < 5 {
+				return ErrRTMPInvalidVideo
+			}
+			if len(msg.body) == 5 {
+				return nil
+			}
+			// Signed 24 bit composition time offset.
+			cts := int32(uint24(msg.body[2:5])<<8) >> 8
+			nalus, err := h264.AVCCUnmarshal(msg.body[5:])
+			if err != nil {
+				return fmt.Errorf("unmarshal video: %w", err)
+			}
+			pts := time.Duration(int64(msg.timestamp)+int64(cts)) * time.Millisecond
+			err = stream.writeData(&dataH264{
+				trackID: 0,
+				ntp:     time.Now(),
+				pts:     pts,
+				nalus:   nalus,
+			})
+			if err != nil {
+				c.logf(log.LevelWarning, "write data: %v", err)
+			}
+		}
+
+	case rtmpMsgAudio:
+		if c.audioTrack == nil || len(msg.body) < 3 ||
+			msg.body[0]>>4 != flvCodecAAC || msg.body[1] != flvPacketData {
+			return nil
+		}
+		err := stream.writeData(&dataMPEG4Audio{
+			trackID: 1,
+			ntp:     time.Now(),
+			pts:     time.Duration(msg.timestamp) * time.Millisecond,
+			aus:     [][]byte{msg.body[2:]},
+		})
+		if err != nil {
+			c.logf(log.LevelWarning, "write data: %v", err)
+		}
+	}
+	return nil
+}
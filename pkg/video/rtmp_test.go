@@ -0,0 +1,316 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+	"nvr/pkg/video/gortsplib"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAMF(t *testing.T) {
+	buf := amfEncode("connect", 1, amfObj{
+		"app":   "cam",
+		"audio": true,
+		"nested": amfObj{
+			"a": nil,
+		},
+	}, nil)
+
+	values, err := amfDecode(buf)
+	require.NoError(t, err)
+	expected := []interface{}{
+		"connect",
+		float64(1),
+		amfObj{
+			"app":   "cam",
+			"audio": true,
+			"nested": amfObj{
+				"a": nil,
+			},
+		},
+		nil,
+	}
+	require.Equal(t, expected, values)
+
+	_, err = amfDecode(buf[:len(buf)-2])
+	require.ErrorIs(t, err, ErrAMFShort)
+
+	_, err = amfDecode([]byte{0xff})
+	require.ErrorIs(t, err, ErrAMFUnsupported)
+}
+
+func TestRTMPReader(t *testing.T) {
+	t.Run("chunks", func(t *testing.T) {
+		var b bytes.Buffer
+		w := newRTMPWriter(&b)
+		body := bytes.Repeat([]byte{1}, 300)
+		msg := &rtmpMessage{typ: rtmpMsgVideo, streamID: 1, timestamp: 10, body: body}
+		require.NoError(t, w.writeMessage(6, msg))
+
+		// Format 2 header, only the timestamp delta.
+		b.Write([]byte{0x80 | 6, 0, 0, 5})
+		b.Write(body[:128])
+		b.Write([]byte{0xc0 | 6})
+		b.Write(body[:128])
+		b.Write([]byte{0xc0 | 6})
+		b.Write(body[:44])
+
+		// Format 3 header, same delta.
+		b.Write([]byte{0xc0 | 6})
+		b.Write(body[:128])
+		b.Write([]byte{0xc0 | 6})
+		b.Write(body[:128])
+		b.Write([]byte{0xc0 | 6})
+		b.Write(body[:44])
+
+		r := newRTMPReader(bufio.NewReader(&b))
+		for _, timestamp := range []uint32{10, 15, 20} {
+			actual, err := r.readMessage()
+			require.NoError(t, err)
+			require.Equal(t, timestamp, actual.timestamp)
+			require.Equal(t, uint8(rtmpMsgVideo), actual.typ)
+			require.Equal(t, uint32(1), actual.streamID)
+			require.Equal(t, body, actual.body)
+		}
+	})
+	t.Run("extendedTimestamp", func(t *testing.T) {
+		var b bytes.Buffer
+		w := newRTMPWriter(&b)
+		body := bytes.Repeat([]byte{1}, 200)
+		msg := &rtmpMessage{typ: rtmpMsgAudio, timestamp: 0x1000000, body: body}
+		require.NoError(t, w.writeMessage(4, msg))
+
+		actual, err := newRTMPReader(bufio.NewReader(&b)).readMessage()
+		require.NoError(t, err)
+		require.Equal(t, msg, actual)
+	})
+	t.Run("invalidFirstChunk", func(t *testing.T) {
+		r := newRTMPReader(bufio.NewReader(bytes.NewReader([]byte{0x40 | 3})))
+		_, err := r.readMessage()
+		require.ErrorIs(t, err, ErrRTMPInvalidChunk)
+	})
+}
+
+func TestParseAVCDecoderConfig(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0xc0, 0x1f}
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+
+	actualSPS, actualPPS, err := parseAVCDecoderConfig(avcDecoderConfig(sps, pps))
+	require.NoError(t, err)
+	require.Equal(t, sps, actualSPS)
+	require.Equal(t, pps, actualPPS)
+
+	_, _, err = parseAVCDecoderConfig(avcDecoderConfig(sps, nil))
+	require.ErrorIs(t, err, ErrRTMPInvalidConfig)
+
+	config := avcDecoderConfig(sps, pps)
+	_, _, err = parseAVCDecoderConfig(config[:len(config)-1])
+	require.ErrorIs(t, err, ErrRTMPInvalidConfig)
+}
+
+func avcDecoderConfig(sps []byte, pps []byte) []byte {
+	buf := []byte{1, sps[1], sps[2], sps[3], 0xff, 0xe1}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(sps)))
+	buf = append(buf, sps...)
+	if pps == nil {
+		return append(buf, 0)
+	}
+	buf = append(buf, 1)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(pps)))
+	return append(buf, pps...)
+}
+
+// testRTMPClient is a minimal RTMP publisher.
+type testRTMPClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *rtmpReader
+	w    *rtmpWriter
+}
+
+func newTestRTMPClient(t *testing.T, address string) *testRTMPClient {
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+	c0c1 := make([]byte, 1+rtmpHandshakeSize)
+	c0c1[0] = rtmpVersion
+	_, err = conn.Write(c0c1)
+	require.NoError(t, err)
+
+	s0s1s2 := make([]byte, 1+2*rtmpHandshakeSize)
+	_, err = conn.Read(s0s1s2[:1])
+	require.NoError(t, err)
+	require.Equal(t, byte(rtmpVersion), s0s1s2[0])
+	r := bufio.NewReader(conn)
+	_, err = r.Discard(2 * rtmpHandshakeSize)
+	require.NoError(t, err)
+
+	_, err = conn.Write(make([]byte, rtmpHandshakeSize))
+	require.NoError(t, err)
+
+	return &testRTMPClient{
+		t:    t,
+		conn: conn,
+		r:    newRTMPReader(r),
+		w:    newRTMPWriter(conn),
+	}
+}
+
+func (c *testRTMPClient) command(streamID uint32, values ...interface{}) {
+	require.NoError(c.t, c.w.writeCommand(rtmpCSIDCommand, streamID, values...))
+}
+
+// readCommand returns the next command.
+func (c *testRTMPClient) readCommand() []interface{} {
+	for {
+		msg, err := c.r.readMessage()
+		require.NoError(c.t, err)
+		switch msg.typ {
+		case rtmpMsgSetChunkSize:
+			require.NoError(c.t, c.r.setChunkSize(binary.BigEndian.Uint32(msg.body)))
+		case rtmpMsgCommandAMF0:
+			values, err := amfDecode(msg.body)
+			require.NoError(c.t, err)
+			return values
+		}
+	}
+}
+
+func (c *testRTMPClient) publish(app string, key string) amfObj {
+	c.command(0, "connect", 1, amfObj{"app": app, "type": "nonprivate"})
+	result := c.readCommand()
+	require.Equal(c.t, "_result", result[0])
+	require.Equal(c.t, "NetConnection.Connect.Success", result[3].(amfObj)["code"])
+
+	c.command(0, "releaseStream", 2, nil, key)
+	c.command(0, "FCPublish", 3, nil, key)
+	c.command(0, "createStream", 4, nil)
+	result = c.readCommand()
+	require.Equal(c.t, []interface{}{"_result", float64(4), nil, float64(1)}, result)
+
+	c.command(1, "publish", 5, nil, key, "live")
+	status := c.readCommand()
+	require.Equal(c.t, "onStatus", status[0])
+	return status[3].(amfObj) //nolint:forcetypeassert
+}
+
+func (c *testRTMPClient) writeMedia(typ uint8, timestamp uint32, body []byte) {
+	msg := &rtmpMessage{typ: typ, streamID: 1, timestamp: timestamp, body: body}
+	require.NoError(c.t, c.w.writeMessage(6, msg))
+}
+
+func newTestRTMPServer(t *testing.T) (*pathManager, string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	pm := newPathManager(wg, log.NewDummyLogger(), nil)
+	_, err := pm.AddPath(ctx, "ingest/cam", PathConf{IsIngest: true})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	streams := map[string]string{"cam": "0123456789abcdef"}
+	logf := func(log.Level, string, ...interface{}) {}
+	go func() {
+		for {
+			nconn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				newRTMPConn(nconn, streams, pm, logf).run(ctx)
+			}()
+		}
+	}()
+	return pm, ln.Addr().String()
+}
+
+func TestRTMPServer(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0xc0, 0x1f}
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+	aacConfig := []byte{0x12, 0x10}
+
+	isReady := func(pm *pathManager) bool {
+		return pm.pathStats()[0].Ready
+	}
+
+	t.Run("publish", func(t *testing.T) {
+		pm, address := newTestRTMPServer(t)
+
+		c := newTestRTMPClient(t, address)
+		status := c.publish("cam", "0123456789abcdef")
+		require.Equal(t, "NetStream.Publish.Start", status["code"])
+
+		videoHeader := append([]byte{0x17, 0, 0, 0, 0}, avcDecoderConfig(sps, pps)...)
+		c.writeMedia(rtmpMsgVideo, 0, videoHeader)
+		c.writeMedia(rtmpMsgAudio, 0, append([]byte{0xaf, 0}, aacConfig...))
+		require.False(t, isReady(pm))
+
+		idr := []byte{0, 0, 0, 3, 0x65, 0x88, 0x84}
+		c.writeMedia(rtmpMsgVideo, 0, append([]byte{0x17, 1, 0, 0, 0}, idr...))
+		c.writeMedia(rtmpMsgAudio, 0, []byte{0xaf, 1, 0x21, 0x10})
+		require.Eventually(t, func() bool { return isReady(pm) }, time.Second, time.Millisecond)
+
+		path, _ := pm.paths.get("ingest/cam")
+		stream, err := path.streamGet()
+		require.NoError(t, err)
+		tracks := stream.tracks()
+		require.Len(t, tracks, 2)
+		require.Equal(t, sps, tracks[0].(*gortsplib.TrackH264).SafeSPS())
+		require.Equal(t, 44100, tracks[1].(*gortsplib.TrackMPEG4Audio).Config.SampleRate)
+
+		// The path is kept after the publisher disconnects.
+		c.conn.Close()
+		require.Eventually(t, func() bool { return !isReady(pm) }, time.Second, time.Millisecond)
+
+		c = newTestRTMPClient(t, address)
+		status = c.publish("cam", "0123456789abcdef")
+		require.Equal(t, "NetStream.Publish.Start", status["code"])
+	})
+	t.Run("invalidKey", func(t *testing.T) {
+		_, address := newTestRTMPServer(t)
+
+		c := newTestRTMPClient(t, address)
+		status := c.publish("cam", "x")
+		require.Equal(t, "NetStream.Publish.BadName", status["code"])
+		require.Equal(t, ErrRTMPInvalidKey.Error(), status["description"])
+	})
+	t.Run("unknownStream", func(t *testing.T) {
+		_, address := newTestRTMPServer(t)
+
+		c := newTestRTMPClient(t, address)
+		status := c.publish("x", "0123456789abcdef")
+		require.Equal(t, "NetStream.Publish.BadName", status["code"])
+	})
+	t.Run("busy", func(t *testing.T) {
+		_, address := newTestRTMPServer(t)
+
+		c := newTestRTMPClient(t, address)
+		status := c.publish("cam", "0123456789abcdef")
+		require.Equal(t, "NetStream.Publish.Start", status["code"])
+
+		c2 := newTestRTMPClient(t, address)
+		status = c2.publish("cam", "0123456789abcdef")
+		require.Equal(t, "NetStream.Publish.BadName", status["code"])
+		require.Equal(t, ErrPathBusy.Error(), status["description"])
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"nvr/pkg/video/gortsplib"
 	"nvr/pkg/video/gortsplib/pkg/base"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,10 +17,11 @@ import (
 )
 
 type rtspServer struct {
-	address     string
-	readTimeout time.Duration
-	pathManager *pathManager
-	logger      *log.Logger
+	addresses       []string
+	readTimeout     time.Duration
+	readBufferCount int
+	pathManager     *pathManager
+	logger          *log.Logger
 
 	ctx      context.Context
 	wg       *sync.WaitGroup
@@ -35,30 +37,20 @@ const (
 
 func newRTSPServer(
 	wg *sync.WaitGroup,
-	address string,
+	addresses []string,
 	readBufferCount int,
 	pathManager *pathManager,
 	logger *log.Logger,
 ) *rtspServer {
-	s := &rtspServer{
-		wg:          wg,
-		address:     address,
-		readTimeout: readTimeout,
-		pathManager: pathManager,
-		logger:      logger,
-		sessions:    make(map[*gortsplib.ServerSession]*rtspSession),
+	return &rtspServer{
+		wg:              wg,
+		addresses:       addresses,
+		readTimeout:     readTimeout,
+		readBufferCount: readBufferCount,
+		pathManager:     pathManager,
+		logger:          logger,
+		sessions:        make(map[*gortsplib.ServerSession]*rtspSession),
 	}
-
-	s.srv = gortsplib.NewServer(
-		s,
-		readTimeout,
-		writeTimeout,
-		readBufferCount,
-		readBufferCount,
-		address,
-	)
-
-	return s
 }
 
 func (s *rtspServer) logf(level log.Level, format string, a ...interface{}) {
@@ -73,15 +65,28 @@ func (s *rtspServer) logf(level log.Level, format string, a ...interface{}) {
 func (s *rtspServer) start(ctx context.Context) error {
 	s.ctx = ctx
 
-	err := s.srv.Start()
+	ln, err := listenTCP(s.addresses)
 	if err != nil {
 		return err
 	}
+	s.srv = gortsplib.NewServer(
+		s,
+		readTimeout,
+		writeTimeout,
+		s.readBufferCount,
+		s.readBufferCount,
+		ln,
+	)
+
+	if err := s.srv.Start(); err != nil {
+		ln.Close()
+		return err
+	}
 
 	s.logger.Log(log.Entry{
 		Level: log.LevelInfo,
 		Src:   "app",
-		Msg:   fmt.Sprintf("RTSP: listener opened on %v", s.address),
+		Msg:   fmt.Sprintf("RTSP: listener opened on %v", strings.Join(s.addresses, ", ")),
 	})
 	s.wg.Add(1)
 	go s.run()
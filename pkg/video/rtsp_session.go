@@ -14,7 +14,7 @@ import (
 )
 
 type rtspSessionPathManager interface {
-	publisherAdd(name string, session *rtspSession) (*path, error)
+	publisherAdd(name string, session pathSource) (*path, error)
 	readerAdd(name string, session *rtspSession) (*path, *stream, error)
 }
 
@@ -23,6 +23,7 @@ type rtspSession struct {
 	ss          *gortsplib.ServerSession
 	author      *gortsplib.ServerConn
 	pathManager rtspSessionPathManager
+	created     time.Time
 
 	path            *path
 	pathLogf        log.Func
@@ -45,6 +46,7 @@ func newRTSPSession(
 		author:      sc,
 		pathManager: pathManager,
 		pathLogf:    pathLogf,
+		created:     time.Now(),
 	}
 
 	return s
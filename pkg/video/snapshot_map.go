@@ -0,0 +1,45 @@
+package video
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// snapshotMap is a copy-on-write map. Readers load an immutable
+// snapshot without locking, writers copy the map and are serialized.
+// It's meant for maps that are read much more often than written.
+type snapshotMap[V any] struct {
+	snapshot atomic.Pointer[map[string]V]
+	mu       sync.Mutex
+}
+
+// load returns the current snapshot, it must not be modified.
+func (m *snapshotMap[V]) load() map[string]V {
+	if s := m.snapshot.Load(); s != nil {
+		return *s
+	}
+	return nil
+}
+
+func (m *snapshotMap[V]) get(key string) (V, bool) {
+	v, exist := m.load()[key]
+	return v, exist
+}
+
+// update calls fn with a copy of the map and publishes
+// the copy if fn doesn't return an error.
+func (m *snapshotMap[V]) update(fn func(map[string]V) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := m.load()
+	s := make(map[string]V, len(old)+1)
+	for k, v := range old {
+		s[k] = v
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	m.snapshot.Store(&s)
+	return nil
+}
@@ -69,8 +69,10 @@ func (s *stream) writeData(data data) error {
 		s.rtspStream.WritePacketRTPWithNTP(data.getTrackID(), pkt, data.getNTP())
 	}
 
-	// Forward to hls muxer.
-	s.hlsMuxer.readerData(data)
+	// Forward to hls muxer. Ingest paths don't have one.
+	if s.hlsMuxer != nil {
+		s.hlsMuxer.readerData(data)
+	}
 
 	return nil
 }
@@ -245,7 +247,14 @@ func (t *streamTrackH264) onData(dat data) error {
 	if tdata.rtpPackets == nil {
 		t.updateTrackParametersFromNALUs(tdata.nalus)
 		tdata.nalus = t.remuxNALUs(tdata.nalus)
+		if tdata.nalus == nil {
+			return nil
+		}
 
+		// Publishers that don't use RTP, like RTMP.
+		if t.encoder == nil {
+			t.encoder = t.track.CreateEncoder()
+		}
 		return t.generateRTPPackets(tdata)
 	}
 
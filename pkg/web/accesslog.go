@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogSource is the log source of the access log.
+const AccessLogSource = "access"
+
+// AccessLog logs the method, path, status, latency, response size,
+// user and client IP of each request. Successful HLS requests are
+// sampled. The query is left out, it may contain tokens. Nothing is
+// changed if the access log is disabled.
+func AccessLog(
+	c storage.AccessLog,
+	a auth.Authenticator,
+	logger log.ILogger,
+	h http.Handler,
+) http.Handler {
+	if !c.Enable {
+		return h
+	}
+	var hlsCount atomic.Uint64
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &accessLogWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+		latency := time.Since(start)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Orchestrators check the health every few seconds.
+		if status < 400 && (r.URL.Path == "/healthz" || r.URL.Path == "/readyz") {
+			return
+		}
+		if status < 400 && strings.HasPrefix(r.URL.Path, "/hls/") {
+			// Log the first request and then one of every N.
+			if (hlsCount.Add(1)-1)%uint64(c.HLSSampleRate) != 0 {
+				return
+			}
+		}
+
+		level := log.LevelInfo
+		switch {
+		case status >= 500:
+			level = log.LevelError
+		case status >= 400:
+			level = log.LevelWarning
+		}
+		logger.Log(log.Entry{
+			Level: level,
+			Src:   AccessLogSource,
+			Msg: fmt.Sprintf("method=%v path=%q status=%v latency=%v bytes=%v user=%v ip=%v",
+				r.Method, r.URL.Path, status, latency.Round(time.Microsecond),
+				rw.bytes, accessLogUser(a, r, status), auth.ClientIP(r)),
+		})
+	})
+}
+
+// accessLogUser returns the authenticated user, "-" if none. The request
+// was already validated by the handler, valid requests are cached by the
+// authenticator. Rejected requests aren't validated again, the failure
+// would be counted twice by the login limiter.
+func accessLogUser(a auth.Authenticator, r *http.Request, status int) string {
+	if status == http.StatusUnauthorized || status == http.StatusTooManyRequests {
+		return "-"
+	}
+	if r.Header.Get("Authorization") == "" && !a.AuthDisabled() {
+		return "-"
+	}
+	res := a.ValidateRequest(r)
+	if !res.IsValid {
+		return "-"
+	}
+	if res.Impersonator != nil {
+		return fmt.Sprintf("%q impersonator=%q", res.User.Username, res.Impersonator.Username)
+	}
+	return fmt.Sprintf("%q", res.User.Username)
+}
+
+// accessLogWriter records the status and size of the response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush is used by the feed and export handlers.
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is used by websocket upgrades.
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
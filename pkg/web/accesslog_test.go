@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordLogger struct {
+	entries []log.Entry
+}
+
+func (l *recordLogger) Log(entry log.Entry) {
+	l.entries = append(l.entries, entry)
+}
+
+func TestAccessLog(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "x":
+			w.Write([]byte("abc")) //nolint:errcheck
+		case "unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "error":
+			http.Error(w, "x", http.StatusInternalServerError)
+		}
+	})
+	a := stubAuth{user: auth.Account{Username: "alice"}}
+	newHandler := func(sampleRate int) (http.Handler, *recordLogger) {
+		logger := &recordLogger{}
+		c := storage.AccessLog{Enable: true, HLSSampleRate: sampleRate}
+		return AccessLog(c, a, logger, next), logger
+	}
+	serve := func(h http.Handler, target string) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = "10.0.0.2:1234"
+		r.Header.Set("Authorization", "Basic x")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		h, logger := newHandler(1)
+		serve(h, "/api/x?token=secret")
+		require.Len(t, logger.entries, 1)
+
+		entry := logger.entries[0]
+		require.Equal(t, log.LevelInfo, entry.Level)
+		require.Equal(t, AccessLogSource, entry.Src)
+		require.Regexp(t, regexp.MustCompile(
+			`^method=GET path="/api/x" status=200 latency=\S+ bytes=3 user="alice" ip=10.0.0.2$`),
+			entry.Msg)
+	})
+	t.Run("status", func(t *testing.T) {
+		h, logger := newHandler(1)
+		serve(h, "/unauthorized")
+		serve(h, "/error")
+		require.Len(t, logger.entries, 2)
+
+		require.Equal(t, log.LevelWarning, logger.entries[0].Level)
+		require.Contains(t, logger.entries[0].Msg, "status=401")
+		require.Contains(t, logger.entries[0].Msg, "user=- ")
+
+		require.Equal(t, log.LevelError, logger.entries[1].Level)
+		require.Contains(t, logger.entries[1].Msg, "status=500")
+	})
+	t.Run("hlsSampling", func(t *testing.T) {
+		h, logger := newHandler(3)
+		for i := 0; i < 7; i++ {
+			serve(h, "/hls/a/index.m3u8")
+		}
+		require.Len(t, logger.entries, 3)
+
+		// Failed requests are always logged.
+		serve(h, "/hls/unauthorized")
+		require.Len(t, logger.entries, 4)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		logger := &recordLogger{}
+		h := AccessLog(storage.AccessLog{}, a, logger, next)
+		serve(h, "/api/x")
+		require.Empty(t, logger.entries)
+	})
+}
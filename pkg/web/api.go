@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nvr/pkg/web/auth"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Auth is the authentication level of a API route.
+type Auth int
+
+// Authentication levels.
+const (
+	AuthNone Auth = iota
+	AuthUser
+	AuthAdmin
+)
+
+func (a Auth) String() string {
+	switch a {
+	case AuthUser:
+		return "user"
+	case AuthAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// Route describes a API route. The description is used to apply
+// the authentication and to generate the OpenAPI specification,
+// the specification can't drift from what the handlers enforce.
+type Route struct {
+	Auth Auth
+
+	// CSRF if requests must include the "X-CSRF-TOKEN" header.
+	CSRF bool
+
+	Operations []Operation
+}
+
+// Operation is a method and path that's served by a route.
+type Operation struct {
+	// ID is the OpenAPI operation ID and
+	// the method name in the generated client.
+	ID     string
+	Method string
+
+	// Path is the OpenAPI path, defaults to the route pattern.
+	// Patterns that end with a slash need a path with the
+	// parameters, for example "/api/group/{id}/recordings".
+	Path string
+
+	Summary string
+	Params  []Param
+
+	// Request is a zero value of the JSON request body, nil if none.
+	Request interface{}
+
+	// Response is a zero value of the JSON response, nil if none.
+	Response interface{}
+
+	// ContentType of the request or response if it isn't JSON.
+	RequestType  string
+	ResponseType string
+
+	// Websocket if the request is upgraded to a websocket
+	// that sends messages of the response type.
+	Websocket bool
+
+	// List if the operation accepts the list query parameters.
+	List bool
+}
+
+// Param is a query or path parameter.
+type Param struct {
+	Name string
+
+	// In is "query" or "path".
+	In string
+
+	// Type is "string", "integer" or "boolean".
+	Type     string
+	Required bool
+
+	Description string
+}
+
+var (
+	apiRoutes   = make(map[string]Route)
+	apiRoutesMu sync.Mutex
+)
+
+// RegisterRoute documents a route by its mux pattern. Routes served
+// by other packages register their description from init functions.
+func RegisterRoute(pattern string, route Route) {
+	apiRoutesMu.Lock()
+	defer apiRoutesMu.Unlock()
+	if _, exist := apiRoutes[pattern]; exist {
+		panic(fmt.Sprintf("route already registered: %v", pattern))
+	}
+	apiRoutes[pattern] = route
+}
+
+func init() {
+	for pattern, route := range routes {
+		RegisterRoute(pattern, route)
+	}
+}
+
+// API registers documented routes on a mux.
+type API struct {
+	mux *http.ServeMux
+	a   auth.Authenticator
+}
+
+// NewAPI returns a API that registers routes on the mux.
+func NewAPI(mux *http.ServeMux, a auth.Authenticator) *API {
+	return &API{mux: mux, a: a}
+}
+
+// Handle registers the handler with the authentication of the
+// route description. Panics if the route isn't documented.
+func (api *API) Handle(pattern string, handler http.Handler) {
+	apiRoutesMu.Lock()
+	route, exist := apiRoutes[pattern]
+	apiRoutesMu.Unlock()
+	if !exist {
+		panic(fmt.Sprintf("undocumented route: %v", pattern))
+	}
+
+	if route.CSRF {
+		handler = api.a.CSRF(handler)
+	}
+	switch route.Auth {
+	case AuthUser:
+		handler = api.a.User(handler)
+	case AuthAdmin:
+		handler = api.a.Admin(handler)
+	case AuthNone:
+	}
+	api.mux.Handle(pattern, handler)
+}
+
+// Spec returns the OpenAPI specification of the documented routes.
+func Spec() *OpenAPI {
+	apiRoutesMu.Lock()
+	defer apiRoutesMu.Unlock()
+
+	patterns := make([]string, 0, len(apiRoutes))
+	for pattern := range apiRoutes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	g := newSchemaGenerator()
+	spec := &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:   "OS-NVR",
+			Version: "1",
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]OpenAPISecurityScheme{
+				"basicAuth": {Type: "http", Scheme: "basic"},
+				"csrfToken": {Type: "apiKey", In: "header", Name: "X-CSRF-TOKEN"},
+			},
+		},
+	}
+	for _, pattern := range patterns {
+		route := apiRoutes[pattern]
+		for _, op := range route.Operations {
+			if op.Path == "" {
+				op.Path = pattern
+			}
+			if spec.Paths[op.Path] == nil {
+				spec.Paths[op.Path] = make(map[string]*OpenAPIOperation)
+			}
+			spec.Paths[op.Path][strings.ToLower(op.Method)] = g.operation(route, op)
+		}
+	}
+	return spec
+}
+
+// APISpec serves the OpenAPI specification.
+func APISpec() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(Spec()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec(t *testing.T) {
+	spec := Spec()
+
+	_, err := json.Marshal(spec)
+	require.NoError(t, err)
+
+	ids := make(map[string]bool)
+	pathParamRegex := regexp.MustCompile(`{(\w+)}`)
+	for path, methods := range spec.Paths {
+		require.True(t, strings.HasPrefix(path, "/api/"), path)
+		for method, op := range methods {
+			name := method + " " + path
+			require.NotEmpty(t, op.OperationID, name)
+			require.False(t, ids[op.OperationID], "duplicate id: %v", op.OperationID)
+			ids[op.OperationID] = true
+			require.NotEmpty(t, op.Summary, name)
+
+			// Path parameters must be documented.
+			var pathParams []string
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					pathParams = append(pathParams, p.Name)
+				}
+			}
+			var want []string
+			for _, match := range pathParamRegex.FindAllStringSubmatch(path, -1) {
+				want = append(want, match[1])
+			}
+			require.Equal(t, want, pathParams, name)
+		}
+	}
+
+	// All references must resolve.
+	raw, err := json.Marshal(spec)
+	require.NoError(t, err)
+	refRegex := regexp.MustCompile(`"\$ref":"` + regexp.QuoteMeta(RefPrefix) + `(\w+)"`)
+	for _, match := range refRegex.FindAllStringSubmatch(string(raw), -1) {
+		require.Contains(t, spec.Components.Schemas, match[1])
+	}
+
+	t.Run("auth", func(t *testing.T) {
+		status := spec.Paths["/api/system/status"]["get"]
+		require.Equal(t, "none", status.Auth)
+		require.Empty(t, status.Security)
+
+		set := spec.Paths["/api/monitor/set"]["put"]
+		require.Equal(t, "admin", set.Auth)
+		require.Equal(t, []map[string][]string{{"basicAuth": {}, "csrfToken": {}}}, set.Security)
+	})
+	t.Run("list", func(t *testing.T) {
+		query := spec.Paths["/api/log/query"]["get"]
+		require.True(t, query.List)
+		var names []string
+		for _, p := range query.Parameters {
+			names = append(names, p.Name)
+		}
+		require.Subset(t, names, []string{"levels", "time", "page[size]", "page[cursor]"})
+		require.Len(t, query.Responses["200"].Content[jsonContentType].Schema.OneOf, 2)
+	})
+}
+
+func TestSchema(t *testing.T) {
+	type embedded struct {
+		A string `json:"a"`
+	}
+	type named struct {
+		embedded
+		B    int               `json:"b,omitempty"`
+		C    *named            `json:"c"`
+		D    map[string][]byte `json:"d"`
+		E    int64             `json:"e,string"`
+		F    string            `json:"-"`
+		G    interface{}       `json:"g"`
+		priv string
+	}
+
+	g := newSchemaGenerator()
+	require.Equal(t, &Schema{Ref: RefPrefix + "Named"}, g.schema(reflect.TypeOf(named{})))
+	require.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"a": {Type: "string", GoName: "A"},
+			"b": {Type: "integer", GoName: "B"},
+			"c": {Ref: RefPrefix + "Named", GoName: "C"},
+			"d": {
+				Type:                 "object",
+				AdditionalProperties: &Schema{Type: "string", Format: "byte"},
+				GoName:               "D",
+			},
+			"e": {Type: "string", GoName: "E"},
+			"g": {GoName: "G"},
+		},
+	}, g.schemas["Named"])
+}
+
+func TestAPIHandle(t *testing.T) {
+	api := NewAPI(http.NewServeMux(), nil)
+	require.Panics(t, func() {
+		api.Handle("/api/undocumented", http.NotFoundHandler())
+	})
+}
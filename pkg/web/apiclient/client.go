@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package apiclient is a client for the REST API. The methods and
+// types are generated from the OpenAPI specification, regenerate with:
+//
+//	go generate ./pkg/web/apiclient
+//
+// Websocket endpoints aren't included, see "/api/spec".
+package apiclient
+
+//go:generate go run ./gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Client authenticates with basic auth. The CSRF token that's
+// required by most write endpoints is requested when needed.
+type Client struct {
+	url      string
+	username string
+	password string
+
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	token string
+	mu    sync.Mutex
+}
+
+// New returns a client for the server at the URL, "http://host:port".
+func New(serverURL string, username string, password string) *Client {
+	return &Client{
+		url:      strings.TrimSuffix(serverURL, "/"),
+		username: username,
+		password: password,
+	}
+}
+
+// ListParams are the list query parameters. Methods that
+// accept them return a ListPage instead of the default format.
+type ListParams struct {
+	// Fields to include in each item.
+	Fields []string
+
+	// Sort by fields, "-" prefix sorts descending.
+	Sort []string
+
+	PageSize int
+
+	// The Next cursor of the previous page.
+	PageCursor string
+}
+
+func (l ListParams) encode(query url.Values) {
+	// An empty page cursor requests the first page.
+	query.Set("page[cursor]", l.PageCursor)
+	if len(l.Fields) != 0 {
+		query.Set("fields", strings.Join(l.Fields, ","))
+	}
+	if len(l.Sort) != 0 {
+		query.Set("sort", strings.Join(l.Sort, ","))
+	}
+	if l.PageSize != 0 {
+		query.Set("page[size]", strconv.Itoa(l.PageSize))
+	}
+}
+
+// ErrStatus unexpected response status.
+var ErrStatus = errors.New("unexpected status")
+
+// StatusError is returned when the server responds with a error status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v: %v %v", ErrStatus, e.StatusCode, e.Message)
+}
+
+// Unwrap returns ErrStatus.
+func (e *StatusError) Unwrap() error { return ErrStatus }
+
+// request sends a request and returns the response if the status is 2xx.
+func (c *Client) request(
+	ctx context.Context,
+	method string,
+	path string,
+	query url.Values,
+	body io.Reader,
+	contentType string,
+) (*http.Response, error) {
+	u := c.url + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if method != http.MethodGet {
+		token, err := c.csrfToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("csrf token: %w", err)
+		}
+		req.Header.Set("X-CSRF-TOKEN", token)
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, &StatusError{
+			StatusCode: res.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+	return res, nil
+}
+
+// doJSON marshals the body and unmarshals the response into out if not nil.
+func (c *Client) doJSON(
+	ctx context.Context,
+	method string,
+	path string,
+	query url.Values,
+	body interface{},
+	out interface{},
+) error {
+	var reqBody io.Reader
+	var contentType string
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+		contentType = "application/json"
+	}
+
+	res, err := c.request(ctx, method, path, query, reqBody, contentType)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// doStream returns the response body, the caller must close it.
+func (c *Client) doStream(
+	ctx context.Context,
+	method string,
+	path string,
+	query url.Values,
+	body io.Reader,
+	contentType string,
+) (io.ReadCloser, error) {
+	res, err := c.request(ctx, method, path, query, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// csrfToken returns the CSRF token of the user. Only admins
+// can request their token, it's empty for other users.
+func (c *Client) csrfToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" || c.username == "" {
+		return c.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/user/my-token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", nil
+	default:
+		return "", &StatusError{StatusCode: res.StatusCode, Message: res.Status}
+	}
+	token, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	c.token = string(token)
+	return c.token, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,2575 @@
+// Code generated by "go run ./gen"; DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AccountObfuscated is a API type.
+type AccountObfuscated struct {
+	ID                 string `json:"id,omitempty"`
+	IsAdmin            bool   `json:"isAdmin,omitempty"`
+	MustChangePassword bool   `json:"mustChangePassword,omitempty"`
+	Talkback           bool   `json:"talkback,omitempty"`
+	Username           string `json:"username,omitempty"`
+}
+
+// ActivityHeatmap is a API type.
+type ActivityHeatmap struct {
+	Buckets  int64                    `json:"buckets,omitempty"`
+	Interval string                   `json:"interval,omitempty"`
+	Monitors map[string]HeatmapCounts `json:"monitors,omitempty"`
+	Start    time.Time                `json:"start,omitempty"`
+}
+
+// AgeReport is a API type.
+type AgeReport struct {
+	Buckets   []int64            `json:"buckets,omitempty"`
+	Generated time.Time          `json:"generated,omitempty"`
+	Monitors  map[string][]int64 `json:"monitors,omitempty"`
+}
+
+// ArmState is a API type.
+type ArmState struct {
+	Armed    bool      `json:"armed,omitempty"`
+	Override string    `json:"override,omitempty"`
+	Until    time.Time `json:"until,omitempty"`
+}
+
+// Capabilities is a API type.
+type Capabilities struct {
+	Encoders []string `json:"encoders,omitempty"`
+	Hwaccels []string `json:"hwaccels,omitempty"`
+	Version  string   `json:"version,omitempty"`
+}
+
+// ChangePasswordRequest is a API type.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword,omitempty"`
+	NewPassword     string `json:"newPassword,omitempty"`
+}
+
+// CreateRequest is a API type.
+type CreateRequest struct {
+	Duration    int64  `json:"duration,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	MonitorID   string `json:"monitorId,omitempty"`
+	Note        string `json:"note,omitempty"`
+	RecordingID string `json:"recordingId,omitempty"`
+}
+
+// Credential is a API type.
+type Credential struct {
+	ID       string `json:"id,omitempty"`
+	Password string `json:"password,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Detection is a API type.
+type Detection struct {
+	Label  string  `json:"label,omitempty"`
+	Region Region  `json:"region,omitempty"`
+	Score  float64 `json:"score,omitempty"`
+	Text   string  `json:"text,omitempty"`
+	Zone   string  `json:"zone,omitempty"`
+}
+
+// DiskAlert is a API type.
+type DiskAlert struct {
+	Level         string    `json:"level,omitempty"`
+	Percent       int64     `json:"percent,omitempty"`
+	RetentionDays int64     `json:"retentionDays,omitempty"`
+	Time          time.Time `json:"time,omitempty"`
+}
+
+// Entry is a API type.
+type Entry struct {
+	Level     int64  `json:"level,omitempty"`
+	MonitorID string `json:"monitorID,omitempty"`
+	Msg       string `json:"msg,omitempty"`
+	Src       string `json:"src,omitempty"`
+	Time      int64  `json:"time,omitempty"`
+}
+
+// Event is a API type.
+type Event struct {
+	Detections []Detection `json:"detections,omitempty"`
+	Duration   int64       `json:"duration,omitempty"`
+	Time       time.Time   `json:"time,omitempty"`
+	Trigger    string      `json:"trigger,omitempty"`
+}
+
+// EventSnapshot is a API type.
+type EventSnapshot struct {
+	Detections []Detection `json:"detections,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	MonitorID  string      `json:"monitorId,omitempty"`
+	Time       time.Time   `json:"time,omitempty"`
+}
+
+// EventsFeedMessage is a API type.
+type EventsFeedMessage struct {
+	Cursor    int64     `json:"cursor,omitempty"`
+	Event     LiveEvent `json:"event,omitempty"`
+	Input     string    `json:"input,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+	Log       Entry     `json:"log,omitempty"`
+	MonitorID string    `json:"monitorId,omitempty"`
+	State     string    `json:"state,omitempty"`
+	Storage   DiskAlert `json:"storage,omitempty"`
+	Time      time.Time `json:"time,omitempty"`
+	Type      string    `json:"type,omitempty"`
+}
+
+// Forecast is a API type.
+type Forecast struct {
+	Days             int64                   `json:"days,omitempty"`
+	DaysUntilFull    int64                   `json:"daysUntilFull,omitempty"`
+	Generated        time.Time               `json:"generated,omitempty"`
+	Max              int64                   `json:"max,omitempty"`
+	MinRetentionDays int64                   `json:"minRetentionDays,omitempty"`
+	Monitors         map[string]ForecastRate `json:"monitors,omitempty"`
+	RetentionDays    int64                   `json:"retentionDays,omitempty"`
+	Total            ForecastRate            `json:"total,omitempty"`
+	Used             int64                   `json:"used,omitempty"`
+}
+
+// ForecastRate is a API type.
+type ForecastRate struct {
+	BytesPerDay  int64 `json:"bytesPerDay,omitempty"`
+	BytesPerWeek int64 `json:"bytesPerWeek,omitempty"`
+}
+
+// GroupActionResult is a API type.
+type GroupActionResult struct {
+	Error     string `json:"error,omitempty"`
+	MonitorID string `json:"monitorId,omitempty"`
+}
+
+// GroupEvents is a API type.
+type GroupEvents struct {
+	Events []RecordingEvent `json:"events,omitempty"`
+	Next   string           `json:"next,omitempty"`
+}
+
+// GroupRecordings is a API type.
+type GroupRecordings struct {
+	Next       string      `json:"next,omitempty"`
+	Recordings []Recording `json:"recordings,omitempty"`
+}
+
+// GstreamerCapabilities is a API type.
+type GstreamerCapabilities struct {
+	Elements []string `json:"elements,omitempty"`
+	Version  string   `json:"version,omitempty"`
+}
+
+// Health is a API type.
+type Health struct {
+	Main        InputHealth `json:"main,omitempty"`
+	Maintenance Maintenance `json:"maintenance,omitempty"`
+	Sub         InputHealth `json:"sub,omitempty"`
+}
+
+// HeatmapCounts is a API type.
+type HeatmapCounts struct {
+	Events     []int64 `json:"events,omitempty"`
+	Recordings []int64 `json:"recordings,omitempty"`
+}
+
+// Info is a API type.
+type Info struct {
+	DisabledMonitors []string `json:"disabledMonitors,omitempty"`
+	Hooks            []string `json:"hooks,omitempty"`
+	Name             string   `json:"name,omitempty"`
+}
+
+// IngestPresignResponse is a API type.
+type IngestPresignResponse struct {
+	Path        string      `json:"path,omitempty"`
+	Token       string      `json:"token,omitempty"`
+	UploadToken UploadToken `json:"uploadToken,omitempty"`
+}
+
+// InputHealth is a API type.
+type InputHealth struct {
+	Backup         bool      `json:"backup,omitempty"`
+	CorruptFrames  int64     `json:"corruptFrames,omitempty"`
+	FPS            float64   `json:"fps,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	LastErrorClass string    `json:"lastErrorClass,omitempty"`
+	LastSegment    time.Time `json:"lastSegment,omitempty"`
+	NextRestart    time.Time `json:"nextRestart,omitempty"`
+	Restarts       int64     `json:"restarts,omitempty"`
+	State          string    `json:"state,omitempty"`
+}
+
+// Job is a API type.
+type Job struct {
+	Created time.Time      `json:"created,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	ID      string         `json:"id,omitempty"`
+	Request Request        `json:"request,omitempty"`
+	Status  string         `json:"status,omitempty"`
+	Summary SummaryRequest `json:"summary,omitempty"`
+}
+
+// Layout is a API type.
+type Layout struct {
+	Columns          int64    `json:"columns,omitempty"`
+	Order            []string `json:"order,omitempty"`
+	RotationInterval int64    `json:"rotationInterval,omitempty"`
+	Rows             int64    `json:"rows,omitempty"`
+	StreamQuality    string   `json:"streamQuality,omitempty"`
+}
+
+// Link is a API type.
+type Link struct {
+	Created     time.Time `json:"created,omitempty"`
+	CreatedBy   string    `json:"createdBy,omitempty"`
+	Expires     time.Time `json:"expires,omitempty"`
+	ID          string    `json:"id,omitempty"`
+	Kind        string    `json:"kind,omitempty"`
+	LastUsed    time.Time `json:"lastUsed,omitempty"`
+	MonitorID   string    `json:"monitorId,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	RecordingID string    `json:"recordingId,omitempty"`
+	Revoked     time.Time `json:"revoked,omitempty"`
+	RevokedBy   string    `json:"revokedBy,omitempty"`
+	Uses        int64     `json:"uses,omitempty"`
+}
+
+// ListPage is a API type.
+type ListPage struct {
+	Items []map[string]json.RawMessage `json:"items,omitempty"`
+	Next  string                       `json:"next,omitempty"`
+	Total int64                        `json:"total,omitempty"`
+}
+
+// LiveEvent is a API type.
+type LiveEvent struct {
+	Detections []Detection `json:"detections,omitempty"`
+	Duration   int64       `json:"duration,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	MonitorID  string      `json:"monitorId,omitempty"`
+	Rule       string      `json:"rule,omitempty"`
+	Scale      int64       `json:"scale,omitempty"`
+	Time       time.Time   `json:"time,omitempty"`
+}
+
+// LiveEventMessage is a API type.
+type LiveEventMessage struct {
+	Cursor     int64       `json:"cursor,omitempty"`
+	Detections []Detection `json:"detections,omitempty"`
+	Duration   int64       `json:"duration,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	MonitorID  string      `json:"monitorId,omitempty"`
+	Rule       string      `json:"rule,omitempty"`
+	Scale      int64       `json:"scale,omitempty"`
+	Time       time.Time   `json:"time,omitempty"`
+}
+
+// LiveSession is a API type.
+type LiveSession struct {
+	Bytes    int64     `json:"bytes,omitempty"`
+	ID       string    `json:"id,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+	Stream   string    `json:"stream,omitempty"`
+	Type     string    `json:"type,omitempty"`
+	UserID   string    `json:"userId,omitempty"`
+	Username string    `json:"username,omitempty"`
+}
+
+// Lockout is a API type.
+type Lockout struct {
+	Failures int64     `json:"failures,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Type     string    `json:"type,omitempty"`
+	Until    time.Time `json:"until,omitempty"`
+}
+
+// LogFeedMessage is a API type.
+type LogFeedMessage struct {
+	Cursor    int64  `json:"cursor,omitempty"`
+	Level     int64  `json:"level,omitempty"`
+	MonitorID string `json:"monitorID,omitempty"`
+	Msg       string `json:"msg,omitempty"`
+	Src       string `json:"src,omitempty"`
+	Time      int64  `json:"time,omitempty"`
+	Token     string `json:"token,omitempty"`
+}
+
+// Maintenance is a API type.
+type Maintenance struct {
+	Reason string    `json:"reason,omitempty"`
+	Start  time.Time `json:"start,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+}
+
+// MaintenanceInfo is a API type.
+type MaintenanceInfo struct {
+	Active  Maintenance         `json:"active,omitempty"`
+	History []MaintenancePeriod `json:"history,omitempty"`
+}
+
+// MaintenancePeriod is a API type.
+type MaintenancePeriod struct {
+	End       time.Time `json:"end,omitempty"`
+	MonitorID string    `json:"monitorId,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Start     time.Time `json:"start,omitempty"`
+}
+
+// MemoryBudgetStats is a API type.
+type MemoryBudgetStats struct {
+	Limit     int64 `json:"limit,omitempty"`
+	PathLimit int64 `json:"pathLimit,omitempty"`
+	Used      int64 `json:"used,omitempty"`
+}
+
+// MemoryStats is a API type.
+type MemoryStats struct {
+	Bytes     int64 `json:"bytes,omitempty"`
+	Evictions int64 `json:"evictions,omitempty"`
+	Segments  int64 `json:"segments,omitempty"`
+}
+
+// MonitorTemplate is a API type.
+type MonitorTemplate struct {
+	Config    map[string]string `json:"config,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Variables []string          `json:"variables,omitempty"`
+}
+
+// MotionConfig is a API type.
+type MotionConfig struct {
+	Cells       []string `json:"cells,omitempty"`
+	Columns     int64    `json:"columns,omitempty"`
+	Rows        int64    `json:"rows,omitempty"`
+	Sensitivity int64    `json:"sensitivity,omitempty"`
+}
+
+// PathStats is a API type.
+type PathStats struct {
+	HLSClients  int64       `json:"hlsClients,omitempty"`
+	HLSMemory   MemoryStats `json:"hlsMemory,omitempty"`
+	IsSub       bool        `json:"isSub,omitempty"`
+	MonitorID   string      `json:"monitorID,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Ready       bool        `json:"ready,omitempty"`
+	RTSPReaders int64       `json:"rtspReaders,omitempty"`
+}
+
+// PresignRequest is a API type.
+type PresignRequest struct {
+	Duration     int64  `json:"duration,omitempty"`
+	MaxSize      int64  `json:"maxSize,omitempty"`
+	MaxTotalSize int64  `json:"maxTotalSize,omitempty"`
+	MonitorID    string `json:"monitorId,omitempty"`
+}
+
+// PrivacyMask is a API type.
+type PrivacyMask struct {
+	Time     time.Time     `json:"time,omitempty"`
+	Username string        `json:"username,omitempty"`
+	Version  int64         `json:"version,omitempty"`
+	Zones    []PrivacyZone `json:"zones,omitempty"`
+}
+
+// PrivacyMaskRequest is a API type.
+type PrivacyMaskRequest struct {
+	Zones []PrivacyZone `json:"zones,omitempty"`
+}
+
+// PrivacyZone is a API type.
+type PrivacyZone struct {
+	Points [][]float64 `json:"points,omitempty"`
+}
+
+// Profile is a API type.
+type Profile struct {
+	Bitrate int64  `json:"bitrate,omitempty"`
+	Codec   string `json:"codec,omitempty"`
+	Height  int64  `json:"height,omitempty"`
+	HWAccel string `json:"hwaccel,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Width   int64  `json:"width,omitempty"`
+}
+
+// PublicStatus is a API type.
+type PublicStatus struct {
+	Monitors  PublicStatusMonitors `json:"monitors,omitempty"`
+	Resources map[string]Resources `json:"resources,omitempty"`
+	Storage   string               `json:"storage,omitempty"`
+	System    string               `json:"system,omitempty"`
+}
+
+// PublicStatusMonitors is a API type.
+type PublicStatusMonitors struct {
+	Healthy int64 `json:"healthy,omitempty"`
+	Total   int64 `json:"total,omitempty"`
+}
+
+// Recording is a API type.
+type Recording struct {
+	Data      RecordingData `json:"data,omitempty"`
+	ID        string        `json:"id,omitempty"`
+	Protected bool          `json:"protected,omitempty"`
+	Status    string        `json:"status,omitempty"`
+}
+
+// RecordingData is a API type.
+type RecordingData struct {
+	Activity          [][]int64 `json:"activity,omitempty"`
+	Duration          int64     `json:"duration,omitempty"`
+	End               time.Time `json:"end,omitempty"`
+	Events            []Event   `json:"events,omitempty"`
+	Height            int64     `json:"height,omitempty"`
+	Labels            []string  `json:"labels,omitempty"`
+	Maintenance       bool      `json:"maintenance,omitempty"`
+	MaintenanceReason string    `json:"maintenanceReason,omitempty"`
+	Recovered         bool      `json:"recovered,omitempty"`
+	Start             time.Time `json:"start,omitempty"`
+	Triggers          []string  `json:"triggers,omitempty"`
+	Version           int64     `json:"version,omitempty"`
+	Width             int64     `json:"width,omitempty"`
+	Zones             []string  `json:"zones,omitempty"`
+}
+
+// RecordingEvent is a API type.
+type RecordingEvent struct {
+	Detections  []Detection `json:"detections,omitempty"`
+	Duration    int64       `json:"duration,omitempty"`
+	MonitorID   string      `json:"monitorId,omitempty"`
+	RecordingID string      `json:"recordingId,omitempty"`
+	Time        time.Time   `json:"time,omitempty"`
+	Trigger     string      `json:"trigger,omitempty"`
+}
+
+// Region is a API type.
+type Region struct {
+	Polygon [][]int64 `json:"polygon,omitempty"`
+	Rect    []int64   `json:"rect,omitempty"`
+}
+
+// Release is a API type.
+type Release struct {
+	Changelog string    `json:"changelog,omitempty"`
+	Published time.Time `json:"published,omitempty"`
+	SHA256    string    `json:"sha256,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Version   string    `json:"version,omitempty"`
+}
+
+// Rendition is a API type.
+type Rendition struct {
+	Bitrate int64   `json:"bitrate,omitempty"`
+	Codecs  string  `json:"codecs,omitempty"`
+	FPS     float64 `json:"fps,omitempty"`
+	Height  int64   `json:"height,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	Path    string  `json:"path,omitempty"`
+	Ready   bool    `json:"ready,omitempty"`
+	Width   int64   `json:"width,omitempty"`
+}
+
+// Request is a API type.
+type Request struct {
+	End      time.Time `json:"end,omitempty"`
+	Layout   string    `json:"layout,omitempty"`
+	Monitors []string  `json:"monitors,omitempty"`
+	Profile  string    `json:"profile,omitempty"`
+	Start    time.Time `json:"start,omitempty"`
+}
+
+// ResetPasswordRequest is a API type.
+type ResetPasswordRequest struct {
+	ID            string `json:"id,omitempty"`
+	PlainPassword string `json:"plainPassword,omitempty"`
+}
+
+// Resources is a API type.
+type Resources struct {
+	CPU float64 `json:"cpu,omitempty"`
+	RSS int64   `json:"rss,omitempty"`
+}
+
+// Result is a API type.
+type Result struct {
+	Latency    int64     `json:"latency,omitempty"`
+	Throughput int64     `json:"throughput,omitempty"`
+	Time       time.Time `json:"time,omitempty"`
+}
+
+// RollbackInfo is a API type.
+type RollbackInfo struct {
+	PreviousVersion string    `json:"previousVersion,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	Time            time.Time `json:"time,omitempty"`
+	Version         string    `json:"version,omitempty"`
+}
+
+// ScrubReport is a API type.
+type ScrubReport struct {
+	Finished time.Time `json:"finished,omitempty"`
+	Freed    int64     `json:"freed,omitempty"`
+	Removed  []string  `json:"removed,omitempty"`
+	Running  bool      `json:"running,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+}
+
+// SetRequest is a API type.
+type SetRequest struct {
+	Enable    bool   `json:"enable,omitempty"`
+	MonitorID string `json:"monitorID,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// SetUserRequest is a API type.
+type SetUserRequest struct {
+	ID                 string `json:"id,omitempty"`
+	IsAdmin            bool   `json:"isAdmin,omitempty"`
+	MustChangePassword bool   `json:"mustChangePassword,omitempty"`
+	PlainPassword      string `json:"plainPassword,omitempty"`
+	Talkback           bool   `json:"talkback,omitempty"`
+	Username           string `json:"username,omitempty"`
+}
+
+// ShareCreateResponse is a API type.
+type ShareCreateResponse struct {
+	Link  Link   `json:"link,omitempty"`
+	Path  string `json:"path,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// SourceLevel is a API type.
+type SourceLevel struct {
+	Level  string `json:"level,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+// Stats is a API type.
+type Stats struct {
+	Main StreamStats `json:"main,omitempty"`
+	Sub  StreamStats `json:"sub,omitempty"`
+}
+
+// StreamRecommendation is a API type.
+type StreamRecommendation struct {
+	MainBitrate int64  `json:"mainBitrate,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Result      Result `json:"result,omitempty"`
+	Sub         bool   `json:"sub,omitempty"`
+	SubBitrate  int64  `json:"subBitrate,omitempty"`
+}
+
+// StreamSample is a API type.
+type StreamSample struct {
+	Bitrate int64     `json:"bitrate,omitempty"`
+	FPS     float64   `json:"fps,omitempty"`
+	Height  int64     `json:"height,omitempty"`
+	Ready   bool      `json:"ready,omitempty"`
+	Time    time.Time `json:"time,omitempty"`
+	Width   int64     `json:"width,omitempty"`
+}
+
+// StreamSession is a API type.
+type StreamSession struct {
+	Bytes     int64     `json:"bytes,omitempty"`
+	Duration  int64     `json:"duration,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	MonitorID string    `json:"monitorId,omitempty"`
+	Started   time.Time `json:"started,omitempty"`
+	Stream    string    `json:"stream,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	UserID    string    `json:"userId,omitempty"`
+	Username  string    `json:"username,omitempty"`
+}
+
+// StreamStats is a API type.
+type StreamStats struct {
+	AvgBitrate int64          `json:"avgBitrate,omitempty"`
+	Drops      int64          `json:"drops,omitempty"`
+	MaxBitrate int64          `json:"maxBitrate,omitempty"`
+	MinBitrate int64          `json:"minBitrate,omitempty"`
+	Samples    []StreamSample `json:"samples,omitempty"`
+}
+
+// StreamTestAudio is a API type.
+type StreamTestAudio struct {
+	Channels   string `json:"channels,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+	SampleRate int64  `json:"sampleRate,omitempty"`
+}
+
+// StreamTestRequest is a API type.
+type StreamTestRequest struct {
+	Credential string `json:"credential,omitempty"`
+	Password   string `json:"password,omitempty"`
+	Protocol   string `json:"protocol,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Username   string `json:"username,omitempty"`
+}
+
+// StreamTestResult is a API type.
+type StreamTestResult struct {
+	Audio    StreamTestAudio `json:"audio,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Message  string          `json:"message,omitempty"`
+	Video    StreamTestVideo `json:"video,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// StreamTestVideo is a API type.
+type StreamTestVideo struct {
+	Codec  string  `json:"codec,omitempty"`
+	FPS    float64 `json:"fps,omitempty"`
+	Height int64   `json:"height,omitempty"`
+	Width  int64   `json:"width,omitempty"`
+}
+
+// SummaryRequest is a API type.
+type SummaryRequest struct {
+	Duration int64     `json:"duration,omitempty"`
+	End      time.Time `json:"end,omitempty"`
+	Mode     string    `json:"mode,omitempty"`
+	Monitor  string    `json:"monitor,omitempty"`
+	Profile  string    `json:"profile,omitempty"`
+	Start    time.Time `json:"start,omitempty"`
+}
+
+// Template is a API type.
+type Template struct {
+	Config map[string]string `json:"config,omitempty"`
+	Name   string            `json:"name,omitempty"`
+}
+
+// Transcoders is a API type.
+type Transcoders struct {
+	FFmpeg    Capabilities          `json:"ffmpeg,omitempty"`
+	GStreamer GstreamerCapabilities `json:"gstreamer,omitempty"`
+}
+
+// UploadToken is a API type.
+type UploadToken struct {
+	BytesUsed    int64     `json:"bytesUsed,omitempty"`
+	Created      time.Time `json:"created,omitempty"`
+	CreatedBy    string    `json:"createdBy,omitempty"`
+	Expires      time.Time `json:"expires,omitempty"`
+	ID           string    `json:"id,omitempty"`
+	LastUsed     time.Time `json:"lastUsed,omitempty"`
+	MaxSize      int64     `json:"maxSize,omitempty"`
+	MaxTotalSize int64     `json:"maxTotalSize,omitempty"`
+	MonitorID    string    `json:"monitorId,omitempty"`
+	Revoked      time.Time `json:"revoked,omitempty"`
+	RevokedBy    string    `json:"revokedBy,omitempty"`
+	Uses         int64     `json:"uses,omitempty"`
+}
+
+// UserPreferences is a API type.
+type UserPreferences struct {
+	DefaultGroup string            `json:"defaultGroup,omitempty"`
+	GridSize     int64             `json:"gridSize,omitempty"`
+	Shortcuts    map[string]string `json:"shortcuts,omitempty"`
+	Theme        string            `json:"theme,omitempty"`
+	TimeZone     string            `json:"timeZone,omitempty"`
+}
+
+// VerifyReport is a API type.
+type VerifyReport struct {
+	Checked  int64     `json:"checked,omitempty"`
+	Corrupt  []string  `json:"corrupt,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+	Repaired []string  `json:"repaired,omitempty"`
+	Running  bool      `json:"running,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+}
+
+// VersionInfo is a API type.
+type VersionInfo struct {
+	CheckError      string       `json:"checkError,omitempty"`
+	Current         string       `json:"current,omitempty"`
+	LastRollback    RollbackInfo `json:"lastRollback,omitempty"`
+	Latest          Release      `json:"latest,omitempty"`
+	Pending         bool         `json:"pending,omitempty"`
+	Trial           bool         `json:"trial,omitempty"`
+	UpdateAvailable bool         `json:"updateAvailable,omitempty"`
+	UpdatesEnabled  bool         `json:"updatesEnabled,omitempty"`
+}
+
+// VideoFragment is a API type.
+type VideoFragment struct {
+	Offset int64   `json:"offset,omitempty"`
+	Size   int64   `json:"size,omitempty"`
+	Start  float64 `json:"start,omitempty"`
+}
+
+// VideoIndex is a API type.
+type VideoIndex struct {
+	Duration  float64         `json:"duration,omitempty"`
+	Fragments []VideoFragment `json:"fragments,omitempty"`
+	Keyframes []float64       `json:"keyframes,omitempty"`
+	Size      int64           `json:"size,omitempty"`
+	Start     time.Time       `json:"start,omitempty"`
+}
+
+// Zone is a API type.
+type Zone struct {
+	Name   string      `json:"name,omitempty"`
+	Points [][]float64 `json:"points,omitempty"`
+}
+
+// AddonSet sends PUT /api/addons/set.
+// Enable or disable a addon for a monitor.
+func (c *Client) AddonSet(ctx context.Context, body SetRequest) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/addons/set", query, body, nil)
+}
+
+// Addons sends GET /api/addons.
+// Addons, their hooks and the monitors where they are disabled.
+func (c *Client) Addons(ctx context.Context) ([]Info, error) {
+	query := url.Values{}
+	var res []Info
+	err := c.doJSON(ctx, "GET", "/api/addons", query, nil, &res)
+	return res, err
+}
+
+// EventClipParams are the parameters of EventClip.
+type EventClipParams struct {
+	// Event ID.
+	ID string
+}
+
+// EventClip sends GET /api/events/{id}/clip.
+// Video clip of a event.
+func (c *Client) EventClip(ctx context.Context, params EventClipParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/events/"+url.PathEscape(params.ID)+"/clip", query, nil, "")
+}
+
+// EventSnapshotParams are the parameters of EventSnapshot.
+type EventSnapshotParams struct {
+	// Event ID.
+	ID string
+}
+
+// EventSnapshot sends GET /api/events/{id}/snapshot.
+// Full resolution image of a detection event.
+func (c *Client) EventSnapshot(ctx context.Context, params EventSnapshotParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/events/"+url.PathEscape(params.ID)+"/snapshot", query, nil, "")
+}
+
+// EventSnapshotsParams are the parameters of EventSnapshots.
+type EventSnapshotsParams struct {
+	// Maximum number of snapshots.
+	Limit int
+	// Start after this event ID or time, "2006-01-02_15-04-05".
+	Time string
+	// Oldest first.
+	Reverse bool
+	// Only events with a recognized license plate that contains this.
+	Plate string
+	// Comma separated list of monitor IDs.
+	Monitors string
+}
+
+// EventSnapshots sends GET /api/events/snapshots.
+// Event snapshot gallery, newest first.
+func (c *Client) EventSnapshots(ctx context.Context, params EventSnapshotsParams) ([]EventSnapshot, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Plate != "" {
+		query.Set("plate", params.Plate)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	var res []EventSnapshot
+	err := c.doJSON(ctx, "GET", "/api/events/snapshots", query, nil, &res)
+	return res, err
+}
+
+// EventsFeedPollParams are the parameters of EventsFeedPoll.
+type EventsFeedPollParams struct {
+	// Comma separated list of "monitor", "event", "storage" and "log".
+	Types string
+	// Comma separated list of monitor IDs.
+	Monitors string
+	// Resume the feed after this message.
+	Cursor int
+	// Seconds to wait for new messages.
+	Timeout int
+}
+
+// EventsFeedPoll sends GET /api/events/feed/poll.
+// Long polling fallback of the events feed websocket.
+func (c *Client) EventsFeedPoll(ctx context.Context, params EventsFeedPollParams) (struct {
+	Cursor int64               `json:"cursor,omitempty"`
+	Items  []EventsFeedMessage `json:"items,omitempty"`
+}, error) {
+	query := url.Values{}
+	if params.Types != "" {
+		query.Set("types", params.Types)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Cursor != 0 {
+		query.Set("cursor", strconv.Itoa(params.Cursor))
+	}
+	if params.Timeout != 0 {
+		query.Set("timeout", strconv.Itoa(params.Timeout))
+	}
+	var res struct {
+		Cursor int64               `json:"cursor,omitempty"`
+		Items  []EventsFeedMessage `json:"items,omitempty"`
+	}
+	err := c.doJSON(ctx, "GET", "/api/events/feed/poll", query, nil, &res)
+	return res, err
+}
+
+// General sends GET /api/general.
+// General config.
+func (c *Client) General(ctx context.Context) (map[string]string, error) {
+	query := url.Values{}
+	var res map[string]string
+	err := c.doJSON(ctx, "GET", "/api/general", query, nil, &res)
+	return res, err
+}
+
+// GeneralSet sends PUT /api/general/set.
+// Set the general config.
+func (c *Client) GeneralSet(ctx context.Context, body map[string]string) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/general/set", query, body, nil)
+}
+
+// GroupActionParams are the parameters of GroupAction.
+type GroupActionParams struct {
+	// Monitor ID.
+	ID string
+	// "start", "stop", "restart", "arm", "disarm" or "auto" to follow the arm schedule.
+	Action string
+	// Duration in minutes. Only used by arm actions. Zero until changed.
+	Duration int
+}
+
+// GroupAction sends POST /api/group/action.
+// Start, stop, restart, arm or disarm every monitor in a group.
+func (c *Client) GroupAction(ctx context.Context, params GroupActionParams) ([]GroupActionResult, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("action", params.Action)
+	if params.Duration != 0 {
+		query.Set("duration", strconv.Itoa(params.Duration))
+	}
+	var res []GroupActionResult
+	err := c.doJSON(ctx, "POST", "/api/group/action", query, nil, &res)
+	return res, err
+}
+
+// GroupConfigs sends GET /api/group/configs.
+// Group configs by ID.
+func (c *Client) GroupConfigs(ctx context.Context) (map[string]map[string]string, error) {
+	query := url.Values{}
+	var res map[string]map[string]string
+	err := c.doJSON(ctx, "GET", "/api/group/configs", query, nil, &res)
+	return res, err
+}
+
+// GroupDeleteParams are the parameters of GroupDelete.
+type GroupDeleteParams struct {
+	// Group ID.
+	ID string
+}
+
+// GroupDelete sends DELETE /api/group/delete.
+// Delete a group.
+func (c *Client) GroupDelete(ctx context.Context, params GroupDeleteParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/group/delete", query, nil, nil)
+}
+
+// GroupEventsParams are the parameters of GroupEvents.
+type GroupEventsParams struct {
+	// Group ID.
+	ID string
+	// Maximum number of recordings.
+	Limit int
+	// Start after this recording ID or time, "2006-01-02_15-04-05".
+	Time string
+	// Oldest first.
+	Reverse bool
+	// Include the recording data.
+	Data bool
+	// Comma separated triggers, "continuous", "motion" or "object".
+	Triggers string
+	// Comma separated detection labels.
+	Labels string
+	// Comma separated detection zones.
+	Zones string
+	// Minimum duration in seconds.
+	MinDuration int
+}
+
+// GroupEvents sends GET /api/group/{id}/events.
+// Events of the recordings of the monitors in a group.
+func (c *Client) GroupEvents(ctx context.Context, params GroupEventsParams) (GroupEvents, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Data {
+		query.Set("data", strconv.FormatBool(params.Data))
+	}
+	if params.Triggers != "" {
+		query.Set("triggers", params.Triggers)
+	}
+	if params.Labels != "" {
+		query.Set("labels", params.Labels)
+	}
+	if params.Zones != "" {
+		query.Set("zones", params.Zones)
+	}
+	if params.MinDuration != 0 {
+		query.Set("minDuration", strconv.Itoa(params.MinDuration))
+	}
+	var res GroupEvents
+	err := c.doJSON(ctx, "GET", "/api/group/"+url.PathEscape(params.ID)+"/events", query, nil, &res)
+	return res, err
+}
+
+// GroupEventsList is GroupEvents with the list parameters.
+func (c *Client) GroupEventsList(ctx context.Context, params GroupEventsParams, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Data {
+		query.Set("data", strconv.FormatBool(params.Data))
+	}
+	if params.Triggers != "" {
+		query.Set("triggers", params.Triggers)
+	}
+	if params.Labels != "" {
+		query.Set("labels", params.Labels)
+	}
+	if params.Zones != "" {
+		query.Set("zones", params.Zones)
+	}
+	if params.MinDuration != 0 {
+		query.Set("minDuration", strconv.Itoa(params.MinDuration))
+	}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/group/"+url.PathEscape(params.ID)+"/events", query, nil, &res)
+	return res, err
+}
+
+// GroupLayoutParams are the parameters of GroupLayout.
+type GroupLayoutParams struct {
+	// Group ID.
+	ID string
+}
+
+// GroupLayout sends GET /api/group/{id}/layout.
+// Live grid layout, tile order, rotation and stream quality of a group.
+func (c *Client) GroupLayout(ctx context.Context, params GroupLayoutParams) (Layout, error) {
+	query := url.Values{}
+	var res Layout
+	err := c.doJSON(ctx, "GET", "/api/group/"+url.PathEscape(params.ID)+"/layout", query, nil, &res)
+	return res, err
+}
+
+// GroupRecordingsParams are the parameters of GroupRecordings.
+type GroupRecordingsParams struct {
+	// Group ID.
+	ID string
+	// Maximum number of recordings.
+	Limit int
+	// Start after this recording ID or time, "2006-01-02_15-04-05".
+	Time string
+	// Oldest first.
+	Reverse bool
+	// Include the recording data.
+	Data bool
+	// Comma separated triggers, "continuous", "motion" or "object".
+	Triggers string
+	// Comma separated detection labels.
+	Labels string
+	// Comma separated detection zones.
+	Zones string
+	// Minimum duration in seconds.
+	MinDuration int
+}
+
+// GroupRecordings sends GET /api/group/{id}/recordings.
+// Recordings of the monitors in a group.
+func (c *Client) GroupRecordings(ctx context.Context, params GroupRecordingsParams) (GroupRecordings, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Data {
+		query.Set("data", strconv.FormatBool(params.Data))
+	}
+	if params.Triggers != "" {
+		query.Set("triggers", params.Triggers)
+	}
+	if params.Labels != "" {
+		query.Set("labels", params.Labels)
+	}
+	if params.Zones != "" {
+		query.Set("zones", params.Zones)
+	}
+	if params.MinDuration != 0 {
+		query.Set("minDuration", strconv.Itoa(params.MinDuration))
+	}
+	var res GroupRecordings
+	err := c.doJSON(ctx, "GET", "/api/group/"+url.PathEscape(params.ID)+"/recordings", query, nil, &res)
+	return res, err
+}
+
+// GroupRecordingsList is GroupRecordings with the list parameters.
+func (c *Client) GroupRecordingsList(ctx context.Context, params GroupRecordingsParams, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Data {
+		query.Set("data", strconv.FormatBool(params.Data))
+	}
+	if params.Triggers != "" {
+		query.Set("triggers", params.Triggers)
+	}
+	if params.Labels != "" {
+		query.Set("labels", params.Labels)
+	}
+	if params.Zones != "" {
+		query.Set("zones", params.Zones)
+	}
+	if params.MinDuration != 0 {
+		query.Set("minDuration", strconv.Itoa(params.MinDuration))
+	}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/group/"+url.PathEscape(params.ID)+"/recordings", query, nil, &res)
+	return res, err
+}
+
+// GroupSet sends PUT /api/group/set.
+// Create or update a group.
+func (c *Client) GroupSet(ctx context.Context, body map[string]string) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/group/set", query, body, nil)
+}
+
+// IngestPresign sends POST /api/ingest/presign.
+// Issue a token that allows a edge device to upload recordings of a monitor without login.
+func (c *Client) IngestPresign(ctx context.Context, body PresignRequest) (IngestPresignResponse, error) {
+	query := url.Values{}
+	var res IngestPresignResponse
+	err := c.doJSON(ctx, "POST", "/api/ingest/presign", query, body, &res)
+	return res, err
+}
+
+// IngestRevokeParams are the parameters of IngestRevoke.
+type IngestRevokeParams struct {
+	// Upload token ID.
+	ID string
+}
+
+// IngestRevoke sends DELETE /api/ingest/revoke.
+// Revoke a upload token.
+func (c *Client) IngestRevoke(ctx context.Context, params IngestRevokeParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/ingest/revoke", query, nil, nil)
+}
+
+// IngestTokens sends GET /api/ingest/tokens.
+// Issued upload tokens newest first, including expired and revoked tokens.
+func (c *Client) IngestTokens(ctx context.Context) ([]UploadToken, error) {
+	query := url.Values{}
+	var res []UploadToken
+	err := c.doJSON(ctx, "GET", "/api/ingest/tokens", query, nil, &res)
+	return res, err
+}
+
+// LogFeedPollParams are the parameters of LogFeedPoll.
+type LogFeedPollParams struct {
+	// Comma separated list of levels, 16=error 24=warning 32=info 48=debug.
+	Levels string
+	// Comma separated list of sources.
+	Sources string
+	// Comma separated list of monitor IDs.
+	Monitors string
+	// Resume the feed after this message.
+	Cursor int
+	// Seconds to wait for new messages.
+	Timeout int
+}
+
+// LogFeedPoll sends GET /api/log/feed/poll.
+// Long polling fallback of the log feed websocket.
+func (c *Client) LogFeedPoll(ctx context.Context, params LogFeedPollParams) (struct {
+	Cursor int64            `json:"cursor,omitempty"`
+	Items  []LogFeedMessage `json:"items,omitempty"`
+}, error) {
+	query := url.Values{}
+	if params.Levels != "" {
+		query.Set("levels", params.Levels)
+	}
+	if params.Sources != "" {
+		query.Set("sources", params.Sources)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Cursor != 0 {
+		query.Set("cursor", strconv.Itoa(params.Cursor))
+	}
+	if params.Timeout != 0 {
+		query.Set("timeout", strconv.Itoa(params.Timeout))
+	}
+	var res struct {
+		Cursor int64            `json:"cursor,omitempty"`
+		Items  []LogFeedMessage `json:"items,omitempty"`
+	}
+	err := c.doJSON(ctx, "GET", "/api/log/feed/poll", query, nil, &res)
+	return res, err
+}
+
+// LogLevelSetParams are the parameters of LogLevelSet.
+type LogLevelSetParams struct {
+	// Log source.
+	Source string
+	// "error", "warning", "info" or "debug".
+	Level string
+}
+
+// LogLevelSet sends POST /api/log/level.
+// Set the most verbose level that is logged for a source.
+func (c *Client) LogLevelSet(ctx context.Context, params LogLevelSetParams) error {
+	query := url.Values{}
+	query.Set("source", params.Source)
+	query.Set("level", params.Level)
+	return c.doJSON(ctx, "POST", "/api/log/level", query, nil, nil)
+}
+
+// LogQueryParams are the parameters of LogQuery.
+type LogQueryParams struct {
+	// Maximum number of entries.
+	Limit int
+	// Comma separated list of levels, 16=error 24=warning 32=info 48=debug.
+	Levels string
+	// Comma separated list of sources.
+	Sources string
+	// Comma separated list of monitor IDs.
+	Monitors string
+	// Only entries before this UNIX time in microseconds.
+	Time int
+	// Stream all the matching entries as "ndjson" or "csv", the limit is ignored.
+	Format string
+}
+
+// LogQuery sends GET /api/log/query.
+// Stored logs, newest first.
+func (c *Client) LogQuery(ctx context.Context, params LogQueryParams) ([]Entry, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Levels != "" {
+		query.Set("levels", params.Levels)
+	}
+	if params.Sources != "" {
+		query.Set("sources", params.Sources)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Time != 0 {
+		query.Set("time", strconv.Itoa(params.Time))
+	}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	var res []Entry
+	err := c.doJSON(ctx, "GET", "/api/log/query", query, nil, &res)
+	return res, err
+}
+
+// LogQueryList is LogQuery with the list parameters.
+func (c *Client) LogQueryList(ctx context.Context, params LogQueryParams, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Levels != "" {
+		query.Set("levels", params.Levels)
+	}
+	if params.Sources != "" {
+		query.Set("sources", params.Sources)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Time != 0 {
+		query.Set("time", strconv.Itoa(params.Time))
+	}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/log/query", query, nil, &res)
+	return res, err
+}
+
+// LogSearchParams are the parameters of LogSearch.
+type LogSearchParams struct {
+	// Maximum number of entries.
+	Limit int
+	// Comma separated list of levels, 16=error 24=warning 32=info 48=debug.
+	Levels string
+	// Comma separated list of sources.
+	Sources string
+	// Comma separated list of monitor IDs.
+	Monitors string
+	// Only entries before this UNIX time in microseconds.
+	Time int
+	// Substring of the message.
+	Text string
+	// Regular expression that matches the message.
+	Regex string
+}
+
+// LogSearch sends GET /api/log/search.
+// Stored logs that contain the text or match the regex, newest first.
+func (c *Client) LogSearch(ctx context.Context, params LogSearchParams) ([]Entry, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Levels != "" {
+		query.Set("levels", params.Levels)
+	}
+	if params.Sources != "" {
+		query.Set("sources", params.Sources)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Time != 0 {
+		query.Set("time", strconv.Itoa(params.Time))
+	}
+	if params.Text != "" {
+		query.Set("text", params.Text)
+	}
+	if params.Regex != "" {
+		query.Set("regex", params.Regex)
+	}
+	var res []Entry
+	err := c.doJSON(ctx, "GET", "/api/log/search", query, nil, &res)
+	return res, err
+}
+
+// LogSearchList is LogSearch with the list parameters.
+func (c *Client) LogSearchList(ctx context.Context, params LogSearchParams, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Levels != "" {
+		query.Set("levels", params.Levels)
+	}
+	if params.Sources != "" {
+		query.Set("sources", params.Sources)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Time != 0 {
+		query.Set("time", strconv.Itoa(params.Time))
+	}
+	if params.Text != "" {
+		query.Set("text", params.Text)
+	}
+	if params.Regex != "" {
+		query.Set("regex", params.Regex)
+	}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/log/search", query, nil, &res)
+	return res, err
+}
+
+// LogSources sends GET /api/log/sources.
+// Log sources and their levels.
+func (c *Client) LogSources(ctx context.Context) ([]SourceLevel, error) {
+	query := url.Values{}
+	var res []SourceLevel
+	err := c.doJSON(ctx, "GET", "/api/log/sources", query, nil, &res)
+	return res, err
+}
+
+// MonitorArmParams are the parameters of MonitorArm.
+type MonitorArmParams struct {
+	// Monitor ID.
+	ID string
+	// "arm", "disarm" or "auto" to follow the schedule.
+	State string
+	// Duration in minutes. Zero until changed.
+	Duration int
+}
+
+// MonitorArm sends POST /api/monitor/arm.
+// Override the arm schedule of a monitor.
+func (c *Client) MonitorArm(ctx context.Context, params MonitorArmParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("state", params.State)
+	if params.Duration != 0 {
+		query.Set("duration", strconv.Itoa(params.Duration))
+	}
+	return c.doJSON(ctx, "POST", "/api/monitor/arm", query, nil, nil)
+}
+
+// MonitorArmStateParams are the parameters of MonitorArmState.
+type MonitorArmStateParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorArmState sends GET /api/monitor/arm-state.
+// Arm state of a monitor.
+func (c *Client) MonitorArmState(ctx context.Context, params MonitorArmStateParams) (ArmState, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res ArmState
+	err := c.doJSON(ctx, "GET", "/api/monitor/arm-state", query, nil, &res)
+	return res, err
+}
+
+// MonitorCameraMotionParams are the parameters of MonitorCameraMotion.
+type MonitorCameraMotionParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorCameraMotion sends GET /api/monitor/camera-motion.
+// Motion detection config of the camera.
+func (c *Client) MonitorCameraMotion(ctx context.Context, params MonitorCameraMotionParams) (MotionConfig, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res MotionConfig
+	err := c.doJSON(ctx, "GET", "/api/monitor/camera-motion", query, nil, &res)
+	return res, err
+}
+
+// MonitorCameraMotionSetParams are the parameters of MonitorCameraMotionSet.
+type MonitorCameraMotionSetParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorCameraMotionSet sends PUT /api/monitor/camera-motion/set.
+// Set the motion detection config of the camera.
+func (c *Client) MonitorCameraMotionSet(ctx context.Context, params MonitorCameraMotionSetParams, body MotionConfig) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "PUT", "/api/monitor/camera-motion/set", query, body, nil)
+}
+
+// MonitorCloneParams are the parameters of MonitorClone.
+type MonitorCloneParams struct {
+	// ID of the monitor to clone.
+	From string
+	// ID of the new monitor.
+	ID string
+	// Name of the new monitor.
+	Name string
+}
+
+// MonitorClone sends POST /api/monitor/clone.
+// Create a monitor from the config of another.
+func (c *Client) MonitorClone(ctx context.Context, params MonitorCloneParams) error {
+	query := url.Values{}
+	query.Set("from", params.From)
+	query.Set("id", params.ID)
+	query.Set("name", params.Name)
+	return c.doJSON(ctx, "POST", "/api/monitor/clone", query, nil, nil)
+}
+
+// MonitorConfigs sends GET /api/monitor/configs.
+// Monitor configs by ID, the passwords are redacted.
+func (c *Client) MonitorConfigs(ctx context.Context) (map[string]map[string]string, error) {
+	query := url.Values{}
+	var res map[string]map[string]string
+	err := c.doJSON(ctx, "GET", "/api/monitor/configs", query, nil, &res)
+	return res, err
+}
+
+// MonitorCredentialDeleteParams are the parameters of MonitorCredentialDelete.
+type MonitorCredentialDeleteParams struct {
+	// Credential ID.
+	ID string
+}
+
+// MonitorCredentialDelete sends DELETE /api/monitor/credential/delete.
+// Delete a camera credential that isn't used by any monitor.
+func (c *Client) MonitorCredentialDelete(ctx context.Context, params MonitorCredentialDeleteParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/monitor/credential/delete", query, nil, nil)
+}
+
+// MonitorCredentialSet sends PUT /api/monitor/credential/set.
+// Create or update a camera credential, a empty password keeps the current password.
+func (c *Client) MonitorCredentialSet(ctx context.Context, body Credential) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/monitor/credential/set", query, body, nil)
+}
+
+// MonitorCredentials sends GET /api/monitor/credentials.
+// Camera credentials without the passwords.
+func (c *Client) MonitorCredentials(ctx context.Context) ([]Credential, error) {
+	query := url.Values{}
+	var res []Credential
+	err := c.doJSON(ctx, "GET", "/api/monitor/credentials", query, nil, &res)
+	return res, err
+}
+
+// MonitorDeleteParams are the parameters of MonitorDelete.
+type MonitorDeleteParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorDelete sends DELETE /api/monitor/delete.
+// Delete a monitor.
+func (c *Client) MonitorDelete(ctx context.Context, params MonitorDeleteParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/monitor/delete", query, nil, nil)
+}
+
+// MonitorEnableParams are the parameters of MonitorEnable.
+type MonitorEnableParams struct {
+	// Monitor ID.
+	ID     string
+	Enable bool
+}
+
+// MonitorEnable sends POST /api/monitor/enable.
+// Enable or disable a monitor.
+func (c *Client) MonitorEnable(ctx context.Context, params MonitorEnableParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("enable", strconv.FormatBool(params.Enable))
+	return c.doJSON(ctx, "POST", "/api/monitor/enable", query, nil, nil)
+}
+
+// MonitorEventsPollParams are the parameters of MonitorEventsPoll.
+type MonitorEventsPollParams struct {
+	// Comma separated list of monitor IDs.
+	Monitors string
+	// Resume the feed after this message.
+	Cursor int
+	// Seconds to wait for new messages.
+	Timeout int
+}
+
+// MonitorEventsPoll sends GET /api/monitor/events/poll.
+// Long polling fallback of the monitor events websocket.
+func (c *Client) MonitorEventsPoll(ctx context.Context, params MonitorEventsPollParams) (struct {
+	Cursor int64              `json:"cursor,omitempty"`
+	Items  []LiveEventMessage `json:"items,omitempty"`
+}, error) {
+	query := url.Values{}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	if params.Cursor != 0 {
+		query.Set("cursor", strconv.Itoa(params.Cursor))
+	}
+	if params.Timeout != 0 {
+		query.Set("timeout", strconv.Itoa(params.Timeout))
+	}
+	var res struct {
+		Cursor int64              `json:"cursor,omitempty"`
+		Items  []LiveEventMessage `json:"items,omitempty"`
+	}
+	err := c.doJSON(ctx, "GET", "/api/monitor/events/poll", query, nil, &res)
+	return res, err
+}
+
+// MonitorHealth sends GET /api/monitor/health.
+// Health of the running monitors by ID.
+func (c *Client) MonitorHealth(ctx context.Context) (map[string]Health, error) {
+	query := url.Values{}
+	var res map[string]Health
+	err := c.doJSON(ctx, "GET", "/api/monitor/health", query, nil, &res)
+	return res, err
+}
+
+// MonitorListParams are the parameters of MonitorList.
+type MonitorListParams struct {
+	// Comma separated list of tags, only monitors with all of them.
+	Tags string
+}
+
+// MonitorList sends GET /api/monitor/list.
+// Redacted monitor configs by ID.
+func (c *Client) MonitorList(ctx context.Context, params MonitorListParams) (map[string]map[string]string, error) {
+	query := url.Values{}
+	if params.Tags != "" {
+		query.Set("tags", params.Tags)
+	}
+	var res map[string]map[string]string
+	err := c.doJSON(ctx, "GET", "/api/monitor/list", query, nil, &res)
+	return res, err
+}
+
+// MonitorListList is MonitorList with the list parameters.
+func (c *Client) MonitorListList(ctx context.Context, params MonitorListParams, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	if params.Tags != "" {
+		query.Set("tags", params.Tags)
+	}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/monitor/list", query, nil, &res)
+	return res, err
+}
+
+// MonitorMaintenanceParams are the parameters of MonitorMaintenance.
+type MonitorMaintenanceParams struct {
+	// Monitor ID.
+	ID     string
+	Enable bool
+	// Duration in minutes. Zero until stopped.
+	Duration int
+	Reason   string
+}
+
+// MonitorMaintenance sends POST /api/monitor/maintenance.
+// Start or stop maintenance of a monitor.
+func (c *Client) MonitorMaintenance(ctx context.Context, params MonitorMaintenanceParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("enable", strconv.FormatBool(params.Enable))
+	if params.Duration != 0 {
+		query.Set("duration", strconv.Itoa(params.Duration))
+	}
+	if params.Reason != "" {
+		query.Set("reason", params.Reason)
+	}
+	return c.doJSON(ctx, "POST", "/api/monitor/maintenance", query, nil, nil)
+}
+
+// MonitorMaintenanceStateParams are the parameters of MonitorMaintenanceState.
+type MonitorMaintenanceStateParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorMaintenanceState sends GET /api/monitor/maintenance-state.
+// Maintenance state and history of a monitor.
+func (c *Client) MonitorMaintenanceState(ctx context.Context, params MonitorMaintenanceStateParams) (MaintenanceInfo, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res MaintenanceInfo
+	err := c.doJSON(ctx, "GET", "/api/monitor/maintenance-state", query, nil, &res)
+	return res, err
+}
+
+// MonitorPrivacyMaskParams are the parameters of MonitorPrivacyMask.
+type MonitorPrivacyMaskParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorPrivacyMask sends GET /api/monitor/privacy-mask.
+// Privacy mask versions of a monitor, oldest first. The last version is the current mask.
+func (c *Client) MonitorPrivacyMask(ctx context.Context, params MonitorPrivacyMaskParams) ([]PrivacyMask, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res []PrivacyMask
+	err := c.doJSON(ctx, "GET", "/api/monitor/privacy-mask", query, nil, &res)
+	return res, err
+}
+
+// MonitorPrivacyMaskSetParams are the parameters of MonitorPrivacyMaskSet.
+type MonitorPrivacyMaskSetParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorPrivacyMaskSet sends PUT /api/monitor/privacy-mask/set.
+// Save a new version of the privacy mask of a monitor and restart it. Empty zones remove the mask.
+func (c *Client) MonitorPrivacyMaskSet(ctx context.Context, params MonitorPrivacyMaskSetParams, body PrivacyMaskRequest) (PrivacyMask, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res PrivacyMask
+	err := c.doJSON(ctx, "PUT", "/api/monitor/privacy-mask/set", query, body, &res)
+	return res, err
+}
+
+// MonitorRenditionsParams are the parameters of MonitorRenditions.
+type MonitorRenditionsParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorRenditions sends GET /api/monitor/renditions.
+// Live streams of a monitor.
+func (c *Client) MonitorRenditions(ctx context.Context, params MonitorRenditionsParams) ([]Rendition, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res []Rendition
+	err := c.doJSON(ctx, "GET", "/api/monitor/renditions", query, nil, &res)
+	return res, err
+}
+
+// MonitorRestartParams are the parameters of MonitorRestart.
+type MonitorRestartParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorRestart sends POST /api/monitor/restart.
+// Restart a monitor.
+func (c *Client) MonitorRestart(ctx context.Context, params MonitorRestartParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "POST", "/api/monitor/restart", query, nil, nil)
+}
+
+// MonitorSet sends PUT /api/monitor/set.
+// Create or update a monitor.
+func (c *Client) MonitorSet(ctx context.Context, body map[string]string) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/monitor/set", query, body, nil)
+}
+
+// MonitorSnapshotParams are the parameters of MonitorSnapshot.
+type MonitorSnapshotParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorSnapshot sends GET /api/monitor/snapshot.
+// Latest frame of a monitor.
+func (c *Client) MonitorSnapshot(ctx context.Context, params MonitorSnapshotParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doStream(ctx, "GET", "/api/monitor/snapshot", query, nil, "")
+}
+
+// MonitorStatsParams are the parameters of MonitorStats.
+type MonitorStatsParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorStats sends GET /api/monitor/stats.
+// Bitrate, frame rate and resolution history of the live streams of a monitor.
+func (c *Client) MonitorStats(ctx context.Context, params MonitorStatsParams) (Stats, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res Stats
+	err := c.doJSON(ctx, "GET", "/api/monitor/stats", query, nil, &res)
+	return res, err
+}
+
+// MonitorTemplateApplyParams are the parameters of MonitorTemplateApply.
+type MonitorTemplateApplyParams struct {
+	// Template name.
+	Template string
+	// ID of the new monitor.
+	ID string
+	// Name of the new monitor.
+	Name string
+}
+
+// MonitorTemplateApply sends POST /api/monitor/template/apply.
+// Create a monitor from a template, the body contains the variables.
+func (c *Client) MonitorTemplateApply(ctx context.Context, params MonitorTemplateApplyParams, body map[string]string) error {
+	query := url.Values{}
+	query.Set("template", params.Template)
+	query.Set("id", params.ID)
+	query.Set("name", params.Name)
+	return c.doJSON(ctx, "POST", "/api/monitor/template/apply", query, body, nil)
+}
+
+// MonitorTemplateDeleteParams are the parameters of MonitorTemplateDelete.
+type MonitorTemplateDeleteParams struct {
+	// Template name.
+	Name string
+}
+
+// MonitorTemplateDelete sends DELETE /api/monitor/template/delete.
+// Delete a monitor template.
+func (c *Client) MonitorTemplateDelete(ctx context.Context, params MonitorTemplateDeleteParams) error {
+	query := url.Values{}
+	query.Set("name", params.Name)
+	return c.doJSON(ctx, "DELETE", "/api/monitor/template/delete", query, nil, nil)
+}
+
+// MonitorTemplateSet sends PUT /api/monitor/template/set.
+// Create or update a monitor template.
+func (c *Client) MonitorTemplateSet(ctx context.Context, body Template) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/monitor/template/set", query, body, nil)
+}
+
+// MonitorTemplates sends GET /api/monitor/templates.
+// Monitor templates and their variables.
+func (c *Client) MonitorTemplates(ctx context.Context) ([]MonitorTemplate, error) {
+	query := url.Values{}
+	var res []MonitorTemplate
+	err := c.doJSON(ctx, "GET", "/api/monitor/templates", query, nil, &res)
+	return res, err
+}
+
+// MonitorTest sends POST /api/monitor/test.
+// Probe a candidate stream URL before the monitor is saved.
+func (c *Client) MonitorTest(ctx context.Context, body StreamTestRequest) (StreamTestResult, error) {
+	query := url.Values{}
+	var res StreamTestResult
+	err := c.doJSON(ctx, "POST", "/api/monitor/test", query, body, &res)
+	return res, err
+}
+
+// MonitorZoneDeleteParams are the parameters of MonitorZoneDelete.
+type MonitorZoneDeleteParams struct {
+	// Monitor ID.
+	ID string
+	// Zone name.
+	Name string
+}
+
+// MonitorZoneDelete sends DELETE /api/monitor/zone/delete.
+// Delete a zone of a monitor.
+func (c *Client) MonitorZoneDelete(ctx context.Context, params MonitorZoneDeleteParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("name", params.Name)
+	return c.doJSON(ctx, "DELETE", "/api/monitor/zone/delete", query, nil, nil)
+}
+
+// MonitorZoneSetParams are the parameters of MonitorZoneSet.
+type MonitorZoneSetParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorZoneSet sends PUT /api/monitor/zone/set.
+// Create or replace the zone with the same name.
+func (c *Client) MonitorZoneSet(ctx context.Context, params MonitorZoneSetParams, body Zone) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "PUT", "/api/monitor/zone/set", query, body, nil)
+}
+
+// MonitorZonesParams are the parameters of MonitorZones.
+type MonitorZonesParams struct {
+	// Monitor ID.
+	ID string
+}
+
+// MonitorZones sends GET /api/monitor/zones.
+// Zones of a monitor sorted by name. Points are normalized, {0, 0} is the top left corner.
+func (c *Client) MonitorZones(ctx context.Context, params MonitorZonesParams) ([]Zone, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res []Zone
+	err := c.doJSON(ctx, "GET", "/api/monitor/zones", query, nil, &res)
+	return res, err
+}
+
+// RecordingActivityParams are the parameters of RecordingActivity.
+type RecordingActivityParams struct {
+	// RFC3339 time.
+	Start string
+	// RFC3339 time.
+	End string
+	// "hour" or "minute", defaults to "hour".
+	Interval string
+	// Comma separated list of monitor IDs.
+	Monitors string
+}
+
+// RecordingActivity sends GET /api/recording/activity.
+// Number of recordings and events of each monitor per hour or minute.
+func (c *Client) RecordingActivity(ctx context.Context, params RecordingActivityParams) (ActivityHeatmap, error) {
+	query := url.Values{}
+	query.Set("start", params.Start)
+	query.Set("end", params.End)
+	if params.Interval != "" {
+		query.Set("interval", params.Interval)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	var res ActivityHeatmap
+	err := c.doJSON(ctx, "GET", "/api/recording/activity", query, nil, &res)
+	return res, err
+}
+
+// RecordingDeleteParams are the parameters of RecordingDelete.
+type RecordingDeleteParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingDelete sends DELETE /api/recording.
+// Delete a recording.
+func (c *Client) RecordingDelete(ctx context.Context, params RecordingDeleteParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/recording", query, nil, nil)
+}
+
+// RecordingDeleteByPathParams are the parameters of RecordingDeleteByPath.
+type RecordingDeleteByPathParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingDeleteByPath sends DELETE /api/recording/delete/{id}.
+// Delete a recording.
+func (c *Client) RecordingDeleteByPath(ctx context.Context, params RecordingDeleteByPathParams) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "DELETE", "/api/recording/delete/"+url.PathEscape(params.ID), query, nil, nil)
+}
+
+// RecordingDownloadParams are the parameters of RecordingDownload.
+type RecordingDownloadParams struct {
+	// Comma separated list of recording IDs.
+	Ids string
+	// RFC3339 time, used if ids is empty.
+	Start string
+	// RFC3339 time.
+	End string
+	// Comma separated list of monitor IDs.
+	Monitors string
+}
+
+// RecordingDownload sends GET /api/recording/download.
+// ZIP archive of recordings by ID or time range.
+func (c *Client) RecordingDownload(ctx context.Context, params RecordingDownloadParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	if params.Ids != "" {
+		query.Set("ids", params.Ids)
+	}
+	if params.Start != "" {
+		query.Set("start", params.Start)
+	}
+	if params.End != "" {
+		query.Set("end", params.End)
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	return c.doStream(ctx, "GET", "/api/recording/download", query, nil, "")
+}
+
+// RecordingExport sends POST /api/recording/export.
+// Start a export job.
+func (c *Client) RecordingExport(ctx context.Context, body Request) (Job, error) {
+	query := url.Values{}
+	var res Job
+	err := c.doJSON(ctx, "POST", "/api/recording/export", query, body, &res)
+	return res, err
+}
+
+// RecordingExportFileParams are the parameters of RecordingExportFile.
+type RecordingExportFileParams struct {
+	// Job ID.
+	ID string
+}
+
+// RecordingExportFile sends GET /api/recording/export/file.
+// File of a finished export job.
+func (c *Client) RecordingExportFile(ctx context.Context, params RecordingExportFileParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doStream(ctx, "GET", "/api/recording/export/file", query, nil, "")
+}
+
+// RecordingExportStatusParams are the parameters of RecordingExportStatus.
+type RecordingExportStatusParams struct {
+	// Job ID.
+	ID string
+}
+
+// RecordingExportStatus sends GET /api/recording/export/status.
+// Status of a export job.
+func (c *Client) RecordingExportStatus(ctx context.Context, params RecordingExportStatusParams) (Job, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	var res Job
+	err := c.doJSON(ctx, "GET", "/api/recording/export/status", query, nil, &res)
+	return res, err
+}
+
+// RecordingIndexParams are the parameters of RecordingIndex.
+type RecordingIndexParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingIndex sends GET /api/recording/index/{id}.
+// Keyframe index of a recording.
+func (c *Client) RecordingIndex(ctx context.Context, params RecordingIndexParams) (VideoIndex, error) {
+	query := url.Values{}
+	var res VideoIndex
+	err := c.doJSON(ctx, "GET", "/api/recording/index/"+url.PathEscape(params.ID), query, nil, &res)
+	return res, err
+}
+
+// RecordingPlaybackParams are the parameters of RecordingPlayback.
+type RecordingPlaybackParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingPlayback sends GET /api/recording/playback/{id}.
+// Video of a recording, remuxed if browsers can't play it.
+func (c *Client) RecordingPlayback(ctx context.Context, params RecordingPlaybackParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/recording/playback/"+url.PathEscape(params.ID), query, nil, "")
+}
+
+// RecordingProtectParams are the parameters of RecordingProtect.
+type RecordingProtectParams struct {
+	// Recording ID.
+	ID      string
+	Protect bool
+}
+
+// RecordingProtect sends POST /api/recording/protect.
+// Protect a recording from being pruned.
+func (c *Client) RecordingProtect(ctx context.Context, params RecordingProtectParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("protect", strconv.FormatBool(params.Protect))
+	return c.doJSON(ctx, "POST", "/api/recording/protect", query, nil, nil)
+}
+
+// RecordingQueryParams are the parameters of RecordingQuery.
+type RecordingQueryParams struct {
+	// Maximum number of recordings.
+	Limit int
+	// Start after this recording ID or time, "2006-01-02_15-04-05".
+	Time string
+	// Oldest first.
+	Reverse bool
+	// Include the recording data.
+	Data bool
+	// Comma separated triggers, "continuous", "motion" or "object".
+	Triggers string
+	// Comma separated detection labels.
+	Labels string
+	// Comma separated detection zones.
+	Zones string
+	// Minimum duration in seconds.
+	MinDuration int
+	// Comma separated list of monitor IDs.
+	Monitors string
+}
+
+// RecordingQuery sends GET /api/recording/query.
+// Recordings before or after a time.
+func (c *Client) RecordingQuery(ctx context.Context, params RecordingQueryParams) ([]Recording, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Data {
+		query.Set("data", strconv.FormatBool(params.Data))
+	}
+	if params.Triggers != "" {
+		query.Set("triggers", params.Triggers)
+	}
+	if params.Labels != "" {
+		query.Set("labels", params.Labels)
+	}
+	if params.Zones != "" {
+		query.Set("zones", params.Zones)
+	}
+	if params.MinDuration != 0 {
+		query.Set("minDuration", strconv.Itoa(params.MinDuration))
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	var res []Recording
+	err := c.doJSON(ctx, "GET", "/api/recording/query", query, nil, &res)
+	return res, err
+}
+
+// RecordingQueryList is RecordingQuery with the list parameters.
+func (c *Client) RecordingQueryList(ctx context.Context, params RecordingQueryParams, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.Reverse {
+		query.Set("reverse", strconv.FormatBool(params.Reverse))
+	}
+	if params.Data {
+		query.Set("data", strconv.FormatBool(params.Data))
+	}
+	if params.Triggers != "" {
+		query.Set("triggers", params.Triggers)
+	}
+	if params.Labels != "" {
+		query.Set("labels", params.Labels)
+	}
+	if params.Zones != "" {
+		query.Set("zones", params.Zones)
+	}
+	if params.MinDuration != 0 {
+		query.Set("minDuration", strconv.Itoa(params.MinDuration))
+	}
+	if params.Monitors != "" {
+		query.Set("monitors", params.Monitors)
+	}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/recording/query", query, nil, &res)
+	return res, err
+}
+
+// RecordingSummary sends POST /api/recording/summary.
+// Start a time-lapse or motion heatmap summary job.
+func (c *Client) RecordingSummary(ctx context.Context, body SummaryRequest) (Job, error) {
+	query := url.Values{}
+	var res Job
+	err := c.doJSON(ctx, "POST", "/api/recording/summary", query, body, &res)
+	return res, err
+}
+
+// RecordingThumbnailParams are the parameters of RecordingThumbnail.
+type RecordingThumbnailParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingThumbnail sends GET /api/recording/thumbnail/{id}.
+// Thumbnail of a recording.
+func (c *Client) RecordingThumbnail(ctx context.Context, params RecordingThumbnailParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/recording/thumbnail/"+url.PathEscape(params.ID), query, nil, "")
+}
+
+// RecordingVODInitParams are the parameters of RecordingVODInit.
+type RecordingVODInitParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingVODInit sends GET /api/recording/vod/init.mp4.
+// Initialization segment of a recording.
+func (c *Client) RecordingVODInit(ctx context.Context, params RecordingVODInitParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doStream(ctx, "GET", "/api/recording/vod/init.mp4", query, nil, "")
+}
+
+// RecordingVODPlaylistParams are the parameters of RecordingVODPlaylist.
+type RecordingVODPlaylistParams struct {
+	// Monitor ID.
+	Monitor string
+	// RFC3339 time.
+	Start string
+	// RFC3339 time.
+	End string
+	// Only include segments with activity.
+	Active bool
+	// RFC3339 time to start playing from.
+	Time string
+}
+
+// RecordingVODPlaylist sends GET /api/recording/vod/playlist.m3u8.
+// HLS playlist that spans the recordings of a monitor.
+func (c *Client) RecordingVODPlaylist(ctx context.Context, params RecordingVODPlaylistParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("monitor", params.Monitor)
+	query.Set("start", params.Start)
+	query.Set("end", params.End)
+	if params.Active {
+		query.Set("active", strconv.FormatBool(params.Active))
+	}
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	return c.doStream(ctx, "GET", "/api/recording/vod/playlist.m3u8", query, nil, "")
+}
+
+// RecordingVODSegmentParams are the parameters of RecordingVODSegment.
+type RecordingVODSegmentParams struct {
+	// Recording ID.
+	ID string
+	// Fragment number.
+	N int
+}
+
+// RecordingVODSegment sends GET /api/recording/vod/segment.m4s.
+// Media segment of a recording.
+func (c *Client) RecordingVODSegment(ctx context.Context, params RecordingVODSegmentParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	query.Set("n", strconv.Itoa(params.N))
+	return c.doStream(ctx, "GET", "/api/recording/vod/segment.m4s", query, nil, "")
+}
+
+// RecordingVideoParams are the parameters of RecordingVideo.
+type RecordingVideoParams struct {
+	// Recording ID.
+	ID string
+}
+
+// RecordingVideo sends GET /api/recording/video/{id}.
+// Video of a recording.
+func (c *Client) RecordingVideo(ctx context.Context, params RecordingVideoParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/recording/video/"+url.PathEscape(params.ID), query, nil, "")
+}
+
+// ShareCreate sends POST /api/share/create.
+// Create a time-limited link to the live stream of a monitor or a recording that works without login.
+func (c *Client) ShareCreate(ctx context.Context, body CreateRequest) (ShareCreateResponse, error) {
+	query := url.Values{}
+	var res ShareCreateResponse
+	err := c.doJSON(ctx, "POST", "/api/share/create", query, body, &res)
+	return res, err
+}
+
+// ShareLinks sends GET /api/share/links.
+// Issued share links newest first, including expired and revoked links.
+func (c *Client) ShareLinks(ctx context.Context) ([]Link, error) {
+	query := url.Values{}
+	var res []Link
+	err := c.doJSON(ctx, "GET", "/api/share/links", query, nil, &res)
+	return res, err
+}
+
+// ShareRevokeParams are the parameters of ShareRevoke.
+type ShareRevokeParams struct {
+	// Share link ID.
+	ID string
+}
+
+// ShareRevoke sends DELETE /api/share/revoke.
+// Revoke a share link.
+func (c *Client) ShareRevoke(ctx context.Context, params ShareRevokeParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/share/revoke", query, nil, nil)
+}
+
+// Spec sends GET /api/spec.
+// OpenAPI specification of the API.
+func (c *Client) Spec(ctx context.Context) (map[string]json.RawMessage, error) {
+	query := url.Values{}
+	var res map[string]json.RawMessage
+	err := c.doJSON(ctx, "GET", "/api/spec", query, nil, &res)
+	return res, err
+}
+
+// SpeedTestRecommendParams are the parameters of SpeedTestRecommend.
+type SpeedTestRecommendParams struct {
+	// Monitor ID.
+	Monitor string
+}
+
+// SpeedTestRecommend sends GET /api/speedtest/recommend.
+// Recommended live stream based on the speed test of the session.
+func (c *Client) SpeedTestRecommend(ctx context.Context, params SpeedTestRecommendParams) (StreamRecommendation, error) {
+	query := url.Values{}
+	query.Set("monitor", params.Monitor)
+	var res StreamRecommendation
+	err := c.doJSON(ctx, "GET", "/api/speedtest/recommend", query, nil, &res)
+	return res, err
+}
+
+// StorageAgeReport sends GET /api/storage/age-report.
+// Size of the recordings of each monitor grouped by age.
+func (c *Client) StorageAgeReport(ctx context.Context) (AgeReport, error) {
+	query := url.Values{}
+	var res AgeReport
+	err := c.doJSON(ctx, "GET", "/api/storage/age-report", query, nil, &res)
+	return res, err
+}
+
+// StorageForecast sends GET /api/storage/forecast.
+// Storage usage rates and when the storage will be full.
+func (c *Client) StorageForecast(ctx context.Context) (Forecast, error) {
+	query := url.Values{}
+	var res Forecast
+	err := c.doJSON(ctx, "GET", "/api/storage/forecast", query, nil, &res)
+	return res, err
+}
+
+// StorageMaintenance sends POST /api/storage/maintenance.
+// Start removing orphaned recording files and empty directories, 409 if already pending.
+func (c *Client) StorageMaintenance(ctx context.Context) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "POST", "/api/storage/maintenance", query, nil, nil)
+}
+
+// StorageMaintenanceStatus sends GET /api/storage/maintenance/status.
+// Report of the running or last storage maintenance.
+func (c *Client) StorageMaintenanceStatus(ctx context.Context) (ScrubReport, error) {
+	query := url.Values{}
+	var res ScrubReport
+	err := c.doJSON(ctx, "GET", "/api/storage/maintenance/status", query, nil, &res)
+	return res, err
+}
+
+// StorageVerify sends POST /api/storage/verify.
+// Start a verification of the recordings, 409 if one is already pending.
+func (c *Client) StorageVerify(ctx context.Context) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "POST", "/api/storage/verify", query, nil, nil)
+}
+
+// StorageVerifyStatus sends GET /api/storage/verify/status.
+// Report of the running or last verification.
+func (c *Client) StorageVerifyStatus(ctx context.Context) (VerifyReport, error) {
+	query := url.Values{}
+	var res VerifyReport
+	err := c.doJSON(ctx, "GET", "/api/storage/verify/status", query, nil, &res)
+	return res, err
+}
+
+// Streams sends GET /api/streams.
+// Live stream sessions of all users and the RTSP readers.
+func (c *Client) Streams(ctx context.Context) ([]StreamSession, error) {
+	query := url.Values{}
+	var res []StreamSession
+	err := c.doJSON(ctx, "GET", "/api/streams", query, nil, &res)
+	return res, err
+}
+
+// StreamsKickParams are the parameters of StreamsKick.
+type StreamsKickParams struct {
+	// Session ID.
+	ID string
+}
+
+// StreamsKick sends POST /api/streams/kick.
+// Close a live stream session or disconnect a RTSP reader.
+func (c *Client) StreamsKick(ctx context.Context, params StreamsKickParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "POST", "/api/streams/kick", query, nil, nil)
+}
+
+// SystemBackup sends GET /api/system/backup.
+// Archive of the config directory.
+func (c *Client) SystemBackup(ctx context.Context) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/system/backup", query, nil, "")
+}
+
+// SystemRestoreParams are the parameters of SystemRestore.
+type SystemRestoreParams struct {
+	// Keep files that aren't in the backup.
+	Merge bool
+}
+
+// SystemRestore sends POST /api/system/restore.
+// Restore a backup archive.
+func (c *Client) SystemRestore(ctx context.Context, params SystemRestoreParams, body io.Reader) error {
+	query := url.Values{}
+	if params.Merge {
+		query.Set("merge", strconv.FormatBool(params.Merge))
+	}
+	res, err := c.doStream(ctx, "POST", "/api/system/restore", query, body, "application/gzip")
+	if err != nil {
+		return err
+	}
+	return res.Close()
+}
+
+// SystemStatus sends GET /api/system/status.
+// Redacted system status, the fields are configured by publicStatus in env.yaml.
+func (c *Client) SystemStatus(ctx context.Context) (PublicStatus, error) {
+	query := url.Values{}
+	var res PublicStatus
+	err := c.doJSON(ctx, "GET", "/api/system/status", query, nil, &res)
+	return res, err
+}
+
+// SystemTimeZone sends GET /api/system/time-zone.
+// Time zone of the system.
+func (c *Client) SystemTimeZone(ctx context.Context) (string, error) {
+	query := url.Values{}
+	var res string
+	err := c.doJSON(ctx, "GET", "/api/system/time-zone", query, nil, &res)
+	return res, err
+}
+
+// SystemTranscoders sends GET /api/system/transcoders.
+// Capabilities of the transcoder backends.
+func (c *Client) SystemTranscoders(ctx context.Context) (Transcoders, error) {
+	query := url.Values{}
+	var res Transcoders
+	err := c.doJSON(ctx, "GET", "/api/system/transcoders", query, nil, &res)
+	return res, err
+}
+
+// SystemUpdate sends POST /api/system/update.
+// Install the latest release and restart, rolls back if the release fails its health check.
+func (c *Client) SystemUpdate(ctx context.Context) (Release, error) {
+	query := url.Values{}
+	var res Release
+	err := c.doJSON(ctx, "POST", "/api/system/update", query, nil, &res)
+	return res, err
+}
+
+// SystemVersionParams are the parameters of SystemVersion.
+type SystemVersionParams struct {
+	// Check the release manifest for a newer release.
+	Check bool
+}
+
+// SystemVersion sends GET /api/system/version.
+// Current version, latest release and update state.
+func (c *Client) SystemVersion(ctx context.Context, params SystemVersionParams) (VersionInfo, error) {
+	query := url.Values{}
+	if params.Check {
+		query.Set("check", strconv.FormatBool(params.Check))
+	}
+	var res VersionInfo
+	err := c.doJSON(ctx, "GET", "/api/system/version", query, nil, &res)
+	return res, err
+}
+
+// TranscodeProfileDeleteParams are the parameters of TranscodeProfileDelete.
+type TranscodeProfileDeleteParams struct {
+	// Profile name.
+	Name string
+}
+
+// TranscodeProfileDelete sends DELETE /api/transcode/profile/delete.
+// Delete a transcode profile.
+func (c *Client) TranscodeProfileDelete(ctx context.Context, params TranscodeProfileDeleteParams) error {
+	query := url.Values{}
+	query.Set("name", params.Name)
+	return c.doJSON(ctx, "DELETE", "/api/transcode/profile/delete", query, nil, nil)
+}
+
+// TranscodeProfileSet sends PUT /api/transcode/profile/set.
+// Create or update a transcode profile.
+func (c *Client) TranscodeProfileSet(ctx context.Context, body Profile) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/transcode/profile/set", query, body, nil)
+}
+
+// TranscodeProfiles sends GET /api/transcode/profiles.
+// Transcode profiles.
+func (c *Client) TranscodeProfiles(ctx context.Context) ([]Profile, error) {
+	query := url.Values{}
+	var res []Profile
+	err := c.doJSON(ctx, "GET", "/api/transcode/profiles", query, nil, &res)
+	return res, err
+}
+
+// UserDeleteParams are the parameters of UserDelete.
+type UserDeleteParams struct {
+	// User ID.
+	ID string
+}
+
+// UserDelete sends DELETE /api/user/delete.
+// Delete a user.
+func (c *Client) UserDelete(ctx context.Context, params UserDeleteParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	return c.doJSON(ctx, "DELETE", "/api/user/delete", query, nil, nil)
+}
+
+// UserImpersonateParams are the parameters of UserImpersonate.
+type UserImpersonateParams struct {
+	// User ID.
+	ID string
+	// Duration in minutes.
+	Duration int
+}
+
+// UserImpersonate sends POST /api/user/impersonate.
+// Validate the requests of the admin as another user.
+func (c *Client) UserImpersonate(ctx context.Context, params UserImpersonateParams) error {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	if params.Duration != 0 {
+		query.Set("duration", strconv.Itoa(params.Duration))
+	}
+	return c.doJSON(ctx, "POST", "/api/user/impersonate", query, nil, nil)
+}
+
+// UserImpersonateStop sends POST /api/user/impersonate/stop.
+// Stop impersonating a user.
+func (c *Client) UserImpersonateStop(ctx context.Context) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "POST", "/api/user/impersonate/stop", query, nil, nil)
+}
+
+// UserLiveSessions sends GET /api/user/live-sessions.
+// Active live streams.
+func (c *Client) UserLiveSessions(ctx context.Context) ([]LiveSession, error) {
+	query := url.Values{}
+	var res []LiveSession
+	err := c.doJSON(ctx, "GET", "/api/user/live-sessions", query, nil, &res)
+	return res, err
+}
+
+// UserLockoutClearParams are the parameters of UserLockoutClear.
+type UserLockoutClearParams struct {
+	// "ip" or "account", empty clears all lockouts.
+	Type string
+	// IP or username.
+	Key string
+}
+
+// UserLockoutClear sends POST /api/user/lockouts/clear.
+// Unlock a IP or account.
+func (c *Client) UserLockoutClear(ctx context.Context, params UserLockoutClearParams) error {
+	query := url.Values{}
+	if params.Type != "" {
+		query.Set("type", params.Type)
+	}
+	if params.Key != "" {
+		query.Set("key", params.Key)
+	}
+	return c.doJSON(ctx, "POST", "/api/user/lockouts/clear", query, nil, nil)
+}
+
+// UserLockouts sends GET /api/user/lockouts.
+// IPs and accounts that are locked after failed logins.
+func (c *Client) UserLockouts(ctx context.Context) ([]Lockout, error) {
+	query := url.Values{}
+	var res []Lockout
+	err := c.doJSON(ctx, "GET", "/api/user/lockouts", query, nil, &res)
+	return res, err
+}
+
+// UserMyToken sends GET /api/user/my-token.
+// CSRF token of the current user.
+func (c *Client) UserMyToken(ctx context.Context) (io.ReadCloser, error) {
+	query := url.Values{}
+	return c.doStream(ctx, "GET", "/api/user/my-token", query, nil, "")
+}
+
+// UserPassword sends PUT /api/user/password.
+// Change the password of the current user.
+func (c *Client) UserPassword(ctx context.Context, body ChangePasswordRequest) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/user/password", query, body, nil)
+}
+
+// UserPreferences sends GET /api/user/preferences.
+// Preferences of the current user.
+func (c *Client) UserPreferences(ctx context.Context) (UserPreferences, error) {
+	query := url.Values{}
+	var res UserPreferences
+	err := c.doJSON(ctx, "GET", "/api/user/preferences", query, nil, &res)
+	return res, err
+}
+
+// UserPreferencesSet sends PUT /api/user/preferences/set.
+// Replace the preferences of the current user.
+func (c *Client) UserPreferencesSet(ctx context.Context, body UserPreferences) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/user/preferences/set", query, body, nil)
+}
+
+// UserReset sends POST /api/user/reset.
+// Set a temporary password that must be changed on the next login.
+func (c *Client) UserReset(ctx context.Context, body ResetPasswordRequest) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "POST", "/api/user/reset", query, body, nil)
+}
+
+// UserSet sends PUT /api/user/set.
+// Create or update a user.
+func (c *Client) UserSet(ctx context.Context, body SetUserRequest) error {
+	query := url.Values{}
+	return c.doJSON(ctx, "PUT", "/api/user/set", query, body, nil)
+}
+
+// Users sends GET /api/users.
+// Obfuscated users by ID.
+func (c *Client) Users(ctx context.Context) (map[string]AccountObfuscated, error) {
+	query := url.Values{}
+	var res map[string]AccountObfuscated
+	err := c.doJSON(ctx, "GET", "/api/users", query, nil, &res)
+	return res, err
+}
+
+// UsersList is Users with the list parameters.
+func (c *Client) UsersList(ctx context.Context, list ListParams) (ListPage, error) {
+	query := url.Values{}
+	list.encode(query)
+	var res ListPage
+	err := c.doJSON(ctx, "GET", "/api/users", query, nil, &res)
+	return res, err
+}
+
+// VideoHLSMemory sends GET /api/video/hls-memory.
+// Memory used by the HLS segments.
+func (c *Client) VideoHLSMemory(ctx context.Context) (MemoryBudgetStats, error) {
+	query := url.Values{}
+	var res MemoryBudgetStats
+	err := c.doJSON(ctx, "GET", "/api/video/hls-memory", query, nil, &res)
+	return res, err
+}
+
+// VideoPaths sends GET /api/video/paths.
+// Statistics of the video server paths.
+func (c *Client) VideoPaths(ctx context.Context) ([]PathStats, error) {
+	query := url.Values{}
+	var res []PathStats
+	err := c.doJSON(ctx, "GET", "/api/video/paths", query, nil, &res)
+	return res, err
+}
@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Gen generates the API client from the OpenAPI specification.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"nvr/pkg/web"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	// Addons register the "/api/addons" routes.
+	_ "nvr/pkg/addon"
+)
+
+const outputFile = "client_gen.go"
+
+func main() {
+	src, err := generate(web.Spec())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(outputFile, src, 0o644); err != nil { //nolint:gosec
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type generator struct {
+	b bytes.Buffer
+}
+
+func (g *generator) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&g.b, format, a...)
+}
+
+func generate(spec *web.OpenAPI) ([]byte, error) {
+	g := &generator{}
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.printf("// %v is a API type.\n", name)
+		g.printf("type %v %v\n\n", name, goType(spec.Components.Schemas[name]))
+	}
+
+	for _, op := range operations(spec) {
+		g.operation(op)
+	}
+
+	var file bytes.Buffer
+	file.WriteString("// Code generated by \"go run ./gen\"; DO NOT EDIT.\n\n")
+	file.WriteString("package apiclient\n\n")
+	file.WriteString("import (\n")
+	for _, pkg := range []string{"context", "encoding/json", "io", "net/url", "strconv", "time"} {
+		if bytes.Contains(g.b.Bytes(), []byte(pkg[strings.LastIndex(pkg, "/")+1:]+".")) {
+			fmt.Fprintf(&file, "%q\n", pkg)
+		}
+	}
+	file.WriteString(")\n\n")
+	file.Write(g.b.Bytes())
+
+	src, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format: %w", err)
+	}
+	return src, nil
+}
+
+type operation struct {
+	*web.OpenAPIOperation
+	method string
+	path   string
+}
+
+// operations returns the operations sorted by ID, websockets are skipped.
+func operations(spec *web.OpenAPI) []operation {
+	var ops []operation
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			if op.WebsocketMessage != nil || op.Responses["101"].Description != "" {
+				continue
+			}
+			ops = append(ops, operation{
+				OpenAPIOperation: op,
+				method:           strings.ToUpper(method),
+				path:             path,
+			})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].OperationID < ops[j].OperationID
+	})
+	return ops
+}
+
+func (g *generator) operation(op operation) { //nolint:funlen
+	name := exportedName(op.OperationID)
+
+	var params []web.OpenAPIParameter
+	for _, p := range op.Parameters {
+		if isListParam(p.Name) {
+			continue
+		}
+		params = append(params, p)
+	}
+	paramsType := name + "Params"
+	if len(params) != 0 {
+		g.printf("// %v are the parameters of %v.\n", paramsType, name)
+		g.printf("type %v struct {\n", paramsType)
+		for _, p := range params {
+			if p.Description != "" {
+				g.printf("// %v\n", p.Description)
+			}
+			g.printf("%v %v\n", goName(p.Name), paramType(p.Schema.Type))
+		}
+		g.printf("}\n\n")
+	}
+
+	// Arguments.
+	args := "ctx context.Context"
+	if len(params) != 0 {
+		args += ", params " + paramsType
+	}
+	body := "nil"
+	var bodyType string
+	if op.RequestBody != nil {
+		for contentType, media := range op.RequestBody.Content {
+			if contentType == "application/json" {
+				args += ", body " + goType(media.Schema)
+				body = "body"
+			} else {
+				args += ", body io.Reader"
+				bodyType = contentType
+			}
+		}
+	}
+
+	// Response.
+	var resType string
+	var stream bool
+	if res, exist := op.Responses["200"]; exist {
+		for contentType, media := range res.Content {
+			if contentType == "application/json" {
+				schema := media.Schema
+				if len(schema.OneOf) != 0 {
+					schema = schema.OneOf[0]
+				}
+				resType = goType(schema)
+			} else {
+				resType = "io.ReadCloser"
+				stream = true
+			}
+		}
+	}
+	writeQuery := func() {
+		g.printf("query := url.Values{}\n")
+		for _, p := range params {
+			if p.In != "query" {
+				continue
+			}
+			field := "params." + goName(p.Name)
+			value := field
+			switch p.Schema.Type {
+			case "integer":
+				value = "strconv.Itoa(" + field + ")"
+			case "boolean":
+				value = "strconv.FormatBool(" + field + ")"
+			}
+			switch {
+			case p.Required:
+				g.printf("query.Set(%q, %v)\n", p.Name, value)
+				continue
+			case p.Schema.Type == "boolean":
+				g.printf("if %v {\n", field)
+			default:
+				g.printf("if %v != %v {\n", field, zeroValue(p.Schema.Type))
+			}
+			g.printf("query.Set(%q, %v)\n", p.Name, value)
+			g.printf("}\n")
+		}
+	}
+	path := pathExpr(op.path)
+
+	g.printf("// %v sends %v %v.\n// %v\n", name, op.method, op.path, op.Summary)
+	switch {
+	case stream:
+		g.printf("func (c *Client) %v(%v) (io.ReadCloser, error) {\n", name, args)
+		writeQuery()
+		if bodyType != "" {
+			g.printf("return c.doStream(ctx, %q, %v, query, body, %q)\n", op.method, path, bodyType)
+		} else {
+			g.printf("return c.doStream(ctx, %q, %v, query, nil, \"\")\n", op.method, path)
+		}
+	case bodyType != "":
+		g.printf("func (c *Client) %v(%v) error {\n", name, args)
+		writeQuery()
+		g.printf("res, err := c.doStream(ctx, %q, %v, query, body, %q)\n", op.method, path, bodyType)
+		g.printf("if err != nil {\nreturn err\n}\n")
+		g.printf("return res.Close()\n")
+	case resType != "":
+		g.printf("func (c *Client) %v(%v) (%v, error) {\n", name, args, resType)
+		writeQuery()
+		g.printf("var res %v\n", resType)
+		g.printf("err := c.doJSON(ctx, %q, %v, query, %v, &res)\n", op.method, path, body)
+		g.printf("return res, err\n")
+	default:
+		g.printf("func (c *Client) %v(%v) error {\n", name, args)
+		writeQuery()
+		g.printf("return c.doJSON(ctx, %q, %v, query, %v, nil)\n", op.method, path, body)
+	}
+	g.printf("}\n\n")
+
+	if !op.List {
+		return
+	}
+	g.printf("// %vList is %v with the list parameters.\n", name, name)
+	g.printf("func (c *Client) %vList(%v, list ListParams) (ListPage, error) {\n", name, args)
+	writeQuery()
+	g.printf("list.encode(query)\n")
+	g.printf("var res ListPage\n")
+	g.printf("err := c.doJSON(ctx, %q, %v, query, %v, &res)\n", op.method, path, body)
+	g.printf("return res, err\n")
+	g.printf("}\n\n")
+}
+
+func isListParam(name string) bool {
+	switch name {
+	case "fields", "sort", "page[size]", "page[cursor]":
+		return true
+	}
+	return false
+}
+
+// pathExpr returns a Go expression that replaces the path parameters.
+func pathExpr(path string) string {
+	var parts []string
+	for path != "" {
+		start := strings.Index(path, "{")
+		if start == -1 {
+			parts = append(parts, fmt.Sprintf("%q", path))
+			break
+		}
+		end := strings.Index(path, "}")
+		if start != 0 {
+			parts = append(parts, fmt.Sprintf("%q", path[:start]))
+		}
+		parts = append(parts, "url.PathEscape(params."+goName(path[start+1:end])+")")
+		path = path[end+1:]
+	}
+	return strings.Join(parts, " + ")
+}
+
+func paramType(typ string) string {
+	switch typ {
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+func zeroValue(typ string) string {
+	switch typ {
+	case "integer":
+		return "0"
+	default:
+		return `""`
+	}
+}
+
+// goType returns the Go type of the schema. Struct fields are
+// omitted if empty so that unset fields keep the server defaults.
+func goType(s *web.Schema) string {
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, web.RefPrefix)
+	}
+	switch s.Type {
+	case "boolean":
+		return "bool"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "array":
+		return "[]" + goType(s.Items)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + goType(s.AdditionalProperties)
+		}
+		if len(s.Properties) == 0 {
+			return "struct{}"
+		}
+		return structType(s)
+	default:
+		return "json.RawMessage"
+	}
+}
+
+func structType(s *web.Schema) string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, name := range names {
+		prop := s.Properties[name]
+		field := prop.GoName
+		if field == "" {
+			field = goName(name)
+		}
+		fmt.Fprintf(&b, "%v %v `json:\"%v,omitempty\"`\n", field, goType(prop), name)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+var initialisms = map[string]string{
+	"id":  "ID",
+	"ip":  "IP",
+	"url": "URL",
+}
+
+// goName converts a parameter name to a exported Go name.
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if initialism, exist := initialisms[strings.ToLower(word)]; exist {
+			b.WriteString(initialism)
+			continue
+		}
+		b.WriteString(exportedName(word))
+	}
+	return b.String()
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nvr/pkg/web"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerated(t *testing.T) {
+	want, err := generate(web.Spec())
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(filepath.Join("..", outputFile))
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got), "run: go generate ./pkg/web/apiclient")
+}
+
+func TestPathExpr(t *testing.T) {
+	require.Equal(t, `"/api/a"`, pathExpr("/api/a"))
+	require.Equal(t,
+		`"/api/group/" + url.PathEscape(params.ID) + "/recordings"`,
+		pathExpr("/api/group/{id}/recordings"),
+	)
+}
+
+func TestGoName(t *testing.T) {
+	require.Equal(t, "ID", goName("id"))
+	require.Equal(t, "MonitorID", goName("monitorID"))
+	require.Equal(t, "PageSize", goName("page[size]"))
+}
@@ -0,0 +1,808 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"nvr/pkg/export"
+	"nvr/pkg/group"
+	"nvr/pkg/ingest"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/onvif"
+	"nvr/pkg/share"
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+	"nvr/pkg/update"
+	"nvr/pkg/video"
+	"nvr/pkg/video/hls"
+	"nvr/pkg/web/auth"
+	"nvr/pkg/zones"
+)
+
+// Content types of the non-JSON endpoints.
+const (
+	contentTypeGzip = "application/gzip"
+	contentTypeJPEG = "image/jpeg"
+	contentTypeMP4  = "video/mp4"
+	contentTypeHLS  = "application/vnd.apple.mpegurl"
+	contentTypeText = "text/plain"
+	contentTypeZip  = "application/zip"
+)
+
+func queryParam(name string, typ string, required bool, description string) Param {
+	return Param{Name: name, In: "query", Type: typ, Required: required, Description: description}
+}
+
+func pathParam(name string, description string) Param {
+	return Param{Name: name, In: "path", Type: "string", Required: true, Description: description}
+}
+
+// feedPoll is the response of the feed fallback endpoints.
+func feedPoll[T any]() interface{} {
+	return struct {
+		Cursor uint64 `json:"cursor"`
+		Items  []T    `json:"items"`
+	}{}
+}
+
+var (
+	idParam      = queryParam("id", "string", true, "Monitor ID.")
+	cursorParam  = queryParam("cursor", "integer", false, "Resume the feed after this message.")
+	timeoutParam = queryParam("timeout", "integer", false, "Seconds to wait for new messages.")
+	monitorsCSV  = queryParam("monitors", "string", false, "Comma separated list of monitor IDs.")
+	recIDParam   = pathParam("id", "Recording ID.")
+)
+
+const durationMin = "Duration in minutes."
+
+var logQueryParams = []Param{
+	queryParam("limit", "integer", true, "Maximum number of entries."),
+	queryParam("levels", "string", false, "Comma separated list of levels, 16=error 24=warning 32=info 48=debug."),
+	queryParam("sources", "string", false, "Comma separated list of sources."),
+	monitorsCSV,
+	queryParam("time", "integer", false, "Only entries before this UNIX time in microseconds."),
+}
+
+var crawlerQueryParams = []Param{
+	queryParam("limit", "integer", true, "Maximum number of recordings."),
+	queryParam("time", "string", false, `Start after this recording ID or time, "2006-01-02_15-04-05".`),
+	queryParam("reverse", "boolean", false, "Oldest first."),
+	queryParam("data", "boolean", false, "Include the recording data."),
+	queryParam("triggers", "string", false, `Comma separated triggers, "continuous", "motion" or "object".`),
+	queryParam("labels", "string", false, "Comma separated detection labels."),
+	queryParam("zones", "string", false, "Comma separated detection zones."),
+	queryParam("minDuration", "integer", false, "Minimum duration in seconds."),
+}
+
+// routes documents the API routes of this package by mux pattern.
+var routes = map[string]Route{
+	"/api/spec": {Auth: AuthUser, Operations: []Operation{{
+		ID: "spec", Method: http.MethodGet,
+		Summary:  "OpenAPI specification of the API.",
+		Response: map[string]interface{}{},
+	}}},
+
+	"/api/system/time-zone": {Auth: AuthUser, Operations: []Operation{{
+		ID: "systemTimeZone", Method: http.MethodGet,
+		Summary:  "Time zone of the system.",
+		Response: "",
+	}}},
+	"/api/system/transcoders": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "systemTranscoders", Method: http.MethodGet,
+		Summary:  "Capabilities of the transcoder backends.",
+		Response: monitor.Transcoders{},
+	}}},
+	"/api/system/backup": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "systemBackup", Method: http.MethodGet,
+		Summary:      "Archive of the config directory.",
+		ResponseType: contentTypeGzip,
+	}}},
+	"/api/system/restore": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "systemRestore", Method: http.MethodPost,
+		Summary: "Restore a backup archive.",
+		Params: []Param{
+			queryParam("merge", "boolean", false, "Keep files that aren't in the backup."),
+		},
+		RequestType: contentTypeGzip,
+	}}},
+	"/api/system/version": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "systemVersion", Method: http.MethodGet,
+		Summary: "Current version, latest release and update state.",
+		Params: []Param{
+			queryParam("check", "boolean", false, "Check the release manifest for a newer release."),
+		},
+		Response: update.VersionInfo{},
+	}}},
+	"/api/system/update": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "systemUpdate", Method: http.MethodPost,
+		Summary:  "Install the latest release and restart, rolls back if the release fails its health check.",
+		Response: update.Release{},
+	}}},
+	"/api/system/status": {Auth: AuthNone, Operations: []Operation{{
+		ID: "systemStatus", Method: http.MethodGet,
+		Summary:  "Redacted system status, the fields are configured by publicStatus in env.yaml.",
+		Response: publicStatus{},
+	}}},
+	"/api/storage/age-report": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "storageAgeReport", Method: http.MethodGet,
+		Summary:  "Size of the recordings of each monitor grouped by age.",
+		Response: storage.AgeReport{},
+	}}},
+	"/api/storage/forecast": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "storageForecast", Method: http.MethodGet,
+		Summary:  "Storage usage rates and when the storage will be full.",
+		Response: storage.Forecast{},
+	}}},
+	"/api/storage/verify": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "storageVerify", Method: http.MethodPost,
+		Summary: "Start a verification of the recordings, 409 if one is already pending.",
+	}}},
+	"/api/storage/verify/status": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "storageVerifyStatus", Method: http.MethodGet,
+		Summary:  "Report of the running or last verification.",
+		Response: storage.VerifyReport{},
+	}}},
+	"/api/storage/maintenance": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "storageMaintenance", Method: http.MethodPost,
+		Summary: "Start removing orphaned recording files and empty directories, 409 if already pending.",
+	}}},
+	"/api/storage/maintenance/status": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "storageMaintenanceStatus", Method: http.MethodGet,
+		Summary:  "Report of the running or last storage maintenance.",
+		Response: storage.ScrubReport{},
+	}}},
+
+	"/api/general": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "general", Method: http.MethodGet,
+		Summary:  "General config.",
+		Response: map[string]string{},
+	}}},
+	"/api/general/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "generalSet", Method: http.MethodPut,
+		Summary: "Set the general config.",
+		Request: map[string]string{},
+	}}},
+
+	"/api/users": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "users", Method: http.MethodGet,
+		Summary:  "Obfuscated users by ID.",
+		Response: map[string]auth.AccountObfuscated{},
+		List:     true,
+	}}},
+	"/api/user/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "userSet", Method: http.MethodPut,
+		Summary: "Create or update a user.",
+		Request: auth.SetUserRequest{},
+	}}},
+	"/api/user/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "userDelete", Method: http.MethodDelete,
+		Summary: "Delete a user.",
+		Params:  []Param{queryParam("id", "string", true, "User ID.")},
+	}}},
+	"/api/user/reset": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "userReset", Method: http.MethodPost,
+		Summary: "Set a temporary password that must be changed on the next login.",
+		Request: auth.ResetPasswordRequest{},
+	}}},
+	"/api/user/password": {Auth: AuthUser, CSRF: true, Operations: []Operation{{
+		ID: "userPassword", Method: http.MethodPut,
+		Summary: "Change the password of the current user.",
+		Request: auth.ChangePasswordRequest{},
+	}}},
+	"/api/user/preferences": {Auth: AuthUser, Operations: []Operation{{
+		ID: "userPreferences", Method: http.MethodGet,
+		Summary:  "Preferences of the current user.",
+		Response: UserPreferences{},
+	}}},
+	"/api/user/preferences/set": {Auth: AuthUser, CSRF: true, Operations: []Operation{{
+		ID: "userPreferencesSet", Method: http.MethodPut,
+		Summary: "Replace the preferences of the current user.",
+		Request: UserPreferences{},
+	}}},
+	"/api/user/impersonate": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "userImpersonate", Method: http.MethodPost,
+		Summary: "Validate the requests of the admin as another user.",
+		Params: []Param{
+			queryParam("id", "string", true, "User ID."),
+			queryParam("duration", "integer", false, durationMin),
+		},
+	}}},
+	"/api/user/impersonate/stop": {Auth: AuthUser, CSRF: true, Operations: []Operation{{
+		ID: "userImpersonateStop", Method: http.MethodPost,
+		Summary: "Stop impersonating a user.",
+	}}},
+	"/api/user/lockouts": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "userLockouts", Method: http.MethodGet,
+		Summary:  "IPs and accounts that are locked after failed logins.",
+		Response: []auth.Lockout{},
+	}}},
+	"/api/user/lockouts/clear": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "userLockoutClear", Method: http.MethodPost,
+		Summary: "Unlock a IP or account.",
+		Params: []Param{
+			queryParam("type", "string", false, `"ip" or "account", empty clears all lockouts.`),
+			queryParam("key", "string", false, "IP or username."),
+		},
+	}}},
+	"/api/user/my-token": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "userMyToken", Method: http.MethodGet,
+		Summary:      "CSRF token of the current user.",
+		ResponseType: contentTypeText,
+	}}},
+	"/api/user/live-sessions": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "userLiveSessions", Method: http.MethodGet,
+		Summary:  "Active live streams.",
+		Response: []LiveSession{},
+	}}},
+	"/api/streams": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "streams", Method: http.MethodGet,
+		Summary:  "Live stream sessions of all users and the RTSP readers.",
+		Response: []StreamSession{},
+	}}},
+	"/api/streams/kick": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "streamsKick", Method: http.MethodPost,
+		Summary: "Close a live stream session or disconnect a RTSP reader.",
+		Params:  []Param{queryParam("id", "string", true, "Session ID.")},
+	}}},
+
+	"/api/monitor/arm": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorArm", Method: http.MethodPost,
+		Summary: "Override the arm schedule of a monitor.",
+		Params: []Param{
+			idParam,
+			queryParam("state", "string", true, `"arm", "disarm" or "auto" to follow the schedule.`),
+			queryParam("duration", "integer", false, durationMin+" Zero until changed."),
+		},
+	}}},
+	"/api/monitor/arm-state": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorArmState", Method: http.MethodGet,
+		Summary:  "Arm state of a monitor.",
+		Params:   []Param{idParam},
+		Response: monitor.ArmState{},
+	}}},
+	"/api/monitor/camera-motion": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "monitorCameraMotion", Method: http.MethodGet,
+		Summary:  "Motion detection config of the camera.",
+		Params:   []Param{idParam},
+		Response: onvif.MotionConfig{},
+	}}},
+	"/api/monitor/camera-motion/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorCameraMotionSet", Method: http.MethodPut,
+		Summary: "Set the motion detection config of the camera.",
+		Params:  []Param{idParam},
+		Request: onvif.MotionConfig{},
+	}}},
+	"/api/monitor/configs": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "monitorConfigs", Method: http.MethodGet,
+		Summary:  "Monitor configs by ID, the passwords are redacted.",
+		Response: monitor.RawConfigs{},
+	}}},
+	"/api/monitor/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorDelete", Method: http.MethodDelete,
+		Summary: "Delete a monitor.",
+		Params:  []Param{idParam},
+	}}},
+	"/api/monitor/snapshot": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorSnapshot", Method: http.MethodGet,
+		Summary:      "Latest frame of a monitor.",
+		Params:       []Param{idParam},
+		ResponseType: contentTypeJPEG,
+	}}},
+	"/api/monitor/talkback": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorTalkback", Method: http.MethodGet,
+		Summary:   "Websocket that relays microphone audio to the camera speaker.",
+		Params:    []Param{idParam},
+		Websocket: true,
+	}}},
+	"/api/monitor/events": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorEvents", Method: http.MethodGet,
+		Summary:   "Websocket with the live events of the monitors.",
+		Params:    []Param{monitorsCSV, cursorParam},
+		Response:  liveEventMessage{},
+		Websocket: true,
+	}}},
+	"/api/monitor/events/poll": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorEventsPoll", Method: http.MethodGet,
+		Summary:  "Long polling fallback of the monitor events websocket.",
+		Params:   []Param{monitorsCSV, cursorParam, timeoutParam},
+		Response: feedPoll[liveEventMessage](),
+	}}},
+	"/api/monitor/health": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorHealth", Method: http.MethodGet,
+		Summary:  "Health of the running monitors by ID.",
+		Response: map[string]monitor.Health{},
+	}}},
+	"/api/monitor/stats": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorStats", Method: http.MethodGet,
+		Summary:  "Bitrate, frame rate and resolution history of the live streams of a monitor.",
+		Params:   []Param{idParam},
+		Response: monitor.Stats{},
+	}}},
+	"/api/monitor/renditions": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorRenditions", Method: http.MethodGet,
+		Summary:  "Live streams of a monitor.",
+		Params:   []Param{idParam},
+		Response: []video.Rendition{},
+	}}},
+	"/api/monitor/maintenance": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorMaintenance", Method: http.MethodPost,
+		Summary: "Start or stop maintenance of a monitor.",
+		Params: []Param{
+			idParam,
+			queryParam("enable", "boolean", true, ""),
+			queryParam("duration", "integer", false, durationMin+" Zero until stopped."),
+			queryParam("reason", "string", false, ""),
+		},
+	}}},
+	"/api/monitor/maintenance-state": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorMaintenanceState", Method: http.MethodGet,
+		Summary:  "Maintenance state and history of a monitor.",
+		Params:   []Param{idParam},
+		Response: monitor.MaintenanceInfo{},
+	}}},
+	"/api/monitor/list": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorList", Method: http.MethodGet,
+		Summary: "Redacted monitor configs by ID.",
+		Params: []Param{
+			queryParam("tags", "string", false, "Comma separated list of tags, only monitors with all of them."),
+		},
+		Response: monitor.RawConfigs{},
+		List:     true,
+	}}},
+	"/api/monitor/restart": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorRestart", Method: http.MethodPost,
+		Summary: "Restart a monitor.",
+		Params:  []Param{idParam},
+	}}},
+	"/api/monitor/enable": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorEnable", Method: http.MethodPost,
+		Summary: "Enable or disable a monitor.",
+		Params: []Param{
+			idParam,
+			queryParam("enable", "boolean", true, ""),
+		},
+	}}},
+	"/api/monitor/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorSet", Method: http.MethodPut,
+		Summary: "Create or update a monitor.",
+		Request: monitor.RawConfig{},
+	}}},
+	"/api/monitor/clone": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorClone", Method: http.MethodPost,
+		Summary: "Create a monitor from the config of another.",
+		Params: []Param{
+			queryParam("from", "string", true, "ID of the monitor to clone."),
+			queryParam("id", "string", true, "ID of the new monitor."),
+			queryParam("name", "string", true, "Name of the new monitor."),
+		},
+	}}},
+	"/api/monitor/templates": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "monitorTemplates", Method: http.MethodGet,
+		Summary:  "Monitor templates and their variables.",
+		Response: []monitorTemplate{},
+	}}},
+	"/api/monitor/template/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorTemplateSet", Method: http.MethodPut,
+		Summary: "Create or update a monitor template.",
+		Request: monitor.Template{},
+	}}},
+	"/api/monitor/template/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorTemplateDelete", Method: http.MethodDelete,
+		Summary: "Delete a monitor template.",
+		Params:  []Param{queryParam("name", "string", true, "Template name.")},
+	}}},
+	"/api/monitor/template/apply": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorTemplateApply", Method: http.MethodPost,
+		Summary: "Create a monitor from a template, the body contains the variables.",
+		Params: []Param{
+			queryParam("template", "string", true, "Template name."),
+			queryParam("id", "string", true, "ID of the new monitor."),
+			queryParam("name", "string", true, "Name of the new monitor."),
+		},
+		Request: map[string]string{},
+	}}},
+	"/api/monitor/credentials": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "monitorCredentials", Method: http.MethodGet,
+		Summary:  "Camera credentials without the passwords.",
+		Response: []monitor.Credential{},
+	}}},
+	"/api/monitor/credential/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorCredentialSet", Method: http.MethodPut,
+		Summary: "Create or update a camera credential, a empty password keeps the current password.",
+		Request: monitor.Credential{},
+	}}},
+	"/api/monitor/credential/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorCredentialDelete", Method: http.MethodDelete,
+		Summary: "Delete a camera credential that isn't used by any monitor.",
+		Params:  []Param{queryParam("id", "string", true, "Credential ID.")},
+	}}},
+	"/api/monitor/test": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorTest", Method: http.MethodPost,
+		Summary:  "Probe a candidate stream URL before the monitor is saved.",
+		Request:  monitor.StreamTestRequest{},
+		Response: monitor.StreamTestResult{},
+	}}},
+	"/api/monitor/privacy-mask": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "monitorPrivacyMask", Method: http.MethodGet,
+		Summary:  "Privacy mask versions of a monitor, oldest first. The last version is the current mask.",
+		Params:   []Param{idParam},
+		Response: []monitor.PrivacyMask{},
+	}}},
+	"/api/monitor/privacy-mask/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorPrivacyMaskSet", Method: http.MethodPut,
+		Summary:  "Save a new version of the privacy mask of a monitor and restart it. Empty zones remove the mask.",
+		Params:   []Param{idParam},
+		Request:  privacyMaskRequest{},
+		Response: monitor.PrivacyMask{},
+	}}},
+	"/api/monitor/zones": {Auth: AuthUser, Operations: []Operation{{
+		ID: "monitorZones", Method: http.MethodGet,
+		Summary:  "Zones of a monitor sorted by name. Points are normalized, {0, 0} is the top left corner.",
+		Params:   []Param{idParam},
+		Response: []zones.Zone{},
+	}}},
+	"/api/monitor/zone/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorZoneSet", Method: http.MethodPut,
+		Summary: "Create or replace the zone with the same name.",
+		Params:  []Param{idParam},
+		Request: zones.Zone{},
+	}}},
+	"/api/monitor/zone/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "monitorZoneDelete", Method: http.MethodDelete,
+		Summary: "Delete a zone of a monitor.",
+		Params: []Param{
+			idParam,
+			queryParam("name", "string", true, "Zone name."),
+		},
+	}}},
+	"/api/share/create": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "shareCreate", Method: http.MethodPost,
+		Summary:  "Create a time-limited link to the live stream of a monitor or a recording that works without login.",
+		Request:  share.CreateRequest{},
+		Response: ShareCreateResponse{},
+	}}},
+	"/api/share/links": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "shareLinks", Method: http.MethodGet,
+		Summary:  "Issued share links newest first, including expired and revoked links.",
+		Response: []share.Link{},
+	}}},
+	"/api/share/revoke": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "shareRevoke", Method: http.MethodDelete,
+		Summary: "Revoke a share link.",
+		Params:  []Param{queryParam("id", "string", true, "Share link ID.")},
+	}}},
+	"/api/ingest/presign": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "ingestPresign", Method: http.MethodPost,
+		Summary:  "Issue a token that allows a edge device to upload recordings of a monitor without login.",
+		Request:  ingest.PresignRequest{},
+		Response: IngestPresignResponse{},
+	}}},
+	"/api/ingest/tokens": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "ingestTokens", Method: http.MethodGet,
+		Summary:  "Issued upload tokens newest first, including expired and revoked tokens.",
+		Response: []ingest.UploadToken{},
+	}}},
+	"/api/ingest/revoke": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "ingestRevoke", Method: http.MethodDelete,
+		Summary: "Revoke a upload token.",
+		Params:  []Param{queryParam("id", "string", true, "Upload token ID.")},
+	}}},
+	"/api/video/paths": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "videoPaths", Method: http.MethodGet,
+		Summary:  "Statistics of the video server paths.",
+		Response: []video.PathStats{},
+	}}},
+	"/api/video/hls-memory": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "videoHLSMemory", Method: http.MethodGet,
+		Summary:  "Memory used by the HLS segments.",
+		Response: hls.MemoryBudgetStats{},
+	}}},
+
+	"/api/live/": {Auth: AuthUser, Operations: []Operation{{
+		ID: "live", Method: http.MethodGet, Path: "/api/live/{id}",
+		Summary: "Websocket with the fragmented MP4 live stream of a monitor.",
+		Params: []Param{
+			pathParam("id", "Monitor ID."),
+			queryParam("sub", "string", false, `"true" for the sub stream, "auto" to use the speed test.`),
+		},
+		Websocket: true,
+	}}},
+	"/api/speedtest": {Auth: AuthUser, Operations: []Operation{{
+		ID: "speedTest", Method: http.MethodGet,
+		Summary:   "Websocket that measures the bandwidth of the session.",
+		Websocket: true,
+	}}},
+	"/api/speedtest/recommend": {Auth: AuthUser, Operations: []Operation{{
+		ID: "speedTestRecommend", Method: http.MethodGet,
+		Summary:  "Recommended live stream based on the speed test of the session.",
+		Params:   []Param{queryParam("monitor", "string", true, "Monitor ID.")},
+		Response: StreamRecommendation{},
+	}}},
+
+	"/api/group/configs": {Auth: AuthUser, Operations: []Operation{{
+		ID: "groupConfigs", Method: http.MethodGet,
+		Summary:  "Group configs by ID.",
+		Response: group.Configs{},
+	}}},
+	"/api/group/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "groupSet", Method: http.MethodPut,
+		Summary: "Create or update a group.",
+		Request: group.Config{},
+	}}},
+	"/api/group/action": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "groupAction", Method: http.MethodPost,
+		Summary: "Start, stop, restart, arm or disarm every monitor in a group.",
+		Params: []Param{
+			idParam,
+			queryParam("action", "string", true,
+				`"start", "stop", "restart", "arm", "disarm" or "auto" to follow the arm schedule.`),
+			queryParam("duration", "integer", false, durationMin+" Only used by arm actions. Zero until changed."),
+		},
+		Response: []monitor.GroupActionResult{},
+	}}},
+	"/api/group/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "groupDelete", Method: http.MethodDelete,
+		Summary: "Delete a group.",
+		Params:  []Param{queryParam("id", "string", true, "Group ID.")},
+	}}},
+	"/api/group/": {Auth: AuthUser, Operations: []Operation{
+		{
+			ID: "groupRecordings", Method: http.MethodGet, Path: "/api/group/{id}/recordings",
+			Summary:  "Recordings of the monitors in a group.",
+			Params:   append([]Param{pathParam("id", "Group ID.")}, crawlerQueryParams...),
+			Response: groupRecordings{},
+			List:     true,
+		},
+		{
+			ID: "groupEvents", Method: http.MethodGet, Path: "/api/group/{id}/events",
+			Summary:  "Events of the recordings of the monitors in a group.",
+			Params:   append([]Param{pathParam("id", "Group ID.")}, crawlerQueryParams...),
+			Response: groupEvents{},
+			List:     true,
+		},
+		{
+			ID: "groupLayout", Method: http.MethodGet, Path: "/api/group/{id}/layout",
+			Summary:  "Live grid layout, tile order, rotation and stream quality of a group.",
+			Params:   []Param{pathParam("id", "Group ID.")},
+			Response: group.Layout{},
+		},
+	}},
+
+	"/api/recording": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "recordingDelete", Method: http.MethodDelete,
+		Summary: "Delete a recording.",
+		Params:  []Param{queryParam("id", "string", true, "Recording ID.")},
+	}}},
+	"/api/recording/delete/": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "recordingDeleteByPath", Method: http.MethodDelete, Path: "/api/recording/delete/{id}",
+		Summary: "Delete a recording.",
+		Params:  []Param{recIDParam},
+	}}},
+	"/api/recording/protect": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "recordingProtect", Method: http.MethodPost,
+		Summary: "Protect a recording from being pruned.",
+		Params: []Param{
+			queryParam("id", "string", true, "Recording ID."),
+			queryParam("protect", "boolean", true, ""),
+		},
+	}}},
+	"/api/recording/download": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingDownload", Method: http.MethodGet,
+		Summary: "ZIP archive of recordings by ID or time range.",
+		Params: []Param{
+			queryParam("ids", "string", false, "Comma separated list of recording IDs."),
+			queryParam("start", "string", false, "RFC3339 time, used if ids is empty."),
+			queryParam("end", "string", false, "RFC3339 time."),
+			monitorsCSV,
+		},
+		ResponseType: contentTypeZip,
+	}}},
+	"/api/recording/thumbnail/": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingThumbnail", Method: http.MethodGet, Path: "/api/recording/thumbnail/{id}",
+		Summary:      "Thumbnail of a recording.",
+		Params:       []Param{recIDParam},
+		ResponseType: contentTypeJPEG,
+	}}},
+	"/api/recording/video/": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingVideo", Method: http.MethodGet, Path: "/api/recording/video/{id}",
+		Summary:      "Video of a recording.",
+		Params:       []Param{recIDParam},
+		ResponseType: contentTypeMP4,
+	}}},
+	"/api/recording/playback/": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingPlayback", Method: http.MethodGet, Path: "/api/recording/playback/{id}",
+		Summary:      "Video of a recording, remuxed if browsers can't play it.",
+		Params:       []Param{recIDParam},
+		ResponseType: contentTypeMP4,
+	}}},
+	"/api/recording/index/": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingIndex", Method: http.MethodGet, Path: "/api/recording/index/{id}",
+		Summary:  "Keyframe index of a recording.",
+		Params:   []Param{recIDParam},
+		Response: storage.VideoIndex{},
+	}}},
+	"/api/recording/vod/": {Auth: AuthUser, Operations: []Operation{
+		{
+			ID: "recordingVODPlaylist", Method: http.MethodGet, Path: "/api/recording/vod/playlist.m3u8",
+			Summary: "HLS playlist that spans the recordings of a monitor.",
+			Params: []Param{
+				queryParam("monitor", "string", true, "Monitor ID."),
+				queryParam("start", "string", true, "RFC3339 time."),
+				queryParam("end", "string", true, "RFC3339 time."),
+				queryParam("active", "boolean", false, "Only include segments with activity."),
+				queryParam("time", "string", false, "RFC3339 time to start playing from."),
+			},
+			ResponseType: contentTypeHLS,
+		},
+		{
+			ID: "recordingVODInit", Method: http.MethodGet, Path: "/api/recording/vod/init.mp4",
+			Summary:      "Initialization segment of a recording.",
+			Params:       []Param{queryParam("id", "string", true, "Recording ID.")},
+			ResponseType: contentTypeMP4,
+		},
+		{
+			ID: "recordingVODSegment", Method: http.MethodGet, Path: "/api/recording/vod/segment.m4s",
+			Summary: "Media segment of a recording.",
+			Params: []Param{
+				queryParam("id", "string", true, "Recording ID."),
+				queryParam("n", "integer", true, "Fragment number."),
+			},
+			ResponseType: contentTypeMP4,
+		},
+	}},
+	"/api/events/feed": {Auth: AuthUser, Operations: []Operation{{
+		ID: "eventsFeed", Method: http.MethodGet,
+		Summary: "Websocket with monitor states, events, storage alerts and logs.",
+		Params: []Param{
+			queryParam("types", "string", false, `Comma separated list of "monitor", "event", "storage" and "log".`),
+			monitorsCSV,
+			cursorParam,
+		},
+		Response:  eventsFeedMessage{},
+		Websocket: true,
+	}}},
+	"/api/events/feed/poll": {Auth: AuthUser, Operations: []Operation{{
+		ID: "eventsFeedPoll", Method: http.MethodGet,
+		Summary: "Long polling fallback of the events feed websocket.",
+		Params: []Param{
+			queryParam("types", "string", false, `Comma separated list of "monitor", "event", "storage" and "log".`),
+			monitorsCSV,
+			cursorParam,
+			timeoutParam,
+		},
+		Response: feedPoll[eventsFeedMessage](),
+	}}},
+	"/api/events/": {Auth: AuthUser, Operations: []Operation{
+		{
+			ID: "eventClip", Method: http.MethodGet, Path: "/api/events/{id}/clip",
+			Summary:      "Video clip of a event.",
+			Params:       []Param{pathParam("id", "Event ID.")},
+			ResponseType: contentTypeMP4,
+		},
+		{
+			ID: "eventSnapshot", Method: http.MethodGet, Path: "/api/events/{id}/snapshot",
+			Summary:      "Full resolution image of a detection event.",
+			Params:       []Param{pathParam("id", "Event ID.")},
+			ResponseType: contentTypeJPEG,
+		},
+	}},
+	"/api/events/snapshots": {Auth: AuthUser, Operations: []Operation{{
+		ID: "eventSnapshots", Method: http.MethodGet,
+		Summary: "Event snapshot gallery, newest first.",
+		Params: []Param{
+			queryParam("limit", "integer", true, "Maximum number of snapshots."),
+			queryParam("time", "string", false, `Start after this event ID or time, "2006-01-02_15-04-05".`),
+			queryParam("reverse", "boolean", false, "Oldest first."),
+			queryParam("plate", "string", false, "Only events with a recognized license plate that contains this."),
+			monitorsCSV,
+		},
+		Response: []monitor.EventSnapshot{},
+	}}},
+	"/api/recording/query": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingQuery", Method: http.MethodGet,
+		Summary:  "Recordings before or after a time.",
+		Params:   append(append([]Param{}, crawlerQueryParams...), monitorsCSV),
+		Response: []storage.Recording{},
+		List:     true,
+	}}},
+	"/api/recording/activity": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingActivity", Method: http.MethodGet,
+		Summary: "Number of recordings and events of each monitor per hour or minute.",
+		Params: []Param{
+			queryParam("start", "string", true, "RFC3339 time."),
+			queryParam("end", "string", true, "RFC3339 time."),
+			queryParam("interval", "string", false, `"hour" or "minute", defaults to "hour".`),
+			monitorsCSV,
+		},
+		Response: storage.ActivityHeatmap{},
+	}}},
+	"/api/transcode/profiles": {Auth: AuthUser, Operations: []Operation{{
+		ID: "transcodeProfiles", Method: http.MethodGet,
+		Summary:  "Transcode profiles.",
+		Response: []transcode.Profile{},
+	}}},
+	"/api/transcode/profile/set": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "transcodeProfileSet", Method: http.MethodPut,
+		Summary: "Create or update a transcode profile.",
+		Request: transcode.Profile{},
+	}}},
+	"/api/transcode/profile/delete": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "transcodeProfileDelete", Method: http.MethodDelete,
+		Summary: "Delete a transcode profile.",
+		Params:  []Param{queryParam("name", "string", true, "Profile name.")},
+	}}},
+
+	"/api/recording/export": {Auth: AuthUser, CSRF: true, Operations: []Operation{{
+		ID: "recordingExport", Method: http.MethodPost,
+		Summary:  "Start a export job.",
+		Request:  export.Request{},
+		Response: export.Job{},
+	}}},
+	"/api/recording/summary": {Auth: AuthUser, CSRF: true, Operations: []Operation{{
+		ID: "recordingSummary", Method: http.MethodPost,
+		Summary:  "Start a time-lapse or motion heatmap summary job.",
+		Request:  export.SummaryRequest{},
+		Response: export.Job{},
+	}}},
+	"/api/recording/export/status": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingExportStatus", Method: http.MethodGet,
+		Summary:  "Status of a export job.",
+		Params:   []Param{queryParam("id", "string", true, "Job ID.")},
+		Response: export.Job{},
+	}}},
+	"/api/recording/export/file": {Auth: AuthUser, Operations: []Operation{{
+		ID: "recordingExportFile", Method: http.MethodGet,
+		Summary:      "File of a finished export job.",
+		Params:       []Param{queryParam("id", "string", true, "Job ID.")},
+		ResponseType: contentTypeMP4,
+	}}},
+
+	"/api/log/feed": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "logFeed", Method: http.MethodGet,
+		Summary: "Websocket with the system logs.",
+		Params: append(append([]Param{}, logQueryParams[1:4]...), cursorParam,
+			queryParam("resume", "string", false, "Resume the feed after the message with this token.")),
+		Response:  logFeedMessage{},
+		Websocket: true,
+	}}},
+	"/api/log/feed/poll": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "logFeedPoll", Method: http.MethodGet,
+		Summary:  "Long polling fallback of the log feed websocket.",
+		Params:   append(append([]Param{}, logQueryParams[1:4]...), cursorParam, timeoutParam),
+		Response: feedPoll[logFeedMessage](),
+	}}},
+	"/api/log/query": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "logQuery", Method: http.MethodGet,
+		Summary: "Stored logs, newest first.",
+		Params: append(append([]Param{}, logQueryParams...),
+			queryParam("format", "string", false,
+				`Stream all the matching entries as "ndjson" or "csv", the limit is ignored.`),
+		),
+		Response: []log.Entry{},
+		List:     true,
+	}}},
+	"/api/log/search": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "logSearch", Method: http.MethodGet,
+		Summary: "Stored logs that contain the text or match the regex, newest first.",
+		Params: append(append([]Param{}, logQueryParams...),
+			queryParam("text", "string", false, "Substring of the message."),
+			queryParam("regex", "string", false, "Regular expression that matches the message."),
+		),
+		Response: []log.Entry{},
+		List:     true,
+	}}},
+	"/api/log/sources": {Auth: AuthAdmin, Operations: []Operation{{
+		ID: "logSources", Method: http.MethodGet,
+		Summary:  "Log sources and their levels.",
+		Response: []log.SourceLevel{},
+	}}},
+	"/api/log/level": {Auth: AuthAdmin, CSRF: true, Operations: []Operation{{
+		ID: "logLevelSet", Method: http.MethodPost,
+		Summary: "Set the most verbose level that is logged for a source.",
+		Params: []Param{
+			queryParam("source", "string", true, "Log source."),
+			queryParam("level", "string", true, `"error", "warning", "info" or "debug".`),
+		},
+	}}},
+}
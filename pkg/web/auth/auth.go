@@ -5,10 +5,13 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"nvr/pkg/log"
 	"nvr/pkg/storage"
+	"strconv"
+	"time"
 
 	stdLog "log"
 )
@@ -20,19 +23,39 @@ type Account struct {
 	Password []byte `json:"password"` // Hashed password.
 	IsAdmin  bool   `json:"isAdmin"`
 	Token    string `json:"-"` // CSRF token.
+
+	// The user must change password before using the app.
+	MustChangePassword bool `json:"mustChangePassword,omitempty"`
+
+	// The user may talk through camera speakers, admins always may.
+	Talkback bool `json:"talkback,omitempty"`
+}
+
+// CanTalkback returns true if the account may use talkback.
+func (a Account) CanTalkback() bool {
+	return a.IsAdmin || a.Talkback
 }
 
 // AccountObfuscated Account without sensitive information.
 type AccountObfuscated struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	IsAdmin  bool   `json:"isAdmin"`
+	ID                 string `json:"id"`
+	Username           string `json:"username"`
+	IsAdmin            bool   `json:"isAdmin"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+	Talkback           bool   `json:"talkback"`
 }
 
 // ValidateResponse ValidateRequest response.
 type ValidateResponse struct {
 	IsValid bool
 	User    Account
+
+	// Admin account that is impersonating User, nil if not impersonated.
+	Impersonator *Account
+
+	// Set if the request was rejected because the IP
+	// or account is locked after too many failed logins.
+	LockedUntil time.Time
 }
 
 // SetUserRequest set user details request.
@@ -41,6 +64,21 @@ type SetUserRequest struct {
 	Username      string `json:"username"`
 	PlainPassword string `json:"plainPassword,omitempty"`
 	IsAdmin       bool   `json:"isAdmin"`
+
+	MustChangePassword bool `json:"mustChangePassword"`
+	Talkback           bool `json:"talkback"`
+}
+
+// ResetPasswordRequest admin password reset request.
+type ResetPasswordRequest struct {
+	ID            string `json:"id"`
+	PlainPassword string `json:"plainPassword"`
+}
+
+// ChangePasswordRequest password change request.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
 }
 
 // NewAuthenticatorFunc function to create authenticator.
@@ -60,6 +98,24 @@ type Authenticator interface {
 	UserSet(SetUserRequest) error
 	// UserDelete deletes a user by id.
 	UserDelete(string) error
+	// UserReset sets a temporary password and requires
+	// the user to change it before using the app.
+	UserReset(ResetPasswordRequest) error
+	// ChangePassword changes the password of the user with the
+	// given id and clears the must change password flag.
+	ChangePassword(id string, req ChangePasswordRequest) error
+
+	// Impersonate validates the requests of the admin as the
+	// user with the given id until the duration has passed.
+	Impersonate(adminID string, userID string, duration time.Duration) error
+	// StopImpersonation stops the impersonation of the admin.
+	StopImpersonation(adminID string) error
+
+	// Lockouts returns the IPs and accounts that are
+	// locked after too many failed login attempts.
+	Lockouts() []Lockout
+	// ClearLockout unlocks a IP or account, see Limiter.Clear.
+	ClearLockout(typ string, key string) error
 
 	// Handler wrappers.
 	// User blocks unauthenticated requests.
@@ -76,26 +132,40 @@ type Authenticator interface {
 	Logout() http.Handler
 }
 
-// LogFailedLogin finds and logs the ip.
+// LogFailedLogin logs the username and client IP.
 func LogFailedLogin(logger *log.Logger, r *http.Request, username string) {
-	ip := ""
-	realIP := r.Header.Get("X-Real-Ip")
-	if realIP != "" {
-		ip += "real:" + realIP + " "
-	}
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" && forwarded != realIP {
-		ip += "forwarded:" + forwarded + " "
-	}
-	remoteAddr := r.RemoteAddr
-	if remoteAddr != "" && remoteAddr != forwarded {
-		ip += "addr:" + remoteAddr
-	}
-
 	logger.Log(log.Entry{
 		Level: log.LevelInfo,
 		Src:   "auth",
-		Msg:   fmt.Sprintf("failed login: username: %v %v\n", username, ip),
+		Msg:   fmt.Sprintf("failed login: username: %v ip: %v", username, ClientIP(r)),
+	})
+}
+
+// RespondLocked responds with "429 Too Many Requests"
+// and when the client can retry the login.
+func RespondLocked(w http.ResponseWriter, until time.Time) {
+	retryAfter := int(time.Until(until).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "too many failed login attempts", http.StatusTooManyRequests)
+}
+
+// ImpersonationHeader is set on the responses of impersonated
+// requests. The value is the username of the impersonated user.
+const ImpersonationHeader = "X-Impersonating"
+
+// Impersonation errors.
+var (
+	ErrImpersonationUnsupported = errors.New("impersonation is not supported")
+	ErrImpersonateSelf          = errors.New("cannot impersonate yourself")
+	ErrNotImpersonating         = errors.New("not impersonating")
+)
+
+// LogImpersonation logs the start or end of an impersonation.
+func LogImpersonation(logger *log.Logger, msg string) {
+	logger.Log(log.Entry{
+		Level: log.LevelWarning,
+		Src:   "auth",
+		Msg:   "impersonation: " + msg,
 	})
 }
 
@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Lockout types.
+const (
+	LockoutIP      = "ip"
+	LockoutAccount = "account"
+)
+
+// Lockout is a locked IP address or account.
+type Lockout struct {
+	Type     string    `json:"type"`
+	Key      string    `json:"key"` // IP address or username.
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+}
+
+type limiterKey struct {
+	typ string
+	key string
+}
+
+type attempts struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// Number of tracked keys before expired entries are pruned and the
+// maximum number of tracked keys, the oldest keys are evicted.
+const (
+	limiterPruneSize = 1024
+	limiterMaxKeys   = 10000
+)
+
+// Failed attempts from a IP are delayed by the step for each failure
+// within the window, up to the max.
+const (
+	defaultDelayStep = 250 * time.Millisecond
+	maxDelay         = 5 * time.Second
+)
+
+// Limiter counts failed login attempts per IP address and per account.
+// The IP or account is locked for the lockout duration if the number
+// of failures within the window reaches the limit. A locked IP can't
+// log in, a locked account can still log in with the correct password,
+// otherwise anyone could lock out the admin. Guessing is slowed down by
+// delaying the attempts from IPs with failures instead.
+type Limiter struct {
+	maxAttempts map[string]int
+	window      time.Duration
+	lockout     time.Duration
+	delayStep   time.Duration
+
+	attempts map[limiterKey]*attempts
+	logger   *log.Logger
+	mu       sync.Mutex
+}
+
+// NewLimiter creates a limiter from the env config.
+func NewLimiter(c storage.AuthRateLimit, logger *log.Logger) *Limiter {
+	return &Limiter{
+		maxAttempts: map[string]int{
+			LockoutIP:      c.MaxAttemptsIP,
+			LockoutAccount: c.MaxAttemptsAccount,
+		},
+		window:    time.Duration(c.Window) * time.Second,
+		lockout:   time.Duration(c.Lockout) * time.Second,
+		delayStep: defaultDelayStep,
+		attempts:  make(map[limiterKey]*attempts),
+		logger:    logger,
+	}
+}
+
+// IP returns the client IP of the request.
+func (l *Limiter) IP(r *http.Request) string {
+	return ClientIP(r)
+}
+
+// ClientIP returns the client IP of the request. Request headers can be
+// forged by the client and aren't used, the remote address of requests
+// from trusted proxies is replaced by the forwarded client IP before the
+// request reaches the handlers, see web.ProxyHeaders.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (l *Limiter) keys(ip string, username string) []limiterKey {
+	keys := []limiterKey{{LockoutIP, ip}}
+	if username != "" {
+		keys = append(keys, limiterKey{LockoutAccount, username})
+	}
+	return keys
+}
+
+// IPLockedUntil returns the end of the lockout of the
+// IP. Returns the zero time if the IP isn't locked.
+func (l *Limiter) IPLockedUntil(ip string, now time.Time) time.Time {
+	return l.lockedUntil(limiterKey{LockoutIP, ip}, now)
+}
+
+// AccountLockedUntil returns the end of the lockout of the account.
+// Returns the zero time if the account isn't locked. Only failed
+// attempts are rejected as locked, see Limiter.
+func (l *Limiter) AccountLockedUntil(username string, now time.Time) time.Time {
+	return l.lockedUntil(limiterKey{LockoutAccount, username}, now)
+}
+
+func (l *Limiter) lockedUntil(key limiterKey, now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, exist := l.attempts[key]
+	if exist && a.lockedUntil.After(now) {
+		return a.lockedUntil
+	}
+	return time.Time{}
+}
+
+// Delay returns how long a login attempt from the IP should
+// be delayed, based on the failed attempts within the window.
+func (l *Limiter) Delay(ip string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, exist := l.attempts[limiterKey{LockoutIP, ip}]
+	if !exist || now.Sub(a.windowStart) > l.window {
+		return 0
+	}
+	return min(time.Duration(a.failures)*l.delayStep, maxDelay)
+}
+
+// Fail records a failed login attempt.
+func (l *Limiter) Fail(ip string, username string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.attempts) >= limiterPruneSize {
+		l.pruneUnsafe(now)
+	}
+	for len(l.attempts) >= limiterMaxKeys {
+		l.evictOldestUnsafe()
+	}
+
+	for _, key := range l.keys(ip, username) {
+		maxAttempts := l.maxAttempts[key.typ]
+		if maxAttempts < 0 {
+			continue
+		}
+
+		a, exist := l.attempts[key]
+		if !exist || now.Sub(a.windowStart) > l.window {
+			a = &attempts{windowStart: now}
+			l.attempts[key] = a
+		}
+		a.failures++
+
+		if a.failures >= maxAttempts && !a.lockedUntil.After(now) {
+			a.lockedUntil = now.Add(l.lockout)
+			l.logger.Log(log.Entry{
+				Level: log.LevelWarning,
+				Src:   "auth",
+				Msg: fmt.Sprintf("locked %v %v for %v after %v failed login attempts",
+					key.typ, key.key, l.lockout, a.failures),
+			})
+		}
+	}
+}
+
+// Success resets the failed attempts of the account.
+func (l *Limiter) Success(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, limiterKey{LockoutAccount, username})
+}
+
+func (l *Limiter) pruneUnsafe(now time.Time) {
+	for key, a := range l.attempts {
+		if now.Sub(a.windowStart) > l.window && !a.lockedUntil.After(now) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// evictOldestUnsafe removes the key with the oldest window. Bounds
+// the memory if many IPs fail within the window.
+func (l *Limiter) evictOldestUnsafe() {
+	var oldest limiterKey
+	var oldestStart time.Time
+	for key, a := range l.attempts {
+		if oldestStart.IsZero() || a.windowStart.Before(oldestStart) {
+			oldest = key
+			oldestStart = a.windowStart
+		}
+	}
+	delete(l.attempts, oldest)
+}
+
+// Lockouts returns the active lockouts sorted by type and key.
+func (l *Limiter) Lockouts(now time.Time) []Lockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lockouts := []Lockout{}
+	for key, a := range l.attempts {
+		if !a.lockedUntil.After(now) {
+			continue
+		}
+		lockouts = append(lockouts, Lockout{
+			Type:     key.typ,
+			Key:      key.key,
+			Failures: a.failures,
+			Until:    a.lockedUntil,
+		})
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		if lockouts[i].Type != lockouts[j].Type {
+			return lockouts[i].Type < lockouts[j].Type
+		}
+		return lockouts[i].Key < lockouts[j].Key
+	})
+	return lockouts
+}
+
+// ErrInvalidLockoutType invalid lockout type.
+var ErrInvalidLockoutType = errors.New("invalid lockout type")
+
+// Clear removes the lockout and failed attempts of the IP or account.
+// All lockouts are cleared if the type is empty.
+func (l *Limiter) Clear(typ string, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch typ {
+	case "":
+		l.attempts = make(map[limiterKey]*attempts)
+	case LockoutIP, LockoutAccount:
+		delete(l.attempts, limiterKey{typ, key})
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidLockoutType, typ)
+	}
+
+	msg := "cleared all lockouts"
+	if typ != "" {
+		msg = fmt.Sprintf("cleared lockout of %v %v", typ, key)
+	}
+	l.logger.Log(log.Entry{
+		Level: log.LevelInfo,
+		Src:   "auth",
+		Msg:   msg,
+	})
+	return nil
+}
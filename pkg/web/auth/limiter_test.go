@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(maxIP int, maxAccount int) *Limiter {
+	// Logs are discarded.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	return NewLimiter(storage.AuthRateLimit{
+		MaxAttemptsIP:      maxIP,
+		MaxAttemptsAccount: maxAccount,
+		Window:             60,
+		Lockout:            120,
+	}, &log.Logger{Ctx: ctx})
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	t.Run("ip", func(t *testing.T) {
+		l := newTestLimiter(3, -1)
+		l.Fail("1", "a", now)
+		l.Fail("1", "b", now)
+		require.True(t, l.IPLockedUntil("1", now).IsZero())
+
+		l.Fail("1", "c", now)
+		require.Equal(t, now.Add(120*time.Second), l.IPLockedUntil("1", now))
+		require.True(t, l.IPLockedUntil("2", now).IsZero())
+		require.True(t, l.AccountLockedUntil("c", now).IsZero())
+
+		// Expired.
+		require.True(t, l.IPLockedUntil("1", now.Add(121*time.Second)).IsZero())
+
+		expected := []Lockout{{
+			Type:     LockoutIP,
+			Key:      "1",
+			Failures: 3,
+			Until:    now.Add(120 * time.Second),
+		}}
+		require.Equal(t, expected, l.Lockouts(now))
+	})
+	t.Run("account", func(t *testing.T) {
+		l := newTestLimiter(-1, 2)
+		l.Fail("1", "a", now)
+		l.Fail("2", "a", now)
+		require.False(t, l.AccountLockedUntil("a", now).IsZero())
+		require.True(t, l.AccountLockedUntil("b", now).IsZero())
+		require.True(t, l.IPLockedUntil("3", now).IsZero())
+	})
+	t.Run("window", func(t *testing.T) {
+		l := newTestLimiter(2, -1)
+		l.Fail("1", "a", now)
+		l.Fail("1", "a", now.Add(61*time.Second))
+		require.True(t, l.IPLockedUntil("1", now.Add(61*time.Second)).IsZero())
+	})
+	t.Run("success", func(t *testing.T) {
+		l := newTestLimiter(-1, 2)
+		l.Fail("1", "a", now)
+		l.Success("a")
+		l.Fail("1", "a", now)
+		require.True(t, l.AccountLockedUntil("a", now).IsZero())
+	})
+	t.Run("delay", func(t *testing.T) {
+		l := newTestLimiter(100, -1)
+		require.Equal(t, time.Duration(0), l.Delay("1", now))
+
+		l.Fail("1", "a", now)
+		l.Fail("1", "a", now)
+		require.Equal(t, 2*defaultDelayStep, l.Delay("1", now))
+		require.Equal(t, time.Duration(0), l.Delay("2", now))
+
+		for i := 0; i < 50; i++ {
+			l.Fail("1", "a", now)
+		}
+		require.Equal(t, maxDelay, l.Delay("1", now))
+
+		// Expired.
+		require.Equal(t, time.Duration(0), l.Delay("1", now.Add(61*time.Second)))
+	})
+	t.Run("clear", func(t *testing.T) {
+		l := newTestLimiter(1, 1)
+		l.Fail("1", "a", now)
+		l.Fail("2", "b", now)
+		require.Len(t, l.Lockouts(now), 4)
+
+		require.NoError(t, l.Clear(LockoutAccount, "a"))
+		require.Len(t, l.Lockouts(now), 3)
+
+		require.ErrorIs(t, l.Clear("x", "a"), ErrInvalidLockoutType)
+
+		require.NoError(t, l.Clear("", ""))
+		require.Empty(t, l.Lockouts(now))
+	})
+	t.Run("prune", func(t *testing.T) {
+		l := newTestLimiter(100, -1)
+		for i := 0; i < limiterPruneSize; i++ {
+			l.Fail(string(rune(i)), "", now)
+		}
+		l.Fail("x", "", now.Add(61*time.Second))
+		require.Len(t, l.attempts, 1)
+	})
+	t.Run("maxKeys", func(t *testing.T) {
+		l := newTestLimiter(100, -1)
+		for i := 0; i < limiterMaxKeys+10; i++ {
+			l.Fail(strconv.Itoa(i), "", now.Add(time.Duration(i)*time.Millisecond))
+		}
+		require.Len(t, l.attempts, limiterMaxKeys)
+
+		// The oldest were evicted.
+		require.Equal(t, time.Duration(0), l.Delay("0", now))
+		require.Equal(t, defaultDelayStep, l.Delay(strconv.Itoa(limiterMaxKeys+9), now))
+	})
+}
+
+func TestLimiterIP(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "1.2.3.4:5",
+		Header:     http.Header{"X-Forwarded-For": []string{"6.7.8.9, 1.2.3.4"}},
+	}
+	// The forwarding headers are applied by web.ProxyHeaders.
+	require.Equal(t, "1.2.3.4", newTestLimiter(1, 1).IP(r))
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"nvr/pkg/storage"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password errors.
+var (
+	ErrPasswordPolicy    = errors.New("password does not meet the requirements")
+	ErrWrongPassword     = errors.New("wrong password")
+	ErrPasswordUnchanged = errors.New("new password must be different from the current password")
+)
+
+// CheckPassword returns an error describing the
+// requirements the password does not meet.
+func CheckPassword(policy storage.PasswordPolicy, password string) error {
+	var missing []string
+	if length := utf8.RuneCountInString(password); length < policy.MinLength {
+		missing = append(missing, fmt.Sprintf("at least %v characters", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		missing = append(missing, "a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		missing = append(missing, "a symbol")
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("%w: must contain %v", ErrPasswordPolicy, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// PasswordChangeHeader is set on the responses of requests
+// that were blocked because the user must change password.
+const PasswordChangeHeader = "X-Password-Change-Required"
+
+// Paths that users who must change their password can access.
+var passwordChangePaths = []string{
+	"/password",
+	"/api/user/password",
+	"/logout",
+}
+
+// PasswordChangeAllowed returns true if a user that must
+// change their password can access the request path.
+func PasswordChangeAllowed(path string) bool {
+	if strings.HasPrefix(path, "/static/") {
+		return true
+	}
+	for _, p := range passwordChangePaths {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RespondPasswordChange redirects page requests to the
+// password change page and blocks all other requests.
+func RespondPasswordChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(PasswordChangeHeader, "true")
+	isPage := !strings.HasPrefix(r.URL.Path, "/api/") &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+	if r.Method == http.MethodGet && isPage {
+		// Relative to keep the base path, http.Redirect would make it absolute.
+		w.Header().Set("Location", "password")
+		w.WriteHeader(http.StatusSeeOther)
+		return
+	}
+	http.Error(w, "password must be changed", http.StatusForbidden)
+}
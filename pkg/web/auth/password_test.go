@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package auth
+
+import (
+	"testing"
+
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPassword(t *testing.T) {
+	policy := storage.PasswordPolicy{
+		MinLength:     8,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+	cases := map[string]struct {
+		password string
+		err      string
+	}{
+		"ok":       {"Passw0rd!", ""},
+		"unicode":  {"Pässwörd1!", ""},
+		"short":    {"Pa0!", "must contain at least 8 characters"},
+		"upper":    {"passw0rd!", "must contain an uppercase letter"},
+		"lower":    {"PASSW0RD!", "must contain a lowercase letter"},
+		"digit":    {"Password!", "must contain a digit"},
+		"symbol":   {"Passw0rds", "must contain a symbol"},
+		"multiple": {"pass", "at least 8 characters, an uppercase letter, a digit, a symbol"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckPassword(policy, tc.password)
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrPasswordPolicy)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+	t.Run("noRequirements", func(t *testing.T) {
+		require.NoError(t, CheckPassword(storage.PasswordPolicy{}, "a"))
+	})
+}
+
+func TestPasswordChangeAllowed(t *testing.T) {
+	require.True(t, PasswordChangeAllowed("/password"))
+	require.True(t, PasswordChangeAllowed("/api/user/password"))
+	require.True(t, PasswordChangeAllowed("/static/style/style.css"))
+	require.False(t, PasswordChangeAllowed("/live"))
+	require.False(t, PasswordChangeAllowed("/api/user/set"))
+}
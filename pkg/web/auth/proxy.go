@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"nvr/pkg/storage"
+	"slices"
+	"strings"
+)
+
+type trustedProxyKey struct{}
+
+// WithTrustedProxy marks the request as forwarded by a trusted proxy.
+// The remote address is replaced by the client IP before the request
+// is authenticated, the mark is the only way to tell them apart.
+func WithTrustedProxy(r *http.Request) *http.Request {
+	ctx := context.WithValue(r.Context(), trustedProxyKey{}, true)
+	return r.WithContext(ctx)
+}
+
+// FromTrustedProxy returns true if the request was forwarded by a trusted proxy.
+func FromTrustedProxy(r *http.Request) bool {
+	trusted, _ := r.Context().Value(trustedProxyKey{}).(bool)
+	return trusted
+}
+
+// ProxyUser returns the username and groups that the proxy set in the request
+// headers. The headers are ignored if proxy auth is disabled or the request
+// isn't from a trusted proxy, anyone could set them on direct requests.
+func ProxyUser(c storage.ProxyAuth, r *http.Request) (string, []string, bool) {
+	if !c.Enable || !FromTrustedProxy(r) {
+		return "", nil, false
+	}
+	username := strings.TrimSpace(r.Header.Get(c.UserHeader))
+	if username == "" {
+		return "", nil, false
+	}
+	var groups []string
+	for _, group := range strings.Split(r.Header.Get(c.GroupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return username, groups, true
+}
+
+// ProxyRole maps the groups of a proxy user to a role. The user is
+// allowed if there are no user groups, the user is in a user group
+// or is an admin. The user is an admin if in any of the admin groups.
+func ProxyRole(c storage.ProxyAuth, groups []string) (allowed bool, isAdmin bool) {
+	inAny := func(names []string) bool {
+		for _, group := range groups {
+			if slices.Contains(names, group) {
+				return true
+			}
+		}
+		return false
+	}
+	isAdmin = inAny(c.AdminGroups)
+	allowed = len(c.UserGroups) == 0 || inAny(c.UserGroups) || isAdmin
+	return allowed, isAdmin
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyUser(t *testing.T) {
+	config := storage.ProxyAuth{
+		Enable:       true,
+		UserHeader:   "Remote-User",
+		GroupsHeader: "Remote-Groups",
+	}
+	newRequest := func(trusted bool, headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		if trusted {
+			r = WithTrustedProxy(r)
+		}
+		return r
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		r := newRequest(true, map[string]string{
+			"Remote-User":   "a",
+			"Remote-Groups": "admins, nvr,,",
+		})
+		username, groups, ok := ProxyUser(config, r)
+		require.True(t, ok)
+		require.Equal(t, "a", username)
+		require.Equal(t, []string{"admins", "nvr"}, groups)
+	})
+	t.Run("noGroups", func(t *testing.T) {
+		r := newRequest(true, map[string]string{"Remote-User": "a"})
+		username, groups, ok := ProxyUser(config, r)
+		require.True(t, ok)
+		require.Equal(t, "a", username)
+		require.Empty(t, groups)
+	})
+	t.Run("untrusted", func(t *testing.T) {
+		r := newRequest(false, map[string]string{"Remote-User": "a"})
+		_, _, ok := ProxyUser(config, r)
+		require.False(t, ok)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		r := newRequest(true, map[string]string{"Remote-User": "a"})
+		_, _, ok := ProxyUser(storage.ProxyAuth{UserHeader: "Remote-User"}, r)
+		require.False(t, ok)
+	})
+	t.Run("noUser", func(t *testing.T) {
+		r := newRequest(true, map[string]string{"Remote-Groups": "admins"})
+		_, _, ok := ProxyUser(config, r)
+		require.False(t, ok)
+	})
+}
+
+func TestProxyRole(t *testing.T) {
+	config := storage.ProxyAuth{
+		AdminGroups: []string{"admins"},
+		UserGroups:  []string{"nvr"},
+	}
+	cases := map[string]struct {
+		config  storage.ProxyAuth
+		groups  []string
+		allowed bool
+		isAdmin bool
+	}{
+		"admin":       {config, []string{"admins"}, true, true},
+		"user":        {config, []string{"x", "nvr"}, true, false},
+		"other":       {config, []string{"x"}, false, false},
+		"none":        {config, nil, false, false},
+		"noUserGroup": {storage.ProxyAuth{}, []string{"x"}, true, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			allowed, isAdmin := ProxyRole(tc.config, tc.groups)
+			require.Equal(t, tc.allowed, allowed)
+			require.Equal(t, tc.isAdmin, isAdmin)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"nvr/pkg/storage"
+	"strconv"
+	"strings"
+)
+
+// Methods and request headers allowed in cross-origin requests.
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE"
+	corsAllowedHeaders = "Authorization, Content-Type, X-CSRF-Token"
+)
+
+// CORS adds the CORS headers to API requests from the allowed origins and
+// answers preflight requests. Preflight requests from other origins are
+// rejected, their other requests are passed on without the headers so
+// the browser doesn't expose the response. Only paths under "/api/" are
+// affected. Nothing is changed if there are no allowed origins.
+func CORS(c storage.CORS, h http.Handler) http.Handler {
+	if len(c.AllowedOrigins) == 0 {
+		return h
+	}
+	allowAny := false
+	allowed := make(map[string]bool)
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		// Validated and normalized by storage.NewConfigEnv.
+		allowed[origin] = true
+	}
+	maxAge := strconv.Itoa(c.MaxAge)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// The response depends on the origin.
+		w.Header().Add("Vary", "Origin")
+
+		isPreflight := r.Method == http.MethodOptions &&
+			r.Header.Get("Access-Control-Request-Method") != ""
+		if !allowAny && !allowed[strings.ToLower(origin)] {
+			if isPreflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		if allowAny {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if isPreflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			header.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
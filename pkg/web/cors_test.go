@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nvr/pkg/storage"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok")) //nolint:errcheck
+	})
+	serve := func(h http.Handler, method, target, origin string, preflight bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if preflight {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	h := CORS(storage.CORS{
+		AllowedOrigins:   []string{"https://a.example.com"},
+		AllowCredentials: true,
+		MaxAge:           60,
+	}, next)
+
+	t.Run("allowed", func(t *testing.T) {
+		w := serve(h, http.MethodGet, "/api/monitor/list", "https://a.example.com", false)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "ok", w.Body.String())
+		require.Equal(t, "https://a.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		require.Equal(t, "Origin", w.Header().Get("Vary"))
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+	t.Run("preflight", func(t *testing.T) {
+		w := serve(h, http.MethodOptions, "/api/monitor/restart", "https://A.example.com", true)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Empty(t, w.Body.String())
+		require.Equal(t, "https://A.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		require.Equal(t, corsAllowedMethods, w.Header().Get("Access-Control-Allow-Methods"))
+		require.Equal(t, corsAllowedHeaders, w.Header().Get("Access-Control-Allow-Headers"))
+		require.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"))
+	})
+	t.Run("notAllowed", func(t *testing.T) {
+		w := serve(h, http.MethodGet, "/api/monitor/list", "https://b.example.com", false)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+		w = serve(h, http.MethodOptions, "/api/monitor/list", "https://b.example.com", true)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+	t.Run("notAPI", func(t *testing.T) {
+		w := serve(h, http.MethodGet, "/live", "https://a.example.com", false)
+		require.Equal(t, "ok", w.Body.String())
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+	t.Run("sameOrigin", func(t *testing.T) {
+		w := serve(h, http.MethodGet, "/api/monitor/list", "", false)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		require.Empty(t, w.Header().Get("Vary"))
+	})
+	t.Run("any", func(t *testing.T) {
+		h := CORS(storage.CORS{AllowedOrigins: []string{"*"}}, next)
+		w := serve(h, http.MethodGet, "/api/monitor/list", "https://b.example.com", false)
+		require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		w := serve(CORS(storage.CORS{}, next),
+			http.MethodOptions, "/api/monitor/list", "https://b.example.com", true)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
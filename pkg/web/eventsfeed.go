@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nvr/pkg/feed"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Events feed message types.
+const (
+	FeedTypeMonitor = "monitor"
+	FeedTypeEvent   = "event"
+	FeedTypeStorage = "storage"
+	FeedTypeLog     = "log"
+)
+
+// FeedMessage is a message on the events feed. One of State,
+// Event, Storage and Log is set depending on the type.
+type FeedMessage struct {
+	Type      string    `json:"type"`
+	MonitorID string    `json:"monitorId,omitempty"`
+	Time      time.Time `json:"time"`
+
+	State     string `json:"state,omitempty"`
+	Input     string `json:"input,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+
+	Event   *monitor.LiveEvent `json:"event,omitempty"`
+	Storage *storage.DiskAlert `json:"storage,omitempty"`
+	Log     *log.Entry         `json:"log,omitempty"`
+}
+
+// EventsFeedSources are the feeds that are multiplexed by the events feed.
+type EventsFeedSources struct {
+	States  *feed.Buffer[monitor.MonitorState]
+	Events  *feed.Buffer[monitor.LiveEvent]
+	Storage *feed.Buffer[storage.DiskAlert]
+	Logs    *feed.Buffer[log.Entry]
+}
+
+// ForwardEventsFeed forwards the sources to the events feed until
+// ctx is canceled. Only error log entries are forwarded.
+func ForwardEventsFeed(ctx context.Context, dst *feed.Buffer[FeedMessage], src EventsFeedSources) {
+	go feed.Forward(ctx, src.States, dst, func(s monitor.MonitorState) (FeedMessage, bool) {
+		return FeedMessage{
+			Type:      FeedTypeMonitor,
+			MonitorID: s.MonitorID,
+			Time:      s.Time,
+			State:     s.State,
+			Input:     s.Input,
+			LastError: s.LastError,
+		}, true
+	})
+	go feed.Forward(ctx, src.Events, dst, func(e monitor.LiveEvent) (FeedMessage, bool) {
+		return FeedMessage{
+			Type:      FeedTypeEvent,
+			MonitorID: e.MonitorID,
+			Time:      e.Time,
+			Event:     &e,
+		}, true
+	})
+	go feed.Forward(ctx, src.Storage, dst, func(a storage.DiskAlert) (FeedMessage, bool) {
+		return FeedMessage{
+			Type:    FeedTypeStorage,
+			Time:    a.Time,
+			Storage: &a,
+		}, true
+	})
+	go feed.Forward(ctx, src.Logs, dst, func(e log.Entry) (FeedMessage, bool) {
+		return FeedMessage{
+			Type:      FeedTypeLog,
+			MonitorID: e.MonitorID,
+			Time:      e.GetTime(),
+			Log:       &e,
+		}, e.Level == log.LevelError
+	})
+}
+
+// EventsFeed opens a websocket that multiplexes monitor starts and
+// stops, monitor events, disk alerts and error logs. Optional types
+// and monitors query parameters are comma separated lists. Log
+// messages are only sent to admins. Optional cursor query
+// parameter resumes the feed after that message.
+func EventsFeed(history *feed.Buffer[FeedMessage], a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		match, err := parseEventsFeedQuery(r.URL.Query(), eventsFeedIsAdmin(a, r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cursor, err := parseFeedCursor(r, history.Cursor())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		serveFeedWebsocket(r.Context(), c, history, cursor, match, func() bool {
+			return a.ValidateRequest(r).IsValid
+		}, newEventsFeedMessage)
+	})
+}
+
+// EventsFeedPoll is the fallback of EventsFeed for
+// clients where websockets are blocked. See serveFeedFallback.
+func EventsFeedPoll(history *feed.Buffer[FeedMessage], a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		match, err := parseEventsFeedQuery(r.URL.Query(), eventsFeedIsAdmin(a, r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		serveFeedFallback(w, r, history, match, func() bool {
+			return a.ValidateRequest(r).IsValid
+		}, newEventsFeedMessage)
+	})
+}
+
+// The request is validated again for each log message,
+// the user may be demoted while the feed is open.
+func eventsFeedIsAdmin(a auth.Authenticator, r *http.Request) func() bool {
+	return func() bool {
+		auth := a.ValidateRequest(r)
+		return auth.IsValid && auth.User.IsAdmin
+	}
+}
+
+// ErrInvalidFeedType invalid events feed type.
+var ErrInvalidFeedType = errors.New("invalid type")
+
+// parseEventsFeedQuery returns the message filter. The monitors
+// filter doesn't apply to messages without a monitor ID.
+func parseEventsFeedQuery(query url.Values, isAdmin func() bool) (func(FeedMessage) bool, error) {
+	types := parseCSVParam(query, "types")
+	for _, t := range types {
+		switch t {
+		case FeedTypeMonitor, FeedTypeEvent, FeedTypeStorage, FeedTypeLog:
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFeedType, t)
+		}
+	}
+	monitors := parseCSVParam(query, "monitors")
+
+	return func(msg FeedMessage) bool {
+		if !log.StringInStrings(msg.Type, types) {
+			return false
+		}
+		if msg.MonitorID != "" && !log.StringInStrings(msg.MonitorID, monitors) {
+			return false
+		}
+		return msg.Type != FeedTypeLog || isAdmin()
+	}, nil
+}
+
+type eventsFeedMessage struct {
+	FeedMessage
+	Cursor uint64 `json:"cursor"`
+}
+
+func newEventsFeedMessage(item feed.Item[FeedMessage]) interface{} {
+	return eventsFeedMessage{FeedMessage: item.Value, Cursor: item.Cursor}
+}
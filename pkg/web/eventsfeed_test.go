@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"nvr/pkg/feed"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventsFeedQuery(t *testing.T) {
+	isAdmin := false
+	query := url.Values{
+		"types":    []string{"monitor,storage,log"},
+		"monitors": []string{"a"},
+	}
+	match, err := parseEventsFeedQuery(query, func() bool { return isAdmin })
+	require.NoError(t, err)
+
+	require.True(t, match(FeedMessage{Type: FeedTypeMonitor, MonitorID: "a"}))
+	require.False(t, match(FeedMessage{Type: FeedTypeMonitor, MonitorID: "b"}))
+	require.False(t, match(FeedMessage{Type: FeedTypeEvent, MonitorID: "a"}))
+	require.True(t, match(FeedMessage{Type: FeedTypeStorage}))
+
+	// Logs are only sent to admins.
+	require.False(t, match(FeedMessage{Type: FeedTypeLog}))
+	isAdmin = true
+	require.True(t, match(FeedMessage{Type: FeedTypeLog}))
+	require.False(t, match(FeedMessage{Type: FeedTypeLog, MonitorID: "b"}))
+
+	_, err = parseEventsFeedQuery(url.Values{"types": []string{"x"}}, nil)
+	require.ErrorIs(t, err, ErrInvalidFeedType)
+}
+
+func TestForwardEventsFeed(t *testing.T) {
+	src := EventsFeedSources{
+		States:  feed.NewBuffer[monitor.MonitorState](4),
+		Events:  feed.NewBuffer[monitor.LiveEvent](4),
+		Storage: feed.NewBuffer[storage.DiskAlert](4),
+		Logs:    feed.NewBuffer[log.Entry](4),
+	}
+	dst := feed.NewBuffer[FeedMessage](8)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ForwardEventsFeed(ctx, dst, src)
+	time.Sleep(10 * time.Millisecond)
+
+	src.States.Push(monitor.MonitorState{MonitorID: "a", State: monitor.MonitorStarted})
+	src.Logs.Push(log.Entry{Level: log.LevelInfo})
+	src.Logs.Push(log.Entry{Level: log.LevelError, MonitorID: "b"})
+	src.Storage.Push(storage.DiskAlert{Level: storage.DiskAlertHigh})
+	src.Events.Push(monitor.LiveEvent{MonitorID: "c"})
+
+	types := make(map[string]string)
+	var cursor uint64
+	for len(types) < 4 {
+		items := dst.Wait(ctx, cursor)
+		require.NoError(t, ctx.Err())
+		for _, item := range items {
+			cursor = item.Cursor
+			types[item.Value.Type] = item.Value.MonitorID
+		}
+	}
+	expected := map[string]string{
+		FeedTypeMonitor: "a",
+		FeedTypeLog:     "b",
+		FeedTypeStorage: "",
+		FeedTypeEvent:   "c",
+	}
+	require.Equal(t, expected, types)
+	require.Equal(t, uint64(4), dst.Cursor())
+}
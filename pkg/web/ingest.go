@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"nvr/pkg/ingest"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/web/auth"
+	"strings"
+	"time"
+)
+
+// IngestPresignResponse is the issued upload token and the token string.
+// Path is the upload URL relative to the base path, "ingest/<token>".
+type IngestPresignResponse struct {
+	UploadToken ingest.UploadToken `json:"uploadToken"`
+	Token       string             `json:"token"`
+	Path        string             `json:"path"`
+}
+
+// IngestPresign handler to issue a upload token for a monitor.
+func IngestPresign(
+	i *ingest.Manager,
+	m *monitor.Manager,
+	a auth.Authenticator,
+	logger log.ILogger,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ingest.PresignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, exist := m.MonitorConfigs()[req.MonitorID]; !exist {
+			http.Error(w, fmt.Sprintf("%v: %q", monitor.ErrMonitorNotExist, req.MonitorID),
+				http.StatusNotFound)
+			return
+		}
+
+		username := a.ValidateRequest(r).User.Username
+		uploadToken, token, err := i.Presign(req, username)
+		switch {
+		case errors.Is(err, ingest.ErrInvalidRequest):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Log(log.Entry{
+			Level:     log.LevelInfo,
+			Src:       "auth",
+			MonitorID: uploadToken.MonitorID,
+			Msg: fmt.Sprintf("upload token %v issued by %v, expires %v",
+				uploadToken.ID, username, uploadToken.Expires.Format(time.RFC3339)),
+		})
+
+		w.Header().Set("Content-Type", jsonContentType)
+		res := IngestPresignResponse{
+			UploadToken: *uploadToken,
+			Token:       token,
+			Path:        "ingest/" + token,
+		}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// IngestTokens handler returns the issued upload tokens,
+// including the expired and revoked tokens.
+func IngestTokens(i *ingest.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		tokens, err := i.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(tokens); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// IngestRevoke handler to revoke a upload token.
+func IngestRevoke(i *ingest.Manager, a auth.Authenticator, logger log.ILogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		username := a.ValidateRequest(r).User.Username
+		err := i.Revoke(id, username)
+		switch {
+		case errors.Is(err, ingest.ErrTokenNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Log(log.Entry{
+			Level: log.LevelInfo,
+			Src:   "auth",
+			Msg:   fmt.Sprintf("upload token %v revoked by %v", id, username),
+		})
+	})
+}
+
+// IngestUploadResponse is the ID of the uploaded recording.
+type IngestUploadResponse struct {
+	ID string `json:"id"`
+}
+
+// Size of the metadata part and the multipart headers.
+const maxIngestMetadataSize = 1000 * 1000
+
+// Ingest handler accepts recordings uploaded with a token without
+// login, "POST /ingest/<token>". The body is a multipart form with
+// the "metadata" JSON part first and then the MP4 "video" part.
+func Ingest(i *ingest.Manager, logger log.ILogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimPrefix(r.URL.Path, "/ingest/")
+		upload, err := i.Begin(token)
+		switch {
+		case errors.Is(err, ingest.ErrInvalidToken):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, ingest.ErrExpired), errors.Is(err, ingest.ErrRevoked):
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		case errors.Is(err, ingest.ErrTotalSize):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer upload.Release()
+
+		r.Body = http.MaxBytesReader(w, r.Body, upload.MaxSize+maxIngestMetadataSize)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		part, err := reader.NextPart()
+		if err != nil || part.FormName() != "metadata" {
+			http.Error(w, "metadata must be the first part", http.StatusBadRequest)
+			return
+		}
+		var data ingest.Metadata
+		err = json.NewDecoder(io.LimitReader(part, maxIngestMetadataSize)).Decode(&data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("decode metadata: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		part, err = reader.NextPart()
+		if err != nil || part.FormName() != "video" {
+			http.Error(w, "video part missing", http.StatusBadRequest)
+			return
+		}
+
+		recID, err := upload.Save(r.Context(), data, part)
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			http.Error(w, fmt.Sprintf("video is larger than %v bytes", upload.MaxSize),
+				http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, ingest.ErrInvalidMetadata), errors.Is(err, ingest.ErrInvalidVideo):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, monitor.ErrMonitorNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, ingest.ErrRecordingExist):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Log(log.Entry{
+			Level:     log.LevelInfo,
+			Src:       "recorder",
+			MonitorID: upload.Token.MonitorID,
+			Msg:       fmt.Sprintf("recording uploaded: %v", recID),
+		})
+
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(IngestUploadResponse{ID: recID}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr/pkg/ingest"
+	"nvr/pkg/kv"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIngests(t *testing.T, recordingsDir string) *ingest.Manager {
+	t.Helper()
+	db, err := kv.Open(filepath.Join(t.TempDir(), "nvr.db"))
+	require.NoError(t, err)
+	ingests, err := ingest.NewManager(db, func(id string) (string, error) {
+		if id != "x" {
+			return "", monitor.ErrMonitorNotExist
+		}
+		return recordingsDir, nil
+	}, "false", false)
+	require.NoError(t, err)
+	return ingests
+}
+
+func TestIngestPresign(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{"id": "x"}))
+
+	ingests := newTestIngests(t, t.TempDir())
+	h := IngestPresign(ingests, m, stubAuth{user: auth.Account{Username: "admin"}}, log.NewDummyLogger())
+	serve := func(method string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/", strings.NewReader(body)))
+		return w
+	}
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, `{"monitorId":"y"}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `{"monitorId":"x","maxSize":-1}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `{`).Code)
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, `{"monitorId":"x"}`).Code)
+
+	w := serve(http.MethodPost, `{"monitorId":"x","maxSize":10}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var res IngestPresignResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Equal(t, "ingest/"+res.Token, res.Path)
+	require.Equal(t, "x", res.UploadToken.MonitorID)
+	require.Equal(t, "admin", res.UploadToken.CreatedBy)
+	require.Equal(t, int64(10*1000*1000), res.UploadToken.MaxSize)
+
+	t.Run("tokens", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		IngestTokens(ingests).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var tokens []ingest.UploadToken
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&tokens))
+		require.Len(t, tokens, 1)
+		require.Equal(t, res.UploadToken.ID, tokens[0].ID)
+	})
+	t.Run("revoke", func(t *testing.T) {
+		revoke := IngestRevoke(ingests, stubAuth{user: auth.Account{Username: "admin"}}, log.NewDummyLogger())
+		serve := func(id string) int {
+			w := httptest.NewRecorder()
+			revoke.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/?id="+id, nil))
+			return w.Code
+		}
+		require.Equal(t, http.StatusBadRequest, serve(""))
+		require.Equal(t, http.StatusNotFound, serve("nil"))
+		require.Equal(t, http.StatusOK, serve(res.UploadToken.ID))
+		_, err := ingests.Validate(res.Token)
+		require.ErrorIs(t, err, ingest.ErrRevoked)
+	})
+}
+
+func TestIngest(t *testing.T) {
+	ingests := newTestIngests(t, t.TempDir())
+	h := Ingest(ingests, log.NewDummyLogger())
+
+	_, token, err := ingests.Presign(ingest.PresignRequest{MonitorID: "x", MaxSize: 1}, "admin")
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	upload := func(token string, metadata ingest.Metadata, video []byte) int {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormField("metadata")
+		require.NoError(t, err)
+		require.NoError(t, json.NewEncoder(part).Encode(metadata))
+		part, err = form.CreateFormFile("video", "clip.mp4")
+		require.NoError(t, err)
+		_, err = part.Write(video)
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+
+		r := httptest.NewRequest(http.MethodPost, "/ingest/"+token, &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	metadata := ingest.Metadata{Start: start, End: start.Add(time.Minute)}
+	mp4 := []byte("\x00\x00\x00\x08ftypmdat")
+
+	require.Equal(t, http.StatusCreated, upload(token, metadata, mp4))
+	require.Equal(t, http.StatusConflict, upload(token, metadata, mp4))
+	require.Equal(t, http.StatusNotFound, upload(token+"x", metadata, mp4))
+
+	metadata.Start = start.Add(time.Minute)
+	metadata.End = start.Add(2 * time.Minute)
+	require.Equal(t, http.StatusBadRequest, upload(token, metadata, []byte("abcdefghij")))
+	require.Equal(t, http.StatusBadRequest, upload(token, ingest.Metadata{}, mp4))
+
+	tooLarge := append(append([]byte{}, mp4...), make([]byte, 2*1000*1000)...)
+	require.Equal(t, http.StatusRequestEntityTooLarge, upload(token, metadata, tooLarge))
+
+	uploadToken, err := ingests.Validate(token)
+	require.NoError(t, err)
+	require.Equal(t, 1, uploadToken.Uses)
+	require.Equal(t, int64(len(mp4)), uploadToken.BytesUsed)
+
+	t.Run("monitorDeleted", func(t *testing.T) {
+		_, token, err := ingests.Presign(ingest.PresignRequest{MonitorID: "y"}, "admin")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, upload(token, metadata, mp4))
+	})
+}
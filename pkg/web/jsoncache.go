@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// jsonCache caches the JSON encoding of a value until its version
+// changes. The ETag is a hash of the encoding, it stays valid across
+// restarts since the version counter does not.
+type jsonCache struct {
+	body    []byte
+	etag    string
+	version uint64
+	valid   bool
+	mu      sync.Mutex
+}
+
+// get returns the cached encoding, or calls value and encodes
+// it if the version has changed. The version must be read
+// before value is called, otherwise an old value could be
+// cached as the new version.
+func (c *jsonCache) get(version uint64, value func() any) ([]byte, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid && c.version == version {
+		return c.body, c.etag, nil
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(value()); err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(body.Bytes())
+
+	c.body = body.Bytes()
+	c.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.version = version
+	c.valid = true
+	return c.body, c.etag, nil
+}
+
+// serve writes the cached value, or 304 Not Modified if
+// the If-None-Match header matches the ETag.
+func (c *jsonCache) serve(
+	w http.ResponseWriter,
+	r *http.Request,
+	version uint64,
+	value func() any,
+) {
+	body, etag, err := c.get(version, value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if checkIfNoneMatch(w, r) == condFalse {
+		writeNotModified(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Write(body) //nolint:errcheck
+}
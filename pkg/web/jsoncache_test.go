@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONCache(t *testing.T) {
+	var cache jsonCache
+	calls := 0
+	value := func(v string) func() any {
+		return func() any {
+			calls++
+			return map[string]string{"a": v}
+		}
+	}
+
+	body, etag, err := cache.get(1, value("x"))
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\":\"x\"}\n", string(body))
+	require.Equal(t, 1, calls)
+
+	body2, etag2, err := cache.get(1, value("y"))
+	require.NoError(t, err)
+	require.Equal(t, body, body2)
+	require.Equal(t, etag, etag2)
+	require.Equal(t, 1, calls)
+
+	body3, etag3, err := cache.get(2, value("y"))
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\":\"y\"}\n", string(body3))
+	require.NotEqual(t, etag, etag3)
+	require.Equal(t, 2, calls)
+
+	// Same value, new version.
+	_, etag4, err := cache.get(3, value("y"))
+	require.NoError(t, err)
+	require.Equal(t, etag3, etag4)
+
+	_, _, err = cache.get(4, func() any { return func() {} })
+	require.Error(t, err)
+}
+
+func TestJSONCacheServe(t *testing.T) {
+	var cache jsonCache
+	value := func() any { return []int{1} }
+
+	serve := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		cache.serve(w, r, 1, value)
+		return w
+	}
+
+	w := serve("")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "[1]\n", w.Body.String())
+	require.Equal(t, jsonContentType, w.Header().Get("Content-Type"))
+	etag := w.Header().Get("Etag")
+	require.NotEmpty(t, etag)
+
+	w = serve(etag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, etag, w.Header().Get("Etag"))
+
+	w = serve(`"x", W/` + etag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = serve(`"x"`)
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,310 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nvr/pkg/log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// List parameters shared by the list endpoints.
+//
+//	fields=a,b      Only include these fields in each item.
+//	sort=a,-b       Sort by fields, "-" prefix sorts descending.
+//	page[size]=100  Number of items per page.
+//	page[cursor]=x  The "next" cursor of the previous page.
+//
+// If any of the parameters are set the response is
+// a listPage instead of the endpoint's default format.
+const (
+	listFieldsParam     = "fields"
+	listSortParam       = "sort"
+	listPageSizeParam   = "page[size]"
+	listPageCursorParam = "page[cursor]"
+
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// List parameter errors.
+var (
+	ErrInvalidFields   = errors.New("invalid fields")
+	ErrInvalidSort     = errors.New("invalid sort")
+	ErrInvalidPageSize = errors.New("invalid page size")
+)
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+func (k sortKey) String() string {
+	if k.desc {
+		return "-" + k.field
+	}
+	return k.field
+}
+
+type listQuery struct {
+	fields   []string
+	sort     []sortKey
+	pageSize int
+	cursor   string
+
+	// True if any of the list parameters are set.
+	set bool
+}
+
+// parseListQuery parses the list parameters. If sortable is specified,
+// the sort is limited to a single key from sortable, for example "-time".
+// Endpoints backed by a store that can only be read in a few orders use
+// this, the cursor is then interpreted by the endpoint instead of paginate.
+func parseListQuery(query url.Values, sortable ...string) (listQuery, error) {
+	var q listQuery
+	for _, param := range []string{
+		listFieldsParam, listSortParam, listPageSizeParam, listPageCursorParam,
+	} {
+		if query.Has(param) {
+			q.set = true
+		}
+	}
+	if !q.set {
+		return q, nil
+	}
+
+	for _, field := range parseCSVParam(query, listFieldsParam) {
+		if field == "" {
+			return listQuery{}, fmt.Errorf("%w: empty field", ErrInvalidFields)
+		}
+		q.fields = append(q.fields, field)
+	}
+
+	for _, key := range parseCSVParam(query, listSortParam) {
+		field, desc := strings.CutPrefix(key, "-")
+		if field == "" {
+			return listQuery{}, fmt.Errorf("%w: empty field", ErrInvalidSort)
+		}
+		q.sort = append(q.sort, sortKey{field: field, desc: desc})
+	}
+	if len(sortable) != 0 && len(q.sort) != 0 {
+		if len(q.sort) != 1 || !log.StringInStrings(q.sort[0].String(), sortable) {
+			return listQuery{}, fmt.Errorf("%w: %q, must be one of %v",
+				ErrInvalidSort, query.Get(listSortParam), strings.Join(sortable, ","))
+		}
+	}
+
+	q.pageSize = defaultPageSize
+	if size := query.Get(listPageSizeParam); size != "" {
+		var err error
+		q.pageSize, err = strconv.Atoi(size)
+		if err != nil || q.pageSize < 1 || q.pageSize > maxPageSize {
+			return listQuery{}, fmt.Errorf("%w: %q, must be between 1 and %v",
+				ErrInvalidPageSize, size, maxPageSize)
+		}
+	}
+	q.cursor = query.Get(listPageCursorParam)
+
+	return q, nil
+}
+
+// sortedBy returns true if the first sort key equals key. The
+// default is used if the sort is unset, for example:
+// q.sortedBy("id", true) returns true for "sort=id" or no sort.
+func (q listQuery) sortedBy(key string, isDefault bool) bool {
+	if len(q.sort) == 0 {
+		return isDefault
+	}
+	return q.sort[0].String() == key
+}
+
+type listItem = map[string]any
+
+// listPage is the response of list endpoints if any list parameters are
+// set. Next is the cursor of the next page, empty on the last page.
+// Total is only set by endpoints that can count the items cheaply.
+type listPage struct {
+	Items []listItem `json:"items"`
+	Next  string     `json:"next"`
+	Total *int       `json:"total,omitempty"`
+}
+
+// toListItems converts a slice of objects, or a map of objects, to list
+// items. Map values are ordered by key. Numbers are kept as json.Number
+// so large integers, like log timestamps, are not rounded.
+func toListItems(v any) ([]listItem, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	items := []listItem{}
+	switch d := decoded.(type) {
+	case []any:
+		for _, v := range d {
+			item, ok := v.(listItem)
+			if !ok {
+				return nil, fmt.Errorf("item is not an object: %T", v) //nolint:goerr113
+			}
+			items = append(items, item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(d))
+		for key := range d {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			item, ok := d[key].(listItem)
+			if !ok {
+				return nil, fmt.Errorf("item is not an object: %T", d[key]) //nolint:goerr113
+			}
+			items = append(items, item)
+		}
+	case nil:
+	default:
+		return nil, fmt.Errorf("not a list: %T", decoded) //nolint:goerr113
+	}
+	return items, nil
+}
+
+// paginate sorts the items and returns the page at the cursor.
+// The cursor is the offset of the page, items keep their
+// order if the sort is unset or the values are equal.
+func (q listQuery) paginate(items []listItem) (listPage, error) {
+	offset := 0
+	if q.cursor != "" {
+		var err error
+		offset, err = strconv.Atoi(q.cursor)
+		if err != nil || offset < 0 {
+			return listPage{}, fmt.Errorf("%w: %q", ErrInvalidCursor, q.cursor)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, key := range q.sort {
+			c := compareValues(items[i][key.field], items[j][key.field])
+			if c == 0 {
+				continue
+			}
+			if key.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + q.pageSize
+	next := strconv.Itoa(end)
+	if end >= len(items) {
+		end = len(items)
+		next = ""
+	}
+	return listPage{Items: q.project(items[offset:end]), Next: next}, nil
+}
+
+// project removes the fields that weren't requested.
+func (q listQuery) project(items []listItem) []listItem {
+	if len(q.fields) == 0 {
+		return items
+	}
+	projected := make([]listItem, 0, len(items))
+	for _, item := range items {
+		p := make(listItem, len(q.fields))
+		for _, field := range q.fields {
+			if v, exist := item[field]; exist {
+				p[field] = v
+			}
+		}
+		projected = append(projected, p)
+	}
+	return projected
+}
+
+// compareValues compares two decoded JSON values. Missing values
+// are sorted first and values of different types are compared
+// by their string representation.
+func compareValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case json.Number:
+		if b, ok := b.(json.Number); ok {
+			af, errA := a.Float64()
+			bf, errB := b.Float64()
+			if errA == nil && errB == nil {
+				switch {
+				case af < bf:
+					return -1
+				case af > bf:
+					return 1
+				}
+				return 0
+			}
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// writeList paginates v and writes the page.
+func writeList(w http.ResponseWriter, q listQuery, v any) {
+	items, err := toListItems(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page, err := q.paginate(items)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeListPage(w, page)
+}
+
+// writeListPage writes a page that was paginated by the endpoint.
+func writeListPage(w http.ResponseWriter, page listPage) {
+	w.Header().Set("Content-Type", jsonContentType)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"nvr/pkg/monitor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListQuery(t *testing.T) {
+	parse := func(rawQuery string, sortable ...string) (listQuery, error) {
+		query, err := url.ParseQuery(rawQuery)
+		require.NoError(t, err)
+		return parseListQuery(query, sortable...)
+	}
+
+	t.Run("unset", func(t *testing.T) {
+		q, err := parse("limit=5")
+		require.NoError(t, err)
+		require.Equal(t, listQuery{}, q)
+	})
+	t.Run("ok", func(t *testing.T) {
+		q, err := parse("fields=id,name&sort=name,-id&page[size]=5&page[cursor]=10")
+		require.NoError(t, err)
+		expected := listQuery{
+			fields:   []string{"id", "name"},
+			sort:     []sortKey{{field: "name"}, {field: "id", desc: true}},
+			pageSize: 5,
+			cursor:   "10",
+			set:      true,
+		}
+		require.Equal(t, expected, q)
+		require.True(t, q.sortedBy("name", false))
+		require.False(t, q.sortedBy("-id", true))
+	})
+	t.Run("defaultPageSize", func(t *testing.T) {
+		q, err := parse("fields=id")
+		require.NoError(t, err)
+		require.Equal(t, defaultPageSize, q.pageSize)
+		require.True(t, q.sortedBy("-id", true))
+	})
+	t.Run("sortable", func(t *testing.T) {
+		_, err := parse("sort=-time", "-time")
+		require.NoError(t, err)
+
+		_, err = parse("sort=time", "-time")
+		require.ErrorIs(t, err, ErrInvalidSort)
+
+		_, err = parse("sort=-time,level", "-time")
+		require.ErrorIs(t, err, ErrInvalidSort)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := parse("fields=id,")
+		require.ErrorIs(t, err, ErrInvalidFields)
+
+		_, err = parse("sort=-")
+		require.ErrorIs(t, err, ErrInvalidSort)
+
+		_, err = parse("page[size]=0")
+		require.ErrorIs(t, err, ErrInvalidPageSize)
+
+		_, err = parse("page[size]=1001")
+		require.ErrorIs(t, err, ErrInvalidPageSize)
+
+		_, err = parse("page[size]=x")
+		require.ErrorIs(t, err, ErrInvalidPageSize)
+	})
+}
+
+func TestToListItems(t *testing.T) {
+	items, err := toListItems(map[string]map[string]int{
+		"b": {"v": 2},
+		"a": {"v": 1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []listItem{
+		{"v": json.Number("1")},
+		{"v": json.Number("2")},
+	}, items)
+
+	items, err = toListItems([]struct {
+		Time uint64 `json:"time"`
+	}{{Time: 1700000000000001}})
+	require.NoError(t, err)
+	require.Equal(t, []listItem{{"time": json.Number("1700000000000001")}}, items)
+
+	items, err = toListItems(nil)
+	require.NoError(t, err)
+	require.Equal(t, []listItem{}, items)
+
+	_, err = toListItems([]int{1})
+	require.Error(t, err)
+
+	_, err = toListItems("x")
+	require.Error(t, err)
+}
+
+func TestPaginate(t *testing.T) {
+	newItems := func() []listItem {
+		return []listItem{
+			{"id": "a", "n": json.Number("2"), "b": true},
+			{"id": "b", "n": json.Number("10")},
+			{"id": "c", "n": json.Number("2"), "b": false},
+		}
+	}
+	ids := func(page listPage) string {
+		var ids []string
+		for _, item := range page.Items {
+			ids = append(ids, item["id"].(string))
+		}
+		return strings.Join(ids, ",")
+	}
+
+	t.Run("sort", func(t *testing.T) {
+		q := listQuery{sort: []sortKey{{field: "n"}, {field: "id", desc: true}}, pageSize: 10}
+		page, err := q.paginate(newItems())
+		require.NoError(t, err)
+		require.Equal(t, "c,a,b", ids(page))
+		require.Equal(t, "", page.Next)
+
+		q = listQuery{sort: []sortKey{{field: "b"}}, pageSize: 10}
+		page, err = q.paginate(newItems())
+		require.NoError(t, err)
+		require.Equal(t, "b,c,a", ids(page))
+	})
+	t.Run("pages", func(t *testing.T) {
+		q := listQuery{pageSize: 2}
+		page, err := q.paginate(newItems())
+		require.NoError(t, err)
+		require.Equal(t, "a,b", ids(page))
+		require.Equal(t, "2", page.Next)
+
+		q.cursor = page.Next
+		page, err = q.paginate(newItems())
+		require.NoError(t, err)
+		require.Equal(t, "c", ids(page))
+		require.Equal(t, "", page.Next)
+
+		q.cursor = "5"
+		page, err = q.paginate(newItems())
+		require.NoError(t, err)
+		require.Empty(t, page.Items)
+
+		q.cursor = "-1"
+		_, err = q.paginate(newItems())
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	})
+	t.Run("fields", func(t *testing.T) {
+		q := listQuery{fields: []string{"id", "b"}, pageSize: 10}
+		page, err := q.paginate(newItems())
+		require.NoError(t, err)
+		require.Equal(t, []listItem{
+			{"id": "a", "b": true},
+			{"id": "b"},
+			{"id": "c", "b": false},
+		}, page.Items)
+	})
+}
+
+func TestMonitorListQuery(t *testing.T) {
+	h := MonitorList(func() monitor.RawConfigs {
+		return monitor.RawConfigs{
+			"1": {"id": "1", "name": "b", "tags": "garage,outdoor"},
+			"2": {"id": "2", "name": "a", "tags": "outdoor"},
+		}
+	})
+	serve := func(target string) (int, string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	code, body := serve("/api/monitor/list?sort=name&fields=id&page[size]=1")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `{"items":[{"id":"2"}],"next":"1"}`, body)
+
+	code, body = serve("/api/monitor/list?sort=name&fields=id&page[size]=1&page[cursor]=1")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `{"items":[{"id":"1"}],"next":""}`, body)
+
+	code, _ = serve("/api/monitor/list?page[cursor]=x")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, body = serve("/api/monitor/list")
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, strings.HasPrefix(body, `{"1":`), body)
+
+	code, body = serve("/api/monitor/list?tags=Outdoor&fields=id&sort=id")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `{"items":[{"id":"1"},{"id":"2"}],"next":""}`, body)
+
+	code, body = serve("/api/monitor/list?tags=outdoor,garage&fields=id")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `{"items":[{"id":"1"}],"next":""}`, body)
+}
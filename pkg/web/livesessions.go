@@ -0,0 +1,403 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"nvr/pkg/storage"
+	"nvr/pkg/video"
+	"nvr/pkg/web/auth"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Live stream types.
+const (
+	LiveSessionMSE = "mse"
+	LiveSessionHLS = "hls"
+
+	// RTSP readers aren't limited, they're only listed by Streams.
+	LiveSessionRTSP = "rtsp"
+)
+
+// HLS is stateless, a HLS session is kept while the client requests
+// the playlist or segments and is released after this timeout.
+const hlsSessionTimeout = 10 * time.Second
+
+// A kicked HLS client can't open the stream again until
+// this timeout, it would otherwise reconnect immediately.
+const liveKickTimeout = time.Minute
+
+// LiveSession is a live stream that's being watched.
+type LiveSession struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"userId"`
+	Username string    `json:"username"`
+	IP       string    `json:"ip"`
+	Stream   string    `json:"stream"`
+	Type     string    `json:"type"`
+	Started  time.Time `json:"started"`
+
+	// Bytes sent to the client.
+	Bytes int64 `json:"bytes"`
+
+	// Zero if the session is held until released.
+	expires time.Time
+
+	// Closes the stream, nil for HLS.
+	cancel func()
+}
+
+// LiveSessions limits the number of concurrent live streams of each
+// user. Websocket streams are held until the socket is closed. HLS
+// streams are keyed by user, client IP and stream, so tabs that
+// watch the same stream from the same client share a session.
+type LiveSessions struct {
+	config   storage.LiveSessions
+	sessions map[string]*LiveSession // map[sessionKey]
+	kicked   map[string]time.Time    // map[sessionKey]expires
+	nextID   uint64
+	mu       sync.Mutex
+}
+
+// NewLiveSessions creates a new live session limiter.
+func NewLiveSessions(config storage.LiveSessions) *LiveSessions {
+	return &LiveSessions{
+		config:   config,
+		sessions: make(map[string]*LiveSession),
+		kicked:   make(map[string]time.Time),
+	}
+}
+
+// Live session errors.
+var (
+	ErrLiveSessionLimit    = errors.New("live stream limit reached")
+	ErrLiveSessionKicked   = errors.New("live stream was closed by an admin")
+	ErrLiveSessionNotExist = errors.New("live session does not exist")
+)
+
+// acquire adds or refreshes the session with the key. Admins are not limited.
+func (s *LiveSessions) acquire(
+	key string,
+	user auth.Account,
+	session LiveSession,
+	now time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+
+	if _, kicked := s.kicked[key]; kicked {
+		return ErrLiveSessionKicked
+	}
+	if existing, exist := s.sessions[key]; exist {
+		existing.expires = session.expires
+		return nil
+	}
+
+	limit := s.config.UserLimit(user.Username)
+	if !user.IsAdmin && limit > 0 {
+		count := 0
+		for _, session := range s.sessions {
+			if session.UserID == user.ID {
+				count++
+			}
+		}
+		if count >= limit {
+			return fmt.Errorf("%w: %v of %v streams are open,"+
+				" close a stream or ask an admin to raise the limit",
+				ErrLiveSessionLimit, count, limit)
+		}
+	}
+
+	s.nextID++
+	session.ID = session.Type + "-" + strconv.FormatUint(s.nextID, 10)
+	session.UserID = user.ID
+	session.Username = user.Username
+	session.Started = now
+	s.sessions[key] = &session
+	return nil
+}
+
+func (s *LiveSessions) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+}
+
+// prune removes expired sessions, the lock must be held.
+func (s *LiveSessions) prune(now time.Time) {
+	for key, session := range s.sessions {
+		if !session.expires.IsZero() && now.After(session.expires) {
+			delete(s.sessions, key)
+		}
+	}
+	for key, expires := range s.kicked {
+		if now.After(expires) {
+			delete(s.kicked, key)
+		}
+	}
+}
+
+// addBytes adds to the bytes sent by the session if it's still open.
+func (s *LiveSessions) addBytes(key string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, exist := s.sessions[key]; exist {
+		session.Bytes += n
+	}
+}
+
+// acquireMSE adds a websocket session and returns its key. The
+// cancel function is called if the session is kicked by an admin.
+func (s *LiveSessions) acquireMSE(
+	user auth.Account,
+	ip string,
+	stream string,
+	cancel func(),
+) (string, error) {
+	s.mu.Lock()
+	s.nextID++
+	key := LiveSessionMSE + ":" + strconv.FormatUint(s.nextID, 10)
+	s.mu.Unlock()
+
+	err := s.acquire(key, user, LiveSession{
+		IP:     ip,
+		Stream: stream,
+		Type:   LiveSessionMSE,
+		cancel: cancel,
+	}, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Kick closes a session by ID.
+func (s *LiveSessions) Kick(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+
+	for key, session := range s.sessions {
+		if session.ID != id {
+			continue
+		}
+		delete(s.sessions, key)
+		if session.cancel != nil {
+			session.cancel()
+		} else {
+			s.kicked[key] = now.Add(liveKickTimeout)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrLiveSessionNotExist, id)
+}
+
+// List returns the open sessions ordered by start time.
+func (s *LiveSessions) List() []LiveSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+
+	list := make([]LiveSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		list = append(list, *session)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Started.Equal(list[j].Started) {
+			return list[i].Stream < list[j].Stream
+		}
+		return list[i].Started.Before(list[j].Started)
+	})
+	return list
+}
+
+// HLS limits the HLS streams, "/hls/<stream>/<file>".
+func (s *LiveSessions) HLS(a auth.Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/hls/"), "/")
+		if r.Method != http.MethodGet || stream == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := a.ValidateRequest(r).User
+		ip := auth.ClientIP(r)
+		key := strings.Join([]string{LiveSessionHLS, user.ID, ip, stream}, ":")
+		now := time.Now()
+		err := s.acquire(key, user, LiveSession{
+			IP:      ip,
+			Stream:  stream,
+			Type:    LiveSessionHLS,
+			expires: now.Add(hlsSessionTimeout),
+		}, now)
+		if err != nil {
+			status := http.StatusTooManyRequests
+			if errors.Is(err, ErrLiveSessionKicked) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		cw := &byteCountWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		s.addBytes(key, cw.n)
+	})
+}
+
+// byteCountWriter counts the bytes written to the response.
+type byteCountWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *byteCountWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// LiveSessionList returns the open live streams of all users.
+func LiveSessionList(s *LiveSessions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(s.List()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// StreamSession is a live stream session or a RTSP reader.
+type StreamSession struct {
+	ID string `json:"id"`
+
+	// "mse", "hls" or "rtsp".
+	Type string `json:"type"`
+
+	// Empty for RTSP readers, they aren't authenticated.
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+
+	IP        string    `json:"ip"`
+	MonitorID string    `json:"monitorId"`
+	Stream    string    `json:"stream"`
+	Started   time.Time `json:"started"`
+
+	// Seconds since the session was started.
+	Duration int64 `json:"duration"`
+
+	// Bytes sent to the client.
+	Bytes int64 `json:"bytes"`
+}
+
+type rtspReaders interface {
+	RTSPReaders() []video.RTSPReader
+	CloseRTSPReader(id string) error
+}
+
+// IDs of RTSP readers are prefixed to not collide with live sessions.
+const rtspSessionPrefix = LiveSessionRTSP + "-"
+
+// streamSessions returns the live sessions and RTSP readers ordered by start time.
+func streamSessions(s *LiveSessions, readers rtspReaders, now time.Time) []StreamSession {
+	list := []StreamSession{}
+	for _, session := range s.List() {
+		list = append(list, StreamSession{
+			ID:        session.ID,
+			Type:      session.Type,
+			UserID:    session.UserID,
+			Username:  session.Username,
+			IP:        session.IP,
+			MonitorID: strings.TrimSuffix(session.Stream, video.SubPathSuffix),
+			Stream:    session.Stream,
+			Started:   session.Started,
+			Bytes:     session.Bytes,
+		})
+	}
+	for _, reader := range readers.RTSPReaders() {
+		ip, _, err := net.SplitHostPort(reader.RemoteAddr)
+		if err != nil {
+			ip = reader.RemoteAddr
+		}
+		list = append(list, StreamSession{
+			ID:        rtspSessionPrefix + reader.ID,
+			Type:      LiveSessionRTSP,
+			IP:        ip,
+			MonitorID: reader.MonitorID,
+			Stream:    reader.Path,
+			Started:   reader.Started,
+			Bytes:     int64(reader.Bytes),
+		})
+	}
+	for i := range list {
+		list[i].Duration = int64(now.Sub(list[i].Started).Seconds())
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Started.Before(list[j].Started)
+	})
+	return list
+}
+
+// Streams returns the live streams of all users and the RTSP readers.
+func Streams(s *LiveSessions, readers rtspReaders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(streamSessions(s, readers, time.Now()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// StreamKick closes a live stream or disconnects a RTSP reader.
+func StreamKick(s *LiveSessions, readers rtspReaders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		if readerID, isRTSP := strings.CutPrefix(id, rtspSessionPrefix); isRTSP {
+			err = readers.CloseRTSPReader(readerID)
+		} else {
+			err = s.Kick(id)
+		}
+		switch {
+		case errors.Is(err, ErrLiveSessionNotExist), errors.Is(err, video.ErrReaderNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
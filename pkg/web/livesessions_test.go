@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nvr/pkg/storage"
+	"nvr/pkg/video"
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveSessions(t *testing.T) {
+	alice := auth.Account{ID: "1", Username: "alice"}
+	bob := auth.Account{ID: "2", Username: "bob"}
+	admin := auth.Account{ID: "3", Username: "admin", IsAdmin: true}
+
+	newSessions := func() *LiveSessions {
+		return NewLiveSessions(storage.LiveSessions{
+			Limit: 2,
+			Users: map[string]int{"bob": 0},
+		})
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		s := newSessions()
+		key1, err := s.acquireMSE(alice, "", "a", nil)
+		require.NoError(t, err)
+		_, err = s.acquireMSE(alice, "", "b", nil)
+		require.NoError(t, err)
+
+		_, err = s.acquireMSE(alice, "", "c", nil)
+		require.ErrorIs(t, err, ErrLiveSessionLimit)
+		require.Contains(t, err.Error(), "2 of 2")
+
+		s.release(key1)
+		_, err = s.acquireMSE(alice, "", "c", nil)
+		require.NoError(t, err)
+	})
+	t.Run("userOverride", func(t *testing.T) {
+		s := newSessions()
+		for i := 0; i < 5; i++ {
+			_, err := s.acquireMSE(bob, "", "a", nil)
+			require.NoError(t, err)
+		}
+	})
+	t.Run("admin", func(t *testing.T) {
+		s := newSessions()
+		for i := 0; i < 5; i++ {
+			_, err := s.acquireMSE(admin, "", "a", nil)
+			require.NoError(t, err)
+		}
+	})
+	t.Run("hlsExpire", func(t *testing.T) {
+		s := newSessions()
+		now := time.Unix(0, 0)
+		hls := func(key string, now time.Time) error {
+			return s.acquire(key, alice, LiveSession{
+				Stream:  key,
+				Type:    LiveSessionHLS,
+				expires: now.Add(hlsSessionTimeout),
+			}, now)
+		}
+		require.NoError(t, hls("a", now))
+		require.NoError(t, hls("b", now))
+
+		// Refresh.
+		require.NoError(t, hls("a", now.Add(9*time.Second)))
+		require.ErrorIs(t, hls("c", now.Add(9*time.Second)), ErrLiveSessionLimit)
+
+		// "b" expired.
+		require.NoError(t, hls("c", now.Add(11*time.Second)))
+	})
+}
+
+type stubAuth struct {
+	auth.Authenticator
+	user auth.Account
+}
+
+func (a stubAuth) ValidateRequest(*http.Request) auth.ValidateResponse {
+	return auth.ValidateResponse{IsValid: true, User: a.user}
+}
+
+func TestLiveSessionsHLS(t *testing.T) {
+	s := NewLiveSessions(storage.LiveSessions{Limit: 1})
+	a := stubAuth{user: auth.Account{ID: "1", Username: "alice"}}
+	h := s.HLS(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(target string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, get("/hls/a/index.m3u8"))
+	require.Equal(t, http.StatusOK, get("/hls/a/init.mp4"))
+	require.Equal(t, http.StatusTooManyRequests, get("/hls/b/index.m3u8"))
+
+	w := httptest.NewRecorder()
+	LiveSessionList(s).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/live-sessions", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var sessions []LiveSession
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 1)
+	require.Equal(t, "alice", sessions[0].Username)
+	require.Equal(t, "a", sessions[0].Stream)
+	require.Equal(t, LiveSessionHLS, sessions[0].Type)
+}
+
+type stubRTSPReaders struct {
+	readers []video.RTSPReader
+	closed  []string
+}
+
+func (r *stubRTSPReaders) RTSPReaders() []video.RTSPReader {
+	return r.readers
+}
+
+func (r *stubRTSPReaders) CloseRTSPReader(id string) error {
+	for _, reader := range r.readers {
+		if reader.ID == id {
+			r.closed = append(r.closed, id)
+			return nil
+		}
+	}
+	return video.ErrReaderNotExist
+}
+
+func TestStreams(t *testing.T) {
+	alice := auth.Account{ID: "1", Username: "alice"}
+	now := time.Now()
+
+	newSessions := func() (*LiveSessions, *stubRTSPReaders) {
+		readers := &stubRTSPReaders{readers: []video.RTSPReader{{
+			ID:         "123",
+			Path:       "x_sub",
+			MonitorID:  "x",
+			RemoteAddr: "127.0.0.1:5000",
+			Started:    now.Add(-time.Hour),
+			Bytes:      10,
+		}}}
+		return NewLiveSessions(storage.LiveSessions{}), readers
+	}
+	kick := func(s *LiveSessions, readers rtspReaders, id string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/streams/kick?id="+id, nil)
+		StreamKick(s, readers).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("list", func(t *testing.T) {
+		s, readers := newSessions()
+		key, err := s.acquireMSE(alice, "1.2.3.4", "x_sub", nil)
+		require.NoError(t, err)
+		s.addBytes(key, 100)
+
+		w := httptest.NewRecorder()
+		Streams(s, readers).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/streams", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var sessions []StreamSession
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+		require.Len(t, sessions, 2)
+
+		require.Equal(t, "rtsp-123", sessions[0].ID)
+		require.Equal(t, LiveSessionRTSP, sessions[0].Type)
+		require.Equal(t, "127.0.0.1", sessions[0].IP)
+		require.Equal(t, int64(10), sessions[0].Bytes)
+		require.GreaterOrEqual(t, sessions[0].Duration, int64(3600))
+
+		require.Equal(t, LiveSessionMSE, sessions[1].Type)
+		require.Equal(t, "alice", sessions[1].Username)
+		require.Equal(t, "1.2.3.4", sessions[1].IP)
+		require.Equal(t, "x", sessions[1].MonitorID)
+		require.Equal(t, "x_sub", sessions[1].Stream)
+		require.Equal(t, int64(100), sessions[1].Bytes)
+	})
+	t.Run("kickMSE", func(t *testing.T) {
+		s, readers := newSessions()
+		canceled := false
+		_, err := s.acquireMSE(alice, "", "x", func() { canceled = true })
+		require.NoError(t, err)
+		id := s.List()[0].ID
+
+		require.Equal(t, http.StatusOK, kick(s, readers, id))
+		require.True(t, canceled)
+		require.Empty(t, s.List())
+		require.Equal(t, http.StatusNotFound, kick(s, readers, id))
+	})
+	t.Run("kickHLS", func(t *testing.T) {
+		s, readers := newSessions()
+		h := s.HLS(stubAuth{user: alice}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("abc")) //nolint:errcheck
+		}))
+		get := func() int {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hls/x/index.m3u8", nil))
+			return w.Code
+		}
+		require.Equal(t, http.StatusOK, get())
+		require.Equal(t, int64(3), s.List()[0].Bytes)
+
+		require.Equal(t, http.StatusOK, kick(s, readers, s.List()[0].ID))
+		require.Equal(t, http.StatusForbidden, get())
+	})
+	t.Run("kickRTSP", func(t *testing.T) {
+		s, readers := newSessions()
+		require.Equal(t, http.StatusOK, kick(s, readers, "rtsp-123"))
+		require.Equal(t, []string{"123"}, readers.closed)
+		require.Equal(t, http.StatusNotFound, kick(s, readers, "rtsp-456"))
+	})
+	t.Run("missingID", func(t *testing.T) {
+		s, readers := newSessions()
+		require.Equal(t, http.StatusBadRequest, kick(s, readers, ""))
+	})
+}
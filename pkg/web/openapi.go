@@ -0,0 +1,349 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpenAPI is a OpenAPI 3 document. Only the parts
+// that are used by the specification are included.
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo .
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation is a method of a path.
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security"`
+
+	// Extensions.
+	Auth             string  `json:"x-auth"`
+	List             bool    `json:"x-list,omitempty"`
+	WebsocketMessage *Schema `json:"x-websocket-message,omitempty"`
+}
+
+// OpenAPIParameter .
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody .
+type OpenAPIRequestBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]OpenAPIMedia `json:"content"`
+}
+
+// OpenAPIResponse .
+type OpenAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]OpenAPIMedia `json:"content,omitempty"`
+}
+
+// OpenAPIMedia .
+type OpenAPIMedia struct {
+	Schema *Schema `json:"schema"`
+}
+
+// OpenAPIComponents .
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema               `json:"schemas"`
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme .
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Schema is a OpenAPI schema object. A empty schema matches any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+
+	// GoName is the name of the struct field, used by the client generator.
+	GoName string `json:"x-go-name,omitempty"`
+}
+
+// RefPrefix is the prefix of component schema references.
+const RefPrefix = "#/components/schemas/"
+
+// schemaGenerator generates schemas from Go types by the same
+// rules as encoding/json. Named structs become components.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+	types   map[string]reflect.Type
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+		types:   make(map[string]reflect.Type),
+	}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *schemaGenerator) schema(t reflect.Type) *Schema { //nolint:funlen
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType), reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType), reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// Named structs are components, anonymous and generic structs are inlined.
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" || strings.Contains(t.Name(), "[") {
+		return g.objectSchema(t)
+	}
+	if name, exist := g.names[t]; exist {
+		return &Schema{Ref: RefPrefix + name}
+	}
+
+	name := exportedName(t.Name())
+	if _, exist := g.types[name]; exist {
+		// Types from different packages with the same name.
+		name = exportedName(path.Base(t.PkgPath())) + name
+	}
+	g.names[t] = name
+	g.types[name] = t
+
+	// The schema is added before the fields so that recursive types work.
+	schema := &Schema{}
+	g.schemas[name] = schema
+	*schema = *g.objectSchema(t)
+
+	return &Schema{Ref: RefPrefix + name}
+}
+
+func (g *schemaGenerator) objectSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the fields of the struct, including
+// the fields of embedded structs without a JSON name.
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			g.addFields(schema, fieldType)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		var fieldSchema *Schema
+		if strings.Contains(opts, "string") {
+			fieldSchema = &Schema{Type: "string"}
+		} else {
+			fieldSchema = g.schema(field.Type)
+		}
+		fieldSchema.GoName = field.Name
+		schema.Properties[name] = fieldSchema
+	}
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func (g *schemaGenerator) operation(route Route, op Operation) *OpenAPIOperation {
+	res := &OpenAPIOperation{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Responses:   make(map[string]OpenAPIResponse),
+		Security:    []map[string][]string{},
+		Auth:        route.Auth.String(),
+		List:        op.List,
+	}
+
+	if tag, _, _ := strings.Cut(strings.TrimPrefix(op.Path, "/api/"), "/"); tag != "" {
+		res.Tags = []string{tag}
+	}
+
+	if route.Auth != AuthNone {
+		requirement := map[string][]string{"basicAuth": {}}
+		if route.CSRF {
+			requirement["csrfToken"] = []string{}
+		}
+		res.Security = append(res.Security, requirement)
+	}
+
+	for _, p := range op.Params {
+		res.Parameters = append(res.Parameters, OpenAPIParameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == "path",
+			Schema:      &Schema{Type: p.Type},
+		})
+	}
+	if op.List {
+		res.Parameters = append(res.Parameters, listParams...)
+	}
+
+	switch {
+	case op.Request != nil:
+		res.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content: map[string]OpenAPIMedia{
+				jsonContentType: {Schema: g.schema(reflect.TypeOf(op.Request))},
+			},
+		}
+	case op.RequestType != "":
+		res.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content: map[string]OpenAPIMedia{
+				op.RequestType: {Schema: &Schema{Type: "string", Format: "binary"}},
+			},
+		}
+	}
+
+	switch {
+	case op.Websocket:
+		res.Responses["101"] = OpenAPIResponse{Description: "Switching Protocols"}
+		if op.Response != nil {
+			res.WebsocketMessage = g.schema(reflect.TypeOf(op.Response))
+		}
+	case op.Response != nil:
+		schema := g.schema(reflect.TypeOf(op.Response))
+		if op.List {
+			schema = &Schema{OneOf: []*Schema{schema, g.schema(reflect.TypeOf(listPage{}))}}
+		}
+		res.Responses["200"] = OpenAPIResponse{
+			Description: http.StatusText(http.StatusOK),
+			Content:     map[string]OpenAPIMedia{jsonContentType: {Schema: schema}},
+		}
+	case op.ResponseType != "":
+		res.Responses["200"] = OpenAPIResponse{
+			Description: http.StatusText(http.StatusOK),
+			Content: map[string]OpenAPIMedia{
+				op.ResponseType: {Schema: &Schema{Type: "string", Format: "binary"}},
+			},
+		}
+	default:
+		res.Responses["200"] = OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+	return res
+}
+
+var listParams = []OpenAPIParameter{
+	{
+		Name:        listFieldsParam,
+		In:          "query",
+		Description: "Comma separated list of fields to include in each item.",
+		Schema:      &Schema{Type: "string"},
+	},
+	{
+		Name:        listSortParam,
+		In:          "query",
+		Description: `Comma separated list of fields to sort by, "-" prefix sorts descending.`,
+		Schema:      &Schema{Type: "string"},
+	},
+	{
+		Name:        listPageSizeParam,
+		In:          "query",
+		Description: "Number of items per page.",
+		Schema:      &Schema{Type: "integer"},
+	},
+	{
+		Name:        listPageCursorParam,
+		In:          "query",
+		Description: `The "next" cursor of the previous page.`,
+		Schema:      &Schema{Type: "string"},
+	},
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nvr/pkg/kv"
+	"nvr/pkg/web/auth"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UserPreferences are the UI and API settings of a user, stored on
+// the server so that they follow the user across browsers.
+// Zero values use the defaults.
+type UserPreferences struct {
+	// Group that the live page opens, empty for all monitors.
+	DefaultGroup string `json:"defaultGroup"`
+
+	// Number of columns in the live and recordings grids.
+	GridSize int `json:"gridSize"`
+
+	// Theme of the UI, empty uses the theme of the general settings.
+	Theme string `json:"theme"`
+
+	// IANA time zone, for example "Europe/Stockholm".
+	// Empty uses the time zone of the server.
+	TimeZone string `json:"timeZone"`
+
+	// Keyboard shortcuts, action to key.
+	Shortcuts map[string]string `json:"shortcuts"`
+}
+
+// Preference limits.
+const (
+	maxPreferenceGridSize  = 20
+	maxPreferenceShortcuts = 100
+	maxPreferenceLength    = 64
+)
+
+// ErrInvalidPreferences invalid preferences.
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+func (p UserPreferences) validate() error {
+	if len(p.DefaultGroup) > maxPreferenceLength {
+		return fmt.Errorf("%w: defaultGroup is too long", ErrInvalidPreferences)
+	}
+	if p.GridSize < 0 || p.GridSize > maxPreferenceGridSize {
+		return fmt.Errorf("%w: gridSize must be between 0 and %v",
+			ErrInvalidPreferences, maxPreferenceGridSize)
+	}
+	if !validThemeName(p.Theme) {
+		return fmt.Errorf("%w: theme %q", ErrInvalidPreferences, p.Theme)
+	}
+	if p.TimeZone != "" {
+		if _, err := time.LoadLocation(p.TimeZone); err != nil {
+			return fmt.Errorf("%w: timeZone %q", ErrInvalidPreferences, p.TimeZone)
+		}
+	}
+	if len(p.Shortcuts) > maxPreferenceShortcuts {
+		return fmt.Errorf("%w: max %v shortcuts", ErrInvalidPreferences, maxPreferenceShortcuts)
+	}
+	for action, key := range p.Shortcuts {
+		if action == "" || len(action) > maxPreferenceLength ||
+			key == "" || len(key) > maxPreferenceLength {
+			return fmt.Errorf("%w: shortcut %q: %q", ErrInvalidPreferences, action, key)
+		}
+	}
+	return nil
+}
+
+// validThemeName themes are the names of the
+// stylesheets in "static/style/themes".
+func validThemeName(name string) bool {
+	if len(name) > maxPreferenceLength {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Bucket of the user preferences, keyed by user ID.
+const preferencesBucket = "preferences"
+
+// Preferences stores the preferences of each user in the database.
+type Preferences struct {
+	db *kv.DB
+}
+
+// NewPreferences creates the preference store. Preferences in the
+// legacy directory, one JSON file per user ID, are moved into the
+// database.
+func NewPreferences(db *kv.DB, legacyDir string) (*Preferences, error) {
+	p := &Preferences{db: db}
+	if err := p.migrate(legacyDir); err != nil {
+		return nil, fmt.Errorf("migrate preferences: %w", err)
+	}
+	return p, nil
+}
+
+// migrate moves the legacy preference files into the database.
+func (p *Preferences) migrate(legacyDir string) error {
+	entries, err := os.ReadDir(legacyDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	err = p.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(preferencesBucket)
+		for _, entry := range entries {
+			userID, isJSON := strings.CutSuffix(entry.Name(), ".json")
+			if entry.IsDir() || !isJSON {
+				continue
+			}
+			path := filepath.Join(legacyDir, entry.Name())
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var prefs UserPreferences
+			if err := json.Unmarshal(raw, &prefs); err != nil {
+				return fmt.Errorf("unmarshal %v: %w", entry.Name(), err)
+			}
+			if err := bucket.PutJSON(userID, prefs); err != nil {
+				return err
+			}
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	// Fails if there are other files.
+	os.Remove(legacyDir)
+	return nil
+}
+
+// Get returns the preferences of the user.
+func (p *Preferences) Get(userID string) (UserPreferences, error) {
+	var prefs UserPreferences
+	err := p.db.View(func(tx *kv.Tx) error {
+		_, err := tx.Bucket(preferencesBucket).GetJSON(userID, &prefs)
+		return err
+	})
+	if err != nil {
+		return UserPreferences{}, fmt.Errorf("get preferences: %w", err)
+	}
+	if prefs.Shortcuts == nil {
+		prefs.Shortcuts = map[string]string{}
+	}
+	return prefs, nil
+}
+
+// Set validates and saves the preferences of the user.
+func (p *Preferences) Set(userID string, prefs UserPreferences) error {
+	if userID == "" {
+		return fmt.Errorf("%w: user id is empty", ErrInvalidPreferences)
+	}
+	if err := prefs.validate(); err != nil {
+		return err
+	}
+	err := p.db.Update(func(tx *kv.Tx) error {
+		return tx.Bucket(preferencesBucket).PutJSON(userID, prefs)
+	})
+	if err != nil {
+		return fmt.Errorf("save preferences: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the preferences of a deleted user.
+func (p *Preferences) Delete(userID string) error {
+	err := p.db.Update(func(tx *kv.Tx) error {
+		return tx.Bucket(preferencesBucket).Delete(userID)
+	})
+	if err != nil {
+		return fmt.Errorf("delete preferences: %w", err)
+	}
+	return nil
+}
+
+// UserPreferencesGet handler returns the preferences of the current user.
+func UserPreferencesGet(a auth.Authenticator, p *Preferences) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := a.ValidateRequest(r).User
+		prefs, err := p.Get(user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(prefs); err != nil {
+			http.Error(w, "could not encode json", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// UserPreferencesSet handler replaces the preferences of the current user.
+func UserPreferencesSet(a auth.Authenticator, p *Preferences) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var prefs UserPreferences
+		if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user := a.ValidateRequest(r).User
+		err := p.Set(user.ID, prefs)
+		if errors.Is(err, ErrInvalidPreferences) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nvr/pkg/kv"
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPreferences(t *testing.T, dbPath string, legacyDir string) *Preferences {
+	t.Helper()
+	db, err := kv.Open(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	p, err := NewPreferences(db, legacyDir)
+	require.NoError(t, err)
+	return p
+}
+
+func TestPreferences(t *testing.T) {
+	t.Run("persist", func(t *testing.T) {
+		dir := t.TempDir()
+		dbPath := filepath.Join(dir, "nvr.db")
+		p := newTestPreferences(t, dbPath, filepath.Join(dir, "nil"))
+
+		prefs, err := p.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, UserPreferences{Shortcuts: map[string]string{}}, prefs)
+
+		prefs = UserPreferences{
+			DefaultGroup: "g1",
+			GridSize:     3,
+			Theme:        "light",
+			TimeZone:     "Europe/Stockholm",
+			Shortcuts:    map[string]string{"fullscreen": "f"},
+		}
+		require.NoError(t, p.Set("1", prefs))
+		require.NoError(t, p.db.Close())
+
+		p2 := newTestPreferences(t, dbPath, filepath.Join(dir, "nil"))
+		got, err := p2.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, prefs, got)
+
+		require.NoError(t, p2.Delete("1"))
+		require.NoError(t, p2.Delete("1"))
+		got, err = p2.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, 0, got.GridSize)
+	})
+	t.Run("migrate", func(t *testing.T) {
+		dir := t.TempDir()
+		legacyDir := filepath.Join(dir, "preferences")
+		require.NoError(t, os.MkdirAll(legacyDir, 0o700))
+		path := filepath.Join(legacyDir, "1.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"gridSize":4}`), 0o600))
+
+		p := newTestPreferences(t, filepath.Join(dir, "nvr.db"), legacyDir)
+		prefs, err := p.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, 4, prefs.GridSize)
+		require.NoDirExists(t, legacyDir)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		p := newTestPreferences(t, filepath.Join(dir, "nvr.db"), dir)
+
+		cases := map[string]struct {
+			userID string
+			prefs  UserPreferences
+		}{
+			"userID":   {"", UserPreferences{}},
+			"gridSize": {"1", UserPreferences{GridSize: -1}},
+			"theme":    {"1", UserPreferences{Theme: "../x"}},
+			"timeZone": {"1", UserPreferences{TimeZone: "nil"}},
+			"shortcut": {"1", UserPreferences{Shortcuts: map[string]string{"a": ""}}},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				err := p.Set(tc.userID, tc.prefs)
+				require.ErrorIs(t, err, ErrInvalidPreferences)
+			})
+		}
+	})
+}
+
+func TestUserPreferencesHandlers(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestPreferences(t, filepath.Join(dir, "nvr.db"), dir)
+	alice := stubAuth{user: auth.Account{ID: "1", Username: "alice"}}
+	bob := stubAuth{user: auth.Account{ID: "2", Username: "bob"}}
+
+	serve := func(h http.Handler, method string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/", strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(UserPreferencesSet(alice, p), http.MethodPut, `{"gridSize":100}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(UserPreferencesSet(alice, p), http.MethodPut, `{"gridSize":2}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	get := func(a auth.Authenticator) UserPreferences {
+		w := serve(UserPreferencesGet(a, p), http.MethodGet, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var prefs UserPreferences
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prefs))
+		return prefs
+	}
+	require.Equal(t, 2, get(alice).GridSize)
+	require.Equal(t, 0, get(bob).GridSize)
+}
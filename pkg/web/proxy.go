@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+	"strings"
+)
+
+// BasePath serves the handler under the base path. The prefix is removed
+// before the request is passed to the handler, the front-end uses relative
+// URLs. Requests outside the base path are rejected.
+func BasePath(basePath string, h http.Handler) http.Handler {
+	if basePath == "" {
+		return h
+	}
+	stripped := http.StripPrefix(basePath, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, basePath+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// Headers set by reverse proxies.
+var forwardingHeaders = []string{
+	"X-Forwarded-For",
+	"X-Forwarded-Proto",
+	"X-Forwarded-Host",
+	"X-Real-Ip",
+}
+
+// ProxyHeaders applies the forwarding headers of requests from trusted
+// proxies. The remote address is replaced by the client IP, the URL scheme
+// by the forwarded protocol and the host by the forwarded host, the
+// websocket origin check compares the origin with the host. The forwarding
+// headers are removed from requests that aren't from a trusted proxy so
+// they can't be spoofed. Requests from trusted proxies are marked so that
+// proxy auth can trust their user headers. Nothing is changed if there
+// are no trusted proxies.
+func ProxyHeaders(trustedProxies []string, h http.Handler) http.Handler {
+	if len(trustedProxies) == 0 {
+		return h
+	}
+	var trusted []netip.Prefix
+	for _, proxy := range trustedProxies {
+		// Validated by storage.NewConfigEnv.
+		prefix, err := storage.ParseTrustedProxy(proxy)
+		if err == nil {
+			trusted = append(trusted, prefix)
+		}
+	}
+	isTrusted := func(ip string) bool {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil || !isTrusted(host) {
+			for _, header := range forwardingHeaders {
+				r.Header.Del(header)
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		r = auth.WithTrustedProxy(r)
+		if ip := forwardedClientIP(r.Header.Values("X-Forwarded-For"), isTrusted); ip != "" {
+			r.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		if proto := lastValue(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			r.URL.Scheme = proto
+		}
+		if host := lastValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			r.Host = host
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClientIP returns the first address from the right that isn't a
+// trusted proxy, the addresses to the left may be set by the client.
+func forwardedClientIP(values []string, isTrusted func(string) bool) string {
+	var ips []string
+	for _, value := range values {
+		for _, ip := range strings.Split(value, ",") {
+			ips = append(ips, strings.TrimSpace(ip))
+		}
+	}
+	for i := len(ips) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(ips[i]); err != nil {
+			return ""
+		}
+		if !isTrusted(ips[i]) || i == 0 {
+			return ips[i]
+		}
+	}
+	return ""
+}
+
+// lastValue returns the last value of a comma separated list,
+// the value added by the proxy closest to the server.
+func lastValue(v string) string {
+	if i := strings.LastIndex(v, ","); i != -1 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasePath(t *testing.T) {
+	h := BasePath("/a/nvr", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path)) //nolint:errcheck
+	}))
+
+	cases := []struct {
+		target   string
+		code     int
+		body     string
+		location string
+	}{
+		{"/a/nvr/live", http.StatusOK, "/live", ""},
+		{"/a/nvr/", http.StatusOK, "/", ""},
+		{"/a/nvr", http.StatusMovedPermanently, "", "/a/nvr/"},
+		{"/a/nvrx/live", http.StatusNotFound, "", ""},
+		{"/live", http.StatusNotFound, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			require.Equal(t, tc.code, w.Code)
+			if tc.body != "" {
+				require.Equal(t, tc.body, w.Body.String())
+			}
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		BasePath("", h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/nvr/x", nil))
+		require.Equal(t, "/x", w.Body.String())
+	})
+}
+
+func TestProxyHeaders(t *testing.T) {
+	type request struct {
+		remoteAddr string
+		scheme     string
+		host       string
+		forwarded  string
+		trusted    bool
+	}
+	var got request
+	h := ProxyHeaders(
+		[]string{"10.0.0.0/8", "127.0.0.1"},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = request{
+				remoteAddr: r.RemoteAddr,
+				scheme:     r.URL.Scheme,
+				host:       r.Host,
+				forwarded:  r.Header.Get("X-Forwarded-For"),
+				trusted:    auth.FromTrustedProxy(r),
+			}
+		}),
+	)
+
+	cases := map[string]struct {
+		remoteAddr string
+		headers    map[string]string
+		expected   request
+	}{
+		"trusted": {
+			"10.0.0.1:1234",
+			map[string]string{
+				"X-Forwarded-For":   "1.2.3.4",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "example.com",
+			},
+			request{"1.2.3.4:1234", "https", "example.com", "1.2.3.4", true},
+		},
+		"chain": {
+			"127.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.0.0.2"},
+			request{"1.2.3.4:1234", "", "example.org", "6.6.6.6, 1.2.3.4, 10.0.0.2", true},
+		},
+		"allTrusted": {
+			"127.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			request{"10.0.0.3:1234", "", "example.org", "10.0.0.3, 10.0.0.2", true},
+		},
+		"invalidIP": {
+			"127.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "x", "X-Forwarded-Proto": "ftp"},
+			request{"127.0.0.1:1234", "", "example.org", "x", true},
+		},
+		"untrusted": {
+			"1.1.1.1:1234",
+			map[string]string{
+				"X-Forwarded-For":   "1.2.3.4",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "example.com",
+			},
+			request{"1.1.1.1:1234", "", "example.org", "", false},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = "example.org"
+			r.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
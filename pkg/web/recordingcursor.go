@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"nvr/pkg/storage"
+	"sort"
+	"strings"
+)
+
+// recordingCursor is the position of a paginated recording query. It's
+// sent to the client as a opaque token, see encode and decodeRecordingCursor.
+type recordingCursor struct {
+	// ID of the last recording of the previous page.
+	After string `json:"after"`
+
+	// Latest recording when the first page was read, newer recordings
+	// are excluded so the pages don't shift while recording.
+	Until string `json:"until,omitempty"`
+
+	// Number of matching recordings when the first page was read.
+	Total int `json:"total"`
+
+	// Hash of the query, the cursor is only valid for the same query.
+	Query string `json:"query"`
+}
+
+func (c recordingCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeRecordingCursor decodes the token and checks that it
+// belongs to the query. A empty token returns a nil cursor.
+func decodeRecordingCursor(token string, queryHash string) (*recordingCursor, error) {
+	if token == "" {
+		return nil, nil //nolint:nilnil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, token)
+	}
+	var c recordingCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.After == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, token)
+	}
+	if c.Query != queryHash {
+		return nil, fmt.Errorf("%w: the query changed", ErrInvalidCursor)
+	}
+	return &c, nil
+}
+
+// recordingQueryHash returns a hash of the parameters that change
+// which recordings are returned, or in which order.
+func recordingQueryHash(q *storage.CrawlerQuery) string {
+	monitors := append([]string(nil), q.Monitors...)
+	sort.Strings(monitors)
+	f := q.Filter
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v\n%v\n%v\n%v\n%v\n%v",
+		q.Reverse,
+		strings.Join(monitors, ","),
+		strings.Join(f.Triggers, ","),
+		strings.Join(f.Labels, ","),
+		strings.Join(f.Zones, ","),
+		f.MinDuration,
+	)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordingListPage reads the page at the list cursor. The time of the
+// query is set from the cursor. The first page counts the recordings
+// and stores the total and the latest recording in the cursor.
+func recordingListPage(
+	crawler *storage.Crawler,
+	q *storage.CrawlerQuery,
+	list listQuery,
+) (listPage, error) {
+	queryHash := recordingQueryHash(q)
+	cursor, err := decodeRecordingCursor(list.cursor, queryHash)
+	if err != nil {
+		return listPage{}, err
+	}
+	if cursor == nil {
+		total, latest, err := crawler.CountRecordings(q)
+		if err != nil {
+			return listPage{}, err
+		}
+		cursor = &recordingCursor{Until: latest, Total: total, Query: queryHash}
+	} else {
+		q.Time = cursor.After
+	}
+	q.Until = cursor.Until
+
+	recordings, err := crawler.RecordingByQuery(q)
+	if err != nil {
+		return listPage{}, err
+	}
+	items, err := toListItems(recordings)
+	if err != nil {
+		return listPage{}, err
+	}
+
+	total := cursor.Total
+	page := listPage{Items: list.project(items), Total: &total}
+	if len(recordings) == q.Limit {
+		cursor.After = recordings[len(recordings)-1].ID
+		page.Next = cursor.encode()
+	}
+	return page, nil
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingQueryCursor(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.json": {},
+		"2000/01/01/m2/2000-01-01_02-00-00_m2.json": {},
+		"2000/01/02/m1/2000-01-02_01-00-00_m1.json": {},
+		"2000/01/03/m1/2000-01-03_01-00-00_m1.json": {},
+	}
+	h := RecordingQuery(storage.NewCrawler(fileSystem), log.NewDummyLogger())
+
+	get := func(t *testing.T, target string) (int, listPage) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var page listPage
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		}
+		return w.Code, page
+	}
+	ids := func(page listPage) []string {
+		var ids []string
+		for _, item := range page.Items {
+			ids = append(ids, item["id"].(string))
+		}
+		return ids
+	}
+	// readAll follows the cursors and adds a recording after the first page.
+	readAll := func(t *testing.T, target string) ([]string, int) {
+		t.Helper()
+		code, page := get(t, target)
+		require.Equal(t, http.StatusOK, code)
+		all := ids(page)
+		total := *page.Total
+
+		fileSystem["2000/01/04/m1/2000-01-04_01-00-00_m1.json"] = &fstest.MapFile{}
+		defer delete(fileSystem, "2000/01/04/m1/2000-01-04_01-00-00_m1.json")
+
+		for page.Next != "" {
+			code, page = get(t, target+"&page[cursor]="+page.Next)
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, total, *page.Total)
+			all = append(all, ids(page)...)
+		}
+		return all, total
+	}
+
+	t.Run("descending", func(t *testing.T) {
+		all, total := readAll(t, "/api/recording/query?page[size]=1")
+		require.Equal(t, []string{
+			"2000-01-03_01-00-00_m1",
+			"2000-01-02_01-00-00_m1",
+			"2000-01-01_02-00-00_m2",
+			"2000-01-01_01-00-00_m1",
+		}, all)
+		require.Equal(t, 4, total)
+	})
+	t.Run("ascending", func(t *testing.T) {
+		all, total := readAll(t, "/api/recording/query?page[size]=3&sort=id")
+		require.Equal(t, []string{
+			"2000-01-01_01-00-00_m1",
+			"2000-01-01_02-00-00_m2",
+			"2000-01-02_01-00-00_m1",
+			"2000-01-03_01-00-00_m1",
+		}, all)
+		require.Equal(t, 4, total)
+	})
+	t.Run("monitors", func(t *testing.T) {
+		all, total := readAll(t, "/api/recording/query?page[size]=2&monitors=m2")
+		require.Equal(t, []string{"2000-01-01_02-00-00_m2"}, all)
+		require.Equal(t, 1, total)
+	})
+	t.Run("empty", func(t *testing.T) {
+		code, page := get(t, "/api/recording/query?page[size]=2&monitors=x")
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, page.Items)
+		require.Empty(t, page.Next)
+		require.Equal(t, 0, *page.Total)
+	})
+	t.Run("invalidCursor", func(t *testing.T) {
+		code, _ := get(t, "/api/recording/query?page[size]=1&page[cursor]=2000-01-02")
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("queryChanged", func(t *testing.T) {
+		code, page := get(t, "/api/recording/query?page[size]=1")
+		require.Equal(t, http.StatusOK, code)
+
+		code, _ = get(t, "/api/recording/query?page[size]=1&monitors=m1&page[cursor]="+page.Next)
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = get(t, "/api/recording/query?page[size]=1&sort=id&page[cursor]="+page.Next)
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+}
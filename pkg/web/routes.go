@@ -3,21 +3,38 @@
 package web
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"nvr/pkg/backup"
+	"nvr/pkg/crypt"
+	"nvr/pkg/export"
+	"nvr/pkg/feed"
+	"nvr/pkg/ffmpeg"
 	"nvr/pkg/group"
 	"nvr/pkg/log"
 	"nvr/pkg/monitor"
+	"nvr/pkg/onvif"
+	"nvr/pkg/speedtest"
 	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+	"nvr/pkg/update"
+	"nvr/pkg/video"
 	"nvr/pkg/web/auth"
+	"nvr/pkg/zones"
 	"nvr/web/static"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/gorilla/websocket"
@@ -56,6 +73,148 @@ func TimeZone(timeZone string) http.Handler {
 	})
 }
 
+// Transcoders handler returns the capabilities of the transcoder backends.
+func Transcoders(transcoders *monitor.Transcoders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(transcoders)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// SystemBackup handler streams a tar.gz backup of the config directory.
+func SystemBackup(logger *log.Logger, configDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filename := "nvr-backup-" + time.Now().Format("2006-01-02") + ".tar.gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		// Headers are already sent, the error can only be logged.
+		if err := backup.Backup(configDir, w); err != nil {
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("backup: %v", err),
+			})
+		}
+	})
+}
+
+// Max size of the restore request body.
+const maxRestoreSize = 64 * 1024 * 1024
+
+// SystemRestore handler restores the config directory from a
+// backup. Configs not in the backup are deleted unless the
+// "merge" query is true. The app must be restarted afterwards.
+func SystemRestore(logger *log.Logger, configDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		merge := r.URL.Query().Get("merge") == "true"
+		body := http.MaxBytesReader(w, r.Body, maxRestoreSize)
+
+		err := backup.Restore(configDir, body, merge)
+		if errors.Is(err, backup.ErrInvalidBackup) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "could not restore backup", http.StatusInternalServerError)
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("restore: %v", err),
+			})
+			return
+		}
+
+		logger.Log(log.Entry{
+			Level: log.LevelWarning,
+			Src:   "app",
+			Msg:   "backup restored, restart required",
+		})
+	})
+}
+
+// SystemVersion handler returns the current version and the update state.
+// The latest release is only checked if the "check" query is true.
+func SystemVersion(info func(ctx context.Context, check bool) update.VersionInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		check := r.URL.Query().Get("check") == "true"
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(info(r.Context(), check)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// Max duration of the update download.
+const updateTimeout = 30 * time.Minute
+
+// SystemUpdate handler downloads, verifies and stages the latest release.
+// The app restarts into the release after the response is sent.
+func SystemUpdate(
+	logger log.ILogger,
+	install func(context.Context) (*update.Release, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// The update shouldn't be aborted if the client disconnects.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), updateTimeout)
+		defer cancel()
+
+		release, err := install(ctx)
+		switch {
+		case errors.Is(err, update.ErrDisabled):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, update.ErrNoUpdate), errors.Is(err, update.ErrInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, "update failed: "+err.Error(), http.StatusInternalServerError)
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("update: %v", err),
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(release); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
 // General handler returns general configuration in json format.
 func General(general *storage.ConfigGeneral) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,6 +252,16 @@ func GeneralSet(general *storage.ConfigGeneral) http.Handler {
 			return
 		}
 
+		if err := checkSchedules(config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := storage.ParseMinRetentionDays(config["minRetentionDays"]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		err = general.Set(config)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -109,8 +278,18 @@ func Users(a auth.Authenticator) http.Handler {
 			return
 		}
 
+		list, err := parseListQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if list.set {
+			writeList(w, list, a.UsersList())
+			return
+		}
+
 		w.Header().Set("Content-Type", jsonContentType)
-		err := json.NewEncoder(w).Encode(a.UsersList())
+		err = json.NewEncoder(w).Encode(a.UsersList())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -151,105 +330,157 @@ func UserSet(a auth.Authenticator) http.Handler {
 	})
 }
 
-// UserDelete handler to delete user.
-func UserDelete(a auth.Authenticator) http.Handler {
+// UserReset handler to set a temporary password that
+// the user must change before using the app.
+func UserReset(a auth.Authenticator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
+		if r.Method != http.MethodPost {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		name := r.URL.Query().Get("id")
-		if name == "" {
-			http.Error(w, "id missing", http.StatusBadRequest)
+		var req auth.ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		err := a.UserDelete(name)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := a.UserReset(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	})
 }
 
-// MonitorList returns a censored monitor list.
-func MonitorList(monitorInfo func() monitor.RawConfigs) http.Handler {
+// UserPassword handler to change the password of the requesting user.
+func UserPassword(a auth.Authenticator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+		if r.Method != http.MethodPut {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		w.Header().Set("Content-Type", jsonContentType)
-		err := json.NewEncoder(w).Encode(monitorInfo())
+		res := a.ValidateRequest(r)
+		if res.Impersonator != nil {
+			http.Error(w, "cannot change password while impersonating", http.StatusForbidden)
+			return
+		}
+
+		var req auth.ChangePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := a.ChangePassword(res.User.ID, req)
+		if errors.Is(err, auth.ErrWrongPassword) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	})
 }
 
-// MonitorConfigs returns monitor configurations in json format.
-func MonitorConfigs(c *monitor.Manager) http.Handler {
+// UserDelete handler to delete user and their preferences.
+func UserDelete(a auth.Authenticator, p *Preferences) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+		if r.Method != http.MethodDelete {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		w.Header().Set("Content-Type", jsonContentType)
-		err := json.NewEncoder(w).Encode(c.MonitorConfigs())
+		name := r.URL.Query().Get("id")
+		if name == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		err := a.UserDelete(name)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+
+		if err := p.Delete(name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	})
 }
 
-// MonitorRestart handler to restart monitor.
-func MonitorRestart(m *monitor.Manager) http.Handler {
+// Max duration of an impersonation.
+const maxImpersonationDuration = 24 * time.Hour
+
+// UserImpersonate handler to start impersonating a user. Duration
+// is in minutes, defaults to 30. Impersonation errors aren't caused
+// by the system and are all bad requests.
+func UserImpersonate(a auth.Authenticator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		id := r.URL.Query().Get("id")
+		query := r.URL.Query()
+		id := query.Get("id")
 		if id == "" {
 			http.Error(w, "id missing", http.StatusBadRequest)
 			return
 		}
 
-		err := m.RestartMonitor(id)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("could not restart monitor: %v", err),
-				http.StatusInternalServerError)
+		duration := 30 * time.Minute
+		if rawDuration := query.Get("duration"); rawDuration != "" {
+			minutes, err := strconv.Atoi(rawDuration)
+			if err != nil || minutes <= 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			duration = min(time.Duration(minutes)*time.Minute, maxImpersonationDuration)
+		}
+
+		admin := a.ValidateRequest(r).User
+		if err := a.Impersonate(admin.ID, id, duration); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	})
 }
 
-// MonitorSet handler to set monitor configuration.
-func MonitorSet(m *monitor.Manager) http.Handler {
+// UserImpersonateStop handler to stop impersonating. The request
+// is validated as the impersonated user and must not require admin.
+func UserImpersonateStop(a auth.Authenticator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
+		if r.Method != http.MethodPost {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var c monitor.RawConfig
-		err := json.NewDecoder(r.Body).Decode(&c)
-		if err != nil {
+		res := a.ValidateRequest(r)
+		if res.Impersonator == nil {
+			http.Error(w, auth.ErrNotImpersonating.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.StopImpersonation(res.Impersonator.ID); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+	})
+}
 
-		if err := checkIDandName(c); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+// UserLockouts returns the IPs and accounts that are
+// locked after too many failed login attempts.
+func UserLockouts(a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		err = m.MonitorSet(c["id"], c)
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(a.Lockouts())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -257,21 +488,61 @@ func MonitorSet(m *monitor.Manager) http.Handler {
 	})
 }
 
-// MonitorDelete handler to delete monitor.
-func MonitorDelete(m *monitor.Manager) http.Handler {
+// UserLockoutClear handler to unlock a IP or account.
+// All lockouts are cleared if type is empty.
+func UserLockoutClear(a auth.Authenticator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
+		if r.Method != http.MethodPost {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		id := r.URL.Query().Get("id")
-		if id == "" {
-			http.Error(w, "id missing", http.StatusBadRequest)
+		query := r.URL.Query()
+		typ := query.Get("type")
+		key := query.Get("key")
+		if typ != "" && key == "" {
+			http.Error(w, "key missing", http.StatusBadRequest)
 			return
 		}
 
-		err := m.MonitorDelete(id)
+		if err := a.ClearLockout(typ, key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	})
+}
+
+// MonitorList returns a censored monitor list. The optional
+// tags query parameter only returns monitors with all the tags.
+func MonitorList(monitorInfo func() monitor.RawConfigs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		list, err := parseListQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		monitors := monitorInfo()
+		if tags := monitor.ParseTags(r.URL.Query().Get("tags")); len(tags) != 0 {
+			for id, rawConf := range monitors {
+				if !monitor.NewConfig(rawConf).HasTags(tags) {
+					delete(monitors, id)
+				}
+			}
+		}
+
+		if list.set {
+			writeList(w, list, monitors)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		err = json.NewEncoder(w).Encode(monitors)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -279,93 +550,319 @@ func MonitorDelete(m *monitor.Manager) http.Handler {
 	})
 }
 
-// GroupConfigs returns group configurations in json format.
-func GroupConfigs(m *group.Manager) http.Handler {
+// MonitorConfigs returns monitor configurations in json format.
+func MonitorConfigs(c *monitor.Manager) http.Handler {
+	var cache jsonCache
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		cache.serve(w, r, c.ConfigVersion(), func() any {
+			configs := c.MonitorConfigs()
+			for id, rawConf := range configs {
+				configs[id] = monitor.RedactConfig(rawConf)
+			}
+			return configs
+		})
+	})
+}
+
+// MonitorHealth handler to get the health of running monitors.
+// Optional id query parameter returns a single monitor.
+func MonitorHealth(m *monitor.Manager) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
+		var res interface{}
+		health := m.MonitorHealth()
+		if id := r.URL.Query().Get("id"); id != "" {
+			h, exist := health[id]
+			if !exist {
+				http.Error(w, "monitor is not running", http.StatusNotFound)
+				return
+			}
+			res = h
+		} else {
+			res = health
+		}
+
 		w.Header().Set("Content-Type", jsonContentType)
-		err := json.NewEncoder(w).Encode(m.Configs())
-		if err != nil {
+		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	})
 }
 
-// GroupSet handler to set group configuration.
-func GroupSet(m *group.Manager) http.Handler {
+// MonitorStats returns the live stream statistics history of a monitor.
+func MonitorStats(m *monitor.Manager) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
+		if r.Method != http.MethodGet {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var g group.Config
-		err := json.NewDecoder(r.Body).Decode(&g)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
 			return
 		}
 
-		if err := checkIDandNameGroup(g); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		stats, exist := m.StreamStats(id)
+		if !exist {
+			http.Error(w, "monitor is not running or wasn't sampled yet", http.StatusNotFound)
 			return
 		}
 
-		if err = m.GroupSet(g["id"], g); err != nil {
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	})
 }
 
-// Errors.
-var (
-	ErrEmptyValue     = errors.New("value cannot be empty")
-	ErrContainsSpaces = errors.New("value cannot contain spaces")
-	ErrIDTooLong      = errors.New("id cannot be longer than 24 bytes")
-)
+// StorageAgeReport returns the size of the recordings of each monitor grouped by age.
+func StorageAgeReport(report func() storage.AgeReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
 
-func checkIDandName(c monitor.RawConfig) error {
-	switch {
-	case c["id"] == "":
-		return fmt.Errorf("id: %w", ErrEmptyValue)
-	case containsSpaces(c["id"]):
-		return fmt.Errorf("id: %w", ErrContainsSpaces)
-	case c["name"] == "":
-		return fmt.Errorf("name: %w", ErrEmptyValue)
-	case containsSpaces(c["name"]):
-		return fmt.Errorf("name: %w", ErrContainsSpaces)
-	case len(c["id"]) > 24:
-		return ErrIDTooLong
-	default:
-		return nil
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(report()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// StorageForecast returns the storage usage forecast.
+func StorageForecast(forecast func() (storage.Forecast, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		f, err := forecast()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(f); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// StorageVerify starts a verification of the recordings.
+// Responds with 409 if a verification is already pending.
+func StorageVerify(trigger func() bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		if !trigger() {
+			http.Error(w, "verification already pending", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// StorageVerifyStatus returns the report of the running or last verification.
+func StorageVerifyStatus(report func() storage.VerifyReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(report()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// StorageMaintenance starts a scrub of the recordings directories.
+// Responds with 409 if a scrub is already pending.
+func StorageMaintenance(trigger func() bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		if !trigger() {
+			http.Error(w, "maintenance already pending", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// StorageMaintenanceStatus returns the report of the running or last scrub.
+func StorageMaintenanceStatus(report func() storage.ScrubReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(report()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// HealthCheck handler responds with 200 if the check passes and with
+// 503 and the reason otherwise. Used for the unauthenticated "/healthz"
+// and "/readyz" endpoints of container orchestrators.
+func HealthCheck(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n")) //nolint:errcheck
+	})
+}
+
+// PublicStatus handler returns a redacted system status without
+// authentication. Only the fields in `env.PublicStatus` are exposed.
+func PublicStatus(env storage.ConfigEnv, m *monitor.Manager, s *storage.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(env.PublicStatus) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var status publicStatus
+		if env.PublicStatusExposed(storage.PublicStatusSystem) {
+			status.System = "up"
+		}
+		if env.PublicStatusExposed(storage.PublicStatusMonitors) {
+			status.Monitors = newPublicStatusMonitors(m.MonitorHealth())
+		}
+		if env.PublicStatusExposed(storage.PublicStatusStorage) {
+			status.Storage = publicStorageStatus(s.DiskUsage(10 * time.Minute))
+		}
+		if env.PublicStatusExposed(storage.PublicStatusResources) {
+			status.Resources = m.MonitorResources()
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+type publicStatus struct {
+	System   string                `json:"system,omitempty"`
+	Monitors *publicStatusMonitors `json:"monitors,omitempty"`
+	Storage  string                `json:"storage,omitempty"`
+
+	// Resource usage of the monitor processes by monitor ID.
+	Resources map[string]monitor.Resources `json:"resources,omitempty"`
+}
+
+type publicStatusMonitors struct {
+	Total   int `json:"total"`
+	Healthy int `json:"healthy"`
+}
+
+// A monitor is healthy if all of its inputs are healthy.
+func newPublicStatusMonitors(health map[string]monitor.Health) *publicStatusMonitors {
+	status := &publicStatusMonitors{Total: len(health)}
+	for _, h := range health {
+		if h.Main.State != monitor.HealthOK {
+			continue
+		}
+		if h.Sub != nil && h.Sub.State != monitor.HealthOK {
+			continue
+		}
+		status.Healthy++
 	}
+	return status
 }
 
-func checkIDandNameGroup(input map[string]string) error {
+// Storage is pruned at 99% usage, staying
+// above it means that pruning is failing.
+func publicStorageStatus(usage storage.DiskUsage, err error) string {
 	switch {
-	case input["id"] == "":
-		return fmt.Errorf("id: %w", ErrEmptyValue)
-	case containsSpaces(input["id"]):
-		return fmt.Errorf("id: %w", ErrContainsSpaces)
-	case input["name"] == "":
-		return fmt.Errorf("name: %w", ErrEmptyValue)
-	case containsSpaces(input["name"]):
-		return fmt.Errorf("name. %w", ErrContainsSpaces)
+	case err != nil:
+		return "error"
+	case usage.Percent >= 99:
+		return "full"
 	default:
-		return nil
+		return "ok"
 	}
 }
 
-// GroupDelete handler to delete group.
-func GroupDelete(m *group.Manager) http.Handler {
+// VideoPaths handler returns the statistics of the video server paths.
+func VideoPaths(s *video.Server) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(s.PathStats())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// VideoHLSMemory handler returns the memory
+// used by the cached HLS segments of all paths.
+func VideoHLSMemory(s *video.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(s.HLSMemory())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorRestart handler to restart monitor.
+func MonitorRestart(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
@@ -376,270 +873,3068 @@ func GroupDelete(m *group.Manager) http.Handler {
 			return
 		}
 
-		err := m.GroupDelete(id)
+		err := m.RestartMonitor(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not restart monitor: %v", err),
+				http.StatusInternalServerError)
+		}
+	})
+}
+
+// MonitorEnable enables or disables a monitor without changing the
+// rest of the config. The monitor is restarted if the state changed.
+func MonitorEnable(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		id := query.Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+		enable, err := strconv.ParseBool(query.Get("enable"))
+		if err != nil {
+			http.Error(w, "invalid enable value", http.StatusBadRequest)
+			return
+		}
+
+		_, err = m.SetEnabled(id, enable)
+		if errors.Is(err, monitor.ErrMonitorNotExist) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not set enabled: %v", err),
+				http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+const snapshotTimeout = 10 * time.Second
+
+// MonitorSnapshot returns the most recent keyframe of a monitor as JPEG.
+func MonitorSnapshot(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), snapshotTimeout)
+		defer cancel()
+
+		snapshot, err := m.Snapshot(ctx, id)
+		switch {
+		case errors.Is(err, monitor.ErrNotExist):
+			http.Error(w, "monitor is not running", http.StatusNotFound)
+			return
+		case errors.Is(err, monitor.ErrSnapshotUnavailable):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, "could not get snapshot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("content-type", "image/jpeg")
+		w.Header().Set("cache-control", "no-store")
+		w.Header().Set("last-modified", snapshot.Time.UTC().Format(http.TimeFormat))
+		w.Write(snapshot.Image) //nolint:errcheck
+	})
+}
+
+// MonitorArm handler to temporarily arm or disarm a monitor.
+// Duration is in minutes, zero or missing lasts until changed.
+func MonitorArm(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		id := query.Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if rawDuration := query.Get("duration"); rawDuration != "" {
+			minutes, err := strconv.Atoi(rawDuration)
+			if err != nil || minutes < 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			duration = time.Duration(minutes) * time.Minute
+		}
+
+		err := m.SetArmOverride(id, query.Get("state"), duration)
+		switch {
+		case errors.Is(err, monitor.ErrInvalidArmState):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, monitor.ErrNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MonitorArmState handler returns the arm state of a monitor.
+func MonitorArmState(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		state, err := m.ArmState(r.URL.Query().Get("id"))
 		if err != nil {
+			http.Error(w, "monitor is not running", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(state); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-	})
-}
+	})
+}
+
+// MonitorCameraMotion handler returns the camera-side
+// motion detection config, it's read through ONVIF.
+func MonitorCameraMotion(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		client, ok := cameraMotionClient(w, r, m)
+		if !ok {
+			return
+		}
+		config, err := client.GetMotion(r.Context())
+		if err != nil {
+			cameraMotionError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(config); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorCameraMotionSet handler updates the camera-side
+// motion detection config, it's written through ONVIF.
+func MonitorCameraMotionSet(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		client, ok := cameraMotionClient(w, r, m)
+		if !ok {
+			return
+		}
+		var config onvif.MotionConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, "unmarshal request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := client.SetMotion(r.Context(), config); err != nil {
+			cameraMotionError(w, err)
+			return
+		}
+	})
+}
+
+// cameraMotionClient returns the ONVIF client of the monitor in the id query.
+func cameraMotionClient(w http.ResponseWriter, r *http.Request, m *monitor.Manager) (*onvif.Client, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id missing", http.StatusBadRequest)
+		return nil, false
+	}
+	rawConf, err := m.ResolvedConfig(id)
+	switch {
+	case errors.Is(err, monitor.ErrMonitorNotExist):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	c := monitor.NewConfig(rawConf)
+	if c.ONVIFAddress() == "" {
+		http.Error(w, "onvif address is not set", http.StatusBadRequest)
+		return nil, false
+	}
+	dialer, err := c.Dialer()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return onvif.NewClient(c.ONVIFAddress(), c.ONVIFUsername(), c.ONVIFPassword(), dialer), true
+}
+
+// Errors that aren't caused by the request are camera errors.
+func cameraMotionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, onvif.ErrInvalidMotionConfig):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, onvif.ErrNotSupported):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "camera: "+err.Error(), http.StatusBadGateway)
+	}
+}
+
+// MonitorMaintenance handler puts a monitor in or out of maintenance.
+func MonitorMaintenance(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		id := query.Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		enable, err := strconv.ParseBool(query.Get("enable"))
+		if err != nil {
+			http.Error(w, "invalid enable value", http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if rawDuration := query.Get("duration"); rawDuration != "" {
+			minutes, err := strconv.Atoi(rawDuration)
+			if err != nil || minutes < 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			duration = time.Duration(minutes) * time.Minute
+		}
+
+		if enable {
+			err = m.SetMaintenance(id, query.Get("reason"), duration)
+		} else {
+			err = m.ClearMaintenance(id)
+		}
+		switch {
+		case errors.Is(err, monitor.ErrMaintenanceReasonTooLong):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, monitor.ErrNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MonitorMaintenanceState handler returns the active
+// maintenance and maintenance history of a monitor.
+func MonitorMaintenanceState(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		info, err := m.Maintenance(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func checkSchedules(config map[string]string) error {
+	for _, key := range []string{"recordSchedule", "armSchedule"} {
+		if _, err := monitor.ParseSchedule(config[key]); err != nil {
+			return fmt.Errorf("%v: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// MonitorSet handler to set monitor configuration.
+func MonitorSet(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var c monitor.RawConfig
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := checkMonitorConfig(c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = m.MonitorSet(c["id"], c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func checkMonitorConfig(c monitor.RawConfig) error {
+	if err := checkIDandName(c); err != nil {
+		return err
+	}
+	if _, err := monitor.NewConfig(c).SourceAddr(); err != nil {
+		return err
+	}
+	if err := monitor.NewConfig(c).CheckResourceLimits(); err != nil {
+		return err
+	}
+	if err := monitor.NewConfig(c).CheckMonitorType(); err != nil {
+		return err
+	}
+	if err := monitor.NewConfig(c).CheckTags(); err != nil {
+		return err
+	}
+	if err := monitor.NewConfig(c).CheckAutoSubStream(); err != nil {
+		return err
+	}
+	return checkSchedules(c)
+}
+
+// ErrMonitorExist monitor already exists.
+var ErrMonitorExist = errors.New("monitor already exists")
+
+// createMonitor saves the config of a new monitor.
+func createMonitor(w http.ResponseWriter, m *monitor.Manager, c monitor.RawConfig) {
+	if err := checkMonitorConfig(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, exist := m.MonitorConfigs()[c["id"]]; exist {
+		http.Error(w, fmt.Sprintf("%v: %v", ErrMonitorExist, c["id"]), http.StatusConflict)
+		return
+	}
+	if err := m.MonitorSet(c["id"], c); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// MonitorClone handler to create a monitor with the config of another monitor.
+func MonitorClone(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		from := query.Get("from")
+		src, exist := m.MonitorConfigs()[from]
+		if !exist {
+			http.Error(w, fmt.Sprintf("%v: %q", monitor.ErrMonitorNotExist, from), http.StatusNotFound)
+			return
+		}
+
+		c := make(monitor.RawConfig, len(src))
+		for key, value := range src {
+			c[key] = value
+		}
+		c["id"] = query.Get("id")
+		c["name"] = query.Get("name")
+
+		createMonitor(w, m, c)
+	})
+}
+
+// MonitorTemplates returns all monitor templates.
+func MonitorTemplates(t *monitor.Templates) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		templates := []monitorTemplate{}
+		for _, tpl := range t.List() {
+			templates = append(templates, monitorTemplate{
+				Template:  tpl,
+				Variables: tpl.Variables(),
+			})
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(templates); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+type monitorTemplate struct {
+	monitor.Template
+	Variables []string `json:"variables"`
+}
+
+// MonitorTemplateSet handler to create or update a monitor template.
+func MonitorTemplateSet(t *monitor.Templates) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var tpl monitor.Template
+		if err := json.NewDecoder(r.Body).Decode(&tpl); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := t.Set(tpl)
+		switch {
+		case errors.Is(err, monitor.ErrInvalidTemplateName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorTemplateDelete handler to delete a monitor template.
+func MonitorTemplateDelete(t *monitor.Templates) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "name missing", http.StatusBadRequest)
+			return
+		}
+
+		err := t.Delete(name)
+		switch {
+		case errors.Is(err, monitor.ErrTemplateNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorCredentials handler returns the camera credentials without the passwords.
+func MonitorCredentials(c *monitor.Credentials) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(c.List()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorCredentialSet handler to create or update a camera credential.
+func MonitorCredentialSet(c *monitor.Credentials) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var cred monitor.Credential
+		if err := json.NewDecoder(r.Body).Decode(&cred); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := c.Set(cred)
+		switch {
+		case errors.Is(err, monitor.ErrInvalidCredentialID):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// ErrCredentialInUse credential is used by a monitor.
+var ErrCredentialInUse = errors.New("credential is used by monitor")
+
+// MonitorCredentialDelete handler to delete a camera credential
+// that isn't used by any monitor.
+func MonitorCredentialDelete(m *monitor.Manager, c *monitor.Credentials) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		for monitorID, rawConf := range m.MonitorConfigs() {
+			if rawConf["credential"] == id {
+				http.Error(w, fmt.Sprintf("%v: %v", ErrCredentialInUse, monitorID), http.StatusConflict)
+				return
+			}
+		}
+
+		err := c.Delete(id)
+		switch {
+		case errors.Is(err, monitor.ErrCredentialNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorTest handler probes a candidate stream URL before the monitor
+// is saved. Connection failures are returned in the result.
+func MonitorTest(t *monitor.StreamTester) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", jsonContentType)
+
+		var req monitor.StreamTestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := t.Test(r.Context(), req)
+		switch {
+		case errors.Is(err, monitor.ErrInvalidStreamURL):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, monitor.ErrCredentialNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, monitor.ErrStreamTestsRunning):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorPrivacyMask handler returns the privacy mask history
+// of a monitor, the last version is the current mask.
+func MonitorPrivacyMask(p *monitor.PrivacyMasks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(p.History(id)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+type privacyMaskRequest struct {
+	Zones []monitor.PrivacyZone `json:"zones"`
+}
+
+// MonitorPrivacyMaskSet handler to save a new version of the
+// privacy mask of a monitor. The monitor is restarted.
+func MonitorPrivacyMaskSet(
+	m *monitor.Manager,
+	p *monitor.PrivacyMasks,
+	a auth.Authenticator,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if _, exist := m.MonitorConfigs()[id]; !exist {
+			http.Error(w, fmt.Sprintf("%v: %q", monitor.ErrMonitorNotExist, id), http.StatusNotFound)
+			return
+		}
+
+		var req privacyMaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mask, err := p.Set(id, a.ValidateRequest(r).User.Username, req.Zones)
+		switch {
+		case errors.Is(err, monitor.ErrInvalidPrivacyZone):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := m.RestartMonitor(id); err != nil {
+			http.Error(w, fmt.Sprintf("could not restart monitor: %v", err),
+				http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(mask); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorZones handler returns the zones of a monitor.
+func MonitorZones(s *zones.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		list, err := s.List(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorZoneSet handler to create or replace a zone of a monitor.
+func MonitorZoneSet(m *monitor.Manager, s *zones.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if _, exist := m.MonitorConfigs()[id]; !exist {
+			http.Error(w, fmt.Sprintf("%v: %q", monitor.ErrMonitorNotExist, id), http.StatusNotFound)
+			return
+		}
+
+		var zone zones.Zone
+		if err := json.NewDecoder(r.Body).Decode(&zone); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := s.Set(id, zone)
+		switch {
+		case errors.Is(err, zones.ErrInvalidZone),
+			errors.Is(err, zones.ErrInvalidZoneName),
+			errors.Is(err, zones.ErrTooManyZones):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorZoneDelete handler to delete a zone of a monitor.
+func MonitorZoneDelete(s *zones.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		id, name := query.Get("id"), query.Get("name")
+		if id == "" || name == "" {
+			http.Error(w, "id or name missing", http.StatusBadRequest)
+			return
+		}
+
+		err := s.Delete(id, name)
+		switch {
+		case errors.Is(err, zones.ErrZoneNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorTemplateApply handler to create a monitor from a template.
+// The request body is a JSON object with the template variables.
+func MonitorTemplateApply(m *monitor.Manager, t *monitor.Templates) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		tpl, err := t.Get(query.Get("template"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		// The body is optional if the template has no variables.
+		vars := make(map[string]string)
+		err = json.NewDecoder(r.Body).Decode(&vars)
+		if err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c, err := tpl.Apply(query.Get("id"), query.Get("name"), vars)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		createMonitor(w, m, c)
+	})
+}
+
+// MonitorDelete handler to delete monitor.
+func MonitorDelete(m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		err := m.MonitorDelete(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// GroupConfigs returns group configurations in json format.
+func GroupConfigs(m *group.Manager) http.Handler {
+	var cache jsonCache
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		cache.serve(w, r, m.Version(), func() any {
+			return m.Configs()
+		})
+	})
+}
+
+// GroupSet handler to set group configuration.
+func GroupSet(m *group.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var g group.Config
+		err := json.NewDecoder(r.Body).Decode(&g)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := checkIDandNameGroup(g); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := g.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = m.GroupSet(g["id"], g); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// Errors.
+var (
+	ErrEmptyValue     = errors.New("value cannot be empty")
+	ErrContainsSpaces = errors.New("value cannot contain spaces")
+	ErrIDTooLong      = errors.New("id cannot be longer than 24 bytes")
+)
+
+func checkIDandName(c monitor.RawConfig) error {
+	switch {
+	case c["id"] == "":
+		return fmt.Errorf("id: %w", ErrEmptyValue)
+	case containsSpaces(c["id"]):
+		return fmt.Errorf("id: %w", ErrContainsSpaces)
+	case c["name"] == "":
+		return fmt.Errorf("name: %w", ErrEmptyValue)
+	case containsSpaces(c["name"]):
+		return fmt.Errorf("name: %w", ErrContainsSpaces)
+	case len(c["id"]) > 24:
+		return ErrIDTooLong
+	default:
+		return nil
+	}
+}
+
+func checkIDandNameGroup(input map[string]string) error {
+	switch {
+	case input["id"] == "":
+		return fmt.Errorf("id: %w", ErrEmptyValue)
+	case containsSpaces(input["id"]):
+		return fmt.Errorf("id: %w", ErrContainsSpaces)
+	case input["name"] == "":
+		return fmt.Errorf("name: %w", ErrEmptyValue)
+	case containsSpaces(input["name"]):
+		return fmt.Errorf("name. %w", ErrContainsSpaces)
+	default:
+		return nil
+	}
+}
+
+// GroupDelete handler to delete group.
+func GroupDelete(m *group.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		err := m.GroupDelete(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// GroupAction handler applies a action to every monitor in a group.
+func GroupAction(g *group.Manager, m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		id := query.Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if rawDuration := query.Get("duration"); rawDuration != "" {
+			minutes, err := strconv.Atoi(rawDuration)
+			if err != nil || minutes < 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			duration = time.Duration(minutes) * time.Minute
+		}
+
+		monitorIDs, err := g.MonitorIDs(id)
+		switch {
+		case errors.Is(err, group.ErrGroupNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		results, err := m.GroupAction(monitorIDs, query.Get("action"), duration)
+		switch {
+		case errors.Is(err, monitor.ErrInvalidAction):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, monitor.ErrNotExist):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// GroupRollup handles the "/api/group/<id>/recordings" and
+// "/api/group/<id>/events" endpoints. They query the recordings
+// or events of all monitors in the group as a single feed.
+func GroupRollup(m *group.Manager, crawler *storage.Crawler, logger log.ILogger) http.Handler { //nolint:funlen
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/group/"), "/")
+		if endpoint == "layout" {
+			serveGroupLayout(w, m, id)
+			return
+		}
+		if endpoint != "recordings" && endpoint != "events" {
+			http.NotFound(w, r)
+			return
+		}
+
+		monitors, err := m.MonitorIDs(id)
+		if err != nil {
+			if errors.Is(err, group.ErrGroupNotExist) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Recordings are sorted by ID and events by time, both
+		// are in the order of the recordings they belong to.
+		sortKey := "id"
+		if endpoint == "events" {
+			sortKey = "time"
+		}
+		list, err := parseListQuery(r.URL.Query(), sortKey, "-"+sortKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q, err := parseGroupRollupQuery(r.URL.Query(), list, sortKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Monitors = monitors
+
+		var response interface{}
+		switch {
+		case len(monitors) == 0:
+			// An empty monitor list would match all monitors.
+			if endpoint == "recordings" {
+				response = groupRecordings{Recordings: []storage.Recording{}}
+			} else {
+				response = groupEvents{Events: []storage.RecordingEvent{}}
+			}
+		case endpoint == "recordings" && list.set:
+			var page listPage
+			page, err = recordingListPage(crawler, q, list)
+			if errors.Is(err, ErrInvalidCursor) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			response = page
+		case endpoint == "recordings":
+			var recordings []storage.Recording
+			recordings, err = crawler.RecordingByQuery(q)
+			res := groupRecordings{Recordings: recordings}
+			if res.Recordings == nil {
+				res.Recordings = []storage.Recording{}
+			}
+			if len(recordings) == q.Limit {
+				res.Next = recordings[len(recordings)-1].ID
+			}
+			response = res
+		default:
+			var res groupEvents
+			res.Events, res.Next, err = crawler.EventsByQuery(q)
+			response = res
+		}
+		if err != nil {
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("crawler: could not process group %v query: %v", endpoint, err),
+			})
+			http.Error(w, "could not process query", http.StatusInternalServerError)
+			return
+		}
+
+		if list.set {
+			writeGroupRollupList(w, list, response)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+type groupRecordings struct {
+	Recordings []storage.Recording `json:"recordings"`
+	Next       string              `json:"next"`
+}
+
+type groupEvents struct {
+	Events []storage.RecordingEvent `json:"events"`
+	Next   string                   `json:"next"`
+}
+
+func writeGroupRollupList(w http.ResponseWriter, list listQuery, response interface{}) {
+	var v interface{}
+	var next string
+	switch res := response.(type) {
+	case listPage:
+		writeListPage(w, res)
+		return
+	case groupRecordings:
+		v, next = res.Recordings, res.Next
+	case groupEvents:
+		v, next = res.Events, res.Next
+	}
+	items, err := toListItems(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeListPage(w, listPage{Items: list.project(items), Next: next})
+}
+
+// serveGroupLayout responds with the live layout of the group.
+func serveGroupLayout(w http.ResponseWriter, m *group.Manager, id string) {
+	layout, err := m.Layout(id)
+	if err != nil {
+		if errors.Is(err, group.ErrGroupNotExist) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	if err := json.NewEncoder(w).Encode(layout); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Maximum page size of the group roll-up endpoints.
+const maxGroupRollupLimit = 1000
+
+// parseGroupRollupQuery parses the limit, time and reverse parameters,
+// or the page size, cursor and sort if the list parameters are set.
+// The first page starts at the latest recording, or the
+// oldest if reverse, if the time is unset.
+func parseGroupRollupQuery(query url.Values, list listQuery, sortKey string) (*storage.CrawlerQuery, error) {
+	filter, err := parseRecordingFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	includeData := query.Get("data") == "true"
+	var q *storage.CrawlerQuery
+	if list.set {
+		// Recording cursors are decoded by recordingListPage.
+		cursor := list.cursor
+		if sortKey == "id" {
+			cursor = ""
+		}
+		q, err = newCrawlerQuery(list.pageSize, cursor, list.sortedBy(sortKey, false), includeData)
+	} else {
+		var limit int
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > maxGroupRollupLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %v", storage.ErrInvalidValue, maxGroupRollupLimit)
+		}
+		q, err = newCrawlerQuery(limit, query.Get("time"), query.Get("reverse") == "true", includeData)
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.Filter = filter
+	return q, nil
+}
+
+// parseRecordingFilter parses the "triggers", "labels",
+// "zones" and "minDuration" parameters of recording queries.
+func parseRecordingFilter(query url.Values) (storage.RecordingFilter, error) {
+	return storage.ParseRecordingFilter(
+		query.Get("triggers"), query.Get("labels"), query.Get("zones"), query.Get("minDuration"))
+}
+
+// newCrawlerQuery returns a query that starts at the latest
+// recording, or the oldest if reverse, if the time is empty.
+func newCrawlerQuery(limit int, time string, reverse bool, includeData bool) (*storage.CrawlerQuery, error) {
+	if time == "" {
+		time = "9999-12-31"
+		if reverse {
+			time = "0000-01-01"
+		}
+	}
+	if len(time) < 10 {
+		return nil, fmt.Errorf("%w: time value to short", storage.ErrInvalidValue)
+	}
+
+	return &storage.CrawlerQuery{
+		Time:        time,
+		Limit:       limit,
+		Reverse:     reverse,
+		IncludeData: includeData,
+	}, nil
+}
+
+// RecordingDelete deletes a recording. The ID is read from the
+// "id" query or from the "/api/recording/delete/<id>" path.
+func RecordingDelete(recordingsDirs []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		recID := r.URL.Query().Get("id")
+		if recID == "" {
+			recID = strings.TrimPrefix(r.URL.Path, "/api/recording/delete/")
+		}
+
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+
+		err = storage.DeleteRecording(recordingsDir, recID)
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidRecordingID) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, storage.ErrRecordingProtected) ||
+				errors.Is(err, storage.ErrRecordingLocked) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingProtect protects or unprotects a recording from
+// deletion, protected recordings are never pruned.
+func RecordingProtect(recordingsDirs []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		recID := query.Get("id")
+		if recID == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		protect, err := strconv.ParseBool(query.Get("protect"))
+		if err != nil {
+			http.Error(w, "invalid protect value", http.StatusBadRequest)
+			return
+		}
+
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+
+		err = storage.SetRecordingProtected(recordingsDir, recID, protect)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingDownload streams a ZIP archive of recordings, either
+// the listed IDs or the finished recordings in a time range.
+//
+//	/api/recording/download?ids=<id>,<id>
+//	/api/recording/download?start=<RFC3339>&end=<RFC3339>&monitors=x,y
+func RecordingDownload(
+	logger log.ILogger,
+	recordingsDirs []string,
+	videoReaderCache *storage.VideoCache,
+) http.Handler {
+	logError := func(msg string, err error) {
+		logger.Log(log.Entry{
+			Level: log.LevelError,
+			Src:   "app",
+			Msg:   fmt.Sprintf("recording download: %v: %v", msg, err),
+		})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		ids := parseCSVParam(query, "ids")
+		if len(ids) == 0 {
+			start, err := time.Parse(time.RFC3339, query.Get("start"))
+			if err != nil {
+				http.Error(w, "ids or valid start and end required", http.StatusBadRequest)
+				return
+			}
+			end, err := time.Parse(time.RFC3339, query.Get("end"))
+			if err != nil {
+				http.Error(w, "invalid end", http.StatusBadRequest)
+				return
+			}
+			ids, err = storage.RecordingsInRange(
+				recordingsDirs, parseCSVParam(query, "monitors"), start, end)
+			if err != nil {
+				if errors.Is(err, storage.ErrInvalidDownloadRange) ||
+					errors.Is(err, storage.ErrInvalidMonitorID) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				logError("find recordings", err)
+				http.Error(w, "see logs for details", http.StatusInternalServerError)
+				return
+			}
+			if len(ids) == 0 {
+				http.Error(w, "no recordings in time range", http.StatusNotFound)
+				return
+			}
+		}
+
+		// The recordings are locked until the archive is written.
+		defer storage.LockRecordings(ids...)()
+
+		files, err := storage.NewDownload(recordingsDirs, ids)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, storage.ErrInvalidRecordingID),
+			errors.Is(err, storage.ErrDuplicateRecordingID),
+			errors.Is(err, storage.ErrTooManyDownloadRecordings):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logError("find files", err)
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+
+		filename := "recordings-" + ids[0][:19] + ".zip"
+		w.Header().Set("Content-Type", contentTypeZip)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		// Headers are already sent, the error can only be logged.
+		if err := storage.WriteDownloadZip(w, files, videoReaderCache); err != nil {
+			logError("write zip", err)
+		}
+	})
+}
+
+// RecordingExport starts an export of the recordings of one or more
+// monitors within a time range. The request is read from the JSON body.
+func RecordingExport(m *export.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req export.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "decode json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		job, err := m.Start(req)
+		switch {
+		case errors.Is(err, export.ErrNoRecordings):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, export.ErrTooManyJobs):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		case errors.Is(err, export.ErrNoMonitors),
+			errors.Is(err, export.ErrTooManyMonitors),
+			errors.Is(err, export.ErrInvalidMonitor),
+			errors.Is(err, export.ErrInvalidRange),
+			errors.Is(err, export.ErrInvalidLayout),
+			errors.Is(err, export.ErrTooManyClips),
+			errors.Is(err, transcode.ErrProfileNotExist):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("content-type", jsonContentType)
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			http.Error(w, "could not encode json", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingSummary starts generating a time-lapse or motion heatmap
+// summary video of a monitor. The request is read from the JSON body.
+func RecordingSummary(m *export.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req export.SummaryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "decode json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		job, err := m.StartSummary(req)
+		switch {
+		case errors.Is(err, export.ErrNoRecordings):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, export.ErrTooManyJobs):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		case errors.Is(err, export.ErrInvalidMonitor),
+			errors.Is(err, export.ErrInvalidRange),
+			errors.Is(err, export.ErrInvalidMode),
+			errors.Is(err, export.ErrInvalidDuration),
+			errors.Is(err, export.ErrTooManyClips),
+			errors.Is(err, transcode.ErrProfileNotExist):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("content-type", jsonContentType)
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			http.Error(w, "could not encode json", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingExportStatus returns the export job by ID.
+func RecordingExportStatus(m *export.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		job, err := m.Job(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("content-type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			http.Error(w, "could not encode json", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingExportFile serves the video of a finished export job.
+func RecordingExportFile(m *export.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		path, err := m.File(id)
+		switch {
+		case errors.Is(err, export.ErrJobNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, export.ErrJobNotDone):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("content-disposition", `attachment; filename="export_`+id+`.mp4"`)
+		http.ServeFile(w, r, path)
+	})
+}
+
+// TranscodeProfiles returns all transcode profiles.
+func TranscodeProfiles(m *transcode.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(m.Profiles())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// TranscodeProfileSet handler to create or update a transcode profile.
+func TranscodeProfileSet(m *transcode.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var p transcode.Profile
+		err := json.NewDecoder(r.Body).Decode(&p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := p.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := m.Set(p); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// TranscodeProfileDelete handler to delete a transcode profile.
+func TranscodeProfileDelete(m *transcode.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "name missing", http.StatusBadRequest)
+			return
+		}
+
+		err := m.Delete(name)
+		switch {
+		case errors.Is(err, transcode.ErrProfileNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingThumbnail serves thumbnail by exact recording ID.
+func RecordingThumbnail(recordingsDirs []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		recID := r.URL.Path[25:] // Trim "/api/recording/thumbnail/"
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+		thumbPath := filepath.Join(recordingsDir, recPath+".jpeg")
+		// Sanitize path.
+		if containsDotDot(thumbPath) {
+			http.Error(w, "invalid recording ID", http.StatusBadRequest)
+			return
+		}
+
+		// The thumbnail may be encrypted.
+		file, err := crypt.Open(thumbPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "thumbnail does not exist", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+
+		info, err := os.Stat(thumbPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeJPEG)
+		http.ServeContent(w, r, "", info.ModTime(), file)
+	})
+}
+
+// RecordingVideo serves video by exact recording ID.
+func RecordingVideo(
+	logger *log.Logger,
+	recordingsDirs []string,
+	videoReaderCache *storage.VideoCache,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		recID := r.URL.Path[21:] // Trim "/api/recording/video/"
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+		path := filepath.Join(recordingsDir, recPath)
+		// Sanitize path.
+		if containsDotDot(path) {
+			http.Error(w, "invalid recording ID", http.StatusBadRequest)
+			return
+		}
+
+		err = serveMP4File(w, r, path+".mp4")
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("video request: %v", err),
+			})
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+
+		video, err := storage.NewVideoReader(path, videoReaderCache)
+		if err != nil {
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("video request: %v", err),
+			})
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+		defer video.Close()
+
+		ServeMP4Content(w, r, video.ModTime(), video.Size(), video)
+	})
+}
+
+// serveMP4File serves a plain or encrypted MP4 file.
+func serveMP4File(w http.ResponseWriter, r *http.Request, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	file, err := crypt.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ServeMP4Content(w, r, info.ModTime(), file.Size(), file)
+	return nil
+}
+
+// EventMedia serves the clip and snapshot of an event,
+// "/api/events/<id>/clip" and "/api/events/<id>/snapshot".
+func EventMedia(
+	logger log.ILogger,
+	clipsDir string,
+	snapshotsDir string,
+	videoReaderCache *storage.VideoCache,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/events/"), "/")
+		if endpoint == "snapshot" {
+			serveEventSnapshot(w, r, snapshotsDir, id)
+			return
+		}
+		if endpoint != "clip" {
+			http.NotFound(w, r)
+			return
+		}
+
+		path, err := monitor.EventClipPath(clipsDir, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		video, err := storage.NewVideoReader(path, videoReaderCache)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "clip does not exist", http.StatusNotFound)
+				return
+			}
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("event clip request: %v", err),
+			})
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+		defer video.Close()
+
+		ServeMP4Content(w, r, video.ModTime(), video.Size(), video)
+	})
+}
+
+func serveEventSnapshot(w http.ResponseWriter, r *http.Request, snapshotsDir string, id string) {
+	path, err := monitor.EventSnapshotPath(snapshotsDir, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(path + ".jpeg")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "snapshot does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJPEG)
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// EventSnapshots returns a page of the event snapshot gallery, newest first.
+func EventSnapshots(snapshotsDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit: %q", query.Get("limit")), http.StatusBadRequest)
+			return
+		}
+
+		q := monitor.EventSnapshotQuery{
+			Time:    query.Get("time"),
+			Limit:   limit,
+			Reverse: query.Get("reverse") == "true",
+			Plate:   query.Get("plate"),
+		}
+		if monitors := query.Get("monitors"); monitors != "" {
+			q.Monitors = strings.Split(monitors, ",")
+		}
+
+		snapshots, err := monitor.QueryEventSnapshots(snapshotsDir, q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(snapshots); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingPlayback serves video by exact recording ID. Unlike RecordingVideo
+// it remuxes files that browsers can't play to fragmented MP4 on the fly.
+// Seeking isn't supported for remuxed files.
+func RecordingPlayback(
+	logger *log.Logger,
+	recordingsDirs []string,
+	videoReaderCache *storage.VideoCache,
+	remuxer *ffmpeg.FFMPEG,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		recID := strings.TrimPrefix(r.URL.Path, "/api/recording/playback/")
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+		path := filepath.Join(recordingsDir, recPath)
+		if containsDotDot(path) {
+			http.Error(w, "invalid recording ID", http.StatusBadRequest)
+			return
+		}
+
+		logf := func(format string, a ...interface{}) {
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("playback request: "+format, a...),
+			})
+		}
+
+		source, err := storage.FindPlaybackSource(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "recording does not exist", http.StatusNotFound)
+				return
+			}
+			logf("%v", err)
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case source.Format == storage.PlaybackMeta:
+			video, err := storage.NewVideoReader(source.Path, videoReaderCache)
+			if err != nil {
+				logf("%v", err)
+				http.Error(w, "see logs for details", http.StatusInternalServerError)
+				return
+			}
+			defer video.Close()
+
+			ServeMP4Content(w, r, video.ModTime(), video.Size(), video)
+
+		case !source.Remux:
+			if err := serveMP4File(w, r, source.Path); err != nil {
+				logf("%v", err)
+				http.Error(w, "see logs for details", http.StatusInternalServerError)
+			}
+
+		default:
+			w.Header().Set("Content-Type", "video/mp4")
+			inputFormat := ""
+			if source.Format == storage.PlaybackH264 {
+				inputFormat = "h264"
+			}
+			err := remuxSource(r.Context(), w, remuxer, source, inputFormat)
+			if err != nil && r.Context().Err() == nil {
+				logf("remux %v: %v", recID, err)
+			}
+		}
+	})
+}
+
+// remuxSource remuxes the source, encrypted sources are
+// decrypted to a temporary file since FFmpeg can't read them.
+func remuxSource(
+	ctx context.Context,
+	w io.Writer,
+	remuxer *ffmpeg.FFMPEG,
+	source storage.PlaybackSource,
+	inputFormat string,
+) error {
+	if !source.Encrypted {
+		return remuxer.Remux(ctx, w, source.Path, inputFormat)
+	}
+
+	video, err := source.Open(nil)
+	if err != nil {
+		return err
+	}
+	defer video.Close()
+
+	tmpFile, err := os.CreateTemp("", "nvr-remux-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := io.Copy(tmpFile, video); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return remuxer.Remux(ctx, w, tmpFile.Name(), inputFormat)
+}
+
+// RecordingIndex serves the seek index of a recording by exact recording ID.
+func RecordingIndex(
+	logger *log.Logger,
+	recordingsDirs []string,
+	videoReaderCache *storage.VideoCache,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		recID := strings.TrimPrefix(r.URL.Path, "/api/recording/index/")
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+		path := filepath.Join(recordingsDir, recPath)
+		if containsDotDot(path) {
+			http.Error(w, "invalid recording ID", http.StatusBadRequest)
+			return
+		}
+
+		video, err := storage.NewVideoReader(path, videoReaderCache)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "recording does not exist", http.StatusNotFound)
+				return
+			}
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("video index request: %v", err),
+			})
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+		defer video.Close()
+
+		w.Header().Set("Content-Type", jsonContentType)
+		err = json.NewEncoder(w).Encode(video.Index())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RecordingVOD serves HLS VOD playlists that span the recordings of a
+// monitor between two timestamps, and the segments they reference.
+// Optional "active=true" only includes segments with activity.
+// Optional "time" is the RFC3339 wall-clock time to start playing from.
+//
+//	/api/recording/vod/playlist.m3u8?monitor=x&start=<RFC3339>&end=<RFC3339>
+//	/api/recording/vod/init.mp4?id=<recording-id>
+//	/api/recording/vod/segment.m4s?id=<recording-id>&n=<fragment>
+func RecordingVOD( //nolint:funlen
+	logger *log.Logger,
+	recordingsDirs []string,
+	videoReaderCache *storage.VideoCache,
+) http.Handler {
+	logError := func(w http.ResponseWriter, err error) {
+		logger.Log(log.Entry{
+			Level: log.LevelError,
+			Src:   "app",
+			Msg:   fmt.Sprintf("vod request: %v", err),
+		})
+		http.Error(w, "see logs for details", http.StatusInternalServerError)
+	}
+
+	playlist := func(w http.ResponseWriter, query url.Values) {
+		start, err := time.Parse(time.RFC3339, query.Get("start"))
+		if err != nil {
+			http.Error(w, "invalid start", http.StatusBadRequest)
+			return
+		}
+		end, err := time.Parse(time.RFC3339, query.Get("end"))
+		if err != nil {
+			http.Error(w, "invalid end", http.StatusBadRequest)
+			return
+		}
+
+		segments, err := storage.VODSegments(recordingsDirs, query.Get("monitor"), start, end)
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidVODRange) ||
+				errors.Is(err, storage.ErrInvalidMonitorID) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logError(w, err)
+			return
+		}
+		if len(segments) == 0 {
+			http.Error(w, "no recordings in time range", http.StatusNotFound)
+			return
+		}
+		if query.Get("active") == "true" {
+			segments = storage.ActiveVODSegments(segments)
+			if len(segments) == 0 {
+				http.Error(w, "no activity in time range", http.StatusNotFound)
+				return
+			}
+		}
+
+		var at time.Time
+		if rawTime := query.Get("time"); rawTime != "" {
+			at, err = time.Parse(time.RFC3339, rawTime)
+			if err != nil {
+				http.Error(w, "invalid time", http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write(storage.GenerateVODPlaylist(segments, at)) //nolint:errcheck
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		file := strings.TrimPrefix(r.URL.Path, "/api/recording/vod/")
+		if file == "playlist.m3u8" {
+			playlist(w, query)
+			return
+		}
+		if file != "init.mp4" && file != "segment.m4s" {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		recID := query.Get("id")
+		recPath, err := storage.RecordingIDToPath(recID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recordingsDir := storage.FindRecordingsDir(recordingsDirs, recPath)
+		path := filepath.Join(recordingsDir, recPath)
+		if containsDotDot(path) {
+			http.Error(w, "invalid recording ID", http.StatusBadRequest)
+			return
+		}
+
+		var content []byte
+		if file == "init.mp4" {
+			content, err = storage.VODInit(path, videoReaderCache)
+		} else {
+			n, err2 := strconv.Atoi(query.Get("n"))
+			if err2 != nil {
+				http.Error(w, "invalid segment number", http.StatusBadRequest)
+				return
+			}
+			content, err = storage.VODSegmentData(path, n, videoReaderCache)
+		}
+		switch {
+		case errors.Is(err, os.ErrNotExist), errors.Is(err, storage.ErrVODSegmentNotExist):
+			http.Error(w, "", http.StatusNotFound)
+			return
+		case err != nil:
+			logError(w, err)
+			return
+		}
+
+		// Recordings are immutable once finished.
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		w.Write(content) //nolint:errcheck
+	})
+}
+
+// RecordingActivity returns the number of recordings and events of each
+// monitor per hour or minute, used to render a activity heatmap.
+//
+//	/api/recording/activity?start=<RFC3339>&end=<RFC3339>&interval=hour&monitors=x,y
+func RecordingActivity(logger log.ILogger, recordingsDirs []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		start, err := time.Parse(time.RFC3339, query.Get("start"))
+		if err != nil {
+			http.Error(w, "invalid start", http.StatusBadRequest)
+			return
+		}
+		end, err := time.Parse(time.RFC3339, query.Get("end"))
+		if err != nil {
+			http.Error(w, "invalid end", http.StatusBadRequest)
+			return
+		}
+		interval := query.Get("interval")
+		if interval == "" {
+			interval = storage.HeatmapHour
+		}
+
+		heatmap, err := storage.NewActivityHeatmap(
+			recordingsDirs, parseCSVParam(query, "monitors"), start, end, interval)
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidHeatmapRange) ||
+				errors.Is(err, storage.ErrInvalidHeatmapInterval) ||
+				errors.Is(err, storage.ErrInvalidMonitorID) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("recording activity: %v", err),
+			})
+			http.Error(w, "see logs for details", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(heatmap); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func containsDotDot(v string) bool {
+	if !strings.Contains(v, "..") {
+		return false
+	}
+	for _, ent := range strings.FieldsFunc(v, isSlashRune) {
+		if ent == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func isSlashRune(r rune) bool { return r == '/' || r == '\\' }
+
+// recordingQueryList handles recording queries with list parameters.
+// The cursor is a opaque token, see recordingCursor.
+func recordingQueryList(
+	w http.ResponseWriter,
+	r *http.Request,
+	crawler *storage.Crawler,
+	logger log.ILogger,
+	list listQuery,
+) {
+	query := r.URL.Query()
+	q, err := newCrawlerQuery(
+		list.pageSize, "", list.sortedBy("id", false), query.Get("data") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Monitors = parseCSVParam(query, "monitors")
+	q.Filter, err = parseRecordingFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := recordingListPage(crawler, q, list)
+	switch {
+	case errors.Is(err, ErrInvalidCursor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		logger.Log(log.Entry{
+			Level: log.LevelError,
+			Src:   "app",
+			Msg:   fmt.Sprintf("crawler: could not process recording query: %v", err),
+		})
+		http.Error(w, "could not process recording query", http.StatusInternalServerError)
+		return
+	}
+	writeListPage(w, page)
+}
+
+// RecordingQuery handles recording query.
+func RecordingQuery(crawler *storage.Crawler, logger log.ILogger) http.Handler { //nolint:funlen
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+
+		list, err := parseListQuery(query, "id", "-id")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if list.set {
+			recordingQueryList(w, r, crawler, logger, list)
+			return
+		}
+
+		limit := query.Get("limit")
+		if limit == "" {
+			http.Error(w, "limit missing", http.StatusBadRequest)
+			return
+		}
+
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not convert limit to int: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		time := query.Get("time")
+		if time == "" {
+			http.Error(w, "time missing", http.StatusBadRequest)
+			return
+		}
+		if len(time) < 19 {
+			http.Error(w, "time value to short", http.StatusBadRequest)
+			return
+		}
+		reverse := query.Get("reverse")
+
+		monitorsCSV := query.Get("monitors")
+
+		var monitors []string
+		if monitorsCSV != "" {
+			monitors = strings.Split(monitorsCSV, ",")
+		}
+
+		var data bool
+		if query.Get("data") == "true" {
+			data = true
+		}
+
+		filter, err := parseRecordingFilter(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := &storage.CrawlerQuery{
+			Time:        time,
+			Limit:       limitInt,
+			Reverse:     reverse == "true",
+			Monitors:    monitors,
+			IncludeData: data,
+			Filter:      filter,
+		}
+
+		recordings, err := crawler.RecordingByQuery(q)
+		if err != nil {
+			logger.Log(log.Entry{
+				Level: log.LevelError,
+				Src:   "app",
+				Msg:   fmt.Sprintf("crawler: could not process recording query: %v", err),
+			})
+			http.Error(w, "could not process recording query", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		err = json.NewEncoder(w).Encode(recordings)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// StreamRecommender recommends the live stream of a monitor for
+// a client based on the speed test result of its session.
+type StreamRecommender struct {
+	store    *speedtest.Store
+	a        auth.Authenticator
+	monitors *monitor.Manager
+	video    *video.Server
+}
+
+// NewStreamRecommender creates a new stream recommender.
+func NewStreamRecommender(
+	store *speedtest.Store,
+	a auth.Authenticator,
+	monitors *monitor.Manager,
+	video *video.Server,
+) *StreamRecommender {
+	return &StreamRecommender{
+		store:    store,
+		a:        a,
+		monitors: monitors,
+		video:    video,
+	}
+}
+
+// StreamRecommendation is the response of SpeedTestRecommend.
+type StreamRecommendation struct {
+	speedtest.Recommendation
+	Result      speedtest.Result `json:"result"`
+	MainBitrate int64            `json:"mainBitrate"` // Zero if unknown.
+	SubBitrate  int64            `json:"subBitrate"`
+}
+
+func (s *StreamRecommender) session(r *http.Request) string {
+	return speedtest.SessionKey(s.a.ValidateRequest(r).User.ID, auth.ClientIP(r))
+}
+
+// Recommend returns false if the session doesn't have a speed test result.
+func (s *StreamRecommender) Recommend(r *http.Request, monitorID string) (StreamRecommendation, bool) {
+	if s == nil {
+		return StreamRecommendation{}, false
+	}
+	result, exist := s.store.Get(s.session(r), time.Now())
+	if !exist {
+		return StreamRecommendation{}, false
+	}
+
+	// The bitrates are unknown if the streams aren't running.
+	mainBitrate, _ := s.video.StreamBitrate(r.Context(), video.PathName(monitorID, false))
+	subBitrate, _ := s.video.StreamBitrate(r.Context(), video.PathName(monitorID, true))
+	subAvailable := s.monitors.MonitorsInfo()[monitorID]["subInputEnabled"] == "true"
+
+	return StreamRecommendation{
+		Recommendation: speedtest.Recommend(result, mainBitrate, subBitrate, subAvailable),
+		Result:         result,
+		MainBitrate:    mainBitrate,
+		SubBitrate:     subBitrate,
+	}, true
+}
+
+// SpeedTest opens a websocket that measures the latency and throughput
+// to the client and stores the result for the session. See speedtest.Run.
+func SpeedTest(s *StreamRecommender) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		session := s.session(r)
+
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.SetReadDeadline(time.Now().Add(speedTestTimeout))  //nolint:errcheck
+		c.SetWriteDeadline(time.Now().Add(speedTestTimeout)) //nolint:errcheck
+
+		result, err := speedtest.Run(c)
+		if err != nil {
+			return
+		}
+		s.store.Set(session, result)
+	})
+}
+
+const speedTestTimeout = 30 * time.Second
+
+// SpeedTestRecommend recommends the live stream of a monitor
+// based on the speed test result of the session.
+func SpeedTestRecommend(s *StreamRecommender) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		monitorID := r.URL.Query().Get("monitor")
+		if monitorID == "" {
+			http.Error(w, "monitor missing", http.StatusBadRequest)
+			return
+		}
+
+		rec, exist := s.Recommend(r, monitorID)
+		if !exist {
+			http.Error(w, "no speed test result for this session", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(rec); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MonitorRenditions returns the live streams of a monitor with their
+// resolution and bitrate, so clients can pick the sub stream for grid
+// tiles and the main stream when a monitor is maximized.
+func MonitorRenditions(s *video.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		renditions := s.Renditions(r.Context(), id)
+		if len(renditions) == 0 {
+			http.Error(w, "monitor is not running", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(renditions); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// LiveMSE opens a websocket that streams the live video of a monitor as
+// fMP4 for Media Source Extensions. The first message is the codecs
+// string, followed by the init segment and the media parts. The sub
+// stream is selected from the speed test result if "sub" is "auto".
+// Streams count towards the live session limit of the user.
+func LiveMSE(
+	s *video.Server,
+	recommender *StreamRecommender,
+	sessions *LiveSessions,
+	a auth.Authenticator,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		monitorID := strings.TrimPrefix(r.URL.Path, "/api/live/")
+		if monitorID == "" || strings.Contains(monitorID, "/") {
+			http.Error(w, "invalid monitor id", http.StatusBadRequest)
+			return
+		}
+		var isSub bool
+		switch r.URL.Query().Get("sub") {
+		case "true":
+			isSub = true
+		case "auto":
+			rec, ok := recommender.Recommend(r, monitorID)
+			isSub = ok && rec.Sub
+		}
+		pathName := video.PathName(monitorID, isSub)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		muxer, err := s.LiveMuxer(ctx, pathName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		initContent, err := muxer.Init()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sessionKey, err := sessions.acquireMSE(
+			a.ValidateRequest(r).User, auth.ClientIP(r), pathName, cancel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		defer sessions.release(sessionKey)
+
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		// Read until the client disconnects.
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := c.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		parts := muxer.Subscribe(ctx)
+
+		write := func(messageType int, data []byte) error {
+			c.SetWriteDeadline(time.Now().Add(liveWriteTimeout)) //nolint:errcheck
+			if err := c.WriteMessage(messageType, data); err != nil {
+				return err
+			}
+			sessions.addBytes(sessionKey, int64(len(data)))
+			return nil
+		}
+		if err := write(websocket.TextMessage, []byte(muxer.Codecs())); err != nil {
+			return
+		}
+		if err := write(websocket.BinaryMessage, initContent); err != nil {
+			return
+		}
+		for part := range parts {
+			if err := write(websocket.BinaryMessage, part.Content()); err != nil {
+				return
+			}
+		}
+	})
+}
+
+const liveWriteTimeout = 10 * time.Second
+
+// MonitorEvents opens a websocket with the live events of the monitors.
+// Optional monitors query parameter is a comma separated list of IDs.
+// Optional cursor query parameter resumes the feed after that event.
+func MonitorEvents(m *monitor.Manager, a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		history := m.EventHistory()
+		cursor, err := parseFeedCursor(r, history.Cursor())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		match := monitorEventsMatch(parseCSVParam(r.URL.Query(), "monitors"))
+
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		serveFeedWebsocket(r.Context(), c, history, cursor, match, func() bool {
+			return a.ValidateRequest(r).IsValid
+		}, newLiveEventMessage)
+	})
+}
+
+// MonitorEventsPoll is the fallback of MonitorEvents for
+// clients where websockets are blocked. See serveFeedFallback.
+func MonitorEventsPoll(m *monitor.Manager, a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		match := monitorEventsMatch(parseCSVParam(r.URL.Query(), "monitors"))
+
+		serveFeedFallback(w, r, m.EventHistory(), match, func() bool {
+			return a.ValidateRequest(r).IsValid
+		}, newLiveEventMessage)
+	})
+}
+
+func monitorEventsMatch(monitors []string) func(monitor.LiveEvent) bool {
+	return func(event monitor.LiveEvent) bool {
+		return log.StringInStrings(event.MonitorID, monitors)
+	}
+}
+
+type liveEventMessage struct {
+	monitor.LiveEvent
+	Cursor uint64 `json:"cursor"`
+}
+
+func newLiveEventMessage(item feed.Item[monitor.LiveEvent]) interface{} {
+	return liveEventMessage{LiveEvent: item.Value, Cursor: item.Cursor}
+}
+
+// LogFeed opens a websocket with system logs. Optional cursor
+// or resume query parameter resumes the feed after that entry.
+func LogFeed(logHistory *feed.Buffer[log.Entry], a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		match, err := parseLogFeedQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cursor, err := parseLogFeedCursor(r, logHistory)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		serveFeedWebsocket(r.Context(), c, logHistory, cursor, match, func() bool {
+			auth := a.ValidateRequest(r)
+			return auth.IsValid && auth.User.IsAdmin
+		}, newLogFeedMessage(logHistory))
+	})
+}
+
+// LogFeedPoll is the fallback of LogFeed for
+// clients where websockets are blocked. See serveFeedFallback.
+func LogFeedPoll(logHistory *feed.Buffer[log.Entry], a auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		match, err := parseLogFeedQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		serveFeedFallback(w, r, logHistory, match, func() bool {
+			auth := a.ValidateRequest(r)
+			return auth.IsValid && auth.User.IsAdmin
+		}, newLogFeedMessage(logHistory))
+	})
+}
+
+func parseLogFeedQuery(query url.Values) (func(log.Entry) bool, error) {
+	levelsCSV := query.Get("levels")
+	var levels []log.Level
+	if levelsCSV != "" {
+		for _, levelStr := range strings.Split(levelsCSV, ",") {
+			levelInt, err := strconv.Atoi(levelStr)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v %v", ErrInvalidLevels, levelsCSV, err)
+			}
+			levels = append(levels, log.Level(levelInt))
+		}
+	}
+	sources := parseCSVParam(query, "sources")
+	monitors := parseCSVParam(query, "monitors")
+
+	return func(entry log.Entry) bool {
+		return log.LevelInLevels(entry.Level, levels) &&
+			log.StringInStrings(entry.Src, sources) &&
+			log.StringInStrings(entry.MonitorID, monitors)
+	}, nil
+}
+
+type logFeedMessage struct {
+	log.Entry
+	Cursor uint64 `json:"cursor"`
+
+	// Resume token, see parseLogFeedCursor.
+	Token string `json:"token"`
+}
+
+func newLogFeedMessage(logHistory *feed.Buffer[log.Entry]) func(feed.Item[log.Entry]) interface{} {
+	return func(item feed.Item[log.Entry]) interface{} {
+		return logFeedMessage{
+			Entry:  item.Value,
+			Cursor: item.Cursor,
+			Token:  logHistory.Token(item.Cursor),
+		}
+	}
+}
+
+// parseLogFeedCursor returns the cursor of the resume query parameter.
+// Unlike cursors, resume tokens survive restarts, a client that
+// reconnects after a restart gets all the buffered entries.
+// Falls back to parseFeedCursor if the parameter is unset.
+func parseLogFeedCursor(r *http.Request, logHistory *feed.Buffer[log.Entry]) (uint64, error) {
+	if token := r.URL.Query().Get("resume"); token != "" {
+		return logHistory.ParseToken(token)
+	}
+	return parseFeedCursor(r, logHistory.Cursor())
+}
+
+// ErrInvalidCursor invalid cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// parseFeedCursor returns the cursor query parameter or the
+// Last-Event-ID header that's sent by reconnecting EventSources.
+// The latest cursor is returned if neither is set.
+func parseFeedCursor(r *http.Request, latest uint64) (uint64, error) {
+	raw := r.URL.Query().Get("cursor")
+	if raw == "" {
+		raw = r.Header.Get("Last-Event-ID")
+	}
+	if raw == "" {
+		return latest, nil
+	}
+	cursor, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCursor, raw)
+	}
+	return min(cursor, latest), nil
+}
+
+// Websocket feed keep-alive. The client is disconnected if
+// it doesn't answer a ping with a pong within feedPongWait.
+const (
+	feedPingInterval = 20 * time.Second
+	feedPongWait     = 60 * time.Second
+)
+
+// serveFeedWebsocket writes the matching items after cursor
+// to the websocket until the client disconnects. Auth is
+// validated before each message. Clients that don't answer
+// pings are disconnected.
+func serveFeedWebsocket[T any](
+	ctx context.Context,
+	c *websocket.Conn,
+	history *feed.Buffer[T],
+	cursor uint64,
+	match func(T) bool,
+	authorized func() bool,
+	newMessage func(feed.Item[T]) interface{},
+) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.SetReadDeadline(time.Now().Add(feedPongWait)) //nolint:errcheck
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(feedPongWait))
+	})
+
+	// Read until the client disconnects or stops answering pings.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := c.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// WriteControl is safe to call concurrently with WriteJSON.
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(feedPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deadline := time.Now().Add(liveWriteTimeout)
+				if err := c.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+					return
+				}
+			}
+		}
+	}()
 
-// RecordingDelete deletes a recording.
-func RecordingDelete(recordingsDir string) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
-			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+	for {
+		items := history.Wait(ctx, cursor)
+		if items == nil {
 			return
 		}
-
-		recID := strings.TrimPrefix(r.URL.Path, "/api/recording/delete/")
-
-		err := storage.DeleteRecording(recordingsDir, recID)
-		if err != nil {
-			if errors.Is(err, storage.ErrInvalidRecordingID) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+		for _, item := range items {
+			cursor = item.Cursor
+			if !match(item.Value) {
+				continue
+			}
+			if !authorized() {
 				return
 			}
-			if errors.Is(err, os.ErrNotExist) {
-				http.Error(w, "", http.StatusNotFound)
+			c.SetWriteDeadline(time.Now().Add(liveWriteTimeout)) //nolint:errcheck
+			if err := c.WriteJSON(newMessage(item)); err != nil {
 				return
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
 		}
-	})
+	}
 }
 
-// RecordingThumbnail serves thumbnail by exact recording ID.
-func RecordingThumbnail(recordingsDir string) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
-			return
-		}
-
-		recID := r.URL.Path[25:] // Trim "/api/recording/thumbnail/"
-		recPath, err := storage.RecordingIDToPath(recID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+// Long-poll timeouts.
+const (
+	feedPollTimeout    = 30 * time.Second
+	feedPollTimeoutMax = 60 * time.Second
+)
 
-		thumbPath := filepath.Join(recordingsDir, recPath+".jpeg")
+// Interval of keep-alive comments in event streams.
+const feedKeepAliveInterval = 15 * time.Second
 
-		// ServeFile will sanitize ".."
-		http.ServeFile(w, r, thumbPath)
-	})
-}
+// serveFeedFallback serves a feed to clients that can't use websockets.
+// Server-sent events are used if the client accepts "text/event-stream",
+// the id of each event is the cursor. Otherwise the request is a
+// long-poll that returns when there are matching items after the
+// cursor, or when the timeout query parameter in seconds expires.
+// The response contains the cursor of the last checked item, which
+// is used in the next request.
+func serveFeedFallback[T any]( //nolint:funlen
+	w http.ResponseWriter,
+	r *http.Request,
+	history *feed.Buffer[T],
+	match func(T) bool,
+	authorized func() bool,
+	newMessage func(feed.Item[T]) interface{},
+) {
+	cursor, err := parseFeedCursor(r, history.Cursor())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-// RecordingVideo serves video by exact recording ID.
-func RecordingVideo(logger *log.Logger, recordingsDir string) http.Handler {
-	videoReaderCache := storage.NewVideoCache()
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
-			return
-		}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		serveFeedEventStream(w, r, history, cursor, match, authorized, newMessage)
+		return
+	}
 
-		recID := r.URL.Path[21:] // Trim "/api/recording/video/"
-		recPath, err := storage.RecordingIDToPath(recID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		path := filepath.Join(recordingsDir, recPath)
-		// Sanitize path.
-		if containsDotDot(path) {
-			http.Error(w, "invalid recording ID", http.StatusBadRequest)
+	timeout := feedPollTimeout
+	if rawTimeout := r.URL.Query().Get("timeout"); rawTimeout != "" {
+		timeoutInt, err := strconv.Atoi(rawTimeout)
+		if err != nil || timeoutInt < 0 {
+			http.Error(w, "invalid timeout: "+rawTimeout, http.StatusBadRequest)
 			return
 		}
+		timeout = min(time.Duration(timeoutInt)*time.Second, feedPollTimeoutMax)
+	}
 
-		mp4Path := path + ".mp4"
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
 
-		_, err = os.Stat(mp4Path)
-		if err == nil { // File exist.
-			http.ServeFile(w, r, mp4Path)
-			return
+	messages := []interface{}{}
+	for len(messages) == 0 {
+		items := history.Wait(ctx, cursor)
+		if items == nil {
+			break
 		}
-		if !errors.Is(err, os.ErrNotExist) {
-			http.Error(w, "stat mp4 file", http.StatusInternalServerError)
-			return
+		for _, item := range items {
+			cursor = item.Cursor
+			if match(item.Value) {
+				messages = append(messages, newMessage(item))
+			}
 		}
+	}
 
-		video, err := storage.NewVideoReader(path, videoReaderCache)
-		if err != nil {
-			logger.Log(log.Entry{
-				Level: log.LevelError,
-				Src:   "app",
-				Msg:   fmt.Sprintf("video request: %v", err),
-			})
-			http.Error(w, "see logs for details", http.StatusInternalServerError)
-		}
-		defer video.Close()
+	response := struct {
+		Cursor uint64        `json:"cursor"`
+		Items  []interface{} `json:"items"`
+	}{
+		Cursor: cursor,
+		Items:  messages,
+	}
 
-		ServeMP4Content(w, r, video.ModTime(), video.Size(), video)
-	})
+	w.Header().Set("content-type", jsonContentType)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
 
-func containsDotDot(v string) bool {
-	if !strings.Contains(v, "..") {
-		return false
+func serveFeedEventStream[T any](
+	w http.ResponseWriter,
+	r *http.Request,
+	history *feed.Buffer[T],
+	cursor uint64,
+	match func(T) bool,
+	authorized func() bool,
+	newMessage func(feed.Item[T]) interface{},
+) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
 	}
-	for _, ent := range strings.FieldsFunc(v, isSlashRune) {
-		if ent == ".." {
-			return true
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	// Disable Nginx buffering.
+	w.Header().Set("x-accel-buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(feedKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		items, notify := history.Since(cursor)
+		for _, item := range items {
+			cursor = item.Cursor
+			if !match(item.Value) {
+				continue
+			}
+			if !authorized() {
+				return
+			}
+			rawMessage, err := json.Marshal(newMessage(item))
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", item.Cursor, rawMessage); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
 		}
 	}
-	return false
 }
 
-func isSlashRune(r rune) bool { return r == '/' || r == '\\' }
-
-// RecordingQuery handles recording query.
-func RecordingQuery(crawler *storage.Crawler, logger *log.Logger) http.Handler { //nolint:funlen
+// LogQuery handles log queries.
+func LogQuery(logStore *log.Store) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
 			return
 		}
-		query := r.URL.Query()
-		limit := query.Get("limit")
-		if limit == "" {
-			http.Error(w, "limit missing", http.StatusBadRequest)
-			return
-		}
 
-		limitInt, err := strconv.Atoi(limit)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("could not convert limit to int: %v", err), http.StatusBadRequest)
+		if format := r.URL.Query().Get("format"); format != "" {
+			exportLogs(w, r, logStore, format)
 			return
 		}
 
-		time := query.Get("time")
-		if time == "" {
-			http.Error(w, "time missing", http.StatusBadRequest)
-			return
-		}
-		if len(time) < 19 {
-			http.Error(w, "time value to short", http.StatusBadRequest)
+		list, err := parseListQuery(r.URL.Query(), "-time")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		reverse := query.Get("reverse")
-
-		monitorsCSV := query.Get("monitors")
-
-		var monitors []string
-		if monitorsCSV != "" {
-			monitors = strings.Split(monitorsCSV, ",")
-		}
-
-		var data bool
-		if query.Get("data") == "true" {
-			data = true
-		}
-
-		q := &storage.CrawlerQuery{
-			Time:        time,
-			Limit:       limitInt,
-			Reverse:     reverse == "true",
-			Monitors:    monitors,
-			IncludeData: data,
-		}
 
-		recordings, err := crawler.RecordingByQuery(q)
+		q, err := parseLogQuery(r.URL.Query(), list)
 		if err != nil {
-			logger.Log(log.Entry{
-				Level: log.LevelError,
-				Src:   "app",
-				Msg:   fmt.Sprintf("crawler: could not process recording query: %v", err),
-			})
-			http.Error(w, "could not process recording query", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		w.Header().Set("Content-Type", jsonContentType)
-		err = json.NewEncoder(w).Encode(recordings)
+		logs, err := logStore.Query(q)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-	})
-}
 
-// LogFeed opens a websocket with system logs.
-func LogFeed(logger *log.Logger, a auth.Authenticator) http.Handler { //nolint:funlen,gocognit
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		if list.set {
+			writeLogList(w, list, q, logs)
 			return
 		}
-		query := r.URL.Query()
-
-		levelsCSV := query.Get("levels")
-		var levels []log.Level
-		if levelsCSV != "" {
-			for _, levelStr := range strings.Split(levelsCSV, ",") {
-				levelInt, err := strconv.Atoi(levelStr)
-				if err != nil {
-					http.Error(w,
-						fmt.Sprintf("invalid levels list: %v %v", levelsCSV, err),
-						http.StatusBadRequest)
-				}
-				levels = append(levels, log.Level(levelInt))
-			}
-		}
 
-		sources := parseCSVParam(query, "sources")
-		monitors := parseCSVParam(query, "monitors")
-
-		q := log.Query{
-			Levels:   levels,
-			Sources:  sources,
-			Monitors: monitors,
-		}
-
-		upgrader := websocket.Upgrader{}
-		c, err := upgrader.Upgrade(w, r, nil)
+		w.Header().Set("Content-Type", jsonContentType)
+		err = json.NewEncoder(w).Encode(logs)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer c.Close()
+	})
+}
 
-		feed, cancel := logger.Subscribe()
-		defer cancel()
+// Log export formats.
+const (
+	logFormatNDJSON = "ndjson"
+	logFormatCSV    = "csv"
+)
 
-		for {
-			var entry log.Entry
-			select {
-			case entry = <-feed:
-			case <-logger.Ctx.Done():
-				return
-			}
+// exportLogs streams all the matching logs, the limit is ignored. The
+// entries are written as they are read, a slow client slows down the read.
+func exportLogs(w http.ResponseWriter, r *http.Request, logStore *log.Store, format string) {
+	query := r.URL.Query()
+	query.Set("limit", "0")
+	if query.Get("time") == "" {
+		query.Set("time", "0")
+	}
+	q, err := parseLogQuery(query, listQuery{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-			if !log.LevelInLevels(entry.Level, q.Levels) {
-				continue
-			}
-			if !log.StringInStrings(entry.Src, q.Sources) {
-				continue
-			}
+	var contentType string
+	switch format {
+	case logFormatNDJSON:
+		contentType = "application/x-ndjson"
+	case logFormatCSV:
+		contentType = "text/csv; charset=utf-8"
+	default:
+		http.Error(w, fmt.Sprintf("invalid format: %q", format), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="logs.`+format+`"`)
 
-			// Validate auth before each message.
-			auth := a.ValidateRequest(r)
-			if !auth.IsValid || !auth.User.IsAdmin {
-				return
-			}
+	out := bufio.NewWriter(w)
+	defer out.Flush()
 
-			if err := c.WriteJSON(entry); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+	write := func(entry log.Entry) error {
+		_, err := out.Write(append(entry.JSON(), '\n'))
+		return err
+	}
+	if format == logFormatCSV {
+		csvWriter := csv.NewWriter(out)
+		defer csvWriter.Flush()
+		csvWriter.Write(log.CSVHeader) //nolint:errcheck
+		write = func(entry log.Entry) error {
+			return csvWriter.Write(entry.CSV())
 		}
-	})
+	}
+
+	// Errors can't be reported after the response has started.
+	logStore.Export(r.Context(), q, write) //nolint:errcheck
 }
 
-// LogQuery handles log queries.
-func LogQuery(logStore *log.Store) http.Handler {
+// LogSearch handles log queries that match the message
+// against a case-insensitive substring or a regular expression.
+func LogSearch(logStore *log.Store) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
@@ -647,56 +3942,42 @@ func LogQuery(logStore *log.Store) http.Handler {
 		}
 		query := r.URL.Query()
 
-		limit := query.Get("limit")
-		if limit == "" {
-			http.Error(w, "limit missing", http.StatusBadRequest)
+		list, err := parseListQuery(query, "-time")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		limitInt, err := strconv.Atoi(limit)
+		q, err := parseLogQuery(query, list)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("could not convert limit to int: %v", err), http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		levelsCSV := query.Get("levels")
-		var levels []log.Level
-		if levelsCSV != "" {
-			for _, levelStr := range strings.Split(levelsCSV, ",") {
-				levelInt, err := strconv.Atoi(levelStr)
-				if err != nil {
-					http.Error(w,
-						fmt.Sprintf("invalid levels list: %v %v", levelsCSV, err),
-						http.StatusBadRequest)
-				}
-				levels = append(levels, log.Level(levelInt))
+		q.Substring = query.Get("text")
+		if expr := query.Get("regex"); expr != "" {
+			q.Regex, err = regexp.Compile(expr)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid regex: %v", err), http.StatusBadRequest)
+				return
 			}
 		}
-
-		sources := parseCSVParam(query, "sources")
-		monitors := parseCSVParam(query, "monitors")
-
-		time := query.Get("time")
-		timeInt, err := strconv.Atoi(time)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("could not convert time to int: %v", err), http.StatusBadRequest)
+		if q.Substring == "" && q.Regex == nil {
+			http.Error(w, "text or regex missing", http.StatusBadRequest)
 			return
 		}
 
-		q := log.Query{
-			Levels:   levels,
-			Sources:  sources,
-			Monitors: monitors,
-			Time:     log.UnixMicro(timeInt),
-			Limit:    limitInt,
-		}
-
 		logs, err := logStore.Query(q)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if list.set {
+			writeLogList(w, list, q, logs)
+			return
+		}
+
 		w.Header().Set("Content-Type", jsonContentType)
 		err = json.NewEncoder(w).Encode(logs)
 		if err != nil {
@@ -706,6 +3987,79 @@ func LogQuery(logStore *log.Store) http.Handler {
 	})
 }
 
+// Errors.
+var (
+	ErrLimitMissing  = errors.New("limit missing")
+	ErrInvalidLevels = errors.New("invalid levels list")
+)
+
+// writeLogList writes the logs as a list page. The cursor
+// is the time of the last entry of the previous page.
+func writeLogList(w http.ResponseWriter, list listQuery, q log.Query, logs []log.Entry) {
+	items, err := toListItems(logs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := listPage{Items: list.project(items)}
+	if len(logs) == q.Limit {
+		page.Next = strconv.FormatUint(uint64(logs[len(logs)-1].Time), 10)
+	}
+	writeListPage(w, page)
+}
+
+// parseLogQuery parses the log query parameters. The page size and cursor
+// replace the limit and time parameters if the list parameters are set.
+func parseLogQuery(query url.Values, list listQuery) (log.Query, error) {
+	limit := query.Get("limit")
+	if list.set {
+		limit = strconv.Itoa(list.pageSize)
+	}
+	if limit == "" {
+		return log.Query{}, ErrLimitMissing
+	}
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		return log.Query{}, fmt.Errorf("could not convert limit to int: %w", err)
+	}
+
+	levelsCSV := query.Get("levels")
+	var levels []log.Level
+	if levelsCSV != "" {
+		for _, levelStr := range strings.Split(levelsCSV, ",") {
+			levelInt, err := strconv.Atoi(levelStr)
+			if err != nil {
+				return log.Query{}, fmt.Errorf("%w: %v %v", ErrInvalidLevels, levelsCSV, err)
+			}
+			levels = append(levels, log.Level(levelInt))
+		}
+	}
+
+	sources := parseCSVParam(query, "sources")
+	monitors := parseCSVParam(query, "monitors")
+
+	time := query.Get("time")
+	if list.set {
+		time = list.cursor
+		if time == "" {
+			time = "0"
+		}
+	}
+	timeInt, err := strconv.Atoi(time)
+	if err != nil {
+		return log.Query{}, fmt.Errorf("could not convert time to int: %w", err)
+	}
+
+	return log.Query{
+		Levels:   levels,
+		Sources:  sources,
+		Monitors: monitors,
+		Time:     log.UnixMicro(timeInt),
+		Limit:    limitInt,
+	}, nil
+}
+
 func parseCSVParam(query url.Values, key string) []string {
 	CSV := query.Get(key)
 	var monitors []string
@@ -715,7 +4069,7 @@ func parseCSVParam(query url.Values, key string) []string {
 	return monitors
 }
 
-// LogSources handles list of log sources.
+// LogSources handles list of log sources and their levels.
 func LogSources(l *log.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -724,7 +4078,7 @@ func LogSources(l *log.Logger) http.Handler {
 		}
 
 		w.Header().Set("Content-Type", jsonContentType)
-		err := json.NewEncoder(w).Encode(l.Sources())
+		err := json.NewEncoder(w).Encode(l.Levels())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -732,6 +4086,27 @@ func LogSources(l *log.Logger) http.Handler {
 	})
 }
 
+// LogLevel sets the level of a source at runtime, the level is persisted.
+//
+//	POST /api/log/level?source=monitor&level=warning
+func LogLevel(l *log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		err := l.SetLevel(query.Get("source"), query.Get("level"))
+		switch {
+		case errors.Is(err, log.ErrUnknownSource), errors.Is(err, log.ErrInvalidLevel):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
 func containsSpaces(s string) bool {
 	return strings.Contains(s, " ")
 }
@@ -1,9 +1,36 @@
 package web
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"nvr/pkg/export"
+	"nvr/pkg/feed"
+	"nvr/pkg/group"
+	"nvr/pkg/kv"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/transcode"
+	"nvr/pkg/update"
+	"nvr/pkg/web/auth"
+	"nvr/pkg/zones"
+
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,3 +51,1148 @@ func TestParseCSVParam(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPublicStatusMonitors(t *testing.T) {
+	ok := monitor.InputHealth{State: monitor.HealthOK}
+	stalled := monitor.InputHealth{State: monitor.HealthStalled}
+	health := map[string]monitor.Health{
+		"1": {Main: ok},
+		"2": {Main: ok, Sub: &ok},
+		"3": {Main: stalled},
+		"4": {Main: ok, Sub: &stalled},
+	}
+	expected := &publicStatusMonitors{Total: 4, Healthy: 2}
+	require.Equal(t, expected, newPublicStatusMonitors(health))
+}
+
+func TestPublicStorageStatus(t *testing.T) {
+	require.Equal(t, "ok", publicStorageStatus(storage.DiskUsage{Percent: 50}, nil))
+	require.Equal(t, "full", publicStorageStatus(storage.DiskUsage{Percent: 99}, nil))
+	require.Equal(t, "error", publicStorageStatus(storage.DiskUsage{}, errors.New("x")))
+}
+
+func TestParseLogQuery(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		query, err := url.ParseQuery("levels=16,24&sources=app&monitors=a,b&time=5&limit=2")
+		require.NoError(t, err)
+
+		q, err := parseLogQuery(query, listQuery{})
+		require.NoError(t, err)
+
+		expected := log.Query{
+			Levels:   []log.Level{16, 24},
+			Sources:  []string{"app"},
+			Monitors: []string{"a", "b"},
+			Time:     5,
+			Limit:    2,
+		}
+		require.Equal(t, expected, q)
+	})
+	t.Run("limitMissing", func(t *testing.T) {
+		_, err := parseLogQuery(url.Values{}, listQuery{})
+		require.ErrorIs(t, err, ErrLimitMissing)
+	})
+	t.Run("list", func(t *testing.T) {
+		query, err := url.ParseQuery("page[size]=3&page[cursor]=7")
+		require.NoError(t, err)
+		list, err := parseListQuery(query, "-time")
+		require.NoError(t, err)
+
+		q, err := parseLogQuery(query, list)
+		require.NoError(t, err)
+		require.Equal(t, log.Query{Time: 7, Limit: 3}, q)
+
+		query, err = url.ParseQuery("sort=-time")
+		require.NoError(t, err)
+		list, err = parseListQuery(query, "-time")
+		require.NoError(t, err)
+
+		q, err = parseLogQuery(query, list)
+		require.NoError(t, err)
+		require.Equal(t, log.Query{Limit: defaultPageSize}, q)
+	})
+	t.Run("levelsErr", func(t *testing.T) {
+		query, err := url.ParseQuery("levels=x&time=5&limit=2")
+		require.NoError(t, err)
+
+		_, err = parseLogQuery(query, listQuery{})
+		require.ErrorIs(t, err, ErrInvalidLevels)
+	})
+}
+
+func TestLogQueryExport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	logger := log.NewLogger(wg, nil)
+	require.NoError(t, logger.Start(ctx))
+	store, err := log.NewStore(t.TempDir(), wg, nil)
+	require.NoError(t, err)
+	store.SaveLogs(ctx, logger)
+
+	// Entries are dropped until the store has subscribed.
+	require.Eventually(t, func() bool {
+		logger.Log(log.Entry{Level: log.LevelDebug, Src: "test", Msg: "ready"})
+		entries, err := store.Query(log.Query{Sources: []string{"test"}, Limit: 1})
+		return err == nil && len(entries) != 0
+	}, time.Second, 10*time.Millisecond)
+
+	logger.Log(log.Entry{Level: log.LevelInfo, Src: "app", Msg: "a"})
+	logger.Log(log.Entry{Level: log.LevelError, Src: "app", MonitorID: "m1", Msg: "b,c"})
+	require.Eventually(t, func() bool {
+		entries, err := store.Query(log.Query{Sources: []string{"app"}})
+		return err == nil && len(entries) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		LogQuery(store).ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/api/log/query?"+query, nil))
+		return w
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		w := serve("format=ndjson&sources=app")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		var entry struct{ Level, Msg string }
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		require.Equal(t, "error", entry.Level)
+		require.Equal(t, "b,c", entry.Msg)
+	})
+	t.Run("csv", func(t *testing.T) {
+		w := serve("format=csv&levels=32")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, log.CSVHeader, records[0])
+		require.Equal(t, []string{"info", "app", "", "a"}, records[1][1:])
+	})
+	t.Run("invalidFormat", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, serve("format=x").Code)
+	})
+	t.Run("levelsErr", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, serve("format=csv&levels=x").Code)
+	})
+}
+
+func TestLogFeed(t *testing.T) {
+	history := feed.NewBuffer[log.Entry](10)
+	history.Push(log.Entry{Src: "app", Msg: "a"})
+	history.Push(log.Entry{Src: "app", Msg: "b"})
+
+	a := stubAuth{user: auth.Account{IsAdmin: true}}
+	server := httptest.NewServer(LogFeed(history, a))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/log/feed?"
+
+	dial := func(t *testing.T, query string) *websocket.Conn {
+		t.Helper()
+		c, _, err := websocket.DefaultDialer.Dial(url+query, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	read := func(t *testing.T, c *websocket.Conn) logFeedMessage {
+		t.Helper()
+		require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+		var msg logFeedMessage
+		require.NoError(t, c.ReadJSON(&msg))
+		return msg
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		msg := read(t, dial(t, "cursor=0"))
+		require.Equal(t, "a", msg.Msg)
+		require.Equal(t, history.Token(1), msg.Token)
+
+		msg = read(t, dial(t, "resume="+msg.Token))
+		require.Equal(t, "b", msg.Msg)
+	})
+	t.Run("resumeAfterRestart", func(t *testing.T) {
+		// Tokens from before a restart resume from the start.
+		token := feed.NewBuffer[log.Entry](10).Token(2)
+		c := dial(t, "resume="+token)
+		require.Equal(t, "a", read(t, c).Msg)
+		require.Equal(t, "b", read(t, c).Msg)
+	})
+	t.Run("invalidToken", func(t *testing.T) {
+		_, res, err := websocket.DefaultDialer.Dial(url+"resume=x", nil)
+		require.Error(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+func TestParseFeedCursor(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		header   string
+		expected uint64
+		err      error
+	}{
+		{"latest", "", "", 10, nil},
+		{"query", "cursor=5", "", 5, nil},
+		{"header", "", "6", 6, nil},
+		{"queryFirst", "cursor=5", "6", 5, nil},
+		{"future", "cursor=20", "", 10, nil},
+		{"invalid", "cursor=x", "", 0, ErrInvalidCursor},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+			if tc.header != "" {
+				r.Header.Set("Last-Event-ID", tc.header)
+			}
+			cursor, err := parseFeedCursor(r, 10)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.expected, cursor)
+		})
+	}
+}
+
+func TestParseLogFeedQuery(t *testing.T) {
+	query := url.Values{
+		"levels":   []string{"16,24"},
+		"sources":  []string{"a"},
+		"monitors": []string{"b"},
+	}
+	match, err := parseLogFeedQuery(query)
+	require.NoError(t, err)
+
+	require.True(t, match(log.Entry{Level: 16, Src: "a", MonitorID: "b"}))
+	require.False(t, match(log.Entry{Level: 32, Src: "a", MonitorID: "b"}))
+	require.False(t, match(log.Entry{Level: 16, Src: "x", MonitorID: "b"}))
+	require.False(t, match(log.Entry{Level: 16, Src: "a", MonitorID: "x"}))
+
+	_, err = parseLogFeedQuery(url.Values{"levels": []string{"x"}})
+	require.ErrorIs(t, err, ErrInvalidLevels)
+}
+
+func TestServeFeedFallback(t *testing.T) {
+	match := func(v string) bool { return v != "skip" }
+	authorized := func() bool { return true }
+	newMessage := func(item feed.Item[string]) interface{} { return item.Value }
+
+	type pollResponse struct {
+		Cursor uint64   `json:"cursor"`
+		Items  []string `json:"items"`
+	}
+	poll := func(t *testing.T, history *feed.Buffer[string], query string) pollResponse {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		w := httptest.NewRecorder()
+		serveFeedFallback(w, r, history, match, authorized, newMessage)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res pollResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		return res
+	}
+
+	t.Run("poll", func(t *testing.T) {
+		history := feed.NewBuffer[string](10)
+		history.Push("a")
+		history.Push("skip")
+		history.Push("b")
+
+		res := poll(t, history, "cursor=0")
+		require.Equal(t, pollResponse{Cursor: 3, Items: []string{"a", "b"}}, res)
+	})
+	t.Run("pollSkipped", func(t *testing.T) {
+		history := feed.NewBuffer[string](10)
+		history.Push("a")
+		history.Push("skip")
+
+		// Only non-matching items, wait until the timeout.
+		res := poll(t, history, "cursor=1&timeout=0")
+		require.Equal(t, pollResponse{Cursor: 2, Items: []string{}}, res)
+	})
+	t.Run("pollWait", func(t *testing.T) {
+		history := feed.NewBuffer[string](10)
+		history.Push("a")
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			history.Push("b")
+		}()
+		res := poll(t, history, "")
+		require.Equal(t, pollResponse{Cursor: 2, Items: []string{"b"}}, res)
+	})
+	t.Run("invalidTimeout", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?timeout=x", nil)
+		w := httptest.NewRecorder()
+		serveFeedFallback(w, r, feed.NewBuffer[string](1), match, authorized, newMessage)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("eventStream", func(t *testing.T) {
+		history := feed.NewBuffer[string](10)
+		history.Push("a")
+		history.Push("skip")
+		history.Push("b")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		r.Header.Set("Accept", "text/event-stream")
+		r.Header.Set("Last-Event-ID", "1")
+		w := httptest.NewRecorder()
+		serveFeedFallback(w, r, history, match, authorized, newMessage)
+
+		require.Equal(t, "text/event-stream", w.Header().Get("content-type"))
+		expected := strings.Join([]string{
+			"id: 3",
+			`data: "b"`,
+			"", "",
+		}, "\n")
+		require.Equal(t, expected, w.Body.String())
+	})
+}
+
+func TestRecordingProtect(t *testing.T) {
+	recordingsDir := t.TempDir()
+	recDir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	recID := "2000-01-01_02-02-02_m1"
+	require.NoError(t, os.MkdirAll(recDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(recDir, recID+".json"), nil, 0o600))
+
+	protect := RecordingProtect([]string{recordingsDir})
+	del := RecordingDelete([]string{recordingsDir})
+
+	serve := func(h http.Handler, method string, target string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w.Code
+	}
+
+	code := serve(protect, http.MethodPost, "/api/recording/protect?id="+recID+"&protect=true")
+	require.Equal(t, http.StatusOK, code)
+
+	code = serve(del, http.MethodDelete, "/api/recording?id="+recID)
+	require.Equal(t, http.StatusConflict, code)
+
+	code = serve(protect, http.MethodPost, "/api/recording/protect?id="+recID+"&protect=false")
+	require.Equal(t, http.StatusOK, code)
+
+	code = serve(del, http.MethodDelete, "/api/recording?id="+recID)
+	require.Equal(t, http.StatusOK, code)
+
+	code = serve(del, http.MethodDelete, "/api/recording?id="+recID)
+	require.Equal(t, http.StatusNotFound, code)
+
+	code = serve(protect, http.MethodPost, "/api/recording/protect?id="+recID+"&protect=true")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code = serve(protect, http.MethodPost, "/api/recording/protect?id="+recID+"&protect=x")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code = serve(protect, http.MethodGet, "/api/recording/protect?id="+recID+"&protect=true")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestRecordingDownload(t *testing.T) {
+	recordingsDir := t.TempDir()
+	recDir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	recID := "2000-01-01_02-00-00_m1"
+	require.NoError(t, os.MkdirAll(recDir, 0o700))
+	data := `{"start":"2000-01-01T02:00:00Z","end":"2000-01-01T02:10:00Z"}`
+	require.NoError(t, os.WriteFile(filepath.Join(recDir, recID+".json"), []byte(data), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(recDir, recID+".h264"), []byte("x"), 0o600))
+
+	h := RecordingDownload(log.NewDummyLogger(), []string{recordingsDir}, nil)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recording/download?"+query, nil))
+		return w
+	}
+	zipNames := func(w *httptest.ResponseRecorder) []string {
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		require.NoError(t, err)
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+	expected := []string{"m1_2000-01-01_02-00-00.h264", "m1_2000-01-01_02-00-00.json"}
+
+	w := get("ids=" + recID)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename="recordings-2000-01-01_02-00-00.zip"`,
+		w.Header().Get("Content-Disposition"))
+	require.Equal(t, expected, zipNames(w))
+
+	w = get("start=2000-01-01T02:05:00Z&end=2000-01-01T03:00:00Z&monitors=m1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, expected, zipNames(w))
+
+	w = get("start=2000-01-01T03:00:00Z&end=2000-01-01T04:00:00Z")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get("ids=2000-01-01_03-00-00_m1")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get("ids=x")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get("")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get("start=2000-01-01T00:00:00Z&end=2000-01-03T00:00:00Z")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRecordingActivity(t *testing.T) {
+	recordingsDir := t.TempDir()
+	recDir := filepath.Join(recordingsDir, "2000", "01", "01", "m1")
+	require.NoError(t, os.MkdirAll(recDir, 0o700))
+	data := `{"start":"2000-01-01T02:00:00Z","end":"2000-01-01T02:10:00Z",` +
+		`"events":[{"time":"2000-01-01T02:05:00Z"}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(recDir, "2000-01-01_02-00-00_m1.json"), []byte(data), 0o600))
+
+	h := RecordingActivity(log.NewDummyLogger(), []string{recordingsDir})
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recording/activity?"+query, nil))
+		return w
+	}
+
+	w := get("start=2000-01-01T00:00:00Z&end=2000-01-01T04:00:00Z")
+	require.Equal(t, http.StatusOK, w.Code)
+	var heatmap storage.ActivityHeatmap
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &heatmap))
+	require.Equal(t, storage.HeatmapHour, heatmap.Interval)
+	require.Equal(t, 4, heatmap.Buckets)
+	require.Equal(t, storage.HeatmapCounts{
+		Recordings: []int{0, 0, 1, 0},
+		Events:     []int{0, 0, 1, 0},
+	}, heatmap.Monitors["m1"])
+
+	w = get("start=2000-01-01T00:00:00Z&end=2000-01-01T04:00:00Z&interval=day")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get("start=x&end=2000-01-01T04:00:00Z")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRecordingExport(t *testing.T) {
+	profiles, err := transcode.NewManager(t.TempDir())
+	require.NoError(t, err)
+	m := export.NewManager([]string{t.TempDir()}, t.TempDir(), "", profiles, log.NewDummyLogger())
+	start := export.Request{
+		Monitors: []string{"m1"},
+		Start:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:      time.Date(2000, 1, 1, 0, 1, 0, 0, time.UTC),
+		Layout:   export.LayoutGrid,
+	}
+
+	serve := func(h http.Handler, method string, target string, body string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w.Code
+	}
+	encode := func(r export.Request) string {
+		raw, err := json.Marshal(r)
+		require.NoError(t, err)
+		return string(raw)
+	}
+
+	h := RecordingExport(m)
+	code := serve(h, http.MethodPost, "/api/recording/export", encode(start))
+	require.Equal(t, http.StatusNotFound, code)
+
+	invalid := start
+	invalid.Layout = "x"
+	code = serve(h, http.MethodPost, "/api/recording/export", encode(invalid))
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code = serve(h, http.MethodPost, "/api/recording/export", "{")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	unknownProfile := start
+	unknownProfile.Profile = "x"
+	code = serve(h, http.MethodPost, "/api/recording/export", encode(unknownProfile))
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code = serve(h, http.MethodGet, "/api/recording/export", encode(start))
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code = serve(RecordingExportStatus(m), http.MethodGet, "/api/recording/export/status?id=x", "")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code = serve(RecordingExportFile(m), http.MethodGet, "/api/recording/export/file?id=x", "")
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestRecordingSummary(t *testing.T) {
+	m := export.NewManager([]string{t.TempDir()}, t.TempDir(), "", nil, log.NewDummyLogger())
+	start := export.SummaryRequest{
+		Monitor: "m1",
+		Start:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:     time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		Mode:    export.SummaryTimelapse,
+	}
+
+	serve := func(method string, body string) int {
+		w := httptest.NewRecorder()
+		RecordingSummary(m).ServeHTTP(w, httptest.NewRequest(
+			method, "/api/recording/summary", strings.NewReader(body)))
+		return w.Code
+	}
+	encode := func(r export.SummaryRequest) string {
+		raw, err := json.Marshal(r)
+		require.NoError(t, err)
+		return string(raw)
+	}
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, encode(start)))
+
+	invalid := start
+	invalid.Mode = "x"
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, encode(invalid)))
+
+	invalid = start
+	invalid.Duration = 1
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, encode(invalid)))
+
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "{"))
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, encode(start)))
+}
+
+func TestEventMedia(t *testing.T) {
+	snapshotsDir := t.TempDir()
+	h := EventMedia(log.NewDummyLogger(), t.TempDir(), snapshotsDir, nil)
+
+	serve := func(method string, target string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w.Code
+	}
+
+	code := serve(http.MethodGet, "/api/events/2000-01-01_02-02-02.123_m1/clip")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code = serve(http.MethodGet, "/api/events/2000-01-01_02-02-02.123_m1/x")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code = serve(http.MethodGet, "/api/events/x/clip")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code = serve(http.MethodPost, "/api/events/2000-01-01_02-02-02.123_m1/clip")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code = serve(http.MethodGet, "/api/events/2000-01-01_02-02-02.123_m1/snapshot")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code = serve(http.MethodGet, "/api/events/x/snapshot")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	snapshotPath := filepath.Join(snapshotsDir, "2000-01-01_02-02-02.123_m1.jpeg")
+	require.NoError(t, os.WriteFile(snapshotPath, []byte("jpeg"), 0o600))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "/api/events/2000-01-01_02-02-02.123_m1/snapshot", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	require.Equal(t, "jpeg", w.Body.String())
+}
+
+func TestEventSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"2000-01-01_02-02-02.123_m1", "2000-01-01_02-02-03.123_m2"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id+".jpeg"), nil, 0o600))
+	}
+	h := EventSnapshots(dir)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := serve("/api/events/snapshots?limit=1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var snapshots []monitor.EventSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshots))
+	require.Len(t, snapshots, 1)
+	require.Equal(t, "2000-01-01_02-02-03.123_m2", snapshots[0].ID)
+
+	w = serve("/api/events/snapshots?limit=10&monitors=m1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshots))
+	require.Len(t, snapshots, 1)
+	require.Equal(t, "m1", snapshots[0].MonitorID)
+
+	w = serve("/api/events/snapshots?limit=x")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTranscodeProfiles(t *testing.T) {
+	m, err := transcode.NewManager(t.TempDir())
+	require.NoError(t, err)
+
+	serve := func(h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	set := TranscodeProfileSet(m)
+	w := serve(set, http.MethodPut, "/api/transcode/profile/set", `{"name":"a","codec":"h265"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(set, http.MethodPut, "/api/transcode/profile/set", `{"name":"b","codec":"x"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(set, http.MethodPost, "/api/transcode/profile/set", `{"name":"a","codec":"h265"}`)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = serve(TranscodeProfiles(m), http.MethodGet, "/api/transcode/profiles", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var profiles []transcode.Profile
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &profiles))
+	require.Equal(t, []transcode.Profile{
+		{Name: "a", Codec: transcode.CodecH265},
+		transcode.DefaultProfile,
+	}, profiles)
+
+	del := TranscodeProfileDelete(m)
+	w = serve(del, http.MethodDelete, "/api/transcode/profile/delete?name=a", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(del, http.MethodDelete, "/api/transcode/profile/delete?name=a", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(del, http.MethodDelete, "/api/transcode/profile/delete", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMonitorCloneAndTemplates(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	templates, err := monitor.NewTemplates(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	src := monitor.RawConfig{"id": "a", "name": "a", "mainInput": "rtsp://x", "enable": "true"}
+	require.NoError(t, m.MonitorSet("a", src))
+
+	serve := func(h http.Handler, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		return w
+	}
+
+	t.Run("clone", func(t *testing.T) {
+		clone := MonitorClone(m)
+		cases := []struct {
+			name   string
+			target string
+			code   int
+		}{
+			{"ok", "/api/monitor/clone?from=a&id=b&name=b", http.StatusOK},
+			{"exists", "/api/monitor/clone?from=a&id=b&name=c", http.StatusConflict},
+			{"sourceMissing", "/api/monitor/clone?from=x&id=c&name=c", http.StatusNotFound},
+			{"invalidName", "/api/monitor/clone?from=a&id=c&name=c%20d", http.StatusBadRequest},
+		}
+		for _, tc := range cases {
+			require.Equal(t, tc.code, serve(clone, tc.target, "").Code, tc.name)
+		}
+
+		expected := monitor.RawConfig{"id": "b", "name": "b", "mainInput": "rtsp://x", "enable": "true"}
+		require.Equal(t, expected, m.MonitorConfigs()["b"])
+		require.Equal(t, "a", m.MonitorConfigs()["a"]["id"])
+	})
+	t.Run("templates", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := `{"name":"t","config":{"mainInput":"rtsp://${ip}/main","enable":"true"}}`
+		MonitorTemplateSet(templates).ServeHTTP(w,
+			httptest.NewRequest(http.MethodPut, "/api/monitor/template/set", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		MonitorTemplates(templates).ServeHTTP(w,
+			httptest.NewRequest(http.MethodGet, "/api/monitor/templates", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), `"variables":["ip"]`)
+
+		apply := MonitorTemplateApply(m, templates)
+		w = serve(apply, "/api/monitor/template/apply?template=x&id=c&name=c", "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = serve(apply, "/api/monitor/template/apply?template=t&id=c&name=c", "")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = serve(apply, "/api/monitor/template/apply?template=t&id=c&name=c", `{"ip":"10.0.0.5"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		expected := monitor.RawConfig{"id": "c", "name": "c", "mainInput": "rtsp://10.0.0.5/main", "enable": "true"}
+		require.Equal(t, expected, m.MonitorConfigs()["c"])
+
+		w = serve(apply, "/api/monitor/template/apply?template=t&id=c&name=c", `{"ip":"10.0.0.6"}`)
+		require.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestMonitorEnable(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{"id": "x", "enable": "false"}))
+
+	h := MonitorEnable(m)
+	serve := func(method string, target string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/?id=x&enable=false"))
+	require.Equal(t, "false", m.MonitorsInfo()["x"]["enable"])
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/?id=y&enable=true"))
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/?id=x&enable=x"))
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/?enable=true"))
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "/?id=x&enable=true"))
+}
+
+func TestMonitorCredentials(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	credentials, err := monitor.NewCredentials(t.TempDir(), nil)
+	require.NoError(t, err)
+	m.SetCredentials(credentials)
+
+	serve := func(h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	set := MonitorCredentialSet(credentials)
+	w := serve(set, http.MethodPut, "/", `{"id":"a b"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(set, http.MethodPut, "/", `{"id":"c","username":"admin","password":"hunter2"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(MonitorCredentials(credentials), http.MethodGet, "/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `[{"id":"c","username":"admin"}]`+"\n", w.Body.String())
+
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{
+		"id": "x", "mainInput": "rtsp://admin:hunter2@x", "credential": "c",
+	}))
+
+	// Passwords are redacted.
+	w = serve(MonitorConfigs(m), http.MethodGet, "/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "hunter2")
+	require.Contains(t, w.Body.String(), "rtsp://admin:********@x")
+
+	del := MonitorCredentialDelete(m, credentials)
+	w = serve(del, http.MethodDelete, "/?id=c", "")
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{"id": "x"}))
+	w = serve(del, http.MethodDelete, "/?id=c", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(del, http.MethodDelete, "/?id=c", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMonitorPrivacyMask(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	masks, err := monitor.NewPrivacyMasks(t.TempDir())
+	require.NoError(t, err)
+	m.SetPrivacyMasks(masks)
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{"id": "x"}))
+
+	serve := func(h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	set := MonitorPrivacyMaskSet(m, masks, stubAuth{user: auth.Account{Username: "admin"}})
+	zones := `{"zones":[{"points":[[0,0],[0.5,0],[0.5,0.5]]}]}`
+	w := serve(set, http.MethodPut, "/?id=y", zones)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(set, http.MethodPut, "/?id=x", `{"zones":[{"points":[[0,0],[2,2],[0,1]]}]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(set, http.MethodPut, "/?id=x", zones)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var mask monitor.PrivacyMask
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &mask))
+	require.Equal(t, 1, mask.Version)
+	require.Equal(t, "admin", mask.Username)
+
+	w = serve(MonitorPrivacyMask(masks), http.MethodGet, "/?id=x", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []monitor.PrivacyMask
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Equal(t, []monitor.PrivacyMask{mask}, history)
+}
+
+func TestMonitorZones(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{"id": "x"}))
+
+	db, err := kv.Open(filepath.Join(t.TempDir(), "nvr.db"))
+	require.NoError(t, err)
+	store := zones.NewStore(db)
+
+	serve := func(h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	set := MonitorZoneSet(m, store)
+	zone := `{"name":"a","points":[[0,0],[0.5,0],[0.5,0.5]]}`
+	w := serve(set, http.MethodPut, "/?id=y", zone)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(set, http.MethodPut, "/?id=x", `{"name":"a","points":[[0,0],[2,2],[0,1]]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(set, http.MethodPut, "/?id=x", zone)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(MonitorZones(store), http.MethodGet, "/?id=x", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "["+zone+"]\n", w.Body.String())
+
+	del := MonitorZoneDelete(store)
+	w = serve(del, http.MethodDelete, "/?id=x&name=a", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(del, http.MethodDelete, "/?id=x&name=a", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(MonitorZones(store), http.MethodGet, "/?id=x", "")
+	require.Equal(t, "[]\n", w.Body.String())
+}
+
+func TestGroupConfigs(t *testing.T) {
+	m, err := group.NewManager(t.TempDir())
+	require.NoError(t, err)
+	h := GroupConfigs(m)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/group/configs", nil)
+		r.Header.Set("If-None-Match", etag)
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "{}\n", w.Body.String())
+	etag := w.Header().Get("Etag")
+
+	w = get(etag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	require.NoError(t, m.GroupSet("g1", group.Config{"id": "g1", "name": "a"}))
+
+	w = get(etag)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `{"g1":{"id":"g1","name":"a"}}`+"\n", w.Body.String())
+	require.NotEqual(t, etag, w.Header().Get("Etag"))
+}
+
+func TestGroupSet(t *testing.T) {
+	m, err := group.NewManager(t.TempDir())
+	require.NoError(t, err)
+	h := GroupSet(m)
+
+	set := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/group/set", strings.NewReader(body)))
+		return w
+	}
+
+	w := set(`{"id":"g1","name":"a","monitors":"[\"m1\"]","gridRows":"9","gridColumns":"1"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Empty(t, m.Configs())
+
+	w = set(`{"id":"g1","name":"a","monitors":"[\"m1\"]","gridRows":"2","gridColumns":"2"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	layout, err := m.Layout("g1")
+	require.NoError(t, err)
+	require.Equal(t, 2, layout.Rows)
+}
+
+func TestGroupRollup(t *testing.T) {
+	groups, err := group.NewManager(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, groups.GroupSet("g1", group.Config{"id": "g1", "monitors": `["m1","m2"]`}))
+	require.NoError(t, groups.GroupSet("g2", group.Config{"id": "g2", "monitors": "[]"}))
+
+	rawData, err := json.Marshal(storage.RecordingData{
+		Events: []storage.Event{{
+			Time:       time.Date(2000, 1, 1, 1, 0, 1, 0, time.UTC),
+			Detections: []storage.Detection{{Label: "person"}},
+		}},
+	})
+	require.NoError(t, err)
+	crawler := storage.NewCrawler(fstest.MapFS{
+		"2000/01/01/m1/2000-01-01_01-00-00_m1.json": {Data: rawData},
+		"2000/01/01/m2/2000-01-01_02-00-00_m2.json": {Data: []byte("{}")},
+		"2000/01/01/m3/2000-01-01_03-00-00_m3.json": {Data: rawData},
+	})
+	h := GroupRollup(groups, crawler, log.NewDummyLogger())
+
+	serve := func(target string) (int, string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	t.Run("recordings", func(t *testing.T) {
+		code, body := serve("/api/group/g1/recordings?limit=1")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"recordings":[{"id":"2000-01-01_02-00-00_m2",`+
+			`"protected":false,"data":null}],"next":"2000-01-01_02-00-00_m2"}`, body)
+
+		code, body = serve("/api/group/g1/recordings?limit=2&time=2000-01-01_02-00-00_m2")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"recordings":[{"id":"2000-01-01_01-00-00_m1",`+
+			`"protected":false,"data":null}],"next":""}`, body)
+	})
+	t.Run("events", func(t *testing.T) {
+		code, body := serve("/api/group/g1/events?limit=10")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"events":[{"recordingId":"2000-01-01_01-00-00_m1",`+
+			`"monitorId":"m1","time":"2000-01-01T01:00:01Z",`+
+			`"detections":[{"label":"person"}]}],"next":""}`, body)
+	})
+	t.Run("filter", func(t *testing.T) {
+		code, body := serve("/api/group/g1/recordings?limit=10&labels=person")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"recordings":[{"id":"2000-01-01_01-00-00_m1",`+
+			`"protected":false,"data":null}],"next":""}`, body)
+
+		code, body = serve("/api/group/g1/recordings?limit=10&labels=car")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"recordings":[],"next":""}`, body)
+
+		code, _ = serve("/api/group/g1/recordings?limit=10&minDuration=x")
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("list", func(t *testing.T) {
+		code, body := serve("/api/group/g1/recordings?page[size]=1&sort=id&fields=id")
+		require.Equal(t, http.StatusOK, code)
+		var page listPage
+		require.NoError(t, json.Unmarshal([]byte(body), &page))
+		require.Equal(t, []listItem{{"id": "2000-01-01_01-00-00_m1"}}, page.Items)
+		require.Equal(t, 2, *page.Total)
+
+		code, body = serve("/api/group/g1/recordings?page[size]=1&sort=id" +
+			"&fields=id&page[cursor]=" + page.Next)
+		require.Equal(t, http.StatusOK, code)
+		require.NoError(t, json.Unmarshal([]byte(body), &page))
+		require.Equal(t, []listItem{{"id": "2000-01-01_02-00-00_m2"}}, page.Items)
+
+		code, _ = serve("/api/group/g1/recordings?page[size]=1&page[cursor]=" + page.Next)
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, body = serve("/api/group/g1/events?fields=monitorId")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"items":[{"monitorId":"m1"}],"next":""}`, body)
+
+		code, _ = serve("/api/group/g1/events?sort=id")
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("emptyGroup", func(t *testing.T) {
+		code, body := serve("/api/group/g2/events?limit=10")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"events":[],"next":""}`, body)
+
+		code, body = serve("/api/group/g2/recordings?limit=10")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"recordings":[],"next":""}`, body)
+	})
+	t.Run("layout", func(t *testing.T) {
+		code, body := serve("/api/group/g1/layout")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `{"rows":0,"columns":0,"order":["m1","m2"],`+
+			`"rotationInterval":0,"streamQuality":""}`, body)
+
+		code, _ = serve("/api/group/nil/layout")
+		require.Equal(t, http.StatusNotFound, code)
+	})
+	t.Run("errors", func(t *testing.T) {
+		code, _ := serve("/api/group/nil/events?limit=1")
+		require.Equal(t, http.StatusNotFound, code)
+
+		code, _ = serve("/api/group/g1/x?limit=1")
+		require.Equal(t, http.StatusNotFound, code)
+
+		code, _ = serve("/api/group/g1/events?limit=0")
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = serve("/api/group/g1/events?limit=1&time=2000")
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestMonitorCameraMotion(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	require.NoError(t, m.MonitorSet("a", monitor.RawConfig{"id": "a", "name": "a"}))
+	require.NoError(t, m.MonitorSet("b", monitor.RawConfig{
+		"id": "b", "name": "b", "onvifAddress": "http://127.0.0.1:1/onvif/device_service",
+	}))
+
+	cases := []struct {
+		name   string
+		h      http.Handler
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{"method", MonitorCameraMotion(m), http.MethodPut, "/?id=a", "", http.StatusMethodNotAllowed},
+		{"idMissing", MonitorCameraMotion(m), http.MethodGet, "/", "", http.StatusBadRequest},
+		{"notExist", MonitorCameraMotion(m), http.MethodGet, "/?id=x", "", http.StatusNotFound},
+		{"noAddress", MonitorCameraMotion(m), http.MethodGet, "/?id=a", "", http.StatusBadRequest},
+		{"unreachable", MonitorCameraMotion(m), http.MethodGet, "/?id=b", "", http.StatusBadGateway},
+		{"setMethod", MonitorCameraMotionSet(m), http.MethodGet, "/?id=b", "", http.StatusMethodNotAllowed},
+		{"setBody", MonitorCameraMotionSet(m), http.MethodPut, "/?id=b", "x", http.StatusBadRequest},
+		{"setInvalid", MonitorCameraMotionSet(m), http.MethodPut, "/?id=b", `{"sensitivity":101}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			tc.h.ServeHTTP(w, r)
+			require.Equal(t, tc.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSystemUpdate(t *testing.T) {
+	serve := func(method string, err error) *httptest.ResponseRecorder {
+		install := func(context.Context) (*update.Release, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &update.Release{Version: "v1.1.0"}, nil
+		}
+		w := httptest.NewRecorder()
+		SystemUpdate(log.NewDummyLogger(), install).ServeHTTP(w, httptest.NewRequest(method, "/", nil))
+		return w
+	}
+
+	w := serve(http.MethodPost, nil)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Contains(t, w.Body.String(), `"version":"v1.1.0"`)
+
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, nil).Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, update.ErrDisabled).Code)
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, update.ErrNoUpdate).Code)
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, update.ErrInProgress).Code)
+	require.Equal(t, http.StatusInternalServerError, serve(http.MethodPost, update.ErrInvalidSignature).Code)
+}
+
+func TestLogLevel(t *testing.T) {
+	logger := log.NewLogger(&sync.WaitGroup{}, nil)
+	serve := func(method string, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		LogLevel(logger).ServeHTTP(w, httptest.NewRequest(method, "/?"+query, nil))
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "source=monitor&level=warning").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "source=x&level=warning").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "source=monitor&level=x").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "").Code)
+
+	w := httptest.NewRecorder()
+	LogSources(logger).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var levels []log.SourceLevel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &levels))
+	require.Contains(t, levels, log.SourceLevel{Source: "monitor", Level: "warning"})
+	require.Contains(t, levels, log.SourceLevel{Source: "app", Level: "debug"})
+}
+
+func TestHealthCheck(t *testing.T) {
+	serve := func(method string, err error) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		check := func() error { return err }
+		HealthCheck(check).ServeHTTP(w, httptest.NewRequest(method, "/healthz", nil))
+		return w
+	}
+
+	w := serve(http.MethodGet, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "ok\n", w.Body.String())
+	require.Equal(t, http.StatusOK, serve(http.MethodHead, nil).Code)
+
+	w = serve(http.MethodGet, errors.New("not ready: not started: monitors")) //nolint:goerr113
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "not ready: not started: monitors\n", w.Body.String())
+
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPost, nil).Code)
+}
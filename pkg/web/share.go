@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/share"
+	"nvr/pkg/storage"
+	"nvr/pkg/video"
+	"nvr/pkg/web/auth"
+	"nvr/web/static"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ShareCreateResponse is the created share link and its token.
+// Path is the link relative to the base path, "share/<token>".
+type ShareCreateResponse struct {
+	Link  share.Link `json:"link"`
+	Token string     `json:"token"`
+	Path  string     `json:"path"`
+}
+
+// ShareCreate handler to create a share link of
+// the live stream of a monitor or a recording.
+func ShareCreate(
+	s *share.Manager,
+	m *monitor.Manager,
+	recordingsDirs []string,
+	a auth.Authenticator,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req share.CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch req.Kind {
+		case share.KindLive:
+			if _, exist := m.MonitorConfigs()[req.MonitorID]; !exist {
+				http.Error(w, fmt.Sprintf("%v: %q", monitor.ErrMonitorNotExist, req.MonitorID),
+					http.StatusNotFound)
+				return
+			}
+		case share.KindRecording:
+			if !recordingExist(recordingsDirs, req.RecordingID) {
+				http.Error(w, fmt.Sprintf("recording does not exist: %q", req.RecordingID),
+					http.StatusNotFound)
+				return
+			}
+		}
+
+		username := a.ValidateRequest(r).User.Username
+		link, token, err := s.Create(req, username)
+		switch {
+		case errors.Is(err, share.ErrInvalidRequest):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		res := ShareCreateResponse{Link: *link, Token: token, Path: "share/" + token}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func recordingExist(recordingsDirs []string, recID string) bool {
+	recPath, err := storage.RecordingIDToPath(recID)
+	if err != nil {
+		return false
+	}
+	path := filepath.Join(storage.FindRecordingsDir(recordingsDirs, recPath), recPath)
+	if containsDotDot(path) {
+		return false
+	}
+	_, err = os.Stat(path + ".meta")
+	return err == nil
+}
+
+// ShareLinks handler returns the issued share links,
+// including the expired and revoked links.
+func ShareLinks(s *share.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		links, err := s.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(links); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// ShareRevoke handler to revoke a share link.
+func ShareRevoke(s *share.Manager, a auth.Authenticator, logger log.ILogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		username := a.ValidateRequest(r).User.Username
+		err := s.Revoke(id, username)
+		switch {
+		case errors.Is(err, share.ErrLinkNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Log(log.Entry{
+			Level: log.LevelInfo,
+			Src:   "auth",
+			Msg:   fmt.Sprintf("share link %v revoked by %v", id, username),
+		})
+	})
+}
+
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8" />
+	<meta name="viewport" content="width=device-width, initial-scale=1" />
+	<meta name="referrer" content="no-referrer" />
+	<title>{{ .Title }}</title>
+	<style>
+		html, body { margin: 0; height: 100%; background: black; }
+		video { width: 100%; height: 100%; object-fit: contain; }
+	</style>
+</head>
+<body>
+	<video controls autoplay muted playsinline></video>
+	<script type="module">
+		const $video = document.querySelector("video");
+		{{- if .Live }}
+		import Hls from "./{{ .Token }}/hls.mjs";
+		const index = "./{{ .Token }}/hls/index.m3u8";
+		if (Hls.isSupported()) {
+			const hls = new Hls({ maxDelaySec: 2, maxRecoveryAttempts: -1 });
+			hls.init($video, index);
+		} else {
+			$video.src = index;
+		}
+		{{- else }}
+		$video.src = "./{{ .Token }}/video";
+		{{- end }}
+	</script>
+</body>
+</html>
+`))
+
+// Share handler serves share links without login. "/share/<token>" is a
+// page with a video player, "/share/<token>/video" the recording and
+// "/share/<token>/hls/" the live stream of the monitor.
+func Share(
+	s *share.Manager,
+	logger log.ILogger,
+	recordingVideo http.Handler,
+	hls http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token, file, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/share/"), "/")
+		// The link must not give access to other streams.
+		if containsDotDot(file) {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		link, err := s.Validate(token)
+		switch {
+		case errors.Is(err, share.ErrInvalidToken):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, share.ErrExpired), errors.Is(err, share.ErrRevoked):
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+
+		isLive := link.Kind == share.KindLive
+		switch {
+		case file == "":
+			serveSharePage(w, s, logger, link, token)
+		case isLive && file == "hls.mjs":
+			fileServer := http.FileServer(http.FS(static.Static))
+			fileServer.ServeHTTP(w, withPath(r, "/scripts/vendor/hls.mjs"))
+		case isLive && strings.HasPrefix(file, "hls/"):
+			pathName := video.PathName(link.MonitorID, false)
+			hls.ServeHTTP(w, withPath(r, "/hls/"+pathName+"/"+strings.TrimPrefix(file, "hls/")))
+		case !isLive && file == "video":
+			recordingVideo.ServeHTTP(w, withPath(r, "/api/recording/video/"+link.RecordingID))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+}
+
+func serveSharePage(
+	w http.ResponseWriter,
+	s *share.Manager,
+	logger log.ILogger,
+	link *share.Link,
+	token string,
+) {
+	if err := s.RecordUse(link.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Log(log.Entry{
+		Level:     log.LevelInfo,
+		Src:       "auth",
+		MonitorID: link.MonitorID,
+		Msg: fmt.Sprintf("share link %v opened, created by %v, expires %v",
+			link.ID, link.CreatedBy, link.Expires.Format(time.RFC3339)),
+	})
+
+	title := "Recording " + link.RecordingID
+	if link.Kind == share.KindLive {
+		title = "Live " + link.MonitorID
+	}
+	data := struct {
+		Title string
+		Token string
+		Live  bool
+	}{
+		Title: title,
+		Token: token,
+		Live:  link.Kind == share.KindLive,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := sharePageTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// withPath returns a shallow copy of the request with the URL path replaced.
+func withPath(r *http.Request, path string) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
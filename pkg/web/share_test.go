@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nvr/pkg/kv"
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/share"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShares(t *testing.T) *share.Manager {
+	t.Helper()
+	db, err := kv.Open(filepath.Join(t.TempDir(), "nvr.db"))
+	require.NoError(t, err)
+	shares, err := share.NewManager(db)
+	require.NoError(t, err)
+	return shares
+}
+
+func TestShareCreate(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	require.NoError(t, m.MonitorSet("x", monitor.RawConfig{"id": "x"}))
+
+	shares := newTestShares(t)
+	h := ShareCreate(shares, m, []string{t.TempDir()}, stubAuth{user: auth.Account{Username: "admin"}})
+	serve := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(`{"kind":"live","monitorId":"y"}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(`{"kind":"recording","recordingId":"2000-01-01_00-00-00_x"}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(`{"kind":"live","monitorId":"x","duration":-1}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(`{"kind":"live","monitorId":"x","note":"tv"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var res ShareCreateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Equal(t, "share/"+res.Token, res.Path)
+	require.Equal(t, "admin", res.Link.CreatedBy)
+
+	link, err := shares.Validate(res.Token)
+	require.NoError(t, err)
+	require.Equal(t, "x", link.MonitorID)
+}
+
+func TestShare(t *testing.T) {
+	shares := newTestShares(t)
+	live, liveToken, err := shares.Create(
+		share.CreateRequest{Kind: share.KindLive, MonitorID: "x"}, "admin")
+	require.NoError(t, err)
+	_, recToken, err := shares.Create(
+		share.CreateRequest{Kind: share.KindRecording, RecordingID: "2000-01-01_00-00-00_x"}, "admin")
+	require.NoError(t, err)
+
+	pathHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path)) //nolint:errcheck
+	}
+	h := Share(
+		shares,
+		log.NewDummyLogger(),
+		http.HandlerFunc(pathHandler),
+		http.HandlerFunc(pathHandler),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/share/" + liveToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), liveToken+"/hls/index.m3u8")
+
+	w = get("/share/" + liveToken + "/hls/index.m3u8")
+	require.Equal(t, "/hls/x/index.m3u8", w.Body.String())
+
+	w = get("/share/" + liveToken + "/hls/../y/index.m3u8")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get("/share/" + liveToken + "/video")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get("/share/" + recToken + "/video")
+	require.Equal(t, "/api/recording/video/2000-01-01_00-00-00_x", w.Body.String())
+
+	w = get("/share/x.y")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	require.NoError(t, shares.Revoke(live.ID, "admin"))
+	w = get("/share/" + liveToken)
+	require.Equal(t, http.StatusGone, w.Code)
+
+	links, err := shares.List()
+	require.NoError(t, err)
+	for _, link := range links {
+		if link.ID == live.ID {
+			require.Equal(t, 1, link.Uses)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"nvr/pkg/monitor"
+	"nvr/pkg/web/auth"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Talkback limits. The speaker is released if the client
+// stops sending audio, push-to-talk clients close the
+// websocket when the button is released.
+const (
+	talkbackIdleTimeout = 5 * time.Second
+	talkbackMaxMessage  = 64 * 1024
+)
+
+// MonitorTalkback opens a websocket that relays microphone audio to the
+// speaker of a camera through the ONVIF audio backchannel. The client
+// sends binary messages of 16-bit little-endian mono PCM at 8kHz.
+// Only admins and users with the talkback permission may talk.
+func MonitorTalkback(a auth.Authenticator, m *monitor.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := a.ValidateRequest(r).User
+		if !user.CanTalkback() {
+			http.Error(w, "talkback permission required", http.StatusForbidden)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id missing", http.StatusBadRequest)
+			return
+		}
+
+		// The backchannel is opened before the upgrade
+		// so the error can be reported with a status.
+		talkback, err := m.StartTalkback(r.Context(), id, user.Username)
+		switch {
+		case errors.Is(err, monitor.ErrNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, monitor.ErrTalkbackDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, monitor.ErrTalkbackBusy):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer talkback.Close()
+
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.SetReadLimit(talkbackMaxMessage)
+
+		relayTalkback(c, talkback)
+	})
+}
+
+// relayTalkback writes the audio messages until the
+// websocket is closed or the client stops sending.
+func relayTalkback(c *websocket.Conn, talkback *monitor.Talkback) {
+	for {
+		if err := c.SetReadDeadline(time.Now().Add(talkbackIdleTimeout)); err != nil {
+			return
+		}
+		msgType, msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+		if err := talkback.Write(decodePCM(msg)); err != nil {
+			c.WriteControl( //nolint:errcheck
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "camera: "+err.Error()),
+				time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+// decodePCM decodes 16-bit little-endian samples.
+func decodePCM(msg []byte) []int16 {
+	samples := make([]int16, len(msg)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(msg[i*2:]))
+	}
+	return samples
+}
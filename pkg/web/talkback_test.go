@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nvr/pkg/log"
+	"nvr/pkg/monitor"
+	"nvr/pkg/storage"
+	"nvr/pkg/web/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorTalkback(t *testing.T) {
+	m, err := monitor.NewManager(
+		t.TempDir(),
+		storage.ConfigEnv{},
+		nil,
+		log.NewDummyLogger(),
+		nil,
+		nil,
+		nil,
+		&monitor.Hooks{Migrate: func(monitor.RawConfig) error { return nil }},
+	)
+	require.NoError(t, err)
+	require.NoError(t, m.MonitorSet("a", monitor.RawConfig{"id": "a", "name": "a"}))
+
+	serve := func(user auth.Account, target string) int {
+		w := httptest.NewRecorder()
+		h := MonitorTalkback(stubAuth{user: user}, m)
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+	user := auth.Account{Username: "alice"}
+	talker := auth.Account{Username: "bob", Talkback: true}
+
+	require.Equal(t, http.StatusForbidden, serve(user, "/?id=a"))
+	require.Equal(t, http.StatusForbidden, serve(talker, "/?id=a"))
+	require.Equal(t, http.StatusNotFound, serve(talker, "/?id=x"))
+	require.Equal(t, http.StatusBadRequest, serve(talker, "/"))
+}
+
+func TestDecodePCM(t *testing.T) {
+	require.Equal(t, []int16{1, -2}, decodePCM([]byte{1, 0, 0xfe, 0xff, 9}))
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewTLS returns the TLS config of the main server and the handler of
+// the HTTP redirect server. In autocert mode the redirect handler also
+// answers the ACME HTTP challenges. The port is the HTTPS port.
+func NewTLS(
+	c storage.TLS,
+	port int,
+	logger log.ILogger,
+) (*tls.Config, http.Handler, error) {
+	redirect := httpsRedirect(port)
+
+	if c.Autocert() {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(c.AutocertDir),
+			HostPolicy: autocert.HostWhitelist(c.AutocertDomains...),
+			Email:      c.AutocertEmail,
+		}
+		return m.TLSConfig(), m.HTTPHandler(redirect), nil
+	}
+
+	reloader, err := newCertReloader(c.CertFile, c.KeyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	return tlsConfig, redirect, nil
+}
+
+// httpsRedirect redirects requests to the same host and path over HTTPS.
+func httpsRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "use HTTPS", http.StatusBadRequest)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// How often the certificate files are checked for changes.
+const certCheckInterval = 10 * time.Second
+
+// certReloader loads the certificate again when the certificate or key
+// file is modified, renewed certificates are used without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   log.ILogger
+
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+	mu        sync.Mutex
+}
+
+func newCertReloader(certFile string, keyFile string, logger log.ILogger) (*certReloader, error) {
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+	if err := c.load(time.Now()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastCheck) < certCheckInterval {
+		return c.cert, nil
+	}
+	if err := c.load(now); err != nil {
+		// Keep the old certificate, the files may be partially written.
+		c.logger.Log(log.Entry{
+			Level: log.LevelError,
+			Src:   "app",
+			Msg:   fmt.Sprintf("reload certificate: %v", err),
+		})
+	}
+	return c.cert, nil
+}
+
+// load loads the certificate if the files were modified since the last load.
+func (c *certReloader) load(now time.Time) error {
+	c.lastCheck = now
+
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	if c.cert != nil {
+		c.logger.Log(log.Entry{
+			Level: log.LevelInfo,
+			Src:   "app",
+			Msg:   "certificate reloaded",
+		})
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
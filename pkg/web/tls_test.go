@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr/pkg/log"
+	"nvr/pkg/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCert(t *testing.T, certFile string, keyFile string, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	rawCert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	rawKey, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rawCert})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: rawKey})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "a")
+
+	c, err := newCertReloader(certFile, keyFile, log.NewDummyLogger())
+	require.NoError(t, err)
+
+	cert, err := c.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "a", commonName(t, cert))
+
+	// Renew.
+	writeTestCert(t, certFile, keyFile, "b")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	// Not checked until the interval has passed.
+	cert, err = c.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "a", commonName(t, cert))
+
+	c.lastCheck = time.Time{}
+	cert, err = c.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "b", commonName(t, cert))
+
+	// The old certificate is kept if the files are invalid.
+	require.NoError(t, os.WriteFile(keyFile, []byte("x"), 0o600))
+	c.lastCheck = time.Time{}
+	cert, err = c.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "b", commonName(t, cert))
+
+	t.Run("missingErr", func(t *testing.T) {
+		_, err := newCertReloader(filepath.Join(dir, "x"), keyFile, log.NewDummyLogger())
+		require.Error(t, err)
+	})
+}
+
+func TestNewTLS(t *testing.T) {
+	t.Run("manual", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "cert.pem")
+		keyFile := filepath.Join(dir, "key.pem")
+		writeTestCert(t, certFile, keyFile, "a")
+
+		c := storage.TLS{CertFile: certFile, KeyFile: keyFile}
+		tlsConfig, _, err := NewTLS(c, 443, log.NewDummyLogger())
+		require.NoError(t, err)
+		require.NotNil(t, tlsConfig.GetCertificate)
+	})
+	t.Run("autocert", func(t *testing.T) {
+		c := storage.TLS{
+			AutocertDomains: []string{"example.com"},
+			AutocertDir:     t.TempDir(),
+		}
+		tlsConfig, redirect, err := NewTLS(c, 443, log.NewDummyLogger())
+		require.NoError(t, err)
+		require.Contains(t, tlsConfig.NextProtos, "acme-tls/1")
+
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/a?b=c", nil)
+		w := httptest.NewRecorder()
+		redirect.ServeHTTP(w, r)
+		require.Equal(t, http.StatusMovedPermanently, w.Code)
+		require.Equal(t, "https://example.com/a?b=c", w.Header().Get("Location"))
+	})
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	cases := map[string]struct {
+		port     int
+		method   string
+		url      string
+		code     int
+		location string
+	}{
+		"default": {443, http.MethodGet, "http://a.com/x?y=z", http.StatusMovedPermanently, "https://a.com/x?y=z"},
+		"port":    {2020, http.MethodGet, "http://a.com:80/x", http.StatusMovedPermanently, "https://a.com:2020/x"},
+		"ipv6":    {2020, http.MethodGet, "http://[::1]:80/", http.StatusMovedPermanently, "https://[::1]:2020/"},
+		"post":    {443, http.MethodPost, "http://a.com/x", http.StatusBadRequest, ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.url, nil)
+			w := httptest.NewRecorder()
+			httpsRedirect(tc.port).ServeHTTP(w, r)
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
@@ -107,6 +107,7 @@ func (templater *Templater) Render(page string) http.Handler {
 
 		auth := templater.auth.ValidateRequest(r)
 		data["user"] = auth.User
+		data["impersonator"] = auth.Impersonator
 
 		if page == "debug.tpl" {
 			tls := r.Header["X-Forwarded-Proto"]
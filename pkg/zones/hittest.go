@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package zones
+
+import (
+	"math"
+	"nvr/pkg/ffmpeg"
+)
+
+// Rect is a normalized bounding box, same coordinates as Point.
+type Rect struct {
+	Top    float64 `json:"top"`
+	Left   float64 `json:"left"`
+	Bottom float64 `json:"bottom"`
+	Right  float64 `json:"right"`
+}
+
+// RectFromDetection converts a detection rect {top, left, bottom, right}
+// that is relative to scale, for example monitor.DetectionScale.
+func RectFromDetection(r ffmpeg.Rect, scale int) Rect {
+	s := float64(scale)
+	return Rect{
+		Top:    float64(r[0]) / s,
+		Left:   float64(r[1]) / s,
+		Bottom: float64(r[2]) / s,
+		Right:  float64(r[3]) / s,
+	}
+}
+
+// Center returns the center of the rect.
+func (r Rect) Center() Point {
+	return Point{(r.Left + r.Right) / 2, (r.Top + r.Bottom) / 2}
+}
+
+func (r Rect) area() float64 {
+	return math.Abs(r.Right-r.Left) * math.Abs(r.Bottom-r.Top)
+}
+
+// Contains returns true if the point is inside the zone.
+func (z Zone) Contains(p Point) bool {
+	inside := false
+	j := len(z.Points) - 1
+	for i := 0; i < len(z.Points); i++ {
+		xi, yi := z.Points[i][0], z.Points[i][1]
+		xj, yj := z.Points[j][0], z.Points[j][1]
+		if (yi > p[1]) != (yj > p[1]) &&
+			p[0] < (xj-xi)*(p[1]-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
+
+// Overlap returns the percentage of the rect area that is inside
+// the zone, from 0 to 100. Zero if the rect doesn't have a area.
+func (z Zone) Overlap(r Rect) float64 {
+	rectArea := r.area()
+	if rectArea == 0 || len(z.Points) < minPoints {
+		return 0
+	}
+	clipped := clipPolygon(z.Points, r)
+	percent := polygonArea(clipped) / rectArea * 100
+	return math.Min(percent, 100)
+}
+
+// clipPolygon clips the polygon to the rect, Sutherland-Hodgman. The
+// polygon can be concave, the clip area must be convex which a rect is.
+func clipPolygon(polygon []Point, r Rect) []Point {
+	left, right := math.Min(r.Left, r.Right), math.Max(r.Left, r.Right)
+	top, bottom := math.Min(r.Top, r.Bottom), math.Max(r.Top, r.Bottom)
+
+	// Each edge of the rect is a inside test and a intersection function.
+	type edge struct {
+		inside    func(Point) bool
+		intersect func(a, b Point) Point
+	}
+	atX := func(x float64) func(a, b Point) Point {
+		return func(a, b Point) Point {
+			return Point{x, a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])}
+		}
+	}
+	atY := func(y float64) func(a, b Point) Point {
+		return func(a, b Point) Point {
+			return Point{a[0] + (b[0]-a[0])*(y-a[1])/(b[1]-a[1]), y}
+		}
+	}
+	edges := []edge{
+		{func(p Point) bool { return p[0] >= left }, atX(left)},
+		{func(p Point) bool { return p[0] <= right }, atX(right)},
+		{func(p Point) bool { return p[1] >= top }, atY(top)},
+		{func(p Point) bool { return p[1] <= bottom }, atY(bottom)},
+	}
+
+	output := polygon
+	for _, e := range edges {
+		input := output
+		output = nil
+		for i, cur := range input {
+			prev := input[(i+len(input)-1)%len(input)]
+			switch {
+			case e.inside(cur) && !e.inside(prev):
+				output = append(output, e.intersect(prev, cur), cur)
+			case e.inside(cur):
+				output = append(output, cur)
+			case e.inside(prev):
+				output = append(output, e.intersect(prev, cur))
+			}
+		}
+		if len(output) == 0 {
+			return nil
+		}
+	}
+	return output
+}
+
+// polygonArea returns the area of the polygon, shoelace formula.
+func polygonArea(polygon []Point) float64 {
+	var sum float64
+	j := len(polygon) - 1
+	for i := range polygon {
+		sum += (polygon[j][0] + polygon[i][0]) * (polygon[j][1] - polygon[i][1])
+		j = i
+	}
+	return math.Abs(sum) / 2
+}
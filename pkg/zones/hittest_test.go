@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package zones
+
+import (
+	"testing"
+
+	"nvr/pkg/ffmpeg"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneContains(t *testing.T) {
+	// L shape.
+	zone := Zone{Points: []Point{
+		{0.1, 0.1}, {0.5, 0.1}, {0.5, 0.5}, {0.9, 0.5}, {0.9, 0.9}, {0.1, 0.9},
+	}}
+	require.True(t, zone.Contains(Point{0.2, 0.2}))
+	require.True(t, zone.Contains(Point{0.8, 0.8}))
+	require.False(t, zone.Contains(Point{0.8, 0.2}))
+	require.False(t, zone.Contains(Point{0.05, 0.5}))
+	require.False(t, Zone{}.Contains(Point{0.5, 0.5}))
+}
+
+func TestZoneOverlap(t *testing.T) {
+	square := Zone{Points: []Point{{0, 0}, {0.5, 0}, {0.5, 0.5}, {0, 0.5}}}
+	cases := map[string]struct {
+		zone     Zone
+		rect     Rect
+		expected float64
+	}{
+		"inside":  {square, Rect{Top: 0.1, Left: 0.1, Bottom: 0.2, Right: 0.2}, 100},
+		"outside": {square, Rect{Top: 0.6, Left: 0.6, Bottom: 0.8, Right: 0.8}, 0},
+		"half":    {square, Rect{Top: 0.1, Left: 0.4, Bottom: 0.2, Right: 0.6}, 50},
+		"quarter": {square, Rect{Top: 0.4, Left: 0.4, Bottom: 0.6, Right: 0.6}, 25},
+		"covers":  {square, Rect{Top: 0, Left: 0, Bottom: 1, Right: 1}, 25},
+		"empty":   {square, Rect{Top: 0.1, Left: 0.1, Bottom: 0.1, Right: 0.2}, 0},
+		"triangle": {
+			Zone{Points: []Point{{0, 0}, {1, 0}, {0, 1}}},
+			Rect{Top: 0, Left: 0, Bottom: 1, Right: 1},
+			50,
+		},
+		"concave": {
+			// U shape, the rect covers the gap and the arms.
+			Zone{Points: []Point{
+				{0, 0}, {0.2, 0}, {0.2, 0.8}, {0.8, 0.8},
+				{0.8, 0}, {1, 0}, {1, 1}, {0, 1},
+			}},
+			Rect{Top: 0, Left: 0, Bottom: 0.5, Right: 1},
+			40,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.InDelta(t, tc.expected, tc.zone.Overlap(tc.rect), 1e-9)
+		})
+	}
+}
+
+func TestRectFromDetection(t *testing.T) {
+	rect := RectFromDetection(ffmpeg.Rect{10, 20, 30, 40}, 100)
+	require.Equal(t, Rect{Top: 0.1, Left: 0.2, Bottom: 0.3, Right: 0.4}, rect)
+	require.InDelta(t, 0.3, rect.Center()[0], 1e-9)
+	require.InDelta(t, 0.2, rect.Center()[1], 1e-9)
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+// Package zones stores named polygons of monitors. Detectors and
+// addons use the zones and the hit-testing helpers instead of
+// each having their own zone format.
+package zones
+
+import (
+	"errors"
+	"fmt"
+	"nvr/pkg/kv"
+	"regexp"
+	"sort"
+)
+
+// Point is a normalized coordinate {x, y}, {0, 0} is the top
+// left corner and {1, 1} the bottom right corner of the frame.
+type Point [2]float64
+
+// Zone is a named polygon.
+type Zone struct {
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// Zone limits.
+const (
+	maxZones     = 32
+	minPoints    = 3
+	maxPoints    = 64
+	zoneBucketID = "zones"
+)
+
+// Errors.
+var (
+	ErrZoneNotExist    = errors.New("zone does not exist")
+	ErrInvalidZone     = errors.New("invalid zone")
+	ErrInvalidZoneName = errors.New("invalid zone name")
+	ErrTooManyZones    = errors.New("too many zones")
+)
+
+var zoneNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Validate returns a error if the name is invalid, the zone has
+// less than three or too many points or a point is out of bounds.
+func (z Zone) Validate() error {
+	if !zoneNameRegex.MatchString(z.Name) {
+		return fmt.Errorf("%w: %q", ErrInvalidZoneName, z.Name)
+	}
+	if len(z.Points) < minPoints || len(z.Points) > maxPoints {
+		return fmt.Errorf("%w: %v: must have %v to %v points",
+			ErrInvalidZone, z.Name, minPoints, maxPoints)
+	}
+	for _, p := range z.Points {
+		if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+			return fmt.Errorf("%w: %v: point %v is out of bounds",
+				ErrInvalidZone, z.Name, p)
+		}
+	}
+	return nil
+}
+
+// Store stores the zones of each monitor in the database,
+// the key is the monitor ID and the value the zones.
+type Store struct {
+	db *kv.DB
+}
+
+// NewStore creates a zone store.
+func NewStore(db *kv.DB) *Store {
+	return &Store{db: db}
+}
+
+// List returns the zones of the monitor sorted by name.
+func (s *Store) List(monitorID string) ([]Zone, error) {
+	zones := []Zone{}
+	err := s.db.View(func(tx *kv.Tx) error {
+		_, err := tx.Bucket(zoneBucketID).GetJSON(monitorID, &zones)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get zones: %w", err)
+	}
+	return zones, nil
+}
+
+// All returns the zones of all monitors.
+func (s *Store) All() (map[string][]Zone, error) {
+	all := make(map[string][]Zone)
+	err := s.db.View(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(zoneBucketID)
+		return bucket.ForEach(func(monitorID string, _ []byte) error {
+			var zones []Zone
+			if _, err := bucket.GetJSON(monitorID, &zones); err != nil {
+				return err
+			}
+			all[monitorID] = zones
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get zones: %w", err)
+	}
+	return all, nil
+}
+
+// Get returns a zone of the monitor by name.
+func (s *Store) Get(monitorID string, name string) (Zone, error) {
+	zones, err := s.List(monitorID)
+	if err != nil {
+		return Zone{}, err
+	}
+	for _, zone := range zones {
+		if zone.Name == name {
+			return zone, nil
+		}
+	}
+	return Zone{}, fmt.Errorf("%w: %v", ErrZoneNotExist, name)
+}
+
+// Set creates or replaces the zone with the same name.
+func (s *Store) Set(monitorID string, zone Zone) error {
+	if err := zone.Validate(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(zoneBucketID)
+		var zones []Zone
+		if _, err := bucket.GetJSON(monitorID, &zones); err != nil {
+			return err
+		}
+
+		replaced := false
+		for i := range zones {
+			if zones[i].Name == zone.Name {
+				zones[i] = zone
+				replaced = true
+			}
+		}
+		if !replaced {
+			if len(zones) >= maxZones {
+				return fmt.Errorf("%w: max %v", ErrTooManyZones, maxZones)
+			}
+			zones = append(zones, zone)
+		}
+		sort.Slice(zones, func(i, j int) bool {
+			return zones[i].Name < zones[j].Name
+		})
+		return bucket.PutJSON(monitorID, zones)
+	})
+}
+
+// Delete deletes a zone of the monitor.
+func (s *Store) Delete(monitorID string, name string) error {
+	return s.db.Update(func(tx *kv.Tx) error {
+		bucket := tx.Bucket(zoneBucketID)
+		var zones []Zone
+		if _, err := bucket.GetJSON(monitorID, &zones); err != nil {
+			return err
+		}
+
+		for i, zone := range zones {
+			if zone.Name != name {
+				continue
+			}
+			zones = append(zones[:i], zones[i+1:]...)
+			if len(zones) == 0 {
+				return bucket.Delete(monitorID)
+			}
+			return bucket.PutJSON(monitorID, zones)
+		}
+		return fmt.Errorf("%w: %v", ErrZoneNotExist, name)
+	})
+}
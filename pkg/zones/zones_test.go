@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: GPL-2.0-or-later
+
+package zones
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"nvr/pkg/kv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nvr.db")
+	db, err := kv.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return NewStore(db), path
+}
+
+var testPoints = []Point{{0, 0}, {1, 0}, {1, 1}}
+
+func TestStore(t *testing.T) {
+	s, path := newTestStore(t)
+
+	zones, err := s.List("m1")
+	require.NoError(t, err)
+	require.Empty(t, zones)
+	require.NotNil(t, zones)
+
+	require.NoError(t, s.Set("m1", Zone{Name: "b", Points: testPoints}))
+	require.NoError(t, s.Set("m1", Zone{Name: "a", Points: testPoints}))
+	require.NoError(t, s.Set("m2", Zone{Name: "a", Points: testPoints}))
+
+	updated := []Point{{0, 0}, {0.5, 0}, {0.5, 0.5}}
+	require.NoError(t, s.Set("m1", Zone{Name: "b", Points: updated}))
+
+	zones, err = s.List("m1")
+	require.NoError(t, err)
+	require.Equal(t, []Zone{
+		{Name: "a", Points: testPoints},
+		{Name: "b", Points: updated},
+	}, zones)
+
+	zone, err := s.Get("m1", "b")
+	require.NoError(t, err)
+	require.Equal(t, updated, zone.Points)
+
+	_, err = s.Get("m1", "x")
+	require.ErrorIs(t, err, ErrZoneNotExist)
+
+	// Reopen.
+	require.NoError(t, s.db.Close())
+	db, err := kv.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	s = NewStore(db)
+
+	all, err := s.All()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Len(t, all["m1"], 2)
+
+	require.NoError(t, s.Delete("m1", "a"))
+	require.ErrorIs(t, s.Delete("m1", "a"), ErrZoneNotExist)
+	require.NoError(t, s.Delete("m2", "a"))
+
+	all, err = s.All()
+	require.NoError(t, err)
+	require.Equal(t, map[string][]Zone{"m1": {{Name: "b", Points: updated}}}, all)
+}
+
+func TestStoreSetInvalid(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	err := s.Set("m1", Zone{Name: "a b", Points: testPoints})
+	require.ErrorIs(t, err, ErrInvalidZoneName)
+
+	err = s.Set("m1", Zone{Name: "a", Points: testPoints[:2]})
+	require.ErrorIs(t, err, ErrInvalidZone)
+
+	err = s.Set("m1", Zone{Name: "a", Points: []Point{{0, 0}, {1, 0}, {1, 1.1}}})
+	require.ErrorIs(t, err, ErrInvalidZone)
+
+	for i := 0; i < maxZones; i++ {
+		require.NoError(t, s.Set("m1", Zone{Name: "z" + strconv.Itoa(i), Points: testPoints}))
+	}
+	err = s.Set("m1", Zone{Name: "x", Points: testPoints})
+	require.ErrorIs(t, err, ErrTooManyZones)
+
+	// Replacing a zone is allowed.
+	require.NoError(t, s.Set("m1", Zone{Name: "z0", Points: testPoints}))
+}
@@ -14,11 +14,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"nvr/pkg/update"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"text/template"
 
 	"gopkg.in/yaml.v3"
@@ -87,7 +89,49 @@ func start() error {
 	return startMain(*env, main, envPath)
 }
 
+// startMain runs the app until it's stopped. The app is restarted if it
+// exits to apply a staged update, or after a failed update is rolled back.
 func startMain(env configEnv, main string, envPath string) error {
+	// Redirect signals to child process.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop)
+
+	for {
+		if _, err := update.Apply(env.HomeDir); err != nil {
+			return fmt.Errorf("could not apply update: %w", err)
+		}
+
+		stopped, err := runMain(env, main, envPath, stop)
+		if stopped {
+			return err
+		}
+		if update.Pending(env.HomeDir) {
+			continue
+		}
+
+		reason := "health check failed"
+		if err != nil {
+			reason = err.Error()
+		}
+		rolledBack, rollbackErr := update.Rollback(env.HomeDir, reason)
+		if rollbackErr != nil {
+			return fmt.Errorf("could not roll back update: %w", rollbackErr)
+		}
+		if !rolledBack {
+			return err
+		}
+		fmt.Printf("update failed: %v: rolled back\n", reason)
+	}
+}
+
+// runMain runs the app once. Returns true if the app was
+// stopped by a signal or couldn't be started.
+func runMain(
+	env configEnv,
+	main string,
+	envPath string,
+	stop <-chan os.Signal,
+) (bool, error) {
 	// go run ./start/build/nvr.go -env ./config/env.yaml
 	cmd := exec.Command(env.GoBin, "run", main, "-env", envPath)
 	cmd.Dir = env.HomeDir
@@ -100,18 +144,24 @@ func startMain(env configEnv, main string, envPath string) error {
 
 	fmt.Println("starting..")
 	if err := cmd.Start(); err != nil {
-		return err
+		return true, err
 	}
 
-	// Redirect interrupt to child process.
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop)
-	go func() {
-		s := <-stop
-		cmd.Process.Signal(s) //nolint:errcheck
-	}()
-
-	return cmd.Wait()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	stopped := false
+	for {
+		select {
+		case s := <-stop:
+			cmd.Process.Signal(s) //nolint:errcheck
+			if s == os.Interrupt || s == syscall.SIGTERM {
+				stopped = true
+			}
+		case err := <-done:
+			return stopped, err
+		}
+	}
 }
 
 func parseEnv(envPath string, envYAML []byte) (*configEnv, error) {
@@ -278,6 +328,8 @@ port: 2020
 # by default. You can expose them to LAN if you need
 # to access them remotely or from another container.
 # But keep in mind that they are completely unprotected.
+# Use rtspBind and hlsBind to listen on specific IPv4 or IPv6
+# addresses instead, see docs/2_Configuration.md.
 rtspPort: 2021
 rtspPortExpose: False
 hlsPort: 2022
@@ -311,6 +363,14 @@ addons: # Uncomment to enable.
   # Documentation ../addons/doods2/README.md
   #- nvr/addons/doods2
 
+  # Object detection with pluggable backends, for example CodeProject.AI.
+  # Documentation ../addons/detector/README.md
+  #- nvr/addons/detector
+
+  # License plate recognition of detection events.
+  # Documentation ../addons/alpr/README.md
+  #- nvr/addons/alpr
+
   # Motion detection.
   # Documentation ../addons/motion/README.md
   #- nvr/addons/motion
@@ -323,10 +383,6 @@ addons: # Uncomment to enable.
   # Show system status in the web interface. CPU, RAM, disk usage.
   #- nvr/addons/status
 
-  # Watchdog.
-  # Detect and restart frozen processes.
-  #- nvr/addons/watchdog
-
   # Timeline.
   # Works best with a Chromium based browser.
   #- nvr/addons/timeline
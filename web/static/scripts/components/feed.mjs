@@ -94,6 +94,7 @@ const newFeedBtn = {
 	mute: newMuteBtn,
 	fullscreen: newFullscreenBtn,
 	recordings: newRecordingsBtn,
+	talkback: newTalkbackBtn,
 };
 
 const iconMutedPath = "static/icons/feather/volume-x.svg";
@@ -174,4 +175,112 @@ function newRecordingsBtn(path, id) {
 	};
 }
 
-export { newFeed, newFeedBtn };
+const iconMicPath = "static/icons/feather/mic.svg";
+const talkbackSampleRate = 8000;
+
+// Push-to-talk, the microphone is streamed to the camera speaker while
+// the button is held. The server expects 16-bit mono PCM at 8kHz.
+function newTalkbackBtn(monitor, canTalkback) {
+	const enabled = canTalkback && monitor["talkbackEnabled"] === "true";
+
+	let html = "";
+	if (enabled) {
+		html = `
+			<button class="js-talkback-btn feed-btn">
+				<img class="feed-btn-img icon" src="${iconMicPath}"/>
+			</button>`;
+	}
+
+	let socket, stream, context;
+	const stop = () => {
+		if (socket) {
+			socket.close();
+			socket = undefined;
+		}
+		if (stream) {
+			for (const track of stream.getTracks()) {
+				track.stop();
+			}
+			stream = undefined;
+		}
+		if (context) {
+			context.close();
+			context = undefined;
+		}
+	};
+
+	const start = async ($btn) => {
+		stop();
+		try {
+			stream = await navigator.mediaDevices.getUserMedia({ audio: true });
+		} catch (error) {
+			alert(`could not access microphone: ${error}`);
+			return;
+		}
+
+		// Use relative path.
+		const path = window.location.pathname.replace("live", "api/monitor/talkback");
+		const params = new URLSearchParams({ id: monitor["id"] });
+		socket = new WebSocket("wss://" + window.location.host + path + "?" + params);
+		socket.binaryType = "arraybuffer";
+		socket.addEventListener("close", (e) => {
+			$btn.classList.remove("feed-btn-active");
+			if (e.reason !== "") {
+				alert(`talkback: ${e.reason}`);
+			}
+		});
+
+		context = new AudioContext();
+		const source = context.createMediaStreamSource(stream);
+		const processor = context.createScriptProcessor(4096, 1, 1);
+		processor.addEventListener("audioprocess", (e) => {
+			if (!socket || socket.readyState !== WebSocket.OPEN) {
+				return;
+			}
+			const input = e.inputBuffer.getChannelData(0);
+			socket.send(encodePCM(input, context.sampleRate, talkbackSampleRate));
+		});
+		source.connect(processor);
+		processor.connect(context.destination);
+		$btn.classList.add("feed-btn-active");
+	};
+
+	return {
+		html: html,
+		init($parent) {
+			if (!enabled) {
+				return;
+			}
+			const $btn = $parent.querySelector(".js-talkback-btn");
+			$btn.addEventListener("pointerdown", () => {
+				start($btn);
+			});
+			for (const event of ["pointerup", "pointerleave"]) {
+				$btn.addEventListener(event, () => {
+					stop();
+				});
+			}
+		},
+	};
+}
+
+// Downsamples float samples by averaging and
+// encodes them as 16-bit little-endian PCM.
+function encodePCM(input, inputRate, outputRate) {
+	const ratio = inputRate / outputRate;
+	const length = Math.floor(input.length / ratio);
+	const output = new DataView(new ArrayBuffer(length * 2));
+	for (let i = 0; i < length; i++) {
+		const start = Math.floor(i * ratio);
+		const end = Math.max(Math.floor((i + 1) * ratio), start + 1);
+		let sum = 0;
+		for (let j = start; j < end; j++) {
+			sum += input[j];
+		}
+		const sample = Math.max(-1, Math.min(1, sum / (end - start)));
+		output.setInt16(i * 2, sample < 0 ? sample * 0x8000 : sample * 0x7fff, true);
+	}
+	return output.buffer;
+}
+
+export { newFeed, newFeedBtn, encodePCM };
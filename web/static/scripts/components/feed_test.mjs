@@ -1,7 +1,7 @@
 // SPDX-License-Identifier: GPL-2.0-or-later
 
 import { uidReset } from "../libs/common.mjs";
-import { newFeed, newFeedBtn } from "./feed.mjs";
+import { newFeed, newFeedBtn, encodePCM } from "./feed.mjs";
 
 describe("feed", () => {
 	test("rendering", () => {
@@ -105,3 +105,32 @@ test("fullscreenBtn", () => {
 		</button>`.replaceAll(/\s/g, "");
 	expect(actual).toBe(expected);
 });
+
+describe("talkbackBtn", () => {
+	test("rendering", () => {
+		const monitor = { talkbackEnabled: "true" };
+		const actual = newFeedBtn.talkback(monitor, true).html.replaceAll(/\s/g, "");
+		const expected = `
+			<button class="js-talkback-btn feed-btn">
+				<img
+					class="feed-btn-img icon"
+					src="static/icons/feather/mic.svg"
+				/>
+			</button>`.replaceAll(/\s/g, "");
+		expect(actual).toBe(expected);
+	});
+	test("noPermission", () => {
+		const monitor = { talkbackEnabled: "true" };
+		expect(newFeedBtn.talkback(monitor, false).html).toBe("");
+	});
+	test("disabled", () => {
+		const monitor = { talkbackEnabled: "false" };
+		expect(newFeedBtn.talkback(monitor, true).html).toBe("");
+	});
+});
+
+test("encodePCM", () => {
+	const input = new Float32Array([0, 1, -1, -1, 0.5, 0.5]);
+	const actual = new Int16Array(encodePCM(input, 16000, 8000));
+	expect(Array.from(actual)).toEqual([16383, -32768, 16383]);
+});
@@ -5,7 +5,7 @@ import { sortByName } from "./libs/common.mjs";
 import { newOptionsMenu, newOptionsBtn } from "./components/optionsMenu.mjs";
 import { newFeed, newFeedBtn } from "./components/feed.mjs";
 
-function newViewer($parent, monitors, hls) {
+function newViewer($parent, monitors, hls, canTalkback = false) {
 	let selectedMonitors = [];
 	const isMonitorSelected = (monitor) => {
 		if (selectedMonitors.length === 0) {
@@ -48,6 +48,7 @@ function newViewer($parent, monitors, hls) {
 					newFeedBtn.recordings(recordingsPath, monitor["id"]),
 					newFeedBtn.fullscreen(),
 					newFeedBtn.mute(monitor),
+					newFeedBtn.talkback(monitor, canTalkback),
 				];
 				feeds.push(newFeed(hls, monitor, preferLowRes, buttons));
 			}
@@ -108,9 +109,10 @@ function init() {
 	// Globals.
 	const groups = Groups; // eslint-disable-line no-undef
 	const monitors = Monitors; // eslint-disable-line no-undef
+	const canTalkback = CanTalkback; // eslint-disable-line no-undef
 
 	const $contentGrid = document.querySelector("#content-grid");
-	const viewer = newViewer($contentGrid, monitors, Hls);
+	const viewer = newViewer($contentGrid, monitors, Hls, canTalkback);
 
 	const $options = document.querySelector("#options-menu");
 	const buttons = [newOptionsBtn.gridSize(), resBtn(), newOptionsBtn.group(groups)];
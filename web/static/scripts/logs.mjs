@@ -15,12 +15,19 @@ function newLogger(formatLog) {
 	const $logList = document.querySelector("#log-list");
 	let logStream;
 
+	// Token of the last received entry, used to resume the feed after a reconnect.
+	let resumeToken;
+	let reconnectTimeout;
+
 	const startLogFeed = () => {
 		const parameters = new URLSearchParams({
 			levels: levels,
 			sources: sources,
 			monitors: monitors,
 		});
+		if (resumeToken) {
+			parameters.set("resume", resumeToken);
+		}
 
 		// Use relative path.
 		const path = window.location.pathname.replace("logs", "api/log/feed");
@@ -28,23 +35,41 @@ function newLogger(formatLog) {
 			"wss://" + window.location.host + path + "?" + parameters,
 		);
 
+		let connected = false;
 		logStream.addEventListener("open", () => {
+			connected = true;
 			console.log("connected...");
 		});
 
-		logStream.addEventListener("error", (error) => {
-			console.log(error);
-		});
-
-		logStream.addEventListener("message", ({ data }) => {
+		const onMessage = ({ data }) => {
 			const log = JSON.parse(data);
+			resumeToken = log.token;
 			const line = document.createElement("span");
 			line.textContent = formatLog(log);
 			$logList.insertBefore(line, $logList.childNodes[0]);
+		};
+
+		logStream.addEventListener("error", (error) => {
+			console.log(error);
+			if (connected) {
+				return;
+			}
+			// Websockets may be blocked by a proxy, fall back to server-sent events.
+			console.log("falling back to server-sent events");
+			logStream = new EventSource(path + "/poll?" + parameters);
+			logStream.addEventListener("message", onMessage);
 		});
 
+		logStream.addEventListener("message", onMessage);
+
+		const stream = logStream;
 		logStream.addEventListener("close", () => {
 			console.log("disconnected.");
+			if (!connected || stream !== logStream) {
+				return;
+			}
+			// Reconnect and get the entries that were missed.
+			reconnectTimeout = setTimeout(startLogFeed, 3000);
 		});
 	};
 
@@ -99,9 +124,13 @@ function newLogger(formatLog) {
 	return {
 		init: init,
 		reset() {
+			clearTimeout(reconnectTimeout);
 			if (logStream) {
-				logStream.close();
+				const stream = logStream;
+				logStream = undefined;
+				stream.close();
 			}
+			resumeToken = undefined;
 			lastLog = false;
 			currentTime = 0;
 			$logList.innerHTML = "";
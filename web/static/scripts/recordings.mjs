@@ -23,7 +23,7 @@ async function newViewer(monitorNameByID, $parent, timeZone, isAdmin, token) {
 		for (const rec of Object.values(recordings)) {
 			let d = {}; // Recording data.
 			d.id = rec.id;
-			d.videoPath = toAbsolutePath(`api/recording/video/${d.id}`);
+			d.videoPath = toAbsolutePath(`api/recording/playback/${d.id}`);
 			d.thumbPath = toAbsolutePath(`api/recording/thumbnail/${d.id}`);
 			d.deletePath = toAbsolutePath(`api/recording/delete/${d.id}`);
 			d.name = await monitorNameByID(d.id.slice(20));
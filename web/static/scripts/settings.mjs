@@ -646,16 +646,20 @@ function newUser(token, fields) {
 		form.reset();
 
 		let id = navElement.attributes.data.value;
-		let username, isAdmin, title;
+		let username, isAdmin, mustChangePassword, talkback, title;
 
 		if (id === "") {
 			id = randomString(16);
 			title = "Add";
 			username = "";
 			isAdmin = "false";
+			mustChangePassword = "false";
+			talkback = "false";
 		} else {
 			username = users[id]["username"];
 			isAdmin = String(users[id]["isAdmin"]);
+			mustChangePassword = String(users[id]["mustChangePassword"]);
+			talkback = String(users[id]["talkback"] === true);
 			title = username;
 		}
 
@@ -663,6 +667,8 @@ function newUser(token, fields) {
 		form.fields.id.value = id;
 		form.fields.username.set(username);
 		form.fields.isAdmin.set(isAdmin);
+		form.fields.mustChangePassword.set(mustChangePassword);
+		form.fields.talkback.set(talkback);
 	};
 
 	const renderUserList = (users) => {
@@ -708,6 +714,8 @@ function newUser(token, fields) {
 			id: form.fields.id.value,
 			username: form.fields.username.value(),
 			isAdmin: form.fields.isAdmin.value() === "true",
+			mustChangePassword: form.fields.mustChangePassword.value() === "true",
+			talkback: form.fields.talkback.value() === "true",
 			plainPassword: form.fields.password.value(),
 		};
 
@@ -18,6 +18,7 @@
 		const Monitors = JSON.parse("{{ .monitors }}");
 		const LogSources = {{ .logSources }};
 		const IsAdmin = "{{ .user.IsAdmin }}" === "true";
+		const CanTalkback = "{{ .user.CanTalkback }}" === "true";
 		const CSRFToken = "{{ .user.Token }}";
 	</script>
 {{ end }}
@@ -41,6 +41,15 @@
 				</a>
 			{{ end }}
 			{{ range .navItems }}{{ . }}{{ end }}
+			{{ if .impersonator }}
+				<div id="impersonation">
+					<button
+						onclick='fetch("api/user/impersonate/stop", { method: "post", headers: { "X-CSRF-TOKEN": CSRFToken } }).then(() => window.location.reload())'
+					>
+						Stop impersonating {{ .user.Username }}
+					</button>
+				</div>
+			{{ end }}
 			<div id="logout">
 				<button
 					onclick='if (confirm("logout?")) { window.location.href = "logout"; }'
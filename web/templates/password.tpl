@@ -0,0 +1,78 @@
+<!-- SPDX-License-Identifier: GPL-2.0-or-later -->
+
+<!DOCTYPE html>
+{{ template "html" }}
+<head>
+	{{ template "meta" . }}
+	<script type="module" defer>
+		import { fetchPut } from "./static/scripts/libs/common.mjs";
+
+		const $form = document.querySelector("#password-form");
+		$form.addEventListener("submit", async (e) => {
+			e.preventDefault();
+			const current = document.querySelector("#password-current").value;
+			const newPassword = document.querySelector("#password-new").value;
+			const repeat = document.querySelector("#password-repeat").value;
+			if (newPassword !== repeat) {
+				alert("passwords do not match");
+				return;
+			}
+
+			const ok = await fetchPut(
+				"api/user/password",
+				{ currentPassword: current, newPassword: newPassword },
+				CSRFToken, // eslint-disable-line no-undef
+				"could not change password",
+			);
+			if (ok) {
+				alert("Password changed, log in with the new password.");
+				window.location.href = "live";
+			}
+		});
+	</script>
+</head>
+<body>
+	<div id="content">
+		<form id="password-form">
+			{{ if .user.MustChangePassword }}
+			<p>You must change your password before continuing.</p>
+			{{ end }}
+			<label for="password-current">Current password</label>
+			<input id="password-current" type="password" autocomplete="current-password" required />
+			<label for="password-new">New password</label>
+			<input id="password-new" type="password" autocomplete="new-password" required />
+			<label for="password-repeat">Repeat new password</label>
+			<input id="password-repeat" type="password" autocomplete="new-password" required />
+			<button type="submit">Change password</button>
+		</form>
+	</div>
+</body>
+<style>
+	#content {
+		display: flex;
+		justify-content: center;
+		background: var(--color2);
+	}
+	#password-form {
+		display: flex;
+		flex-direction: column;
+		gap: 0.5rem;
+		width: 100%;
+		max-width: 20rem;
+		padding: 2rem 1rem;
+		font-size: 1.2rem;
+		color: var(--color-text);
+	}
+	#password-form input {
+		padding: 0.3rem;
+		font-size: 1.2rem;
+	}
+	#password-form button {
+		margin-top: 1rem;
+		padding: 0.3rem;
+		font-size: 1.2rem;
+		background: var(--color3);
+		color: var(--color-text);
+		border-radius: 0.3rem;
+	}
+</style>
@@ -31,7 +31,28 @@ if (isAdmin) {
 
 	const generalFields = {
 		diskSpace: fieldTemplate.text("Max disk usage (GB)", "5000"),
+		minRetentionDays: fieldTemplate.integer("Minimum retention (days)", "7", "0"),
 		theme: fieldTemplate.select("Theme", ["default", "light"], "default"),
+		recordSchedule: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Record schedule",
+				placeholder: "mon-fri 08:00-17:00 (optional)",
+			},
+		),
+		armSchedule: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Arm schedule",
+				placeholder: "mon-fri 18:00-07:00 (optional)",
+			},
+		),
 	};
 	const general = newGeneral(csrfToken, generalFields);
 	renderer.addCategory(general);
@@ -56,6 +77,11 @@ if (isAdmin) {
 		),
 		name: fieldTemplate.text("Name", "my_monitor"),
 		enable: fieldTemplate.toggle("Enable monitor", "true"),
+		monitorType: fieldTemplate.select(
+			"Monitor type",
+			["camera", "virtual"],
+			"camera",
+		),
 		inputOptions: newSelectCustomField([], ["", "-rtsp_transport tcp"], {
 			label: "Input options",
 		}),
@@ -79,6 +105,83 @@ if (isAdmin) {
 				placeholder: "rtsp//x.x.x.x/sub (optional)",
 			},
 		),
+		autoSubStream: fieldTemplate.toggle("Auto sub stream", "false"),
+		autoSubStreamHeight: fieldTemplate.integer("Auto sub stream height", "360", "360"),
+		backupInput: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Backup input",
+				placeholder: "rtsp://x.x.x.x/backup (optional)",
+			},
+		),
+		failoverDelay: fieldTemplate.text("Failover delay (sec)", "30", "30"),
+		credential: newField(
+			[inputRules.noSpaces],
+			{
+				input: "text",
+			},
+			{
+				label: "Credential",
+				placeholder: "credential ID (optional)",
+			},
+		),
+		transcoder: fieldTemplate.select(
+			"Transcoder",
+			["auto", "ffmpeg", "gstreamer"],
+			"auto",
+		),
+		gstreamerSourceOptions: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "GStreamer source options",
+				placeholder: "protocols=tcp latency=200 (optional)",
+			},
+		),
+		sourceInterface: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Source interface",
+				placeholder: "eth1 (optional)",
+			},
+		),
+		onvifAddress: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "ONVIF address",
+				placeholder: "http://192.168.1.10/onvif/device_service (optional)",
+			},
+		),
+		talkback: fieldTemplate.toggle("Talkback", "false"),
+		onvifUsername: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "ONVIF username",
+			},
+		),
+		onvifPassword: newField(
+			[],
+			{
+				input: "password",
+			},
+			{
+				label: "ONVIF password",
+			},
+		),
 		hwaccel: newField(
 			[],
 			{
@@ -88,6 +191,36 @@ if (isAdmin) {
 				label: "Hardware acceleration",
 			},
 		),
+		threads: newField(
+			[inputRules.noSpaces],
+			{
+				input: "text",
+			},
+			{
+				label: "Encoder threads",
+				placeholder: "2 (optional)",
+			},
+		),
+		niceness: newField(
+			[inputRules.noSpaces],
+			{
+				input: "text",
+			},
+			{
+				label: "Niceness",
+				placeholder: "10 (optional)",
+			},
+		),
+		cpuAffinity: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "CPU affinity",
+				placeholder: "0,2-3 (optional)",
+			},
+		),
 		videoEncoder: fieldTemplate.selectCustom(
 			"Video encoder",
 			[
@@ -100,10 +233,73 @@ if (isAdmin) {
 		),
 		audioEncoder: fieldTemplate.selectCustom(
 			"Audio encoder",
-			["none", "copy", "aac"],
+			["none", "copy", "aac", "auto"],
 			"none",
 		),
+		audioStream: newField(
+			[inputRules.noSpaces],
+			{
+				input: "text",
+			},
+			{
+				label: "Audio stream",
+				placeholder: "0 (optional)",
+			},
+		),
+		storageVolume: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Storage volume",
+				placeholder: "/mnt/disk2 (optional)",
+			},
+		),
+		startAfter: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Start after",
+				placeholder: "monitor IDs (optional)",
+			},
+		),
+		tags: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Tags",
+				placeholder: "garage,outdoor (optional)",
+			},
+		),
 		alwaysRecord: fieldTemplate.toggle("Always record", "false"),
+		recordSchedule: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Record schedule",
+				placeholder: "global schedule",
+			},
+		),
+		armSchedule: newField(
+			[],
+			{
+				input: "text",
+			},
+			{
+				label: "Arm schedule",
+				placeholder: "global schedule",
+			},
+		),
+		eventClip: fieldTemplate.toggle("Event clips", "false"),
+		eventClipPre: fieldTemplate.text("Event clip pre (sec)", "2", "2"),
+		eventClipPost: fieldTemplate.text("Event clip post (sec)", "5", "5"),
 		videoLength: fieldTemplate.text("Video length (min)", "15", "15"),
 		timestampOffset: fieldTemplate.integer("Timestamp offset (ms)", "500", "500"),
 		logLevel: fieldTemplate.select(
@@ -129,6 +325,10 @@ if (isAdmin) {
 		})(),
 		name: fieldTemplate.text("Name", "my_group"),
 		monitors: newSelectMonitor("settings-group-monitors"),
+		gridRows: fieldTemplate.integer("Grid rows (0=auto)", "0", "0"),
+		gridColumns: fieldTemplate.integer("Grid columns (0=auto)", "0", "0"),
+		rotationInterval: fieldTemplate.integer("Rotation interval (sec, 0=off)", "0", "0"),
+		streamQuality: fieldTemplate.select("Stream quality", ["auto", "main", "sub"], "auto"),
 	};
 
 	const group = newGroup(csrfToken, groupFields);
@@ -151,6 +351,8 @@ if (isAdmin) {
 			},
 		),
 		isAdmin: fieldTemplate.toggle("Admin"),
+		mustChangePassword: fieldTemplate.toggle("Require password change"),
+		talkback: fieldTemplate.toggle("Talkback"),
 		password: newPasswordField(),
 	};
 	const user = newUser(csrfToken, userFields);